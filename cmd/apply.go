@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/apply"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show what applying a spec would change",
+	Long: `Compare a YAML or JSON spec of storage pools, networks, network filters and VMs
+with the server and show what 'flint apply' would create, update and delete.
+Nothing is changed.
+
+Examples:
+  flint plan -f lab.yaml
+  cat lab.yaml | flint plan -f -`,
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := serverURL()
+		file, _ := cmd.Flags().GetString("file")
+
+		spec := readSpecFile(file)
+		var plan core.Plan
+		if err := doJSONRequest("POST", baseURL+"/api/plan", spec, &plan); err != nil {
+			log.Fatalf("Failed to plan: %v", err)
+		}
+		printPlan(plan)
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Bring the server to the state in a spec",
+	Long: `Create, update and delete storage pools, networks, network filters and VMs so
+the server matches a YAML or JSON spec. The plan is shown and confirmed first.
+Applying the same spec again changes nothing.
+
+  pools:
+    - name: data
+      path: /srv/flint/data
+  networks:
+    - name: lab
+      bridge: virbr-lab
+  vms:
+    - name: web-01
+      memoryMB: 2048
+      vcpus: 2
+      imageName: noble-server-cloudimg-amd64.img
+      diskSizeGB: 20
+      networkName: lab
+      disks:
+        - pool: data
+          sizeGB: 50
+    - name: old-web
+      ensure: absent
+
+Examples:
+  flint apply -f lab.yaml
+  flint apply -f lab.yaml --force`,
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := serverURL()
+		file, _ := cmd.Flags().GetString("file")
+		force, _ := cmd.Flags().GetBool("force")
+
+		spec := readSpecFile(file)
+		var plan core.Plan
+		if err := doJSONRequest("POST", baseURL+"/api/plan", spec, &plan); err != nil {
+			log.Fatalf("Failed to plan: %v", err)
+		}
+		printPlan(plan)
+		if len(plan.Actions) == 0 {
+			return
+		}
+
+		if !force {
+			fmt.Print("\nApply these changes? (y/N): ")
+			var response string
+			fmt.Scanln(&response)
+			if response != "y" && response != "Y" {
+				fmt.Println("Cancelled")
+				return
+			}
+		}
+
+		var job core.Job
+		if err := doJSONRequest("POST", baseURL+"/api/apply?async=true", spec, &job); err != nil {
+			log.Fatalf("Failed to apply: %v", err)
+		}
+		if jobStarted(cmd, job) {
+			return
+		}
+		job, err := waitForJob(job.ID, 0)
+		if err != nil {
+			log.Fatalf("Failed to wait for apply: %v", err)
+		}
+		if job.State != core.JobSucceeded {
+			log.Fatalf("Apply %s: %s", job.State, job.Error)
+		}
+
+		var result core.Plan
+		if data, err := json.Marshal(job.Result); err == nil {
+			json.Unmarshal(data, &result)
+		}
+		for _, action := range result.Actions {
+			fmt.Printf("✅ %s %s %s\n", action.Op, action.Kind, action.Name)
+		}
+		fmt.Printf("Apply complete: %d actions applied.\n", len(result.Actions))
+	},
+}
+
+// readSpecFile reads and validates a spec; "-" reads standard input
+func readSpecFile(file string) core.Spec {
+	if file == "" {
+		log.Fatalf("A spec file is required (-f)")
+	}
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read spec: %v", err)
+	}
+
+	spec, err := apply.Parse(data)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return spec
+}
+
+// printPlan shows a plan: + creates, ~ updates, - deletes
+func printPlan(plan core.Plan) {
+	if len(plan.Actions) == 0 {
+		fmt.Println("No changes. The server matches the spec.")
+	}
+	for _, action := range plan.Actions {
+		symbol := map[string]string{core.PlanCreate: "+", core.PlanUpdate: "~", core.PlanDelete: "-"}[action.Op]
+		fmt.Printf("%s %s %s\n", symbol, action.Kind, action.Name)
+		for _, change := range action.Changes {
+			switch {
+			case change.From == "":
+				fmt.Printf("    %s: %s\n", change.Field, change.To)
+			default:
+				fmt.Printf("    %s: %s → %s\n", change.Field, change.From, change.To)
+			}
+		}
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	if len(plan.Actions) > 0 {
+		fmt.Printf("\nPlan: %s.\n", planSummary(plan))
+	}
+}
+
+func planSummary(plan core.Plan) string {
+	counts := map[string]int{}
+	for _, action := range plan.Actions {
+		counts[action.Op]++
+	}
+	return fmt.Sprintf("%d to create, %d to update, %d to delete", counts[core.PlanCreate], counts[core.PlanUpdate], counts[core.PlanDelete])
+}
+
+func init() {
+	for _, c := range []*cobra.Command{planCmd, applyCmd} {
+		c.Flags().StringP("file", "f", "", "Spec file, or - for standard input")
+	}
+	applyCmd.Flags().Bool("force", false, "Skip confirmation prompt")
+	addJobFlags(applyCmd)
+}
@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/apiclient"
+	"github.com/volantvm/flint/pkg/output"
+)
+
+var (
+	serverFlag  string
+	contextFlag string
+)
+
+// cliTarget is the Flint server the CLI talks to. An empty Server means local libvirt.
+type cliTarget struct {
+	Context string
+	Server  string
+	APIKey  string
+	Host    string
+}
+
+// currentTarget resolves the Flint server from, in order of precedence, --server,
+// FLINT_SERVER, --context and the current context in ~/.flint/cli.json. The API
+// key comes from FLINT_API_KEY or the context.
+func currentTarget() cliTarget {
+	cfg, err := apiclient.LoadConfig("")
+	if err != nil {
+		log.Fatalf("Failed to load CLI config: %v", err)
+	}
+
+	var target cliTarget
+	name := contextFlag
+	if name == "" {
+		name = cfg.CurrentContext
+	}
+	if name != "" {
+		ctx, ok := cfg.Get(name)
+		if !ok {
+			log.Fatalf("Context %q not found; see 'flint context list'", name)
+		}
+		target = cliTarget{Context: ctx.Name, Server: ctx.Server, APIKey: ctx.APIKey, Host: ctx.Host}
+	}
+
+	if server := os.Getenv("FLINT_SERVER"); server != "" {
+		target.Server = server
+	}
+	if serverFlag != "" {
+		target.Server = serverFlag
+	}
+	if key := os.Getenv("FLINT_API_KEY"); key != "" {
+		target.APIKey = key
+	}
+	return target
+}
+
+// serverURL returns the Flint server for commands that only work through the API
+func serverURL() string {
+	if server := currentTarget().Server; server != "" {
+		return server
+	}
+	return apiclient.DefaultURL
+}
+
+// apiClient returns a client for commands that only work through the API
+func apiClient() *apiclient.Client {
+	target := currentTarget()
+	if target.Server == "" {
+		target.Server = apiclient.DefaultURL
+	}
+	return newAPIClient(target)
+}
+
+// remoteClient returns an API client if a Flint server is configured. Without
+// one, commands work on local libvirt.
+func remoteClient() (*apiclient.Client, bool) {
+	target := currentTarget()
+	if target.Server == "" {
+		return nil, false
+	}
+	return newAPIClient(target), true
+}
+
+// newAPIClient creates a client for a target, falling back to the key in
+// ~/.flint/config.json, which is the local server's
+func newAPIClient(target cliTarget) *apiclient.Client {
+	apiKey := target.APIKey
+	if apiKey == "" {
+		apiKey, _ = localAPIKey()
+	}
+	client := apiclient.New(target.Server, apiKey)
+	if target.Host != "" {
+		client = client.WithServer(target.Host)
+	}
+	return client
+}
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage the Flint servers the CLI talks to",
+	Long: `flint context manages named Flint servers in ~/.flint/cli.json, like kubeconfig contexts.
+
+With a current context, every command runs against that server over the REST API.
+Without one, VM, snapshot, storage and network commands use local libvirt and the
+other commands use the server at http://localhost:5550.
+
+--server and FLINT_SERVER override the context's server, FLINT_API_KEY its API key.
+--context picks a context for a single command.`,
+}
+
+var contextListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List contexts",
+	Long: `List contexts. The current one is marked with *.
+
+Examples:
+  flint context ls
+  flint context ls -o name`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := apiclient.LoadConfig("")
+		if err != nil {
+			log.Fatalf("Failed to load CLI config: %v", err)
+		}
+		if len(cfg.Contexts) == 0 && wantsTable(cmd) {
+			fmt.Println("No contexts; commands use local libvirt. Add one with 'flint context set'.")
+			return
+		}
+
+		views := make([]contextView, 0, len(cfg.Contexts))
+		for _, ctx := range cfg.Contexts {
+			views = append(views, contextView{
+				Name:      ctx.Name,
+				Server:    ctx.Server,
+				Host:      ctx.Host,
+				Current:   ctx.Name == cfg.CurrentContext,
+				HasAPIKey: ctx.APIKey != "",
+			})
+		}
+		printList(cmd, views, contextTable)
+	},
+}
+
+// contextView is a context as 'flint context list' shows it, without its API key
+type contextView struct {
+	Name      string `json:"name"`
+	Server    string `json:"server"`
+	Host      string `json:"host,omitempty"`
+	Current   bool   `json:"current"`
+	HasAPIKey bool   `json:"has_api_key"`
+}
+
+var contextTable = output.Table[contextView]{
+	Columns: []output.Column[contextView]{
+		{Header: "CURRENT", Value: func(ctx contextView) string {
+			if ctx.Current {
+				return "*"
+			}
+			return ""
+		}},
+		{Header: "NAME", Value: func(ctx contextView) string { return ctx.Name }},
+		{Header: "SERVER", Value: func(ctx contextView) string { return ctx.Server }},
+		{Header: "HOST", Value: func(ctx contextView) string { return orDash(ctx.Host) }},
+		{Header: "API KEY", Value: func(ctx contextView) string {
+			if ctx.HasAPIKey {
+				return "set"
+			}
+			return "-"
+		}},
+	},
+}
+
+var contextSetCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Add or update a context",
+	Long: `Add a context or update an existing one. Flags that are not given keep their value.
+
+Examples:
+  flint context set lab --server https://flint.lab:5550 --api-key $KEY --use
+  flint context set lab-hv2 --server https://flint.lab:5550 --api-key $KEY --host hv-02
+  flint context set lab --api-key $NEW_KEY`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := apiclient.LoadConfig("")
+		if err != nil {
+			log.Fatalf("Failed to load CLI config: %v", err)
+		}
+
+		ctx, _ := cfg.Get(args[0])
+		ctx.Name = args[0]
+		if cmd.Flags().Changed("server") {
+			ctx.Server = serverFlag
+		}
+		if cmd.Flags().Changed("api-key") {
+			ctx.APIKey, _ = cmd.Flags().GetString("api-key")
+		}
+		if cmd.Flags().Changed("host") {
+			ctx.Host, _ = cmd.Flags().GetString("host")
+		}
+		if err := cfg.Set(ctx); err != nil {
+			log.Fatalf("Failed to set context: %v", err)
+		}
+		if use, _ := cmd.Flags().GetBool("use"); use {
+			cfg.CurrentContext = ctx.Name
+		}
+		if err := cfg.Save(); err != nil {
+			log.Fatalf("Failed to save CLI config: %v", err)
+		}
+		fmt.Printf("Context '%s' saved\n", ctx.Name)
+	},
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use [name]",
+	Short: "Switch the current context",
+	Long: `Make a context current. Use --local to go back to local libvirt.
+
+Examples:
+  flint context use lab
+  flint context use --local`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		local, _ := cmd.Flags().GetBool("local")
+		if local == (len(args) == 1) {
+			log.Fatalf("Give either a context name or --local")
+		}
+
+		cfg, err := apiclient.LoadConfig("")
+		if err != nil {
+			log.Fatalf("Failed to load CLI config: %v", err)
+		}
+		if local {
+			cfg.CurrentContext = ""
+		} else if err := cfg.Use(args[0]); err != nil {
+			log.Fatalf("Failed to switch context: %v", err)
+		}
+		if err := cfg.Save(); err != nil {
+			log.Fatalf("Failed to save CLI config: %v", err)
+		}
+
+		if local {
+			fmt.Println("Using local libvirt")
+		} else {
+			fmt.Printf("Switched to context '%s'\n", args[0])
+		}
+	},
+}
+
+var contextDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a context",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := apiclient.LoadConfig("")
+		if err != nil {
+			log.Fatalf("Failed to load CLI config: %v", err)
+		}
+		if err := cfg.Delete(args[0]); err != nil {
+			log.Fatalf("Failed to delete context: %v", err)
+		}
+		if err := cfg.Save(); err != nil {
+			log.Fatalf("Failed to save CLI config: %v", err)
+		}
+		fmt.Printf("Context '%s' deleted\n", args[0])
+	},
+}
+
+var contextCurrentCmd = &cobra.Command{
+	Use:   "current",
+	Short: "Show where commands will run",
+	Long: `Show the Flint server commands will run against, after applying --server,
+--context, FLINT_SERVER and FLINT_API_KEY.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		target := currentTarget()
+		if target.Server == "" {
+			fmt.Println("Local libvirt (qemu:///system)")
+			return
+		}
+
+		name := target.Context
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Printf("Context: %s\nServer:  %s\n", name, target.Server)
+		if target.Host != "" {
+			fmt.Printf("Host:    %s\n", target.Host)
+		}
+	},
+}
+
+func init() {
+	contextCmd.AddCommand(contextListCmd)
+	contextCmd.AddCommand(contextSetCmd)
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextDeleteCmd)
+	contextCmd.AddCommand(contextCurrentCmd)
+
+	addListFlags(contextListCmd)
+	contextSetCmd.Flags().String("api-key", "", "API key or token for the server")
+	contextSetCmd.Flags().String("host", "", "Registered server to operate on, by ID or name (default: the Flint server's own host)")
+	contextSetCmd.Flags().Bool("use", false, "Also make this the current context")
+	contextUseCmd.Flags().Bool("local", false, "Clear the current context and use local libvirt")
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/connectionpool"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/fleet"
+	"github.com/volantvm/flint/pkg/output"
+	"github.com/volantvm/flint/pkg/serverregistry"
+)
+
+var fleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "Inspect resources across all registered servers",
+	Long:  "flint fleet queries every server in ~/.flint/servers.json concurrently and aggregates the results",
+}
+
+var fleetListCmd = &cobra.Command{
+	Use:     "list [vms|storage-pools|networks]",
+	Aliases: []string{"ls"},
+	Short:   "List resources across all registered servers",
+	Long: `List VMs, storage pools or networks from every registered server.
+Servers that fail or time out are reported as warnings on standard error
+without hiding the others.
+
+Examples:
+  flint fleet ls                      # VMs on every server
+  flint fleet ls networks             # Networks on every server
+  flint fleet ls --timeout 30s        # Allow slow hosts more time
+  flint fleet ls -l server_name=hv-*,state=running
+  flint fleet ls storage-pools -o json`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"vms", "storage-pools", "networks"},
+	Run: func(cmd *cobra.Command, args []string) {
+		resource := "vms"
+		if len(args) > 0 {
+			resource = args[0]
+		}
+
+		registry, err := serverregistry.NewRegistry("")
+		if err != nil {
+			log.Fatalf("Failed to load server registry: %v", err)
+		}
+		if registry.GetServerCount() == 0 {
+			log.Fatalf("No servers registered. Add one with the web UI or POST /api/servers")
+		}
+
+		pool := connectionpool.NewPool(0, 0)
+		defer pool.CloseAll()
+
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		aggregator := fleet.NewAggregator(registry, pool, timeout)
+
+		var errs []core.FleetError
+		switch resource {
+		case "vms":
+			list := aggregator.VMs(context.Background())
+			errs = list.Errors
+			printList(cmd, list.Items, fleetVMTable)
+		case "storage-pools":
+			list := aggregator.StoragePools(context.Background())
+			errs = list.Errors
+			printList(cmd, list.Items, fleetStoragePoolTable)
+		case "networks":
+			list := aggregator.Networks(context.Background())
+			errs = list.Errors
+			printList(cmd, list.Items, fleetNetworkTable)
+		default:
+			log.Fatalf("Unknown resource %q (use vms, storage-pools or networks)", resource)
+		}
+
+		for _, e := range errs {
+			reason := e.Error
+			if e.TimedOut {
+				reason = "timed out: " + reason
+			}
+			fmt.Fprintf(os.Stderr, "Warning: server %s (%s) unavailable: %s\n", e.ServerName, e.ServerID, reason)
+		}
+	},
+}
+
+var fleetVMTable = output.Table[core.FleetVM]{
+	Columns: []output.Column[core.FleetVM]{
+		{Header: "SERVER", Value: func(vm core.FleetVM) string { return vm.ServerName }},
+		{Header: "NAME", Value: func(vm core.FleetVM) string { return vm.Name }},
+		{Header: "UUID", Wide: true, Value: func(vm core.FleetVM) string { return vm.UUID }},
+		{Header: "STATE", Value: func(vm core.FleetVM) string { return vm.State }},
+		{Header: "VCPUS", Value: func(vm core.FleetVM) string { return fmt.Sprint(vm.VCPUs) }},
+		{Header: "MEMORY", Value: func(vm core.FleetVM) string { return fmt.Sprintf("%d MB", vm.MemoryKB/1024) }},
+		{Header: "IP", Value: func(vm core.FleetVM) string { return strings.Join(vm.IPAddresses, ", ") }},
+	},
+}
+
+var fleetStoragePoolTable = output.Table[core.FleetStoragePool]{
+	Columns: []output.Column[core.FleetStoragePool]{
+		{Header: "SERVER", Value: func(pool core.FleetStoragePool) string { return pool.ServerName }},
+		{Header: "NAME", Value: func(pool core.FleetStoragePool) string { return pool.Name }},
+		{Header: "STATUS", Value: func(pool core.FleetStoragePool) string { return pool.State }},
+		{Header: "CAPACITY", Value: func(pool core.FleetStoragePool) string { return formatBytes(int64(pool.CapacityB)) }},
+		{Header: "ALLOCATED", Value: func(pool core.FleetStoragePool) string { return formatBytes(int64(pool.AllocationB)) }},
+		{Header: "AVAILABLE", Value: func(pool core.FleetStoragePool) string {
+			return formatBytes(int64(pool.CapacityB) - int64(pool.AllocationB))
+		}},
+	},
+}
+
+var fleetNetworkTable = output.Table[core.FleetNetwork]{
+	Columns: []output.Column[core.FleetNetwork]{
+		{Header: "SERVER", Value: func(network core.FleetNetwork) string { return network.ServerName }},
+		{Header: "NAME", Value: func(network core.FleetNetwork) string { return network.Name }},
+		{Header: "STATUS", Value: func(network core.FleetNetwork) string { return activeLabel(network.IsActive) }},
+		{Header: "BRIDGE", Value: func(network core.FleetNetwork) string { return network.Bridge }},
+		{Header: "PERSISTENT", Value: func(network core.FleetNetwork) string { return yesNo(network.IsPersistent) }},
+	},
+}
+
+func init() {
+	fleetCmd.AddCommand(fleetListCmd)
+
+	addListFlags(fleetListCmd)
+	fleetListCmd.Flags().Duration("timeout", fleet.DefaultTimeout, "Per-server timeout (at most 2m)")
+}
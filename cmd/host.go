@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+// hostClient is what the VM, snapshot, storage and network commands need from a
+// host: *libvirtclient.Client provides it locally and *apiclient.Client through
+// a Flint server
+type hostClient interface {
+	GetVMSummaries() ([]core.VM_Summary, error)
+	GetVMDetails(uuidStr string) (core.VM_Detailed, error)
+	CreateVM(cfg core.VMCreationConfig) (core.VM_Detailed, error)
+	PerformVMAction(uuidStr string, action string) error
+	DeleteVM(uuidStr string, deleteDisks bool) error
+	CheckGuestAgentStatus(uuidStr string) (bool, error)
+	UpdateVMLabels(uuidStr string, req core.UpdateVMLabelsRequest) (core.VM_Detailed, error)
+	UpdateVM(uuidStr string, req core.UpdateVMRequest) (core.UpdateVMResult, error)
+
+	GetVMSnapshots(uuidStr string) ([]core.Snapshot, error)
+	CreateVMSnapshot(uuidStr string, cfg core.CreateSnapshotRequest) (core.Snapshot, error)
+	RevertToVMSnapshot(uuidStr string, snapshotName string) error
+
+	BackupVM(ctx context.Context, uuidStr string, req core.CreateBackupRequest, progress func(percent float64, message string)) (core.Backup, error)
+	RestoreBackup(ctx context.Context, req core.RestoreBackupRequest, progress func(percent float64, message string)) (core.VM_Detailed, error)
+	ExportVM(ctx context.Context, uuidStr string, req core.ExportVMRequest, progress func(percent float64, message string)) (core.VMExport, error)
+	ImportVM(ctx context.Context, req core.ImportVMRequest, progress func(percent float64, message string)) (core.VM_Detailed, error)
+
+	GetStoragePools() ([]core.StoragePool, error)
+	GetVolumes(poolName string) ([]core.Volume, error)
+	CreateVolume(poolName string, volConfig core.VolumeConfig) error
+	UpdateVolume(poolName string, volumeName string, config core.VolumeConfig) error
+	DeleteVolume(poolName string, volumeName string) error
+
+	GetNetworks() ([]core.Network, error)
+	CreateNetwork(name string, bridgeName string) error
+	UpdateNetwork(name string, action string) error
+	DeleteNetwork(name string) error
+
+	Close() error
+}
+
+// connectHost connects to the configured Flint server, or to local libvirt if
+// there is none
+func connectHost() (hostClient, error) {
+	if client, ok := remoteClient(); ok {
+		return client, nil
+	}
+	return libvirtclient.NewClient("qemu:///system", "isos", "templates")
+}
+
+// findVM looks a VM up by name or UUID
+func findVM(client hostClient, nameOrUUID string) (core.VM_Summary, error) {
+	vms, err := client.GetVMSummaries()
+	if err != nil {
+		return core.VM_Summary{}, fmt.Errorf("failed to get VMs: %w", err)
+	}
+	for _, vm := range vms {
+		if vm.Name == nameOrUUID || vm.UUID == nameOrUUID {
+			return vm, nil
+		}
+	}
+	return core.VM_Summary{}, fmt.Errorf("VM '%s' not found", nameOrUUID)
+}
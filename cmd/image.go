@@ -5,16 +5,29 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"text/tabwriter"
-	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/apiclient"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/imagerepository"
+	"github.com/volantvm/flint/pkg/output"
 )
 
-// getAPIKey retrieves the API key from config file
+// getAPIKey returns the API key from FLINT_API_KEY or the current context,
+// falling back to the local server's key in ~/.flint/config.json
 func getAPIKey() (string, error) {
+	if apiKey := currentTarget().APIKey; apiKey != "" {
+		return apiKey, nil
+	}
+	return localAPIKey()
+}
+
+// localAPIKey retrieves the API key from config file
+func localAPIKey() (string, error) {
 	configPath := filepath.Join(os.Getenv("HOME"), ".flint", "config.json")
 
 	// Read config file
@@ -37,7 +50,8 @@ func getAPIKey() (string, error) {
 	return apiKey.(string), nil
 }
 
-// createAuthenticatedRequest creates an HTTP request with API key authentication
+// createAuthenticatedRequest creates an HTTP request with API key authentication,
+// targeting the current context's host if it has one
 func createAuthenticatedRequest(method, url string) (*http.Request, error) {
 	apiKey, err := getAPIKey()
 	if err != nil {
@@ -51,6 +65,10 @@ func createAuthenticatedRequest(method, url string) (*http.Request, error) {
 
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "flint-cli")
+	if host := currentTarget().Host; host != "" {
+		req.Header.Set(apiclient.ServerHeader, host)
+	}
 
 	return req, nil
 }
@@ -64,14 +82,21 @@ var imageCmd = &cobra.Command{
 var imageListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available cloud images",
-	Long:  "List all available cloud images in the repository with download status",
+	Long: `List all available cloud images in the repository and its catalogs with download status.
+
+Examples:
+  flint image list
+  flint image list --arch arm64 -o wide
+  flint image list -l downloaded=true -o name
+  flint image list -l os=ubuntu --sort-by .version`,
 	Run: func(cmd *cobra.Command, args []string) {
-		baseURL, _ := cmd.Flags().GetString("server")
-		if baseURL == "" {
-			baseURL = "http://localhost:5550"
+		baseURL := serverURL()
+		endpoint := baseURL + "/api/image-repository"
+		if arch, _ := cmd.Flags().GetString("arch"); arch != "" {
+			endpoint += "?arch=" + url.QueryEscape(arch)
 		}
 
-		req, err := createAuthenticatedRequest("GET", baseURL+"/api/image-repository")
+		req, err := createAuthenticatedRequest("GET", endpoint)
 		if err != nil {
 			log.Fatalf("Authentication failed: %v", err)
 		}
@@ -90,34 +115,45 @@ var imageListCmd = &cobra.Command{
 			log.Fatalf("Server returned error: %s", resp.Status)
 		}
 
-		var images []map[string]interface{}
+		var images []repositoryImage
 		if err := json.NewDecoder(resp.Body).Decode(&images); err != nil {
 			log.Fatalf("Failed to decode response: %v", err)
 		}
 
-		// Display in table format
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "ID\tNAME\tOS\tVERSION\tARCH\tSIZE\tSTATUS")
-		fmt.Fprintln(w, "---\t----\t--\t-------\t----\t----\t------")
-
-		for _, img := range images {
-			id := img["id"].(string)
-			name := img["name"].(string)
-			os := img["os"].(string)
-			version := img["version"].(string)
-			arch := img["architecture"].(string)
-			size := img["size"].(string)
-			
-			status := "Available"
-			if downloaded, ok := img["downloaded"].(bool); ok && downloaded {
-				status = "Downloaded"
-			}
+		printList(cmd, images, imageTable)
+	},
+}
 
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
-				id, name, os, version, arch, size, status)
-		}
-		w.Flush()
+// repositoryImage is an image as GET /api/image-repository lists it
+type repositoryImage struct {
+	imagerepository.CloudImage
+	Downloaded bool `json:"downloaded"`
+}
+
+var imageTable = output.Table[repositoryImage]{
+	Columns: []output.Column[repositoryImage]{
+		{Header: "ID", Value: func(img repositoryImage) string { return img.ID }},
+		{Header: "NAME", Value: func(img repositoryImage) string { return img.Name }},
+		{Header: "OS", Value: func(img repositoryImage) string { return img.OS }},
+		{Header: "VERSION", Value: func(img repositoryImage) string { return img.Version }},
+		{Header: "ARCH", Value: func(img repositoryImage) string { return img.Architecture }},
+		{Header: "TYPE", Wide: true, Value: func(img repositoryImage) string { return img.Type }},
+		{Header: "SIZE", Value: func(img repositoryImage) string { return fmt.Sprintf("%.1f GB", img.SizeGB) }},
+		{Header: "CATALOG", Value: func(img repositoryImage) string {
+			if img.Catalog == "" {
+				return "built-in"
+			}
+			return img.Catalog
+		}},
+		{Header: "STATUS", Value: func(img repositoryImage) string {
+			if img.Downloaded {
+				return "Downloaded"
+			}
+			return "Available"
+		}},
+		{Header: "URL", Wide: true, Value: func(img repositoryImage) string { return img.URL }},
 	},
+	Name: func(img repositoryImage) string { return img.ID },
 }
 
 var imageDownloadCmd = &cobra.Command{
@@ -127,11 +163,7 @@ var imageDownloadCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		imageID := args[0]
-		baseURL, _ := cmd.Flags().GetString("server")
-		if baseURL == "" {
-			baseURL = "http://localhost:5550"
-		}
-
+		baseURL := serverURL()
 		// Start download
 		req, err := createAuthenticatedRequest("POST", baseURL+"/api/image-repository/"+imageID+"/download")
 		if err != nil {
@@ -152,45 +184,32 @@ var imageDownloadCmd = &cobra.Command{
 			log.Fatalf("Failed to start download: %s", resp.Status)
 		}
 
-		fmt.Printf("Download started for image: %s\n", imageID)
-		fmt.Println("Use 'flint image status' to check progress")
+		var started struct {
+			JobID string `json:"jobId"`
+		}
+		json.NewDecoder(resp.Body).Decode(&started)
+		if jobStarted(cmd, core.Job{ID: started.JobID}) {
+			return
+		}
+
+		fmt.Printf("Download started for image: %s (job %s)\n", imageID, started.JobID)
 
 		// Optionally wait for completion if --wait flag is set
 		wait, _ := cmd.Flags().GetBool("wait")
-		if wait {
-			fmt.Println("Waiting for download to complete...")
-			for {
-				time.Sleep(2 * time.Second)
-				
-				statusReq, err := createAuthenticatedRequest("GET", baseURL+"/api/image-repository/"+imageID+"/status")
-				if err != nil {
-					log.Printf("Authentication failed for status check: %v", err)
-					continue
-				}
-				statusResp, err := client.Do(statusReq)
-				if err != nil {
-					log.Printf("Failed to check status: %v", err)
-					continue
-				}
-
-				var status map[string]interface{}
-				json.NewDecoder(statusResp.Body).Decode(&status)
-				statusResp.Body.Close()
-
-				if downloading, ok := status["downloading"].(bool); !ok || !downloading {
-					if downloaded, ok := status["downloaded"].(bool); ok && downloaded {
-						fmt.Println("✅ Download completed successfully!")
-					} else {
-						fmt.Println("❌ Download failed")
-					}
-					break
-				}
+		if !wait {
+			fmt.Println("Use 'flint image status' or 'flint job wait' to check progress")
+			return
+		}
 
-				if progress, ok := status["progress"].(float64); ok {
-					fmt.Printf("\rProgress: %.1f%%", progress*100)
-				}
-			}
+		fmt.Println("Waiting for download to complete...")
+		job, err := waitForJob(started.JobID, 0)
+		if err != nil {
+			log.Fatalf("Failed to check status: %v", err)
+		}
+		if job.State != core.JobSucceeded {
+			log.Fatalf("❌ Download %s: %s", job.State, job.Error)
 		}
+		fmt.Println("✅ Download completed successfully!")
 	},
 }
 
@@ -200,11 +219,7 @@ var imageStatusCmd = &cobra.Command{
 	Long:  "Check the download status and progress of a cloud image",
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		baseURL, _ := cmd.Flags().GetString("server")
-		if baseURL == "" {
-			baseURL = "http://localhost:5550"
-		}
-
+		baseURL := serverURL()
 		if len(args) == 0 {
 			// Show status for all images
 			req, err := createAuthenticatedRequest("GET", baseURL+"/api/image-repository")
@@ -287,9 +302,8 @@ func init() {
 	imageCmd.AddCommand(imageDownloadCmd)
 	imageCmd.AddCommand(imageStatusCmd)
 
-	// Add server flag to all image commands
-	imageListCmd.Flags().String("server", "http://localhost:5550", "Flint server URL")
-	imageDownloadCmd.Flags().String("server", "http://localhost:5550", "Flint server URL")
-	imageDownloadCmd.Flags().Bool("wait", false, "Wait for download to complete")
-	imageStatusCmd.Flags().String("server", "http://localhost:5550", "Flint server URL")
+	// Add flags
+	addListFlags(imageListCmd)
+	imageListCmd.Flags().String("arch", "", "Only list images for this architecture, e.g. amd64 or arm64")
+	addJobFlags(imageDownloadCmd)
 }
\ No newline at end of file
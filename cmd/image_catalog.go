@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/imagerepository"
+	"github.com/volantvm/flint/pkg/output"
+)
+
+var imageCatalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Manage image catalogs",
+	Long: `Image catalogs add images to the repository next to the built-in ones. A catalog
+is a YAML or JSON file, on the server or at an HTTP(S) URL, listing images:
+
+  images:
+    - id: golden-web-2025.10
+      name: Golden Web Base
+      url: https://images.internal/golden/web-2025.10.qcow2
+      checksum: sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+      os: Ubuntu
+      version: "24.04"
+      architecture: amd64
+
+A simplestreams index or products file, such as the one of cloud-images.ubuntu.com,
+can be used as a catalog too. A catalog image with the ID of a built-in image
+replaces it.`,
+}
+
+var imageCatalogAddCmd = &cobra.Command{
+	Use:   "add [name] [location]",
+	Short: "Add an image catalog",
+	Long: `Add a catalog from a file on the server or an HTTP(S) URL. The catalog is read
+right away, and is not added if that fails.
+
+Examples:
+  flint image catalog add golden /etc/flint/golden-images.yaml
+  flint image catalog add ubuntu https://cloud-images.ubuntu.com/releases/streams/v1/index.json --arch amd64`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := serverURL()
+		arch, _ := cmd.Flags().GetString("arch")
+
+		location := args[1]
+		if !strings.Contains(location, "://") && !filepath.IsAbs(location) {
+			log.Fatalf("Location must be a URL or an absolute path on the server: %s", location)
+		}
+
+		var catalog imagerepository.Catalog
+		req := imagerepository.CatalogRequest{Name: args[0], Location: location, Architecture: arch}
+		if err := doJSONRequest("POST", baseURL+"/api/image-repository/catalogs", req, &catalog); err != nil {
+			log.Fatalf("Failed to add catalog: %v", err)
+		}
+		fmt.Printf("✅ Catalog '%s' added with %d images\n", catalog.Name, catalog.ImageCount)
+	},
+}
+
+var imageCatalogListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List image catalogs",
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := serverURL()
+
+		var catalogs []imagerepository.Catalog
+		if err := doJSONRequest("GET", baseURL+"/api/image-repository/catalogs", nil, &catalogs); err != nil {
+			log.Fatalf("Failed to list catalogs: %v", err)
+		}
+		if len(catalogs) == 0 && wantsTable(cmd) {
+			fmt.Println("No image catalogs. Add one with 'flint image catalog add'.")
+			return
+		}
+		printCatalogs(cmd, catalogs)
+	},
+}
+
+var imageCatalogRefreshCmd = &cobra.Command{
+	Use:   "refresh [name]",
+	Short: "Refresh one or all image catalogs",
+	Long: `Read catalogs again to pick up new images. Catalogs that cannot be read keep the
+images of their last successful refresh.
+
+Examples:
+  flint image catalog refresh
+  flint image catalog refresh golden`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := serverURL()
+
+		var catalogs []imagerepository.Catalog
+		if len(args) == 1 {
+			var catalog imagerepository.Catalog
+			if err := doJSONRequest("POST", baseURL+"/api/image-repository/catalogs/"+url.PathEscape(args[0])+"/refresh", nil, &catalog); err != nil {
+				log.Fatalf("Failed to refresh catalog: %v", err)
+			}
+			catalogs = append(catalogs, catalog)
+		} else if err := doJSONRequest("POST", baseURL+"/api/image-repository/catalogs/refresh", nil, &catalogs); err != nil {
+			log.Fatalf("Failed to refresh catalogs: %v", err)
+		}
+
+		printCatalogs(cmd, catalogs)
+		for _, catalog := range catalogs {
+			if catalog.LastError != "" {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+var imageCatalogRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove an image catalog",
+	Long:  "Remove a catalog from the repository. Images already downloaded from it are kept.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := serverURL()
+
+		if err := doJSONRequest("DELETE", baseURL+"/api/image-repository/catalogs/"+url.PathEscape(args[0]), nil, nil); err != nil {
+			log.Fatalf("Failed to remove catalog: %v", err)
+		}
+		fmt.Printf("✅ Catalog '%s' removed\n", args[0])
+	},
+}
+
+var catalogTable = output.Table[imagerepository.Catalog]{
+	Columns: []output.Column[imagerepository.Catalog]{
+		{Header: "NAME", Value: func(catalog imagerepository.Catalog) string { return catalog.Name }},
+		{Header: "IMAGES", Value: func(catalog imagerepository.Catalog) string { return fmt.Sprint(catalog.ImageCount) }},
+		{Header: "ARCH", Value: func(catalog imagerepository.Catalog) string {
+			if catalog.Architecture == "" {
+				return "all"
+			}
+			return catalog.Architecture
+		}},
+		{Header: "LAST REFRESH", Value: func(catalog imagerepository.Catalog) string {
+			if catalog.LastRefresh == nil {
+				return "never"
+			}
+			return catalog.LastRefresh.Local().Format(time.DateTime)
+		}},
+		{Header: "STATUS", Value: func(catalog imagerepository.Catalog) string {
+			if catalog.LastError != "" {
+				return "Error"
+			}
+			return "OK"
+		}},
+		{Header: "LOCATION", Value: func(catalog imagerepository.Catalog) string { return catalog.Location }},
+	},
+}
+
+// printCatalogs shows catalogs with the command's output flags. Tables have the
+// errors of failed refreshes below them.
+func printCatalogs(cmd *cobra.Command, catalogs []imagerepository.Catalog) {
+	printList(cmd, catalogs, catalogTable)
+	if !wantsTable(cmd) {
+		return
+	}
+
+	for _, catalog := range catalogs {
+		if catalog.LastError != "" {
+			fmt.Printf("\n%s: %s\n", catalog.Name, catalog.LastError)
+		}
+	}
+}
+
+func init() {
+	imageCmd.AddCommand(imageCatalogCmd)
+	imageCatalogCmd.AddCommand(imageCatalogAddCmd)
+	imageCatalogCmd.AddCommand(imageCatalogListCmd)
+	imageCatalogCmd.AddCommand(imageCatalogRefreshCmd)
+	imageCatalogCmd.AddCommand(imageCatalogRemoveCmd)
+
+	addListFlags(imageCatalogListCmd)
+	imageCatalogAddCmd.Flags().String("arch", "", "Only keep images for this architecture, e.g. amd64 or arm64")
+}
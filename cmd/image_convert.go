@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+var imageInspectCmd = &cobra.Command{
+	Use:   "inspect [image]",
+	Short: "Show the format, sizes and users of an image",
+	Long: `Show what qemu-img reports about an image in the library, and the volumes and
+VMs that need it. Images used as a backing file cannot be deleted or replaced.
+
+Examples:
+  flint image inspect noble-server-cloudimg-amd64.img
+  flint image inspect noble-server-cloudimg-amd64.img -o jsonpath='{.info.format}'`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := serverURL()
+
+		var details core.ImageDetails
+		if err := doJSONRequest("GET", baseURL+"/api/images/"+url.PathEscape(args[0]), nil, &details); err != nil {
+			log.Fatalf("Failed to inspect image: %v", err)
+		}
+		if printObject(cmd, details) {
+			return
+		}
+
+		fmt.Printf("Name:          %s\n", details.Name)
+		fmt.Printf("Path:          %s\n", details.Path)
+		fmt.Printf("Type:          %s\n", details.Type)
+		if info := details.Info; info != nil {
+			fmt.Printf("Format:        %s\n", info.Format)
+			fmt.Printf("Virtual size:  %s\n", formatBackupSize(int64(info.VirtualSizeB)))
+			fmt.Printf("Disk usage:    %s\n", formatBackupSize(int64(info.ActualSizeB)))
+			if info.ClusterSize > 0 {
+				fmt.Printf("Cluster size:  %s\n", formatBackupSize(int64(info.ClusterSize)))
+			}
+			if info.Compat != "" {
+				fmt.Printf("qcow2 compat:  %s\n", info.Compat)
+			}
+			if info.Dirty || info.Corrupt {
+				fmt.Printf("Health:        dirty=%t corrupt=%t (run qemu-img check -r all)\n", info.Dirty, info.Corrupt)
+			}
+			for i, backing := range info.BackingChain {
+				fmt.Printf("Backing [%d]:   %s (%s)\n", i, backing.Path, backing.Format)
+			}
+		}
+		if len(details.UsedBy) == 0 {
+			fmt.Println("Used by:       nothing")
+		}
+		for _, user := range details.UsedBy {
+			fmt.Printf("Used by:       %s %s (%s)\n", user.Type, user.Name, user.Path)
+		}
+	},
+}
+
+var imageConvertCmd = &cobra.Command{
+	Use:   "convert [image]",
+	Short: "Convert, compress or sparsify an image",
+	Long: `Rewrite an image in the library with qemu-img. By default the image is converted
+to qcow2 from raw, VMDK, VDI, VHDX or VHD, and saved under its name with a .qcow2
+extension. --compress writes compressed qcow2 and --sparsify drops zeroed blocks
+while keeping the format; both replace the image unless --name is given. Images
+that volumes or VMs still need can only be written to a new name.
+
+Examples:
+  flint image convert appliance.vmdk
+  flint image convert debian-12.raw --name debian-12-base.qcow2
+  flint image convert noble.img --compress --name noble-small.img
+  flint image convert web-base.qcow2 --sparsify`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := serverURL()
+		name, _ := cmd.Flags().GetString("name")
+		compress, _ := cmd.Flags().GetBool("compress")
+		sparsify, _ := cmd.Flags().GetBool("sparsify")
+
+		req := core.ConvertImageRequest{Operation: core.ImageOpConvert, Name: name}
+		switch {
+		case compress && sparsify:
+			log.Fatalf("--compress and --sparsify cannot be combined")
+		case compress:
+			req.Operation = core.ImageOpCompress
+		case sparsify:
+			req.Operation = core.ImageOpSparsify
+		}
+
+		var job core.Job
+		endpoint := baseURL + "/api/images/" + url.PathEscape(args[0]) + "/convert?async=true"
+		if err := doJSONRequest("POST", endpoint, req, &job); err != nil {
+			log.Fatalf("Failed to start conversion: %v", err)
+		}
+		if jobStarted(cmd, job) {
+			return
+		}
+		job, err := waitForJob(job.ID, 0)
+		if err != nil {
+			log.Fatalf("Failed to wait for conversion: %v", err)
+		}
+		if job.State != core.JobSucceeded {
+			log.Fatalf("Conversion %s: %s", job.State, job.Error)
+		}
+
+		var image core.Image
+		if data, err := json.Marshal(job.Result); err == nil {
+			json.Unmarshal(data, &image)
+		}
+		fmt.Printf("✅ Image '%s' written (%s)\n", image.Name, formatBackupSize(int64(image.SizeB)))
+	},
+}
+
+func init() {
+	imageCmd.AddCommand(imageInspectCmd)
+	imageCmd.AddCommand(imageConvertCmd)
+
+	addOutputFlags(imageInspectCmd)
+	imageConvertCmd.Flags().String("name", "", "Name of the result (default: see above)")
+	imageConvertCmd.Flags().Bool("compress", false, "Write compressed qcow2")
+	imageConvertCmd.Flags().Bool("sparsify", false, "Drop zeroed blocks, keeping the format")
+	addJobFlags(imageConvertCmd)
+}
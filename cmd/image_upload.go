@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// uploadRetries is how many times a failed chunk is retried before giving up
+const uploadRetries = 5
+
+var imageUploadCmd = &cobra.Command{
+	Use:   "upload [file]",
+	Short: "Upload a disk image or ISO into the image library",
+	Long: `Upload a local qcow2, raw or ISO image into the flint-image-library pool of a
+Flint server. The file is sent in chunks through the API and streamed into the pool
+over the server's libvirt connection, so it works for remote (qemu+ssh://) hosts too.
+
+The format is detected from the file's contents, and the SHA-256 of the file is
+verified by the server once all data has arrived. Interrupted uploads resume where
+they stopped when the command is run again.
+
+Examples:
+  flint image upload debian-12.qcow2
+  flint image upload ./ubuntu-24.04-live-server-amd64.iso --host hypervisor-2
+  flint image upload disk.img --name web-base.img --chunk-size 256`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		baseURL := serverURL()
+		host, _ := cmd.Flags().GetString("host")
+		name, _ := cmd.Flags().GetString("name")
+		sum, _ := cmd.Flags().GetString("sha256")
+		noVerify, _ := cmd.Flags().GetBool("no-verify")
+		chunkMB, _ := cmd.Flags().GetInt("chunk-size")
+		if chunkMB < 1 {
+			log.Fatalf("--chunk-size must be at least 1 MiB")
+		}
+		chunkSize := int64(chunkMB) << 20
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", args[0], err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", args[0], err)
+		}
+		if !info.Mode().IsRegular() || info.Size() == 0 {
+			log.Fatalf("%s is not a non-empty regular file", args[0])
+		}
+		if name == "" {
+			name = filepath.Base(args[0])
+		}
+
+		if sum == "" && !noVerify {
+			fmt.Printf("Computing SHA-256 of %s...\n", args[0])
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err != nil {
+				log.Fatalf("Failed to read %s: %v", args[0], err)
+			}
+			sum = hex.EncodeToString(h.Sum(nil))
+		}
+
+		api := imageUploadAPI{baseURL: strings.TrimRight(baseURL, "/"), host: host}
+		upload, resumed, err := api.begin(core.CreateImageUploadRequest{Name: name, SizeBytes: info.Size(), SHA256: sum})
+		if err != nil {
+			log.Fatalf("Failed to start upload: %v", err)
+		}
+		if resumed {
+			fmt.Printf("Resuming upload of '%s' at %s\n", name, formatBackupSize(upload.Offset))
+		} else {
+			fmt.Printf("Uploading %s as '%s'...\n", args[0], name)
+		}
+
+		started := time.Now()
+		startOffset := upload.Offset
+		offset := upload.Offset
+		progress := func(sent int64) {
+			printUploadProgress(offset+sent, info.Size(), startOffset, started)
+		}
+
+		for !upload.Completed {
+			length := min(chunkSize, info.Size()-offset)
+			var chunkErr error
+			for attempt := 0; ; attempt++ {
+				body := &progressReader{r: io.NewSectionReader(f, offset, length), report: progress}
+				upload, chunkErr = api.patch(upload.ID, offset, length, body)
+				if chunkErr == nil || attempt == uploadRetries || !retryableUpload(chunkErr) {
+					break
+				}
+				time.Sleep(time.Duration(attempt+1) * 2 * time.Second)
+				// The server may have kept part of the chunk or none of it
+				current, err := api.get(upload.ID)
+				if err == nil {
+					upload = current
+					if upload.Completed {
+						chunkErr = nil
+						break
+					}
+					offset = current.Offset
+					length = min(chunkSize, info.Size()-offset)
+				}
+			}
+			if chunkErr != nil {
+				fmt.Println()
+				log.Fatalf("Upload failed: %v", chunkErr)
+			}
+			offset = upload.Offset
+			progress(0)
+		}
+		fmt.Println()
+
+		image := upload.Image
+		if image == nil {
+			log.Fatalf("Upload finished without an image")
+		}
+		elapsed := time.Since(started).Round(time.Second)
+		fmt.Printf("✅ Uploaded '%s' (%s, %s) in %s\n", image.Name, upload.Format, formatBackupSize(int64(image.SizeB)), elapsed)
+		if sum != "" {
+			fmt.Printf("   sha256 %s verified\n", sum)
+		}
+	},
+}
+
+// imageUploadAPI talks to the resumable upload endpoints of a Flint server
+type imageUploadAPI struct {
+	baseURL string
+	host    string // Registered server to upload to, sent as X-Flint-Server
+}
+
+// uploadAPIError is an error response from the upload API
+type uploadAPIError struct {
+	status  int
+	message string
+}
+
+func (e *uploadAPIError) Error() string {
+	if e.message != "" {
+		return fmt.Sprintf("%s: %s", http.StatusText(e.status), e.message)
+	}
+	return http.StatusText(e.status)
+}
+
+// begin resumes an unfinished upload of the same image or starts a new one
+func (a imageUploadAPI) begin(req core.CreateImageUploadRequest) (core.ImageUpload, bool, error) {
+	var uploads []core.ImageUpload
+	if err := a.do("GET", "/api/images/uploads", nil, -1, &uploads); err != nil {
+		return core.ImageUpload{}, false, err
+	}
+	for _, u := range uploads {
+		if !u.Completed && u.Name == req.Name && u.SizeBytes == req.SizeBytes && u.SHA256 == req.SHA256 {
+			return u, true, nil
+		}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return core.ImageUpload{}, false, err
+	}
+	var upload core.ImageUpload
+	err = a.do("POST", "/api/images/uploads", bytes.NewReader(body), int64(len(body)), &upload)
+	return upload, false, err
+}
+
+func (a imageUploadAPI) get(id string) (core.ImageUpload, error) {
+	var upload core.ImageUpload
+	err := a.do("GET", "/api/images/uploads/"+id, nil, -1, &upload)
+	return upload, err
+}
+
+// patch sends one chunk starting at offset
+func (a imageUploadAPI) patch(id string, offset, length int64, body io.Reader) (core.ImageUpload, error) {
+	var upload core.ImageUpload
+	err := a.doWithHeaders("PATCH", "/api/images/uploads/"+id, body, length, &upload, map[string]string{
+		"Upload-Offset": strconv.FormatInt(offset, 10),
+		"Content-Type":  "application/offset+octet-stream",
+	})
+	if err != nil {
+		upload.ID = id
+	}
+	return upload, err
+}
+
+func (a imageUploadAPI) do(method, path string, body io.Reader, length int64, out interface{}) error {
+	return a.doWithHeaders(method, path, body, length, out, nil)
+}
+
+func (a imageUploadAPI) doWithHeaders(method, path string, body io.Reader, length int64, out interface{}, headers map[string]string) error {
+	req, err := createAuthenticatedRequest(method, a.baseURL+path)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	if body != nil {
+		req.Body = io.NopCloser(body)
+		req.ContentLength = length
+	}
+	if a.host != "" {
+		req.Header.Set("X-Flint-Server", a.host)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("authentication failed. Please run 'flint api-key' to get your API key")
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return &uploadAPIError{status: resp.StatusCode, message: apiErr.Error}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// retryableUpload reports whether a chunk is worth sending again: network
+// errors, server errors and offset conflicts are; rejected images are not
+func retryableUpload(err error) bool {
+	var apiErr *uploadAPIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.status == http.StatusConflict || apiErr.status >= 500
+}
+
+// printUploadProgress draws a progress bar with the transfer rate of this run
+func printUploadProgress(done, total, startOffset int64, started time.Time) {
+	const width = 30
+	filled := int(float64(width) * float64(done) / float64(total))
+	rate := ""
+	if elapsed := time.Since(started).Seconds(); elapsed > 0 {
+		rate = formatBackupSize(int64(float64(done-startOffset)/elapsed)) + "/s"
+	}
+	fmt.Printf("\r  [%s%s] %5.1f%%  %s / %s  %-12s", strings.Repeat("#", filled), strings.Repeat(".", width-filled),
+		float64(done)*100/float64(total), formatBackupSize(done), formatBackupSize(total), rate)
+}
+
+// progressReader reports how many bytes have been read through it
+type progressReader struct {
+	r      io.Reader
+	n      int64
+	report func(int64)
+	last   time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	if time.Since(p.last) > 200*time.Millisecond {
+		p.last = time.Now()
+		p.report(p.n)
+	}
+	return n, err
+}
+
+func init() {
+	imageCmd.AddCommand(imageUploadCmd)
+
+	imageUploadCmd.Flags().String("host", "", "Registered server to upload to (default: the server's own libvirt connection)")
+	imageUploadCmd.Flags().String("name", "", "Name of the image in the library (default: the file name)")
+	imageUploadCmd.Flags().String("sha256", "", "Expected SHA-256 of the file (default: computed locally)")
+	imageUploadCmd.Flags().Bool("no-verify", false, "Skip computing and verifying the checksum")
+	imageUploadCmd.Flags().Int("chunk-size", 64, "Chunk size in MiB")
+}
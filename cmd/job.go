@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/apiclient"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/output"
+)
+
+var jobCmd = &cobra.Command{
+	Use:   "job",
+	Short: "Track background jobs on a Flint server",
+	Long:  "flint job lists, inspects, waits for and cancels slow operations such as VM creation and image downloads",
+}
+
+var jobListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List background jobs",
+	Long: `List jobs tracked by the Flint server, newest first.
+
+Examples:
+  flint job ls
+  flint job ls --state running
+  flint job ls --type image.download -o json
+  flint job ls -l target=web-* --sort-by .created_at`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jobType, _ := cmd.Flags().GetString("type")
+		state, _ := cmd.Flags().GetString("state")
+
+		filter := apiclient.JobFilter{Type: jobType, State: core.JobState(state)}
+		jobs, err := apiClient().ListJobs(context.Background(), filter)
+		if err != nil {
+			log.Fatalf("Failed to list jobs: %v", err)
+		}
+
+		printList(cmd, jobs, jobTable)
+	},
+}
+
+var jobTable = output.Table[core.Job]{
+	Columns: []output.Column[core.Job]{
+		{Header: "ID", Value: func(job core.Job) string { return job.ID }},
+		{Header: "TYPE", Value: func(job core.Job) string { return job.Type }},
+		{Header: "TARGET", Value: func(job core.Job) string { return job.Target }},
+		{Header: "SERVER", Wide: true, Value: func(job core.Job) string { return orDash(job.ServerID) }},
+		{Header: "STATE", Value: func(job core.Job) string { return string(job.State) }},
+		{Header: "PROGRESS", Value: func(job core.Job) string { return fmt.Sprintf("%.0f%%", job.Progress) }},
+		{Header: "MESSAGE", Wide: true, Value: func(job core.Job) string { return orDash(job.Message) }},
+		{Header: "CREATED", Value: func(job core.Job) string { return job.CreatedAt.Format("2006-01-02 15:04:05") }},
+	},
+}
+
+var jobGetCmd = &cobra.Command{
+	Use:   "get [job-id]",
+	Short: "Show a background job",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		job, err := apiClient().GetJob(context.Background(), args[0])
+		if err != nil {
+			log.Fatalf("Failed to get job: %v", err)
+		}
+
+		// A job has no table view; it is shown as JSON unless -o asks otherwise
+		if printObject(cmd, job) {
+			return
+		}
+		jsonData, _ := json.MarshalIndent(job, "", "  ")
+		fmt.Println(string(jsonData))
+	},
+}
+
+var jobWaitCmd = &cobra.Command{
+	Use:   "wait [job-id]",
+	Short: "Wait for a background job to finish",
+	Long: `Wait for a job to finish, printing its progress. Exits non-zero if the job fails or is cancelled.
+
+Examples:
+  flint job wait 3f6c2a1e-...
+  flint job wait 3f6c2a1e-... --timeout 10m`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		job, err := waitForJob(args[0], timeout)
+		if err != nil {
+			log.Fatalf("Failed to wait for job: %v", err)
+		}
+		if job.State != core.JobSucceeded {
+			log.Fatalf("Job %s %s: %s", job.ID, job.State, job.Error)
+		}
+		fmt.Printf("✅ Job %s succeeded\n", job.ID)
+	},
+}
+
+var jobCancelCmd = &cobra.Command{
+	Use:   "cancel [job-id]",
+	Short: "Cancel a queued or running job",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		job, err := apiClient().CancelJob(context.Background(), args[0])
+		if err != nil {
+			log.Fatalf("Failed to cancel job: %v", err)
+		}
+		fmt.Printf("Cancellation requested for job %s\n", job.ID)
+	},
+}
+
+// addJobFlags adds --async and --wait to a command whose operation runs as a
+// job on the Flint server
+func addJobFlags(cmd *cobra.Command) {
+	if cmd.Flags().Lookup("async") != nil {
+		return
+	}
+	cmd.Flags().Bool("async", false, "Start the job on the Flint server, print its ID and return")
+	cmd.Flags().Bool("wait", false, "Run the job on the Flint server and wait for it to finish")
+	cmd.MarkFlagsMutuallyExclusive("async", "wait")
+}
+
+// wantsServerJob reports whether --async or --wait was given
+func wantsServerJob(cmd *cobra.Command) bool {
+	async, _ := cmd.Flags().GetBool("async")
+	wait, _ := cmd.Flags().GetBool("wait")
+	return async || wait
+}
+
+// connectJobHost is connectHost for job-backed commands. With --async or --wait
+// the operation goes through a Flint server, the local one if no other is
+// configured, so that there is a job to follow.
+func connectJobHost(cmd *cobra.Command) (hostClient, error) {
+	if wantsServerJob(cmd) {
+		return apiClient(), nil
+	}
+	return connectHost()
+}
+
+// startAsync handles --async: it starts the operation as a job, prints the job
+// ID and reports true. Without --async it does nothing and reports false.
+func startAsync(cmd *cobra.Command, client hostClient, method, path string, body interface{}) bool {
+	if async, _ := cmd.Flags().GetBool("async"); !async {
+		return false
+	}
+	api, ok := client.(*apiclient.Client)
+	if !ok {
+		log.Fatalf("--async needs a Flint server")
+	}
+	job, err := api.StartJob(context.Background(), method, path, body)
+	if err != nil {
+		log.Fatalf("Failed to start job: %v", err)
+	}
+	return jobStarted(cmd, job)
+}
+
+// jobStarted handles --async once a job is queued: it prints the job ID and
+// reports true so the command returns without waiting
+func jobStarted(cmd *cobra.Command, job core.Job) bool {
+	if async, _ := cmd.Flags().GetBool("async"); !async {
+		return false
+	}
+	fmt.Println(job.ID)
+	return true
+}
+
+// doJSONRequest performs an authenticated request with body, if not nil, sent
+// as JSON and decodes the response into out, if not nil
+func doJSONRequest(method, url string, body, out interface{}) error {
+	req, err := createAuthenticatedRequest(method, url)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.ContentLength = int64(len(data))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("authentication failed. Please run 'flint api-key' to get your API key")
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// waitForJob polls a job until it finishes, printing progress; a zero timeout waits forever
+func waitForJob(jobID string, timeout time.Duration) (core.Job, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	job, err := apiClient().WaitJob(ctx, jobID, func(job core.Job) {
+		fmt.Printf("\r%s: %s %.1f%% %s", job.Type, job.State, job.Progress, job.Message)
+	})
+	fmt.Println()
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return job, fmt.Errorf("timed out after %s (job is still %s)", timeout, job.State)
+	}
+	return job, err
+}
+
+func init() {
+	jobCmd.AddCommand(jobListCmd)
+	jobCmd.AddCommand(jobGetCmd)
+	jobCmd.AddCommand(jobWaitCmd)
+	jobCmd.AddCommand(jobCancelCmd)
+
+	jobListCmd.Flags().String("type", "", "Only show jobs of this type (e.g. vm.create, image.download)")
+	jobListCmd.Flags().String("state", "", "Only show jobs in this state (queued, running, succeeded, failed, cancelled)")
+	addListFlags(jobListCmd)
+	addOutputFlags(jobGetCmd)
+	jobWaitCmd.Flags().Duration("timeout", 0, "Give up after this long (0 waits forever)")
+}
@@ -1,14 +1,12 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-	"text/tabwriter"
 
-	"github.com/volantvm/flint/pkg/libvirtclient"
 	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/output"
 )
 
 var networkCmd = &cobra.Command{
@@ -25,11 +23,12 @@ var networkListCmd = &cobra.Command{
 
 Examples:
   flint network list                # List all networks
-  flint network list --format json # JSON output`,
+  flint network list -o json       # JSON output
+  flint network list -l is_active=false -o name`,
 	Run: func(cmd *cobra.Command, args []string) {
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -38,14 +37,7 @@ Examples:
 			log.Fatalf("Failed to get networks: %v", err)
 		}
 
-		format, _ := cmd.Flags().GetString("format")
-		if format == "json" {
-			jsonData, _ := json.MarshalIndent(networks, "", "  ")
-			fmt.Println(string(jsonData))
-			return
-		}
-
-		displayNetworksTable(networks)
+		printList(cmd, networks, networkTable)
 	},
 }
 
@@ -60,9 +52,9 @@ Examples:
 	Run: func(cmd *cobra.Command, args []string) {
 		networkName := args[0]
 		
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -88,9 +80,9 @@ Examples:
 	Run: func(cmd *cobra.Command, args []string) {
 		networkName := args[0]
 		
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -114,9 +106,9 @@ Examples:
 	Run: func(cmd *cobra.Command, args []string) {
 		networkName := args[0]
 		
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -140,9 +132,9 @@ Examples:
 	Run: func(cmd *cobra.Command, args []string) {
 		networkName := args[0]
 		
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -155,44 +147,28 @@ Examples:
 	},
 }
 
-func displayNetworksTable(networks interface{}) {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tSTATUS\tBRIDGE\tPERSISTENT\tUUID")
-	fmt.Fprintln(w, "----\t------\t------\t----------\t----")
-
-	// Handle the networks data structure
-	switch v := networks.(type) {
-	case []interface{}:
-		for _, network := range v {
-			if netMap, ok := network.(map[string]interface{}); ok {
-				name := getStringField(netMap, "name")
-				status := "Inactive"
-				if active, ok := netMap["is_active"].(bool); ok && active {
-					status = "Active"
-				}
-				bridge := getStringField(netMap, "bridge")
-				persistent := "No"
-				if pers, ok := netMap["is_persistent"].(bool); ok && pers {
-					persistent = "Yes"
-				}
-				uuid := getStringField(netMap, "uuid")
-				if len(uuid) > 8 {
-					uuid = uuid[:8] + "..."
-				}
-
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, status, bridge, persistent, uuid)
-			}
-		}
-	}
+var networkTable = output.Table[core.Network]{
+	Columns: []output.Column[core.Network]{
+		{Header: "NAME", Value: func(network core.Network) string { return network.Name }},
+		{Header: "STATUS", Value: func(network core.Network) string { return activeLabel(network.IsActive) }},
+		{Header: "BRIDGE", Value: func(network core.Network) string { return network.Bridge }},
+		{Header: "PERSISTENT", Value: func(network core.Network) string { return yesNo(network.IsPersistent) }},
+		{Header: "UUID", Value: func(network core.Network) string { return network.UUID }},
+	},
+}
 
-	w.Flush()
+func activeLabel(active bool) string {
+	if active {
+		return "Active"
+	}
+	return "Inactive"
 }
 
-func getStringField(m map[string]interface{}, key string) string {
-	if val, ok := m[key]; ok {
-		return fmt.Sprintf("%v", val)
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
 	}
-	return "N/A"
+	return "No"
 }
 
 func init() {
@@ -204,7 +180,7 @@ func init() {
 	networkCmd.AddCommand(networkStopCmd)
 
 	// Add flags
-	networkListCmd.Flags().String("format", "table", "Output format (table, json)")
+	addListFlags(networkListCmd)
 	
 	networkCreateCmd.Flags().String("bridge", "", "Bridge name for the network")
 	networkCreateCmd.Flags().String("subnet", "", "Subnet for the network (e.g., 192.168.100.0/24)")
@@ -26,6 +26,8 @@ func ExecuteWithAssets(assets embed.FS) {
 func init() {
 	// This flag will be available to all subcommands
 	rootCmd.PersistentFlags().StringVar(&socketPath, "socket", "/var/run/libvirt/libvirt-sock", "Path to the libvirt socket")
+	rootCmd.PersistentFlags().StringVar(&serverFlag, "server", "", "Flint server URL (default: the current context, else local libvirt or http://localhost:5550)")
+	rootCmd.PersistentFlags().StringVar(&contextFlag, "context", "", "Context from ~/.flint/cli.json to use for this command")
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(vmCmd)
 	rootCmd.AddCommand(snapshotCmd)
@@ -33,4 +35,10 @@ func init() {
 	rootCmd.AddCommand(storageCmd)
 	rootCmd.AddCommand(imageCmd)
 	rootCmd.AddCommand(apiKeyCmd)
+	rootCmd.AddCommand(fleetCmd)
+	rootCmd.AddCommand(jobCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(contextCmd)
 }
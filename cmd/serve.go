@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -202,6 +204,14 @@ func (d *dummyClient) NewStream(flags libvirt.StreamFlags) (*libvirt.Stream, err
 	return nil, errors.New("libvirt connection not available")
 }
 
+func (d *dummyClient) UpdateVMLabels(uuidStr string, req core.UpdateVMLabelsRequest) (core.VM_Detailed, error) {
+	return core.VM_Detailed{}, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) UpdateVM(uuidStr string, req core.UpdateVMRequest) (core.UpdateVMResult, error) {
+	return core.UpdateVMResult{}, errors.New("libvirt connection not available")
+}
+
 func (d *dummyClient) AttachDiskToVM(uuidStr string, volumePath string, targetDev string) error {
 	return errors.New("libvirt connection not available")
 }
@@ -270,6 +280,58 @@ func (d *dummyClient) DeleteNWFilter(name string) error {
 	return fmt.Errorf("not implemented in dummy client")
 }
 
+func (d *dummyClient) CloneVM(ctx context.Context, sourceUUID string, req core.CloneVMRequest, progress func(percent float64, message string)) (core.VM_Detailed, error) {
+	return core.VM_Detailed{}, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) SubscribeEvents(handler func(core.Event)) (func(), error) {
+	return nil, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) GetDomainCounters() ([]core.VMCounters, error) {
+	return nil, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) MigrateVM(ctx context.Context, uuidStr string, dest libvirtclient.ClientInterface, req core.MigrateVMRequest, progress func(core.MigrationProgress)) (core.MigrationResult, error) {
+	return core.MigrationResult{}, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) BackupVM(ctx context.Context, uuidStr string, req core.CreateBackupRequest, progress func(percent float64, message string)) (core.Backup, error) {
+	return core.Backup{}, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) RestoreBackup(ctx context.Context, req core.RestoreBackupRequest, progress func(percent float64, message string)) (core.VM_Detailed, error) {
+	return core.VM_Detailed{}, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) ExportVM(ctx context.Context, uuidStr string, req core.ExportVMRequest, progress func(percent float64, message string)) (core.VMExport, error) {
+	return core.VMExport{}, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) ImportVM(ctx context.Context, req core.ImportVMRequest, progress func(percent float64, message string)) (core.VM_Detailed, error) {
+	return core.VM_Detailed{}, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) CreateImageUploadVolume(name string, sizeBytes uint64) error {
+	return errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) UploadImage(ctx context.Context, name string, offset uint64, r io.Reader) (int64, error) {
+	return 0, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) FinishImageUpload(name string) (core.Image, error) {
+	return core.Image{}, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) GetImageDetails(imageId string) (core.ImageDetails, error) {
+	return core.ImageDetails{}, errors.New("libvirt connection not available")
+}
+
+func (d *dummyClient) ConvertImage(ctx context.Context, imageId string, req core.ConvertImageRequest, progress func(percent float64, message string)) (core.Image, error) {
+	return core.Image{}, errors.New("libvirt connection not available")
+}
+
 var (
 	passphraseFlag string
 	setPassphrase  bool
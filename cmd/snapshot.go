@@ -3,18 +3,23 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"os"
-	"text/tabwriter"
+	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/volantvm/flint/pkg/core"
-	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/output"
 	"github.com/spf13/cobra"
 )
 
 var (
-	snapshotName string
-	description  string
+	snapshotName     string
+	description      string
+	snapshotDiskOnly bool
+	snapshotMemory   bool
+	snapshotLive     bool
+	snapshotQuiesce  bool
+	snapshotTree     bool
 )
 
 var snapshotCmd = &cobra.Command{
@@ -28,41 +33,48 @@ var createSnapshotCmd = &cobra.Command{
 	Short: "Create a snapshot of a VM",
 	Long: `Create a snapshot of a VM that can be used as a template.
 
+By default the snapshot is internal (stored in the qcow2 images) and includes RAM
+while the VM runs. --disk-only and --memory create external qcow2 overlays next to
+each disk instead.
+
 Examples:
   flint snapshot create web-server --name base-config
-  flint snapshot create web-server --name "after-nginx-install" --description "Web server with nginx configured"`,
-	Args: cobra.ExactArgs(1),
+  flint snapshot create web-server --name "after-nginx-install" --description "Web server with nginx configured"
+  flint snapshot create db-01 --name pre-upgrade --disk-only --quiesce   # Consistent filesystems via the guest agent
+  flint snapshot create db-01 --name checkpoint --memory --live          # Overlays plus RAM, guest keeps running
+  flint snapshot create --selector env=dev --name pre-maintenance         # Every VM labelled env=dev`,
+	Args: vmOrSelectorArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		vmIdentifier := args[0]
+		// Create snapshot
+		req := core.CreateSnapshotRequest{
+			Name:        snapshotName,
+			Description: description,
+			DiskOnly:    snapshotDiskOnly,
+			Memory:      snapshotMemory,
+			Live:        snapshotLive,
+			Quiesce:     snapshotQuiesce,
+		}
+		if err := req.Validate(); err != nil {
+			log.Fatalf("Invalid snapshot options: %v", err)
+		}
+		if bulkReq, ok := bulkRequest(cmd, "snapshot"); ok {
+			bulkReq.Snapshot = &req
+			runBulk(cmd, bulkReq, false)
+			return
+		}
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectJobHost(cmd)
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
-		// Find VM by name
-		vms, err := client.GetVMSummaries()
+		vm, err := findVM(client, args[0])
 		if err != nil {
-			log.Fatalf("Failed to get VMs: %v", err)
-		}
-		
-		var vm *core.VM_Summary
-		for _, v := range vms {
-			if v.Name == vmIdentifier || v.UUID == vmIdentifier {
-				vm = &v
-				break
-			}
+			log.Fatalf("%v", err)
 		}
-		
-		if vm == nil {
-			log.Fatalf("VM '%s' not found", vmIdentifier)
-		}
-
-		// Create snapshot
-		req := core.CreateSnapshotRequest{
-			Name:        snapshotName,
-			Description: description,
+		if startAsync(cmd, client, http.MethodPost, "/api/vms/"+url.PathEscape(vm.UUID)+"/snapshots", req) {
+			return
 		}
 
 		fmt.Printf("📸 Creating snapshot '%s' of VM '%s'...\n", snapshotName, vm.Name)
@@ -76,38 +88,37 @@ Examples:
 		fmt.Printf("   Name: %s\n", snapshot.Name)
 		fmt.Printf("   Description: %s\n", snapshot.Description)
 		fmt.Printf("   Created: %s\n", time.Unix(snapshot.CreationTS, 0).Format("2006-01-02 15:04:05"))
+		fmt.Printf("   Kind: %s\n", snapshotKindLabel(snapshot))
+		for _, disk := range snapshot.Disks {
+			if disk.File != "" {
+				fmt.Printf("   Overlay %s: %s\n", disk.Name, disk.File)
+			}
+		}
 	},
 }
 
 var listSnapshotsCmd = &cobra.Command{
 	Use:   "list [vm-name]",
 	Short: "List snapshots for a VM",
-	Args:  cobra.ExactArgs(1),
+	Long: `List snapshots for a VM. The current snapshot is marked with *.
+
+Examples:
+  flint snapshot list web-server
+  flint snapshot list web-server --tree    # Parent/child relationships and overlay files
+  flint snapshot list web-server -l kind=external --sort-by .creation_ts -o name`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		vmIdentifier := args[0]
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
-		// Find VM by name
-		vms, err := client.GetVMSummaries()
+		vm, err := findVM(client, vmIdentifier)
 		if err != nil {
-			log.Fatalf("Failed to get VMs: %v", err)
-		}
-		
-		var vm *core.VM_Summary
-		for _, v := range vms {
-			if v.Name == vmIdentifier || v.UUID == vmIdentifier {
-				vm = &v
-				break
-			}
-		}
-		
-		if vm == nil {
-			log.Fatalf("VM '%s' not found", vmIdentifier)
+			log.Fatalf("%v", err)
 		}
 
 		// Get snapshots
@@ -116,25 +127,42 @@ var listSnapshotsCmd = &cobra.Command{
 			log.Fatalf("Failed to get snapshots: %v", err)
 		}
 
+		if !wantsTable(cmd) {
+			printList(cmd, snapshots, snapshotTable)
+			return
+		}
 		if len(snapshots) == 0 {
 			fmt.Printf("No snapshots found for VM '%s'\n", vm.Name)
 			return
 		}
 
-		fmt.Printf("📸 Snapshots for VM '%s':\n\n", vm.Name)
-
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tDESCRIPTION\tCREATED")
-		fmt.Fprintln(w, "----\t-----------\t-------")
-
-		for _, snapshot := range snapshots {
-			fmt.Fprintf(w, "%s\t%s\t%s\n",
-				snapshot.Name,
-				snapshot.Description,
-				time.Unix(snapshot.CreationTS, 0).Format("2006-01-02 15:04:05"))
+		if snapshotTree {
+			fmt.Printf("📸 Snapshots for VM '%s':\n\n", vm.Name)
+			for _, root := range core.BuildSnapshotTree(snapshots).Roots {
+				printSnapshotNode(root, "", "")
+			}
+			return
 		}
 
-		w.Flush()
+		printList(cmd, snapshots, snapshotTable)
+	},
+}
+
+var snapshotTable = output.Table[core.Snapshot]{
+	Columns: []output.Column[core.Snapshot]{
+		{Header: "NAME", Value: func(snapshot core.Snapshot) string {
+			if snapshot.Current {
+				return snapshot.Name + " *"
+			}
+			return snapshot.Name
+		}},
+		{Header: "KIND", Value: snapshotKindLabel},
+		{Header: "STATE", Wide: true, Value: func(snapshot core.Snapshot) string { return snapshot.State }},
+		{Header: "PARENT", Value: func(snapshot core.Snapshot) string { return snapshot.Parent }},
+		{Header: "DESCRIPTION", Value: func(snapshot core.Snapshot) string { return snapshot.Description }},
+		{Header: "CREATED", Value: func(snapshot core.Snapshot) string {
+			return time.Unix(snapshot.CreationTS, 0).Format("2006-01-02 15:04:05")
+		}},
 	},
 }
 
@@ -146,28 +174,15 @@ var revertSnapshotCmd = &cobra.Command{
 		vmIdentifier := args[0]
 		snapshotName := args[1]
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
-		// Find VM by name
-		vms, err := client.GetVMSummaries()
+		vm, err := findVM(client, vmIdentifier)
 		if err != nil {
-			log.Fatalf("Failed to get VMs: %v", err)
-		}
-		
-		var vm *core.VM_Summary
-		for _, v := range vms {
-			if v.Name == vmIdentifier || v.UUID == vmIdentifier {
-				vm = &v
-				break
-			}
-		}
-		
-		if vm == nil {
-			log.Fatalf("VM '%s' not found", vmIdentifier)
+			log.Fatalf("%v", err)
 		}
 
 		fmt.Printf("⏪ Reverting VM '%s' to snapshot '%s'...\n", vm.Name, snapshotName)
@@ -181,6 +196,45 @@ var revertSnapshotCmd = &cobra.Command{
 	},
 }
 
+// snapshotKindLabel describes what a snapshot contains, e.g. "external+memory"
+func snapshotKindLabel(snapshot core.Snapshot) string {
+	kind := snapshot.Kind
+	if snapshot.Memory {
+		kind += "+memory"
+	}
+	return kind
+}
+
+// printSnapshotNode prints a snapshot and its descendants as an indented tree
+func printSnapshotNode(node core.SnapshotNode, prefix, branch string) {
+	marker := ""
+	if node.Current {
+		marker = " *"
+	}
+	fmt.Printf("%s%s%s%s  [%s, %s]\n", prefix, branch, node.Name, marker,
+		snapshotKindLabel(node.Snapshot), time.Unix(node.CreationTS, 0).Format("2006-01-02 15:04:05"))
+
+	childPrefix := prefix
+	switch branch {
+	case "├── ":
+		childPrefix += "│   "
+	case "└── ":
+		childPrefix += "    "
+	}
+	for _, disk := range node.Disks {
+		if disk.File != "" {
+			fmt.Printf("%s      %s: %s\n", childPrefix, disk.Name, disk.File)
+		}
+	}
+	for i, child := range node.Children {
+		childBranch := "├── "
+		if i == len(node.Children)-1 {
+			childBranch = "└── "
+		}
+		printSnapshotNode(child, childPrefix, childBranch)
+	}
+}
+
 func init() {
 	// Add subcommands
 	snapshotCmd.AddCommand(createSnapshotCmd)
@@ -190,5 +244,13 @@ func init() {
 	// Flags for create command
 	createSnapshotCmd.Flags().StringVar(&snapshotName, "name", "", "Snapshot name (required)")
 	createSnapshotCmd.Flags().StringVar(&description, "description", "", "Snapshot description")
+	createSnapshotCmd.Flags().BoolVar(&snapshotDiskOnly, "disk-only", false, "External snapshot of the disks only (qcow2 overlays)")
+	createSnapshotCmd.Flags().BoolVar(&snapshotMemory, "memory", false, "External snapshot of the disks and RAM (VM must be running)")
+	createSnapshotCmd.Flags().BoolVar(&snapshotLive, "live", false, "With --memory, keep the VM running while RAM is saved")
+	createSnapshotCmd.Flags().BoolVar(&snapshotQuiesce, "quiesce", false, "With --disk-only, freeze guest filesystems via the guest agent")
 	createSnapshotCmd.MarkFlagRequired("name")
+	addBulkFlags(createSnapshotCmd)
+
+	addListFlags(listSnapshotsCmd)
+	listSnapshotsCmd.Flags().BoolVar(&snapshotTree, "tree", false, "Show parent/child relationships")
 }
\ No newline at end of file
@@ -1,15 +1,14 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
+	"net/url"
 	"strconv"
-	"text/tabwriter"
 
 	"github.com/volantvm/flint/pkg/core"
-	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/output"
 	"github.com/spf13/cobra"
 )
 
@@ -39,11 +38,12 @@ var poolListCmd = &cobra.Command{
 
 Examples:
   flint storage pool list                # List all pools
-  flint storage pool list --format json # JSON output`,
+  flint storage pool list -o json       # JSON output
+  flint storage pool list -l state=active --sort-by .capacity_b`,
 	Run: func(cmd *cobra.Command, args []string) {
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -52,14 +52,7 @@ Examples:
 			log.Fatalf("Failed to get storage pools: %v", err)
 		}
 
-		format, _ := cmd.Flags().GetString("format")
-		if format == "json" {
-			jsonData, _ := json.MarshalIndent(pools, "", "  ")
-			fmt.Println(string(jsonData))
-			return
-		}
-
-		displayPoolsTable(pools)
+		printList(cmd, pools, poolTable)
 	},
 }
 
@@ -71,14 +64,15 @@ var volumeListCmd = &cobra.Command{
 
 Examples:
   flint storage volume list default     # List volumes in default pool
-  flint storage volume list --format json default`,
+  flint storage volume list default -o json
+  flint storage volume list default -l name=*.qcow2 -o name`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		poolName := args[0]
 		
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -87,14 +81,7 @@ Examples:
 			log.Fatalf("Failed to get volumes: %v", err)
 		}
 
-		format, _ := cmd.Flags().GetString("format")
-		if format == "json" {
-			jsonData, _ := json.MarshalIndent(volumes, "", "  ")
-			fmt.Println(string(jsonData))
-			return
-		}
-
-		displayVolumesTable(volumes)
+		printList(cmd, volumes, volumeTable)
 	},
 }
 
@@ -111,9 +98,9 @@ Examples:
 		poolName := args[0]
 		volumeName := args[1]
 		
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -151,9 +138,9 @@ Examples:
 		poolName := args[0]
 		volumeName := args[1]
 		
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -179,9 +166,9 @@ Examples:
 		volumeName := args[1]
 		newSizeStr := args[2]
 		
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectJobHost(cmd)
 		if err != nil {
-			log.Fatalf("Failed to connect to libvirt: %v", err)
+			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
@@ -194,6 +181,10 @@ Examples:
 			Name:   volumeName,
 			SizeGB: uint64(newSizeGB),
 		}
+		path := "/api/storage-pools/" + url.PathEscape(poolName) + "/volumes/" + url.PathEscape(volumeName)
+		if startAsync(cmd, client, http.MethodPut, path, map[string]uint64{"size_gb": config.SizeGB}) {
+			return
+		}
 		err = client.UpdateVolume(poolName, volumeName, config)
 		if err != nil {
 			log.Fatalf("Failed to resize volume: %v", err)
@@ -203,65 +194,24 @@ Examples:
 	},
 }
 
-func displayPoolsTable(pools interface{}) {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tSTATUS\tCAPACITY\tALLOCATED\tAVAILABLE")
-	fmt.Fprintln(w, "----\t------\t--------\t---------\t---------")
-
-	switch v := pools.(type) {
-	case []interface{}:
-		for _, pool := range v {
-			if poolMap, ok := pool.(map[string]interface{}); ok {
-				name := getStringField(poolMap, "name")
-				status := getStringField(poolMap, "state")
-				
-				capacity := formatBytes(getInt64Field(poolMap, "capacity_b"))
-				allocated := formatBytes(getInt64Field(poolMap, "allocation_b"))
-				available := formatBytes(getInt64Field(poolMap, "capacity_b") - getInt64Field(poolMap, "allocation_b"))
-
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, status, capacity, allocated, available)
-			}
-		}
-	}
-
-	w.Flush()
-}
-
-func displayVolumesTable(volumes interface{}) {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tCAPACITY\tALLOCATION\tFORMAT\tPATH")
-	fmt.Fprintln(w, "----\t--------\t----------\t------\t----")
-
-	switch v := volumes.(type) {
-	case []interface{}:
-		for _, volume := range v {
-			if volMap, ok := volume.(map[string]interface{}); ok {
-				name := getStringField(volMap, "name")
-				capacity := formatBytes(getInt64Field(volMap, "capacity_b"))
-				allocation := formatBytes(getInt64Field(volMap, "allocation_b"))
-				format := getStringField(volMap, "format")
-				path := getStringField(volMap, "path")
-
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, capacity, allocation, format, path)
-			}
-		}
-	}
-
-	w.Flush()
+var poolTable = output.Table[core.StoragePool]{
+	Columns: []output.Column[core.StoragePool]{
+		{Header: "NAME", Value: func(pool core.StoragePool) string { return pool.Name }},
+		{Header: "STATUS", Value: func(pool core.StoragePool) string { return pool.State }},
+		{Header: "CAPACITY", Value: func(pool core.StoragePool) string { return formatBytes(int64(pool.CapacityB)) }},
+		{Header: "ALLOCATED", Value: func(pool core.StoragePool) string { return formatBytes(int64(pool.AllocationB)) }},
+		{Header: "AVAILABLE", Value: func(pool core.StoragePool) string {
+			return formatBytes(int64(pool.CapacityB) - int64(pool.AllocationB))
+		}},
+	},
 }
 
-func getInt64Field(m map[string]interface{}, key string) int64 {
-	if val, ok := m[key]; ok {
-		switch v := val.(type) {
-		case int64:
-			return v
-		case int:
-			return int64(v)
-		case float64:
-			return int64(v)
-		}
-	}
-	return 0
+var volumeTable = output.Table[core.Volume]{
+	Columns: []output.Column[core.Volume]{
+		{Header: "NAME", Value: func(volume core.Volume) string { return volume.Name }},
+		{Header: "CAPACITY", Value: func(volume core.Volume) string { return formatBytes(int64(volume.Capacity)) }},
+		{Header: "PATH", Value: func(volume core.Volume) string { return volume.Path }},
+	},
 }
 
 func formatBytes(bytes int64) string {
@@ -316,9 +266,10 @@ func init() {
 	storageVolumeCmd.AddCommand(volumeResizeCmd)
 
 	// Add flags
-	poolListCmd.Flags().String("format", "table", "Output format (table, json)")
-	volumeListCmd.Flags().String("format", "table", "Output format (table, json)")
+	addListFlags(poolListCmd)
+	addListFlags(volumeListCmd)
 	
 	volumeCreateCmd.Flags().String("size", "10G", "Size of the volume (e.g., 10G, 1024M)")
 	volumeCreateCmd.Flags().String("format", "qcow2", "Format of the volume (qcow2, raw)")
+	addJobFlags(volumeResizeCmd)
 }
\ No newline at end of file
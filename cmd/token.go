@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/auth"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/output"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage users and their scoped API tokens",
+	Long: `flint token issues, lists and revokes API tokens for named users in ~/.flint/auth.json.
+A running server picks up changes immediately.
+
+Roles:
+  viewer    read-only access
+  operator  manage VMs, snapshots, volumes and images
+  admin     also manage servers, networks, storage pools, users and tokens
+
+Tokens may be scoped to registered servers by ID or name (--server) or by server tag (--tag),
+and to the VMs whose labels match a selector (--vm-labels).`,
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Issue an API token for a user",
+	Long: `Issue an API token for a user. The user is created with --role and the scope flags
+if it does not exist yet. The token's role and scope may narrow the user's but never widen them.
+The token secret is only shown once.
+
+Examples:
+  flint token create --user alice --role operator
+  flint token create --user ci --role viewer --tag prod --expires 720h
+  flint token create --user dev-team --role operator --vm-labels team=web,env!=prod
+  flint token create --user bob --role operator --server hv-01 --server hv-02 --description "bob's laptop"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, _ := cmd.Flags().GetString("user")
+		role, _ := cmd.Flags().GetString("role")
+		servers, _ := cmd.Flags().GetStringSlice("server")
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		vmLabels, _ := cmd.Flags().GetString("vm-labels")
+		expires, _ := cmd.Flags().GetString("expires")
+		description, _ := cmd.Flags().GetString("description")
+		format, _ := cmd.Flags().GetString("format")
+
+		if userName == "" {
+			log.Fatalf("--user is required")
+		}
+
+		store, err := auth.NewStore("")
+		if err != nil {
+			log.Fatalf("Failed to open token store: %v", err)
+		}
+
+		scope := core.AccessScope{Servers: servers, Tags: tags, VMLabels: vmLabels}
+		if _, err := store.GetUser(userName); errors.Is(err, auth.ErrUserNotFound) {
+			if role == "" {
+				log.Fatalf("User %s does not exist; pass --role to create it", userName)
+			}
+			if _, err := store.AddUser(core.CreateUserRequest{Name: userName, Role: core.Role(role), Scope: scope}); err != nil {
+				log.Fatalf("Failed to create user: %v", err)
+			}
+			fmt.Fprintf(os.Stderr, "Created user %s (%s)\n", userName, role)
+		}
+
+		token, secret, err := store.CreateToken(core.CreateTokenRequest{
+			User:        userName,
+			Description: description,
+			Role:        core.Role(role),
+			Scope:       scope,
+			ExpiresIn:   expires,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create token: %v", err)
+		}
+
+		if format == "json" {
+			jsonData, _ := json.MarshalIndent(core.CreateTokenResponse{Token: token, Secret: secret}, "", "  ")
+			fmt.Println(string(jsonData))
+			return
+		}
+
+		fmt.Printf("🔑 Token %s for %s (%s, %s)\n\n", token.ID, token.User, token.Role, formatScope(token.Scope))
+		fmt.Printf("  %s\n\n", secret)
+		fmt.Println("Use it in the Authorization header:")
+		fmt.Printf("  Authorization: Bearer %s\n\n", secret)
+		fmt.Println("⚠️  This is the only time the token is shown. Store it securely.")
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List API tokens",
+	Long: `List API tokens, oldest first. Secrets are never shown, only their prefix.
+
+Examples:
+  flint token ls
+  flint token ls --user alice
+  flint token ls -l role=admin -o name
+  flint token ls -o json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, _ := cmd.Flags().GetString("user")
+
+		store, err := auth.NewStore("")
+		if err != nil {
+			log.Fatalf("Failed to open token store: %v", err)
+		}
+		tokens := store.ListTokens(userName)
+
+		printList(cmd, tokens, tokenTable)
+	},
+}
+
+var tokenTable = output.Table[core.APIToken]{
+	Columns: []output.Column[core.APIToken]{
+		{Header: "ID", Value: func(token core.APIToken) string { return token.ID }},
+		{Header: "PREFIX", Value: func(token core.APIToken) string { return token.Prefix }},
+		{Header: "USER", Value: func(token core.APIToken) string { return token.User }},
+		{Header: "ROLE", Value: func(token core.APIToken) string { return string(token.Role) }},
+		{Header: "SCOPE", Value: func(token core.APIToken) string { return formatScope(token.Scope) }},
+		{Header: "CREATED", Wide: true, Value: func(token core.APIToken) string { return token.CreatedAt.Format("2006-01-02 15:04") }},
+		{Header: "EXPIRES", Value: func(token core.APIToken) string { return formatOptionalTime(token.ExpiresAt) }},
+		{Header: "LAST USED", Value: func(token core.APIToken) string { return formatOptionalTime(token.LastUsedAt) }},
+		{Header: "DESCRIPTION", Value: func(token core.APIToken) string { return token.Description }},
+	},
+	Name: func(token core.APIToken) string { return token.ID },
+}
+
+// formatOptionalTime renders a time that may be unset for tables
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke [token-id or prefix]",
+	Short: "Revoke an API token",
+	Long: `Revoke an API token by ID or by the prefix shown in 'flint token ls'.
+
+Examples:
+  flint token revoke 3f6c2a1e-...
+  flint token revoke flt_1a2b3c4d`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := auth.NewStore("")
+		if err != nil {
+			log.Fatalf("Failed to open token store: %v", err)
+		}
+
+		token, err := store.RevokeToken(args[0])
+		if err != nil {
+			log.Fatalf("Failed to revoke token: %v", err)
+		}
+		fmt.Printf("✅ Revoked token %s of %s\n", token.ID, token.User)
+	},
+}
+
+// formatScope renders a scope for tables
+func formatScope(scope core.AccessScope) string {
+	if scope.IsEmpty() {
+		return "all servers"
+	}
+	var parts []string
+	if len(scope.Servers) > 0 {
+		parts = append(parts, "servers="+strings.Join(scope.Servers, ","))
+	}
+	if len(scope.Tags) > 0 {
+		parts = append(parts, "tags="+strings.Join(scope.Tags, ","))
+	}
+	if scope.VMLabels != "" {
+		parts = append(parts, "vms="+scope.VMLabels)
+	}
+	return strings.Join(parts, " ")
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+
+	tokenCreateCmd.Flags().String("user", "", "User the token acts as (required)")
+	tokenCreateCmd.Flags().String("role", "", "viewer, operator or admin (defaults to the user's role)")
+	tokenCreateCmd.Flags().StringSlice("server", nil, "Limit the token to a registered server ID or name (repeatable)")
+	tokenCreateCmd.Flags().StringSlice("tag", nil, "Limit the token to servers with this tag (repeatable)")
+	tokenCreateCmd.Flags().String("vm-labels", "", "Limit the token to VMs matching this label selector, e.g. team=web,env!=prod")
+	tokenCreateCmd.Flags().String("expires", "", "Expire the token after this long, e.g. 720h (default never)")
+	tokenCreateCmd.Flags().String("description", "", "What the token is for")
+	tokenCreateCmd.Flags().String("format", "text", "Output format (text, json)")
+	tokenListCmd.Flags().String("user", "", "Only show tokens of this user")
+	addListFlags(tokenListCmd)
+}
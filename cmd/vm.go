@@ -1,16 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"time"
 
+	"github.com/volantvm/flint/pkg/connectionpool"
 	"github.com/volantvm/flint/pkg/core"
 	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/output"
+	"github.com/volantvm/flint/pkg/serverregistry"
 	"github.com/spf13/cobra"
 )
 
@@ -21,10 +29,20 @@ var vmCmd = &cobra.Command{
 }
 
 var vmListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List all virtual machines",
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all virtual machines",
+	Long: `List virtual machines with their state and resource usage.
+
+Examples:
+  flint vm list
+  flint vm ls -o wide                             # Add UUID, vCPUs, OS and labels
+  flint vm ls -l state=running --sort-by .memory_kb
+  flint vm ls -l name=web-* -o name
+  flint vm ls -l label.env=prod,label.tier=web
+  flint vm ls -o jsonpath='{range .items[*]}{.name}{"\t"}{.ip_addresses[0]}{"\n"}{end}'`,
 	Run: func(cmd *cobra.Command, args []string) {
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
 			log.Fatalf("Failed to connect: %v", err)
 		}
@@ -35,31 +53,22 @@ var vmListCmd = &cobra.Command{
 			log.Fatalf("Failed to list VMs: %v", err)
 		}
 
-		// Enhanced table output
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
-		fmt.Printf("Virtual Machines Status\n")
-		fmt.Printf("%s\n\n", strings.Repeat("=", 80))
-		fmt.Printf("%-36s %-12s %-8s CPU%-8s MEM%-8s UP%-8s IP\n", "NAME", "STATUS", "", "", "", "")
-		fmt.Printf("%s\n", strings.Repeat("-", 80))
-
-		for _, vm := range vms {
-			status := vm.State
-			if status == "Running" {
-				status = fmt.Sprintf("\033[32m%s\033[0m", status)
-			} else if status == "Shutoff" {
-				status = fmt.Sprintf("\033[31m%s\033[0m", status)
-			} else {
-				status = fmt.Sprintf("\033[33m%s\033[0m", status)
-			}
-
-			uptimeStr := libvirtclient.FormatUptime(vm.UptimeSec)
-			ipStr := strings.Join(vm.IPAddresses, ", ")
+		printList(cmd, vms, vmTable)
+	},
+}
 
-			fmt.Printf("%-36s %-12s %-8.1f%% %-8dMB %-8s %s\n",
-				vm.Name, status, vm.CPUPercent, vm.MemoryKB/1024,
-				uptimeStr, ipStr)
-		}
-		fmt.Printf("\n%s\n", strings.Repeat("=", 80))
+var vmTable = output.Table[core.VM_Summary]{
+	Columns: []output.Column[core.VM_Summary]{
+		{Header: "NAME", Value: func(vm core.VM_Summary) string { return vm.Name }},
+		{Header: "UUID", Wide: true, Value: func(vm core.VM_Summary) string { return vm.UUID }},
+		{Header: "STATE", Value: func(vm core.VM_Summary) string { return vm.State }},
+		{Header: "VCPUS", Wide: true, Value: func(vm core.VM_Summary) string { return fmt.Sprint(vm.VCPUs) }},
+		{Header: "CPU", Value: func(vm core.VM_Summary) string { return fmt.Sprintf("%.1f%%", vm.CPUPercent) }},
+		{Header: "MEMORY", Value: func(vm core.VM_Summary) string { return fmt.Sprintf("%d MB", vm.MemoryKB/1024) }},
+		{Header: "UPTIME", Value: func(vm core.VM_Summary) string { return libvirtclient.FormatUptime(vm.UptimeSec) }},
+		{Header: "OS", Wide: true, Value: func(vm core.VM_Summary) string { return orDash(vm.OSInfo) }},
+		{Header: "IP", Value: func(vm core.VM_Summary) string { return orDash(strings.Join(vm.IPAddresses, ", ")) }},
+		{Header: "LABELS", Wide: true, Value: func(vm core.VM_Summary) string { return orDash(core.FormatLabels(vm.Labels)) }},
 	},
 }
 
@@ -81,7 +90,7 @@ var vmLaunchCmd = &cobra.Command{
 			name = "ubuntu-server"
 		}
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectJobHost(cmd)
 		if err != nil {
 			log.Fatalf("Failed to connect: %v", err)
 		}
@@ -139,6 +148,10 @@ runcmd:
 			NetworkName:   "default",
 		}
 
+		if startAsync(cmd, client, http.MethodPost, "/api/vms", cfg) {
+			return
+		}
+
 		fmt.Printf("Creating VM '%s' with smart defaults...\n", name)
 
 		vm, err := client.CreateVM(cfg)
@@ -172,27 +185,15 @@ var vmSSHCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
 			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
-		vms, err := client.GetVMSummaries()
+		targetVM, err := findVM(client, name)
 		if err != nil {
-			log.Fatalf("Failed to get VMs: %v", err)
-		}
-
-		var targetVM *core.VM_Summary
-		for _, vm := range vms {
-			if vm.Name == name {
-				targetVM = &vm
-				break
-			}
-		}
-
-		if targetVM == nil {
-			fmt.Printf("Error: VM '%s' not found\n", name)
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
 
@@ -232,6 +233,9 @@ var vmConsoleCmd = &cobra.Command{
 			fmt.Println("Error: VM name required")
 			os.Exit(1)
 		}
+		if client, ok := remoteClient(); ok {
+			log.Fatalf("The console needs local libvirt; use the serial console in the web UI at %s", client.BaseURL())
+		}
 
 		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
 		if err != nil {
@@ -324,19 +328,33 @@ var vmConsoleCmd = &cobra.Command{
 var vmDeleteCmd = &cobra.Command{
 	Use:   "delete [name]",
 	Short: "Delete a virtual machine",
-	Long:  "Delete a virtual machine and optionally its storage",
-	Args:  cobra.ExactArgs(1),
+	Long: `Delete a virtual machine and optionally its storage.
+
+Examples:
+  flint vm delete web-01
+  flint vm delete --selector env=scratch --delete-storage   # Every VM labelled env=scratch`,
+	Args: vmOrSelectorJobArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		name := args[0]
 		force, _ := cmd.Flags().GetBool("force")
 		deleteStorage, _ := cmd.Flags().GetBool("delete-storage")
+		if req, ok := bulkRequest(cmd, "delete"); ok {
+			req.DeleteDisks = deleteStorage
+			runBulk(cmd, req, !force)
+			return
+		}
+		name := args[0]
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
 			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
+		vm, err := findVM(client, name)
+		if err != nil {
+			log.Fatalf("Failed to delete VM: %v", err)
+		}
+
 		if !force {
 			fmt.Printf("Are you sure you want to delete VM '%s'? (y/N): ", name)
 			var response string
@@ -347,7 +365,7 @@ var vmDeleteCmd = &cobra.Command{
 			}
 		}
 
-		err = client.DeleteVM(name, deleteStorage)
+		err = client.DeleteVM(vm.UUID, deleteStorage)
 		if err != nil {
 			log.Fatalf("Failed to delete VM: %v", err)
 		}
@@ -359,17 +377,31 @@ var vmDeleteCmd = &cobra.Command{
 var vmStartCmd = &cobra.Command{
 	Use:   "start [name]",
 	Short: "Start a virtual machine",
-	Args:  cobra.ExactArgs(1),
+	Long: `Start a virtual machine, or with --selector every VM whose labels match.
+
+Examples:
+  flint vm start web-01
+  flint vm start --selector env=dev --parallel 8`,
+	Args: vmOrSelectorJobArgs,
 	Run: func(cmd *cobra.Command, args []string) {
+		if req, ok := bulkRequest(cmd, "start"); ok {
+			runBulk(cmd, req, false)
+			return
+		}
 		name := args[0]
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
 			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
-		err = client.StartVM(name)
+		vm, err := findVM(client, name)
+		if err != nil {
+			log.Fatalf("Failed to start VM: %v", err)
+		}
+
+		err = client.PerformVMAction(vm.UUID, "start")
 		if err != nil {
 			log.Fatalf("Failed to start VM: %v", err)
 		}
@@ -381,21 +413,41 @@ var vmStartCmd = &cobra.Command{
 var vmStopCmd = &cobra.Command{
 	Use:   "stop [name]",
 	Short: "Stop a virtual machine",
-	Args:  cobra.ExactArgs(1),
+	Long: `Shut a virtual machine down, or with --selector every VM whose labels match.
+VMs that are already shut off are left alone.
+
+Examples:
+  flint vm stop web-01
+  flint vm stop --selector env=dev --parallel 4   # Shut a lab down before host maintenance
+  flint vm stop --selector env=dev,tier!=db --force`,
+	Args: vmOrSelectorJobArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		name := args[0]
 		force, _ := cmd.Flags().GetBool("force")
+		bulkAction := "stop"
+		if force {
+			bulkAction = "force-stop"
+		}
+		if req, ok := bulkRequest(cmd, bulkAction); ok {
+			runBulk(cmd, req, false)
+			return
+		}
+		name := args[0]
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
 			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
+		vm, err := findVM(client, name)
+		if err != nil {
+			log.Fatalf("Failed to stop VM: %v", err)
+		}
+
 		if force {
-			err = client.ForceStopVM(name)
+			err = client.PerformVMAction(vm.UUID, "force-stop")
 		} else {
-			err = client.StopVM(name)
+			err = client.PerformVMAction(vm.UUID, "stop")
 		}
 		if err != nil {
 			log.Fatalf("Failed to stop VM: %v", err)
@@ -412,18 +464,40 @@ var vmStopCmd = &cobra.Command{
 var vmRestartCmd = &cobra.Command{
 	Use:   "restart [name]",
 	Short: "Restart a virtual machine",
-	Args:  cobra.ExactArgs(1),
+	Long: `Restart a virtual machine, or with --selector every VM whose labels match.
+
+Examples:
+  flint vm restart web-01
+  flint vm restart --selector tier=web --parallel 1   # One at a time`,
+	Args: vmOrSelectorJobArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		name := args[0]
 		force, _ := cmd.Flags().GetBool("force")
+		bulkAction := "reboot"
+		if force {
+			bulkAction = "reset"
+		}
+		if req, ok := bulkRequest(cmd, bulkAction); ok {
+			runBulk(cmd, req, false)
+			return
+		}
+		name := args[0]
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
 			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
-		err = client.RestartVM(name, force)
+		vm, err := findVM(client, name)
+		if err != nil {
+			log.Fatalf("Failed to restart VM: %v", err)
+		}
+
+		action := "reboot"
+		if force {
+			action = "reset"
+		}
+		err = client.PerformVMAction(vm.UUID, action)
 		if err != nil {
 			log.Fatalf("Failed to restart VM: %v", err)
 		}
@@ -435,27 +509,47 @@ var vmRestartCmd = &cobra.Command{
 var vmDetailsCmd = &cobra.Command{
 	Use:   "details [name]",
 	Short: "Show detailed information about a VM",
-	Args:  cobra.ExactArgs(1),
+	Long: `Show detailed information about a VM.
+
+Examples:
+  flint vm details web-01
+  flint vm details web-01 -o yaml
+  flint vm details web-01 -o jsonpath='{.disks[*].source_path}'`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		name := args[0]
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
 			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
-		vm, err := client.GetVMDetails(name)
+		summary, err := findVM(client, name)
 		if err != nil {
 			log.Fatalf("Failed to get VM details: %v", err)
 		}
 
+		vm, err := client.GetVMDetails(summary.UUID)
+		if err != nil {
+			log.Fatalf("Failed to get VM details: %v", err)
+		}
+		if printObject(cmd, vm) {
+			return
+		}
+
 		fmt.Printf("\n=== VM Details: %s ===\n", vm.Name)
 		fmt.Printf("UUID: %s\n", vm.UUID)
 		fmt.Printf("State: %s\n", vm.State)
 		fmt.Printf("Memory: %d MB\n", vm.MemoryKB/1024)
 		fmt.Printf("vCPUs: %d\n", vm.VCPUs)
 		fmt.Printf("OS: %s\n", vm.OS)
+		if vm.Description != "" {
+			fmt.Printf("Description: %s\n", vm.Description)
+		}
+		if len(vm.Labels) > 0 {
+			fmt.Printf("Labels: %s\n", core.FormatLabels(vm.Labels))
+		}
 		
 		if len(vm.IPAddresses) > 0 {
 			fmt.Printf("IP Addresses: %s\n", strings.Join(vm.IPAddresses, ", "))
@@ -484,6 +578,144 @@ var vmDetailsCmd = &cobra.Command{
 	},
 }
 
+var vmMigrateCmd = &cobra.Command{
+	Use:   "migrate [name]",
+	Short: "Migrate a VM to another registered server",
+	Long: `Move a VM to a server registered in ~/.flint/servers.json, or with the Flint
+server when one is configured (see 'flint context').
+Running VMs are migrated live; shut-off VMs only have their definition moved.
+Press Ctrl+C to abort a migration in progress.
+
+Examples:
+  flint vm migrate web01 --to host-b                          # Live migration over shared storage
+  flint vm migrate web01 --to host-b --copy-storage all       # Also copy the disks
+  flint vm migrate web01 --to host-b --bandwidth 200 --auto-converge
+  flint vm migrate web01 --from host-a --to host-b --post-copy`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		req := core.MigrateVMRequest{TargetServer: to}
+		req.Offline, _ = cmd.Flags().GetBool("offline")
+		req.CopyStorage, _ = cmd.Flags().GetString("copy-storage")
+		req.BandwidthMiB, _ = cmd.Flags().GetUint64("bandwidth")
+		req.AutoConverge, _ = cmd.Flags().GetBool("auto-converge")
+		req.PostCopy, _ = cmd.Flags().GetBool("post-copy")
+
+		if to == "" {
+			log.Fatalf("--to is required")
+		}
+
+		api, ok := remoteClient()
+		if !ok && wantsServerJob(cmd) {
+			api, ok = apiClient(), true
+		}
+		if ok {
+			// The Flint server migrates between the servers registered with it
+			if from != "" {
+				api = api.WithServer(from)
+			}
+			vm, err := findVM(api, name)
+			if err != nil {
+				log.Fatalf("Failed to migrate VM: %v", err)
+			}
+			if startAsync(cmd, api, http.MethodPost, "/api/vms/"+url.PathEscape(vm.UUID)+"/migrate", req) {
+				return
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			fmt.Printf("Migrating VM '%s' to %s...\n", name, to)
+			result, err := api.MigrateVM(ctx, vm.UUID, req, func(percent float64, message string) {
+				fmt.Printf("\r  %5.1f%%  %-50s", percent, message)
+			})
+			fmt.Println()
+			if err != nil {
+				log.Fatalf("Failed to migrate VM: %v", err)
+			}
+			printMigrationResult(name, to, result)
+			return
+		}
+
+		registry, err := serverregistry.NewRegistry("")
+		if err != nil {
+			log.Fatalf("Failed to load server registry: %v", err)
+		}
+		target, err := registry.FindServer(to)
+		if err != nil {
+			log.Fatalf("Unknown target server %q: %v", to, err)
+		}
+
+		pool := connectionpool.NewPool(0, 0)
+		defer pool.CloseAll()
+
+		var client libvirtclient.ClientInterface
+		if from != "" {
+			source, err := registry.FindServer(from)
+			if err != nil {
+				log.Fatalf("Unknown source server %q: %v", from, err)
+			}
+			if source.ID == target.ID {
+				log.Fatalf("Source and target are the same server")
+			}
+			client, err = pool.GetConnection(source)
+			if err != nil {
+				log.Fatalf("Failed to connect to %s: %v", source.Name, err)
+			}
+		} else {
+			local, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+			if err != nil {
+				log.Fatalf("Failed to connect: %v", err)
+			}
+			defer local.Close()
+			client = local
+		}
+
+		dest, err := pool.GetConnection(target)
+		if err != nil {
+			log.Fatalf("Failed to connect to %s: %v", target.Name, err)
+		}
+
+		dom, err := client.GetDomainByName(name)
+		if err != nil {
+			log.Fatalf("VM '%s' not found: %v", name, err)
+		}
+		uuid, err := dom.GetUUIDString()
+		dom.Free()
+		if err != nil {
+			log.Fatalf("Failed to read VM UUID: %v", err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Migrating VM '%s' to %s...\n", name, target.Name)
+		result, err := client.MigrateVM(ctx, uuid, dest, req, func(p core.MigrationProgress) {
+			fmt.Printf("\r  %-8s %5.1f%%  %d/%d MiB", p.Phase, p.Percent, p.DataProcessed/(1024*1024), p.DataTotal/(1024*1024))
+		})
+		fmt.Println()
+		if err != nil {
+			log.Fatalf("Failed to migrate VM: %v", err)
+		}
+
+		printMigrationResult(name, target.Name, result)
+	},
+}
+
+// printMigrationResult summarises a finished migration
+func printMigrationResult(name, target string, result core.MigrationResult) {
+	mode := "offline"
+	if result.Live {
+		mode = "live"
+	}
+	if result.PostCopy {
+		mode += ", post-copy"
+	}
+	fmt.Printf("VM '%s' migrated to %s (%s) in %s\n", name, target, mode, time.Duration(result.DurationMs)*time.Millisecond)
+}
+
 var vmGuestAgentCmd = &cobra.Command{
 	Use:   "guest-agent",
 	Short: "Manage guest agent",
@@ -497,16 +729,25 @@ var vmGuestAgentStatusCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		name := args[0]
 
-		client, err := libvirtclient.NewClient("qemu:///system", "isos", "templates")
+		client, err := connectHost()
 		if err != nil {
 			log.Fatalf("Failed to connect: %v", err)
 		}
 		defer client.Close()
 
-		status, err := client.GetGuestAgentStatus(name)
+		vm, err := findVM(client, name)
+		if err != nil {
+			log.Fatalf("Failed to get guest agent status: %v", err)
+		}
+
+		available, err := client.CheckGuestAgentStatus(vm.UUID)
 		if err != nil {
 			log.Fatalf("Failed to get guest agent status: %v", err)
 		}
+		status := "Not Available"
+		if available {
+			status = "Available"
+		}
 
 		fmt.Printf("Guest Agent Status for VM '%s': %s\n", name, status)
 	},
@@ -522,14 +763,30 @@ func init() {
 	vmCmd.AddCommand(vmStopCmd)
 	vmCmd.AddCommand(vmRestartCmd)
 	vmCmd.AddCommand(vmDetailsCmd)
+	vmCmd.AddCommand(vmMigrateCmd)
 	vmCmd.AddCommand(vmGuestAgentCmd)
 
 	// Add guest agent subcommands
 	vmGuestAgentCmd.AddCommand(vmGuestAgentStatusCmd)
 
 	// Add flags
+	addListFlags(vmListCmd)
+	addOutputFlags(vmDetailsCmd)
+	addBulkFlags(vmStartCmd)
+	addBulkFlags(vmStopCmd)
+	addBulkFlags(vmRestartCmd)
+	addBulkFlags(vmDeleteCmd)
+	addJobFlags(vmLaunchCmd)
+	addJobFlags(vmMigrateCmd)
 	vmDeleteCmd.Flags().Bool("force", false, "Skip confirmation prompt")
 	vmDeleteCmd.Flags().Bool("delete-storage", false, "Also delete VM storage")
 	vmStopCmd.Flags().Bool("force", false, "Force stop (equivalent to power off)")
 	vmRestartCmd.Flags().Bool("force", false, "Force restart")
+	vmMigrateCmd.Flags().String("to", "", "Target server ID or name")
+	vmMigrateCmd.Flags().String("from", "", "Source server ID or name (default: local libvirt or the context's host)")
+	vmMigrateCmd.Flags().Bool("offline", false, "Move only the definition of a shut-off VM")
+	vmMigrateCmd.Flags().String("copy-storage", "", "Copy disks for hosts without shared storage: all or incremental")
+	vmMigrateCmd.Flags().Uint64("bandwidth", 0, "Bandwidth limit in MiB/s (0 = unlimited)")
+	vmMigrateCmd.Flags().Bool("auto-converge", false, "Throttle the guest if memory changes faster than it is copied")
+	vmMigrateCmd.Flags().Bool("post-copy", false, "Switch to post-copy if pre-copy does not converge")
 }
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/apiclient"
+	"github.com/volantvm/flint/pkg/backup"
+	"github.com/volantvm/flint/pkg/config"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/output"
+)
+
+var vmBackupCmd = &cobra.Command{
+	Use:   "backup [name]",
+	Short: "Back up a VM's definition and disks to a backup directory",
+	Long: `Copy a VM's domain XML and disks into <dir>/<vm-uuid>/<backup-id>/ as qcow2 images,
+compressed and checksummed. Running VMs are copied consistently through libvirt's
+backup API; shut-off VMs are copied with qemu-img.
+
+Backups of running VMs with qcow2 disks record a checkpoint, so the next backup can
+be --incremental and copy only the blocks changed since. Restoring an incremental
+backup needs every backup back to the last full one.
+
+The directory defaults to backup.path in ~/.flint/config.json (/var/lib/flint/backups).
+Against a Flint server the server's configured directory is always used.
+Press Ctrl+C to abort a backup in progress.
+
+Examples:
+  flint vm backup web01
+  flint vm backup web01 --to /mnt/backups
+  flint vm backup web01 --to /mnt/backups --incremental`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		dir, _ := cmd.Flags().GetString("to")
+		incremental, _ := cmd.Flags().GetBool("incremental")
+		noCompress, _ := cmd.Flags().GetBool("no-compress")
+
+		compress := !noCompress
+		req := core.CreateBackupRequest{Incremental: incremental, Compress: &compress}
+
+		client, err := connectJobHost(cmd)
+		if err != nil {
+			log.Fatalf("Failed to connect: %v", err)
+		}
+		defer client.Close()
+
+		destination := backupDestination(client, dir)
+		if _, remote := client.(*apiclient.Client); !remote {
+			req.Repository = destination
+		}
+
+		vm, err := findVM(client, name)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if startAsync(cmd, client, http.MethodPost, "/api/vms/"+url.PathEscape(vm.UUID)+"/backups", req) {
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Backing up VM '%s' to %s...\n", name, destination)
+		b, err := client.BackupVM(ctx, vm.UUID, req, func(percent float64, message string) {
+			fmt.Printf("\r  %5.1f%%  %-40s", percent, message)
+		})
+		fmt.Println()
+		if err != nil {
+			log.Fatalf("Failed to back up VM: %v", err)
+		}
+
+		fmt.Printf("✅ %s backup %s of '%s' (%d disks, %s) in %s\n", b.Kind, b.ID, name, len(b.Disks),
+			formatBackupSize(b.SizeBytes), time.Duration(b.DurationMs)*time.Millisecond)
+		fmt.Printf("   %s\n", b.Path)
+	},
+}
+
+var vmBackupsCmd = &cobra.Command{
+	Use:   "backups [name or uuid]",
+	Short: "List backups in a backup directory",
+	Long: `List backups oldest first, for one VM or for every VM in the directory.
+VMs that have been deleted can be found by name or UUID.
+
+Examples:
+  flint vm backups
+  flint vm backups web01 --from /mnt/backups
+  flint vm backups web01 -o json
+  flint vm backups -l kind=full --sort-by .size_bytes -o wide`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("from")
+
+		vm := ""
+		if len(args) == 1 {
+			vm = args[0]
+		}
+
+		var backups []core.Backup
+		if api, ok := remoteClient(); ok {
+			backupDestination(api, dir)
+			b, err := api.ListBackups(vm)
+			if err != nil {
+				log.Fatalf("Failed to list backups: %v", err)
+			}
+			backups = b
+		} else {
+			repo, err := backup.NewRepository(backupDir(dir))
+			if err != nil {
+				log.Fatalf("Failed to open backup directory: %v", err)
+			}
+			if backups, err = repo.List(vm); err != nil {
+				log.Fatalf("Failed to list backups: %v", err)
+			}
+		}
+
+		printList(cmd, backups, backupTable)
+	},
+}
+
+var backupTable = output.Table[core.Backup]{
+	Columns: []output.Column[core.Backup]{
+		{Header: "VM", Value: func(b core.Backup) string { return b.VMName }},
+		{Header: "VM UUID", Wide: true, Value: func(b core.Backup) string { return b.VMUUID }},
+		{Header: "ID", Value: func(b core.Backup) string { return b.ID }},
+		{Header: "KIND", Value: func(b core.Backup) string { return b.Kind }},
+		{Header: "PARENT", Value: func(b core.Backup) string { return orDash(b.Parent) }},
+		{Header: "LIVE", Wide: true, Value: func(b core.Backup) string { return yesNo(b.Live) }},
+		{Header: "DISKS", Value: func(b core.Backup) string { return fmt.Sprint(len(b.Disks)) }},
+		{Header: "SIZE", Value: func(b core.Backup) string { return formatBackupSize(b.SizeBytes) }},
+		{Header: "CREATED", Value: func(b core.Backup) string { return b.CreatedAt.Local().Format("2006-01-02 15:04") }},
+		{Header: "PATH", Wide: true, Value: func(b core.Backup) string { return b.Path }},
+	},
+	Name: func(b core.Backup) string { return b.ID },
+}
+
+var vmRestoreCmd = &cobra.Command{
+	Use:   "restore [name or uuid]",
+	Short: "Restore a VM from a backup",
+	Long: `Verify a backup's checksums, copy its disks into a storage pool and define the VM
+from the backed-up domain XML. The latest backup is used unless --backup is given.
+
+The VM keeps its name and UUID unless --name or --new-uuid is given; restoring next to
+the original VM needs both.
+
+Examples:
+  flint vm restore web01
+  flint vm restore web01 --from /mnt/backups --backup 20250115-020000 --start
+  flint vm restore web01 --name web01-restored --new-uuid --pool default`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("from")
+		req := core.RestoreBackupRequest{VM: args[0]}
+		req.BackupID, _ = cmd.Flags().GetString("backup")
+		req.Name, _ = cmd.Flags().GetString("name")
+		req.NewUUID, _ = cmd.Flags().GetBool("new-uuid")
+		req.Pool, _ = cmd.Flags().GetString("pool")
+		req.Start, _ = cmd.Flags().GetBool("start")
+
+		client, err := connectJobHost(cmd)
+		if err != nil {
+			log.Fatalf("Failed to connect: %v", err)
+		}
+		defer client.Close()
+
+		source := backupDestination(client, dir)
+		if api, remote := client.(*apiclient.Client); remote {
+			// The API restores by UUID; the VM may be gone, so look it up in its backups
+			backups, err := api.ListBackups(args[0])
+			if err != nil {
+				log.Fatalf("Failed to list backups: %v", err)
+			}
+			if len(backups) == 0 {
+				log.Fatalf("No backups of '%s' found", args[0])
+			}
+			req.VM = backups[0].VMUUID
+
+			backupID := req.BackupID
+			if backupID == "" {
+				backupID = "latest"
+			}
+			if startAsync(cmd, api, http.MethodPost, "/api/vms/"+url.PathEscape(req.VM)+"/backups/"+url.PathEscape(backupID)+"/restore", req) {
+				return
+			}
+		} else {
+			req.Repository = source
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Restoring '%s' from %s...\n", args[0], source)
+		vm, err := client.RestoreBackup(ctx, req, func(percent float64, message string) {
+			fmt.Printf("\r  %5.1f%%  %-40s", percent, message)
+		})
+		fmt.Println()
+		if err != nil {
+			log.Fatalf("Failed to restore VM: %v", err)
+		}
+
+		fmt.Printf("✅ VM '%s' restored (%s, %s)\n", vm.Name, vm.UUID, vm.State)
+	},
+}
+
+// backupDir returns the directory given on the command line or the configured one
+func backupDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		return config.DefaultConfig().Backup.Path
+	}
+	return cfg.Backup.Path
+}
+
+// backupDestination describes where backups are read and written: the
+// directory for local libvirt, the server's repository through the API, where a
+// directory cannot be chosen
+func backupDestination(client hostClient, dir string) string {
+	api, remote := client.(*apiclient.Client)
+	if !remote {
+		return backupDir(dir)
+	}
+	if dir != "" {
+		log.Fatalf("A backup directory cannot be given with a Flint server; it uses its configured one")
+	}
+	return "the backup repository of " + api.BaseURL()
+}
+
+// formatBackupSize renders a byte count in MiB or GiB
+func formatBackupSize(bytes int64) string {
+	const mib = 1024 * 1024
+	if bytes >= 1024*mib {
+		return fmt.Sprintf("%.1f GiB", float64(bytes)/(1024*mib))
+	}
+	return fmt.Sprintf("%.1f MiB", float64(bytes)/mib)
+}
+
+func init() {
+	vmCmd.AddCommand(vmBackupCmd)
+	vmCmd.AddCommand(vmBackupsCmd)
+	vmCmd.AddCommand(vmRestoreCmd)
+
+	vmBackupCmd.Flags().String("to", "", "Backup directory (default: backup.path from the config)")
+	vmBackupCmd.Flags().Bool("incremental", false, "Copy only blocks changed since the VM's latest backup")
+	vmBackupCmd.Flags().Bool("no-compress", false, "Store uncompressed qcow2 images")
+	addJobFlags(vmBackupCmd)
+	vmBackupsCmd.Flags().String("from", "", "Backup directory (default: backup.path from the config)")
+	addListFlags(vmBackupsCmd)
+	vmRestoreCmd.Flags().String("from", "", "Backup directory (default: backup.path from the config)")
+	vmRestoreCmd.Flags().String("backup", "", "Backup ID (default: the latest)")
+	vmRestoreCmd.Flags().String("name", "", "Name of the restored VM (default: the original name)")
+	vmRestoreCmd.Flags().Bool("new-uuid", false, "Give the restored VM a new UUID and MAC addresses")
+	vmRestoreCmd.Flags().String("pool", "", "Storage pool for the restored disks (default: flint-image-library)")
+	vmRestoreCmd.Flags().Bool("start", false, "Start the VM once restored")
+	addJobFlags(vmRestoreCmd)
+}
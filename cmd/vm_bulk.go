@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/apiclient"
+	"github.com/volantvm/flint/pkg/bulk"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// addBulkFlags lets a single-VM command act on every VM matching a label selector
+func addBulkFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("selector", "l", "", "Act on all VMs whose labels match, e.g. env=dev,tier!=db")
+	cmd.Flags().Int("parallel", core.DefaultBulkParallelism, "With --selector, how many VMs to act on at a time")
+	addJobFlags(cmd)
+}
+
+// vmOrSelectorArgs accepts one VM name, or none with --selector
+func vmOrSelectorArgs(cmd *cobra.Command, args []string) error {
+	selector, _ := cmd.Flags().GetString("selector")
+	switch {
+	case selector != "" && len(args) > 0:
+		return errors.New("give a VM name or --selector, not both")
+	case selector == "" && len(args) != 1:
+		return errors.New("requires a VM name or --selector")
+	}
+	return nil
+}
+
+// vmOrSelectorJobArgs is vmOrSelectorArgs for commands that only run as a job
+// with --selector, so --async and --wait need one
+func vmOrSelectorJobArgs(cmd *cobra.Command, args []string) error {
+	if err := vmOrSelectorArgs(cmd, args); err != nil {
+		return err
+	}
+	if selector, _ := cmd.Flags().GetString("selector"); selector == "" && wantsServerJob(cmd) {
+		return errors.New("--async and --wait need --selector")
+	}
+	return nil
+}
+
+// bulkRequest returns the bulk request for the command's --selector, or false
+// if the command acts on a single VM
+func bulkRequest(cmd *cobra.Command, action string) (core.BulkVMActionRequest, bool) {
+	selector, _ := cmd.Flags().GetString("selector")
+	if selector == "" {
+		return core.BulkVMActionRequest{}, false
+	}
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	return core.BulkVMActionRequest{Action: action, Selector: selector, Parallelism: parallel}, true
+}
+
+// confirmBulk lists the VMs a bulk request selects and asks whether to go on
+func confirmBulk(client hostClient, req core.BulkVMActionRequest) bool {
+	vms, _, err := bulk.Select(client, req)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(vms) == 0 {
+		fmt.Printf("No VMs match %s\n", req.Selector)
+		return false
+	}
+
+	fmt.Printf("This will %s %d VMs:\n", req.Action, len(vms))
+	for _, vm := range vms {
+		fmt.Printf("  %s (%s)\n", vm.Name, vm.State)
+	}
+	fmt.Print("Continue? (y/N): ")
+	var response string
+	fmt.Scanln(&response)
+	if response != "y" && response != "Y" {
+		fmt.Println("Cancelled")
+		return false
+	}
+	return true
+}
+
+// runBulk runs a bulk request, on the Flint server if there is one, prints
+// the outcome for each VM and exits non-zero if any VM failed
+func runBulk(cmd *cobra.Command, req core.BulkVMActionRequest, confirm bool) {
+	if err := req.Validate(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	client, err := connectJobHost(cmd)
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if confirm && !confirmBulk(client, req) {
+		return
+	}
+	if startAsync(cmd, client, http.MethodPost, "/api/vms/actions", req) {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	printed := false
+	progress := func(percent float64, message string) {
+		fmt.Printf("\r  %5.1f%%  %-50s", percent, message)
+		printed = true
+	}
+	var result core.BulkVMActionResult
+	if api, ok := client.(*apiclient.Client); ok {
+		result, err = api.BulkVMAction(ctx, req, progress)
+	} else {
+		result, err = bulk.Run(ctx, client, req, progress)
+	}
+	if printed {
+		fmt.Println()
+	}
+	if err != nil {
+		log.Fatalf("Failed to %s VMs: %v", req.Action, err)
+	}
+	if len(result.Results) == 0 {
+		fmt.Printf("No VMs match %s\n", req.Selector)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tERROR")
+	fmt.Fprintln(tw, "----\t------\t-----")
+	for _, r := range result.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", orDash(r.Name), r.Status, orDash(r.Error))
+	}
+	tw.Flush()
+
+	fmt.Printf("\n%s: %d succeeded, %d unchanged, %d failed, %d skipped\n", req.Action, result.Succeeded, result.Unchanged, result.Failed, result.Skipped)
+	if result.Failed > 0 || result.Skipped > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/apiclient"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+var vmExportCmd = &cobra.Command{
+	Use:   "export [name]",
+	Short: "Export a shut-off VM as an OVA or tar archive",
+	Long: `Write a shut-off VM to an archive that other hypervisors or Flint hosts can import.
+
+  ova  OVF descriptor and streamOptimized VMDK disks, for VirtualBox and VMware
+  tar  Flint's domain XML and compressed qcow2 disks, plus an OVF descriptor
+
+Both formats include a manifest of SHA-256 checksums. CD-ROM media are not exported.
+
+Against a Flint server the archive is written to the server's export directory and
+only downloaded if --to is given.
+
+Examples:
+  flint vm export web01
+  flint vm export web01 --format tar --to /mnt/transfer/web01.tar`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		format, _ := cmd.Flags().GetString("format")
+		path, _ := cmd.Flags().GetString("to")
+
+		if format != core.ExportFormatOVA && format != core.ExportFormatTar {
+			log.Fatalf("Unsupported format '%s' (use ova or tar)", format)
+		}
+
+		client, err := connectJobHost(cmd)
+		if err != nil {
+			log.Fatalf("Failed to connect: %v", err)
+		}
+		defer client.Close()
+
+		api, remote := client.(*apiclient.Client)
+		destination := path
+		if remote {
+			destination = "the export directory of " + api.BaseURL()
+		} else if path == "" {
+			path = name + "." + format
+			destination = path
+		}
+
+		if remote && path != "" {
+			if async, _ := cmd.Flags().GetBool("async"); async {
+				log.Fatalf("--to cannot be combined with --async, which does not wait for the archive")
+			}
+			if _, err := os.Stat(path); err == nil {
+				log.Fatalf("%s already exists", path)
+			}
+		}
+
+		vm, err := findVM(client, name)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if startAsync(cmd, client, http.MethodPost, "/api/vms/"+url.PathEscape(vm.UUID)+"/export", core.ExportVMRequest{Format: format}) {
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Exporting VM '%s' to %s...\n", name, destination)
+		export, err := client.ExportVM(ctx, vm.UUID, core.ExportVMRequest{Format: format, Path: path}, func(percent float64, message string) {
+			fmt.Printf("\r  %5.1f%%  %-40s", percent, message)
+		})
+		fmt.Println()
+		if err != nil {
+			log.Fatalf("Failed to export VM: %v", err)
+		}
+
+		fmt.Printf("✅ Exported '%s' (%d disks, %s) in %s\n", name, export.Disks, formatBackupSize(export.SizeBytes),
+			time.Duration(export.DurationMs)*time.Millisecond)
+		fmt.Printf("   %s\n   sha256 %s\n", export.Path, export.SHA256)
+
+		if remote && path != "" {
+			fmt.Printf("Downloading %s to %s...\n", export.File, path)
+			if err := downloadExport(ctx, api, export.File, path); err != nil {
+				log.Fatalf("Failed to download export: %v", err)
+			}
+		}
+	},
+}
+
+var vmImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import a VM from an OVA, OVF or tar archive",
+	Long: `Define a new VM from an OVA or OVF package (VirtualBox, VMware) or a tar archive
+written by 'flint vm export'. Manifest checksums are verified when present, and the
+disks are converted to qcow2 volumes in the flint-image-library pool.
+
+Imported VMs get a new UUID and new MAC addresses. OVF packages are attached to
+--network; tar archives keep the networks of their original definition.
+
+Against a Flint server a local OVA or tar archive is uploaded to the server's export
+directory first; a name that is not a local file refers to a file already there.
+
+Examples:
+  flint vm import appliance.ova
+  flint vm import ./debian/debian.ovf --name debian-lab --network br0
+  flint vm import web01.tar --name web01-copy --start`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := core.ImportVMRequest{}
+		req.Name, _ = cmd.Flags().GetString("name")
+		req.Network, _ = cmd.Flags().GetString("network")
+		req.Start, _ = cmd.Flags().GetBool("start")
+
+		client, err := connectJobHost(cmd)
+		if err != nil {
+			log.Fatalf("Failed to connect: %v", err)
+		}
+		defer client.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if api, remote := client.(*apiclient.Client); remote {
+			file, err := uploadForImport(ctx, api, args[0])
+			if err != nil {
+				log.Fatalf("Failed to upload %s: %v", args[0], err)
+			}
+			req.File = file
+		} else {
+			path, err := filepath.Abs(args[0])
+			if err != nil {
+				log.Fatalf("Invalid path: %v", err)
+			}
+			req.File = path
+		}
+		if startAsync(cmd, client, http.MethodPost, "/api/vms/import", req) {
+			return
+		}
+
+		fmt.Printf("Importing %s...\n", args[0])
+		vm, err := client.ImportVM(ctx, req, func(percent float64, message string) {
+			fmt.Printf("\r  %5.1f%%  %-40s", percent, message)
+		})
+		fmt.Println()
+		if err != nil {
+			log.Fatalf("Failed to import VM: %v", err)
+		}
+
+		fmt.Printf("✅ VM '%s' imported (%s, %s)\n", vm.Name, vm.UUID, vm.State)
+	},
+}
+
+// downloadExport fetches an archive from the server's export directory to path
+func downloadExport(ctx context.Context, api *apiclient.Client, file, path string) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	_, err = api.DownloadExport(ctx, file, out)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// uploadForImport uploads a local archive to the server's export directory and
+// returns its name there. Anything that is not a local file is taken to be in
+// the export directory already.
+func uploadForImport(ctx context.Context, api *apiclient.Client, path string) (string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return filepath.Base(path), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".ovf") {
+		return "", fmt.Errorf("an OVF descriptor refers to disks in separate files; package it as an OVA to import it through a Flint server")
+	}
+
+	fmt.Printf("Uploading %s to %s...\n", path, api.BaseURL())
+	uploaded, err := api.UploadExport(ctx, filepath.Base(path), f)
+	if err != nil {
+		return "", err
+	}
+	return uploaded.Name, nil
+}
+
+func init() {
+	vmCmd.AddCommand(vmExportCmd)
+	vmCmd.AddCommand(vmImportCmd)
+
+	vmExportCmd.Flags().String("format", core.ExportFormatOVA, "Archive format (ova, tar)")
+	vmExportCmd.Flags().String("to", "", "Archive to write (default: ./<name>.<format>, or none against a Flint server)")
+	vmImportCmd.Flags().String("name", "", "Name of the imported VM (default: the name in the package)")
+	vmImportCmd.Flags().String("network", "default", "Network for the VM's interfaces when importing OVF")
+	vmImportCmd.Flags().Bool("start", false, "Start the VM once imported")
+	addJobFlags(vmExportCmd)
+	addJobFlags(vmImportCmd)
+}
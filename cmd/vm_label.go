@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+var vmLabelCmd = &cobra.Command{
+	Use:   "label [name] [key=value ...] [key- ...]",
+	Short: "Show or change a VM's labels and description",
+	Long: `Set labels with key=value and remove them with key-. Without changes the command
+shows the VM's labels and description.
+
+Labels are kept in the domain's metadata, so they stay with the VM when it is
+migrated, exported or managed with virsh. Select VMs by label with
+"flint vm ls -l label.env=prod" or GET /api/vms?label=env=prod.
+
+Examples:
+  flint vm label web-01
+  flint vm label web-01 env=prod tier=web
+  flint vm label web-01 tier- --description "Front end, owned by the web team"
+  flint vm label web-01 env=staging --replace     # Drop all other labels`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		var req core.UpdateVMLabelsRequest
+		labels, err := parseLabelArgs(args[1:])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		req.Labels = labels
+		req.Replace, _ = cmd.Flags().GetBool("replace")
+		if cmd.Flags().Changed("description") {
+			description, _ := cmd.Flags().GetString("description")
+			req.Description = &description
+		}
+		if err := req.Validate(); err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		client, err := connectHost()
+		if err != nil {
+			log.Fatalf("Failed to connect: %v", err)
+		}
+		defer client.Close()
+
+		summary, err := findVM(client, name)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		vm := summary
+		if len(req.Labels) > 0 || req.Replace || req.Description != nil {
+			updated, err := client.UpdateVMLabels(summary.UUID, req)
+			if err != nil {
+				log.Fatalf("Failed to update labels: %v", err)
+			}
+			vm = updated.VM_Summary
+			if wantsTable(cmd) {
+				fmt.Printf("VM '%s' labels updated\n", name)
+			}
+		}
+
+		view := vmLabels{Name: vm.Name, Labels: vm.Labels, Description: vm.Description}
+		if printObject(cmd, view) {
+			return
+		}
+		if vm.Description != "" {
+			fmt.Printf("Description: %s\n", vm.Description)
+		}
+		if len(vm.Labels) == 0 {
+			fmt.Println("No labels")
+			return
+		}
+		keys := make([]string, 0, len(vm.Labels))
+		for key := range vm.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("%s=%s\n", key, vm.Labels[key])
+		}
+	},
+}
+
+// vmLabels is what vm label prints with -o
+type vmLabels struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels"`
+	Description string            `json:"description"`
+}
+
+// parseLabelArgs turns key=value and key- arguments into label changes
+func parseLabelArgs(args []string) (map[string]*string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]*string, len(args))
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			labels[key] = &value
+		} else if key, ok := strings.CutSuffix(arg, "-"); ok {
+			labels[key] = nil
+		} else {
+			return nil, fmt.Errorf("invalid label %q: use key=value to set a label or key- to remove it", arg)
+		}
+	}
+	return labels, nil
+}
+
+func init() {
+	vmCmd.AddCommand(vmLabelCmd)
+
+	addOutputFlags(vmLabelCmd)
+	vmLabelCmd.Flags().String("description", "", "Set the VM's description (\"\" clears it)")
+	vmLabelCmd.Flags().Bool("replace", false, "Remove labels not given on the command line")
+}
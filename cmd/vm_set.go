@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+var vmSetCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Change a VM's vCPUs, memory, disks, NICs or CD-ROM in place",
+	Long: `Reconfigure a VM without recreating it. A running VM takes a change at once
+when the hypervisor allows it: vCPUs are hotplugged up to the VM's maximum,
+memory is ballooned up to its maximum, disks grow with a block resize, NICs move
+to another network and CD-ROM media is swapped. Every change is also saved to
+the VM's definition; what a running VM cannot take live, such as more vCPUs or
+memory than its maximum or a new NIC model, applies when it is restarted.
+The APPLIED column shows which is the case.
+
+Disks can only grow. Give --resize-disk and --nic once per device.
+
+Examples:
+  flint vm set web-01 --vcpus 4 --memory 8192
+  flint vm set web-01 --resize-disk vdb=200
+  flint vm set web-01 --nic 52:54:00:12:34:56,network=lab
+  flint vm set web-01 --nic 52:54:00:12:34:56,model=e1000
+  flint vm set web-01 --cdrom ubuntu-24.04.iso
+  flint vm set web-01 --eject`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var req core.UpdateVMRequest
+		req.VCPUs, _ = cmd.Flags().GetInt("vcpus")
+		req.MemoryMB, _ = cmd.Flags().GetUint64("memory")
+
+		resizes, _ := cmd.Flags().GetStringArray("resize-disk")
+		for _, arg := range resizes {
+			resize, err := parseDiskResize(arg)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			req.ResizeDisks = append(req.ResizeDisks, resize)
+		}
+		nics, _ := cmd.Flags().GetStringArray("nic")
+		for _, arg := range nics {
+			nic, err := parseNICUpdate(arg)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			req.UpdateNICs = append(req.UpdateNICs, nic)
+		}
+
+		iso, _ := cmd.Flags().GetString("cdrom")
+		eject, _ := cmd.Flags().GetBool("eject")
+		if iso != "" || eject {
+			target, _ := cmd.Flags().GetString("cdrom-target")
+			req.CDROM = &core.CDROMChange{Target: target, ISO: iso, Eject: eject}
+		}
+
+		runUpdateVM(cmd, args[0], req)
+	},
+}
+
+var vmDetachCmd = &cobra.Command{
+	Use:   "detach [name]",
+	Short: "Detach disks or NICs from a VM",
+	Long: `Remove disks by target device and NICs by MAC address, from a running VM and
+from its definition. A running guest is asked to release the device. Detached
+disks keep their storage volumes; delete them with 'flint storage volume delete'.
+
+Examples:
+  flint vm detach web-01 --disk vdb
+  flint vm detach web-01 --nic 52:54:00:12:34:56 --disk vdc`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var req core.UpdateVMRequest
+		req.DetachDisks, _ = cmd.Flags().GetStringArray("disk")
+		req.DetachNICs, _ = cmd.Flags().GetStringArray("nic")
+		if req.IsEmpty() {
+			log.Fatalf("Give a --disk or --nic to detach")
+		}
+		runUpdateVM(cmd, args[0], req)
+	},
+}
+
+// runUpdateVM reconfigures a VM and prints the changes made
+func runUpdateVM(cmd *cobra.Command, name string, req core.UpdateVMRequest) {
+	if err := req.Validate(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	client, err := connectHost()
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	vm, err := findVM(client, name)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	result, err := client.UpdateVM(vm.UUID, req)
+	if err != nil {
+		log.Fatalf("Failed to update VM: %v", err)
+	}
+	if printObject(cmd, result) {
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tFROM\tTO\tAPPLIED\tNOTE")
+	fmt.Fprintln(tw, "-----\t----\t--\t-------\t----")
+	for _, change := range result.Changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", change.Field, orDash(change.From), orDash(change.To), changeApplied(change), orDash(change.Note))
+	}
+	tw.Flush()
+
+	if result.RestartRequired {
+		fmt.Printf("\nRestart '%s' for the changes applied at restart to take effect\n", name)
+	}
+}
+
+// changeApplied says where a change took effect
+func changeApplied(change core.VMChange) string {
+	switch {
+	case change.Live && change.Config:
+		return "live"
+	case change.Live:
+		return "live only"
+	case change.RestartRequired:
+		return "at restart"
+	case change.Config:
+		return "config"
+	}
+	return "-"
+}
+
+// parseDiskResize parses a --resize-disk value, target=sizeGB
+func parseDiskResize(arg string) (core.DiskResize, error) {
+	target, size, ok := strings.Cut(arg, "=")
+	sizeGB, err := strconv.ParseUint(strings.TrimSuffix(strings.ToUpper(size), "G"), 10, 64)
+	if !ok || err != nil {
+		return core.DiskResize{}, fmt.Errorf("invalid --resize-disk %q: use target=sizeGB, e.g. vdb=100", arg)
+	}
+	return core.DiskResize{Target: target, SizeGB: sizeGB}, nil
+}
+
+// parseNICUpdate parses a --nic value, MAC,network=NAME,model=MODEL
+func parseNICUpdate(arg string) (core.NICUpdate, error) {
+	parts := strings.Split(arg, ",")
+	nic := core.NICUpdate{MAC: parts[0]}
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "network":
+			nic.Network = value
+		case "model":
+			nic.Model = value
+		default:
+			return core.NICUpdate{}, fmt.Errorf("invalid --nic %q: use MAC,network=NAME,model=MODEL", arg)
+		}
+	}
+	return nic, nil
+}
+
+func init() {
+	vmCmd.AddCommand(vmSetCmd)
+	vmCmd.AddCommand(vmDetachCmd)
+
+	addOutputFlags(vmSetCmd)
+	vmSetCmd.Flags().Int("vcpus", 0, "Number of vCPUs")
+	vmSetCmd.Flags().Uint64("memory", 0, "Memory in MB")
+	vmSetCmd.Flags().StringArray("resize-disk", nil, "Grow a disk, target=sizeGB, e.g. vdb=100 (repeatable)")
+	vmSetCmd.Flags().StringArray("nic", nil, "Change a NIC, MAC,network=NAME,model=MODEL (repeatable)")
+	vmSetCmd.Flags().String("cdrom", "", "Insert an ISO from the image library, or an absolute path")
+	vmSetCmd.Flags().Bool("eject", false, "Eject the CD-ROM")
+	vmSetCmd.Flags().String("cdrom-target", "", "CD-ROM drive to change, e.g. sdb (default: the first one)")
+
+	addOutputFlags(vmDetachCmd)
+	vmDetachCmd.Flags().StringArray("disk", nil, "Target device of a disk to detach, e.g. vdb (repeatable)")
+	vmDetachCmd.Flags().StringArray("nic", nil, "MAC address of a NIC to detach (repeatable)")
+}
@@ -4,11 +4,13 @@ go 1.25.0
 
 require (
 	github.com/go-chi/chi/v5 v5.2.3
+	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/libvirt/libvirt-go v7.4.0+incompatible
 	github.com/spf13/cobra v1.10.1
 	golang.org/x/crypto v0.41.0
 	golang.org/x/term v0.35.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -4,10 +4,25 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/logger"
 	"sync"
 	"time"
 )
 
+// persistent receives a copy of every event added to any Logger, so history
+// survives restarts and reconnects. It is nil until SetStore is called.
+var (
+	persistent   *Store
+	persistentMu sync.RWMutex
+)
+
+// SetStore makes every Logger also record its events in store. Pass nil to stop.
+func SetStore(store *Store) {
+	persistentMu.Lock()
+	defer persistentMu.Unlock()
+	persistent = store
+}
+
 // Logger is an in-memory, thread-safe, capped-size activity logger.
 type Logger struct {
 	mu      sync.RWMutex
@@ -28,23 +43,31 @@ func NewLogger(maxSize int) *Logger {
 
 // Add adds a new activity event.
 func (l *Logger) Add(action, target, status, message string) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	// Generate a simple ID
-	idBytes := make([]byte, 8)
-	rand.Read(idBytes)
-	id := hex.EncodeToString(idBytes)
-
 	event := core.ActivityEvent{
-		ID:        id,
+		ID:        newEventID(),
 		Timestamp: time.Now().Unix(),
 		Action:    action,
 		Target:    target,
 		Status:    status,
 		Message:   message,
+		Actor:     "system",
 	}
 
+	persistentMu.RLock()
+	store := persistent
+	persistentMu.RUnlock()
+	if store != nil {
+		if err := store.Record(event); err != nil {
+			logger.Warn("Failed to persist activity event", map[string]interface{}{
+				"action": action,
+				"error":  err.Error(),
+			})
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	l.events = append(l.events, event)
 
 	// Trim if we exceed max size
@@ -54,6 +77,13 @@ func (l *Logger) Add(action, target, status, message string) {
 	}
 }
 
+// newEventID generates a simple random event ID
+func newEventID() string {
+	idBytes := make([]byte, 8)
+	rand.Read(idBytes)
+	return hex.EncodeToString(idBytes)
+}
+
 // Get returns a copy of all current activity events.
 func (l *Logger) Get() []core.ActivityEvent {
 	l.mu.RLock()
@@ -0,0 +1,294 @@
+package activity
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// DefaultStorePath is where the audit log lives unless configured otherwise
+const DefaultStorePath = "/var/lib/flint/audit/activity.jsonl"
+
+// pruneInterval is how often Record checks whether events have aged out
+const pruneInterval = time.Hour
+
+// Retention bounds how much history the store keeps. Zero values disable a limit.
+type Retention struct {
+	MaxAge    time.Duration
+	MaxEvents int
+}
+
+// Query filters and paginates stored events. Zero values match everything.
+type Query struct {
+	Since    time.Time
+	Until    time.Time
+	Target   string // Matches the target name or UUID
+	Action   string // Case-insensitive substring of the action
+	Status   string
+	Actor    string
+	ServerID string
+	Limit    int
+	Offset   int
+
+	// ServerIDs, when non-nil, limits results to these servers ("" is the local connection)
+	ServerIDs []string
+}
+
+// Store is a durable, append-only audit log kept as JSON lines on disk
+type Store struct {
+	mu        sync.RWMutex
+	path      string
+	file      *os.File
+	events    []core.ActivityEvent // Oldest first, mirrors the file
+	retention Retention
+	lastPrune time.Time
+}
+
+// NewStore opens (or creates) the audit log at path and applies retention
+func NewStore(path string, retention Retention) (*Store, error) {
+	if path == "" {
+		path = DefaultStorePath
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	s := &Store{
+		path:      path,
+		retention: retention,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.pruneLocked(); err != nil {
+		return nil, err
+	}
+	if s.file == nil {
+		if err := s.openLocked(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Record appends an event to the log, filling in its ID and timestamp if missing
+func (s *Store) Record(event core.ActivityEvent) error {
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.Timestamp == 0 {
+		event.Timestamp = time.Now().Unix()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		if err := s.openLocked(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write activity event: %w", err)
+	}
+	s.events = append(s.events, event)
+
+	overLimit := s.retention.MaxEvents > 0 && len(s.events) > s.retention.MaxEvents+s.retention.MaxEvents/10
+	if overLimit || time.Since(s.lastPrune) > pruneInterval {
+		return s.pruneLocked()
+	}
+	return nil
+}
+
+// Query returns the matching events, newest first, along with the total number
+// of matches before pagination
+func (s *Store) Query(q Query) ([]core.ActivityEvent, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched []core.ActivityEvent
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if q.matches(s.events[i]) {
+			matched = append(matched, s.events[i])
+		}
+	}
+
+	total := len(matched)
+	if q.Offset > 0 {
+		if q.Offset >= len(matched) {
+			return []core.ActivityEvent{}, total
+		}
+		matched = matched[q.Offset:]
+	}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	if matched == nil {
+		matched = []core.ActivityEvent{}
+	}
+
+	return matched, total
+}
+
+// Close flushes and closes the underlying file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// matches reports whether an event satisfies the query filters
+func (q Query) matches(e core.ActivityEvent) bool {
+	if !q.Since.IsZero() && e.Timestamp < q.Since.Unix() {
+		return false
+	}
+	if !q.Until.IsZero() && e.Timestamp > q.Until.Unix() {
+		return false
+	}
+	if q.Target != "" && e.Target != q.Target && e.TargetUUID != q.Target {
+		return false
+	}
+	if q.Action != "" && !strings.Contains(strings.ToLower(e.Action), strings.ToLower(q.Action)) {
+		return false
+	}
+	if q.Status != "" && !strings.EqualFold(e.Status, q.Status) {
+		return false
+	}
+	if q.Actor != "" && e.Actor != q.Actor {
+		return false
+	}
+	if q.ServerID != "" && e.ServerID != q.ServerID {
+		return false
+	}
+	if q.ServerIDs != nil && !slices.Contains(q.ServerIDs, e.ServerID) {
+		return false
+	}
+	return true
+}
+
+// load reads existing events, skipping lines that cannot be parsed (e.g. a
+// partial write from a crash)
+func (s *Store) load() error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event core.ActivityEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		s.events = append(s.events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	// Appends are chronological, but keep the invariant even if files were merged by hand
+	sort.SliceStable(s.events, func(i, j int) bool {
+		return s.events[i].Timestamp < s.events[j].Timestamp
+	})
+
+	return nil
+}
+
+// openLocked opens the log for appending. Callers must hold s.mu or own the store.
+func (s *Store) openLocked() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	s.file = file
+	return nil
+}
+
+// pruneLocked drops events outside the retention window and rewrites the file
+// when anything was removed. Callers must hold s.mu or own the store.
+func (s *Store) pruneLocked() error {
+	s.lastPrune = time.Now()
+
+	keepFrom := 0
+	if s.retention.MaxAge > 0 {
+		cutoff := time.Now().Add(-s.retention.MaxAge).Unix()
+		for keepFrom < len(s.events) && s.events[keepFrom].Timestamp < cutoff {
+			keepFrom++
+		}
+	}
+	if s.retention.MaxEvents > 0 && len(s.events)-keepFrom > s.retention.MaxEvents {
+		keepFrom = len(s.events) - s.retention.MaxEvents
+	}
+	if keepFrom == 0 {
+		return nil
+	}
+
+	s.events = append([]core.ActivityEvent(nil), s.events[keepFrom:]...)
+	return s.rewriteLocked()
+}
+
+// rewriteLocked atomically replaces the log file with the in-memory events
+func (s *Store) rewriteLocked() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to compact audit log: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, event := range s.events {
+		if err := encoder.Encode(event); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to compact audit log: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact audit log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact audit log: %w", err)
+	}
+
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to compact audit log: %w", err)
+	}
+
+	return s.openLocked()
+}
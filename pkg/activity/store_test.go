@@ -0,0 +1,78 @@
+package activity
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+func TestStore_PersistsAndQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity.jsonl")
+	store, err := NewStore(path, Retention{})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	now := time.Now().Unix()
+	store.Record(core.ActivityEvent{Timestamp: now - 7200, Action: "VM Started", Target: "web-01", Status: "Success"})
+	store.Record(core.ActivityEvent{Timestamp: now - 60, Action: "VM Stopped", Target: "web-01", Status: "Error"})
+	store.Record(core.ActivityEvent{Timestamp: now, Action: "VM Started", Target: "db-01", Status: "Success"})
+	store.Close()
+
+	// Reopen to make sure events survive a restart
+	store, err = NewStore(path, Retention{})
+	if err != nil {
+		t.Fatalf("NewStore() reopen error = %v", err)
+	}
+	defer store.Close()
+
+	events, total := store.Query(Query{})
+	if total != 3 || events[0].Target != "db-01" {
+		t.Fatalf("Expected 3 events newest first, got %d: %+v", total, events)
+	}
+
+	if _, total := store.Query(Query{Target: "web-01"}); total != 2 {
+		t.Errorf("Expected 2 events for web-01, got %d", total)
+	}
+	if _, total := store.Query(Query{Action: "started", Status: "success"}); total != 2 {
+		t.Errorf("Expected 2 successful starts, got %d", total)
+	}
+	if _, total := store.Query(Query{Since: time.Now().Add(-time.Hour)}); total != 2 {
+		t.Errorf("Expected 2 events in the last hour, got %d", total)
+	}
+
+	page, total := store.Query(Query{Limit: 1, Offset: 1})
+	if total != 3 || len(page) != 1 || page[0].Action != "VM Stopped" {
+		t.Errorf("Unexpected page: total=%d %+v", total, page)
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity.jsonl")
+	store, err := NewStore(path, Retention{})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour).Unix()
+	store.Record(core.ActivityEvent{Timestamp: old, Action: "VM Created", Target: "old"})
+	for i := 0; i < 5; i++ {
+		store.Record(core.ActivityEvent{Action: "VM Started", Target: "new"})
+	}
+	store.Close()
+
+	store, err = NewStore(path, Retention{MaxAge: 24 * time.Hour, MaxEvents: 3})
+	if err != nil {
+		t.Fatalf("NewStore() reopen error = %v", err)
+	}
+	defer store.Close()
+
+	if _, total := store.Query(Query{}); total != 3 {
+		t.Errorf("Expected retention to keep 3 events, got %d", total)
+	}
+	if _, total := store.Query(Query{Target: "old"}); total != 0 {
+		t.Errorf("Expected events older than MaxAge to be dropped")
+	}
+}
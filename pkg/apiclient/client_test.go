@@ -0,0 +1,126 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	client := New(srv.URL+"/", "secret")
+	client.PollInterval = time.Millisecond
+	return client
+}
+
+func TestClient_Headers(t *testing.T) {
+	var got http.Header
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		if r.URL.Path != "/api/vms" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode([]core.VM_Summary{{Name: "web-01", UUID: "u1"}})
+	}))
+
+	vms, err := client.WithServer("hv-02").GetVMSummaries()
+	if err != nil {
+		t.Fatalf("GetVMSummaries failed: %v", err)
+	}
+	if len(vms) != 1 || vms[0].Name != "web-01" {
+		t.Errorf("Unexpected VMs %+v", vms)
+	}
+	if got.Get("Authorization") != "Bearer secret" || got.Get("User-Agent") != "flint-cli" || got.Get(ServerHeader) != "hv-02" {
+		t.Errorf("Unexpected headers %v", got)
+	}
+
+	if _, err := client.GetVMSummaries(); err != nil {
+		t.Fatalf("GetVMSummaries failed: %v", err)
+	}
+	if got.Get(ServerHeader) != "" {
+		t.Errorf("WithServer must not change the original client, got %s", got.Get(ServerHeader))
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/vms/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "VM not found"}`))
+		case "/api/vms/broken":
+			http.Error(w, "lookup domain: boom", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	_, err := client.GetVMDetails("missing")
+	if !IsNotFound(err) || !strings.Contains(err.Error(), "VM not found") {
+		t.Errorf("Expected a not found error with the API message, got %v", err)
+	}
+	if err := client.DeleteVM("broken", false); err == nil || !strings.Contains(err.Error(), "lookup domain: boom") || IsNotFound(err) {
+		t.Errorf("Expected the plain text error, got %v", err)
+	}
+	if _, err := client.GetNetworks(); err == nil || !strings.Contains(err.Error(), "flint api-key") {
+		t.Errorf("Expected an authentication hint, got %v", err)
+	}
+}
+
+func TestClient_RunJob(t *testing.T) {
+	var polls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/vms", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("async") != "true" {
+			t.Errorf("Expected an async request, got %s", r.URL.RawQuery)
+		}
+		var cfg core.VMCreationConfig
+		json.NewDecoder(r.Body).Decode(&cfg)
+		if cfg.Name != "web-01" {
+			t.Errorf("Unexpected config %+v", cfg)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(core.Job{ID: "job-1", State: core.JobQueued})
+	})
+	mux.HandleFunc("POST /api/vms/u1/backups", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(core.Job{ID: "job-2", State: core.JobQueued})
+	})
+	mux.HandleFunc("GET /api/jobs/job-1", func(w http.ResponseWriter, r *http.Request) {
+		job := core.Job{ID: "job-1", State: core.JobRunning, Progress: 50}
+		if polls.Add(1) > 1 {
+			job.State = core.JobSucceeded
+			job.Result = core.VM_Detailed{VM_Summary: core.VM_Summary{Name: "web-01", UUID: "u1"}}
+		}
+		json.NewEncoder(w).Encode(job)
+	})
+	mux.HandleFunc("GET /api/jobs/job-2", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(core.Job{ID: "job-2", State: core.JobFailed, Error: "disk full"})
+	})
+	client := newTestClient(t, mux)
+
+	vm, err := client.CreateVM(core.VMCreationConfig{Name: "web-01"})
+	if err != nil {
+		t.Fatalf("CreateVM failed: %v", err)
+	}
+	if vm.Name != "web-01" || vm.UUID != "u1" {
+		t.Errorf("Expected the job result, got %+v", vm)
+	}
+	if polls.Load() != 2 {
+		t.Errorf("Expected 2 polls, got %d", polls.Load())
+	}
+
+	if _, err := client.BackupVM(context.Background(), "u1", core.CreateBackupRequest{}, nil); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Expected the job error, got %v", err)
+	}
+}
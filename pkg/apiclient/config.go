@@ -0,0 +1,129 @@
+package apiclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Context is a named Flint server the CLI can talk to, like a kubeconfig context
+type Context struct {
+	Name   string `json:"name"`
+	Server string `json:"server"`            // Flint URL, e.g. https://flint.lab:5550
+	APIKey string `json:"api_key,omitempty"` // API key or token
+	Host   string `json:"host,omitempty"`    // Registered server to operate on, by ID or name
+}
+
+// Config is the CLI's list of contexts, stored in ~/.flint/cli.json
+type Config struct {
+	CurrentContext string    `json:"current_context,omitempty"`
+	Contexts       []Context `json:"contexts"`
+
+	path string
+}
+
+// DefaultConfigPath returns ~/.flint/cli.json
+func DefaultConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".flint", "cli.json"), nil
+}
+
+// LoadConfig reads the CLI config at path, or at DefaultConfigPath if path is
+// empty. A missing file is an empty config.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultConfigPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := &Config{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Save writes the config back where it was loaded from. The file holds API
+// keys, so only the owner may read it.
+func (c *Config) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
+
+// Get returns the context with the given name
+func (c *Config) Get(name string) (Context, bool) {
+	for _, ctx := range c.Contexts {
+		if ctx.Name == name {
+			return ctx, true
+		}
+	}
+	return Context{}, false
+}
+
+// Current returns the current context, if one is set
+func (c *Config) Current() (Context, bool) {
+	if c.CurrentContext == "" {
+		return Context{}, false
+	}
+	return c.Get(c.CurrentContext)
+}
+
+// Set adds a context or replaces the one with the same name
+func (c *Config) Set(ctx Context) error {
+	if ctx.Name == "" {
+		return fmt.Errorf("context name is required")
+	}
+	if ctx.Server == "" {
+		return fmt.Errorf("context %s: server is required", ctx.Name)
+	}
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == ctx.Name {
+			c.Contexts[i] = ctx
+			return nil
+		}
+	}
+	c.Contexts = append(c.Contexts, ctx)
+	return nil
+}
+
+// Use makes the named context current
+func (c *Config) Use(name string) error {
+	if _, ok := c.Get(name); !ok {
+		return fmt.Errorf("context %q not found", name)
+	}
+	c.CurrentContext = name
+	return nil
+}
+
+// Delete removes a context. Deleting the current context falls back to local libvirt.
+func (c *Config) Delete(name string) error {
+	for i, ctx := range c.Contexts {
+		if ctx.Name == name {
+			c.Contexts = append(c.Contexts[:i], c.Contexts[i+1:]...)
+			if c.CurrentContext == name {
+				c.CurrentContext = ""
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("context %q not found", name)
+}
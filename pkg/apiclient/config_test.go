@@ -0,0 +1,68 @@
+package apiclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".flint", "cli.json")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig on a missing file failed: %v", err)
+	}
+	if _, ok := cfg.Current(); ok {
+		t.Fatalf("Expected no current context in an empty config")
+	}
+
+	if err := cfg.Set(Context{Name: "lab"}); err == nil {
+		t.Errorf("Expected an error for a context without a server")
+	}
+	if err := cfg.Set(Context{Name: "lab", Server: "https://lab:5550", APIKey: "k1"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cfg.Set(Context{Name: "prod", Server: "https://prod:5550", Host: "hv-02"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cfg.Set(Context{Name: "lab", Server: "https://lab:5550", APIKey: "k2"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := cfg.Use("missing"); err == nil {
+		t.Errorf("Expected an error using a missing context")
+	}
+	if err := cfg.Use("lab"); err != nil {
+		t.Fatalf("Use failed: %v", err)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	current, ok := loaded.Current()
+	if !ok || current.APIKey != "k2" || len(loaded.Contexts) != 2 {
+		t.Errorf("Expected the updated lab context to be current, got %+v", loaded)
+	}
+
+	if err := loaded.Delete("lab"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if loaded.CurrentContext != "" {
+		t.Errorf("Deleting the current context should clear it, got %q", loaded.CurrentContext)
+	}
+	if err := loaded.Delete("lab"); err == nil {
+		t.Errorf("Expected an error deleting a missing context")
+	}
+}
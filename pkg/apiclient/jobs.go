@@ -0,0 +1,131 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// JobFilter narrows ListJobs; empty fields match everything
+type JobFilter struct {
+	Type   string
+	State  core.JobState
+	Target string
+	Server string
+}
+
+// ListJobs returns tracked jobs, newest first
+func (c *Client) ListJobs(ctx context.Context, filter JobFilter) ([]core.Job, error) {
+	query := url.Values{}
+	if filter.Type != "" {
+		query.Set("type", filter.Type)
+	}
+	if filter.State != "" {
+		query.Set("state", string(filter.State))
+	}
+	if filter.Target != "" {
+		query.Set("target", filter.Target)
+	}
+	if filter.Server != "" {
+		query.Set("server", filter.Server)
+	}
+
+	var jobs []core.Job
+	err := c.Do(ctx, http.MethodGet, "/api/jobs", query, nil, &jobs)
+	return jobs, err
+}
+
+// GetJob returns a single job
+func (c *Client) GetJob(ctx context.Context, id string) (core.Job, error) {
+	var job core.Job
+	err := c.Do(ctx, http.MethodGet, "/api/jobs/"+escape(id), nil, nil, &job)
+	return job, err
+}
+
+// CancelJob requests cancellation of a queued or running job
+func (c *Client) CancelJob(ctx context.Context, id string) (core.Job, error) {
+	var job core.Job
+	err := c.Do(ctx, http.MethodPost, "/api/jobs/"+escape(id)+"/cancel", nil, nil, &job)
+	return job, err
+}
+
+// WaitJob polls a job until it finishes or ctx is done, calling progress, if
+// not nil, after every poll of an unfinished job. A failed or cancelled job is
+// returned without an error; check its State. On error the last job polled is
+// returned.
+func (c *Client) WaitJob(ctx context.Context, id string, progress func(core.Job)) (core.Job, error) {
+	ticker := time.NewTicker(c.PollInterval)
+	defer ticker.Stop()
+
+	var last core.Job
+	for {
+		job, err := c.GetJob(ctx, id)
+		if err != nil {
+			return last, err
+		}
+		last = job
+		if job.State.IsFinal() {
+			return job, nil
+		}
+		if progress != nil {
+			progress(job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// StartJob starts an operation as a background job with ?async=true and returns
+// the queued job without waiting for it
+func (c *Client) StartJob(ctx context.Context, method, path string, body interface{}) (core.Job, error) {
+	var job core.Job
+	err := c.Do(ctx, method, path, url.Values{"async": {"true"}}, body, &job)
+	return job, err
+}
+
+// runJob starts an operation as a background job, waits for it and decodes its
+// result into out, if not nil. Cancelling ctx cancels the job on the server.
+func (c *Client) runJob(ctx context.Context, method, path string, body, out interface{}, progress func(percent float64, message string)) error {
+	job, err := c.StartJob(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	id := job.ID
+	job, err = c.WaitJob(ctx, id, func(job core.Job) {
+		if progress != nil {
+			progress(job.Progress, job.Message)
+		}
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// Best effort; the job would otherwise keep running unattended
+			c.CancelJob(context.Background(), id)
+		}
+		return err
+	}
+	if job.State != core.JobSucceeded {
+		return fmt.Errorf("job %s %s: %s", job.ID, job.State, job.Error)
+	}
+	if progress != nil {
+		progress(100, job.Message)
+	}
+
+	if out == nil || job.Result == nil {
+		return nil
+	}
+	data, err := json.Marshal(job.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
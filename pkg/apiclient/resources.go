@@ -0,0 +1,62 @@
+package apiclient
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// GetStoragePools lists the host's storage pools
+func (c *Client) GetStoragePools() ([]core.StoragePool, error) {
+	var pools []core.StoragePool
+	err := c.Do(context.Background(), http.MethodGet, "/api/storage-pools", nil, nil, &pools)
+	return pools, err
+}
+
+// GetVolumes lists the volumes in a storage pool
+func (c *Client) GetVolumes(poolName string) ([]core.Volume, error) {
+	var volumes []core.Volume
+	err := c.Do(context.Background(), http.MethodGet, "/api/storage-pools/"+escape(poolName)+"/volumes", nil, nil, &volumes)
+	return volumes, err
+}
+
+// CreateVolume creates a volume in a storage pool
+func (c *Client) CreateVolume(poolName string, volConfig core.VolumeConfig) error {
+	return c.Do(context.Background(), http.MethodPost, "/api/storage-pools/"+escape(poolName)+"/volumes", nil, volConfig, nil)
+}
+
+// UpdateVolume grows a volume to config.SizeGB and waits for the volume.resize job
+func (c *Client) UpdateVolume(poolName string, volumeName string, config core.VolumeConfig) error {
+	body := map[string]uint64{"size_gb": config.SizeGB}
+	return c.runJob(context.Background(), http.MethodPut, "/api/storage-pools/"+escape(poolName)+"/volumes/"+escape(volumeName), body, nil, nil)
+}
+
+// DeleteVolume deletes a volume
+func (c *Client) DeleteVolume(poolName string, volumeName string) error {
+	return c.Do(context.Background(), http.MethodDelete, "/api/storage-pools/"+escape(poolName)+"/volumes/"+escape(volumeName), nil, nil, nil)
+}
+
+// GetNetworks lists the host's virtual networks
+func (c *Client) GetNetworks() ([]core.Network, error) {
+	var networks []core.Network
+	err := c.Do(context.Background(), http.MethodGet, "/api/networks", nil, nil, &networks)
+	return networks, err
+}
+
+// CreateNetwork creates a virtual network on a bridge
+func (c *Client) CreateNetwork(name string, bridgeName string) error {
+	body := map[string]string{"name": name, "bridgeName": bridgeName}
+	return c.Do(context.Background(), http.MethodPost, "/api/networks", nil, body, nil)
+}
+
+// UpdateNetwork starts, stops or restarts a virtual network
+func (c *Client) UpdateNetwork(name string, action string) error {
+	body := map[string]string{"action": action}
+	return c.Do(context.Background(), http.MethodPut, "/api/networks/"+escape(name), nil, body, nil)
+}
+
+// DeleteNetwork deletes a virtual network
+func (c *Client) DeleteNetwork(name string) error {
+	return c.Do(context.Background(), http.MethodDelete, "/api/networks/"+escape(name), nil, nil, nil)
+}
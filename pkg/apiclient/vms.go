@@ -0,0 +1,198 @@
+package apiclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// GetVMSummaries lists the VMs on the host
+func (c *Client) GetVMSummaries() ([]core.VM_Summary, error) {
+	var vms []core.VM_Summary
+	err := c.Do(context.Background(), http.MethodGet, "/api/vms", nil, nil, &vms)
+	return vms, err
+}
+
+// GetVMDetails returns a VM by UUID
+func (c *Client) GetVMDetails(uuidStr string) (core.VM_Detailed, error) {
+	var vm core.VM_Detailed
+	err := c.Do(context.Background(), http.MethodGet, "/api/vms/"+escape(uuidStr), nil, nil, &vm)
+	return vm, err
+}
+
+// CreateVM creates a VM and waits for the vm.create job
+func (c *Client) CreateVM(cfg core.VMCreationConfig) (core.VM_Detailed, error) {
+	var vm core.VM_Detailed
+	err := c.runJob(context.Background(), http.MethodPost, "/api/vms", cfg, &vm, nil)
+	return vm, err
+}
+
+// PerformVMAction starts, stops, reboots, resets, pauses or resumes a VM.
+// "force-stop" powers it off.
+func (c *Client) PerformVMAction(uuidStr string, action string) error {
+	body := map[string]string{"action": action}
+	return c.Do(context.Background(), http.MethodPost, "/api/vms/"+escape(uuidStr)+"/action", nil, body, nil)
+}
+
+// DeleteVM undefines a VM, also deleting its disks if deleteDisks is set
+func (c *Client) DeleteVM(uuidStr string, deleteDisks bool) error {
+	query := url.Values{"deleteDisks": {strconv.FormatBool(deleteDisks)}}
+	return c.Do(context.Background(), http.MethodDelete, "/api/vms/"+escape(uuidStr), query, nil, nil)
+}
+
+// CheckGuestAgentStatus reports whether the VM's guest agent responds
+func (c *Client) CheckGuestAgentStatus(uuidStr string) (bool, error) {
+	var status struct {
+		Available bool `json:"available"`
+	}
+	err := c.Do(context.Background(), http.MethodGet, "/api/vms/"+escape(uuidStr)+"/guest-agent/status", nil, nil, &status)
+	return status.Available, err
+}
+
+// BulkVMAction runs an action on many VMs and waits for the vm.bulk-action job
+func (c *Client) BulkVMAction(ctx context.Context, req core.BulkVMActionRequest, progress func(percent float64, message string)) (core.BulkVMActionResult, error) {
+	var result core.BulkVMActionResult
+	err := c.runJob(ctx, http.MethodPost, "/api/vms/actions", req, &result, progress)
+	return result, err
+}
+
+// UpdateVMLabels changes a VM's labels and description
+func (c *Client) UpdateVMLabels(uuidStr string, req core.UpdateVMLabelsRequest) (core.VM_Detailed, error) {
+	var vm core.VM_Detailed
+	err := c.Do(context.Background(), http.MethodPatch, "/api/vms/"+escape(uuidStr)+"/labels", nil, req, &vm)
+	return vm, err
+}
+
+// UpdateVM reconfigures a VM in place
+func (c *Client) UpdateVM(uuidStr string, req core.UpdateVMRequest) (core.UpdateVMResult, error) {
+	var result core.UpdateVMResult
+	err := c.Do(context.Background(), http.MethodPatch, "/api/vms/"+escape(uuidStr), nil, req, &result)
+	return result, err
+}
+
+// GetVMSnapshots lists a VM's snapshots
+func (c *Client) GetVMSnapshots(uuidStr string) ([]core.Snapshot, error) {
+	var snapshots []core.Snapshot
+	err := c.Do(context.Background(), http.MethodGet, "/api/vms/"+escape(uuidStr)+"/snapshots", nil, nil, &snapshots)
+	return snapshots, err
+}
+
+// CreateVMSnapshot snapshots a VM and waits for the vm.snapshot job
+func (c *Client) CreateVMSnapshot(uuidStr string, cfg core.CreateSnapshotRequest) (core.Snapshot, error) {
+	var snapshot core.Snapshot
+	err := c.runJob(context.Background(), http.MethodPost, "/api/vms/"+escape(uuidStr)+"/snapshots", cfg, &snapshot, nil)
+	return snapshot, err
+}
+
+// DeleteVMSnapshot deletes a snapshot
+func (c *Client) DeleteVMSnapshot(uuidStr string, snapshotName string) error {
+	return c.Do(context.Background(), http.MethodDelete, "/api/vms/"+escape(uuidStr)+"/snapshots/"+escape(snapshotName), nil, nil, nil)
+}
+
+// RevertToVMSnapshot reverts a VM to a snapshot
+func (c *Client) RevertToVMSnapshot(uuidStr string, snapshotName string) error {
+	return c.Do(context.Background(), http.MethodPost, "/api/vms/"+escape(uuidStr)+"/snapshots/"+escape(snapshotName)+"/revert", nil, nil, nil)
+}
+
+// MigrateVM moves a VM to req.TargetServer, another server registered with
+// the Flint server
+func (c *Client) MigrateVM(ctx context.Context, uuidStr string, req core.MigrateVMRequest, progress func(percent float64, message string)) (core.MigrationResult, error) {
+	var result core.MigrationResult
+	err := c.runJob(ctx, http.MethodPost, "/api/vms/"+escape(uuidStr)+"/migrate", req, &result, progress)
+	return result, err
+}
+
+// ListBackups lists backups in the server's repository, oldest first, for the
+// VM with the given name or UUID, or for every VM if vm is empty. VMs that have
+// been deleted are still found.
+func (c *Client) ListBackups(vm string) ([]core.Backup, error) {
+	var backups []core.Backup
+	if err := c.Do(context.Background(), http.MethodGet, "/api/backups", nil, nil, &backups); err != nil {
+		return nil, err
+	}
+	if vm == "" {
+		return backups, nil
+	}
+
+	matching := []core.Backup{}
+	for _, b := range backups {
+		if b.VMUUID == vm || b.VMName == vm {
+			matching = append(matching, b)
+		}
+	}
+	return matching, nil
+}
+
+// BackupVM backs a VM up into the server's repository. req.Repository is
+// ignored; the server always uses its configured one.
+func (c *Client) BackupVM(ctx context.Context, uuidStr string, req core.CreateBackupRequest, progress func(percent float64, message string)) (core.Backup, error) {
+	req.Repository = ""
+	var b core.Backup
+	err := c.runJob(ctx, http.MethodPost, "/api/vms/"+escape(uuidStr)+"/backups", req, &b, progress)
+	return b, err
+}
+
+// RestoreBackup restores req.BackupID, or the latest backup, of the VM with
+// UUID req.VM from the server's repository
+func (c *Client) RestoreBackup(ctx context.Context, req core.RestoreBackupRequest, progress func(percent float64, message string)) (core.VM_Detailed, error) {
+	backupID := req.BackupID
+	if backupID == "" {
+		backupID = "latest"
+	}
+	req.Repository = ""
+
+	var vm core.VM_Detailed
+	err := c.runJob(ctx, http.MethodPost, "/api/vms/"+escape(req.VM)+"/backups/"+escape(backupID)+"/restore", req, &vm, progress)
+	return vm, err
+}
+
+// ExportVM writes a shut-off VM to an archive in the server's export
+// directory. req.Path is ignored; fetch the archive with DownloadExport.
+func (c *Client) ExportVM(ctx context.Context, uuidStr string, req core.ExportVMRequest, progress func(percent float64, message string)) (core.VMExport, error) {
+	req.Path = ""
+	var export core.VMExport
+	err := c.runJob(ctx, http.MethodPost, "/api/vms/"+escape(uuidStr)+"/export", req, &export, progress)
+	return export, err
+}
+
+// ImportVM defines a VM from req.File, a file in the server's export
+// directory. Use UploadExport to put a local archive there first.
+func (c *Client) ImportVM(ctx context.Context, req core.ImportVMRequest, progress func(percent float64, message string)) (core.VM_Detailed, error) {
+	var vm core.VM_Detailed
+	err := c.runJob(ctx, http.MethodPost, "/api/vms/import", req, &vm, progress)
+	return vm, err
+}
+
+// ListExports lists the files in the server's export directory
+func (c *Client) ListExports(ctx context.Context) ([]core.ExportFile, error) {
+	var files []core.ExportFile
+	err := c.Do(ctx, http.MethodGet, "/api/exports", nil, nil, &files)
+	return files, err
+}
+
+// DownloadExport copies a file from the server's export directory to w
+func (c *Client) DownloadExport(ctx context.Context, file string, w io.Writer) (int64, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/exports/"+escape(file), nil, nil, "")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// UploadExport stores r as file in the server's export directory, for
+// importing afterwards. Existing files are not replaced.
+func (c *Client) UploadExport(ctx context.Context, file string, r io.Reader) (core.ExportFile, error) {
+	var uploaded core.ExportFile
+	resp, err := c.send(ctx, http.MethodPut, "/api/exports/"+escape(file), nil, r, "application/octet-stream")
+	if err != nil {
+		return uploaded, err
+	}
+	defer resp.Body.Close()
+	err = decodeBody(resp, &uploaded)
+	return uploaded, err
+}
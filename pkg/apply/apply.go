@@ -0,0 +1,59 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// Apply brings the host to the spec. It plans again, runs the actions in
+// order and stops at the first one that fails; the returned plan holds the
+// outcome of each action. Applying a spec a second time does nothing.
+func Apply(ctx context.Context, client Client, spec core.Spec, progress func(percent float64, message string)) (core.Plan, error) {
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+	steps, warnings, err := plan(client, spec)
+	if err != nil {
+		return core.Plan{}, err
+	}
+
+	result := core.Plan{Actions: make([]core.PlanAction, 0, len(steps)), Warnings: warnings}
+	var failed error
+	applied := 0
+	for i, st := range steps {
+		action := st.action
+		if failed == nil {
+			failed = ctx.Err()
+		}
+		if failed != nil {
+			action.Status = core.PlanSkipped
+			result.Actions = append(result.Actions, action)
+			continue
+		}
+
+		progress(float64(i)*100/float64(len(steps)), fmt.Sprintf("%s %s %s", capitalize(action.Op), action.Kind, action.Name))
+		if err := st.run(); err != nil {
+			action.Status, action.Error = core.PlanFailed, err.Error()
+			failed = fmt.Errorf("failed to %s %s %s: %w", action.Op, action.Kind, action.Name, err)
+		} else {
+			action.Status = core.PlanApplied
+			applied++
+		}
+		result.Actions = append(result.Actions, action)
+	}
+
+	if failed != nil {
+		return result, fmt.Errorf("%w (%d of %d actions applied)", failed, applied, len(steps))
+	}
+	return result, nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
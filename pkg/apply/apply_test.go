@@ -0,0 +1,411 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// fakeClient keeps a host in memory
+type fakeClient struct {
+	pools    []core.StoragePool
+	volumes  map[string][]core.Volume
+	networks []core.Network
+	filters  map[string][]core.NWFilterRule
+	vms      map[string]*core.VM_Detailed
+	calls    []string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		pools:   []core.StoragePool{{Name: DefaultPool, State: "Active"}},
+		volumes: map[string][]core.Volume{},
+		filters: map[string][]core.NWFilterRule{},
+		vms:     map[string]*core.VM_Detailed{},
+	}
+}
+
+func (f *fakeClient) call(format string, args ...interface{}) {
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeClient) vm(uuid string) (*core.VM_Detailed, error) {
+	for _, vm := range f.vms {
+		if vm.UUID == uuid {
+			return vm, nil
+		}
+	}
+	return nil, fmt.Errorf("domain %s not found", uuid)
+}
+
+func (f *fakeClient) GetVMSummaries() ([]core.VM_Summary, error) {
+	var out []core.VM_Summary
+	for _, vm := range f.vms {
+		out = append(out, vm.VM_Summary)
+	}
+	return out, nil
+}
+
+func (f *fakeClient) GetVMDetails(uuid string) (core.VM_Detailed, error) {
+	vm, err := f.vm(uuid)
+	if err != nil {
+		return core.VM_Detailed{}, err
+	}
+	return *vm, nil
+}
+
+func (f *fakeClient) CreateVM(cfg core.VMCreationConfig) (core.VM_Detailed, error) {
+	f.call("create vm %s", cfg.Name)
+	vm := &core.VM_Detailed{
+		VM_Summary:  core.VM_Summary{Name: cfg.Name, UUID: "uuid-" + cfg.Name, State: "Shutoff", MemoryKB: cfg.MemoryMB * 1024, VCPUs: cfg.VCPUs},
+		MaxMemoryKB: cfg.MemoryMB * 1024,
+		Disks:       []core.Disk{{SourcePath: "/var/lib/flint/images/" + cfg.Name + "-disk-0.qcow2", TargetDev: "vda"}},
+	}
+	if cfg.NetworkName != "" {
+		vm.Nics = append(vm.Nics, core.NIC{Source: cfg.NetworkName, Model: "virtio"})
+	}
+	for _, nic := range cfg.NICs {
+		vm.Nics = append(vm.Nics, core.NIC{Source: nic.Source(), Model: nic.Model})
+	}
+	for i, disk := range cfg.Disks {
+		volume := core.Volume{Name: dataDiskVolume(cfg.Name, i), Path: "/pools/" + diskPool(disk) + "/" + dataDiskVolume(cfg.Name, i), Capacity: disk.SizeGB << 30}
+		f.volumes[diskPool(disk)] = append(f.volumes[diskPool(disk)], volume)
+		vm.Disks = append(vm.Disks, core.Disk{SourcePath: volume.Path, TargetDev: "vd" + string(rune('b'+i)), CapacityB: volume.Capacity})
+	}
+	f.vms[cfg.Name] = vm
+	return *vm, nil
+}
+
+func (f *fakeClient) UpdateVM(uuid string, req core.UpdateVMRequest) (core.UpdateVMResult, error) {
+	vm, err := f.vm(uuid)
+	if err != nil {
+		return core.UpdateVMResult{}, err
+	}
+	f.call("update vm %s memory=%d vcpus=%d", vm.Name, req.MemoryMB, req.VCPUs)
+	if req.MemoryMB > 0 {
+		vm.MemoryKB = req.MemoryMB * 1024
+		vm.MaxMemoryKB = max(vm.MaxMemoryKB, vm.MemoryKB)
+	}
+	if req.VCPUs > 0 {
+		vm.VCPUs = req.VCPUs
+	}
+	return core.UpdateVMResult{VM: *vm}, nil
+}
+
+func (f *fakeClient) PerformVMAction(uuid string, action string) error {
+	vm, err := f.vm(uuid)
+	if err != nil {
+		return err
+	}
+	f.call("%s vm %s", action, vm.Name)
+	vm.State = map[string]string{"start": "Running", "stop": "Shutoff"}[action]
+	return nil
+}
+
+func (f *fakeClient) DeleteVM(uuid string, deleteDisks bool) error {
+	vm, err := f.vm(uuid)
+	if err != nil {
+		return err
+	}
+	f.call("delete vm %s", vm.Name)
+	delete(f.vms, vm.Name)
+	return nil
+}
+
+func (f *fakeClient) AttachDiskToVM(uuid string, volumePath string, targetDev string) error {
+	vm, err := f.vm(uuid)
+	if err != nil {
+		return err
+	}
+	f.call("attach disk %s %s %s", vm.Name, path.Base(volumePath), targetDev)
+	disk := core.Disk{SourcePath: volumePath, TargetDev: targetDev}
+	for _, volumes := range f.volumes {
+		for _, v := range volumes {
+			if v.Path == volumePath {
+				disk.CapacityB = v.Capacity
+			}
+		}
+	}
+	vm.Disks = append(vm.Disks, disk)
+	return nil
+}
+
+func (f *fakeClient) AttachNetworkInterfaceToVM(uuid string, networkName string, model string) error {
+	vm, err := f.vm(uuid)
+	if err != nil {
+		return err
+	}
+	f.call("attach nic %s %s", vm.Name, networkName)
+	vm.Nics = append(vm.Nics, core.NIC{Source: networkName, Model: model})
+	return nil
+}
+
+func (f *fakeClient) GetStoragePools() ([]core.StoragePool, error) { return f.pools, nil }
+
+func (f *fakeClient) CreateStoragePool(cfg core.PoolConfig) error {
+	f.call("create pool %s", cfg.Name)
+	f.pools = append(f.pools, core.StoragePool{Name: cfg.Name, State: "Active"})
+	return nil
+}
+
+func (f *fakeClient) GetVolumes(poolName string) ([]core.Volume, error) {
+	return f.volumes[poolName], nil
+}
+
+func (f *fakeClient) CreateVolume(poolName string, cfg core.VolumeConfig) error {
+	f.call("create volume %s/%s", poolName, cfg.Name)
+	f.volumes[poolName] = append(f.volumes[poolName], core.Volume{Name: cfg.Name, Path: "/pools/" + poolName + "/" + cfg.Name, Capacity: cfg.SizeGB << 30})
+	return nil
+}
+
+func (f *fakeClient) UpdateVolume(poolName string, volumeName string, cfg core.VolumeConfig) error {
+	f.call("resize volume %s/%s %d", poolName, volumeName, cfg.SizeGB)
+	for _, vm := range f.vms {
+		for i, disk := range vm.Disks {
+			if path.Base(disk.SourcePath) == volumeName {
+				vm.Disks[i].CapacityB = cfg.SizeGB << 30
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeClient) GetNetworks() ([]core.Network, error) { return f.networks, nil }
+
+func (f *fakeClient) CreateNetwork(name string, bridgeName string) error {
+	f.call("create network %s", name)
+	f.networks = append(f.networks, core.Network{Name: name, Bridge: bridgeName, IsActive: true})
+	return nil
+}
+
+func (f *fakeClient) UpdateNetwork(name string, action string) error {
+	f.call("%s network %s", action, name)
+	for i := range f.networks {
+		if f.networks[i].Name == name {
+			f.networks[i].IsActive = action == "start"
+		}
+	}
+	return nil
+}
+
+func (f *fakeClient) DeleteNetwork(name string) error {
+	f.call("delete network %s", name)
+	for i := range f.networks {
+		if f.networks[i].Name == name {
+			f.networks = append(f.networks[:i], f.networks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("network %s not found", name)
+}
+
+// ListNWFilters renders rules the way libvirt returns a filter defined by CreateNWFilter
+func (f *fakeClient) ListNWFilters() ([]core.NWFilter, error) {
+	var out []core.NWFilter
+	for name, rules := range f.filters {
+		var b strings.Builder
+		fmt.Fprintf(&b, "<filter name='%s' chain='root'>\n  <uuid>1d6b1c3c</uuid>\n", name)
+		for _, r := range rules {
+			fmt.Fprintf(&b, "  <rule action='%s' direction='%s' priority='%d'>\n", r.Action, r.Direction, r.Priority)
+			fmt.Fprintf(&b, "    <%s dstportstart='%s'/>\n  </rule>\n", strings.ToLower(r.Protocol), r.DstPort)
+		}
+		b.WriteString("</filter>")
+		out = append(out, core.NWFilter{Name: name, XML: b.String()})
+	}
+	return out, nil
+}
+
+func (f *fakeClient) CreateNWFilter(req core.CreateNWFilterRequest) error {
+	f.call("create nwfilter %s", req.Name)
+	f.filters[req.Name] = req.Rules
+	return nil
+}
+
+func (f *fakeClient) UpdateNWFilter(name string, req core.CreateNWFilterRequest) error {
+	f.call("update nwfilter %s", name)
+	f.filters[name] = req.Rules
+	return nil
+}
+
+func (f *fakeClient) DeleteNWFilter(name string) error {
+	f.call("delete nwfilter %s", name)
+	delete(f.filters, name)
+	return nil
+}
+
+const testSpec = `
+pools:
+  - name: data
+    path: /srv/flint/data
+networks:
+  - name: lab
+    bridge: virbr-lab
+nwfilters:
+  - name: web
+    rules:
+      - {action: accept, direction: in, priority: 500, protocol: TCP, dstport: "443"}
+vms:
+  - name: web-01
+    memoryMB: 2048
+    vcpus: 2
+    imageName: noble-server-cloudimg-amd64.img
+    diskSizeGB: 20
+    networkName: lab
+    nics:
+      - network: default
+        model: e1000e
+    disks:
+      - pool: data
+        sizeGB: 10
+        bus: scsi
+    machineType: q35
+    cloudInit:
+      commonFields:
+        hostname: web-01
+`
+
+func TestParse(t *testing.T) {
+	spec, err := Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	vm := spec.VMs[0]
+	if vm.MemoryMB != 2048 || vm.VCPUs != 2 || vm.ImageName != "noble-server-cloudimg-amd64.img" || vm.NetworkName != "lab" {
+		t.Errorf("VM fields not decoded: %+v", vm.VMCreationConfig)
+	}
+	if vm.CloudInit == nil || vm.CloudInit.CommonFields.Hostname != "web-01" || len(vm.Disks) != 1 || vm.Disks[0].Bus != "scsi" || vm.NICs[0].Model != "e1000e" {
+		t.Errorf("nested fields not decoded: %+v", vm)
+	}
+	if spec.Pools[0].Path != "/srv/flint/data" || spec.NWFilters[0].Rules[0].DstPort != "443" {
+		t.Errorf("pool or filter not decoded: %+v", spec)
+	}
+
+	json := `{"vms": [{"name": "db-01", "memoryMB": 1024, "vcpus": 1, "state": "stopped"}]}`
+	if spec, err := Parse([]byte(json)); err != nil || spec.VMs[0].State != core.VMStateStopped {
+		t.Errorf("Parse(JSON) = %+v, %v", spec, err)
+	}
+
+	for name, bad := range map[string]string{
+		"unknown field":  "vms:\n  - name: a\n    memoryMB: 512\n    vcpus: 1\n    cpus: 2\n",
+		"duplicate":      "networks:\n  - name: lab\n  - name: lab\n",
+		"missing memory": "vms:\n  - name: a\n    vcpus: 1\n",
+		"bad state":      "vms:\n  - name: a\n    memoryMB: 512\n    vcpus: 1\n    state: paused\n",
+		"bad ensure":     "nwfilters:\n  - name: web\n    ensure: gone\n",
+		"empty":          "",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%s: Parse succeeded", name)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	client := newFakeClient()
+	client.networks = []core.Network{{Name: "default", Bridge: "virbr0", IsActive: true}}
+	spec, err := Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	plan, err := Plan(client, spec)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	var got []string
+	for _, action := range plan.Actions {
+		got = append(got, action.Op+" "+action.Kind+" "+action.Name)
+	}
+	want := "create pool data, create network lab, create nwfilter web, create vm web-01"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("plan = %s, want %s", strings.Join(got, ", "), want)
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("Plan changed the host: %v", client.calls)
+	}
+
+	result, err := Apply(context.Background(), client, spec, nil)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for _, action := range result.Actions {
+		if action.Status != core.PlanApplied {
+			t.Errorf("%s %s: status %s", action.Kind, action.Name, action.Status)
+		}
+	}
+	calls := strings.Join(client.calls, ", ")
+	want = "create pool data, create network lab, create nwfilter web, create vm web-01, start vm web-01"
+	if calls != want {
+		t.Errorf("calls = %s\nwant    %s", calls, want)
+	}
+
+	// Applying again changes nothing
+	if plan, err := Plan(client, spec); err != nil || len(plan.Actions) != 0 || len(plan.Warnings) != 0 {
+		t.Fatalf("second plan = %+v, %v", plan, err)
+	}
+
+	// Grow the VM, add a disk, remove the network and filter, and stop the VM
+	spec.VMs[0].MemoryMB = 4096
+	spec.VMs[0].Disks[0].SizeGB = 20
+	spec.VMs[0].Disks = append(spec.VMs[0].Disks, core.VMDiskConfig{SizeGB: 5})
+	spec.VMs[0].NICs = nil
+	spec.VMs[0].State = core.VMStateStopped
+	spec.Networks[0].Ensure = core.EnsureAbsent
+	spec.NWFilters[0].Ensure = core.EnsureAbsent
+	client.calls = nil
+
+	plan, err = Plan(client, spec)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	got = nil
+	for _, action := range plan.Actions {
+		got = append(got, action.Op+" "+action.Kind+" "+action.Name)
+	}
+	if want := "update vm web-01, delete nwfilter web, delete network lab"; strings.Join(got, ", ") != want {
+		t.Errorf("plan = %s, want %s", strings.Join(got, ", "), want)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "NIC on default") {
+		t.Errorf("warnings = %v, want the NIC on default", plan.Warnings)
+	}
+
+	if _, err := Apply(context.Background(), client, spec, nil); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	want = "update vm web-01 memory=4096 vcpus=0, resize volume data/web-01-disk-1.qcow2 20, create volume flint-image-library/web-01-disk-2.qcow2, " +
+		"attach disk web-01 web-01-disk-2.qcow2 vdc, stop vm web-01, delete nwfilter web, delete network lab"
+	if calls := strings.Join(client.calls, ", "); calls != want {
+		t.Errorf("calls = %s\nwant    %s", calls, want)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	client := newFakeClient()
+	spec := core.Spec{
+		Networks: []core.NetworkSpec{{Name: "gone", Ensure: core.EnsureAbsent}},
+		VMs: []core.VMSpec{{
+			VMCreationConfig: core.VMCreationConfig{Name: "web-01", MemoryMB: 1024, VCPUs: 1, NICs: []core.VMNICConfig{{Network: "lab"}}},
+		}},
+	}
+
+	// A network that does not exist yet cannot be created without a bridge
+	spec.Networks = append(spec.Networks, core.NetworkSpec{Name: "lab"})
+	if _, err := Plan(client, spec); err == nil || !strings.Contains(err.Error(), "bridge") {
+		t.Errorf("Plan without a bridge = %v, want an error", err)
+	}
+	spec.Networks = spec.Networks[:1]
+	client.networks = []core.Network{{Name: "gone"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := Apply(ctx, client, spec, nil)
+	if err == nil || len(result.Actions) != 2 || result.Actions[0].Status != core.PlanSkipped {
+		t.Errorf("Apply after cancel = %+v, %v", result, err)
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("cancelled apply changed the host: %v", client.calls)
+	}
+}
@@ -0,0 +1,535 @@
+package apply
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// Client is a connection the spec is applied to (libvirtclient.ClientInterface)
+type Client interface {
+	GetVMSummaries() ([]core.VM_Summary, error)
+	GetVMDetails(uuid string) (core.VM_Detailed, error)
+	CreateVM(cfg core.VMCreationConfig) (core.VM_Detailed, error)
+	UpdateVM(uuid string, req core.UpdateVMRequest) (core.UpdateVMResult, error)
+	PerformVMAction(uuid string, action string) error
+	DeleteVM(uuid string, deleteDisks bool) error
+	AttachDiskToVM(uuid string, volumePath string, targetDev string) error
+	AttachNetworkInterfaceToVM(uuid string, networkName string, model string) error
+	GetStoragePools() ([]core.StoragePool, error)
+	CreateStoragePool(cfg core.PoolConfig) error
+	GetVolumes(poolName string) ([]core.Volume, error)
+	CreateVolume(poolName string, volConfig core.VolumeConfig) error
+	UpdateVolume(poolName string, volumeName string, config core.VolumeConfig) error
+	GetNetworks() ([]core.Network, error)
+	CreateNetwork(name string, bridgeName string) error
+	UpdateNetwork(name string, action string) error
+	DeleteNetwork(name string) error
+	ListNWFilters() ([]core.NWFilter, error)
+	CreateNWFilter(req core.CreateNWFilterRequest) error
+	UpdateNWFilter(name string, req core.CreateNWFilterRequest) error
+	DeleteNWFilter(name string) error
+}
+
+// step is a plan action with the calls that carry it out
+type step struct {
+	action core.PlanAction
+	run    func() error
+}
+
+// planner collects the steps for one spec
+type planner struct {
+	client   Client
+	changes  []step // Creates and updates: pools, networks, filters, then VMs
+	deletes  []step // VMs first, so networks and filters are no longer in use
+	warnings []string
+}
+
+// Plan compares a spec with the host and returns what Apply would do
+func Plan(client Client, spec core.Spec) (core.Plan, error) {
+	steps, warnings, err := plan(client, spec)
+	if err != nil {
+		return core.Plan{}, err
+	}
+	result := core.Plan{Actions: make([]core.PlanAction, 0, len(steps)), Warnings: warnings}
+	for _, st := range steps {
+		result.Actions = append(result.Actions, st.action)
+	}
+	return result, nil
+}
+
+func plan(client Client, spec core.Spec) ([]step, []string, error) {
+	if err := Validate(spec); err != nil {
+		return nil, nil, err
+	}
+
+	p := &planner{client: client}
+	if err := p.planPools(spec.Pools); err != nil {
+		return nil, nil, err
+	}
+	if err := p.planNetworks(spec.Networks); err != nil {
+		return nil, nil, err
+	}
+	if err := p.planNWFilters(spec.NWFilters); err != nil {
+		return nil, nil, err
+	}
+	if err := p.planVMs(spec.VMs); err != nil {
+		return nil, nil, err
+	}
+
+	// Deletes were collected networks first; reverse them so VMs go before what they use
+	slices.Reverse(p.deletes)
+	return append(p.changes, p.deletes...), p.warnings, nil
+}
+
+func (p *planner) change(action core.PlanAction, run func() error) {
+	p.changes = append(p.changes, step{action: action, run: run})
+}
+
+func (p *planner) delete(kind, name string, run func() error) {
+	p.deletes = append(p.deletes, step{action: core.PlanAction{Kind: kind, Name: name, Op: core.PlanDelete}, run: run})
+}
+
+func (p *planner) warn(format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+}
+
+func (p *planner) planPools(specs []core.PoolSpec) error {
+	pools, err := p.client.GetStoragePools()
+	if err != nil {
+		return fmt.Errorf("failed to list storage pools: %w", err)
+	}
+	for _, spec := range specs {
+		if slices.ContainsFunc(pools, func(pool core.StoragePool) bool { return pool.Name == spec.Name }) {
+			continue
+		}
+		cfg := spec.PoolConfig
+		if cfg.Type == "" {
+			cfg.Type = "dir"
+		}
+		p.change(core.PlanAction{Kind: "pool", Name: spec.Name, Op: core.PlanCreate, Changes: []core.PlanChange{
+			{Field: "type", To: cfg.Type},
+			{Field: "path", To: cfg.Path},
+		}}, func() error {
+			return p.client.CreateStoragePool(cfg)
+		})
+	}
+	return nil
+}
+
+func (p *planner) planNetworks(specs []core.NetworkSpec) error {
+	networks, err := p.client.GetNetworks()
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %w", err)
+	}
+	existing := map[string]core.Network{}
+	for _, network := range networks {
+		existing[network.Name] = network
+	}
+
+	for _, spec := range specs {
+		name := spec.Name
+		network, exists := existing[name]
+		switch {
+		case spec.Ensure == core.EnsureAbsent:
+			if exists {
+				p.delete("network", name, func() error { return p.client.DeleteNetwork(name) })
+			}
+		case !exists:
+			if spec.Bridge == "" {
+				return fmt.Errorf("network %s does not exist; a bridge is needed to create it", name)
+			}
+			bridge := spec.Bridge
+			p.change(core.PlanAction{Kind: "network", Name: name, Op: core.PlanCreate, Changes: []core.PlanChange{
+				{Field: "bridge", To: bridge},
+			}}, func() error {
+				return p.client.CreateNetwork(name, bridge)
+			})
+		default:
+			if spec.Bridge != "" && spec.Bridge != network.Bridge {
+				p.warn("network %s uses bridge %s, not %s; remove and recreate it to change the bridge", name, network.Bridge, spec.Bridge)
+			}
+			if !network.IsActive {
+				p.change(core.PlanAction{Kind: "network", Name: name, Op: core.PlanUpdate, Changes: []core.PlanChange{
+					{Field: "active", From: "false", To: "true"},
+				}}, func() error {
+					return p.client.UpdateNetwork(name, "start")
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func (p *planner) planNWFilters(specs []core.NWFilterSpec) error {
+	filters, err := p.client.ListNWFilters()
+	if err != nil {
+		return fmt.Errorf("failed to list network filters: %w", err)
+	}
+	existing := map[string]core.NWFilter{}
+	for _, filter := range filters {
+		existing[filter.Name] = filter
+	}
+
+	for _, spec := range specs {
+		name := spec.Name
+		filter, exists := existing[name]
+		req := core.CreateNWFilterRequest{Name: name, Rules: spec.Rules}
+		switch {
+		case spec.Ensure == core.EnsureAbsent:
+			if exists {
+				p.delete("nwfilter", name, func() error { return p.client.DeleteNWFilter(name) })
+			}
+		case !exists:
+			p.change(core.PlanAction{Kind: "nwfilter", Name: name, Op: core.PlanCreate, Changes: []core.PlanChange{
+				{Field: "rules", To: countOf(len(spec.Rules), "rule")},
+			}}, func() error {
+				return p.client.CreateNWFilter(req)
+			})
+		default:
+			rules, err := parseNWFilterRules(filter.XML)
+			if err != nil {
+				return fmt.Errorf("network filter %s: %w", name, err)
+			}
+			if sameRules(rules, spec.Rules) {
+				continue
+			}
+			p.change(core.PlanAction{Kind: "nwfilter", Name: name, Op: core.PlanUpdate, Changes: []core.PlanChange{
+				{Field: "rules", From: countOf(len(rules), "rule"), To: countOf(len(spec.Rules), "rule")},
+			}}, func() error {
+				return p.client.UpdateNWFilter(name, req)
+			})
+		}
+	}
+	return nil
+}
+
+func (p *planner) planVMs(specs []core.VMSpec) error {
+	summaries, err := p.client.GetVMSummaries()
+	if err != nil {
+		return fmt.Errorf("failed to list VMs: %w", err)
+	}
+	existing := map[string]core.VM_Summary{}
+	for _, summary := range summaries {
+		existing[summary.Name] = summary
+	}
+
+	for _, spec := range specs {
+		summary, exists := existing[spec.Name]
+		switch {
+		case spec.Ensure == core.EnsureAbsent:
+			if exists {
+				uuid, deleteDisks := summary.UUID, spec.DeleteDisks
+				p.delete("vm", spec.Name, func() error { return p.client.DeleteVM(uuid, deleteDisks) })
+			}
+		case !exists:
+			p.planVMCreate(spec)
+		default:
+			details, err := p.client.GetVMDetails(summary.UUID)
+			if err != nil {
+				return fmt.Errorf("failed to get VM %s: %w", spec.Name, err)
+			}
+			p.planVMUpdate(spec, details)
+		}
+	}
+	return nil
+}
+
+func (p *planner) planVMCreate(spec core.VMSpec) {
+	cfg := spec.VMCreationConfig
+	cfg.StartOnCreate = false // Started separately so a failure to start is reported
+	cfg.EnableCloudInit = cfg.CloudInit != nil
+	if cfg.ImageType == "" && cfg.ImageName != "" {
+		cfg.ImageType = "template"
+		if strings.HasSuffix(strings.ToLower(cfg.ImageName), ".iso") {
+			cfg.ImageType = "iso"
+		}
+	}
+
+	changes := []core.PlanChange{
+		{Field: "memoryMB", To: fmt.Sprint(cfg.MemoryMB)},
+		{Field: "vcpus", To: fmt.Sprint(cfg.VCPUs)},
+	}
+	if cfg.ImageName != "" {
+		changes = append(changes, core.PlanChange{Field: "image", To: cfg.ImageName})
+	}
+	for _, nic := range vmNICs(spec) {
+		changes = append(changes, core.PlanChange{Field: "nic", To: nic.Source()})
+	}
+	for i, disk := range spec.Disks {
+		changes = append(changes, core.PlanChange{Field: dataDiskField(i), To: fmt.Sprintf("%d GB", disk.SizeGB)})
+	}
+	changes = append(changes, core.PlanChange{Field: "state", To: vmState(spec)})
+
+	p.change(core.PlanAction{Kind: "vm", Name: spec.Name, Op: core.PlanCreate, Changes: changes}, func() error {
+		vm, err := p.client.CreateVM(cfg)
+		if err != nil {
+			return err
+		}
+		if vmState(spec) == core.VMStateRunning {
+			return p.client.PerformVMAction(vm.UUID, "start")
+		}
+		return nil
+	})
+}
+
+func (p *planner) planVMUpdate(spec core.VMSpec, vm core.VM_Detailed) {
+	var changes []core.PlanChange
+	var ops []func() error
+	running := vm.State == "Running"
+
+	// UpdateVM only ever raises the maximum memory, so compare the current amount
+	memoryKB := vm.MemoryKB
+	if memoryKB == 0 {
+		memoryKB = vm.MaxMemoryKB
+	}
+	var resize core.UpdateVMRequest
+	if memoryKB/1024 != spec.MemoryMB {
+		changes = append(changes, core.PlanChange{Field: "memoryMB", From: fmt.Sprint(memoryKB / 1024), To: fmt.Sprint(spec.MemoryMB)})
+		resize.MemoryMB = spec.MemoryMB
+	}
+	if vm.VCPUs != spec.VCPUs {
+		changes = append(changes, core.PlanChange{Field: "vcpus", From: fmt.Sprint(vm.VCPUs), To: fmt.Sprint(spec.VCPUs)})
+		resize.VCPUs = spec.VCPUs
+	}
+	if !resize.IsEmpty() {
+		if running && vmState(spec) == core.VMStateRunning {
+			p.warn("vm %s: memory and vCPU changes that cannot be made live take effect when it is restarted", spec.Name)
+		}
+		ops = append(ops, func() error {
+			_, err := p.client.UpdateVM(vm.UUID, resize)
+			return err
+		})
+	}
+
+	// NICs are matched by network or bridge, so listing one twice asks for two
+	// NICs on it. NICs added to an existing VM only get their source and model.
+	unmatched := make([]string, 0, len(vm.Nics))
+	for _, nic := range vm.Nics {
+		unmatched = append(unmatched, nic.Source)
+	}
+	for _, nic := range vmNICs(spec) {
+		source := nic.Source()
+		if i := slices.Index(unmatched, source); i >= 0 {
+			unmatched = slices.Delete(unmatched, i, i+1)
+			continue
+		}
+		model := nic.Model
+		if model == "" {
+			model = "virtio"
+		}
+		if nic.MAC != "" || nic.NWFilter != "" {
+			p.warn("vm %s: the MAC and network filter of the new NIC on %s are only set when a VM is created", spec.Name, source)
+		}
+		changes = append(changes, core.PlanChange{Field: "nic", To: source})
+		ops = append(ops, func() error { return p.client.AttachNetworkInterfaceToVM(vm.UUID, source, model) })
+	}
+	for _, source := range unmatched {
+		p.warn("vm %s: NIC on %s is not in the spec", spec.Name, source)
+	}
+
+	// Data disks are matched by volume name; missing ones are attached on
+	// the first free virtio device
+	usedDevs := make(map[string]bool)
+	for _, d := range vm.Disks {
+		usedDevs[d.TargetDev] = true
+	}
+	for i, disk := range spec.Disks {
+		field := dataDiskField(i)
+		volume := dataDiskVolume(spec.Name, i)
+		idx := slices.IndexFunc(vm.Disks, func(d core.Disk) bool { return filepath.Base(d.SourcePath) == volume })
+		if idx < 0 {
+			dev := nextVirtioDev(usedDevs)
+			if dev == "" {
+				p.warn("vm %s: no free virtio device for %s", spec.Name, field)
+				continue
+			}
+			usedDevs[dev] = true
+			if disk.Bus != "" && disk.Bus != "virtio" {
+				p.warn("vm %s: %s is attached to an existing VM on virtio, not %s", spec.Name, field, disk.Bus)
+			}
+			changes = append(changes, core.PlanChange{Field: field, To: fmt.Sprintf("%d GB", disk.SizeGB)})
+			ops = append(ops, func() error { return p.addDataDisk(vm.UUID, spec.Name, i, disk, dev) })
+			continue
+		}
+		switch sizeB := disk.SizeGB << 30; {
+		case vm.Disks[idx].CapacityB < sizeB:
+			changes = append(changes, core.PlanChange{Field: field, From: fmt.Sprintf("%d GB", vm.Disks[idx].CapacityB>>30), To: fmt.Sprintf("%d GB", disk.SizeGB)})
+			ops = append(ops, func() error {
+				return p.client.UpdateVolume(diskPool(disk), volume, core.VolumeConfig{Name: volume, SizeGB: disk.SizeGB})
+			})
+		case vm.Disks[idx].CapacityB > sizeB:
+			p.warn("vm %s: %s is larger than %d GB; disks are never shrunk", spec.Name, field, disk.SizeGB)
+		}
+	}
+
+	switch want := vmState(spec); {
+	case want == core.VMStateRunning && !running:
+		changes = append(changes, core.PlanChange{Field: "state", From: strings.ToLower(vm.State), To: want})
+		ops = append(ops, func() error { return p.client.PerformVMAction(vm.UUID, "start") })
+	case want == core.VMStateStopped && running:
+		changes = append(changes, core.PlanChange{Field: "state", From: strings.ToLower(vm.State), To: want})
+		ops = append(ops, func() error { return p.client.PerformVMAction(vm.UUID, "stop") })
+	}
+
+	if len(changes) == 0 {
+		return
+	}
+	p.change(core.PlanAction{Kind: "vm", Name: spec.Name, Op: core.PlanUpdate, Changes: changes}, func() error {
+		for _, op := range ops {
+			if err := op(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addDataDisk creates data disk i of a VM, unless a previous apply already
+// did, and attaches it as dev
+func (p *planner) addDataDisk(uuid, vmName string, i int, disk core.VMDiskConfig, dev string) error {
+	pool, volume := diskPool(disk), dataDiskVolume(vmName, i)
+	path, err := p.volumePath(pool, volume)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		if err := p.client.CreateVolume(pool, core.VolumeConfig{Name: volume, SizeGB: disk.SizeGB}); err != nil {
+			return fmt.Errorf("failed to create volume %s: %w", volume, err)
+		}
+		if path, err = p.volumePath(pool, volume); err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("volume %s not found in pool %s after creating it", volume, pool)
+		}
+	}
+	return p.client.AttachDiskToVM(uuid, path, dev)
+}
+
+// volumePath returns the path of a volume, or "" if the pool does not have it
+func (p *planner) volumePath(pool, volume string) (string, error) {
+	volumes, err := p.client.GetVolumes(pool)
+	if err != nil {
+		return "", fmt.Errorf("failed to list volumes in pool %s: %w", pool, err)
+	}
+	for _, v := range volumes {
+		if v.Name == volume {
+			return v.Path, nil
+		}
+	}
+	return "", nil
+}
+
+// vmNICs returns the NICs of a VM in order, starting with the one on NetworkName
+func vmNICs(spec core.VMSpec) []core.VMNICConfig {
+	var nics []core.VMNICConfig
+	if spec.NetworkName != "" {
+		nics = append(nics, core.VMNICConfig{Network: spec.NetworkName})
+	}
+	return append(nics, spec.NICs...)
+}
+
+func vmState(spec core.VMSpec) string {
+	if spec.State == "" {
+		return core.VMStateRunning
+	}
+	return spec.State
+}
+
+func diskPool(disk core.VMDiskConfig) string {
+	if disk.Pool == "" {
+		return DefaultPool
+	}
+	return disk.Pool
+}
+
+// dataDiskVolume continues the numbering of the OS disk, <vm>-disk-0.qcow2
+func dataDiskVolume(vmName string, i int) string {
+	return fmt.Sprintf("%s-disk-%d.qcow2", vmName, i+1)
+}
+
+func dataDiskField(i int) string {
+	return fmt.Sprintf("disk %d", i+1)
+}
+
+// nextVirtioDev returns the first free device from vdb to vdz, or ""
+func nextVirtioDev(used map[string]bool) string {
+	for c := 'b'; c <= 'z'; c++ {
+		if dev := "vd" + string(c); !used[dev] {
+			return dev
+		}
+	}
+	return ""
+}
+
+func countOf(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// parseNWFilterRules reads back the rules of a filter defined by
+// CreateNWFilter or UpdateNWFilter
+func parseNWFilterRules(filterXML string) ([]core.NWFilterRule, error) {
+	var fx struct {
+		Rules []struct {
+			Action    string `xml:"action,attr"`
+			Direction string `xml:"direction,attr"`
+			Priority  int    `xml:"priority,attr"`
+			Match     []struct {
+				XMLName xml.Name
+				Attrs   []xml.Attr `xml:",any,attr"`
+			} `xml:",any"`
+		} `xml:"rule"`
+	}
+	if err := xml.Unmarshal([]byte(filterXML), &fx); err != nil {
+		return nil, fmt.Errorf("failed to parse filter XML: %w", err)
+	}
+
+	rules := make([]core.NWFilterRule, 0, len(fx.Rules))
+	for _, r := range fx.Rules {
+		rule := core.NWFilterRule{Action: r.Action, Direction: r.Direction, Priority: r.Priority}
+		if len(r.Match) > 0 {
+			rule.Protocol = r.Match[0].XMLName.Local
+			for _, attr := range r.Match[0].Attrs {
+				switch attr.Name.Local {
+				case "srcipaddr":
+					rule.SrcIP = attr.Value
+				case "dstipaddr":
+					rule.DstIP = attr.Value
+				case "srcportstart":
+					rule.SrcPort = attr.Value
+				case "dstportstart":
+					rule.DstPort = attr.Value
+				}
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// sameRules compares rules the way CreateNWFilter writes them
+func sameRules(have, want []core.NWFilterRule) bool {
+	return slices.EqualFunc(have, want, func(a, b core.NWFilterRule) bool {
+		return normalizeRule(a) == normalizeRule(b)
+	})
+}
+
+func normalizeRule(rule core.NWFilterRule) core.NWFilterRule {
+	rule.Protocol = strings.ToLower(rule.Protocol)
+	switch rule.Protocol {
+	case "tcp", "udp":
+	case "icmp", "all":
+		rule.SrcPort, rule.DstPort = "", ""
+	default:
+		rule.Protocol, rule.SrcPort, rule.DstPort = "ip", "", ""
+	}
+	return rule
+}
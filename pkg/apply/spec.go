@@ -0,0 +1,108 @@
+// Package apply reconciles a host with a declarative spec of storage pools,
+// networks, network filters and VMs.
+package apply
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/volantvm/flint/pkg/core"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPool holds data disks that do not name a pool
+const DefaultPool = "flint-image-library"
+
+// Parse reads a spec from YAML or JSON and validates it. Unknown fields are
+// rejected so that typos do not silently drop settings.
+func Parse(data []byte) (core.Spec, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return core.Spec{}, fmt.Errorf("invalid spec: %w", err)
+	}
+	if doc == nil {
+		return core.Spec{}, errors.New("spec is empty")
+	}
+
+	// Go through JSON so the spec uses the API's field names
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return core.Spec{}, fmt.Errorf("invalid spec: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var spec core.Spec
+	if err := dec.Decode(&spec); err != nil {
+		return core.Spec{}, fmt.Errorf("invalid spec: %w", err)
+	}
+	return spec, Validate(spec)
+}
+
+// Validate checks that resources are named once and have what they need
+func Validate(spec core.Spec) error {
+	var problems []string
+	seen := map[string]bool{}
+	check := func(kind, name, ensure string) {
+		switch {
+		case name == "":
+			problems = append(problems, kind+" without a name")
+		case seen[kind+"/"+name]:
+			problems = append(problems, fmt.Sprintf("%s %s is listed twice", kind, name))
+		}
+		seen[kind+"/"+name] = true
+		if ensure != "" && ensure != core.EnsurePresent && ensure != core.EnsureAbsent {
+			problems = append(problems, fmt.Sprintf("%s %s: ensure must be present or absent", kind, name))
+		}
+	}
+
+	for _, pool := range spec.Pools {
+		check("pool", pool.Name, "")
+		if pool.Path == "" {
+			problems = append(problems, fmt.Sprintf("pool %s: path is required", pool.Name))
+		}
+	}
+	for _, network := range spec.Networks {
+		check("network", network.Name, network.Ensure)
+	}
+	for _, filter := range spec.NWFilters {
+		check("nwfilter", filter.Name, filter.Ensure)
+		for i, rule := range filter.Rules {
+			if rule.Action != "accept" && rule.Action != "drop" {
+				problems = append(problems, fmt.Sprintf("nwfilter %s: rule %d: action must be accept or drop", filter.Name, i+1))
+			}
+			if rule.Direction != "in" && rule.Direction != "out" && rule.Direction != "inout" {
+				problems = append(problems, fmt.Sprintf("nwfilter %s: rule %d: direction must be in, out or inout", filter.Name, i+1))
+			}
+		}
+	}
+	for _, vm := range spec.VMs {
+		check("vm", vm.Name, vm.Ensure)
+		if vm.Ensure == core.EnsureAbsent {
+			continue
+		}
+		if vm.MemoryMB == 0 || vm.VCPUs <= 0 {
+			problems = append(problems, fmt.Sprintf("vm %s: memoryMB and vcpus are required", vm.Name))
+		}
+		if vm.State != "" && vm.State != core.VMStateRunning && vm.State != core.VMStateStopped {
+			problems = append(problems, fmt.Sprintf("vm %s: state must be running or stopped", vm.Name))
+		}
+		for i, disk := range vm.Disks {
+			if disk.SizeGB == 0 {
+				problems = append(problems, fmt.Sprintf("vm %s: disk %d: sizeGB is required", vm.Name, i+1))
+			}
+		}
+		for i, nic := range vm.NICs {
+			if nic.Source() == "" {
+				problems = append(problems, fmt.Sprintf("vm %s: nic %d: network or bridge is required", vm.Name, i+1))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid spec: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,535 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// TokenPrefix starts every token secret so it is easy to tell apart from the API key
+const TokenPrefix = "flt_"
+
+// lastUsedPersistInterval limits how often token usage alone rewrites the store
+const lastUsedPersistInterval = time.Minute
+
+var (
+	// ErrInvalidToken is returned for unknown or revoked token secrets
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned for tokens past their expiry
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenOutOfScope is returned for tokens whose scope their user no longer has
+	ErrTokenOutOfScope = errors.New("token scope no longer granted to its user")
+	// ErrUserNotFound is returned for unknown user names
+	ErrUserNotFound = errors.New("user not found")
+	// ErrTokenNotFound is returned for unknown token IDs
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// reservedNames are actors the audit log already uses for other kinds of access
+var reservedNames = map[string]bool{"api-key": true, "cli": true, "session": true, "system": true}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Identity is who an authenticated token acts as, with its effective role and scope
+type Identity struct {
+	User    string
+	TokenID string
+	Role    core.Role
+	Scope   core.AccessScope
+}
+
+// storedToken is a token as persisted: its metadata and the SHA-256 of its secret
+type storedToken struct {
+	core.APIToken
+	Hash string `json:"hash"`
+}
+
+// Store persists users and API tokens. Token secrets are only kept as hashes.
+// The file is shared with the CLI, so the store reloads it whenever it changes
+// on disk.
+type Store struct {
+	mu          sync.Mutex
+	users       map[string]*core.User
+	tokens      map[string]*storedToken // by ID
+	byHash      map[string]*storedToken
+	storagePath string
+	modTime     time.Time
+}
+
+// NewStore opens the user and token store (~/.flint/auth.json by default)
+func NewStore(storagePath string) (*Store, error) {
+	if storagePath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		storagePath = filepath.Join(homeDir, ".flint", "auth.json")
+	}
+
+	store := &Store{
+		users:       make(map[string]*core.User),
+		tokens:      make(map[string]*storedToken),
+		byHash:      make(map[string]*storedToken),
+		storagePath: storagePath,
+	}
+
+	if err := store.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load auth store: %w", err)
+	}
+
+	return store, nil
+}
+
+// AddUser creates a named user
+func (s *Store) AddUser(req core.CreateUserRequest) (core.User, error) {
+	if err := validateUserName(req.Name); err != nil {
+		return core.User{}, err
+	}
+	if !req.Role.Valid() {
+		return core.User{}, fmt.Errorf("invalid role %q: must be viewer, operator or admin", req.Role)
+	}
+	if _, err := req.Scope.VMSelector(); err != nil {
+		return core.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+
+	if _, exists := s.users[req.Name]; exists {
+		return core.User{}, fmt.Errorf("user already exists: %s", req.Name)
+	}
+
+	user := &core.User{
+		Name:      req.Name,
+		Role:      req.Role,
+		Scope:     req.Scope,
+		CreatedAt: time.Now(),
+	}
+	s.users[user.Name] = user
+
+	if err := s.save(); err != nil {
+		delete(s.users, user.Name)
+		return core.User{}, err
+	}
+	return *user, nil
+}
+
+// GetUser returns a user by name
+func (s *Store) GetUser(name string) (core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+
+	user, exists := s.users[name]
+	if !exists {
+		return core.User{}, fmt.Errorf("%w: %s", ErrUserNotFound, name)
+	}
+	return *user, nil
+}
+
+// ListUsers returns all users sorted by name
+func (s *Store) ListUsers() []core.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+
+	users := make([]core.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, *user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users
+}
+
+// DeleteUser removes a user and revokes all of its tokens
+func (s *Store) DeleteUser(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+
+	if _, exists := s.users[name]; !exists {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, name)
+	}
+
+	delete(s.users, name)
+	for id, token := range s.tokens {
+		if token.User == name {
+			delete(s.tokens, id)
+			delete(s.byHash, token.Hash)
+		}
+	}
+
+	return s.save()
+}
+
+// CreateToken issues a token for an existing user and returns it with its secret.
+// The token's role and scope may narrow the user's but never widen them.
+func (s *Store) CreateToken(req core.CreateTokenRequest) (core.APIToken, string, error) {
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			return core.APIToken{}, "", fmt.Errorf("invalid expiresIn %q: expected a positive duration such as 720h", req.ExpiresIn)
+		}
+		t := time.Now().Add(d)
+		expiresAt = &t
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+
+	user, exists := s.users[req.User]
+	if !exists {
+		return core.APIToken{}, "", fmt.Errorf("%w: %s", ErrUserNotFound, req.User)
+	}
+
+	role := req.Role
+	if role == "" {
+		role = user.Role
+	}
+	if !role.Valid() {
+		return core.APIToken{}, "", fmt.Errorf("invalid role %q: must be viewer, operator or admin", role)
+	}
+	if !user.Role.Allows(role) {
+		return core.APIToken{}, "", fmt.Errorf("role %s exceeds the %s role of user %s", role, user.Role, user.Name)
+	}
+
+	if _, err := req.Scope.VMSelector(); err != nil {
+		return core.APIToken{}, "", err
+	}
+	scope := req.Scope
+	if !scope.LimitsServers() {
+		scope.Servers, scope.Tags = user.Scope.Servers, user.Scope.Tags
+	}
+	scope.VMLabels = mergeVMLabels(user.Scope.VMLabels, scope.VMLabels)
+	if err := checkWithin(scope, user.Scope); err != nil {
+		return core.APIToken{}, "", fmt.Errorf("scope exceeds that of user %s: %w", user.Name, err)
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return core.APIToken{}, "", err
+	}
+
+	token := &storedToken{
+		APIToken: core.APIToken{
+			ID:          uuid.New().String(),
+			User:        user.Name,
+			Description: req.Description,
+			Role:        role,
+			Scope:       scope,
+			Prefix:      secret[:len(TokenPrefix)+8],
+			CreatedAt:   time.Now(),
+			ExpiresAt:   expiresAt,
+		},
+		Hash: hashSecret(secret),
+	}
+	s.tokens[token.ID] = token
+	s.byHash[token.Hash] = token
+
+	if err := s.save(); err != nil {
+		delete(s.tokens, token.ID)
+		delete(s.byHash, token.Hash)
+		return core.APIToken{}, "", err
+	}
+	return token.APIToken, secret, nil
+}
+
+// ListTokens returns the tokens of a user, or of every user when user is "",
+// oldest first
+func (s *Store) ListTokens(user string) []core.APIToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+
+	tokens := make([]core.APIToken, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		if user == "" || token.User == user {
+			tokens = append(tokens, token.APIToken)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens
+}
+
+// RevokeToken deletes a token by ID or by its prefix
+func (s *Store) RevokeToken(idOrPrefix string) (core.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+
+	token, exists := s.tokens[idOrPrefix]
+	if !exists {
+		for _, candidate := range s.tokens {
+			if candidate.Prefix == idOrPrefix {
+				token = candidate
+				break
+			}
+		}
+	}
+	if token == nil {
+		return core.APIToken{}, fmt.Errorf("%w: %s", ErrTokenNotFound, idOrPrefix)
+	}
+
+	delete(s.tokens, token.ID)
+	delete(s.byHash, token.Hash)
+	if err := s.save(); err != nil {
+		return core.APIToken{}, err
+	}
+	return token.APIToken, nil
+}
+
+// Authenticate resolves a token secret to the identity it acts as. The effective
+// role is the lower of the token's and its user's current role, and the effective
+// scope the part of the token's scope its user still has.
+func (s *Store) Authenticate(secret string) (Identity, error) {
+	if !strings.HasPrefix(secret, TokenPrefix) {
+		return Identity{}, ErrInvalidToken
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadLocked()
+
+	token, exists := s.byHash[hashSecret(secret)]
+	if !exists {
+		return Identity{}, ErrInvalidToken
+	}
+	user, exists := s.users[token.User]
+	if !exists {
+		return Identity{}, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return Identity{}, ErrTokenExpired
+	}
+
+	persist := token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedPersistInterval
+	token.LastUsedAt = &now
+	if persist {
+		// Usage tracking is best effort; a failed write must not fail the request
+		s.save()
+	}
+
+	role := token.Role
+	if !user.Role.Allows(role) {
+		role = user.Role
+	}
+	scope, ok := effectiveScope(token.Scope, user.Scope)
+	if !ok {
+		return Identity{}, ErrTokenOutOfScope
+	}
+	return Identity{User: user.Name, TokenID: token.ID, Role: role, Scope: scope}, nil
+}
+
+// effectiveScope narrows a token's scope to its user's current scope, which may
+// have changed since the token was issued. ok is false when no server is left.
+func effectiveScope(token, user core.AccessScope) (scope core.AccessScope, ok bool) {
+	scope.VMLabels = mergeVMLabels(user.VMLabels, token.VMLabels)
+	switch {
+	case !user.LimitsServers():
+		scope.Servers, scope.Tags = token.Servers, token.Tags
+		return scope, true
+	case !token.LimitsServers():
+		scope.Servers, scope.Tags = user.Servers, user.Tags
+		return scope, true
+	}
+	for _, server := range token.Servers {
+		if contains(user.Servers, server) {
+			scope.Servers = append(scope.Servers, server)
+		}
+	}
+	for _, tag := range token.Tags {
+		if contains(user.Tags, tag) {
+			scope.Tags = append(scope.Tags, tag)
+		}
+	}
+	return scope, scope.LimitsServers()
+}
+
+// mergeVMLabels combines two VM label selectors into one that requires both.
+// Selectors that do not parse are kept as they are, so VM checks fail closed.
+func mergeVMLabels(user, token string) string {
+	merged, err := core.ParseLabelSelector(user)
+	if err != nil {
+		return user + "," + token
+	}
+	extra, err := core.ParseLabelSelector(token)
+	if err != nil {
+		return user + "," + token
+	}
+	for _, req := range extra {
+		if !slices.Contains(merged, req) {
+			merged = append(merged, req)
+		}
+	}
+	return merged.String()
+}
+
+// InScope reports whether a scope grants access to a registered server. server is
+// nil when a request targets a host that is not registered, which only a scope
+// without servers or tags grants.
+func InScope(scope core.AccessScope, server *core.ServerConfig) bool {
+	if !scope.LimitsServers() {
+		return true
+	}
+	if server == nil {
+		return false
+	}
+	for _, s := range scope.Servers {
+		if s == server.ID || s == server.Name {
+			return true
+		}
+	}
+	for _, tag := range scope.Tags {
+		for _, serverTag := range server.Tags {
+			if tag == serverTag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkWithin verifies that scope does not grant more than parent
+func checkWithin(scope, parent core.AccessScope) error {
+	if !parent.LimitsServers() {
+		return nil
+	}
+	for _, server := range scope.Servers {
+		if !contains(parent.Servers, server) {
+			return fmt.Errorf("server %s is not in the user's scope", server)
+		}
+	}
+	for _, tag := range scope.Tags {
+		if !contains(parent.Tags, tag) {
+			return fmt.Errorf("tag %s is not in the user's scope", tag)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func validateUserName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid user name %q: use letters, digits, '.', '_' or '-' (max 64)", name)
+	}
+	if reservedNames[name] {
+		return fmt.Errorf("user name %q is reserved", name)
+	}
+	return nil
+}
+
+// generateSecret returns a new random token secret
+func generateSecret() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return TokenPrefix + hex.EncodeToString(bytes), nil
+}
+
+// hashSecret hashes a token secret. Secrets are 256-bit random values, so a fast
+// hash is sufficient.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// reloadLocked picks up changes another process (the CLI) wrote to the store
+func (s *Store) reloadLocked() {
+	info, err := os.Stat(s.storagePath)
+	if err != nil || info.ModTime().Equal(s.modTime) {
+		return
+	}
+	s.load()
+}
+
+// storedAuth is the on-disk layout of the store
+type storedAuth struct {
+	Users  map[string]*core.User   `json:"users"`
+	Tokens map[string]*storedToken `json:"tokens"`
+}
+
+// load reads users and tokens from storage
+func (s *Store) load() error {
+	info, err := os.Stat(s.storagePath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(s.storagePath)
+	if err != nil {
+		return err
+	}
+
+	var stored storedAuth
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to unmarshal auth store: %w", err)
+	}
+
+	s.users = make(map[string]*core.User)
+	for name, user := range stored.Users {
+		s.users[name] = user
+	}
+	s.tokens = make(map[string]*storedToken)
+	s.byHash = make(map[string]*storedToken)
+	for id, token := range stored.Tokens {
+		s.tokens[id] = token
+		s.byHash[token.Hash] = token
+	}
+	s.modTime = info.ModTime()
+
+	return nil
+}
+
+// save writes users and tokens to storage
+func (s *Store) save() error {
+	dir := filepath.Dir(s.storagePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(storedAuth{Users: s.users, Tokens: s.tokens}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal auth store: %w", err)
+	}
+
+	tmpPath := s.storagePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write auth store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.storagePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write auth store: %w", err)
+	}
+
+	if info, err := os.Stat(s.storagePath); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+func TestStore_TokensAuthenticateAndRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	if _, err := store.AddUser(core.CreateUserRequest{Name: "alice", Role: core.RoleOperator, Scope: core.AccessScope{Servers: []string{"hv-01"}}}); err != nil {
+		t.Fatalf("AddUser() error = %v", err)
+	}
+	if _, err := store.AddUser(core.CreateUserRequest{Name: "cli", Role: core.RoleAdmin}); err == nil {
+		t.Error("Expected reserved user name to be rejected")
+	}
+
+	if _, _, err := store.CreateToken(core.CreateTokenRequest{User: "alice", Role: core.RoleAdmin}); err == nil {
+		t.Error("Expected a token role above the user's to be rejected")
+	}
+	if _, _, err := store.CreateToken(core.CreateTokenRequest{User: "alice", Scope: core.AccessScope{Servers: []string{"hv-02"}}}); err == nil {
+		t.Error("Expected a token scope outside the user's to be rejected")
+	}
+
+	token, secret, err := store.CreateToken(core.CreateTokenRequest{User: "alice", Role: core.RoleViewer})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	if token.Scope.Servers[0] != "hv-01" {
+		t.Errorf("Expected the token to inherit the user's scope, got %+v", token.Scope)
+	}
+
+	// A second store over the same file sees the token, as the CLI and server do
+	other, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	identity, err := other.Authenticate(secret)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if identity.User != "alice" || identity.Role != core.RoleViewer || identity.TokenID != token.ID {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+	if _, err := other.Authenticate(secret + "x"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+
+	if _, err := store.RevokeToken(token.Prefix); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	if _, err := other.Authenticate(secret); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected revoked token to be rejected, got %v", err)
+	}
+}
+
+func TestInScope(t *testing.T) {
+	server := &core.ServerConfig{ID: "id-1", Name: "hv-01", Tags: []string{"prod"}}
+
+	tests := []struct {
+		name   string
+		scope  core.AccessScope
+		server *core.ServerConfig
+		want   bool
+	}{
+		{"empty scope", core.AccessScope{}, server, true},
+		{"by name", core.AccessScope{Servers: []string{"hv-01"}}, server, true},
+		{"by ID", core.AccessScope{Servers: []string{"id-1"}}, server, true},
+		{"by tag", core.AccessScope{Tags: []string{"prod"}}, server, true},
+		{"other server", core.AccessScope{Servers: []string{"hv-02"}, Tags: []string{"dev"}}, server, false},
+		{"unregistered host", core.AccessScope{Tags: []string{"prod"}}, nil, false},
+		{"VM labels only", core.AccessScope{VMLabels: "team=web"}, server, true},
+	}
+	for _, tt := range tests {
+		if got := InScope(tt.scope, tt.server); got != tt.want {
+			t.Errorf("%s: InScope() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStore_AuthenticateNarrowsToUserScope(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "auth.json"))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	if _, err := store.AddUser(core.CreateUserRequest{Name: "alice", Role: core.RoleOperator, Scope: core.AccessScope{Servers: []string{"hv-01", "hv-02"}, Tags: []string{"prod"}}}); err != nil {
+		t.Fatalf("AddUser() error = %v", err)
+	}
+	_, secret, err := store.CreateToken(core.CreateTokenRequest{User: "alice"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	// Narrow the user after the token was issued
+	store.users["alice"].Scope = core.AccessScope{Servers: []string{"hv-02"}}
+	identity, err := store.Authenticate(secret)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if len(identity.Scope.Servers) != 1 || identity.Scope.Servers[0] != "hv-02" || len(identity.Scope.Tags) != 0 {
+		t.Errorf("Expected the token to be narrowed to hv-02, got %+v", identity.Scope)
+	}
+
+	store.users["alice"].Scope = core.AccessScope{Tags: []string{"dev"}}
+	if _, err := store.Authenticate(secret); !errors.Is(err, ErrTokenOutOfScope) {
+		t.Errorf("Expected ErrTokenOutOfScope, got %v", err)
+	}
+}
+
+func TestEffectiveScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		token, user core.AccessScope
+		want        core.AccessScope
+		wantOK      bool
+	}{
+		{"unscoped user", core.AccessScope{Tags: []string{"prod"}}, core.AccessScope{}, core.AccessScope{Tags: []string{"prod"}}, true},
+		{"user scoped since", core.AccessScope{}, core.AccessScope{Servers: []string{"hv-01"}}, core.AccessScope{Servers: []string{"hv-01"}}, true},
+		{"within", core.AccessScope{Servers: []string{"hv-01"}}, core.AccessScope{Servers: []string{"hv-01", "hv-02"}}, core.AccessScope{Servers: []string{"hv-01"}}, true},
+		{"partly revoked", core.AccessScope{Servers: []string{"hv-01"}, Tags: []string{"prod", "dev"}}, core.AccessScope{Tags: []string{"dev"}}, core.AccessScope{Tags: []string{"dev"}}, true},
+		{"fully revoked", core.AccessScope{Servers: []string{"hv-01"}}, core.AccessScope{Servers: []string{"hv-02"}}, core.AccessScope{}, false},
+		{"VM labels of both", core.AccessScope{VMLabels: "env=dev"}, core.AccessScope{Servers: []string{"hv-01"}, VMLabels: "team=web"}, core.AccessScope{Servers: []string{"hv-01"}, VMLabels: "team=web,env=dev"}, true},
+		{"VM labels kept when servers narrow", core.AccessScope{Servers: []string{"hv-01"}, VMLabels: "team=web"}, core.AccessScope{Servers: []string{"hv-01", "hv-02"}}, core.AccessScope{Servers: []string{"hv-01"}, VMLabels: "team=web"}, true},
+	}
+	for _, tt := range tests {
+		got, ok := effectiveScope(tt.token, tt.user)
+		if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: effectiveScope() = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestStore_CreateTokenWithVMLabels(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "auth.json"))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	if _, err := store.AddUser(core.CreateUserRequest{Name: "web", Role: core.RoleOperator, Scope: core.AccessScope{Servers: []string{"hv-01"}, VMLabels: "team=web"}}); err != nil {
+		t.Fatalf("AddUser() error = %v", err)
+	}
+	if _, err := store.AddUser(core.CreateUserRequest{Name: "bad", Role: core.RoleViewer, Scope: core.AccessScope{VMLabels: "team=web,=x"}}); err == nil {
+		t.Error("Expected an invalid label selector to be rejected")
+	}
+
+	// A token asking only for VM labels keeps the user's servers and labels
+	token, _, err := store.CreateToken(core.CreateTokenRequest{User: "web", Scope: core.AccessScope{VMLabels: "env=dev"}})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	want := core.AccessScope{Servers: []string{"hv-01"}, VMLabels: "team=web,env=dev"}
+	if !reflect.DeepEqual(token.Scope, want) {
+		t.Errorf("token scope = %+v, want %+v", token.Scope, want)
+	}
+
+	if _, _, err := store.CreateToken(core.CreateTokenRequest{User: "web", Scope: core.AccessScope{Servers: []string{"hv-02"}}}); err == nil {
+		t.Error("Expected a server outside the user's scope to be rejected")
+	}
+}
@@ -0,0 +1,229 @@
+// Package backup manages a directory of VM backups. Each backup lives in
+// <repository>/<vm-uuid>/<backup-id>/ with the domain XML, one qcow2 image per
+// disk and a manifest.json that is written last, so a backup without a
+// manifest never finished and is ignored.
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// ManifestFile is the name of the file describing a finished backup
+const ManifestFile = "manifest.json"
+
+// DomainXMLFile is the name of the domain definition in a backup
+const DomainXMLFile = "domain.xml"
+
+// idLayout names backups after the UTC time they were started
+const idLayout = "20060102-150405"
+
+// ErrBackupNotFound is returned for unknown VMs or backup IDs
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Repository is a directory of VM backups
+type Repository struct {
+	dir string
+}
+
+// NewRepository opens the backup repository in dir. The directory is created
+// when the first backup is written.
+func NewRepository(dir string) (*Repository, error) {
+	if dir == "" {
+		return nil, errors.New("backup repository directory is required")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve backup repository: %w", err)
+	}
+	return &Repository{dir: abs}, nil
+}
+
+// Dir returns the repository directory
+func (r *Repository) Dir() string {
+	return r.dir
+}
+
+// NewID returns the ID of a backup started at t
+func NewID(t time.Time) string {
+	return t.UTC().Format(idLayout)
+}
+
+// Create makes the directory for a new backup of a VM and returns it
+func (r *Repository) Create(vmUUID, id string) (string, error) {
+	dir := filepath.Join(r.dir, vmUUID, id)
+	if _, err := os.Stat(dir); err == nil {
+		return "", fmt.Errorf("backup %s already exists", id)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("create backup directory: %w", err)
+	}
+	return dir, nil
+}
+
+// Commit checksums a backup's domain XML and disk files and writes its manifest
+func (r *Repository) Commit(b *core.Backup) error {
+	dir := filepath.Join(r.dir, b.VMUUID, b.ID)
+
+	b.SizeBytes = 0
+	files := []*core.BackupFile{&b.DomainXML}
+	for i := range b.Disks {
+		files = append(files, &b.Disks[i].BackupFile)
+	}
+	for _, f := range files {
+		sum, size, err := HashFile(filepath.Join(dir, f.File))
+		if err != nil {
+			return err
+		}
+		f.SHA256 = sum
+		f.SizeBytes = size
+		b.SizeBytes += size
+	}
+
+	b.Path = ""
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal backup manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), data, 0600); err != nil {
+		return fmt.Errorf("write backup manifest: %w", err)
+	}
+	b.Path = dir
+	return nil
+}
+
+// List returns the finished backups of a VM (by UUID or name), or of every VM
+// when vm is "", oldest first
+func (r *Repository) List(vm string) ([]core.Backup, error) {
+	vmDirs, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []core.Backup{}, nil
+		}
+		return nil, fmt.Errorf("read backup repository: %w", err)
+	}
+
+	backups := []core.Backup{}
+	for _, vmDir := range vmDirs {
+		if !vmDir.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(r.dir, vmDir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			b, err := r.read(filepath.Join(r.dir, vmDir.Name(), entry.Name()))
+			if err != nil {
+				continue
+			}
+			if vm == "" || strings.EqualFold(b.VMUUID, vm) || b.VMName == vm {
+				backups = append(backups, b)
+			}
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.Before(backups[j].CreatedAt)
+		}
+		return backups[i].ID < backups[j].ID
+	})
+	return backups, nil
+}
+
+// Get returns one backup of a VM, or its latest when id is ""
+func (r *Repository) Get(vm, id string) (core.Backup, error) {
+	backups, err := r.List(vm)
+	if err != nil {
+		return core.Backup{}, err
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		if id == "" || backups[i].ID == id {
+			return backups[i], nil
+		}
+	}
+	if id == "" {
+		return core.Backup{}, fmt.Errorf("%w: no backups of %s", ErrBackupNotFound, vm)
+	}
+	return core.Backup{}, fmt.Errorf("%w: %s/%s", ErrBackupNotFound, vm, id)
+}
+
+// Chain returns the backups needed to restore b, from its full backup to b itself
+func (r *Repository) Chain(b core.Backup) ([]core.Backup, error) {
+	chain := []core.Backup{b}
+	for b.Kind == core.BackupIncremental {
+		if len(chain) > 1000 {
+			return nil, errors.New("backup chain is too long or cyclic")
+		}
+		parent, err := r.read(filepath.Join(r.dir, b.VMUUID, b.Parent))
+		if err != nil {
+			return nil, fmt.Errorf("backup %s needs missing parent %s: %w", b.ID, b.Parent, err)
+		}
+		chain = append([]core.Backup{parent}, chain...)
+		b = parent
+	}
+	return chain, nil
+}
+
+// Verify checks every file of a backup against its manifest checksums
+func Verify(b core.Backup) error {
+	files := []core.BackupFile{b.DomainXML}
+	for _, d := range b.Disks {
+		files = append(files, d.BackupFile)
+	}
+	for _, f := range files {
+		sum, size, err := HashFile(filepath.Join(b.Path, f.File))
+		if err != nil {
+			return err
+		}
+		if size != f.SizeBytes || sum != f.SHA256 {
+			return fmt.Errorf("backup %s is corrupt: checksum mismatch for %s", b.ID, f.File)
+		}
+	}
+	return nil
+}
+
+// HashFile returns the SHA-256 and size of a file
+func HashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("open %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// read loads the manifest of the backup in dir
+func (r *Repository) read(dir string) (core.Backup, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return core.Backup{}, err
+	}
+	var b core.Backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return core.Backup{}, fmt.Errorf("parse backup manifest: %w", err)
+	}
+	b.Path = dir
+	return b, nil
+}
@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+const testUUID = "550e8400-e29b-41d4-a716-446655440000"
+
+// writeBackup creates a finished backup with one disk
+func writeBackup(t *testing.T, repo *Repository, id, kind, parent string, created time.Time) core.Backup {
+	t.Helper()
+	dir, err := repo.Create(testUUID, id)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, DomainXMLFile), []byte("<domain/>"), 0600)
+	os.WriteFile(filepath.Join(dir, "vda.qcow2"), []byte("disk "+id), 0600)
+
+	b := core.Backup{
+		ID:        id,
+		VMName:    "web-01",
+		VMUUID:    testUUID,
+		Kind:      kind,
+		Parent:    parent,
+		CreatedAt: created,
+		DomainXML: core.BackupFile{File: DomainXMLFile},
+		Disks:     []core.BackupDisk{{Target: "vda", BackupFile: core.BackupFile{File: "vda.qcow2"}}},
+	}
+	if err := repo.Commit(&b); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	return b
+}
+
+func TestRepository_ListAndChain(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+
+	start := time.Date(2025, 1, 15, 2, 0, 0, 0, time.UTC)
+	full := writeBackup(t, repo, NewID(start), core.BackupFull, "", start)
+	inc1 := writeBackup(t, repo, NewID(start.Add(24*time.Hour)), core.BackupIncremental, full.ID, start.Add(24*time.Hour))
+	inc2 := writeBackup(t, repo, NewID(start.Add(48*time.Hour)), core.BackupIncremental, inc1.ID, start.Add(48*time.Hour))
+
+	// A backup that never finished has no manifest and is ignored
+	if _, err := repo.Create(testUUID, NewID(start.Add(72*time.Hour))); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	for _, vm := range []string{"", "web-01", testUUID} {
+		backups, err := repo.List(vm)
+		if err != nil {
+			t.Fatalf("List(%q) error = %v", vm, err)
+		}
+		if len(backups) != 3 || backups[0].ID != full.ID || backups[2].ID != inc2.ID {
+			t.Errorf("List(%q) = %d backups, want the three finished ones oldest first", vm, len(backups))
+		}
+	}
+	if backups, _ := repo.List("db-01"); len(backups) != 0 {
+		t.Errorf("List(db-01) = %d backups, want none", len(backups))
+	}
+
+	latest, err := repo.Get("web-01", "")
+	if err != nil || latest.ID != inc2.ID {
+		t.Fatalf("Get(latest) = %s, %v, want %s", latest.ID, err, inc2.ID)
+	}
+	if _, err := repo.Get("web-01", "19700101-000000"); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("Get(unknown) error = %v, want ErrBackupNotFound", err)
+	}
+
+	chain, err := repo.Chain(latest)
+	if err != nil {
+		t.Fatalf("Chain() error = %v", err)
+	}
+	if len(chain) != 3 || chain[0].ID != full.ID || chain[1].ID != inc1.ID || chain[2].ID != inc2.ID {
+		t.Errorf("Chain() = %v, want full, inc1, inc2", chain)
+	}
+
+	os.RemoveAll(inc1.Path)
+	if _, err := repo.Chain(latest); err == nil {
+		t.Error("Chain() with a missing parent expected an error")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	repo, err := NewRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	b := writeBackup(t, repo, "20250115-020000", core.BackupFull, "", time.Now())
+
+	if b.SizeBytes == 0 || b.Disks[0].SHA256 == "" {
+		t.Fatalf("Commit() did not checksum the files: %+v", b)
+	}
+	if err := Verify(b); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	os.WriteFile(filepath.Join(b.Path, "vda.qcow2"), []byte("disk 20250115-02000X"), 0600)
+	if err := Verify(b); err == nil {
+		t.Error("Verify() of a modified disk expected an error")
+	}
+}
@@ -0,0 +1,165 @@
+// Package bulk runs one VM action, such as stop or snapshot, on many VMs at
+// once with bounded concurrency, recording the outcome for each VM.
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// Client is a connection the actions run on (libvirtclient.ClientInterface)
+type Client interface {
+	GetVMSummaries() ([]core.VM_Summary, error)
+	PerformVMAction(uuid string, action string) error
+	DeleteVM(uuid string, deleteDisks bool) error
+	CreateVMSnapshot(uuid string, cfg core.CreateSnapshotRequest) (core.Snapshot, error)
+}
+
+// doneStates are the states after which an action has nothing to do
+var doneStates = map[string]string{
+	"start":      "running",
+	"stop":       "shutoff",
+	"force-stop": "shutoff",
+	"pause":      "paused",
+	"resume":     "running",
+}
+
+// Select returns the VMs a request acts on, sorted by name, and the requested
+// UUIDs that match no VM
+func Select(client Client, req core.BulkVMActionRequest) ([]core.VM_Summary, []string, error) {
+	vms, err := client.GetVMSummaries()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+
+	var selected []core.VM_Summary
+	var missing []string
+	if len(req.UUIDs) > 0 {
+		byUUID := make(map[string]core.VM_Summary, len(vms))
+		for _, vm := range vms {
+			byUUID[strings.ToLower(vm.UUID)] = vm
+		}
+		seen := make(map[string]bool, len(req.UUIDs))
+		for _, uuid := range req.UUIDs {
+			key := strings.ToLower(uuid)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if vm, ok := byUUID[key]; ok {
+				selected = append(selected, vm)
+			} else {
+				missing = append(missing, uuid)
+			}
+		}
+	} else {
+		for _, vm := range vms {
+			if req.Selects(vm) {
+				selected = append(selected, vm)
+			}
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].Name < selected[j].Name })
+	return selected, missing, nil
+}
+
+// Run selects VMs and runs the action on them, Parallelism at a time. A failed
+// VM does not stop the others; cancelling ctx skips the VMs not yet started.
+// Stop and reboot only ask the guest, as the single-VM actions do.
+func Run(ctx context.Context, client Client, req core.BulkVMActionRequest, progress func(percent float64, message string)) (core.BulkVMActionResult, error) {
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+	if err := req.Validate(); err != nil {
+		return core.BulkVMActionResult{}, err
+	}
+	vms, missing, err := Select(client, req)
+	if err != nil {
+		return core.BulkVMActionResult{}, err
+	}
+
+	parallelism := req.Parallelism
+	if parallelism == 0 {
+		parallelism = core.DefaultBulkParallelism
+	}
+
+	results := make([]core.BulkVMResult, len(vms))
+	var mu sync.Mutex
+	finished := 0
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallelism)
+	for i, vm := range vms {
+		results[i] = core.BulkVMResult{UUID: vm.UUID, Name: vm.Name, Status: core.BulkSkipped}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		if ctx.Err() != nil {
+			<-slots
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, vm core.VM_Summary) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			status, err := runOne(client, req, vm)
+			mu.Lock()
+			defer mu.Unlock()
+			results[i].Status = status
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+			finished++
+			progress(float64(finished)*100/float64(len(vms)), fmt.Sprintf("%s %s: %s (%d/%d)", req.Action, vm.Name, status, finished, len(vms)))
+		}(i, vm)
+	}
+	wg.Wait()
+
+	result := core.BulkVMActionResult{Action: req.Action, Results: results}
+	for _, uuid := range missing {
+		result.Results = append(result.Results, core.BulkVMResult{UUID: uuid, Status: core.BulkFailed, Error: "VM not found"})
+	}
+	for _, r := range result.Results {
+		switch r.Status {
+		case core.BulkSucceeded:
+			result.Succeeded++
+		case core.BulkUnchanged:
+			result.Unchanged++
+		case core.BulkFailed:
+			result.Failed++
+		case core.BulkSkipped:
+			result.Skipped++
+		}
+	}
+	return result, nil
+}
+
+// runOne runs the action on one VM
+func runOne(client Client, req core.BulkVMActionRequest, vm core.VM_Summary) (string, error) {
+	if state, ok := doneStates[req.Action]; ok && strings.EqualFold(vm.State, state) {
+		return core.BulkUnchanged, nil
+	}
+
+	var err error
+	switch req.Action {
+	case "delete":
+		err = client.DeleteVM(vm.UUID, req.DeleteDisks)
+	case "snapshot":
+		_, err = client.CreateVMSnapshot(vm.UUID, *req.Snapshot)
+	default:
+		err = client.PerformVMAction(vm.UUID, req.Action)
+	}
+	if err != nil {
+		return core.BulkFailed, err
+	}
+	return core.BulkSucceeded, nil
+}
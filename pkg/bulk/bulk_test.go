@@ -0,0 +1,168 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// fakeClient records the actions it is asked to run
+type fakeClient struct {
+	vms     []core.VM_Summary
+	failing map[string]bool
+	delay   time.Duration
+
+	mu      sync.Mutex
+	calls   []string
+	running int
+	peak    int
+}
+
+func (f *fakeClient) GetVMSummaries() ([]core.VM_Summary, error) { return f.vms, nil }
+
+func (f *fakeClient) do(call, uuid string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.running++
+	if f.running > f.peak {
+		f.peak = f.running
+	}
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	f.running--
+	f.mu.Unlock()
+	if f.failing[uuid] {
+		return errors.New("domain is locked")
+	}
+	return nil
+}
+
+func (f *fakeClient) PerformVMAction(uuid string, action string) error {
+	return f.do(action+" "+uuid, uuid)
+}
+
+func (f *fakeClient) DeleteVM(uuid string, deleteDisks bool) error {
+	return f.do(fmt.Sprintf("delete %s disks=%v", uuid, deleteDisks), uuid)
+}
+
+func (f *fakeClient) CreateVMSnapshot(uuid string, cfg core.CreateSnapshotRequest) (core.Snapshot, error) {
+	return core.Snapshot{Name: cfg.Name}, f.do("snapshot "+uuid+" "+cfg.Name, uuid)
+}
+
+func (f *fakeClient) sortedCalls() string {
+	sort.Strings(f.calls)
+	return strings.Join(f.calls, "; ")
+}
+
+func newLab() *fakeClient {
+	return &fakeClient{vms: []core.VM_Summary{
+		{Name: "web-02", UUID: "u2", State: "Running", Labels: map[string]string{"env": "dev", "tier": "web"}},
+		{Name: "web-01", UUID: "u1", State: "Running", Labels: map[string]string{"env": "dev", "tier": "web"}},
+		{Name: "db-01", UUID: "u3", State: "Shutoff", Labels: map[string]string{"env": "dev", "tier": "db"}},
+		{Name: "prod-01", UUID: "u4", State: "Running", Labels: map[string]string{"env": "prod"}},
+	}}
+}
+
+func TestRun_Selector(t *testing.T) {
+	client := newLab()
+	client.failing = map[string]bool{"u2": true}
+
+	result, err := Run(context.Background(), client, core.BulkVMActionRequest{Action: "stop", Selector: "env=dev"}, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := client.sortedCalls(); got != "stop u1; stop u2" {
+		t.Errorf("calls = %s", got)
+	}
+
+	var summary []string
+	for _, r := range result.Results {
+		summary = append(summary, r.Name+"="+r.Status)
+	}
+	if got := strings.Join(summary, ","); got != "db-01=unchanged,web-01=succeeded,web-02=failed" {
+		t.Errorf("results = %s", got)
+	}
+	if result.Succeeded != 1 || result.Unchanged != 1 || result.Failed != 1 || result.Results[2].Error != "domain is locked" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestRun_UUIDsAndOptions(t *testing.T) {
+	client := newLab()
+	result, err := Run(context.Background(), client, core.BulkVMActionRequest{Action: "delete", UUIDs: []string{"u4", "U3", "u4", "u9"}, DeleteDisks: true}, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := client.sortedCalls(); got != "delete u3 disks=true; delete u4 disks=true" {
+		t.Errorf("calls = %s", got)
+	}
+	if result.Succeeded != 2 || result.Failed != 1 || result.Results[2].UUID != "u9" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	client = newLab()
+	snapshot := &core.CreateSnapshotRequest{Name: "pre-maintenance"}
+	if _, err := Run(context.Background(), client, core.BulkVMActionRequest{Action: "snapshot", Name: "web-*", Snapshot: snapshot}, nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := client.sortedCalls(); got != "snapshot u1 pre-maintenance; snapshot u2 pre-maintenance" {
+		t.Errorf("calls = %s", got)
+	}
+}
+
+func TestRun_Parallelism(t *testing.T) {
+	client := &fakeClient{delay: 20 * time.Millisecond}
+	for i := 0; i < 10; i++ {
+		client.vms = append(client.vms, core.VM_Summary{Name: fmt.Sprintf("vm-%02d", i), UUID: fmt.Sprint(i), State: "Shutoff"})
+	}
+
+	var updates int
+	result, err := Run(context.Background(), client, core.BulkVMActionRequest{Action: "start", Name: "*", Parallelism: 3}, func(float64, string) { updates++ })
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Succeeded != 10 || updates != 10 {
+		t.Errorf("succeeded = %d, progress updates = %d", result.Succeeded, updates)
+	}
+	if client.peak != 3 {
+		t.Errorf("peak concurrency = %d, want 3", client.peak)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = Run(ctx, client, core.BulkVMActionRequest{Action: "start", Name: "*"}, nil)
+	if err != nil || result.Skipped != 10 {
+		t.Errorf("cancelled run: skipped = %d, err = %v", result.Skipped, err)
+	}
+}
+
+func TestRun_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		req  core.BulkVMActionRequest
+	}{
+		{"unknown action", core.BulkVMActionRequest{Action: "explode", Name: "*"}},
+		{"nothing selected", core.BulkVMActionRequest{Action: "stop"}},
+		{"uuids and selector", core.BulkVMActionRequest{Action: "stop", UUIDs: []string{"u1"}, Selector: "env=dev"}},
+		{"bad selector", core.BulkVMActionRequest{Action: "stop", Selector: "=dev"}},
+		{"bad pattern", core.BulkVMActionRequest{Action: "stop", Name: "[web"}},
+		{"parallelism", core.BulkVMActionRequest{Action: "stop", Name: "*", Parallelism: core.MaxBulkParallelism + 1}},
+		{"snapshot without options", core.BulkVMActionRequest{Action: "snapshot", Name: "*"}},
+		{"deleteDisks with stop", core.BulkVMActionRequest{Action: "stop", Name: "*", DeleteDisks: true}},
+	}
+	for _, tt := range tests {
+		if _, err := Run(context.Background(), newLab(), tt.req, nil); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -11,10 +11,18 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Security SecurityConfig `json:"security"`
-	Libvirt  LibvirtConfig  `json:"libvirt"`
-	Logging  LoggingConfig  `json:"logging"`
+	Server     ServerConfig     `json:"server"`
+	Security   SecurityConfig   `json:"security"`
+	Libvirt    LibvirtConfig    `json:"libvirt"`
+	Logging    LoggingConfig    `json:"logging"`
+	Audit      AuditConfig      `json:"audit"`
+	Metrics    MetricsConfig    `json:"metrics"`
+	Prometheus PrometheusConfig `json:"prometheus"`
+	Backup     BackupConfig     `json:"backup"`
+	Export     ExportConfig     `json:"export"`
+	Upload     UploadConfig     `json:"upload"`
+	Download   DownloadConfig   `json:"download"`
+	Jobs       JobsConfig       `json:"jobs"`
 }
 
 // ServerConfig represents server-specific configuration
@@ -59,6 +67,58 @@ type LoggingConfig struct {
 	Format string `json:"format"` // json, text
 }
 
+// AuditConfig represents the persistent activity/audit log configuration
+type AuditConfig struct {
+	Path          string `json:"path"`           // JSONL file the audit log is appended to
+	RetentionDays int    `json:"retention_days"` // 0 keeps events forever
+	MaxEvents     int    `json:"max_events"`     // 0 means no cap
+}
+
+// MetricsConfig represents the per-VM performance history configuration
+type MetricsConfig struct {
+	Enabled         bool   `json:"enabled"`
+	Path            string `json:"path"`             // Directory holding one time series per VM
+	IntervalSeconds int    `json:"interval_seconds"` // How often running VMs are sampled
+	RetentionHours  int    `json:"retention_hours"`  // How long samples are kept
+}
+
+// PrometheusConfig represents the /metrics exporter configuration
+type PrometheusConfig struct {
+	Enabled              bool   `json:"enabled"`
+	Token                string `json:"token"`                 // Bearer token accepted for scrapes in addition to the API key
+	AllowUnauthenticated bool   `json:"allow_unauthenticated"` // Serve /metrics without any credentials
+}
+
+// BackupConfig represents the VM backup repository configuration
+type BackupConfig struct {
+	Path string `json:"path"` // Directory holding one subdirectory of backups per VM
+}
+
+// ExportConfig represents the directory VM exports are written to and imported from
+type ExportConfig struct {
+	Path      string `json:"path"`        // Directory for OVA and tar archives exchanged through the API
+	MaxSizeGB int    `json:"max_size_gb"` // Largest archive accepted by uploads, 0 means no limit
+}
+
+// UploadConfig represents the limits on images uploaded through the API
+type UploadConfig struct {
+	MaxSizeGB       int `json:"max_size_gb"`       // Largest image accepted, 0 means no limit
+	SessionTTLHours int `json:"session_ttl_hours"` // How long an idle resumable upload is kept
+}
+
+// DownloadConfig represents the limits on images downloaded from URLs
+type DownloadConfig struct {
+	Path               string `json:"path"`                 // Directory holding URL downloads until they are imported
+	MaxConcurrent      int    `json:"max_concurrent"`       // Downloads running at once; others are queued
+	BandwidthLimitKBps int    `json:"bandwidth_limit_kbps"` // Combined KiB/s of all downloads, 0 means no limit
+}
+
+// JobsConfig represents how many background jobs run at once
+type JobsConfig struct {
+	Workers     int `json:"workers"`      // Short jobs such as VM creation, clones, snapshots and volume resizes
+	LongWorkers int `json:"long_workers"` // Long jobs such as backups, exports, migrations, downloads, apply and bulk actions
+}
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -90,6 +150,39 @@ func DefaultConfig() *Config {
 			Level:  "INFO",
 			Format: "json",
 		},
+		Audit: AuditConfig{
+			Path:          "/var/lib/flint/audit/activity.jsonl",
+			RetentionDays: 90,
+			MaxEvents:     100000,
+		},
+		Metrics: MetricsConfig{
+			Enabled:         true,
+			Path:            "/var/lib/flint/metrics",
+			IntervalSeconds: 30,
+			RetentionHours:  72,
+		},
+		Prometheus: PrometheusConfig{
+			Enabled: true,
+		},
+		Backup: BackupConfig{
+			Path: "/var/lib/flint/backups",
+		},
+		Export: ExportConfig{
+			Path:      "/var/lib/flint/exports",
+			MaxSizeGB: 500,
+		},
+		Upload: UploadConfig{
+			MaxSizeGB:       100,
+			SessionTTLHours: 24,
+		},
+		Download: DownloadConfig{
+			Path:          "/var/lib/flint/downloads",
+			MaxConcurrent: 2,
+		},
+		Jobs: JobsConfig{
+			Workers:     4,
+			LongWorkers: 4,
+		},
 	}
 }
 
@@ -206,6 +299,104 @@ func loadFromEnv(config *Config) {
 	if format := os.Getenv("FLINT_LOG_FORMAT"); format != "" {
 		config.Logging.Format = strings.ToLower(format)
 	}
+
+	// Audit configuration
+	if auditPath := os.Getenv("FLINT_AUDIT_PATH"); auditPath != "" {
+		config.Audit.Path = auditPath
+	}
+	if retentionDays := os.Getenv("FLINT_AUDIT_RETENTION_DAYS"); retentionDays != "" {
+		if d, err := strconv.Atoi(retentionDays); err == nil {
+			config.Audit.RetentionDays = d
+		}
+	}
+	if maxEvents := os.Getenv("FLINT_AUDIT_MAX_EVENTS"); maxEvents != "" {
+		if m, err := strconv.Atoi(maxEvents); err == nil {
+			config.Audit.MaxEvents = m
+		}
+	}
+
+	// Metrics configuration
+	if metricsEnabled := os.Getenv("FLINT_METRICS_ENABLED"); metricsEnabled != "" {
+		config.Metrics.Enabled = metricsEnabled == "true" || metricsEnabled == "1"
+	}
+	if metricsPath := os.Getenv("FLINT_METRICS_PATH"); metricsPath != "" {
+		config.Metrics.Path = metricsPath
+	}
+	if interval := os.Getenv("FLINT_METRICS_INTERVAL_SECONDS"); interval != "" {
+		if i, err := strconv.Atoi(interval); err == nil {
+			config.Metrics.IntervalSeconds = i
+		}
+	}
+	if retentionHours := os.Getenv("FLINT_METRICS_RETENTION_HOURS"); retentionHours != "" {
+		if h, err := strconv.Atoi(retentionHours); err == nil {
+			config.Metrics.RetentionHours = h
+		}
+	}
+
+	// Prometheus exporter configuration
+	if promEnabled := os.Getenv("FLINT_PROMETHEUS_ENABLED"); promEnabled != "" {
+		config.Prometheus.Enabled = promEnabled == "true" || promEnabled == "1"
+	}
+	if promToken := os.Getenv("FLINT_PROMETHEUS_TOKEN"); promToken != "" {
+		config.Prometheus.Token = promToken
+	}
+	if promPublic := os.Getenv("FLINT_PROMETHEUS_ALLOW_UNAUTHENTICATED"); promPublic != "" {
+		config.Prometheus.AllowUnauthenticated = promPublic == "true" || promPublic == "1"
+	}
+
+	// Backup configuration
+	if backupPath := os.Getenv("FLINT_BACKUP_PATH"); backupPath != "" {
+		config.Backup.Path = backupPath
+	}
+
+	// Export configuration
+	if exportPath := os.Getenv("FLINT_EXPORT_PATH"); exportPath != "" {
+		config.Export.Path = exportPath
+	}
+	if maxSize := os.Getenv("FLINT_EXPORT_MAX_SIZE_GB"); maxSize != "" {
+		if m, err := strconv.Atoi(maxSize); err == nil {
+			config.Export.MaxSizeGB = m
+		}
+	}
+
+	// Upload configuration
+	if maxSize := os.Getenv("FLINT_UPLOAD_MAX_SIZE_GB"); maxSize != "" {
+		if m, err := strconv.Atoi(maxSize); err == nil {
+			config.Upload.MaxSizeGB = m
+		}
+	}
+	if ttl := os.Getenv("FLINT_UPLOAD_SESSION_TTL_HOURS"); ttl != "" {
+		if h, err := strconv.Atoi(ttl); err == nil {
+			config.Upload.SessionTTLHours = h
+		}
+	}
+
+	// Download configuration
+	if downloadPath := os.Getenv("FLINT_DOWNLOAD_PATH"); downloadPath != "" {
+		config.Download.Path = downloadPath
+	}
+	if maxConcurrent := os.Getenv("FLINT_DOWNLOAD_MAX_CONCURRENT"); maxConcurrent != "" {
+		if m, err := strconv.Atoi(maxConcurrent); err == nil {
+			config.Download.MaxConcurrent = m
+		}
+	}
+	if limit := os.Getenv("FLINT_DOWNLOAD_BANDWIDTH_LIMIT_KBPS"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			config.Download.BandwidthLimitKBps = l
+		}
+	}
+
+	// Jobs configuration
+	if workers := os.Getenv("FLINT_JOBS_WORKERS"); workers != "" {
+		if w, err := strconv.Atoi(workers); err == nil {
+			config.Jobs.Workers = w
+		}
+	}
+	if longWorkers := os.Getenv("FLINT_JOBS_LONG_WORKERS"); longWorkers != "" {
+		if w, err := strconv.Atoi(longWorkers); err == nil {
+			config.Jobs.LongWorkers = w
+		}
+	}
 }
 
 // SaveConfig saves the configuration to a file
@@ -283,6 +474,30 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid log level: %s", c.Logging.Level)
 	}
 
+	// Validate audit config
+	if c.Audit.Path == "" {
+		return fmt.Errorf("audit log path cannot be empty")
+	}
+	if c.Audit.RetentionDays < 0 {
+		return fmt.Errorf("audit retention days cannot be negative")
+	}
+	if c.Audit.MaxEvents < 0 {
+		return fmt.Errorf("audit max events cannot be negative")
+	}
+
+	// Validate metrics config
+	if c.Metrics.Enabled {
+		if c.Metrics.Path == "" {
+			return fmt.Errorf("metrics path cannot be empty")
+		}
+		if c.Metrics.IntervalSeconds < 1 {
+			return fmt.Errorf("metrics interval must be at least 1 second")
+		}
+		if c.Metrics.RetentionHours < 1 {
+			return fmt.Errorf("metrics retention must be at least 1 hour")
+		}
+	}
+
 	validFormats := map[string]bool{
 		"json": true,
 		"text": true,
@@ -27,10 +27,25 @@ type Pool struct {
 	maxIdle     time.Duration
 	healthCheck time.Duration
 	stopChan    chan struct{}
+	dial        Dialer
+}
+
+// Dialer opens a connection to a server
+type Dialer func(config *core.ServerConfig) (libvirtclient.ClientInterface, error)
+
+// dialLibvirt connects to the server's libvirt URI
+func dialLibvirt(config *core.ServerConfig) (libvirtclient.ClientInterface, error) {
+	return libvirtclient.NewClient(config.URI, config.ISOPool, config.TemplatePool)
 }
 
 // NewPool creates a new connection pool
 func NewPool(maxIdleDuration, healthCheckInterval time.Duration) *Pool {
+	return NewPoolWithDialer(maxIdleDuration, healthCheckInterval, dialLibvirt)
+}
+
+// NewPoolWithDialer creates a connection pool that opens connections with dial,
+// e.g. to hand out fake clients in tests
+func NewPoolWithDialer(maxIdleDuration, healthCheckInterval time.Duration, dial Dialer) *Pool {
 	if maxIdleDuration == 0 {
 		maxIdleDuration = 30 * time.Minute
 	}
@@ -43,6 +58,7 @@ func NewPool(maxIdleDuration, healthCheckInterval time.Duration) *Pool {
 		maxIdle:     maxIdleDuration,
 		healthCheck: healthCheckInterval,
 		stopChan:    make(chan struct{}),
+		dial:        dial,
 	}
 
 	// Start background cleanup and health check
@@ -87,7 +103,7 @@ func (p *Pool) createConnection(config *core.ServerConfig) (libvirtclient.Client
 	})
 
 	// Create new client
-	client, err := libvirtclient.NewClient(config.URI, config.ISOPool, config.TemplatePool)
+	client, err := p.dial(config)
 	if err != nil {
 		logger.Error("Failed to create libvirt connection", map[string]interface{}{
 			"server_id":   config.ID,
@@ -0,0 +1,89 @@
+package core
+
+// Values for the ensure field of spec resources
+const (
+	EnsurePresent = "present"
+	EnsureAbsent  = "absent"
+)
+
+// Desired power states of a VMSpec
+const (
+	VMStateRunning = "running"
+	VMStateStopped = "stopped"
+)
+
+// Plan operations
+const (
+	PlanCreate = "create"
+	PlanUpdate = "update"
+	PlanDelete = "delete"
+)
+
+// Outcomes of a plan action after apply
+const (
+	PlanApplied = "applied"
+	PlanFailed  = "failed"
+	PlanSkipped = "skipped" // An earlier action failed
+)
+
+// Spec is the desired state of a host: storage pools, networks, network
+// filters and VMs. Resources not in the spec are left alone.
+type Spec struct {
+	Pools     []PoolSpec     `json:"pools,omitempty"`
+	Networks  []NetworkSpec  `json:"networks,omitempty"`
+	NWFilters []NWFilterSpec `json:"nwfilters,omitempty"`
+	VMs       []VMSpec       `json:"vms,omitempty"`
+}
+
+// PoolSpec is a storage pool. Pools are only created, never changed or removed.
+type PoolSpec struct {
+	PoolConfig
+}
+
+// NetworkSpec is a NAT network. The bridge is needed to create it.
+type NetworkSpec struct {
+	Name   string `json:"name"`
+	Bridge string `json:"bridge,omitempty"`
+	Ensure string `json:"ensure,omitempty"` // present (default) or absent
+}
+
+// NWFilterSpec is a network filter with its complete list of rules
+type NWFilterSpec struct {
+	Name   string         `json:"name"`
+	Rules  []NWFilterRule `json:"rules,omitempty"`
+	Ensure string         `json:"ensure,omitempty"` // present (default) or absent
+}
+
+// VMSpec is a VM with the fields of VMCreationConfig. Memory, vCPUs, data
+// disks, NICs and the power state are reconciled; the image, hardware options
+// and cloud-init only apply when the VM is created.
+type VMSpec struct {
+	VMCreationConfig
+	State       string `json:"state,omitempty"`       // running (default) or stopped
+	Ensure      string `json:"ensure,omitempty"`      // present (default) or absent
+	DeleteDisks bool   `json:"deleteDisks,omitempty"` // Delete the VM's volumes with it when absent
+}
+
+// PlanChange is one difference between a resource and its spec
+type PlanChange struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// PlanAction creates, updates or deletes one resource
+type PlanAction struct {
+	Kind    string       `json:"kind"` // pool, network, nwfilter or vm
+	Name    string       `json:"name"`
+	Op      string       `json:"op"`
+	Changes []PlanChange `json:"changes,omitempty"`
+	Status  string       `json:"status,omitempty"` // Set by apply
+	Error   string       `json:"error,omitempty"`
+}
+
+// Plan is what apply would do to reach a spec, in order. Warnings are
+// differences apply leaves alone, such as NICs missing from the spec.
+type Plan struct {
+	Actions  []PlanAction `json:"actions"`
+	Warnings []string     `json:"warnings,omitempty"`
+}
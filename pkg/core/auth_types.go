@@ -0,0 +1,96 @@
+package core
+
+import "time"
+
+// Role is what an API user or token may do. Each role includes the ones below it.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // Read-only access
+	RoleOperator Role = "operator" // Manage VMs, snapshots, volumes and images
+	RoleAdmin    Role = "admin"    // Also manage servers, networks, storage pools, users and tokens
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Allows reports whether r includes the required role
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// AccessScope limits a user or token to some registered servers and VMs. A
+// server is in scope when its ID or name is listed in Servers or it carries one
+// of Tags; without either, every server is. VMLabels, a label selector such as
+// env=dev,team=web, further limits access to the VMs whose labels match. An
+// empty scope grants everything.
+type AccessScope struct {
+	Servers  []string `json:"servers,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	VMLabels string   `json:"vm_labels,omitempty"`
+}
+
+// IsEmpty reports whether the scope grants everything
+func (s AccessScope) IsEmpty() bool {
+	return !s.LimitsServers() && s.VMLabels == ""
+}
+
+// LimitsServers reports whether the scope names servers or server tags
+func (s AccessScope) LimitsServers() bool {
+	return len(s.Servers) > 0 || len(s.Tags) > 0
+}
+
+// VMSelector parses VMLabels. An empty selector matches every VM.
+func (s AccessScope) VMSelector() (LabelSelector, error) {
+	return ParseLabelSelector(s.VMLabels)
+}
+
+// User is a named API identity. Its tokens never exceed its role and scope.
+type User struct {
+	Name      string      `json:"name"`
+	Role      Role        `json:"role"`
+	Scope     AccessScope `json:"scope"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// APIToken describes a bearer token issued to a user. The secret itself is only
+// returned once, when the token is created.
+type APIToken struct {
+	ID          string      `json:"id"`
+	User        string      `json:"user"`
+	Description string      `json:"description,omitempty"`
+	Role        Role        `json:"role"`
+	Scope       AccessScope `json:"scope"`
+	Prefix      string      `json:"prefix"` // First characters of the secret, to recognise it
+	CreatedAt   time.Time   `json:"created_at"`
+	ExpiresAt   *time.Time  `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time  `json:"last_used_at,omitempty"`
+}
+
+// CreateUserRequest is the body of POST /api/users
+type CreateUserRequest struct {
+	Name  string      `json:"name"`
+	Role  Role        `json:"role"`
+	Scope AccessScope `json:"scope"`
+}
+
+// CreateTokenRequest is the body of POST /api/tokens. Role defaults to the user's
+// role and Scope to the user's scope.
+type CreateTokenRequest struct {
+	User        string      `json:"user"`
+	Description string      `json:"description,omitempty"`
+	Role        Role        `json:"role,omitempty"`
+	Scope       AccessScope `json:"scope"`
+	ExpiresIn   string      `json:"expiresIn,omitempty"` // Go duration such as 720h; empty never expires
+}
+
+// CreateTokenResponse carries the new token and its secret
+type CreateTokenResponse struct {
+	Token  APIToken `json:"token"`
+	Secret string   `json:"secret"`
+}
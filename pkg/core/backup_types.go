@@ -0,0 +1,63 @@
+package core
+
+import "time"
+
+// Backup kinds
+const (
+	BackupFull        = "full"        // Every disk copied in full
+	BackupIncremental = "incremental" // Only blocks changed since the parent backup
+)
+
+// BackupFile is a file in a backup with its checksum
+type BackupFile struct {
+	File      string `json:"file"` // Relative to the backup directory
+	SHA256    string `json:"sha256"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// BackupDisk is the copy of one VM disk in a backup
+type BackupDisk struct {
+	Target           string `json:"target"` // Device, e.g. vda
+	SourcePath       string `json:"source_path"`
+	SourceFormat     string `json:"source_format"`
+	VirtualSizeBytes uint64 `json:"virtual_size_bytes"`
+	BackupFile
+}
+
+// Backup describes one backup of a VM in a backup repository. Incremental
+// backups store qcow2 images backed by their parent's, so restoring one
+// needs the whole chain back to a full backup.
+type Backup struct {
+	ID         string       `json:"id"`
+	VMName     string       `json:"vm_name"`
+	VMUUID     string       `json:"vm_uuid"`
+	Kind       string       `json:"kind"`                 // "full" or "incremental"
+	Parent     string       `json:"parent,omitempty"`     // Backup ID an incremental backup builds on
+	Checkpoint string       `json:"checkpoint,omitempty"` // libvirt checkpoint tracking changes since this backup
+	Live       bool         `json:"live"`                 // Taken while the VM was running
+	Compressed bool         `json:"compressed"`
+	CreatedAt  time.Time    `json:"created_at"`
+	DurationMs int64        `json:"duration_ms"`
+	SizeBytes  int64        `json:"size_bytes"` // Total size of the backup's files
+	DomainXML  BackupFile   `json:"domain_xml"`
+	Disks      []BackupDisk `json:"disks"`
+	Path       string       `json:"path"` // Backup directory, filled in when read
+}
+
+// CreateBackupRequest is the body for backing up a VM
+type CreateBackupRequest struct {
+	Repository  string `json:"repository,omitempty"` // Backup directory; the API always uses the configured one
+	Incremental bool   `json:"incremental"`          // Copy only blocks changed since the VM's latest backup
+	Compress    *bool  `json:"compress,omitempty"`   // qcow2 compression, on by default
+}
+
+// RestoreBackupRequest describes how to restore a backup into a new domain
+type RestoreBackupRequest struct {
+	Repository string `json:"repository,omitempty"` // Backup directory; the API always uses the configured one
+	VM         string `json:"vm"`                   // UUID or name of the backed-up VM
+	BackupID   string `json:"backupId"`             // Defaults to the VM's latest backup
+	Name       string `json:"name,omitempty"`       // Defaults to the backed-up VM's name
+	NewUUID    bool   `json:"newUUID"`              // Give the restored VM a new UUID and MAC addresses
+	Pool       string `json:"pool,omitempty"`       // Storage pool for the restored disks (default flint-image-library)
+	Start      bool   `json:"start"`
+}
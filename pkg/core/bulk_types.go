@@ -0,0 +1,105 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Actions POST /api/vms/actions can run on many VMs at once
+var BulkVMActions = []string{"start", "stop", "reboot", "reset", "force-stop", "pause", "resume", "delete", "snapshot"}
+
+// How many VMs a bulk action works on at a time
+const (
+	DefaultBulkParallelism = 4
+	MaxBulkParallelism     = 32
+)
+
+// Outcomes of a bulk action on one VM
+const (
+	BulkSucceeded = "succeeded"
+	BulkUnchanged = "unchanged" // The VM was already in the state the action leads to
+	BulkFailed    = "failed"
+	BulkSkipped   = "skipped" // The action was cancelled before it reached the VM
+)
+
+// BulkVMActionRequest runs one action on a list of VMs, or on the VMs whose
+// labels match Selector and whose names match the glob Name. Something must
+// select the VMs; use name "*" to act on every VM.
+type BulkVMActionRequest struct {
+	Action      string                 `json:"action"`
+	UUIDs       []string               `json:"uuids,omitempty"`
+	Selector    string                 `json:"selector,omitempty"` // Label selector, e.g. env=dev,tier!=db
+	Name        string                 `json:"name,omitempty"`     // Name glob, e.g. web-*
+	Parallelism int                    `json:"parallelism,omitempty"`
+	DeleteDisks bool                   `json:"deleteDisks,omitempty"` // With delete
+	Snapshot    *CreateSnapshotRequest `json:"snapshot,omitempty"`    // With snapshot
+}
+
+// Validate checks the action, how VMs are selected and the action's options
+func (r BulkVMActionRequest) Validate() error {
+	if !slices.Contains(BulkVMActions, r.Action) {
+		return fmt.Errorf("unknown action %q (use %s)", r.Action, strings.Join(BulkVMActions, ", "))
+	}
+	if len(r.UUIDs) > 0 && (r.Selector != "" || r.Name != "") {
+		return errors.New("give either uuids or a selector and name, not both")
+	}
+	if len(r.UUIDs) == 0 && strings.TrimSpace(r.Selector) == "" && r.Name == "" {
+		return errors.New("select VMs with uuids, a label selector or a name pattern")
+	}
+	if _, err := ParseLabelSelector(r.Selector); err != nil {
+		return err
+	}
+	if _, err := path.Match(r.Name, ""); err != nil {
+		return fmt.Errorf("invalid name pattern %q: %w", r.Name, err)
+	}
+	if r.Parallelism < 0 || r.Parallelism > MaxBulkParallelism {
+		return fmt.Errorf("parallelism must be between 1 and %d", MaxBulkParallelism)
+	}
+	if r.DeleteDisks && r.Action != "delete" {
+		return errors.New("deleteDisks only applies to delete")
+	}
+	if r.Action == "snapshot" {
+		if r.Snapshot == nil {
+			return errors.New("snapshot action requires snapshot options")
+		}
+		if err := r.Snapshot.Validate(); err != nil {
+			return err
+		}
+	} else if r.Snapshot != nil {
+		return errors.New("snapshot options only apply to snapshot")
+	}
+	return nil
+}
+
+// Selects reports whether the request's selector and name pattern match a VM.
+// It does not look at UUIDs.
+func (r BulkVMActionRequest) Selects(vm VM_Summary) bool {
+	if r.Name != "" {
+		if ok, _ := path.Match(r.Name, vm.Name); !ok {
+			return false
+		}
+	}
+	selector, err := ParseLabelSelector(r.Selector)
+	return err == nil && selector.Matches(vm.Labels)
+}
+
+// BulkVMResult is the outcome of a bulk action on one VM
+type BulkVMResult struct {
+	UUID   string `json:"uuid"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status"` // succeeded, unchanged, failed or skipped
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkVMActionResult holds the outcome for each selected VM, in name order
+type BulkVMActionResult struct {
+	Action    string         `json:"action"`
+	Results   []BulkVMResult `json:"results"`
+	Succeeded int            `json:"succeeded"`
+	Unchanged int            `json:"unchanged"`
+	Failed    int            `json:"failed"`
+	Skipped   int            `json:"skipped"`
+}
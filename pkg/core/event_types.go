@@ -0,0 +1,22 @@
+package core
+
+import "time"
+
+// Resource types reported by Event
+const (
+	EventResourceDomain      = "domain"
+	EventResourceNetwork     = "network"
+	EventResourceStoragePool = "storage_pool"
+)
+
+// Event is a libvirt lifecycle notification, fanned out through /api/events
+type Event struct {
+	Time         time.Time `json:"time"`
+	ServerID     string    `json:"server_id,omitempty"` // "" for the local connection
+	ResourceType string    `json:"resource_type"`       // "domain", "network", "storage_pool"
+	Kind         string    `json:"kind"`                // "lifecycle", "reboot", "block_job", "agent_lifecycle"
+	Name         string    `json:"name"`
+	UUID         string    `json:"uuid"`
+	Event        string    `json:"event"` // "started", "stopped", "crashed", "completed", ...
+	Detail       string    `json:"detail,omitempty"`
+}
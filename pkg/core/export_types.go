@@ -0,0 +1,44 @@
+package core
+
+import "time"
+
+// VM export formats
+const (
+	ExportFormatOVA = "ova" // OVF descriptor and streamOptimized VMDK disks, for VirtualBox and VMware
+	ExportFormatTar = "tar" // Flint's domain XML and qcow2 disks, plus an OVF descriptor
+)
+
+// ExportVMRequest is the body for exporting a VM
+type ExportVMRequest struct {
+	Format string `json:"format"`         // "ova" (default) or "tar"
+	Path   string `json:"path,omitempty"` // Archive to write; the API always writes to the export directory
+}
+
+// VMExport describes an archive written by a VM export
+type VMExport struct {
+	VMName     string    `json:"vm_name"`
+	VMUUID     string    `json:"vm_uuid"`
+	Format     string    `json:"format"`
+	File       string    `json:"file"` // Archive name, e.g. web-01.ova
+	Path       string    `json:"path"`
+	SizeBytes  int64     `json:"size_bytes"`
+	SHA256     string    `json:"sha256"`
+	Disks      int       `json:"disks"`
+	CreatedAt  time.Time `json:"created_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// ExportFile is a file in the export directory
+type ExportFile struct {
+	Name       string    `json:"name"`
+	SizeBytes  int64     `json:"size_bytes"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// ImportVMRequest describes how to import a VM from an OVA, OVF or tar archive
+type ImportVMRequest struct {
+	File    string `json:"file"`              // File in the export directory (API) or a path (CLI)
+	Name    string `json:"name,omitempty"`    // Defaults to the name in the package
+	Network string `json:"network,omitempty"` // Network for the VM's interfaces when translating OVF (default "default")
+	Start   bool   `json:"start"`
+}
@@ -0,0 +1,51 @@
+package core
+
+// FleetVM is a VM summary tagged with the server it runs on
+type FleetVM struct {
+	ServerID   string `json:"server_id"`
+	ServerName string `json:"server_name"`
+	VM_Summary
+}
+
+// FleetStoragePool is a storage pool tagged with the server it belongs to
+type FleetStoragePool struct {
+	ServerID   string `json:"server_id"`
+	ServerName string `json:"server_name"`
+	StoragePool
+}
+
+// FleetNetwork is a virtual network tagged with the server it belongs to
+type FleetNetwork struct {
+	ServerID   string `json:"server_id"`
+	ServerName string `json:"server_name"`
+	Network
+}
+
+// FleetError describes a server that could not be queried during a fleet-wide request
+type FleetError struct {
+	ServerID   string `json:"server_id"`
+	ServerName string `json:"server_name"`
+	Error      string `json:"error"`
+	TimedOut   bool   `json:"timed_out"`
+}
+
+// FleetVMList is the aggregated response for fleet-wide VM listings
+type FleetVMList struct {
+	Items   []FleetVM    `json:"items"`
+	Errors  []FleetError `json:"errors"`
+	Servers int          `json:"servers"`
+}
+
+// FleetStoragePoolList is the aggregated response for fleet-wide storage pool listings
+type FleetStoragePoolList struct {
+	Items   []FleetStoragePool `json:"items"`
+	Errors  []FleetError       `json:"errors"`
+	Servers int                `json:"servers"`
+}
+
+// FleetNetworkList is the aggregated response for fleet-wide network listings
+type FleetNetworkList struct {
+	Items   []FleetNetwork `json:"items"`
+	Errors  []FleetError   `json:"errors"`
+	Servers int            `json:"servers"`
+}
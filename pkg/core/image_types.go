@@ -0,0 +1,49 @@
+package core
+
+// Operations for ConvertImageRequest
+const (
+	ImageOpConvert  = "convert"  // Convert a raw, VMDK, VDI, VHDX or VHD image to qcow2
+	ImageOpCompress = "compress" // Rewrite as compressed qcow2
+	ImageOpSparsify = "sparsify" // Rewrite in the same format without zeroed blocks
+)
+
+// ImageInfo is what qemu-img reports about an image in the library
+type ImageInfo struct {
+	Format       string        `json:"format"`
+	VirtualSizeB uint64        `json:"virtual_size_b"`
+	ActualSizeB  uint64        `json:"actual_size_b"` // Space used on disk
+	ClusterSize  uint64        `json:"cluster_size,omitempty"`
+	Compat       string        `json:"compat,omitempty"`      // qcow2 version, e.g. 1.1
+	Compression  string        `json:"compression,omitempty"` // qcow2 compression type
+	Dirty        bool          `json:"dirty"`                 // Not closed cleanly; qemu-img check can repair it
+	Corrupt      bool          `json:"corrupt"`
+	BackingChain []BackingFile `json:"backing_chain,omitempty"` // Backing files, nearest first
+}
+
+// BackingFile is one image in a backing chain
+type BackingFile struct {
+	Path   string `json:"path"`
+	Format string `json:"format,omitempty"`
+}
+
+// ImageUser needs an image: a volume using it as its backing file, or a VM
+// with a disk on the image or on such a volume
+type ImageUser struct {
+	Type string `json:"type"` // "volume" or "vm"
+	Name string `json:"name"`
+	UUID string `json:"uuid,omitempty"` // VMs only
+	Path string `json:"path"`           // The volume, or the VM disk that needs the image
+}
+
+// ImageDetails is an image with its qemu-img info and the volumes and VMs that need it
+type ImageDetails struct {
+	Image
+	Info   *ImageInfo  `json:"info,omitempty"` // Missing for images on remote hosts
+	UsedBy []ImageUser `json:"used_by"`
+}
+
+// ConvertImageRequest is the body for converting an image in the library
+type ConvertImageRequest struct {
+	Operation string `json:"operation"`      // convert, compress or sparsify
+	Name      string `json:"name,omitempty"` // Name of the result; defaults to the image itself, or its name with a .qcow2 extension when the format changes
+}
@@ -0,0 +1,35 @@
+package core
+
+import "time"
+
+// Image formats accepted for upload, detected from the first bytes of the data
+const (
+	ImageFormatQCOW2 = "qcow2"
+	ImageFormatRaw   = "raw"
+	ImageFormatISO   = "iso"
+)
+
+// CreateImageUploadRequest is the body for starting a resumable image upload
+type CreateImageUploadRequest struct {
+	Name      string `json:"name"`             // Name of the image in the library, e.g. debian-12.qcow2
+	SizeBytes int64  `json:"sizeBytes"`        // Total size; required for resumable uploads
+	SHA256    string `json:"sha256,omitempty"` // Verified once all data has arrived
+	Format    string `json:"format,omitempty"` // Expected format; the upload is refused if the data differs
+}
+
+// ImageUpload is a resumable upload into the image library. Chunks are sent
+// in order with PATCH requests starting at Offset.
+type ImageUpload struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	ServerID  string    `json:"server_id,omitempty"`
+	SizeBytes int64     `json:"size_bytes"`
+	Offset    int64     `json:"offset"` // Bytes received so far
+	SHA256    string    `json:"sha256,omitempty"`
+	Format    string    `json:"format,omitempty"` // Detected once the first chunk arrives
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"` // Idle uploads are discarded after this
+	Completed bool      `json:"completed"`
+	Image     *Image    `json:"image,omitempty"` // Set once the upload is complete
+}
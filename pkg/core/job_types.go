@@ -0,0 +1,35 @@
+package core
+
+import "time"
+
+// JobState is the lifecycle state of a background job
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// IsFinal reports whether the job has stopped and will not change again
+func (s JobState) IsFinal() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// Job tracks a slow operation such as VM creation or an image download
+type Job struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`             // e.g. "vm.create", "image.download"
+	Target     string      `json:"target,omitempty"` // VM name/UUID, image ID, volume, ...
+	ServerID   string      `json:"server_id,omitempty"`
+	State      JobState    `json:"state"`
+	Progress   float64     `json:"progress"` // 0-100
+	Message    string      `json:"message,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Limits on VM labels and descriptions
+const (
+	MaxLabelKeyLength      = 63
+	MaxLabelValueLength    = 255
+	MaxVMDescriptionLength = 4096
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
+// UpdateVMLabelsRequest is the body of PATCH /api/vms/{uuid}/labels. Labels are
+// merged into the VM's and a null value removes one; with Replace the VM keeps
+// only the labels given. A nil Description leaves the description alone.
+type UpdateVMLabelsRequest struct {
+	Labels      map[string]*string `json:"labels,omitempty"`
+	Replace     bool               `json:"replace,omitempty"`
+	Description *string            `json:"description,omitempty"`
+}
+
+// Validate checks label keys, values and the description
+func (r UpdateVMLabelsRequest) Validate() error {
+	for key, value := range r.Labels {
+		if err := ValidateLabelKey(key); err != nil {
+			return err
+		}
+		if value != nil {
+			if err := ValidateLabelValue(*value); err != nil {
+				return fmt.Errorf("label %s: %w", key, err)
+			}
+		}
+	}
+	if r.Description != nil && len(*r.Description) > MaxVMDescriptionLength {
+		return fmt.Errorf("description is longer than %d bytes", MaxVMDescriptionLength)
+	}
+	return nil
+}
+
+// Apply returns the labels that result from applying the request to current,
+// which is not modified
+func (r UpdateVMLabelsRequest) Apply(current map[string]string) map[string]string {
+	labels := make(map[string]string, len(current)+len(r.Labels))
+	if !r.Replace {
+		for key, value := range current {
+			labels[key] = value
+		}
+	}
+	for key, value := range r.Labels {
+		if value == nil {
+			delete(labels, key)
+		} else {
+			labels[key] = *value
+		}
+	}
+	return labels
+}
+
+// ValidateLabelKey checks a label key: letters, digits, '.', '_', '-' and '/',
+// starting and ending with a letter or digit
+func ValidateLabelKey(key string) error {
+	if len(key) > MaxLabelKeyLength {
+		return fmt.Errorf("label key %q is longer than %d characters", key, MaxLabelKeyLength)
+	}
+	if !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q: use letters, digits, '.', '_', '-' and '/', starting and ending with a letter or digit", key)
+	}
+	return nil
+}
+
+// ValidateLabelValue checks a label value: any printable text up to the length limit
+func ValidateLabelValue(value string) error {
+	if len(value) > MaxLabelValueLength {
+		return fmt.Errorf("value is longer than %d characters", MaxLabelValueLength)
+	}
+	if strings.IndexFunc(value, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		return fmt.Errorf("value must not contain control characters")
+	}
+	return nil
+}
+
+// LabelRequirement is one term of a LabelSelector
+type LabelRequirement struct {
+	Key      string
+	Operator string // "=", "!=", "exists" or "!exists"
+	Value    string
+}
+
+// LabelSelector selects VMs by their labels. Its text form is a comma-separated
+// list of terms that must all hold: env=prod, env!=prod, env (has the label)
+// and !env (does not have it).
+type LabelSelector []LabelRequirement
+
+// ParseLabelSelector parses the text form of a selector. An empty string
+// selects every VM.
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var selector LabelSelector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var req LabelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			key, value, _ := strings.Cut(term, "!=")
+			req = LabelRequirement{Key: key, Operator: "!=", Value: value}
+		case strings.Contains(term, "="):
+			key, value, _ := strings.Cut(term, "=")
+			req = LabelRequirement{Key: key, Operator: "=", Value: strings.TrimPrefix(value, "=")}
+		case strings.HasPrefix(term, "!"):
+			req = LabelRequirement{Key: term[1:], Operator: "!exists"}
+		default:
+			req = LabelRequirement{Key: term, Operator: "exists"}
+		}
+		req.Key, req.Value = strings.TrimSpace(req.Key), strings.TrimSpace(req.Value)
+		if err := ValidateLabelKey(req.Key); err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %w", term, err)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// Matches reports whether labels meet every requirement
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, ok := labels[req.Key]
+		switch req.Operator {
+		case "=":
+			if !ok || value != req.Value {
+				return false
+			}
+		case "!=":
+			if ok && value == req.Value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// String returns the selector's text form
+func (s LabelSelector) String() string {
+	terms := make([]string, len(s))
+	for i, req := range s {
+		switch req.Operator {
+		case "exists":
+			terms[i] = req.Key
+		case "!exists":
+			terms[i] = "!" + req.Key
+		default:
+			terms[i] = req.Key + req.Operator + req.Value
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// FormatLabels renders labels as key=value pairs sorted by key, e.g. for tables
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + labels[key]
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,80 @@
+package core
+
+// VMCounters are the cumulative counters of a domain at one instant. The metrics
+// sampler turns consecutive readings into rates. Inactive domains only carry
+// their state and configured resources.
+type VMCounters struct {
+	UUID          string              `json:"uuid"`
+	Name          string              `json:"name"`
+	State         string              `json:"state"`
+	Active        bool                `json:"active"`
+	Timestamp     int64               `json:"timestamp_ns"` // Unix nanoseconds
+	VCPUs         uint                `json:"vcpus"`
+	CPUTimeNs     uint64              `json:"cpu_time_ns"`
+	MemoryUsedKB  uint64              `json:"memory_used_kb"`
+	MemoryTotalKB uint64              `json:"memory_total_kb"`
+	Disks         []DiskCounters      `json:"disks"`
+	Interfaces    []InterfaceCounters `json:"interfaces"`
+}
+
+// DiskCounters are cumulative block I/O counters for one disk
+type DiskCounters struct {
+	Device     string `json:"device"` // Target device, e.g. vda
+	ReadBytes  uint64 `json:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes"`
+	ReadReqs   uint64 `json:"read_reqs"`
+	WriteReqs  uint64 `json:"write_reqs"`
+}
+
+// InterfaceCounters are cumulative traffic counters for one network interface
+type InterfaceCounters struct {
+	Device    string `json:"device"` // Host-side device, e.g. vnet0
+	MAC       string `json:"mac"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	TxPackets uint64 `json:"tx_packets"`
+}
+
+// VMMetricsPoint is one sample of a VM's resource usage expressed as rates over
+// the preceding sampling interval
+type VMMetricsPoint struct {
+	Timestamp     int64          `json:"timestamp"`   // Unix seconds
+	CPUPercent    float64        `json:"cpu_percent"` // Share of the VM's vCPUs, 0-100
+	MemoryUsedKB  uint64         `json:"memory_used_kb"`
+	MemoryTotalKB uint64         `json:"memory_total_kb"`
+	DiskReadBps   float64        `json:"disk_read_bps"`
+	DiskWriteBps  float64        `json:"disk_write_bps"`
+	NetRxBps      float64        `json:"net_rx_bps"`
+	NetTxBps      float64        `json:"net_tx_bps"`
+	Disks         []DiskRates    `json:"disks,omitempty"`
+	Interfaces    []NetworkRates `json:"interfaces,omitempty"`
+}
+
+// DiskRates is the I/O throughput of one disk
+type DiskRates struct {
+	Device    string  `json:"device"`
+	ReadBps   float64 `json:"read_bps"`
+	WriteBps  float64 `json:"write_bps"`
+	ReadIOPS  float64 `json:"read_iops"`
+	WriteIOPS float64 `json:"write_iops"`
+}
+
+// NetworkRates is the traffic of one network interface
+type NetworkRates struct {
+	Device string  `json:"device"`
+	MAC    string  `json:"mac,omitempty"`
+	RxBps  float64 `json:"rx_bps"`
+	TxBps  float64 `json:"tx_bps"`
+	RxPps  float64 `json:"rx_pps"`
+	TxPps  float64 `json:"tx_pps"`
+}
+
+// VMMetricsSeries is the response of /api/vms/{uuid}/metrics
+type VMMetricsSeries struct {
+	UUID   string           `json:"uuid"`
+	From   int64            `json:"from"`
+	To     int64            `json:"to"`
+	Step   int64            `json:"step"` // Seconds per point; 0 means raw samples
+	Points []VMMetricsPoint `json:"points"`
+}
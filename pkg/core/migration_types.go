@@ -0,0 +1,44 @@
+package core
+
+// Storage copy modes for migrating VMs between hosts without shared storage
+const (
+	MigrateCopyStorageNone        = ""            // Disks are on storage both hosts can reach
+	MigrateCopyStorageAll         = "all"         // Copy every disk to the destination
+	MigrateCopyStorageIncremental = "incremental" // Copy only the top image; backing files must already exist on the destination
+)
+
+// MigrateVMRequest describes how to move a VM to another registered server.
+// Running VMs are migrated live; shut-off VMs only have their definition moved.
+type MigrateVMRequest struct {
+	TargetServer string `json:"targetServer"`           // Registered server ID or name
+	Offline      bool   `json:"offline"`                // Move only the definition; the VM must be shut off
+	CopyStorage  string `json:"copyStorage,omitempty"`  // "", "all" or "incremental"
+	BandwidthMiB uint64 `json:"bandwidthMiB,omitempty"` // Migration bandwidth limit in MiB/s (0 = unlimited)
+	AutoConverge bool   `json:"autoConverge"`           // Throttle guest vCPUs if memory is dirtied faster than it is copied
+	PostCopy     bool   `json:"postCopy"`               // Switch to post-copy if pre-copy does not converge after the first pass
+}
+
+// MigrationProgress is a snapshot of a running migration taken from the domain job info
+type MigrationProgress struct {
+	Phase           string  `json:"phase"` // "storage", "memory" or "postcopy"
+	Percent         float64 `json:"percent"`
+	DataTotal       uint64  `json:"data_total"`
+	DataProcessed   uint64  `json:"data_processed"`
+	DataRemaining   uint64  `json:"data_remaining"`
+	MemIteration    uint64  `json:"mem_iteration,omitempty"`
+	MemDirtyRate    uint64  `json:"mem_dirty_rate,omitempty"` // Pages per second
+	BytesPerSecond  uint64  `json:"bytes_per_second,omitempty"`
+	TimeElapsedMs   uint64  `json:"time_elapsed_ms"`
+	TimeRemainingMs uint64  `json:"time_remaining_ms,omitempty"`
+}
+
+// MigrationResult describes a completed migration
+type MigrationResult struct {
+	VM              VM_Detailed `json:"vm"`
+	SourceServer    string      `json:"source_server"` // "" for the local host
+	TargetServer    string      `json:"target_server"`
+	Live            bool        `json:"live"`
+	PostCopy        bool        `json:"post_copy"` // Whether the migration actually switched to post-copy
+	DurationMs      int64       `json:"duration_ms"`
+	DataTransferred uint64      `json:"data_transferred,omitempty"`
+}
@@ -0,0 +1,61 @@
+package core
+
+import "time"
+
+// SnapshotRetention decides which of a policy's snapshots survive pruning.
+// A snapshot is kept if any rule keeps it; all zero keeps every snapshot.
+type SnapshotRetention struct {
+	KeepLast   int `json:"keep_last,omitempty"`   // Newest N snapshots
+	KeepDaily  int `json:"keep_daily,omitempty"`  // Newest snapshot of each of the last N days that have one
+	KeepWeekly int `json:"keep_weekly,omitempty"` // Newest snapshot of each of the last N ISO weeks that have one
+}
+
+// IsEmpty reports whether the retention keeps every snapshot
+func (r SnapshotRetention) IsEmpty() bool {
+	return r.KeepLast <= 0 && r.KeepDaily <= 0 && r.KeepWeekly <= 0
+}
+
+// SnapshotPolicy takes snapshots of a set of VMs on a cron schedule and prunes
+// the ones it created earlier
+type SnapshotPolicy struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Enabled     bool              `json:"enabled"`
+	Schedule    string            `json:"schedule"`            // Cron expression, e.g. "0 2 * * *" or "@daily"
+	ServerID    string            `json:"server_id,omitempty"` // Registered server ID or name ("" for the local host)
+	Tags        []string          `json:"tags,omitempty"`      // Server tags, not VM labels: apply to every registered server with one of them instead
+	VMs         []string          `json:"vms,omitempty"`       // VM names or UUIDs (empty for every VM on the servers)
+	DiskOnly    bool              `json:"disk_only,omitempty"`
+	Quiesce     bool              `json:"quiesce,omitempty"`
+	NamePrefix  string            `json:"name_prefix"` // Snapshots are named <prefix>-<YYYYMMDD-HHMMSS>
+	Retention   SnapshotRetention `json:"retention"`
+	CreatedAt   time.Time         `json:"created_at"`
+	LastRun     *time.Time        `json:"last_run,omitempty"`
+	LastStatus  string            `json:"last_status,omitempty"` // "Success" or "Error"
+	LastMessage string            `json:"last_message,omitempty"`
+	NextRun     *time.Time        `json:"next_run,omitempty"` // Filled in by the scheduler, not stored
+}
+
+// SnapshotPolicyRequest is the body for creating or replacing a snapshot policy
+type SnapshotPolicyRequest struct {
+	Name       string            `json:"name"`
+	Enabled    *bool             `json:"enabled,omitempty"` // Defaults to true
+	Schedule   string            `json:"schedule"`
+	ServerID   string            `json:"server_id,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	VMs        []string          `json:"vms,omitempty"`
+	DiskOnly   bool              `json:"disk_only,omitempty"`
+	Quiesce    bool              `json:"quiesce,omitempty"`
+	NamePrefix string            `json:"name_prefix,omitempty"` // Defaults to "auto-<name>"
+	Retention  SnapshotRetention `json:"retention"`
+}
+
+// SnapshotPolicyRun is the outcome of running a policy once
+type SnapshotPolicyRun struct {
+	PolicyID  string    `json:"policy_id"`
+	StartedAt time.Time `json:"started_at"`
+	Status    string    `json:"status"` // "Success" or "Error"
+	Created   []string  `json:"created"`
+	Pruned    []string  `json:"pruned"`
+	Errors    []string  `json:"errors,omitempty"`
+}
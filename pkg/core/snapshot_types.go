@@ -0,0 +1,93 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Snapshot kinds
+const (
+	SnapshotInternal = "internal" // Stored inside the qcow2 disk images
+	SnapshotExternal = "external" // New qcow2 overlays on top of the previous disk images
+)
+
+// SnapshotDisk is how one disk takes part in a snapshot
+type SnapshotDisk struct {
+	Name     string `json:"name"`           // Target device, e.g. vda
+	Snapshot string `json:"snapshot"`       // "internal", "external" or "no"
+	File     string `json:"file,omitempty"` // Overlay path of external snapshots
+}
+
+// Validate checks the snapshot options are consistent
+func (r CreateSnapshotRequest) Validate() error {
+	switch {
+	case strings.TrimSpace(r.Name) == "":
+		return errors.New("snapshot name is required")
+	case strings.ContainsAny(r.Name, "/\x00"):
+		return errors.New("snapshot name must not contain '/'")
+	case r.DiskOnly && r.Memory:
+		return errors.New("diskOnly and memory are mutually exclusive")
+	case r.Live && !r.Memory:
+		return errors.New("live requires memory")
+	case r.Quiesce && !r.DiskOnly:
+		return errors.New("quiesce requires diskOnly")
+	}
+	return nil
+}
+
+// SnapshotNode is a snapshot and the snapshots taken on top of it
+type SnapshotNode struct {
+	Snapshot
+	Children []SnapshotNode `json:"children"`
+}
+
+// SnapshotTree is a VM's snapshots arranged by parent
+type SnapshotTree struct {
+	Current string         `json:"current,omitempty"` // Name of the current snapshot
+	Roots   []SnapshotNode `json:"roots"`
+}
+
+// BuildSnapshotTree arranges snapshots by parent, oldest first at every level.
+// Snapshots whose parent is missing become roots.
+func BuildSnapshotTree(snapshots []Snapshot) SnapshotTree {
+	sorted := append([]Snapshot(nil), snapshots...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].CreationTS != sorted[j].CreationTS {
+			return sorted[i].CreationTS < sorted[j].CreationTS
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	known := make(map[string]bool, len(sorted))
+	children := make(map[string][]Snapshot)
+	tree := SnapshotTree{Roots: []SnapshotNode{}}
+	for _, snap := range sorted {
+		known[snap.Name] = true
+		if snap.Current {
+			tree.Current = snap.Name
+		}
+	}
+
+	var roots []Snapshot
+	for _, snap := range sorted {
+		if snap.Parent != "" && known[snap.Parent] && snap.Parent != snap.Name {
+			children[snap.Parent] = append(children[snap.Parent], snap)
+		} else {
+			roots = append(roots, snap)
+		}
+	}
+
+	var build func(snap Snapshot) SnapshotNode
+	build = func(snap Snapshot) SnapshotNode {
+		node := SnapshotNode{Snapshot: snap, Children: []SnapshotNode{}}
+		for _, child := range children[snap.Name] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+	for _, root := range roots {
+		tree.Roots = append(tree.Roots, build(root))
+	}
+	return tree
+}
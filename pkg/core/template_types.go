@@ -0,0 +1,41 @@
+package core
+
+import "time"
+
+// VMTemplate describes a VM that new machines can be cloned from
+type VMTemplate struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ServerID    string     `json:"server_id,omitempty"` // Registered server the source VM lives on ("" for local)
+	SourceUUID  string     `json:"source_uuid"`         // UUID of the source domain
+	SourceVM    string     `json:"source_vm"`           // Name of the source domain
+	VCPUs       int        `json:"vcpus"`
+	MemoryMB    uint64     `json:"memory_mb"`
+	DiskSizeGB  uint64     `json:"disk_size_gb"` // Combined capacity of all disks
+	Disks       []Disk     `json:"disks"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsed    *time.Time `json:"last_used,omitempty"`
+}
+
+// CreateVMTemplateRequest registers an existing VM as a template
+type CreateVMTemplateRequest struct {
+	VMID        string `json:"vm_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CloneVMRequest describes how to clone a VM
+type CloneVMRequest struct {
+	Name          string           `json:"name"`
+	Linked        bool             `json:"linked"`                // qcow2 overlays backed by the source disks instead of full copies
+	TargetPool    string           `json:"target_pool,omitempty"` // defaults to the pool of each source disk
+	StartOnCreate bool             `json:"start_on_create"`
+	CloudInit     *CloudInitConfig `json:"cloud_init,omitempty"` // regenerates the cloud-init seed for the clone
+}
+
+// CreateVMFromTemplateRequest clones a registered template into a new VM
+type CreateVMFromTemplateRequest struct {
+	TemplateID string `json:"template_id"`
+	CloneVMRequest
+}
@@ -29,15 +29,17 @@ type HostResources struct {
 
 // VM_Summary is the light info for lists.
 type VM_Summary struct {
-	Name        string   `json:"name"`
-	UUID        string   `json:"uuid"`
-	State       string   `json:"state"`
-	MemoryKB    uint64   `json:"memory_kb"`
-	VCPUs       int      `json:"vcpus"`
-	CPUPercent  float64  `json:"cpu_percent"` // computed over sample window
-	UptimeSec   uint64   `json:"uptime_sec"`
-	OSInfo      string   `json:"os_info"`
-	IPAddresses []string `json:"ip_addresses"`
+	Name        string            `json:"name"`
+	UUID        string            `json:"uuid"`
+	State       string            `json:"state"`
+	MemoryKB    uint64            `json:"memory_kb"`
+	VCPUs       int               `json:"vcpus"`
+	CPUPercent  float64           `json:"cpu_percent"` // computed over sample window
+	UptimeSec   uint64            `json:"uptime_sec"`
+	OSInfo      string            `json:"os_info"`
+	IPAddresses []string          `json:"ip_addresses"`
+	Labels      map[string]string `json:"labels,omitempty"`      // Stored in the domain's Flint metadata
+	Description string            `json:"description,omitempty"` // Stored in the domain's Flint metadata
 }
 
 // Disk / NIC small models for detailed view:
@@ -45,6 +47,8 @@ type Disk struct {
 	SourcePath string `json:"source_path"`
 	TargetDev  string `json:"target_dev"`
 	Device     string `json:"device"`
+	Format     string `json:"format,omitempty"`
+	CapacityB  uint64 `json:"capacity_b,omitempty"`
 }
 
 type NIC struct {
@@ -110,6 +114,50 @@ type VMCreationConfig struct {
 	DiskSizeGB      uint64
 	EnableCloudInit bool
 	PXEConfig       *PXEConfig       `json:"pxeConfig,omitempty"` // PXE boot configuration
+	Disks           []VMDiskConfig   `json:"disks,omitempty"`       // Data disks after the OS disk
+	NICs            []VMNICConfig    `json:"nics,omitempty"`        // NICs after the one on NetworkName
+	CPU             *VMCPUConfig     `json:"cpu,omitempty"`
+	MachineType     string           `json:"machineType,omitempty"` // "pc" (default) or "q35"
+	Firmware        string           `json:"firmware,omitempty"`    // "bios" (default) or "uefi"
+	SecureBoot      bool             `json:"secureBoot,omitempty"`  // UEFI secure boot, needs q35
+	TPM             bool             `json:"tpm,omitempty"`         // Emulated TPM 2.0
+	Graphics        string           `json:"graphics,omitempty"`    // "vnc" (default), "spice" or "none"
+}
+
+// VMDiskConfig is a data disk created with a VM. Disk n of VM x is the qcow2
+// volume x-disk-n.qcow2, counting from 1.
+type VMDiskConfig struct {
+	Pool   string `json:"pool,omitempty"`  // Default flint-image-library
+	SizeGB uint64 `json:"sizeGB"`
+	Bus    string `json:"bus,omitempty"`   // "virtio" (default), "scsi" or "sata"
+	Cache  string `json:"cache,omitempty"` // "none", "writeback", "writethrough", "directsync" or "unsafe"
+	IO     string `json:"io,omitempty"`    // "native", "threads" or "io_uring"
+}
+
+// VMNICConfig is a NIC on either a libvirt network or a host bridge
+type VMNICConfig struct {
+	Network  string `json:"network,omitempty"`
+	Bridge   string `json:"bridge,omitempty"`
+	Model    string `json:"model,omitempty"`    // "virtio" (default), "e1000", "e1000e" or "rtl8139"
+	MAC      string `json:"mac,omitempty"`      // Assigned by libvirt when empty
+	NWFilter string `json:"nwfilter,omitempty"` // Network filter applied to the NIC
+}
+
+// VMCPUConfig is the CPU model and topology. Sockets, cores and threads
+// default to 1, and their product must equal the VM's vCPUs.
+type VMCPUConfig struct {
+	Mode    string `json:"mode,omitempty"` // "host-passthrough" or "host-model"; emulated when empty
+	Sockets int    `json:"sockets,omitempty"`
+	Cores   int    `json:"cores,omitempty"`
+	Threads int    `json:"threads,omitempty"`
+}
+
+// Source is the network or bridge the NIC is on
+func (n VMNICConfig) Source() string {
+	if n.Bridge != "" {
+		return n.Bridge
+	}
+	return n.Network
 }
 
 // Storage / Volume types:
@@ -157,16 +205,27 @@ type PerformanceSample struct {
 
 // ADD THIS NEW STRUCT FOR SNAPSHOTS
 type Snapshot struct {
-	Name        string `json:"name"`
-	State       string `json:"state"`
-	CreationTS  int64  `json:"creation_ts"` // Unix timestamp
-	Description string `json:"description"`
+	Name        string         `json:"name"`
+	State       string         `json:"state"`
+	CreationTS  int64          `json:"creation_ts"` // Unix timestamp
+	Description string         `json:"description"`
+	Parent      string         `json:"parent,omitempty"` // Snapshot this one was taken on top of
+	Current     bool           `json:"current"`          // The VM's current snapshot
+	Kind        string         `json:"kind"`             // "internal" or "external"
+	Memory      bool           `json:"memory"`           // Includes the guest's RAM
+	MemoryFile  string         `json:"memory_file,omitempty"`
+	Disks       []SnapshotDisk `json:"disks,omitempty"`
 }
 
 // CreateSnapshotRequest is the body for the snapshot creation endpoint.
+// Without options a VM gets an internal snapshot, which includes RAM while it runs.
 type CreateSnapshotRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
+	DiskOnly    bool   `json:"diskOnly,omitempty"` // External qcow2 overlays, no RAM
+	Memory      bool   `json:"memory,omitempty"`   // External overlays plus a RAM file; the VM must be running
+	Live        bool   `json:"live,omitempty"`     // With memory: keep the guest running while RAM is saved
+	Quiesce     bool   `json:"quiesce,omitempty"`  // With diskOnly: freeze guest filesystems via the guest agent
 }
 
 // ADD a type for Network
@@ -196,12 +255,17 @@ type SystemInterface struct {
 
 // ADD a type for Activity Log
 type ActivityEvent struct {
-	ID        string `json:"id"`
-	Timestamp int64  `json:"timestamp"` // Unix timestamp
-	Action    string `json:"action"`    // "VM Started", "Snapshot Created"
-	Target    string `json:"target"`    // "web-server-01"
-	Status    string `json:"status"`    // "Success", "Error"
-	Message   string `json:"message"`
+	ID         string                 `json:"id"`
+	Timestamp  int64                  `json:"timestamp"` // Unix timestamp
+	Action     string                 `json:"action"`    // "VM Started", "Snapshot Created", "POST /api/vms"
+	Target     string                 `json:"target"`    // "web-server-01"
+	Status     string                 `json:"status"`    // "Success", "Error"
+	Message    string                 `json:"message"`
+	Actor      string                 `json:"actor,omitempty"`       // "api-key", "session", "cli", "system" or an API token's user
+	TargetUUID string                 `json:"target_uuid,omitempty"` // VM UUID when the target is a VM
+	ServerID   string                 `json:"server_id,omitempty"`   // Registered server the request targeted
+	RemoteAddr string                 `json:"remote_addr,omitempty"`
+	Params     map[string]interface{} `json:"params,omitempty"` // Request parameters, secrets redacted
 }
 
 // VNCInfo holds VNC connection details for a VM
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -80,3 +81,148 @@ func TestHostStatus(t *testing.T) {
 		t.Errorf("Expected 1 health check, got %d", len(status.HealthChecks))
 	}
 }
+
+func TestBuildSnapshotTree(t *testing.T) {
+	tree := BuildSnapshotTree([]Snapshot{
+		{Name: "patched", Parent: "base", CreationTS: 300, Current: true},
+		{Name: "base", CreationTS: 100},
+		{Name: "experiment", Parent: "base", CreationTS: 200},
+		{Name: "orphan", Parent: "deleted", CreationTS: 50},
+	})
+
+	if tree.Current != "patched" {
+		t.Errorf("Expected current snapshot 'patched', got '%s'", tree.Current)
+	}
+	if len(tree.Roots) != 2 || tree.Roots[0].Name != "orphan" || tree.Roots[1].Name != "base" {
+		t.Fatalf("Expected roots [orphan base], got %+v", tree.Roots)
+	}
+
+	children := tree.Roots[1].Children
+	if len(children) != 2 || children[0].Name != "experiment" || children[1].Name != "patched" {
+		t.Errorf("Expected base's children oldest first, got %+v", children)
+	}
+}
+
+func TestCreateSnapshotRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     CreateSnapshotRequest
+		wantErr bool
+	}{
+		{"internal", CreateSnapshotRequest{Name: "base"}, false},
+		{"disk-only quiesced", CreateSnapshotRequest{Name: "base", DiskOnly: true, Quiesce: true}, false},
+		{"live memory", CreateSnapshotRequest{Name: "base", Memory: true, Live: true}, false},
+		{"missing name", CreateSnapshotRequest{}, true},
+		{"slash in name", CreateSnapshotRequest{Name: "a/b"}, true},
+		{"disk-only with memory", CreateSnapshotRequest{Name: "base", DiskOnly: true, Memory: true}, true},
+		{"live without memory", CreateSnapshotRequest{Name: "base", Live: true}, true},
+		{"quiesce without disk-only", CreateSnapshotRequest{Name: "base", Quiesce: true}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestUpdateVMLabelsRequest(t *testing.T) {
+	prod, empty, long := "prod", "", strings.Repeat("x", MaxLabelValueLength+1)
+	current := map[string]string{"env": "dev", "team": "web"}
+
+	merged := UpdateVMLabelsRequest{Labels: map[string]*string{"env": &prod, "team": nil, "owner": &empty}}.Apply(current)
+	if FormatLabels(merged) != "env=prod,owner=" || current["env"] != "dev" {
+		t.Errorf("Unexpected merge %v (current %v)", merged, current)
+	}
+	replaced := UpdateVMLabelsRequest{Labels: map[string]*string{"tier": &prod}, Replace: true}.Apply(current)
+	if FormatLabels(replaced) != "tier=prod" {
+		t.Errorf("Unexpected replacement %v", replaced)
+	}
+
+	for key, wantErr := range map[string]bool{"env": false, "example.com/team": false, "a_b-c.9": false, "-env": true, "env-": true, "": true, "a b": true, strings.Repeat("k", 64): true} {
+		req := UpdateVMLabelsRequest{Labels: map[string]*string{key: &prod}}
+		if err := req.Validate(); (err != nil) != wantErr {
+			t.Errorf("key %q: Validate() error = %v, wantErr %v", key, err, wantErr)
+		}
+	}
+	for _, value := range []string{long, "a\nb"} {
+		if err := (UpdateVMLabelsRequest{Labels: map[string]*string{"env": &value}}).Validate(); err == nil {
+			t.Errorf("Expected value %q to be rejected", value)
+		}
+	}
+}
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"env": "prod", "tier": "web"}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env==prod,tier=web", true},
+		{"env=prod,tier=db", false},
+		{"env!=dev", true},
+		{"owner!=me", true},
+		{"tier", true},
+		{"owner", false},
+		{"!owner", true},
+		{"!env", false},
+	}
+	for _, tt := range tests {
+		selector, err := ParseLabelSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("ParseLabelSelector(%q) failed: %v", tt.selector, err)
+		}
+		if got := selector.Matches(labels); got != tt.want {
+			t.Errorf("%q: Matches() = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+
+	selector, _ := ParseLabelSelector(" env = prod ,!owner,tier")
+	if selector.String() != "env=prod,!owner,tier" {
+		t.Errorf("Unexpected String() %q", selector.String())
+	}
+	for _, bad := range []string{"=prod", "env prod", "!"} {
+		if _, err := ParseLabelSelector(bad); err == nil {
+			t.Errorf("Expected selector %q to be rejected", bad)
+		}
+	}
+}
+
+func TestUpdateVMRequest_Validate(t *testing.T) {
+	valid := []UpdateVMRequest{
+		{VCPUs: 4},
+		{MemoryMB: 8192},
+		{ResizeDisks: []DiskResize{{Target: "vdb", SizeGB: 100}}},
+		{DetachDisks: []string{"vdc"}, DetachNICs: []string{"52:54:00:12:34:56"}},
+		{UpdateNICs: []NICUpdate{{MAC: "52:54:00:12:34:56", Network: "lab", Model: "e1000"}}},
+		{CDROM: &CDROMChange{ISO: "ubuntu-24.04.iso"}},
+		{CDROM: &CDROMChange{Target: "sda", Eject: true}},
+	}
+	for _, req := range valid {
+		if err := req.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", req, err)
+		}
+	}
+
+	invalid := []UpdateVMRequest{
+		{},
+		{VCPUs: MaxVMVCPUs + 1},
+		{MemoryMB: MaxVMMemoryMB + 1},
+		{ResizeDisks: []DiskResize{{Target: "vdb"}}},
+		{ResizeDisks: []DiskResize{{Target: "/dev/sda", SizeGB: 10}}},
+		{ResizeDisks: []DiskResize{{Target: "vdb", SizeGB: 10}}, DetachDisks: []string{"vdb"}},
+		{DetachNICs: []string{"not-a-mac"}},
+		{UpdateNICs: []NICUpdate{{MAC: "52:54:00:12:34:56"}}},
+		{UpdateNICs: []NICUpdate{{MAC: "52:54:00:12:34:56", Model: "ne2k"}}},
+		{UpdateNICs: []NICUpdate{{MAC: "52:54:00:12:34:56", Network: "lab"}}, DetachNICs: []string{"52:54:00:12:34:56"}},
+		{CDROM: &CDROMChange{}},
+		{CDROM: &CDROMChange{ISO: "a.iso", Eject: true}},
+		{CDROM: &CDROMChange{ISO: "../etc/passwd"}},
+	}
+	for _, req := range invalid {
+		if err := req.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", req)
+		}
+	}
+}
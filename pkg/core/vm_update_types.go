@@ -0,0 +1,161 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Limits on reconfigured VMs, the same as for new ones
+const (
+	MaxVMVCPUs    = 128
+	MaxVMMemoryMB = 524288 // 512 GB
+	MaxVMDiskGB   = 10000  // 10 TB
+)
+
+var diskTargetPattern = regexp.MustCompile(`^(vd|sd|hd|xvd)[a-z]{1,2}$`)
+
+// UpdateVMRequest is the body of PATCH /api/vms/{uuid}. Every field is
+// optional and only the given ones change. Changes are made to the running VM
+// when the hypervisor allows it and to the persistent definition; the result
+// says which of the two each change reached.
+type UpdateVMRequest struct {
+	VCPUs       int          `json:"vcpus,omitempty"`
+	MemoryMB    uint64       `json:"memoryMB,omitempty"`
+	ResizeDisks []DiskResize `json:"resizeDisks,omitempty"`
+	DetachDisks []string     `json:"detachDisks,omitempty"` // Target devices, e.g. vdb
+	DetachNICs  []string     `json:"detachNics,omitempty"`  // MAC addresses
+	UpdateNICs  []NICUpdate  `json:"updateNics,omitempty"`
+	CDROM       *CDROMChange `json:"cdrom,omitempty"`
+}
+
+// DiskResize grows a disk. Disks cannot be shrunk.
+type DiskResize struct {
+	Target string `json:"target"` // e.g. vdb
+	SizeGB uint64 `json:"sizeGB"`
+}
+
+// NICUpdate moves the NIC with the given MAC to another network or bridge,
+// or changes its model
+type NICUpdate struct {
+	MAC     string `json:"mac"`
+	Network string `json:"network,omitempty"` // Libvirt network or host bridge
+	Model   string `json:"model,omitempty"`   // "virtio", "e1000", "e1000e" or "rtl8139"
+}
+
+// CDROMChange inserts an ISO into a CD-ROM drive, or ejects it
+type CDROMChange struct {
+	Target string `json:"target,omitempty"` // Default: the VM's first CD-ROM drive
+	ISO    string `json:"iso,omitempty"`    // ISO in the image library, or an absolute path
+	Eject  bool   `json:"eject,omitempty"`
+}
+
+// IsEmpty reports whether the request changes nothing
+func (r UpdateVMRequest) IsEmpty() bool {
+	return r.VCPUs == 0 && r.MemoryMB == 0 && len(r.ResizeDisks) == 0 && len(r.DetachDisks) == 0 &&
+		len(r.DetachNICs) == 0 && len(r.UpdateNICs) == 0 && r.CDROM == nil
+}
+
+// Validate checks the request's values; whether the devices exist is checked
+// against the VM
+func (r UpdateVMRequest) Validate() error {
+	if r.IsEmpty() {
+		return errors.New("nothing to change")
+	}
+	if r.VCPUs < 0 || r.VCPUs > MaxVMVCPUs {
+		return fmt.Errorf("vCPUs must be between 1 and %d", MaxVMVCPUs)
+	}
+	if r.MemoryMB > MaxVMMemoryMB {
+		return errors.New("memory cannot exceed 512 GB")
+	}
+
+	detached := make(map[string]bool, len(r.DetachDisks))
+	for _, target := range r.DetachDisks {
+		if !diskTargetPattern.MatchString(target) {
+			return fmt.Errorf("invalid disk target %q", target)
+		}
+		detached[target] = true
+	}
+	for _, resize := range r.ResizeDisks {
+		if !diskTargetPattern.MatchString(resize.Target) {
+			return fmt.Errorf("invalid disk target %q", resize.Target)
+		}
+		if resize.SizeGB == 0 || resize.SizeGB > MaxVMDiskGB {
+			return fmt.Errorf("disk %s: size must be between 1 GB and 10 TB", resize.Target)
+		}
+		if detached[resize.Target] {
+			return fmt.Errorf("disk %s cannot be both resized and detached", resize.Target)
+		}
+	}
+
+	detachedNICs := make(map[string]bool, len(r.DetachNICs))
+	for _, mac := range r.DetachNICs {
+		if err := validateMAC(mac); err != nil {
+			return err
+		}
+		detachedNICs[strings.ToLower(mac)] = true
+	}
+	for _, nic := range r.UpdateNICs {
+		if err := validateMAC(nic.MAC); err != nil {
+			return err
+		}
+		if nic.Network == "" && nic.Model == "" {
+			return fmt.Errorf("NIC %s: network or model is required", nic.MAC)
+		}
+		if len(nic.Network) > 50 {
+			return fmt.Errorf("NIC %s: network name must be 50 characters or less", nic.MAC)
+		}
+		switch nic.Model {
+		case "", "virtio", "e1000", "e1000e", "rtl8139":
+		default:
+			return fmt.Errorf("NIC %s: model must be 'virtio', 'e1000', 'e1000e', or 'rtl8139'", nic.MAC)
+		}
+		if detachedNICs[strings.ToLower(nic.MAC)] {
+			return fmt.Errorf("NIC %s cannot be both changed and detached", nic.MAC)
+		}
+	}
+
+	if cd := r.CDROM; cd != nil {
+		if cd.Target != "" && !diskTargetPattern.MatchString(cd.Target) {
+			return fmt.Errorf("invalid CD-ROM target %q", cd.Target)
+		}
+		if (cd.ISO == "") == !cd.Eject {
+			return errors.New("cdrom needs either an iso or eject")
+		}
+		if strings.Contains(cd.ISO, "..") {
+			return errors.New("cdrom iso cannot contain '..'")
+		}
+	}
+	return nil
+}
+
+func validateMAC(s string) error {
+	mac, err := net.ParseMAC(s)
+	if err != nil || len(mac) != 6 {
+		return fmt.Errorf("invalid MAC address %q", s)
+	}
+	return nil
+}
+
+// VMChange is one change PATCH /api/vms/{uuid} made. Live changes reached the
+// running VM; Config changes were saved to the definition. A change that is
+// only in the definition of a running VM takes effect when the VM is next
+// started, which RestartRequired reports.
+type VMChange struct {
+	Field           string `json:"field"` // e.g. vcpus, memory, disk vdb, nic 52:54:00:12:34:56
+	From            string `json:"from,omitempty"`
+	To              string `json:"to,omitempty"`
+	Live            bool   `json:"live"`
+	Config          bool   `json:"config"`
+	RestartRequired bool   `json:"restart_required,omitempty"`
+	Note            string `json:"note,omitempty"`
+}
+
+// UpdateVMResult is the VM after PATCH /api/vms/{uuid} and the changes made
+type UpdateVMResult struct {
+	VM              VM_Detailed `json:"vm"`
+	Changes         []VMChange  `json:"changes"`
+	RestartRequired bool        `json:"restart_required"`
+}
@@ -0,0 +1,51 @@
+package download
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"strings"
+)
+
+// ParseChecksums finds the checksum of a file in a checksum list such as
+// SHA256SUMS or SHA512SUMS. Both the GNU ("<hash>  <name>", "<hash> *<name>")
+// and BSD ("SHA256 (<name>) = <hash>") line formats are understood. It
+// returns "" when the file is not listed.
+func ParseChecksums(data []byte, name string) string {
+	name = path.Base(name)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// BSD style
+		if open := strings.Index(line, " ("); open > 0 {
+			if closing := strings.LastIndex(line, ") = "); closing > open {
+				if path.Base(line[open+2:closing]) == name {
+					return normalizeChecksum(line[closing+4:])
+				}
+				continue
+			}
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if path.Base(strings.TrimPrefix(fields[1], "*")) == name {
+			return normalizeChecksum(fields[0])
+		}
+	}
+	return ""
+}
+
+// normalizeChecksum lowercases a checksum, returning "" if it is not hex
+func normalizeChecksum(sum string) string {
+	sum = strings.ToLower(strings.TrimSpace(sum))
+	if !hexPattern.MatchString(sum) {
+		return ""
+	}
+	return sum
+}
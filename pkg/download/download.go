@@ -0,0 +1,493 @@
+// Package download fetches files over HTTP(S) for the image library.
+// Interrupted transfers resume with Range requests, finished files are
+// verified against expected checksums, and all downloads share a bandwidth
+// limit and a cap on how many run at once.
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults used when Config leaves a field at zero
+const (
+	DefaultMaxConcurrent = 2
+	DefaultRetries       = 5
+)
+
+// idleTimeout is how long a transfer may go without receiving data before it
+// is dropped and resumed
+const idleTimeout = 60 * time.Second
+
+// retryDelay is the wait before the first retry; later ones wait longer
+var retryDelay = 2 * time.Second
+
+var (
+	// ErrChecksumMismatch is returned when a finished file does not match its expected checksum
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrInProgress is returned when another download is writing the same file
+	ErrInProgress = errors.New("download already in progress")
+
+	errStalled = errors.New("no data received for " + idleTimeout.String())
+
+	contentRangePattern = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+|\*)$`)
+	hexPattern          = regexp.MustCompile(`^[0-9a-f]+$`)
+)
+
+// Config sets the limits shared by all downloads
+type Config struct {
+	MaxConcurrent  int          // Downloads running at once; others wait for a slot
+	BytesPerSecond int64        // Combined bandwidth of all downloads, 0 means no limit
+	Retries        int          // Attempts to resume an interrupted transfer
+	Client         *http.Client // Defaults to a client with connection and header timeouts
+}
+
+// Request describes a file to download
+type Request struct {
+	URL      string
+	Path     string // Destination; partial data is kept in Path.part until verified
+	SHA256   string // Expected checksums, verified when set
+	SHA512   string
+	Progress func(downloaded, total int64) // total is -1 when unknown
+}
+
+// Result describes a finished download
+type Result struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"sizeBytes"`
+	SHA256    string `json:"sha256"`
+	Resumed   bool   `json:"resumed"` // Part of the file came from an earlier attempt
+}
+
+// Downloader runs downloads within the configured limits
+type Downloader struct {
+	client  *http.Client
+	slots   chan struct{}
+	limiter *limiter
+	retries int
+
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+// New creates a downloader
+func New(cfg Config) *Downloader {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = DefaultMaxConcurrent
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = DefaultRetries
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   15 * time.Second,
+			ResponseHeaderTimeout: 60 * time.Second,
+			IdleConnTimeout:       90 * time.Second,
+		}}
+	}
+
+	d := &Downloader{
+		client:  cfg.Client,
+		slots:   make(chan struct{}, cfg.MaxConcurrent),
+		retries: cfg.Retries,
+		active:  make(map[string]bool),
+	}
+	if cfg.BytesPerSecond > 0 {
+		d.limiter = &limiter{rate: float64(cfg.BytesPerSecond)}
+	}
+	return d
+}
+
+// ValidateURL checks that a download URL is absolute HTTP or HTTPS
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("only HTTP and HTTPS URLs are allowed")
+	}
+	if u.Host == "" {
+		return errors.New("URL has no host")
+	}
+	return nil
+}
+
+// Download fetches a file, waiting for a free slot first. Partial data is
+// kept when the download fails or is cancelled, so that the next download of
+// the same URL to the same path resumes where this one stopped. Data that
+// fails verification is discarded.
+func (d *Downloader) Download(ctx context.Context, req Request) (Result, error) {
+	if err := ValidateURL(req.URL); err != nil {
+		return Result{}, err
+	}
+	req.SHA256 = strings.ToLower(req.SHA256)
+	req.SHA512 = strings.ToLower(req.SHA512)
+	if req.SHA256 != "" && (len(req.SHA256) != 64 || !hexPattern.MatchString(req.SHA256)) {
+		return Result{}, errors.New("sha256 must be 64 hex digits")
+	}
+	if req.SHA512 != "" && (len(req.SHA512) != 128 || !hexPattern.MatchString(req.SHA512)) {
+		return Result{}, errors.New("sha512 must be 128 hex digits")
+	}
+
+	d.mu.Lock()
+	if d.active[req.Path] {
+		d.mu.Unlock()
+		return Result{}, fmt.Errorf("%w: %s", ErrInProgress, req.Path)
+	}
+	d.active[req.Path] = true
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.active, req.Path)
+		d.mu.Unlock()
+	}()
+
+	select {
+	case d.slots <- struct{}{}:
+		defer func() { <-d.slots }()
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+
+	part := req.Path + ".part"
+	statePath := part + ".json"
+	state := loadState(statePath)
+	if state.URL != req.URL {
+		// Partial data from another URL is of no use
+		os.Remove(part)
+		state = partState{URL: req.URL}
+	}
+	resumed := false
+	for attempt := 0; ; attempt++ {
+		err := d.fetch(ctx, req, part, statePath, &state, &resumed)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		var statusErr *statusError
+		if (errors.As(err, &statusErr) && !statusErr.retryable()) || attempt >= d.retries {
+			return Result{}, err
+		}
+		select {
+		case <-time.After(time.Duration(attempt+1) * retryDelay):
+		case <-ctx.Done():
+			return Result{}, ctx.Err()
+		}
+	}
+
+	result, err := verify(part, req)
+	if err != nil {
+		if errors.Is(err, ErrChecksumMismatch) {
+			os.Remove(part)
+			os.Remove(statePath)
+		}
+		return Result{}, err
+	}
+	if err := os.Rename(part, req.Path); err != nil {
+		return Result{}, fmt.Errorf("failed to rename download: %w", err)
+	}
+	os.Remove(statePath)
+
+	result.Path = req.Path
+	result.Resumed = resumed
+	return result, nil
+}
+
+// Fetch reads a small file such as a checksum list, up to limit bytes
+func (d *Downloader) Fetch(ctx context.Context, rawURL string, limit int64) ([]byte, error) {
+	if err := ValidateURL(rawURL); err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{code: resp.StatusCode}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", rawURL, limit)
+	}
+	return data, nil
+}
+
+// fetch makes one attempt at transferring the rest of the file into part,
+// setting resumed when it continued from data already there
+func (d *Downloader) fetch(ctx context.Context, req Request, part, statePath string, state *partState, resumed *bool) error {
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// Only continue if the file has not changed since the first attempt
+		if state.ETag != "" {
+			httpReq.Header.Set("If-Range", state.ETag)
+		} else if state.LastModified != "" {
+			httpReq.Header.Set("If-Range", state.LastModified)
+		}
+	}
+
+	resp, err := d.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	total := int64(-1)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			f.Truncate(0)
+			return fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		total = size
+	case http.StatusOK:
+		// Ranges are not supported or the file changed: start over
+		offset = 0
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		if size, ok := parseUnsatisfiedRange(resp.Header.Get("Content-Range")); ok && size == offset {
+			*resumed = true
+			return nil
+		}
+		f.Truncate(0)
+		return &statusError{code: resp.StatusCode}
+	default:
+		return &statusError{code: resp.StatusCode}
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		state.ETag = etag
+	}
+	state.LastModified = resp.Header.Get("Last-Modified")
+	saveState(statePath, *state)
+	*resumed = offset > 0
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	idle := time.AfterFunc(idleTimeout, func() { cancel(errStalled) })
+	defer idle.Stop()
+
+	bufSize := 256 << 10
+	if d.limiter != nil {
+		// Smaller reads keep a limited transfer smooth
+		bufSize = int(min(int64(bufSize), max(int64(d.limiter.rate)/8, 4<<10)))
+	}
+	buf := make([]byte, bufSize)
+	written := offset
+	if req.Progress != nil {
+		req.Progress(written, total)
+	}
+	for {
+		n, rerr := resp.Body.Read(buf)
+		if n > 0 {
+			idle.Reset(idleTimeout)
+			if d.limiter != nil {
+				if err := d.limiter.wait(ctx, n); err != nil {
+					return err
+				}
+			}
+			if _, err := f.Write(buf[:n]); err != nil {
+				return fmt.Errorf("failed to write to file: %w", err)
+			}
+			written += int64(n)
+			if req.Progress != nil {
+				req.Progress(written, total)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			if cause := context.Cause(ctx); errors.Is(cause, errStalled) {
+				return cause
+			}
+			return fmt.Errorf("download error: %w", rerr)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	if total >= 0 && written != total {
+		return fmt.Errorf("download ended after %d of %d bytes", written, total)
+	}
+	return nil
+}
+
+// verify hashes a finished file and compares it with the expected checksums
+func verify(path string, req Request) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	sum256 := sha256.New()
+	writers := []io.Writer{sum256}
+	var sum512 hash.Hash
+	if req.SHA512 != "" {
+		sum512 = sha512.New()
+		writers = append(writers, sum512)
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), f)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to calculate hash: %w", err)
+	}
+
+	result := Result{SizeBytes: size, SHA256: hex.EncodeToString(sum256.Sum(nil))}
+	if req.SHA256 != "" && result.SHA256 != req.SHA256 {
+		return Result{}, fmt.Errorf("%w: expected sha256 %s, got %s", ErrChecksumMismatch, req.SHA256, result.SHA256)
+	}
+	if sum512 != nil {
+		if got := hex.EncodeToString(sum512.Sum(nil)); got != req.SHA512 {
+			return Result{}, fmt.Errorf("%w: expected sha512 %s, got %s", ErrChecksumMismatch, req.SHA512, got)
+		}
+	}
+	return result, nil
+}
+
+// parseContentRange parses "bytes start-end/size" from a 206 response; size
+// is -1 when the server does not know it
+func parseContentRange(value string) (start, size int64, ok bool) {
+	m := contentRangePattern.FindStringSubmatch(value)
+	if m == nil {
+		return 0, 0, false
+	}
+	start, _ = strconv.ParseInt(m[1], 10, 64)
+	size = -1
+	if m[3] != "*" {
+		size, _ = strconv.ParseInt(m[3], 10, 64)
+	}
+	return start, size, true
+}
+
+// parseUnsatisfiedRange parses "bytes */size" from a 416 response
+func parseUnsatisfiedRange(value string) (int64, bool) {
+	rest, ok := strings.CutPrefix(value, "bytes */")
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(rest, 10, 64)
+	return size, err == nil
+}
+
+// statusError is an unexpected HTTP status
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("download failed with status: %d", e.code)
+}
+
+// retryable reports whether the server might answer differently later
+func (e *statusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code == http.StatusRequestedRangeNotSatisfiable || e.code >= 500
+}
+
+// partState is kept next to partial data so that a later attempt, even after
+// a restart, only resumes if the remote file is unchanged
+type partState struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+func loadState(path string) partState {
+	var state partState
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+func saveState(path string, state partState) {
+	if data, err := json.Marshal(state); err == nil {
+		os.WriteFile(path, data, 0644)
+	}
+}
+
+// limiter spaces out reads so that all downloads together stay under a rate
+type limiter struct {
+	mu   sync.Mutex
+	rate float64 // bytes per second
+	next time.Time
+}
+
+// wait blocks until n more bytes fit within the rate
+func (l *limiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
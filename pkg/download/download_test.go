@@ -0,0 +1,225 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	retryDelay = 10 * time.Millisecond
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 253)
+	}
+	return data
+}
+
+func sum256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileServer serves data with an ETag and range support, counting range requests
+func fileServer(data []byte, etag string, ranges *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" && ranges != nil {
+			atomic.AddInt32(ranges, 1)
+		}
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "image.qcow2", time.Unix(1700000000, 0), bytes.NewReader(data))
+	}))
+}
+
+func TestDownload_VerifiesChecksums(t *testing.T) {
+	data := testData(300 << 10)
+	srv := fileServer(data, `"v1"`, nil)
+	defer srv.Close()
+
+	d := New(Config{})
+	dest := filepath.Join(t.TempDir(), "image.qcow2")
+	sum512 := sha512.Sum512(data)
+
+	var last int64
+	result, err := d.Download(context.Background(), Request{
+		URL:      srv.URL + "/image.qcow2",
+		Path:     dest,
+		SHA256:   strings.ToUpper(sum256(data)),
+		SHA512:   hex.EncodeToString(sum512[:]),
+		Progress: func(downloaded, total int64) { last = downloaded },
+	})
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if result.SizeBytes != int64(len(data)) || result.SHA256 != sum256(data) || result.Resumed || last != int64(len(data)) {
+		t.Errorf("Download() = %+v, last progress %d", result, last)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, data) {
+		t.Error("downloaded file differs")
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Error("partial file left behind")
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.qcow2")
+	_, err = d.Download(context.Background(), Request{URL: srv.URL, Path: bad, SHA256: strings.Repeat("0", 64)})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Download() error = %v, want ErrChecksumMismatch", err)
+	}
+	for _, path := range []string{bad, bad + ".part", bad + ".part.json"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s kept after a checksum mismatch", filepath.Base(path))
+		}
+	}
+
+	if _, err := d.Download(context.Background(), Request{URL: "file:///etc/passwd", Path: bad}); err == nil {
+		t.Error("Download() of a file:// URL expected an error")
+	}
+}
+
+func TestDownload_Resumes(t *testing.T) {
+	data := testData(200 << 10)
+	var ranges int32
+	srv := fileServer(data, `"v1"`, &ranges)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "image.qcow2")
+	os.WriteFile(dest+".part", data[:70000], 0644)
+	saveState(dest+".part.json", partState{URL: srv.URL, ETag: `"v1"`})
+
+	result, err := New(Config{}).Download(context.Background(), Request{URL: srv.URL, Path: dest, SHA256: sum256(data)})
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if !result.Resumed || atomic.LoadInt32(&ranges) != 1 {
+		t.Errorf("Download() = %+v after %d range requests, want a resumed download", result, ranges)
+	}
+
+	// A changed file is downloaded again from the start
+	os.Remove(dest)
+	os.WriteFile(dest+".part", []byte("stale data"), 0644)
+	saveState(dest+".part.json", partState{URL: srv.URL, ETag: `"v0"`})
+	result, err = New(Config{}).Download(context.Background(), Request{URL: srv.URL, Path: dest, SHA256: sum256(data)})
+	if err != nil {
+		t.Fatalf("Download() of a changed file error = %v", err)
+	}
+	if result.Resumed {
+		t.Error("Download() resumed from data of another version")
+	}
+}
+
+func TestDownload_RetriesInterruptedTransfer(t *testing.T) {
+	data := testData(128 << 10)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// Promise the whole file, send half, then drop the connection
+			w.Header().Set("Content-Length", "131072")
+			w.Write(data[:64<<10])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "image.qcow2", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "image.qcow2")
+	result, err := New(Config{}).Download(context.Background(), Request{URL: srv.URL, Path: dest, SHA256: sum256(data)})
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if !result.Resumed || atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("Download() = %+v after %d requests, want a resume on the second", result, requests)
+	}
+}
+
+func TestDownload_DoesNotRetryClientErrors(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	_, err := New(Config{}).Download(context.Background(), Request{URL: srv.URL, Path: filepath.Join(t.TempDir(), "x")})
+	if err == nil || atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("Download() error = %v after %d requests, want one failed request", err, requests)
+	}
+}
+
+func TestDownload_Limits(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.Write(testData(50 << 10))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	dir := t.TempDir()
+	d := New(Config{MaxConcurrent: 1, BytesPerSecond: 100 << 10})
+	go d.Download(context.Background(), Request{URL: srv.URL + "/slow", Path: filepath.Join(dir, "slow")})
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := d.Download(context.Background(), Request{URL: srv.URL + "/slow", Path: filepath.Join(dir, "slow")}); !errors.Is(err, ErrInProgress) {
+		t.Errorf("Download() to a busy path error = %v, want ErrInProgress", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := d.Download(ctx, Request{URL: srv.URL, Path: filepath.Join(dir, "queued")}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Download() without a free slot error = %v, want DeadlineExceeded", err)
+	}
+
+	// 50 KiB at 100 KiB/s takes about half a second
+	free := New(Config{BytesPerSecond: 100 << 10})
+	start := time.Now()
+	if _, err := free.Download(context.Background(), Request{URL: srv.URL, Path: filepath.Join(dir, "limited")}); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("limited download took %s, want at least 300ms", elapsed)
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sums := strings.Join([]string{
+		"# comment",
+		"3e2c1b5bd9a7b87ebea1fd6bfda7ae5b4b0d4cbb6b9e0e8b6f1b2e1c7c3a4d5e *noble-server-cloudimg-amd64.img",
+		"AB12AB12AB12AB12AB12AB12AB12AB12AB12AB12AB12AB12AB12AB12AB12AB12  debian-12-generic-amd64.qcow2",
+		"SHA256 (Fedora-Cloud-Base-42.qcow2) = 00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff",
+		"",
+	}, "\n")
+
+	tests := map[string]string{
+		"noble-server-cloudimg-amd64.img":         "3e2c1b5bd9a7b87ebea1fd6bfda7ae5b4b0d4cbb6b9e0e8b6f1b2e1c7c3a4d5e",
+		"https://x/debian-12-generic-amd64.qcow2": "ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12ab12",
+		"Fedora-Cloud-Base-42.qcow2":              "00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff00ff",
+		"missing.img":                             "",
+	}
+	for name, want := range tests {
+		if got := ParseChecksums([]byte(sums), name); got != want {
+			t.Errorf("ParseChecksums(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package events
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before
+// further events are dropped for it
+const subscriberBuffer = 64
+
+// Source is a connection that can deliver live events (libvirtclient.ClientInterface)
+type Source interface {
+	SubscribeEvents(handler func(core.Event)) (func(), error)
+}
+
+// Filter selects events for a subscriber. Empty fields match everything.
+type Filter struct {
+	ResourceTypes []string // "domain", "network", "storage_pool"
+	UUID          string
+	Name          string
+	ServerID      string
+}
+
+// Matches reports whether an event passes the filter
+func (f Filter) Matches(e core.Event) bool {
+	if len(f.ResourceTypes) > 0 {
+		found := false
+		for _, t := range f.ResourceTypes {
+			if strings.EqualFold(t, e.ResourceType) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.UUID != "" && !strings.EqualFold(f.UUID, e.UUID) {
+		return false
+	}
+	if f.Name != "" && f.Name != e.Name {
+		return false
+	}
+	if f.ServerID != "" && f.ServerID != e.ServerID {
+		return false
+	}
+	return true
+}
+
+type subscriber struct {
+	ch     chan core.Event
+	filter Filter
+}
+
+type watch struct {
+	source      Source
+	unsubscribe func()
+}
+
+// Hub fans out libvirt events from every watched connection to subscribers
+type Hub struct {
+	watchMu     sync.Mutex // Serialises Watch/Unwatch so a server is never subscribed twice
+	mu          sync.RWMutex
+	subscribers map[int]*subscriber
+	nextID      int
+	watches     map[string]*watch
+}
+
+// NewHub creates an empty event hub
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[int]*subscriber),
+		watches:     make(map[string]*watch),
+	}
+}
+
+// Watch starts forwarding events from source, tagged with serverID ("" for the
+// local connection). Watching the same source again is a no-op; a new source for
+// a known server (e.g. after a reconnect) replaces the old one.
+func (h *Hub) Watch(serverID string, source Source) error {
+	h.watchMu.Lock()
+	defer h.watchMu.Unlock()
+
+	h.mu.Lock()
+	existing, ok := h.watches[serverID]
+	if ok && existing.source == source {
+		h.mu.Unlock()
+		return nil
+	}
+	delete(h.watches, serverID)
+	h.mu.Unlock()
+
+	// Register and deregister outside the lock; libvirt delivers callbacks from its
+	// event loop, which may be waiting in Publish
+	if ok {
+		existing.unsubscribe()
+	}
+
+	unsubscribe, err := source.SubscribeEvents(func(e core.Event) {
+		e.ServerID = serverID
+		h.Publish(e)
+	})
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.watches[serverID] = &watch{source: source, unsubscribe: unsubscribe}
+	h.mu.Unlock()
+
+	logger.Info("Watching libvirt events", map[string]interface{}{
+		"server_id": serverID,
+	})
+	return nil
+}
+
+// Unwatch stops forwarding events for a server
+func (h *Hub) Unwatch(serverID string) {
+	h.watchMu.Lock()
+	defer h.watchMu.Unlock()
+
+	h.mu.Lock()
+	existing, ok := h.watches[serverID]
+	delete(h.watches, serverID)
+	h.mu.Unlock()
+
+	if ok {
+		existing.unsubscribe()
+	}
+}
+
+// Publish delivers an event to every matching subscriber without blocking
+func (h *Hub) Publish(e core.Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, sub := range h.subscribers {
+		if !sub.filter.Matches(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			// Subscriber is too slow; drop rather than stall libvirt's event loop
+		}
+	}
+}
+
+// Subscribe returns a channel of events matching filter and a function that
+// unsubscribes and closes the channel
+func (h *Hub) Subscribe(filter Filter) (<-chan core.Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id := h.nextID
+	h.nextID++
+	sub := &subscriber{ch: make(chan core.Event, subscriberBuffer), filter: filter}
+	h.subscribers[id] = sub
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, id)
+			h.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// fakeSource hands its handler to the test so events can be injected
+type fakeSource struct {
+	handler       func(core.Event)
+	subscriptions int
+	unsubscribed  int
+}
+
+func (f *fakeSource) SubscribeEvents(handler func(core.Event)) (func(), error) {
+	f.handler = handler
+	f.subscriptions++
+	return func() { f.unsubscribed++ }, nil
+}
+
+func receive(t *testing.T, ch <-chan core.Event) (core.Event, bool) {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e, true
+	case <-time.After(100 * time.Millisecond):
+		return core.Event{}, false
+	}
+}
+
+func TestHub_WatchAndFilter(t *testing.T) {
+	hub := NewHub()
+	source := &fakeSource{}
+
+	if err := hub.Watch("srv-1", source); err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if err := hub.Watch("srv-1", source); err != nil {
+		t.Fatalf("Watch() again error = %v", err)
+	}
+	if source.subscriptions != 1 {
+		t.Errorf("Expected one subscription for the same source, got %d", source.subscriptions)
+	}
+
+	domains, unsubscribe := hub.Subscribe(Filter{ResourceTypes: []string{"domain"}, UUID: "vm-1"})
+	defer unsubscribe()
+	all, unsubscribeAll := hub.Subscribe(Filter{})
+	defer unsubscribeAll()
+
+	source.handler(core.Event{ResourceType: core.EventResourceNetwork, UUID: "net-1", Event: "started"})
+	source.handler(core.Event{ResourceType: core.EventResourceDomain, UUID: "vm-1", Event: "crashed"})
+
+	e, ok := receive(t, domains)
+	if !ok || e.UUID != "vm-1" || e.ServerID != "srv-1" {
+		t.Fatalf("Expected vm-1 event tagged with srv-1, got %+v (ok=%v)", e, ok)
+	}
+	if e, ok := receive(t, domains); ok {
+		t.Errorf("Filtered subscriber received unexpected event %+v", e)
+	}
+
+	for _, want := range []string{"net-1", "vm-1"} {
+		if e, ok := receive(t, all); !ok || e.UUID != want {
+			t.Errorf("Expected %s on unfiltered subscription, got %+v (ok=%v)", want, e, ok)
+		}
+	}
+
+	// A reconnect hands the hub a new client for the same server
+	replacement := &fakeSource{}
+	if err := hub.Watch("srv-1", replacement); err != nil {
+		t.Fatalf("Watch() replacement error = %v", err)
+	}
+	if source.unsubscribed != 1 || replacement.subscriptions != 1 {
+		t.Errorf("Expected old source to be released and new one subscribed")
+	}
+
+	hub.Unwatch("srv-1")
+	if replacement.unsubscribed != 1 {
+		t.Errorf("Expected Unwatch to release the subscription")
+	}
+}
@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of request latency buckets
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// requestKey identifies one labelled request series
+type requestKey struct {
+	method string
+	route  string
+	code   string
+}
+
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// RequestDurations is a histogram of HTTP request latencies labelled by method,
+// route pattern and status code
+type RequestDurations struct {
+	mu      sync.Mutex
+	buckets []float64
+	series  map[requestKey]*histogram
+}
+
+// NewRequestDurations creates a histogram with the given bucket bounds in seconds
+// (DefaultLatencyBuckets when nil)
+func NewRequestDurations(buckets []float64) *RequestDurations {
+	if buckets == nil {
+		buckets = DefaultLatencyBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &RequestDurations{
+		buckets: sorted,
+		series:  make(map[requestKey]*histogram),
+	}
+}
+
+// Observe records one request
+func (d *RequestDurations) Observe(method, route, code string, duration time.Duration) {
+	key := requestKey{method: method, route: route, code: code}
+	secs := duration.Seconds()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h, ok := d.series[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(d.buckets))}
+		d.series[key] = h
+	}
+	for i, bound := range d.buckets {
+		if secs <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+// Collect adds the histogram to w as the named family
+func (d *RequestDurations) Collect(w *Writer, name, help string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keys := make([]requestKey, 0, len(d.series))
+	for key := range d.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		return strings.Join([]string{a.route, a.method, a.code}, " ") < strings.Join([]string{b.route, b.method, b.code}, " ")
+	})
+
+	family := w.Family(name, help, Histogram)
+	for _, key := range keys {
+		h := d.series[key]
+		labels := Labels{"method": key.method, "route": key.route, "code": key.code}
+
+		var cumulative uint64
+		for i, bound := range d.buckets {
+			cumulative += h.counts[i]
+			family.addSuffixed("_bucket", labels.With("le", formatValue(bound)), float64(cumulative))
+		}
+		family.addSuffixed("_bucket", labels.With("le", "+Inf"), float64(h.count))
+		family.addSuffixed("_sum", labels, h.sum)
+		family.addSuffixed("_count", labels, float64(h.count))
+	}
+}
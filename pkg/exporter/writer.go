@@ -0,0 +1,158 @@
+package exporter
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the Prometheus text exposition format served by /metrics
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types
+const (
+	Gauge     = "gauge"
+	Counter   = "counter"
+	Histogram = "histogram"
+)
+
+// Labels are the label names and values of one sample
+type Labels map[string]string
+
+// With returns a copy of the labels with extra name/value pairs added
+func (l Labels) With(pairs ...string) Labels {
+	out := make(Labels, len(l)+len(pairs)/2)
+	for k, v := range l {
+		out[k] = v
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		out[pairs[i]] = pairs[i+1]
+	}
+	return out
+}
+
+type sample struct {
+	suffix string // _bucket, _sum, _count for histograms
+	labels Labels
+	value  float64
+}
+
+// Family is a named metric and all of its samples
+type Family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+// Add records a sample
+func (f *Family) Add(labels Labels, value float64) {
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// addSuffixed records a histogram component sample such as name_bucket
+func (f *Family) addSuffixed(suffix string, labels Labels, value float64) {
+	f.samples = append(f.samples, sample{suffix: suffix, labels: labels, value: value})
+}
+
+// Writer collects metric families and renders them in the Prometheus text format.
+// Families are written in the order they were first requested.
+type Writer struct {
+	families []*Family
+	byName   map[string]*Family
+}
+
+// NewWriter creates an empty writer
+func NewWriter() *Writer {
+	return &Writer{byName: make(map[string]*Family)}
+}
+
+// Family returns the family with the given name, creating it on first use
+func (w *Writer) Family(name, help, typ string) *Family {
+	if f, ok := w.byName[name]; ok {
+		return f
+	}
+	f := &Family{name: name, help: help, typ: typ}
+	w.families = append(w.families, f)
+	w.byName[name] = f
+	return f
+}
+
+// WriteTo renders every family that has samples
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	bw := bufio.NewWriter(out)
+	var n int64
+	write := func(s string) {
+		m, _ := bw.WriteString(s)
+		n += int64(m)
+	}
+
+	for _, f := range w.families {
+		if len(f.samples) == 0 {
+			continue
+		}
+		write("# HELP " + f.name + " " + escapeHelp(f.help) + "\n")
+		write("# TYPE " + f.name + " " + f.typ + "\n")
+		for _, s := range f.samples {
+			write(f.name + s.suffix + formatLabels(s.labels) + " " + formatValue(s.value) + "\n")
+		}
+	}
+
+	return n, bw.Flush()
+}
+
+// formatLabels renders {a="1",b="2"} with names sorted for stable output
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(labels[name]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func escapeHelp(v string) string {
+	return helpEscaper.Replace(v)
+}
+
+// formatValue renders a sample value, including the special float values
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,41 @@
+package exporter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriter_TextFormat(t *testing.T) {
+	w := NewWriter()
+	up := w.Family("flint_libvirt_up", "Whether the libvirt connection answered", Gauge)
+	up.Add(Labels{"server_id": "hv-01"}, 1)
+	up.Add(Labels{"server_id": `odd"name\`}, 0)
+	w.Family("flint_unused", "Never sampled", Gauge)
+
+	durations := NewRequestDurations([]float64{0.1, 1})
+	durations.Observe("GET", "/api/vms", "200", 50*time.Millisecond)
+	durations.Observe("GET", "/api/vms", "200", 2*time.Second)
+	durations.Collect(w, "flint_http_request_duration_seconds", "HTTP request latency")
+
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	want := `# HELP flint_libvirt_up Whether the libvirt connection answered
+# TYPE flint_libvirt_up gauge
+flint_libvirt_up{server_id="hv-01"} 1
+flint_libvirt_up{server_id="odd\"name\\"} 0
+# HELP flint_http_request_duration_seconds HTTP request latency
+# TYPE flint_http_request_duration_seconds histogram
+flint_http_request_duration_seconds_bucket{code="200",le="0.1",method="GET",route="/api/vms"} 1
+flint_http_request_duration_seconds_bucket{code="200",le="1",method="GET",route="/api/vms"} 1
+flint_http_request_duration_seconds_bucket{code="200",le="+Inf",method="GET",route="/api/vms"} 2
+flint_http_request_duration_seconds_sum{code="200",method="GET",route="/api/vms"} 2.05
+flint_http_request_duration_seconds_count{code="200",method="GET",route="/api/vms"} 2
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTo() output mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
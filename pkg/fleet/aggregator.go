@@ -0,0 +1,204 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+// How long a single host may take to answer a fleet query
+const (
+	DefaultTimeout = 10 * time.Second
+	MaxTimeout     = 2 * time.Minute
+)
+
+// Registry lists the servers a fleet query covers (*serverregistry.Registry)
+type Registry interface {
+	ListServers() []*core.ServerConfig
+}
+
+// Pool hands out a client for each server (*connectionpool.Pool)
+type Pool interface {
+	GetConnection(config *core.ServerConfig) (libvirtclient.ClientInterface, error)
+}
+
+// Aggregator fans read-only queries out to every registered server
+type Aggregator struct {
+	registry Registry
+	pool     Pool
+	timeout  time.Duration
+}
+
+// NewAggregator creates a new fleet aggregator. The timeout defaults to
+// DefaultTimeout and is capped at MaxTimeout.
+func NewAggregator(registry Registry, pool Pool, timeout time.Duration) *Aggregator {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if timeout > MaxTimeout {
+		timeout = MaxTimeout
+	}
+
+	return &Aggregator{
+		registry: registry,
+		pool:     pool,
+		timeout:  timeout,
+	}
+}
+
+// hostResult holds the outcome of querying a single server
+type hostResult[T any] struct {
+	server *core.ServerConfig
+	items  []T
+	err    *core.FleetError
+}
+
+// VMs returns the VM summaries of every server
+func (a *Aggregator) VMs(ctx context.Context) core.FleetVMList {
+	results := collect(ctx, a, func(client libvirtclient.ClientInterface) ([]core.VM_Summary, error) {
+		return client.GetVMSummaries()
+	})
+
+	list := core.FleetVMList{Items: []core.FleetVM{}, Errors: []core.FleetError{}, Servers: len(results)}
+	for _, res := range results {
+		if res.err != nil {
+			list.Errors = append(list.Errors, *res.err)
+			continue
+		}
+		for _, vm := range res.items {
+			list.Items = append(list.Items, core.FleetVM{
+				ServerID:   res.server.ID,
+				ServerName: res.server.Name,
+				VM_Summary: vm,
+			})
+		}
+	}
+
+	return list
+}
+
+// StoragePools returns the storage pools of every server
+func (a *Aggregator) StoragePools(ctx context.Context) core.FleetStoragePoolList {
+	results := collect(ctx, a, func(client libvirtclient.ClientInterface) ([]core.StoragePool, error) {
+		return client.GetStoragePools()
+	})
+
+	list := core.FleetStoragePoolList{Items: []core.FleetStoragePool{}, Errors: []core.FleetError{}, Servers: len(results)}
+	for _, res := range results {
+		if res.err != nil {
+			list.Errors = append(list.Errors, *res.err)
+			continue
+		}
+		for _, pool := range res.items {
+			list.Items = append(list.Items, core.FleetStoragePool{
+				ServerID:    res.server.ID,
+				ServerName:  res.server.Name,
+				StoragePool: pool,
+			})
+		}
+	}
+
+	return list
+}
+
+// Networks returns the virtual networks of every server
+func (a *Aggregator) Networks(ctx context.Context) core.FleetNetworkList {
+	results := collect(ctx, a, func(client libvirtclient.ClientInterface) ([]core.Network, error) {
+		return client.GetNetworks()
+	})
+
+	list := core.FleetNetworkList{Items: []core.FleetNetwork{}, Errors: []core.FleetError{}, Servers: len(results)}
+	for _, res := range results {
+		if res.err != nil {
+			list.Errors = append(list.Errors, *res.err)
+			continue
+		}
+		for _, network := range res.items {
+			list.Items = append(list.Items, core.FleetNetwork{
+				ServerID:   res.server.ID,
+				ServerName: res.server.Name,
+				Network:    network,
+			})
+		}
+	}
+
+	return list
+}
+
+// collect runs fetch against every registered server concurrently. A server that
+// fails or exceeds the timeout is reported as an error without affecting the others.
+// Results are returned in server name order.
+func collect[T any](ctx context.Context, a *Aggregator, fetch func(libvirtclient.ClientInterface) ([]T, error)) []hostResult[T] {
+	servers := a.registry.ListServers()
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Name < servers[j].Name
+	})
+
+	results := make([]hostResult[T], len(servers))
+	done := make(chan int, len(servers))
+
+	for i, server := range servers {
+		go func(i int, server *core.ServerConfig) {
+			results[i] = queryHost(ctx, a, server, fetch)
+			done <- i
+		}(i, server)
+	}
+
+	for range servers {
+		<-done
+	}
+
+	return results
+}
+
+// queryHost resolves a pooled client for server and runs fetch under the
+// aggregator timeout
+func queryHost[T any](ctx context.Context, a *Aggregator, server *core.ServerConfig, fetch func(libvirtclient.ClientInterface) ([]T, error)) hostResult[T] {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	type outcome struct {
+		items []T
+		err   error
+	}
+
+	// Buffered so a slow host can finish after we stop waiting for it
+	ch := make(chan outcome, 1)
+	go func() {
+		client, err := a.pool.GetConnection(server)
+		if err != nil {
+			ch <- outcome{err: err}
+			return
+		}
+		items, err := fetch(client)
+		ch <- outcome{items: items, err: err}
+	}()
+
+	select {
+	case out := <-ch:
+		if out.err != nil {
+			return hostResult[T]{server: server, err: hostError(server, out.err, false)}
+		}
+		return hostResult[T]{server: server, items: out.items}
+	case <-ctx.Done():
+		err := fmt.Errorf("timed out after %s", a.timeout)
+		if ctx.Err() == context.Canceled {
+			err = ctx.Err()
+		}
+		return hostResult[T]{server: server, err: hostError(server, err, ctx.Err() == context.DeadlineExceeded)}
+	}
+}
+
+// hostError builds the per-server error entry for a fleet response
+func hostError(server *core.ServerConfig, err error, timedOut bool) *core.FleetError {
+	return &core.FleetError{
+		ServerID:   server.ID,
+		ServerName: server.Name,
+		Error:      err.Error(),
+		TimedOut:   timedOut,
+	}
+}
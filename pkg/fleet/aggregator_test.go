@@ -0,0 +1,182 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+// fakeRegistry lists a fixed set of servers
+type fakeRegistry []*core.ServerConfig
+
+func (r fakeRegistry) ListServers() []*core.ServerConfig { return r }
+
+// fakePool hands out a fake client per server ID, or fails to connect
+type fakePool struct {
+	clients map[string]*fakeClient
+	failing map[string]error
+}
+
+func (p *fakePool) GetConnection(config *core.ServerConfig) (libvirtclient.ClientInterface, error) {
+	if err := p.failing[config.ID]; err != nil {
+		return nil, err
+	}
+	return p.clients[config.ID], nil
+}
+
+// fakeClient answers fleet queries; a client with hang set blocks until it is closed
+type fakeClient struct {
+	libvirtclient.ClientInterface
+	vms      []core.VM_Summary
+	pools    []core.StoragePool
+	networks []core.Network
+	err      error
+	hang     chan struct{}
+}
+
+func (c *fakeClient) wait() {
+	if c.hang != nil {
+		<-c.hang
+	}
+}
+
+func (c *fakeClient) GetVMSummaries() ([]core.VM_Summary, error) {
+	c.wait()
+	return c.vms, c.err
+}
+
+func (c *fakeClient) GetStoragePools() ([]core.StoragePool, error) {
+	c.wait()
+	return c.pools, c.err
+}
+
+func (c *fakeClient) GetNetworks() ([]core.Network, error) {
+	c.wait()
+	return c.networks, c.err
+}
+
+// newFleet builds four servers, listed out of name order: one healthy, one
+// whose libvirt calls fail, one that cannot be reached and one that hangs
+func newFleet(t *testing.T) (fakeRegistry, *fakePool) {
+	hang := make(chan struct{})
+	t.Cleanup(func() { close(hang) })
+
+	registry := fakeRegistry{
+		{ID: "s4", Name: "delta"},
+		{ID: "s2", Name: "bravo"},
+		{ID: "s1", Name: "alpha"},
+		{ID: "s3", Name: "charlie"},
+	}
+	pool := &fakePool{
+		clients: map[string]*fakeClient{
+			"s1": {
+				vms:      []core.VM_Summary{{Name: "web-01"}, {Name: "web-02"}},
+				pools:    []core.StoragePool{{Name: "default"}},
+				networks: []core.Network{{Name: "default"}},
+			},
+			"s2": {err: errors.New("libvirt: internal error")},
+			"s4": {hang: hang},
+		},
+		failing: map[string]error{"s3": errors.New("failed to connect to server charlie: no route to host")},
+	}
+	return registry, pool
+}
+
+func TestAggregator_VMs(t *testing.T) {
+	registry, pool := newFleet(t)
+	aggregator := NewAggregator(registry, pool, 50*time.Millisecond)
+
+	start := time.Now()
+	list := aggregator.VMs(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("VMs() took %s, expected the hanging host to time out", elapsed)
+	}
+
+	if list.Servers != 4 {
+		t.Errorf("Servers = %d, want 4", list.Servers)
+	}
+	if len(list.Items) != 2 {
+		t.Fatalf("got %d VMs, want 2", len(list.Items))
+	}
+	for _, vm := range list.Items {
+		if vm.ServerID != "s1" || vm.ServerName != "alpha" {
+			t.Errorf("VM %s attributed to %s (%s)", vm.Name, vm.ServerName, vm.ServerID)
+		}
+	}
+
+	want := []struct {
+		serverID string
+		errPart  string
+		timedOut bool
+	}{
+		{"s2", "internal error", false},
+		{"s3", "no route to host", false},
+		{"s4", "timed out after 50ms", true},
+	}
+	if len(list.Errors) != len(want) {
+		t.Fatalf("got %d errors, want %d: %+v", len(list.Errors), len(want), list.Errors)
+	}
+	for i, w := range want {
+		got := list.Errors[i]
+		if got.ServerID != w.serverID || !strings.Contains(got.Error, w.errPart) || got.TimedOut != w.timedOut {
+			t.Errorf("errors[%d] = %+v, want server %s with %q, timed out %v", i, got, w.serverID, w.errPart, w.timedOut)
+		}
+	}
+}
+
+func TestAggregator_PoolsAndNetworks(t *testing.T) {
+	registry, pool := newFleet(t)
+	aggregator := NewAggregator(registry, pool, 50*time.Millisecond)
+
+	pools := aggregator.StoragePools(context.Background())
+	if len(pools.Items) != 1 || pools.Items[0].ServerName != "alpha" || len(pools.Errors) != 3 {
+		t.Errorf("unexpected storage pools %+v", pools)
+	}
+	networks := aggregator.Networks(context.Background())
+	if len(networks.Items) != 1 || networks.Items[0].ServerID != "s1" || len(networks.Errors) != 3 {
+		t.Errorf("unexpected networks %+v", networks)
+	}
+}
+
+func TestAggregator_Cancelled(t *testing.T) {
+	registry, pool := newFleet(t)
+	aggregator := NewAggregator(registry, pool, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	results := collect(ctx, aggregator, func(client libvirtclient.ClientInterface) ([]core.VM_Summary, error) {
+		return client.GetVMSummaries()
+	})
+	last := results[len(results)-1]
+	if last.server.ID != "s4" || last.err == nil || last.err.TimedOut || last.err.Error != context.Canceled.Error() {
+		t.Errorf("hanging host after cancel = %+v, want a cancelled, not timed out, error", last.err)
+	}
+	if results[0].err != nil || len(results[0].items) != 2 {
+		t.Errorf("healthy host = %+v, want its VMs", results[0])
+	}
+}
+
+func TestNewAggregator_Timeout(t *testing.T) {
+	tests := []struct {
+		timeout, want time.Duration
+	}{
+		{0, DefaultTimeout},
+		{-time.Second, DefaultTimeout},
+		{30 * time.Second, 30 * time.Second},
+		{time.Hour, MaxTimeout},
+	}
+	for _, tt := range tests {
+		if got := NewAggregator(fakeRegistry{}, &fakePool{}, tt.timeout).timeout; got != tt.want {
+			t.Errorf("NewAggregator(%s).timeout = %s, want %s", tt.timeout, got, tt.want)
+		}
+	}
+}
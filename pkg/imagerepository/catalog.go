@@ -0,0 +1,375 @@
+package imagerepository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/volantvm/flint/pkg/download"
+)
+
+// maxCatalogSize bounds the catalog files read; simplestreams product files
+// of public mirrors run to tens of megabytes
+const maxCatalogSize = 64 << 20
+
+var (
+	// ErrCatalogNotFound is returned for unknown catalog names
+	ErrCatalogNotFound = errors.New("image catalog not found")
+	// ErrCatalogExists is returned when adding a catalog under a name in use
+	ErrCatalogExists = errors.New("image catalog already exists")
+)
+
+// namePattern restricts catalog names and image IDs, which end up in URLs and file names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Catalog is a user-defined list of cloud images, read from a local YAML or
+// JSON file or fetched from a URL. Its images are kept from the last
+// successful refresh.
+type Catalog struct {
+	Name         string       `json:"name"`
+	Location     string       `json:"location"`               // Path of a local file, or an HTTP(S) URL
+	Architecture string       `json:"architecture,omitempty"` // Only images for this architecture are kept; empty keeps all
+	CreatedAt    time.Time    `json:"created_at"`
+	LastRefresh  *time.Time   `json:"last_refresh,omitempty"`
+	LastError    string       `json:"last_error,omitempty"` // Error of the last refresh, if it failed
+	ImageCount   int          `json:"image_count"`
+	Images       []CloudImage `json:"images,omitempty"`
+}
+
+// CatalogRequest is the request to add a catalog
+type CatalogRequest struct {
+	Name         string `json:"name"`
+	Location     string `json:"location"`
+	Architecture string `json:"architecture,omitempty"`
+}
+
+// CatalogStore persists image catalogs and the images last read from them
+type CatalogStore struct {
+	catalogs    map[string]*Catalog
+	mu          sync.RWMutex
+	storagePath string
+
+	Downloader *download.Downloader // Fetches remote catalogs; a default one is used when nil
+}
+
+// NewCatalogStore creates a new catalog store
+func NewCatalogStore(storagePath string) (*CatalogStore, error) {
+	if storagePath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		storagePath = filepath.Join(homeDir, ".flint", "image-catalogs.json")
+	}
+
+	store := &CatalogStore{
+		catalogs:    make(map[string]*Catalog),
+		storagePath: storagePath,
+	}
+
+	if err := store.load(); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load image catalog store: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// Add reads a new catalog and saves it. Catalogs that cannot be read, or
+// have no images for the requested architecture, are not added.
+func (s *CatalogStore) Add(ctx context.Context, req CatalogRequest) (*Catalog, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Location = strings.TrimSpace(req.Location)
+	if !namePattern.MatchString(req.Name) || req.Name == "refresh" {
+		return nil, fmt.Errorf("invalid catalog name: %q", req.Name)
+	}
+	if req.Location == "" {
+		return nil, errors.New("catalog location is required")
+	}
+	if isURL(req.Location) {
+		if err := download.ValidateURL(req.Location); err != nil {
+			return nil, err
+		}
+	} else if !filepath.IsAbs(req.Location) {
+		return nil, fmt.Errorf("catalog location must be an HTTP(S) URL or an absolute path: %s", req.Location)
+	}
+
+	s.mu.RLock()
+	_, exists := s.catalogs[req.Name]
+	s.mu.RUnlock()
+	if exists {
+		return nil, fmt.Errorf("%w: %s", ErrCatalogExists, req.Name)
+	}
+
+	catalog := &Catalog{
+		Name:         req.Name,
+		Location:     req.Location,
+		Architecture: NormalizeArchitecture(req.Architecture),
+		CreatedAt:    time.Now(),
+	}
+	images, err := s.read(ctx, catalog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog %s: %w", req.Name, err)
+	}
+	catalog.LastRefresh = &catalog.CreatedAt
+	catalog.Images = images
+	catalog.ImageCount = len(images)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.catalogs[catalog.Name]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrCatalogExists, catalog.Name)
+	}
+	s.catalogs[catalog.Name] = catalog
+
+	if err := s.save(); err != nil {
+		delete(s.catalogs, catalog.Name)
+		return nil, fmt.Errorf("failed to save image catalog store: %w", err)
+	}
+
+	return summary(catalog), nil
+}
+
+// Get retrieves a catalog with its images
+func (s *CatalogStore) Get(name string) (*Catalog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	catalog, ok := s.catalogs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCatalogNotFound, name)
+	}
+	catalogCopy := *catalog
+	catalogCopy.Images = append([]CloudImage(nil), catalog.Images...)
+	return &catalogCopy, nil
+}
+
+// List returns all catalogs sorted by name, without their images
+func (s *CatalogStore) List() []Catalog {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	catalogs := make([]Catalog, 0, len(s.catalogs))
+	for _, catalog := range s.catalogs {
+		catalogs = append(catalogs, *summary(catalog))
+	}
+	sort.Slice(catalogs, func(i, j int) bool { return catalogs[i].Name < catalogs[j].Name })
+	return catalogs
+}
+
+// Remove deletes a catalog. Images already downloaded from it are kept.
+func (s *CatalogStore) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	catalog, ok := s.catalogs[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrCatalogNotFound, name)
+	}
+	delete(s.catalogs, name)
+
+	if err := s.save(); err != nil {
+		s.catalogs[name] = catalog
+		return fmt.Errorf("failed to save image catalog store: %w", err)
+	}
+	return nil
+}
+
+// Refresh reads a catalog again. When that fails the catalog keeps its
+// previous images, and the error is both recorded and returned.
+func (s *CatalogStore) Refresh(ctx context.Context, name string) (*Catalog, error) {
+	s.mu.RLock()
+	catalog, ok := s.catalogs[name]
+	var source Catalog
+	if ok {
+		source = *catalog
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCatalogNotFound, name)
+	}
+
+	images, readErr := s.read(ctx, &source)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	catalog, ok = s.catalogs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCatalogNotFound, name)
+	}
+	previous := *catalog
+	now := time.Now()
+	catalog.LastRefresh = &now
+	if readErr != nil {
+		catalog.LastError = readErr.Error()
+	} else {
+		catalog.LastError = ""
+		catalog.Images = images
+		catalog.ImageCount = len(images)
+	}
+
+	if err := s.save(); err != nil {
+		*catalog = previous
+		return nil, fmt.Errorf("failed to save image catalog store: %w", err)
+	}
+	if readErr != nil {
+		return summary(catalog), fmt.Errorf("failed to refresh catalog %s: %w", name, readErr)
+	}
+	return summary(catalog), nil
+}
+
+// RefreshAll refreshes every catalog, returning them with the outcome of
+// their refresh in LastError
+func (s *CatalogStore) RefreshAll(ctx context.Context) []Catalog {
+	catalogs := []Catalog{}
+	for _, catalog := range s.List() {
+		if refreshed, err := s.Refresh(ctx, catalog.Name); refreshed != nil {
+			catalogs = append(catalogs, *refreshed)
+		} else if !errors.Is(err, ErrCatalogNotFound) {
+			catalog.LastError = err.Error()
+			catalogs = append(catalogs, catalog)
+		}
+	}
+	return catalogs
+}
+
+// Images returns the images of all catalogs, in catalog name order
+func (s *CatalogStore) Images() []CloudImage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.catalogs))
+	for name := range s.catalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var images []CloudImage
+	for _, name := range names {
+		images = append(images, s.catalogs[name].Images...)
+	}
+	return images
+}
+
+// read fetches and parses a catalog, keeping the images for its architecture
+func (s *CatalogStore) read(ctx context.Context, catalog *Catalog) ([]CloudImage, error) {
+	downloader := s.Downloader
+	if downloader == nil {
+		downloader = download.New(download.Config{})
+	}
+	readFile := func(location string) ([]byte, error) {
+		return readLocation(ctx, downloader, location)
+	}
+
+	data, err := readFile(catalog.Location)
+	if err != nil {
+		return nil, err
+	}
+	images, err := parseCatalog(data, catalog.Location, readFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []CloudImage
+	for _, img := range images {
+		if catalog.Architecture != "" && NormalizeArchitecture(img.Architecture) != catalog.Architecture {
+			continue
+		}
+		img.Catalog = catalog.Name
+		kept = append(kept, img)
+	}
+	if len(kept) == 0 {
+		if catalog.Architecture != "" {
+			return nil, fmt.Errorf("catalog has no %s images", catalog.Architecture)
+		}
+		return nil, errors.New("catalog has no images")
+	}
+	return kept, nil
+}
+
+// readLocation reads a catalog file from a URL or the local filesystem
+func readLocation(ctx context.Context, downloader *download.Downloader, location string) ([]byte, error) {
+	if isURL(location) {
+		return downloader.Fetch(ctx, location, maxCatalogSize)
+	}
+
+	f, err := os.Open(location)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxCatalogSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCatalogSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", location, maxCatalogSize)
+	}
+	return data, nil
+}
+
+// summary copies a catalog without its images
+func summary(catalog *Catalog) *Catalog {
+	catalogCopy := *catalog
+	catalogCopy.Images = nil
+	return &catalogCopy
+}
+
+// load reads catalogs from storage
+func (s *CatalogStore) load() error {
+	data, err := os.ReadFile(s.storagePath)
+	if err != nil {
+		return err
+	}
+
+	var stored struct {
+		Catalogs map[string]*Catalog `json:"catalogs"`
+	}
+
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to unmarshal image catalog store: %w", err)
+	}
+
+	if stored.Catalogs != nil {
+		s.catalogs = stored.Catalogs
+	}
+
+	return nil
+}
+
+// save writes catalogs to storage
+func (s *CatalogStore) save() error {
+	dir := filepath.Dir(s.storagePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	stored := struct {
+		Catalogs map[string]*Catalog `json:"catalogs"`
+	}{
+		Catalogs: s.catalogs,
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal image catalog store: %w", err)
+	}
+
+	if err := os.WriteFile(s.storagePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write image catalog store: %w", err)
+	}
+
+	return nil
+}
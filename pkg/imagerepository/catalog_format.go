@@ -0,0 +1,299 @@
+package imagerepository
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/volantvm/flint/pkg/download"
+	"gopkg.in/yaml.v3"
+)
+
+// Catalog formats. Flint catalogs list images directly; the others are
+// simplestreams documents as published by cloud-images.ubuntu.com and
+// images.linuxcontainers.org.
+const (
+	CatalogFormatFlint        = "flint:1.0"
+	CatalogFormatStreamIndex  = "index:1.0"
+	CatalogFormatStreamImages = "products:1.0"
+)
+
+// streamFileTypes are the simplestreams item types usable as VM disks, in order of preference
+var streamFileTypes = []string{"disk1.img", "disk-kvm.img", "qcow2"}
+
+// catalogDocument is the top level of a catalog in any supported format.
+// JSON catalogs are read as YAML, which covers them.
+type catalogDocument struct {
+	Format   string                      `yaml:"format"`
+	Images   []CloudImage                `yaml:"images"`
+	Index    map[string]streamIndexEntry `yaml:"index"`
+	Products map[string]streamProduct    `yaml:"products"`
+}
+
+type streamIndexEntry struct {
+	Datatype string `yaml:"datatype"`
+	Format   string `yaml:"format"`
+	Path     string `yaml:"path"`
+}
+
+type streamProduct struct {
+	Arch            string                   `yaml:"arch"`
+	OS              string                   `yaml:"os"`
+	Release         string                   `yaml:"release"`
+	ReleaseTitle    string                   `yaml:"release_title"`
+	ReleaseCodename string                   `yaml:"release_codename"`
+	Version         string                   `yaml:"version"`
+	Versions        map[string]streamVersion `yaml:"versions"`
+}
+
+type streamVersion struct {
+	Items map[string]streamItem `yaml:"items"`
+}
+
+type streamItem struct {
+	Ftype  string `yaml:"ftype"`
+	Path   string `yaml:"path"`
+	Size   int64  `yaml:"size"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// parseCatalog reads the images of a catalog found at location. readFile
+// reads the product files a simplestreams index refers to.
+func parseCatalog(data []byte, location string, readFile func(location string) ([]byte, error)) ([]CloudImage, error) {
+	var doc catalogDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %w", err)
+	}
+
+	var images []CloudImage
+	switch doc.Format {
+	case "", CatalogFormatFlint:
+		for _, img := range doc.Images {
+			img.URL = resolveReference(location, img.URL)
+			if img.ChecksumURL != "" {
+				img.ChecksumURL = resolveReference(location, img.ChecksumURL)
+			}
+			images = append(images, img)
+		}
+	case CatalogFormatStreamImages:
+		images = streamImages(doc.Products, streamRoot(location))
+	case CatalogFormatStreamIndex:
+		root := streamRoot(location)
+		keys := make([]string, 0, len(doc.Index))
+		for key := range doc.Index {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entry := doc.Index[key]
+			if entry.Datatype != "image-downloads" || entry.Format != CatalogFormatStreamImages {
+				continue
+			}
+			productData, err := readFile(resolveReference(root, entry.Path))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", entry.Path, err)
+			}
+			var products catalogDocument
+			if err := yaml.Unmarshal(productData, &products); err != nil {
+				return nil, fmt.Errorf("invalid product file %s: %w", entry.Path, err)
+			}
+			images = append(images, streamImages(products.Products, root)...)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported catalog format: %s", doc.Format)
+	}
+
+	seen := make(map[string]bool, len(images))
+	for i := range images {
+		if err := validateCatalogImage(&images[i]); err != nil {
+			return nil, err
+		}
+		if seen[images[i].ID] {
+			return nil, fmt.Errorf("duplicate image ID: %s", images[i].ID)
+		}
+		seen[images[i].ID] = true
+	}
+	return images, nil
+}
+
+// validateCatalogImage checks an image read from a catalog and fills in defaults
+func validateCatalogImage(img *CloudImage) error {
+	if !namePattern.MatchString(img.ID) {
+		return fmt.Errorf("invalid image ID: %q", img.ID)
+	}
+	if err := download.ValidateURL(img.URL); err != nil {
+		return fmt.Errorf("image %s: %w", img.ID, err)
+	}
+	if img.ChecksumURL != "" {
+		if err := download.ValidateURL(img.ChecksumURL); err != nil {
+			return fmt.Errorf("image %s: checksum URL: %w", img.ID, err)
+		}
+	}
+	if img.Checksum != "" {
+		sum := checksumHex(img.Checksum)
+		if _, err := hex.DecodeString(sum); err != nil || (len(sum) != 64 && len(sum) != 128) {
+			return fmt.Errorf("image %s: checksum is not a SHA-256 or SHA-512 hex digest", img.ID)
+		}
+	}
+	if img.Name == "" {
+		img.Name = img.ID
+	}
+	if img.Type == "" {
+		img.Type = "template"
+	}
+	return nil
+}
+
+// streamImages turns simplestreams products into images, using the newest
+// version of each that has a disk image
+func streamImages(products map[string]streamProduct, root string) []CloudImage {
+	names := make([]string, 0, len(products))
+	for name := range products {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var images []CloudImage
+	for _, name := range names {
+		product := products[name]
+		serial, item, ok := newestDiskItem(product)
+		if !ok {
+			continue
+		}
+
+		osName := product.OS
+		if osName != "" {
+			osName = strings.ToUpper(osName[:1]) + osName[1:]
+		}
+		version := product.Version
+		if version == "" {
+			version = product.Release
+		}
+		title := strings.TrimSpace(osName + " " + firstNonEmpty(product.ReleaseTitle, version))
+		if product.ReleaseCodename != "" {
+			title += " (" + product.ReleaseCodename + ")"
+		}
+
+		images = append(images, CloudImage{
+			ID:           streamImageID(name),
+			Name:         title,
+			URL:          resolveReference(root, item.Path),
+			Checksum:     item.SHA256,
+			SizeGB:       math.Round(float64(item.Size)/(1<<30)*10) / 10,
+			Type:         "template",
+			OS:           osName,
+			Version:      version,
+			Description:  fmt.Sprintf("%s, build %s", name, serial),
+			Architecture: product.Arch,
+		})
+	}
+	return images
+}
+
+// newestDiskItem finds the disk image of the newest version of a product that has one
+func newestDiskItem(product streamProduct) (string, streamItem, bool) {
+	serials := make([]string, 0, len(product.Versions))
+	for serial := range product.Versions {
+		serials = append(serials, serial)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(serials)))
+
+	for _, serial := range serials {
+		items := product.Versions[serial].Items
+		for _, ftype := range streamFileTypes {
+			for _, item := range items {
+				if item.Ftype == ftype && item.Path != "" {
+					return serial, item, true
+				}
+			}
+		}
+	}
+	return "", streamItem{}, false
+}
+
+// streamImageID makes an image ID from a product name such as
+// com.ubuntu.cloud:server:24.04:amd64
+func streamImageID(product string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, product)
+}
+
+// streamRoot returns the mirror root that simplestreams paths are relative
+// to: the part of the location before streams/v1/
+func streamRoot(location string) string {
+	if i := strings.LastIndex(location, "streams/v1/"); i >= 0 {
+		return location[:i]
+	}
+	if isURL(location) {
+		return location[:strings.LastIndex(location, "/")+1]
+	}
+	return filepath.Dir(location) + string(filepath.Separator)
+}
+
+// resolveReference resolves a URL or path in a catalog against the
+// location it was read from. Roots ending in a slash are directories.
+func resolveReference(base, ref string) string {
+	if u, err := url.Parse(ref); err == nil && u.IsAbs() {
+		return ref
+	}
+	if isURL(base) {
+		baseURL, err := url.Parse(base)
+		refURL, refErr := url.Parse(ref)
+		if err != nil || refErr != nil {
+			return ref
+		}
+		return baseURL.ResolveReference(refURL).String()
+	}
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	if strings.HasSuffix(base, string(filepath.Separator)) {
+		return filepath.Join(base, ref)
+	}
+	return filepath.Join(filepath.Dir(base), ref)
+}
+
+// NormalizeArchitecture maps architecture names to the Debian names used by
+// most catalogs, so that x86_64 matches amd64 and aarch64 matches arm64
+func NormalizeArchitecture(arch string) string {
+	arch = strings.ToLower(strings.TrimSpace(arch))
+	switch arch {
+	case "x86_64", "x86-64", "x64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "ppc64el":
+		return "ppc64le"
+	}
+	return arch
+}
+
+// checksumHex strips an algorithm prefix such as "sha256:" from a checksum
+func checksumHex(sum string) string {
+	sum = strings.ToLower(strings.TrimSpace(sum))
+	for _, prefix := range []string{"sha256:", "sha512:"} {
+		sum = strings.TrimPrefix(sum, prefix)
+	}
+	return sum
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
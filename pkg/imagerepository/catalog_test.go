@@ -0,0 +1,227 @@
+package imagerepository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const goldenCatalog = `
+images:
+  - id: golden-web
+    name: Golden Web Base
+    url: https://images.example.com/golden/web.qcow2
+    checksum: sha256:9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08
+    os: Ubuntu
+    version: 24.04
+    architecture: x86_64
+    size_gb: 2.5
+  - id: golden-db
+    url: https://images.example.com/golden/db-arm.qcow2
+    checksum_url: https://images.example.com/golden/SHA256SUMS
+    architecture: aarch64
+  - id: ubuntu-24.04-lts
+    name: Ubuntu 24.04 LTS (pinned)
+    url: https://mirror.example.com/noble-20250101.img
+    architecture: amd64
+`
+
+func writeCatalog(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "catalog.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseCatalog_Flint(t *testing.T) {
+	images, err := parseCatalog([]byte(goldenCatalog), "/etc/flint/golden.yaml", nil)
+	if err != nil {
+		t.Fatalf("parseCatalog() error = %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("parseCatalog() returned %d images, want 3", len(images))
+	}
+	web := images[0]
+	if web.Version != "24.04" || web.Type != "template" || web.SizeGB != 2.5 {
+		t.Errorf("golden-web = %+v", web)
+	}
+	if images[1].Name != "golden-db" {
+		t.Errorf("image without a name got name %q, want its ID", images[1].Name)
+	}
+
+	// The same catalog as JSON, with URLs relative to where it was fetched from
+	jsonCatalog := `{"images": [{"id": "relative", "url": "disks/relative.qcow2", "checksum_url": "SHA256SUMS"}]}`
+	images, err = parseCatalog([]byte(jsonCatalog), "https://images.example.com/catalogs/golden.json", nil)
+	if err != nil {
+		t.Fatalf("parseCatalog() of JSON error = %v", err)
+	}
+	if images[0].URL != "https://images.example.com/catalogs/disks/relative.qcow2" || images[0].ChecksumURL != "https://images.example.com/catalogs/SHA256SUMS" {
+		t.Errorf("relative URLs resolved to %q and %q", images[0].URL, images[0].ChecksumURL)
+	}
+
+	bad := map[string]string{
+		"path as ID":     `images: [{id: ../etc, url: "https://x/a.img"}]`,
+		"relative URL":   `images: [{id: a, url: a.img}]`,
+		"file URL":       `images: [{id: a, url: "file:///etc/shadow"}]`,
+		"short checksum": `images: [{id: a, url: "https://x/a.img", checksum: abc}]`,
+		"duplicate IDs":  `images: [{id: a, url: "https://x/a.img"}, {id: a, url: "https://x/b.img"}]`,
+		"unknown format": `format: products:2.0`,
+		"not a catalog":  `[1, 2`,
+	}
+	for name, catalog := range bad {
+		if _, err := parseCatalog([]byte(catalog), "/etc/flint/catalog.yaml", nil); err == nil {
+			t.Errorf("%s: parseCatalog() expected an error", name)
+		}
+	}
+}
+
+func TestParseCatalog_Simplestreams(t *testing.T) {
+	products := `{
+  "format": "products:1.0",
+  "datatype": "image-downloads",
+  "products": {
+    "com.ubuntu.cloud:server:24.04:amd64": {
+      "arch": "amd64", "os": "ubuntu", "release": "noble", "release_title": "24.04 LTS",
+      "release_codename": "Noble Numbat", "version": "24.04",
+      "versions": {
+        "20250101": {"items": {"disk1.img": {"ftype": "disk1.img", "path": "server/noble/20250101/noble-amd64.img", "size": 612368384, "sha256": "aa"}}},
+        "20250301": {"items": {
+          "root.tar.xz": {"ftype": "root.tar.xz", "path": "server/noble/20250301/noble-amd64.tar.xz"},
+          "disk1.img": {"ftype": "disk1.img", "path": "server/noble/20250301/noble-amd64.img", "size": 612368384, "sha256": "` + strings.Repeat("ab", 32) + `"}
+        }},
+        "20250401": {"items": {"root.tar.xz": {"ftype": "root.tar.xz", "path": "server/noble/20250401/noble-amd64.tar.xz"}}}
+      }
+    },
+    "com.ubuntu.cloud:server:24.04:lxd": {
+      "arch": "amd64", "os": "ubuntu", "version": "24.04",
+      "versions": {"20250301": {"items": {"lxd.tar.xz": {"ftype": "lxd.tar.xz", "path": "lxd.tar.xz"}}}}
+    }
+  }
+}`
+	index := `{"format": "index:1.0", "index": {
+  "com.ubuntu.cloud:released:download": {"datatype": "image-downloads", "format": "products:1.0", "path": "streams/v1/com.ubuntu.cloud:released:download.json"},
+  "com.ubuntu.cloud:released:aws": {"datatype": "image-ids", "format": "products:1.0", "path": "streams/v1/aws.json"}
+}}`
+
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/releases/streams/v1/index.json":
+			w.Write([]byte(index))
+		case "/releases/streams/v1/com.ubuntu.cloud:released:download.json":
+			w.Write([]byte(products))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	store, err := NewCatalogStore(filepath.Join(t.TempDir(), "image-catalogs.json"))
+	if err != nil {
+		t.Fatalf("NewCatalogStore() error = %v", err)
+	}
+	catalog, err := store.Add(context.Background(), CatalogRequest{Name: "ubuntu", Location: srv.URL + "/releases/streams/v1/index.json"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if catalog.ImageCount != 1 || len(requested) != 2 {
+		t.Fatalf("Add() = %+v after requests %v, want one image from the download stream", catalog, requested)
+	}
+
+	img := store.Images()[0]
+	want := CloudImage{
+		ID:           "com.ubuntu.cloud-server-24.04-amd64",
+		Name:         "Ubuntu 24.04 LTS (Noble Numbat)",
+		URL:          srv.URL + "/releases/server/noble/20250301/noble-amd64.img",
+		Checksum:     strings.Repeat("ab", 32),
+		SizeGB:       0.6,
+		Type:         "template",
+		OS:           "Ubuntu",
+		Version:      "24.04",
+		Description:  "com.ubuntu.cloud:server:24.04:amd64, build 20250301",
+		Architecture: "amd64",
+		Catalog:      "ubuntu",
+	}
+	if img != want {
+		t.Errorf("image = %+v\nwant %+v", img, want)
+	}
+}
+
+func TestCatalogStore(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "image-catalogs.json")
+	store, err := NewCatalogStore(storePath)
+	if err != nil {
+		t.Fatalf("NewCatalogStore() error = %v", err)
+	}
+	ctx := context.Background()
+	path := writeCatalog(t, goldenCatalog)
+
+	if _, err := store.Add(ctx, CatalogRequest{Name: "golden", Location: "catalog.yaml"}); err == nil {
+		t.Error("Add() with a relative path expected an error")
+	}
+	if _, err := store.Add(ctx, CatalogRequest{Name: "golden", Location: path, Architecture: "s390x"}); err == nil {
+		t.Error("Add() of a catalog without images for the architecture expected an error")
+	}
+	catalog, err := store.Add(ctx, CatalogRequest{Name: "golden", Location: path, Architecture: "amd64"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if catalog.ImageCount != 2 || catalog.LastRefresh == nil {
+		t.Errorf("Add() = %+v, want the two amd64 images", catalog)
+	}
+	if _, err := store.Add(ctx, CatalogRequest{Name: "golden", Location: path}); !errors.Is(err, ErrCatalogExists) {
+		t.Errorf("Add() of a duplicate error = %v, want ErrCatalogExists", err)
+	}
+
+	// Catalog images are merged with the built-ins, replacing those with the same ID
+	repo := NewImageRepository(t.TempDir())
+	repo.Catalogs = store
+	images := repo.GetImages()
+	if len(images) != len(getDefaultImages())+1 {
+		t.Errorf("GetImages() returned %d images, want the built-ins plus one", len(images))
+	}
+	pinned, _ := repo.GetImage("ubuntu-24.04-lts")
+	if pinned.Catalog != "golden" || pinned.URL != "https://mirror.example.com/noble-20250101.img" {
+		t.Errorf("GetImage() = %+v, want the catalog's pinned image", pinned)
+	}
+	for _, img := range repo.GetImagesByArchitecture("x86_64") {
+		if NormalizeArchitecture(img.Architecture) != "amd64" {
+			t.Errorf("GetImagesByArchitecture(x86_64) returned %s image %s", img.Architecture, img.ID)
+		}
+	}
+	if len(repo.GetImagesByArchitecture("arm64")) != 0 {
+		t.Error("GetImagesByArchitecture(arm64) returned images filtered out of the catalog")
+	}
+
+	// A failed refresh keeps the images of the last good one
+	os.WriteFile(path, []byte("images: [{id: broken}]"), 0644)
+	if _, err := store.Refresh(ctx, "golden"); err == nil {
+		t.Error("Refresh() of a broken catalog expected an error")
+	}
+	refreshed := store.RefreshAll(ctx)
+	if len(refreshed) != 1 || refreshed[0].LastError == "" || refreshed[0].ImageCount != 2 {
+		t.Errorf("RefreshAll() = %+v, want the error recorded and the images kept", refreshed)
+	}
+
+	reopened, err := NewCatalogStore(storePath)
+	if err != nil {
+		t.Fatalf("NewCatalogStore() reopen error = %v", err)
+	}
+	if got := reopened.Images(); len(got) != 2 || got[0].Catalog != "golden" {
+		t.Errorf("reopened store has images %+v", got)
+	}
+	if err := reopened.Remove("golden"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := reopened.Get("golden"); !errors.Is(err, ErrCatalogNotFound) {
+		t.Errorf("Get() after Remove() error = %v, want ErrCatalogNotFound", err)
+	}
+}
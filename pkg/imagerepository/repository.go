@@ -1,34 +1,40 @@
 package imagerepository
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+
+	"github.com/volantvm/flint/pkg/download"
 )
 
 // CloudImage represents a downloadable cloud image
 type CloudImage struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	URL         string  `json:"url"`
-	ChecksumURL string  `json:"checksum_url,omitempty"`
-	SizeGB      float64 `json:"size_gb"`
-	Type        string  `json:"type"`
-	OS          string  `json:"os"`
-	Version     string  `json:"version"`
-	Description string  `json:"description"`
-	Architecture string `json:"architecture"`
+	ID           string  `json:"id" yaml:"id"`
+	Name         string  `json:"name" yaml:"name"`
+	URL          string  `json:"url" yaml:"url"`
+	ChecksumURL  string  `json:"checksum_url,omitempty" yaml:"checksum_url"`
+	Checksum     string  `json:"checksum,omitempty" yaml:"checksum"` // SHA-256 or SHA-512 hex, optionally prefixed with "sha256:" or "sha512:"
+	SizeGB       float64 `json:"size_gb" yaml:"size_gb"`
+	Type         string  `json:"type" yaml:"type"`
+	OS           string  `json:"os" yaml:"os"`
+	Version      string  `json:"version" yaml:"version"`
+	Description  string  `json:"description" yaml:"description"`
+	Architecture string  `json:"architecture" yaml:"architecture"`
+	Catalog      string  `json:"catalog,omitempty" yaml:"-"` // Catalog the image comes from; empty for built-in images
 }
 
 // ImageRepository manages cloud image downloads
 type ImageRepository struct {
 	StoragePath string
 	Images      []CloudImage
+	Downloader  *download.Downloader // Shared with other downloads; a default one is used when nil
+	Catalogs    *CatalogStore        // User-defined catalogs merged with Images; optional
 }
 
 // NewImageRepository creates a new image repository
@@ -202,15 +208,34 @@ func getDefaultImages() []CloudImage {
 	}
 }
 
-// GetImages returns all available cloud images
+// GetImages returns all available cloud images: the built-in ones followed
+// by those of each catalog. A catalog image replaces an earlier one with the
+// same ID, so catalogs can pin or override built-in images.
 func (r *ImageRepository) GetImages() []CloudImage {
-	return r.Images
+	images := append([]CloudImage(nil), r.Images...)
+	if r.Catalogs == nil {
+		return images
+	}
+
+	index := make(map[string]int, len(images))
+	for i, img := range images {
+		index[img.ID] = i
+	}
+	for _, img := range r.Catalogs.Images() {
+		if i, ok := index[img.ID]; ok {
+			images[i] = img
+			continue
+		}
+		index[img.ID] = len(images)
+		images = append(images, img)
+	}
+	return images
 }
 
 // GetImagesByOS returns images filtered by operating system
 func (r *ImageRepository) GetImagesByOS(osName string) []CloudImage {
 	var filtered []CloudImage
-	for _, img := range r.Images {
+	for _, img := range r.GetImages() {
 		if strings.EqualFold(img.OS, osName) {
 			filtered = append(filtered, img)
 		}
@@ -218,17 +243,40 @@ func (r *ImageRepository) GetImagesByOS(osName string) []CloudImage {
 	return filtered
 }
 
-// DownloadImage downloads a cloud image with checksum verification
-func (r *ImageRepository) DownloadImage(imageID string, progressCallback func(downloaded, total int64)) error {
-	// Find the image
-	var image *CloudImage
-	for _, img := range r.Images {
+// GetImagesByArchitecture returns images built for an architecture, treating
+// aliases such as x86_64 and amd64 as the same
+func (r *ImageRepository) GetImagesByArchitecture(arch string) []CloudImage {
+	var filtered []CloudImage
+	for _, img := range r.GetImages() {
+		if NormalizeArchitecture(img.Architecture) == NormalizeArchitecture(arch) {
+			filtered = append(filtered, img)
+		}
+	}
+	return filtered
+}
+
+// GetImage returns an image by ID
+func (r *ImageRepository) GetImage(imageID string) (CloudImage, bool) {
+	for _, img := range r.GetImages() {
 		if img.ID == imageID {
-			image = &img
-			break
+			return img, true
 		}
 	}
-	if image == nil {
+	return CloudImage{}, false
+}
+
+// DownloadImage downloads a cloud image with checksum verification
+func (r *ImageRepository) DownloadImage(imageID string, progressCallback func(downloaded, total int64)) error {
+	return r.DownloadImageContext(context.Background(), imageID, progressCallback)
+}
+
+// DownloadImageContext is DownloadImage with cancellation. A cancelled or
+// interrupted download keeps its partial data next to the image, so the
+// image is not reported as downloaded and the next attempt resumes it.
+func (r *ImageRepository) DownloadImageContext(ctx context.Context, imageID string, progressCallback func(downloaded, total int64)) error {
+	// Find the image
+	image, ok := r.GetImage(imageID)
+	if !ok {
 		return fmt.Errorf("image not found: %s", imageID)
 	}
 
@@ -237,128 +285,58 @@ func (r *ImageRepository) DownloadImage(imageID string, progressCallback func(do
 		return fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
-	// Generate filename
-	filename := fmt.Sprintf("%s.qcow2", image.ID)
-	filepath := filepath.Join(r.StoragePath, filename)
-
 	// Check if already downloaded
-	if _, err := os.Stat(filepath); err == nil {
-		return fmt.Errorf("image already exists: %s", filename)
+	path := r.GetDownloadedImagePath(imageID)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("image already exists: %s", filepath.Base(path))
 	}
 
-	// Download the image
-	resp, err := http.Get(image.URL)
-	if err != nil {
-		return fmt.Errorf("failed to download image: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	downloader := r.Downloader
+	if downloader == nil {
+		downloader = download.New(download.Config{})
 	}
+	req := download.Request{URL: image.URL, Path: path, Progress: progressCallback}
 
-	// Create output file
-	out, err := os.Create(filepath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer out.Close()
-
-	// Download with progress tracking
-	var downloaded int64
-	total := resp.ContentLength
-	
-	buffer := make([]byte, 32*1024) // 32KB buffer
-	hash := sha256.New()
-	
-	for {
-		n, err := resp.Body.Read(buffer)
-		if n > 0 {
-			// Write to file
-			if _, writeErr := out.Write(buffer[:n]); writeErr != nil {
-				return fmt.Errorf("failed to write to file: %w", writeErr)
-			}
-			
-			// Update hash
-			hash.Write(buffer[:n])
-			
-			// Update progress
-			downloaded += int64(n)
-			if progressCallback != nil {
-				progressCallback(downloaded, total)
-			}
-		}
-		
-		if err == io.EOF {
-			break
-		}
+	// Use the catalog's checksum, or look it up in the checksum list if available
+	sum := checksumHex(image.Checksum)
+	if sum == "" && image.ChecksumURL != "" {
+		var err error
+		sum, err = r.expectedChecksum(ctx, downloader, &image)
 		if err != nil {
-			return fmt.Errorf("download error: %w", err)
+			return fmt.Errorf("checksum verification failed: %w", err)
 		}
 	}
+	if len(sum) == 128 {
+		req.SHA512 = sum
+	} else {
+		req.SHA256 = sum
+	}
 
-	// Verify checksum if available
-	if image.ChecksumURL != "" {
-		if err := r.verifyChecksum(filepath, image.ChecksumURL, filename); err != nil {
-			os.Remove(filepath) // Clean up on verification failure
+	if _, err := downloader.Download(ctx, req); err != nil {
+		if errors.Is(err, download.ErrChecksumMismatch) {
 			return fmt.Errorf("checksum verification failed: %w", err)
 		}
+		return err
 	}
-
 	return nil
 }
 
-// verifyChecksum downloads and verifies the SHA256 checksum
-func (r *ImageRepository) verifyChecksum(filePath, checksumURL, filename string) error {
-	// Download checksum file
-	resp, err := http.Get(checksumURL)
+// expectedChecksum looks up the checksum of an image in its checksum list
+func (r *ImageRepository) expectedChecksum(ctx context.Context, downloader *download.Downloader, image *CloudImage) (string, error) {
+	data, err := downloader.Fetch(ctx, image.ChecksumURL, 1<<20)
 	if err != nil {
-		return fmt.Errorf("failed to download checksum: %w", err)
+		return "", fmt.Errorf("failed to download checksum: %w", err)
 	}
-	defer resp.Body.Close()
 
-	checksumData, err := io.ReadAll(resp.Body)
+	imageURL, err := url.Parse(image.URL)
 	if err != nil {
-		return fmt.Errorf("failed to read checksum: %w", err)
+		return "", err
 	}
-
-	// Parse checksum file (format: "hash filename")
-	lines := strings.Split(string(checksumData), "\n")
-	var expectedHash string
-	
-	for _, line := range lines {
-		if strings.Contains(line, filename) || strings.Contains(line, "cloudimg-amd64.img") {
-			parts := strings.Fields(line)
-			if len(parts) >= 1 {
-				expectedHash = parts[0]
-				break
-			}
-		}
+	sum := download.ParseChecksums(data, path.Base(imageURL.Path))
+	if sum == "" {
+		return "", fmt.Errorf("checksum not found for file")
 	}
-
-	if expectedHash == "" {
-		return fmt.Errorf("checksum not found for file")
-	}
-
-	// Calculate actual hash
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file for verification: %w", err)
-	}
-	defer file.Close()
-
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return fmt.Errorf("failed to calculate hash: %w", err)
-	}
-
-	actualHash := hex.EncodeToString(hash.Sum(nil))
-
-	if actualHash != expectedHash {
-		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedHash, actualHash)
-	}
-
-	return nil
+	return sum, nil
 }
 
 // IsImageDownloaded checks if an image is already downloaded
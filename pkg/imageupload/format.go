@@ -0,0 +1,84 @@
+package imageupload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// SniffSize is how many leading bytes Detect needs to tell the supported
+// formats apart: the ISO 9660 volume descriptors start at 32 KiB
+const SniffSize = 64 << 10
+
+// isoMagicOffset is where "CD001" sits in the primary volume descriptor
+const isoMagicOffset = 32769
+
+// qcow2IncompatExternalData is the qcow2 v3 incompatible feature bit for an
+// external data file
+const qcow2IncompatExternalData = 1 << 2
+
+// ErrUnsupportedFormat is returned for images in formats the library does not accept
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// Detect returns the format of an image from its first bytes: qcow2, iso or
+// raw. VMDK, VHD(X) and VDI images are refused rather than stored as raw
+// disks, and so are qcow2 images that refer to other files on the host.
+func Detect(header []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte("QFI\xfb")):
+		if err := checkQCOW2(header); err != nil {
+			return "", err
+		}
+		return core.ImageFormatQCOW2, nil
+	case len(header) >= isoMagicOffset+5 && string(header[isoMagicOffset:isoMagicOffset+5]) == "CD001":
+		return core.ImageFormatISO, nil
+	case bytes.HasPrefix(header, []byte("KDMV")), bytes.HasPrefix(header, []byte("# Disk DescriptorFile")):
+		return "", fmt.Errorf("%w: VMDK images must be converted to qcow2 first", ErrUnsupportedFormat)
+	case bytes.HasPrefix(header, []byte("vhdxfile")), bytes.HasPrefix(header, []byte("conectix")):
+		return "", fmt.Errorf("%w: VHD images must be converted to qcow2 first", ErrUnsupportedFormat)
+	case len(header) >= 68 && binary.LittleEndian.Uint32(header[64:68]) == 0xbeda107f:
+		return "", fmt.Errorf("%w: VDI images must be converted to qcow2 first", ErrUnsupportedFormat)
+	}
+	return core.ImageFormatRaw, nil
+}
+
+// checkQCOW2 refuses qcow2 headers with a backing file or an external data
+// file, which would let an image read arbitrary files on the host
+func checkQCOW2(header []byte) error {
+	if len(header) < 72 {
+		return fmt.Errorf("%w: truncated qcow2 header", ErrUnsupportedFormat)
+	}
+	version := binary.BigEndian.Uint32(header[4:8])
+	if version != 2 && version != 3 {
+		return fmt.Errorf("%w: qcow2 version %d", ErrUnsupportedFormat, version)
+	}
+	if binary.BigEndian.Uint64(header[8:16]) != 0 {
+		return fmt.Errorf("%w: qcow2 images with a backing file are not accepted", ErrUnsupportedFormat)
+	}
+	if version == 3 {
+		if len(header) < 80 {
+			return fmt.Errorf("%w: truncated qcow2 header", ErrUnsupportedFormat)
+		}
+		if binary.BigEndian.Uint64(header[72:80])&qcow2IncompatExternalData != 0 {
+			return fmt.Errorf("%w: qcow2 images with an external data file are not accepted", ErrUnsupportedFormat)
+		}
+	}
+	return nil
+}
+
+// checkName makes sure the image name matches the detected format, since the
+// library tells ISOs from disk images by their extension
+func checkName(name, format string) error {
+	iso := strings.HasSuffix(strings.ToLower(name), ".iso")
+	if format == core.ImageFormatISO && !iso {
+		return fmt.Errorf("%w: ISO images must be named *.iso", ErrUnsupportedFormat)
+	}
+	if format != core.ImageFormatISO && iso {
+		return fmt.Errorf("%w: %s is a %s image, not an ISO", ErrUnsupportedFormat, name, format)
+	}
+	return nil
+}
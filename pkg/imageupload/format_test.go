@@ -0,0 +1,79 @@
+package imageupload
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// qcow2Header returns a qcow2 header of the given version
+func qcow2Header(version uint32) []byte {
+	h := make([]byte, 512)
+	copy(h, "QFI\xfb")
+	binary.BigEndian.PutUint32(h[4:8], version)
+	return h
+}
+
+func TestDetect(t *testing.T) {
+	iso := make([]byte, SniffSize)
+	copy(iso[isoMagicOffset:], "CD001")
+
+	backing := qcow2Header(3)
+	binary.BigEndian.PutUint64(backing[8:16], 512)
+	dataFile := qcow2Header(3)
+	binary.BigEndian.PutUint64(dataFile[72:80], qcow2IncompatExternalData)
+	vdi := make([]byte, 128)
+	binary.LittleEndian.PutUint32(vdi[64:68], 0xbeda107f)
+
+	tests := []struct {
+		name    string
+		header  []byte
+		want    string
+		wantErr bool
+	}{
+		{"qcow2 v3", qcow2Header(3), core.ImageFormatQCOW2, false},
+		{"qcow2 v2", qcow2Header(2), core.ImageFormatQCOW2, false},
+		{"iso", iso, core.ImageFormatISO, false},
+		{"raw", []byte("\xeb\x63\x90 boot sector"), core.ImageFormatRaw, false},
+		{"empty", nil, core.ImageFormatRaw, false},
+		{"qcow2 backing file", backing, "", true},
+		{"qcow2 external data file", dataFile, "", true},
+		{"qcow2 truncated", []byte("QFI\xfb\x00\x00\x00\x03"), "", true},
+		{"qcow2 unknown version", qcow2Header(4), "", true},
+		{"vmdk", []byte("KDMV\x01\x00\x00\x00"), "", true},
+		{"vmdk descriptor", []byte("# Disk DescriptorFile\nversion=1"), "", true},
+		{"vhdx", []byte("vhdxfile"), "", true},
+		{"vdi", vdi, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Detect(tt.header)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedFormat) {
+					t.Errorf("Detect() error = %v, want ErrUnsupportedFormat", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Detect() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	if err := checkName("debian.iso", core.ImageFormatISO); err != nil {
+		t.Errorf("checkName(iso) error = %v", err)
+	}
+	if err := checkName("debian.ISO", core.ImageFormatISO); err != nil {
+		t.Errorf("checkName(ISO) error = %v", err)
+	}
+	if err := checkName("debian.img", core.ImageFormatISO); err == nil {
+		t.Error("checkName() expected an error for an ISO without .iso")
+	}
+	if err := checkName("disk.iso", core.ImageFormatQCOW2); err == nil {
+		t.Error("checkName() expected an error for a disk image named .iso")
+	}
+}
@@ -0,0 +1,548 @@
+// Package imageupload streams images into the image library, in a single
+// request or as resumable chunked uploads, checking their format, size and
+// checksum on the way in.
+package imageupload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// DefaultTTL is how long an idle upload is kept before it is discarded
+const DefaultTTL = 24 * time.Hour
+
+var (
+	// ErrUploadNotFound is returned for unknown upload IDs
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrOffsetMismatch is returned for chunks that do not start where the upload stands
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrUploadBusy is returned while another chunk of the same upload is being received
+	ErrUploadBusy = errors.New("upload is receiving another chunk")
+	// ErrTooLarge is returned for images over the size limit or chunks past the declared size
+	ErrTooLarge = errors.New("image too large")
+	// ErrChecksumMismatch is returned when the received data does not match the expected SHA-256
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrImageExists is returned when the library already has an image of that name
+	ErrImageExists = errors.New("image already exists")
+	// ErrInvalidUpload is returned for malformed upload requests
+	ErrInvalidUpload = errors.New("invalid upload")
+)
+
+var (
+	imageNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+	sha256Pattern    = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// Uploader writes image volumes; libvirtclient.Client implements it
+type Uploader interface {
+	GetImages() ([]core.Image, error)
+	CreateImageUploadVolume(name string, sizeBytes uint64) error
+	UploadImage(ctx context.Context, name string, offset uint64, r io.Reader) (int64, error)
+	FinishImageUpload(name string) (core.Image, error)
+	DeleteImage(imageId string) error
+}
+
+// ClientFunc returns the uploader for a server ID ("" for the local connection)
+type ClientFunc func(serverID string) (Uploader, error)
+
+// upload is a resumable upload along with the state of its running checksum
+type upload struct {
+	core.ImageUpload
+	HashState []byte `json:"hashState,omitempty"`
+
+	busy bool
+}
+
+// Manager tracks resumable uploads, persisting them so that they survive restarts
+type Manager struct {
+	uploads     map[string]*upload
+	mu          sync.Mutex
+	storagePath string
+	maxSize     int64 // 0 means no limit
+	ttl         time.Duration
+	clients     ClientFunc
+	now         func() time.Time
+}
+
+// NewManager creates an upload manager. Uploads are stored in
+// ~/.flint/image-uploads.json when storagePath is empty; clients is used to
+// discard the volumes of expired uploads.
+func NewManager(storagePath string, maxSize int64, ttl time.Duration, clients ClientFunc) (*Manager, error) {
+	if storagePath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		storagePath = filepath.Join(homeDir, ".flint", "image-uploads.json")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	m := &Manager{
+		uploads:     make(map[string]*upload),
+		storagePath: storagePath,
+		maxSize:     maxSize,
+		ttl:         ttl,
+		clients:     clients,
+		now:         time.Now,
+	}
+
+	if err := m.load(); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load image upload store: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+// MaxSize returns the largest image accepted, 0 meaning no limit
+func (m *Manager) MaxSize() int64 {
+	return m.maxSize
+}
+
+// Begin starts a resumable upload, creating the volume the data is written to
+func (m *Manager) Begin(client Uploader, serverID string, req core.CreateImageUploadRequest) (core.ImageUpload, error) {
+	if err := m.validate(&req, true); err != nil {
+		return core.ImageUpload{}, err
+	}
+	m.expire()
+
+	m.mu.Lock()
+	for _, u := range m.uploads {
+		if u.ServerID == serverID && u.Name == req.Name && !u.Completed {
+			m.mu.Unlock()
+			return core.ImageUpload{}, fmt.Errorf("%w: an upload of %s is already in progress", ErrImageExists, req.Name)
+		}
+	}
+	m.mu.Unlock()
+
+	if err := createVolume(client, req.Name, req.SizeBytes); err != nil {
+		return core.ImageUpload{}, err
+	}
+
+	now := m.now()
+	u := &upload{ImageUpload: core.ImageUpload{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		ServerID:  serverID,
+		SizeBytes: req.SizeBytes,
+		SHA256:    req.SHA256,
+		Format:    req.Format,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(m.ttl),
+	}}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads[u.ID] = u
+	if err := m.save(); err != nil {
+		delete(m.uploads, u.ID)
+		client.DeleteImage(u.Name)
+		return core.ImageUpload{}, fmt.Errorf("failed to save image upload store: %w", err)
+	}
+	return u.ImageUpload, nil
+}
+
+// Write appends a chunk to an upload. The chunk must start at the upload's
+// current offset; a failed chunk leaves the offset unchanged so that it can
+// be sent again. The first chunk must hold at least SniffSize bytes (or the
+// whole image) for the format to be detected. Once the last byte arrives the
+// checksum is verified and the image is added to the library.
+func (m *Manager) Write(ctx context.Context, client Uploader, serverID, id string, offset int64, r io.Reader) (core.ImageUpload, error) {
+	m.mu.Lock()
+	u, ok := m.uploads[id]
+	if !ok || u.ServerID != serverID {
+		m.mu.Unlock()
+		return core.ImageUpload{}, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+	if u.busy {
+		m.mu.Unlock()
+		return u.ImageUpload, ErrUploadBusy
+	}
+	if u.Completed {
+		m.mu.Unlock()
+		return u.ImageUpload, fmt.Errorf("%w: upload is already complete", ErrOffsetMismatch)
+	}
+	if offset != u.Offset {
+		m.mu.Unlock()
+		return u.ImageUpload, fmt.Errorf("%w: upload is at byte %d, not %d", ErrOffsetMismatch, u.Offset, offset)
+	}
+	u.busy = true
+	current := *u
+	m.mu.Unlock()
+
+	res, err := m.transfer(ctx, client, &current, r)
+	complete := err == nil && res.offset == current.SizeBytes
+	var image core.Image
+	if complete {
+		current.Format = res.format
+		image, err = finalize(client, &current.ImageUpload, res.sum)
+	}
+	// A refused format or a failed final check cannot be fixed by resending
+	fatal := complete || errors.Is(err, ErrUnsupportedFormat)
+
+	m.mu.Lock()
+	u.busy = false
+	if err != nil {
+		state := u.ImageUpload
+		if fatal {
+			delete(m.uploads, id)
+			m.save()
+		}
+		m.mu.Unlock()
+		if fatal && !complete {
+			client.DeleteImage(state.Name)
+		}
+		return state, err
+	}
+	defer m.mu.Unlock()
+
+	now := m.now()
+	u.Offset = res.offset
+	u.Format = res.format
+	u.HashState = res.hashState
+	u.UpdatedAt = now
+	u.ExpiresAt = now.Add(m.ttl)
+	if complete {
+		u.Completed = true
+		u.HashState = nil
+		u.Image = &image
+	}
+	if err := m.save(); err != nil {
+		return u.ImageUpload, fmt.Errorf("failed to save image upload store: %w", err)
+	}
+	return u.ImageUpload, nil
+}
+
+// Upload stores an image sent in one piece. The size may be 0 when it is not
+// known in advance, in which case the size limit is enforced as data arrives.
+// Nothing is kept if the upload fails.
+func (m *Manager) Upload(ctx context.Context, client Uploader, req core.CreateImageUploadRequest, r io.Reader) (core.Image, error) {
+	if err := m.validate(&req, false); err != nil {
+		return core.Image{}, err
+	}
+	if err := createVolume(client, req.Name, req.SizeBytes); err != nil {
+		return core.Image{}, err
+	}
+
+	u := &upload{ImageUpload: core.ImageUpload{
+		Name:      req.Name,
+		SizeBytes: req.SizeBytes,
+		SHA256:    req.SHA256,
+		Format:    req.Format,
+	}}
+	res, err := m.transfer(ctx, client, u, r)
+	if err == nil && res.offset == 0 {
+		err = fmt.Errorf("%w: image is empty", ErrInvalidUpload)
+	}
+	if err == nil && req.SizeBytes > 0 && res.offset != req.SizeBytes {
+		err = fmt.Errorf("%w: received %d of %d bytes", ErrInvalidUpload, res.offset, req.SizeBytes)
+	}
+	if err != nil {
+		client.DeleteImage(req.Name)
+		return core.Image{}, err
+	}
+
+	u.Format = res.format
+	return finalize(client, &u.ImageUpload, res.sum)
+}
+
+// Get returns an upload of a server
+func (m *Manager) Get(serverID, id string) (core.ImageUpload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.uploads[id]
+	if !ok || u.ServerID != serverID {
+		return core.ImageUpload{}, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+	return u.ImageUpload, nil
+}
+
+// List returns the uploads of a server, oldest first
+func (m *Manager) List(serverID string) []core.ImageUpload {
+	m.expire()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	uploads := []core.ImageUpload{}
+	for _, u := range m.uploads {
+		if u.ServerID == serverID {
+			uploads = append(uploads, u.ImageUpload)
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].CreatedAt.Before(uploads[j].CreatedAt)
+	})
+	return uploads
+}
+
+// Abort cancels an upload and deletes the partial image. Completed uploads
+// are only forgotten; their image stays in the library.
+func (m *Manager) Abort(client Uploader, serverID, id string) error {
+	m.mu.Lock()
+	u, ok := m.uploads[id]
+	if !ok || u.ServerID != serverID {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+	if u.busy {
+		m.mu.Unlock()
+		return ErrUploadBusy
+	}
+	delete(m.uploads, id)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to save image upload store: %w", err)
+	}
+
+	if !u.Completed {
+		if err := client.DeleteImage(u.Name); err != nil {
+			return fmt.Errorf("failed to delete partial image %s: %w", u.Name, err)
+		}
+	}
+	return nil
+}
+
+// expire forgets uploads that have been idle for longer than the TTL and
+// deletes the partial images of those that never completed
+func (m *Manager) expire() {
+	m.mu.Lock()
+	now := m.now()
+	var stale []*upload
+	for id, u := range m.uploads {
+		if !u.busy && now.After(u.ExpiresAt) {
+			delete(m.uploads, id)
+			stale = append(stale, u)
+		}
+	}
+	if len(stale) > 0 {
+		m.save()
+	}
+	m.mu.Unlock()
+
+	for _, u := range stale {
+		if u.Completed || m.clients == nil {
+			continue
+		}
+		if client, err := m.clients(u.ServerID); err == nil {
+			client.DeleteImage(u.Name)
+		}
+	}
+}
+
+// validate checks an upload request, normalizing its checksum
+func (m *Manager) validate(req *core.CreateImageUploadRequest, sizeRequired bool) error {
+	if !imageNamePattern.MatchString(req.Name) || len(req.Name) > 255 || strings.HasSuffix(req.Name, ".part") {
+		return fmt.Errorf("%w: invalid image name %q", ErrInvalidUpload, req.Name)
+	}
+	if req.SizeBytes < 0 || (sizeRequired && req.SizeBytes == 0) {
+		return fmt.Errorf("%w: sizeBytes must be positive", ErrInvalidUpload)
+	}
+	if m.maxSize > 0 && req.SizeBytes > m.maxSize {
+		return fmt.Errorf("%w: %d bytes is over the %d byte limit", ErrTooLarge, req.SizeBytes, m.maxSize)
+	}
+	req.SHA256 = strings.ToLower(req.SHA256)
+	if req.SHA256 != "" && !sha256Pattern.MatchString(req.SHA256) {
+		return fmt.Errorf("%w: sha256 must be 64 hex digits", ErrInvalidUpload)
+	}
+	switch req.Format {
+	case "", core.ImageFormatQCOW2, core.ImageFormatRaw, core.ImageFormatISO:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, req.Format)
+	}
+	return nil
+}
+
+// createVolume creates the volume for an upload unless the image exists
+func createVolume(client Uploader, name string, size int64) error {
+	images, err := client.GetImages()
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if image.Name == name {
+			return fmt.Errorf("%w: %s", ErrImageExists, name)
+		}
+	}
+	return client.CreateImageUploadVolume(name, uint64(size))
+}
+
+// transferResult is the state of an upload after a chunk has been written
+type transferResult struct {
+	offset    int64
+	format    string
+	hashState []byte
+	sum       string
+}
+
+// transfer writes a chunk to the volume of an upload, detecting the format
+// from the first chunk and carrying the running checksum over
+func (m *Manager) transfer(ctx context.Context, client Uploader, u *upload, r io.Reader) (transferResult, error) {
+	res := transferResult{offset: u.Offset, format: u.Format}
+
+	src := r
+	switch {
+	case u.SizeBytes > 0:
+		src = &limitReader{r: r, n: u.SizeBytes - u.Offset,
+			err: fmt.Errorf("%w: data runs past the declared size of %d bytes", ErrTooLarge, u.SizeBytes)}
+	case m.maxSize > 0:
+		src = &limitReader{r: r, n: m.maxSize,
+			err: fmt.Errorf("%w: over the %d byte limit", ErrTooLarge, m.maxSize)}
+	}
+
+	if u.Offset == 0 {
+		header := make([]byte, SniffSize)
+		n, err := io.ReadFull(src, header)
+		header = header[:n]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if u.SizeBytes > 0 && int64(n) != u.SizeBytes {
+				return res, fmt.Errorf("%w: the first chunk must hold at least %d bytes", ErrInvalidUpload, SniffSize)
+			}
+		} else if err != nil {
+			return res, err
+		}
+
+		format, err := Detect(header)
+		if err != nil {
+			return res, err
+		}
+		if u.Format != "" && format != u.Format {
+			return res, fmt.Errorf("%w: expected a %s image, got %s", ErrUnsupportedFormat, u.Format, format)
+		}
+		if err := checkName(u.Name, format); err != nil {
+			return res, err
+		}
+		res.format = format
+		src = io.MultiReader(bytes.NewReader(header), src)
+	}
+
+	h := sha256.New()
+	if len(u.HashState) > 0 {
+		if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(u.HashState); err != nil {
+			return res, fmt.Errorf("failed to restore checksum state: %w", err)
+		}
+	}
+
+	n, err := client.UploadImage(ctx, u.Name, uint64(u.Offset), io.TeeReader(src, h))
+	if err != nil {
+		return res, err
+	}
+
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return res, fmt.Errorf("failed to save checksum state: %w", err)
+	}
+	res.offset = u.Offset + n
+	res.hashState = state
+	res.sum = hex.EncodeToString(h.Sum(nil))
+	return res, nil
+}
+
+// finalize verifies the checksum of a complete upload and adds the image to
+// the library, deleting it if either step fails
+func finalize(client Uploader, u *core.ImageUpload, sum string) (core.Image, error) {
+	if u.SHA256 != "" && sum != u.SHA256 {
+		client.DeleteImage(u.Name)
+		return core.Image{}, fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, u.SHA256, sum)
+	}
+	image, err := client.FinishImageUpload(u.Name)
+	if err != nil {
+		client.DeleteImage(u.Name)
+		return core.Image{}, err
+	}
+	return image, nil
+}
+
+// limitReader reads at most n bytes and fails with err if there is more data
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, l.err
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// load reads uploads from storage
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.storagePath)
+	if err != nil {
+		return err
+	}
+
+	var stored struct {
+		Uploads map[string]*upload `json:"uploads"`
+	}
+
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to unmarshal image upload store: %w", err)
+	}
+
+	if stored.Uploads != nil {
+		m.uploads = stored.Uploads
+	}
+
+	return nil
+}
+
+// save writes uploads to storage
+func (m *Manager) save() error {
+	dir := filepath.Dir(m.storagePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	stored := struct {
+		Uploads map[string]*upload `json:"uploads"`
+	}{
+		Uploads: m.uploads,
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal image upload store: %w", err)
+	}
+
+	if err := os.WriteFile(m.storagePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write image upload store: %w", err)
+	}
+
+	return nil
+}
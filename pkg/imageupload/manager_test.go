@@ -0,0 +1,271 @@
+package imageupload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// fakeUploader keeps volumes in memory
+type fakeUploader struct {
+	mu       sync.Mutex
+	volumes  map[string][]byte
+	finished map[string]bool
+	failNext error
+}
+
+func newFakeUploader() *fakeUploader {
+	return &fakeUploader{volumes: make(map[string][]byte), finished: make(map[string]bool)}
+}
+
+func (f *fakeUploader) GetImages() ([]core.Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var images []core.Image
+	for name, data := range f.volumes {
+		images = append(images, core.Image{ID: name, Name: name, SizeB: uint64(len(data))})
+	}
+	return images, nil
+}
+
+func (f *fakeUploader) CreateImageUploadVolume(name string, sizeBytes uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.volumes[name] = []byte{}
+	return nil
+}
+
+func (f *fakeUploader) UploadImage(ctx context.Context, name string, offset uint64, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failNext != nil {
+		err, f.failNext = f.failNext, nil
+		return 0, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	vol, ok := f.volumes[name]
+	if !ok {
+		return 0, fmt.Errorf("image not found: %s", name)
+	}
+	if uint64(len(vol)) < offset {
+		vol = append(vol, make([]byte, int(offset)-len(vol))...)
+	}
+	f.volumes[name] = append(vol[:offset], data...)
+	return int64(len(data)), nil
+}
+
+func (f *fakeUploader) FinishImageUpload(name string) (core.Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.finished[name] = true
+	return core.Image{ID: name, Name: name, SizeB: uint64(len(f.volumes[name]))}, nil
+}
+
+func (f *fakeUploader) DeleteImage(imageId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.volumes[imageId]; !ok {
+		return fmt.Errorf("image not found: %s", imageId)
+	}
+	delete(f.volumes, imageId)
+	return nil
+}
+
+func (f *fakeUploader) has(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.volumes[name]
+	return ok
+}
+
+// testImage returns a qcow2-looking image of the given size and its SHA-256
+func testImage(size int) ([]byte, string) {
+	data := make([]byte, size)
+	copy(data, qcow2Header(3))
+	for i := 512; i < size; i++ {
+		data[i] = byte(i % 251)
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:])
+}
+
+func newTestManager(t *testing.T, maxSize int64, client Uploader) (*Manager, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "image-uploads.json")
+	m, err := NewManager(path, maxSize, time.Hour, func(string) (Uploader, error) { return client, nil })
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	return m, path
+}
+
+func TestManager_ResumableUpload(t *testing.T) {
+	client := newFakeUploader()
+	m, path := newTestManager(t, 0, client)
+	data, sum := testImage(3*SniffSize + 123)
+
+	up, err := m.Begin(client, "", core.CreateImageUploadRequest{Name: "disk.qcow2", SizeBytes: int64(len(data)), SHA256: strings.ToUpper(sum)})
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, err := m.Begin(client, "", core.CreateImageUploadRequest{Name: "disk.qcow2", SizeBytes: 10}); !errors.Is(err, ErrImageExists) {
+		t.Errorf("Begin() twice error = %v, want ErrImageExists", err)
+	}
+
+	// The first chunk must be large enough to detect the format
+	if _, err := m.Write(context.Background(), client, "", up.ID, 0, bytes.NewReader(data[:100])); !errors.Is(err, ErrInvalidUpload) {
+		t.Errorf("Write() short first chunk error = %v, want ErrInvalidUpload", err)
+	}
+
+	chunk := SniffSize + 7
+	state, err := m.Write(context.Background(), client, "", up.ID, 0, bytes.NewReader(data[:chunk]))
+	if err != nil {
+		t.Fatalf("Write() first chunk error = %v", err)
+	}
+	if state.Offset != int64(chunk) || state.Format != core.ImageFormatQCOW2 {
+		t.Errorf("Write() = %+v, want offset %d and qcow2", state, chunk)
+	}
+
+	if _, err := m.Write(context.Background(), client, "", up.ID, 0, bytes.NewReader(data[:chunk])); !errors.Is(err, ErrOffsetMismatch) {
+		t.Errorf("Write() at a stale offset error = %v, want ErrOffsetMismatch", err)
+	}
+	if _, err := m.Write(context.Background(), client, "other", up.ID, int64(chunk), bytes.NewReader(nil)); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Write() from another server error = %v, want ErrUploadNotFound", err)
+	}
+
+	// A failed chunk leaves the offset where it was
+	client.failNext = errors.New("connection reset")
+	if _, err := m.Write(context.Background(), client, "", up.ID, int64(chunk), bytes.NewReader(data[chunk:2*chunk])); err == nil {
+		t.Fatal("Write() expected the injected error")
+	}
+
+	// The checksum state survives a restart
+	reopened, err := NewManager(path, 0, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("NewManager() reopen error = %v", err)
+	}
+	got, err := reopened.Get("", up.ID)
+	if err != nil || got.Offset != int64(chunk) {
+		t.Fatalf("Get() after reopen = %+v, %v", got, err)
+	}
+
+	if _, err := reopened.Write(context.Background(), client, "", up.ID, int64(chunk), bytes.NewReader(data[chunk:2*chunk])); err != nil {
+		t.Fatalf("Write() second chunk error = %v", err)
+	}
+	if _, err := reopened.Write(context.Background(), client, "", up.ID, int64(2*chunk), bytes.NewReader(append(data[2*chunk:], 0))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Write() past the declared size error = %v, want ErrTooLarge", err)
+	}
+	done, err := reopened.Write(context.Background(), client, "", up.ID, int64(2*chunk), bytes.NewReader(data[2*chunk:]))
+	if err != nil {
+		t.Fatalf("Write() last chunk error = %v", err)
+	}
+	if !done.Completed || done.Image == nil || done.Image.SizeB != uint64(len(data)) {
+		t.Errorf("Write() last chunk = %+v, want a completed upload", done)
+	}
+	if !bytes.Equal(client.volumes["disk.qcow2"], data) || !client.finished["disk.qcow2"] {
+		t.Error("uploaded image does not match the data sent")
+	}
+
+	// Forgetting a completed upload keeps the image
+	if err := reopened.Abort(client, "", up.ID); err != nil {
+		t.Fatalf("Abort() error = %v", err)
+	}
+	if !client.has("disk.qcow2") {
+		t.Error("Abort() of a completed upload deleted the image")
+	}
+	if list := reopened.List(""); len(list) != 0 {
+		t.Errorf("List() after Abort() = %+v", list)
+	}
+}
+
+func TestManager_ChecksumMismatch(t *testing.T) {
+	client := newFakeUploader()
+	m, _ := newTestManager(t, 0, client)
+	data, _ := testImage(1000)
+
+	up, err := m.Begin(client, "", core.CreateImageUploadRequest{Name: "disk.qcow2", SizeBytes: int64(len(data)), SHA256: strings.Repeat("0", 64)})
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, err := m.Write(context.Background(), client, "", up.ID, 0, bytes.NewReader(data)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Write() error = %v, want ErrChecksumMismatch", err)
+	}
+	if client.has("disk.qcow2") {
+		t.Error("image with a bad checksum was kept")
+	}
+	if _, err := m.Get("", up.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Get() after a checksum mismatch error = %v, want ErrUploadNotFound", err)
+	}
+}
+
+func TestManager_Upload(t *testing.T) {
+	client := newFakeUploader()
+	m, _ := newTestManager(t, 4096, client)
+
+	data, sum := testImage(2048)
+	image, err := m.Upload(context.Background(), client, core.CreateImageUploadRequest{Name: "small.qcow2", SHA256: sum}, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if image.Name != "small.qcow2" || image.SizeB != 2048 {
+		t.Errorf("Upload() = %+v", image)
+	}
+	if _, err := m.Upload(context.Background(), client, core.CreateImageUploadRequest{Name: "small.qcow2"}, bytes.NewReader(data)); !errors.Is(err, ErrImageExists) {
+		t.Errorf("Upload() of an existing image error = %v, want ErrImageExists", err)
+	}
+
+	// Unknown sizes are cut off at the limit
+	big, _ := testImage(5000)
+	if _, err := m.Upload(context.Background(), client, core.CreateImageUploadRequest{Name: "big.qcow2"}, bytes.NewReader(big)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Upload() over the limit error = %v, want ErrTooLarge", err)
+	}
+	if client.has("big.qcow2") {
+		t.Error("oversized upload was kept")
+	}
+	if _, err := m.Upload(context.Background(), client, core.CreateImageUploadRequest{Name: "big.qcow2", SizeBytes: 5000}, bytes.NewReader(big)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Upload() with a declared size over the limit error = %v, want ErrTooLarge", err)
+	}
+
+	// Formats are sniffed from the data, not trusted from the name
+	if _, err := m.Upload(context.Background(), client, core.CreateImageUploadRequest{Name: "disk.iso"}, bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Upload() of a qcow2 named .iso error = %v, want ErrUnsupportedFormat", err)
+	}
+	if _, err := m.Upload(context.Background(), client, core.CreateImageUploadRequest{Name: "disk.img", Format: core.ImageFormatRaw}, bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Upload() with the wrong expected format error = %v, want ErrUnsupportedFormat", err)
+	}
+	if _, err := m.Upload(context.Background(), client, core.CreateImageUploadRequest{Name: "../etc/passwd"}, bytes.NewReader(data)); !errors.Is(err, ErrInvalidUpload) {
+		t.Errorf("Upload() with a path error = %v, want ErrInvalidUpload", err)
+	}
+}
+
+func TestManager_Expire(t *testing.T) {
+	client := newFakeUploader()
+	m, _ := newTestManager(t, 0, client)
+
+	up, err := m.Begin(client, "", core.CreateImageUploadRequest{Name: "stale.img", SizeBytes: 100})
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	m.now = func() time.Time { return up.ExpiresAt.Add(time.Second) }
+
+	if list := m.List(""); len(list) != 0 {
+		t.Errorf("List() = %+v, want the stale upload expired", list)
+	}
+	if client.has("stale.img") {
+		t.Error("expired upload's partial image was kept")
+	}
+}
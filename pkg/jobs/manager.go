@@ -0,0 +1,312 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+const (
+	// DefaultWorkers is how many short jobs may run at once
+	DefaultWorkers = 4
+	// DefaultLongWorkers is how many long jobs may run at once
+	DefaultLongWorkers = 4
+	// DefaultRetention is how long finished jobs stay queryable
+	DefaultRetention = 24 * time.Hour
+)
+
+var (
+	// ErrNotFound is returned for unknown job IDs
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when cancelling a job that already stopped
+	ErrFinished = errors.New("job already finished")
+)
+
+// Pool selects the workers a job runs on. Long jobs have their own workers so a
+// few backups or downloads never hold up a VM being created.
+type Pool int
+
+const (
+	// Short jobs are libvirt operations such as creating, cloning or snapshotting a VM
+	Short Pool = iota
+	// Long jobs move bulk data or fan out over many VMs, such as backups,
+	// exports, migrations, downloads, apply and bulk actions
+	Long
+)
+
+// Func is the work performed by a job. It should honour ctx cancellation where
+// it can and report progress through p. The returned value becomes the job result.
+type Func func(ctx context.Context, p *Progress) (interface{}, error)
+
+// Progress lets a running job report how far along it is
+type Progress struct {
+	manager *Manager
+	id      string
+}
+
+// Update sets the job's progress percentage (0-100) and an optional status message
+func (p *Progress) Update(percent float64, message string) {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+
+	p.manager.mu.Lock()
+	defer p.manager.mu.Unlock()
+
+	if e, exists := p.manager.jobs[p.id]; exists && e.job.State == core.JobRunning {
+		e.job.Progress = percent
+		if message != "" {
+			e.job.Message = message
+		}
+	}
+}
+
+// Filter narrows the jobs returned by List; empty fields match everything
+type Filter struct {
+	Type     string
+	Target   string
+	ServerID string
+	State    core.JobState
+}
+
+// entry is the manager's bookkeeping for one job
+type entry struct {
+	job    core.Job
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Manager runs slow operations in the background and tracks their state
+type Manager struct {
+	jobs      map[string]*entry
+	mu        sync.RWMutex
+	slots     map[Pool]chan struct{}
+	retention time.Duration
+}
+
+// NewManager creates a job manager running at most workers short jobs and
+// longWorkers long jobs concurrently
+func NewManager(workers, longWorkers int, retention time.Duration) *Manager {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if longWorkers <= 0 {
+		longWorkers = DefaultLongWorkers
+	}
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+
+	return &Manager{
+		jobs: make(map[string]*entry),
+		slots: map[Pool]chan struct{}{
+			Short: make(chan struct{}, workers),
+			Long:  make(chan struct{}, longWorkers),
+		},
+		retention: retention,
+	}
+}
+
+// Submit queues fn as a new short job and returns it immediately
+func (m *Manager) Submit(jobType, target, serverID string, fn Func) core.Job {
+	return m.SubmitTo(Short, jobType, target, serverID, fn)
+}
+
+// SubmitTo queues fn as a new job on the given pool and returns it immediately
+func (m *Manager) SubmitTo(pool Pool, jobType, target, serverID string, fn Func) core.Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &entry{
+		job: core.Job{
+			ID:        uuid.New().String(),
+			Type:      jobType,
+			Target:    target,
+			ServerID:  serverID,
+			State:     core.JobQueued,
+			CreatedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	m.jobs[e.job.ID] = e
+	job := e.job
+	m.mu.Unlock()
+
+	go m.run(ctx, m.slots[pool], e, fn)
+
+	return job
+}
+
+// run waits for a free worker slot in its pool and executes the job
+func (m *Manager) run(ctx context.Context, slots chan struct{}, e *entry, fn Func) {
+	defer close(e.done)
+	defer e.cancel()
+
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+	}
+
+	// A slot may free up at the same moment the job is cancelled; never start it then
+	if ctx.Err() != nil {
+		m.finish(e, nil, ctx.Err(), true)
+		return
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	e.job.State = core.JobRunning
+	e.job.StartedAt = &now
+	id := e.job.ID
+	m.mu.Unlock()
+
+	// A job that completes despite a late cancel request still counts as succeeded
+	result, err := m.call(ctx, id, fn)
+	m.finish(e, result, err, err != nil && ctx.Err() != nil)
+}
+
+// call runs fn, turning a panic into a job failure rather than crashing the server
+func (m *Manager) call(ctx context.Context, id string, fn Func) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return fn(ctx, &Progress{manager: m, id: id})
+}
+
+// finish records the outcome of a job
+func (m *Manager) finish(e *entry, result interface{}, err error, cancelled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	e.job.FinishedAt = &now
+
+	switch {
+	case cancelled:
+		e.job.State = core.JobCancelled
+		e.job.Message = "Cancelled"
+	case err != nil:
+		e.job.State = core.JobFailed
+		e.job.Error = err.Error()
+	default:
+		e.job.State = core.JobSucceeded
+		e.job.Progress = 100
+		e.job.Result = result
+	}
+
+	if err != nil && !cancelled {
+		logger.Warn("Job failed", map[string]interface{}{
+			"job_id": e.job.ID,
+			"type":   e.job.Type,
+			"target": e.job.Target,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// Get returns a snapshot of a job
+func (m *Manager) Get(id string) (core.Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	e, exists := m.jobs[id]
+	if !exists {
+		return core.Job{}, ErrNotFound
+	}
+	return e.job, nil
+}
+
+// List returns the jobs matching filter, newest first
+func (m *Manager) List(filter Filter) []core.Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := make([]core.Job, 0, len(m.jobs))
+	for _, e := range m.jobs {
+		if filter.Type != "" && e.job.Type != filter.Type {
+			continue
+		}
+		if filter.Target != "" && e.job.Target != filter.Target {
+			continue
+		}
+		if filter.ServerID != "" && e.job.ServerID != filter.ServerID {
+			continue
+		}
+		if filter.State != "" && e.job.State != filter.State {
+			continue
+		}
+		jobs = append(jobs, e.job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+
+	return jobs
+}
+
+// Cancel stops a job. Queued jobs are cancelled straight away; running jobs have
+// their context cancelled and stop as soon as the underlying operation allows.
+func (m *Manager) Cancel(id string) (core.Job, error) {
+	m.mu.Lock()
+	e, exists := m.jobs[id]
+	if !exists {
+		m.mu.Unlock()
+		return core.Job{}, ErrNotFound
+	}
+	if e.job.State.IsFinal() {
+		job := e.job
+		m.mu.Unlock()
+		return job, ErrFinished
+	}
+	if e.job.State == core.JobRunning {
+		e.job.Message = "Cancelling"
+	}
+	job := e.job
+	m.mu.Unlock()
+
+	e.cancel()
+	return job, nil
+}
+
+// Wait blocks until the job finishes or ctx is done
+func (m *Manager) Wait(ctx context.Context, id string) (core.Job, error) {
+	m.mu.RLock()
+	e, exists := m.jobs[id]
+	m.mu.RUnlock()
+	if !exists {
+		return core.Job{}, ErrNotFound
+	}
+
+	select {
+	case <-e.done:
+		return m.Get(id)
+	case <-ctx.Done():
+		job, _ := m.Get(id)
+		return job, ctx.Err()
+	}
+}
+
+// pruneLocked drops finished jobs older than the retention period. Callers must hold m.mu.
+func (m *Manager) pruneLocked() {
+	cutoff := time.Now().Add(-m.retention)
+	for id, e := range m.jobs {
+		if e.job.FinishedAt != nil && e.job.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
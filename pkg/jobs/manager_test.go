@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+func waitFor(t *testing.T, m *Manager, id string) core.Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := m.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("Wait(%s) failed: %v", id, err)
+	}
+	return job
+}
+
+func TestManager_SucceededAndFailed(t *testing.T) {
+	m := NewManager(2, 0, time.Hour)
+
+	ok := m.Submit("vm.create", "web-01", "", func(ctx context.Context, p *Progress) (interface{}, error) {
+		p.Update(50, "halfway")
+		return "done", nil
+	})
+	failed := m.Submit("vm.create", "web-02", "", func(ctx context.Context, p *Progress) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+
+	if job := waitFor(t, m, ok.ID); job.State != core.JobSucceeded || job.Progress != 100 || job.Result != "done" {
+		t.Errorf("Expected succeeded job with result, got %+v", job)
+	}
+	if job := waitFor(t, m, failed.ID); job.State != core.JobFailed || job.Error != "boom" {
+		t.Errorf("Expected failed job with error, got %+v", job)
+	}
+
+	if jobs := m.List(Filter{State: core.JobFailed}); len(jobs) != 1 || jobs[0].ID != failed.ID {
+		t.Errorf("Expected only the failed job, got %+v", jobs)
+	}
+}
+
+func TestManager_Cancel(t *testing.T) {
+	m := NewManager(1, 0, time.Hour)
+
+	started := make(chan struct{})
+	running := m.Submit("image.download", "ubuntu", "", func(ctx context.Context, p *Progress) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+
+	// Only one worker slot, so this job stays queued behind the running one
+	queued := m.Submit("image.download", "debian", "", func(ctx context.Context, p *Progress) (interface{}, error) {
+		t.Error("Cancelled queued job should never run")
+		return nil, nil
+	})
+
+	if _, err := m.Cancel(queued.ID); err != nil {
+		t.Fatalf("Cancel(queued) failed: %v", err)
+	}
+	if _, err := m.Cancel(running.ID); err != nil {
+		t.Fatalf("Cancel(running) failed: %v", err)
+	}
+
+	for _, id := range []string{queued.ID, running.ID} {
+		if job := waitFor(t, m, id); job.State != core.JobCancelled {
+			t.Errorf("Expected job %s to be cancelled, got %s", id, job.State)
+		}
+	}
+
+	if _, err := m.Cancel(running.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Expected ErrFinished, got %v", err)
+	}
+	if _, err := m.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestManager_LongJobsDoNotBlockShortJobs(t *testing.T) {
+	m := NewManager(1, 1, time.Hour)
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	m.SubmitTo(Long, "vm.backup", "web-01", "", func(ctx context.Context, p *Progress) (interface{}, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	<-started
+
+	// The only long worker is busy, so a second long job waits
+	queued := m.SubmitTo(Long, "vm.export", "web-02", "", func(ctx context.Context, p *Progress) (interface{}, error) {
+		return nil, nil
+	})
+	short := m.Submit("vm.create", "web-03", "", func(ctx context.Context, p *Progress) (interface{}, error) {
+		return "created", nil
+	})
+
+	if job := waitFor(t, m, short.ID); job.State != core.JobSucceeded {
+		t.Errorf("Expected the short job to run beside the long one, got %+v", job)
+	}
+	if job, _ := m.Get(queued.ID); job.State != core.JobQueued {
+		t.Errorf("Expected the second long job to be queued, got %s", job.State)
+	}
+}
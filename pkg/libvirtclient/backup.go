@@ -0,0 +1,623 @@
+package libvirtclient
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/backup"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// backupPollInterval is how often a running backup job is checked
+const backupPollInterval = time.Second
+
+// backupCheckpointPrefix names the checkpoints Flint creates for incremental backups
+const backupCheckpointPrefix = "flint-backup-"
+
+// backupDisk is a disk taking part in a backup
+type backupDisk struct {
+	target string
+	bus    string
+	path   string
+	format string
+	size   uint64
+}
+
+// domainBackupXML is libvirt's <domainbackup> push-mode request
+type domainBackupXML struct {
+	XMLName     xml.Name              `xml:"domainbackup"`
+	Mode        string                `xml:"mode,attr"`
+	Incremental string                `xml:"incremental,omitempty"`
+	Disks       []domainBackupDiskXML `xml:"disks>disk"`
+}
+
+type domainBackupDiskXML struct {
+	Name   string `xml:"name,attr"`
+	Backup string `xml:"backup,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Target *struct {
+		File string `xml:"file,attr"`
+	} `xml:"target,omitempty"`
+	Driver *snapshotDriverXML `xml:"driver,omitempty"`
+}
+
+// domainCheckpointXML is the checkpoint created alongside a backup
+type domainCheckpointXML struct {
+	XMLName     xml.Name                  `xml:"domaincheckpoint"`
+	Name        string                    `xml:"name"`
+	Description string                    `xml:"description,omitempty"`
+	Disks       []domainCheckpointDiskXML `xml:"disks>disk"`
+}
+
+type domainCheckpointDiskXML struct {
+	Name       string `xml:"name,attr"`
+	Checkpoint string `xml:"checkpoint,attr"`
+}
+
+// BackupVM copies a VM's definition and disks into a backup repository. Running
+// VMs are copied consistently with libvirt's backup API and get a checkpoint so
+// the next backup can be incremental; shut-off VMs are copied with qemu-img.
+// Disks are stored as (optionally compressed) qcow2 images and checksummed.
+func (c *Client) BackupVM(ctx context.Context, uuidStr string, req core.CreateBackupRequest, progress func(percent float64, message string)) (core.Backup, error) {
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+	if err := c.requireLocal("backups"); err != nil {
+		return core.Backup{}, err
+	}
+	repo, err := backup.NewRepository(req.Repository)
+	if err != nil {
+		return core.Backup{}, err
+	}
+
+	dom, err := c.conn.LookupDomainByUUIDString(uuidStr)
+	if err != nil {
+		return core.Backup{}, fmt.Errorf("lookup domain: %w", err)
+	}
+	defer dom.Free()
+
+	name, _ := dom.GetName()
+	active, err := dom.IsActive()
+	if err != nil {
+		return core.Backup{}, fmt.Errorf("get domain state: %w", err)
+	}
+	xmlDesc, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE | libvirt.DOMAIN_XML_SECURE)
+	if err != nil {
+		return core.Backup{}, fmt.Errorf("domain xml: %w", err)
+	}
+	liveXML, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return core.Backup{}, fmt.Errorf("domain xml: %w", err)
+	}
+
+	disks, err := c.backupDisks(dom, liveXML)
+	if err != nil {
+		return core.Backup{}, err
+	}
+	if len(disks) == 0 {
+		return core.Backup{}, fmt.Errorf("VM '%s' has no disks to back up", name)
+	}
+
+	compress := req.Compress == nil || *req.Compress
+	started := time.Now()
+	b := core.Backup{
+		ID:         backup.NewID(started),
+		VMName:     name,
+		VMUUID:     uuidStr,
+		Kind:       core.BackupFull,
+		Live:       active,
+		Compressed: compress,
+		CreatedAt:  started,
+		DomainXML:  core.BackupFile{File: backup.DomainXMLFile},
+	}
+
+	var parent core.Backup
+	if req.Incremental {
+		if !active {
+			return core.Backup{}, fmt.Errorf("incremental backups need VM '%s' to be running", name)
+		}
+		parent, err = repo.Get(uuidStr, "")
+		if err != nil {
+			return core.Backup{}, fmt.Errorf("no previous backup to build on; take a full backup first: %w", err)
+		}
+		if err := checkIncrementalParent(dom, parent, disks); err != nil {
+			return core.Backup{}, err
+		}
+		b.Kind = core.BackupIncremental
+		b.Parent = parent.ID
+	}
+
+	dir, err := repo.Create(uuidStr, b.ID)
+	if err != nil {
+		return core.Backup{}, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	if err := os.WriteFile(filepath.Join(dir, backup.DomainXMLFile), []byte(xmlDesc), 0600); err != nil {
+		cleanup()
+		return core.Backup{}, fmt.Errorf("write domain xml: %w", err)
+	}
+
+	for _, d := range disks {
+		b.Disks = append(b.Disks, core.BackupDisk{
+			Target:           d.target,
+			SourcePath:       d.path,
+			SourceFormat:     d.format,
+			VirtualSizeBytes: d.size,
+			BackupFile:       core.BackupFile{File: d.target + ".qcow2"},
+		})
+	}
+
+	if active {
+		err = c.backupRunningDomain(ctx, dom, &b, parent, disks, dir, compress, progress)
+	} else {
+		err = backupShutOffDomain(ctx, disks, dir, compress, progress)
+	}
+	if err != nil {
+		cleanup()
+		c.logger.Add("VM Backup", name, "Error", fmt.Sprintf("Backup failed: %v", err))
+		return core.Backup{}, err
+	}
+
+	progress(99, "Writing checksums")
+	b.DurationMs = time.Since(started).Milliseconds()
+	if err := repo.Commit(&b); err != nil {
+		cleanup()
+		if b.Checkpoint != "" {
+			deleteCheckpoint(dom, b.Checkpoint)
+		}
+		return core.Backup{}, err
+	}
+
+	c.logger.Add("VM Backup", name, "Success", fmt.Sprintf("%s backup %s (%d disks, %d MiB) to %s",
+		b.Kind, b.ID, len(b.Disks), b.SizeBytes/(1024*1024), repo.Dir()))
+	return b, nil
+}
+
+// backupRunningDomain runs a push-mode libvirt backup into dir, then rebases
+// incremental images onto their parent and compresses them
+func (c *Client) backupRunningDomain(ctx context.Context, dom *libvirt.Domain, b *core.Backup, parent core.Backup, disks []backupDisk, dir string, compress bool, progress func(float64, string)) error {
+	request := domainBackupXML{Mode: "push", Incremental: parent.Checkpoint}
+	checkpoint := domainCheckpointXML{
+		Name:        backupCheckpointPrefix + b.ID,
+		Description: "Changes since Flint backup " + b.ID,
+	}
+	bitmaps := true
+	for _, d := range disks {
+		disk := domainBackupDiskXML{Name: d.target, Backup: "yes", Type: "file", Driver: &snapshotDriverXML{Type: "qcow2"}}
+		disk.Target = &struct {
+			File string `xml:"file,attr"`
+		}{File: filepath.Join(dir, d.target+".qcow2.part")}
+		request.Disks = append(request.Disks, disk)
+		checkpoint.Disks = append(checkpoint.Disks, domainCheckpointDiskXML{Name: d.target, Checkpoint: "bitmap"})
+		// Dirty bitmaps can only be stored in qcow2 images
+		bitmaps = bitmaps && d.format == "qcow2"
+	}
+
+	backupXML, err := xml.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("marshal backup xml: %w", err)
+	}
+	var checkpointXML []byte
+	if bitmaps {
+		if checkpointXML, err = xml.Marshal(checkpoint); err != nil {
+			return fmt.Errorf("marshal checkpoint xml: %w", err)
+		}
+		b.Checkpoint = checkpoint.Name
+	}
+
+	progress(0, "Starting backup job")
+	if err := dom.BackupBegin(string(backupXML), string(checkpointXML), 0); err != nil {
+		return fmt.Errorf("start backup: %w", err)
+	}
+	// A checkpoint without a finished backup would make the next incremental skip changes
+	fail := func(err error) error {
+		if b.Checkpoint != "" {
+			deleteCheckpoint(dom, b.Checkpoint)
+		}
+		return err
+	}
+	if err := waitForBackupJob(ctx, dom, progress); err != nil {
+		return fail(err)
+	}
+
+	for i, d := range disks {
+		part := filepath.Join(dir, d.target+".qcow2.part")
+		final := filepath.Join(dir, d.target+".qcow2")
+		progress(90+float64(i)/float64(len(disks))*9, fmt.Sprintf("Finishing %s", d.target))
+
+		var parentFile string
+		if b.Kind == core.BackupIncremental {
+			// Unchanged blocks are read from the parent backup's image
+			parentFile = filepath.Join("..", parent.ID, parentDiskFile(parent, d.target))
+			if err := runQemuImg(ctx, "rebase", "-u", "-F", "qcow2", "-b", parentFile, part); err != nil {
+				return fail(err)
+			}
+		}
+
+		if !compress {
+			if err := os.Rename(part, final); err != nil {
+				return fail(fmt.Errorf("rename %s: %w", d.target, err))
+			}
+			continue
+		}
+		args := []string{"convert", "-c", "-O", "qcow2"}
+		if parentFile != "" {
+			args = append(args, "-B", parentFile, "-F", "qcow2")
+		}
+		if err := runQemuImg(ctx, append(args, part, final)...); err != nil {
+			return fail(err)
+		}
+		os.Remove(part)
+	}
+
+	// Only the newest checkpoint is needed; older ones would keep bitmaps growing
+	if b.Checkpoint != "" {
+		if checkpoints, err := dom.ListAllCheckpoints(0); err == nil {
+			for _, cp := range checkpoints {
+				if cpName, err := cp.GetName(); err == nil && cpName != b.Checkpoint && strings.HasPrefix(cpName, backupCheckpointPrefix) {
+					cp.Delete(0)
+				}
+				cp.Free()
+			}
+		}
+	}
+	return nil
+}
+
+// backupShutOffDomain copies each disk, flattening any backing chain, with qemu-img
+func backupShutOffDomain(ctx context.Context, disks []backupDisk, dir string, compress bool, progress func(float64, string)) error {
+	for i, d := range disks {
+		progress(float64(i)/float64(len(disks))*90, fmt.Sprintf("Copying %s", d.target))
+		args := []string{"convert", "-O", "qcow2", "-f", d.format}
+		if compress {
+			args = append(args, "-c")
+		}
+		if err := runQemuImg(ctx, append(args, d.path, filepath.Join(dir, d.target+".qcow2"))...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForBackupJob polls the domain's backup job until it ends, aborting it if ctx is cancelled
+func waitForBackupJob(ctx context.Context, dom *libvirt.Domain, progress func(float64, string)) error {
+	ticker := time.NewTicker(backupPollInterval)
+	defer ticker.Stop()
+
+	aborted := false
+	for {
+		select {
+		case <-ctx.Done():
+			if !aborted {
+				aborted = true
+				dom.AbortJob()
+			}
+		case <-ticker.C:
+		}
+
+		info, err := dom.GetJobInfo()
+		if err != nil {
+			return fmt.Errorf("get backup job: %w", err)
+		}
+		if info.Type != libvirt.DOMAIN_JOB_NONE {
+			if info.DataTotal > 0 {
+				const mib = 1024 * 1024
+				progress(float64(info.DataProcessed)/float64(info.DataTotal)*90,
+					fmt.Sprintf("Copied %d/%d MiB", info.DataProcessed/mib, info.DataTotal/mib))
+			}
+			continue
+		}
+
+		if aborted {
+			return fmt.Errorf("backup cancelled: %w", ctx.Err())
+		}
+		// The job has ended; libvirt keeps the outcome of the last one
+		stats, err := dom.GetJobStats(libvirt.DOMAIN_JOB_STATS_COMPLETED)
+		if err == nil && stats.Type == libvirt.DOMAIN_JOB_FAILED {
+			if stats.ErrorMessageSet {
+				return fmt.Errorf("backup failed: %s", stats.ErrorMessage)
+			}
+			return errors.New("backup failed")
+		}
+		return nil
+	}
+}
+
+// checkIncrementalParent makes sure an incremental backup can build on parent
+func checkIncrementalParent(dom *libvirt.Domain, parent core.Backup, disks []backupDisk) error {
+	if parent.Checkpoint == "" {
+		return fmt.Errorf("backup %s has no checkpoint (it was taken while the VM was shut off or from non-qcow2 disks); take a full backup", parent.ID)
+	}
+	cp, err := dom.CheckpointLookupByName(parent.Checkpoint, 0)
+	if err != nil {
+		return fmt.Errorf("checkpoint %s of backup %s no longer exists; take a full backup", parent.Checkpoint, parent.ID)
+	}
+	cp.Free()
+
+	if len(disks) != len(parent.Disks) {
+		return fmt.Errorf("disks changed since backup %s; take a full backup", parent.ID)
+	}
+	for _, d := range disks {
+		if parentDiskFile(parent, d.target) == "" {
+			return fmt.Errorf("disk %s is not in backup %s; take a full backup", d.target, parent.ID)
+		}
+	}
+	return nil
+}
+
+// parentDiskFile returns the image of a disk in a backup, or "" if it has none
+func parentDiskFile(b core.Backup, target string) string {
+	for _, d := range b.Disks {
+		if d.Target == target {
+			return d.File
+		}
+	}
+	return ""
+}
+
+// backupDisks returns the writable disks of a domain with their current image and size
+func (c *Client) backupDisks(dom *libvirt.Domain, domainXML string) ([]backupDisk, error) {
+	var dx struct {
+		Devices struct {
+			Disks []struct {
+				cloneDisk
+				ReadOnly *struct{} `xml:"readonly"`
+			} `xml:"disk"`
+		} `xml:"devices"`
+	}
+	if err := xml.Unmarshal([]byte(domainXML), &dx); err != nil {
+		return nil, fmt.Errorf("parse domain XML: %w", err)
+	}
+
+	var disks []backupDisk
+	for _, d := range dx.Devices.Disks {
+		if d.Device != "disk" || d.ReadOnly != nil || d.Target.Dev == "" {
+			continue
+		}
+
+		path := d.Source.File
+		if path == "" && d.Source.Pool != "" {
+			vol, err := c.lookupDiskVolume("", d.Source.Pool, d.Source.Volume)
+			if err != nil {
+				return nil, fmt.Errorf("disk %s: %w", d.Target.Dev, err)
+			}
+			path, err = vol.GetPath()
+			vol.Free()
+			if err != nil {
+				return nil, fmt.Errorf("disk %s: get volume path: %w", d.Target.Dev, err)
+			}
+		}
+		if path == "" {
+			return nil, fmt.Errorf("disk %s has no file source and cannot be backed up", d.Target.Dev)
+		}
+
+		info, err := dom.GetBlockInfo(d.Target.Dev, 0)
+		if err != nil {
+			return nil, fmt.Errorf("disk %s: get block info: %w", d.Target.Dev, err)
+		}
+
+		format := d.Driver.Type
+		if format == "" {
+			format = "raw"
+		}
+		disks = append(disks, backupDisk{target: d.Target.Dev, bus: d.Target.Bus, path: path, format: format, size: info.Capacity})
+	}
+	return disks, nil
+}
+
+// RestoreBackup verifies a backup and its chain, copies its disks into a
+// storage pool and defines the domain from the backed-up XML, optionally
+// under a new name or with a new UUID and MAC addresses
+func (c *Client) RestoreBackup(ctx context.Context, req core.RestoreBackupRequest, progress func(percent float64, message string)) (core.VM_Detailed, error) {
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+	if err := c.requireLocal("restores"); err != nil {
+		return core.VM_Detailed{}, err
+	}
+	repo, err := backup.NewRepository(req.Repository)
+	if err != nil {
+		return core.VM_Detailed{}, err
+	}
+
+	b, err := repo.Get(req.VM, req.BackupID)
+	if err != nil {
+		return core.VM_Detailed{}, err
+	}
+	chain, err := repo.Chain(b)
+	if err != nil {
+		return core.VM_Detailed{}, err
+	}
+	progress(0, "Verifying checksums")
+	for _, link := range chain {
+		if err := backup.Verify(link); err != nil {
+			return core.VM_Detailed{}, err
+		}
+	}
+
+	name := req.Name
+	if name == "" {
+		name = b.VMName
+	}
+	if existing, err := c.conn.LookupDomainByName(name); err == nil {
+		existing.Free()
+		return core.VM_Detailed{}, fmt.Errorf("a VM named '%s' already exists", name)
+	}
+	if !req.NewUUID {
+		if existing, err := c.conn.LookupDomainByUUIDString(b.VMUUID); err == nil {
+			existing.Free()
+			return core.VM_Detailed{}, fmt.Errorf("a VM with UUID %s already exists; restore with a new UUID", b.VMUUID)
+		}
+	}
+
+	domainXML, err := os.ReadFile(filepath.Join(b.Path, b.DomainXML.File))
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("read domain xml: %w", err)
+	}
+
+	poolName := req.Pool
+	if poolName == "" {
+		poolName = flintImagePoolName
+	}
+	pool, err := c.conn.LookupStoragePoolByName(poolName)
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("lookup pool %s: %w", poolName, err)
+	}
+	defer pool.Free()
+
+	var created []createdVolume
+	rollback := func() {
+		for _, v := range created {
+			_ = c.deleteVolume(v.pool, v.name) // Best-effort cleanup
+		}
+	}
+
+	volumes := make(map[string]string) // target -> volume name
+	for i, d := range b.Disks {
+		if err := ctx.Err(); err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("restore cancelled: %w", err)
+		}
+		progress(5+float64(i)/float64(len(b.Disks))*90, fmt.Sprintf("Restoring %s", d.Target))
+
+		volName := fmt.Sprintf("%s-disk-%d.qcow2", name, i)
+		volXML := fmt.Sprintf(`<volume>
+  <name>%s</name>
+  <capacity unit="bytes">%d</capacity>
+  <target>
+    <format type="qcow2"/>
+  </target>
+</volume>`, xmlEscape(volName), d.VirtualSizeBytes)
+		vol, err := pool.StorageVolCreateXML(volXML, 0)
+		if err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("create volume for %s: %w", d.Target, err)
+		}
+		created = append(created, createdVolume{pool: poolName, name: volName})
+		volPath, err := vol.GetPath()
+		vol.Free()
+		if err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("get volume path: %w", err)
+		}
+
+		// Incremental images resolve their parents through their backing files
+		if err := runQemuImg(ctx, "convert", "-n", "-O", "qcow2", filepath.Join(b.Path, d.File), volPath); err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("restore %s: %w", d.Target, err)
+		}
+		volumes[d.Target] = volName
+	}
+
+	restoredXML, err := rewriteDiskSources(string(domainXML), volumes, poolName)
+	if err != nil {
+		rollback()
+		return core.VM_Detailed{}, err
+	}
+	restoredXML = renameDomainXML(restoredXML, name, req.NewUUID)
+
+	newDom, err := c.conn.DomainDefineXML(restoredXML)
+	if err != nil {
+		rollback()
+		return core.VM_Detailed{}, fmt.Errorf("failed to define restored domain: %w", err)
+	}
+	defer newDom.Free()
+
+	c.logger.Add("VM Restored", name, "Success", fmt.Sprintf("Restored from %s backup %s of %s", b.Kind, b.ID, b.VMName))
+
+	if req.Start {
+		if err := newDom.Create(); err != nil {
+			fmt.Printf("Warning: Failed to start restored VM %s: %v\n", name, err)
+		}
+	}
+
+	uuid, _ := newDom.GetUUIDString()
+	return c.GetVMDetails(uuid)
+}
+
+// rewriteDiskSources points a domain's disks at new volumes in a pool, by
+// target device. Drives that were not copied, such as CD-ROMs, are kept, but
+// their media is dropped if the file no longer exists.
+func rewriteDiskSources(domainXML string, volumes map[string]string, poolName string) (string, error) {
+	var rewriteErr error
+	rewritten := domainDiskPattern.ReplaceAllStringFunc(domainXML, func(block string) string {
+		var d cloneDisk
+		if err := xml.Unmarshal([]byte(block), &d); err != nil {
+			rewriteErr = fmt.Errorf("parse disk xml: %w", err)
+			return block
+		}
+
+		volName, ok := volumes[d.Target.Dev]
+		if !ok {
+			if d.Source.File != "" {
+				if _, err := os.Stat(d.Source.File); err != nil {
+					return replaceFirst(diskSourcePattern, block, "")
+				}
+			}
+			return block
+		}
+
+		if !diskSourcePattern.MatchString(block) {
+			rewriteErr = fmt.Errorf("disk %s: unsupported source element", d.Target.Dev)
+			return block
+		}
+		// Restored volumes are standalone; drop the old backing chain with the source
+		block = diskBackingPattern.ReplaceAllString(block, "")
+		block = replaceFirst(diskSourcePattern, block, fmt.Sprintf("<source pool='%s' volume='%s'/>", xmlEscape(poolName), xmlEscape(volName)))
+		block = diskTypePattern.ReplaceAllString(block, "${1}'volume'")
+		return diskDriverPattern.ReplaceAllString(block, "${1}'qcow2'")
+	})
+	return rewritten, rewriteErr
+}
+
+// renameDomainXML sets a domain's name and, with newIdentity, drops its UUID,
+// MAC addresses and NVRAM so libvirt generates fresh ones
+func renameDomainXML(domainXML, name string, newIdentity bool) string {
+	domainXML = replaceFirst(domainNamePattern, domainXML, "<name>"+xmlEscape(name)+"</name>")
+	if newIdentity {
+		domainXML = replaceFirst(domainUUIDPattern, domainXML, "")
+		domainXML = interfaceMACPattern.ReplaceAllString(domainXML, "")
+		domainXML = nvramPattern.ReplaceAllString(domainXML, "")
+	}
+	return domainXML
+}
+
+// requireLocal fails for connections to other hosts: backup files are read and
+// written by this process, so they must be on the hypervisor's filesystem
+func (c *Client) requireLocal(operation string) error {
+	uri, err := c.conn.GetURI()
+	if err != nil {
+		return fmt.Errorf("get connection uri: %w", err)
+	}
+	if u, err := url.Parse(uri); err != nil || u.Host != "" {
+		return fmt.Errorf("%s are only supported on the local host, not %s", operation, uri)
+	}
+	return nil
+}
+
+// deleteCheckpoint removes a checkpoint, ignoring errors
+func deleteCheckpoint(dom *libvirt.Domain, name string) {
+	if cp, err := dom.CheckpointLookupByName(name, 0); err == nil {
+		cp.Delete(0)
+		cp.Free()
+	}
+}
+
+// runQemuImg runs qemu-img, including its output in the error
+func runQemuImg(ctx context.Context, args ...string) error {
+	out, err := exec.CommandContext(ctx, "qemu-img", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("qemu-img %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package libvirtclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRewriteDiskSources(t *testing.T) {
+	media := filepath.Join(t.TempDir(), "install.iso")
+	if err := os.WriteFile(media, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	domainXML := `<domain><name>web-01</name><devices>
+    <disk type='file' device='disk'>
+      <driver name='qemu' type='qcow2'/>
+      <source file='/images/web-01.qcow2'/>
+      <target dev='vda' bus='virtio'/>
+      <backingStore type='file'><format type='qcow2'/><source file='/images/base.qcow2'/></backingStore>
+    </disk>
+    <disk type='file' device='disk'>
+      <driver name='qemu' type='raw'/>
+      <source file='/images/web-01-data.img'/>
+      <target dev='vdb' bus='virtio'/>
+    </disk>
+    <disk type='file' device='cdrom'>
+      <source file='` + media + `'/>
+      <target dev='sda' bus='sata'/>
+    </disk>
+    <disk type='file' device='cdrom'>
+      <source file='/images/gone-cloudinit.iso'/>
+      <target dev='sdb' bus='sata'/>
+    </disk>
+  </devices></domain>`
+
+	out, err := rewriteDiskSources(domainXML, map[string]string{"vda": "web-01-restored-vda", "vdb": "web-01-restored-vdb"}, "images")
+	if err != nil {
+		t.Fatalf("rewriteDiskSources: %v", err)
+	}
+
+	contains := []string{
+		"<disk type='volume' device='disk'>\n      <driver name='qemu' type='qcow2'/>\n      <source pool='images' volume='web-01-restored-vda'/>\n      <target dev='vda' bus='virtio'/>\n    </disk>",
+		"<disk type='volume' device='disk'>\n      <driver name='qemu' type='qcow2'/>\n      <source pool='images' volume='web-01-restored-vdb'/>\n      <target dev='vdb'",
+		"<source file='" + media + "'/>",
+		"<target dev='sdb' bus='sata'/>",
+	}
+	for _, want := range contains {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"backingStore", "base.qcow2", "web-01.qcow2", "web-01-data.img", "gone-cloudinit.iso"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output still contains %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestRewriteDiskSources_UnsupportedSource(t *testing.T) {
+	networkDisk := `<domain><devices>
+    <disk type='network' device='disk'>
+      <source protocol='rbd' name='pool/image'>
+        <host name='ceph-1' port='6789'/>
+      </source>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+  </devices></domain>`
+
+	if _, err := rewriteDiskSources(networkDisk, map[string]string{"vda": "restored"}, "images"); err == nil || !strings.Contains(err.Error(), "unsupported source element") {
+		t.Errorf("rewriteDiskSources() error = %v, want unsupported source element", err)
+	}
+}
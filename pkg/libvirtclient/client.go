@@ -1,6 +1,7 @@
 package libvirtclient
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"github.com/volantvm/flint/pkg/activity"
@@ -27,9 +28,16 @@ type ClientInterface interface {
 	DeleteVMSnapshot(uuidStr string, snapshotName string) error
 	RevertToVMSnapshot(uuidStr string, snapshotName string) error
 	GetVMPerformance(uuidStr string) (core.PerformanceSample, error)
+	GetDomainCounters() ([]core.VMCounters, error)
 	PerformVMAction(uuidStr string, action string) error
 	DeleteVM(uuidStr string, deleteDisks bool) error
 	CreateVM(cfg core.VMCreationConfig) (core.VM_Detailed, error)
+	CloneVM(ctx context.Context, sourceUUID string, req core.CloneVMRequest, progress func(percent float64, message string)) (core.VM_Detailed, error)
+	MigrateVM(ctx context.Context, uuidStr string, dest ClientInterface, req core.MigrateVMRequest, progress func(core.MigrationProgress)) (core.MigrationResult, error)
+	BackupVM(ctx context.Context, uuidStr string, req core.CreateBackupRequest, progress func(percent float64, message string)) (core.Backup, error)
+	RestoreBackup(ctx context.Context, req core.RestoreBackupRequest, progress func(percent float64, message string)) (core.VM_Detailed, error)
+	ExportVM(ctx context.Context, uuidStr string, req core.ExportVMRequest, progress func(percent float64, message string)) (core.VMExport, error)
+	ImportVM(ctx context.Context, req core.ImportVMRequest, progress func(percent float64, message string)) (core.VM_Detailed, error)
 	GetHostStatus() (core.HostStatus, error)
 	GetHostResources() (core.HostResources, error)
 	GetStoragePools() ([]core.StoragePool, error)
@@ -44,9 +52,16 @@ type ClientInterface interface {
 	GetImages() ([]core.Image, error)
 	ImportImageFromPath(path string) (core.Image, error)
 	DeleteImage(imageId string) error
+	CreateImageUploadVolume(name string, sizeBytes uint64) error
+	UploadImage(ctx context.Context, name string, offset uint64, r io.Reader) (int64, error)
+	FinishImageUpload(name string) (core.Image, error)
+	GetImageDetails(imageId string) (core.ImageDetails, error)
+	ConvertImage(ctx context.Context, imageId string, req core.ConvertImageRequest, progress func(percent float64, message string)) (core.Image, error)
 	GetVMSerialConsolePath(uuidStr string) (string, error)
 	GetDomainByName(name string) (*libvirt.Domain, error)
 	NewStream(flags libvirt.StreamFlags) (*libvirt.Stream, error)
+	UpdateVMLabels(uuidStr string, req core.UpdateVMLabelsRequest) (core.VM_Detailed, error)
+	UpdateVM(uuidStr string, req core.UpdateVMRequest) (core.UpdateVMResult, error)
 	AttachDiskToVM(uuidStr string, volumePath string, targetDev string) error
 	AttachNetworkInterfaceToVM(uuidStr string, networkName string, model string) error
 	GetActivity() []core.ActivityEvent
@@ -74,6 +89,9 @@ type ClientInterface interface {
 	CreateNWFilter(req core.CreateNWFilterRequest) error
 	UpdateNWFilter(name string, req core.CreateNWFilterRequest) error
 	DeleteNWFilter(name string) error
+
+	// Live events
+	SubscribeEvents(handler func(core.Event)) (func(), error)
 }
 
 // Client holds the libvirt connection.
@@ -93,6 +111,9 @@ func NewClient(uri string, isoPoolName, templatePoolName string) (*Client, error
 		// libvirt-go will use the standard SSH authentication mechanisms
 	}
 
+	// Callbacks only fire on connections opened after the event loop is registered
+	startEventLoop()
+
 	conn, err := libvirt.NewConnect(uri) // typical API
 	if err != nil {
 		return nil, fmt.Errorf("libvirt connect: %w", err)
@@ -334,6 +355,22 @@ func (c *Client) DeleteImage(imageId string) error {
 	}
 	defer vol.Free()
 
+	// Refuse to delete images that volumes still use as their backing file,
+	// or that VMs have disks on
+	if path, err := vol.GetPath(); err == nil {
+		users, err := c.imageUsers(path)
+		if err != nil {
+			return fmt.Errorf("failed to check image users: %w", err)
+		}
+		if len(users) > 0 {
+			names := make([]string, len(users))
+			for i, user := range users {
+				names[i] = user.Type + " " + user.Name
+			}
+			return fmt.Errorf("%w by %s", ErrImageInUse, strings.Join(names, ", "))
+		}
+	}
+
 	// Delete the volume
 	if err := vol.Delete(0); err != nil {
 		return fmt.Errorf("failed to delete image: %w", err)
@@ -0,0 +1,331 @@
+package libvirtclient
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+var (
+	domainDiskPattern   = regexp.MustCompile(`(?s)<disk\b[^>]*>.*?</disk>\s*`)
+	domainNamePattern   = regexp.MustCompile(`<name>[^<]*</name>`)
+	domainUUIDPattern   = regexp.MustCompile(`\s*<uuid>[^<]*</uuid>`)
+	interfaceMACPattern = regexp.MustCompile(`\s*<mac address=['"][^'"]*['"]\s*/>`)
+	nvramPattern        = regexp.MustCompile(`(?s)\s*<nvram\b[^>]*(/>|>.*?</nvram>)`)
+	diskSourcePattern   = regexp.MustCompile(`<source\b[^>]*/>`)
+	diskBackingPattern  = regexp.MustCompile(`(?s)\s*<backingStore\b[^>]*(/>|>.*</backingStore>)`)
+	diskDriverPattern   = regexp.MustCompile(`(<driver\b[^>]*\btype=)['"][^'"]*['"]`)
+	diskTypePattern     = regexp.MustCompile(`^(<disk\b[^>]*\btype=)['"][^'"]*['"]`)
+)
+
+// cloneDisk is the subset of a <disk> element needed to plan a clone
+type cloneDisk struct {
+	Type   string `xml:"type,attr"`
+	Device string `xml:"device,attr"`
+	Driver struct {
+		Type string `xml:"type,attr"`
+	} `xml:"driver"`
+	Source struct {
+		File   string `xml:"file,attr"`
+		Pool   string `xml:"pool,attr"`
+		Volume string `xml:"volume,attr"`
+	} `xml:"source"`
+	Target struct {
+		Dev string `xml:"dev,attr"`
+		Bus string `xml:"bus,attr"`
+	} `xml:"target"`
+}
+
+// createdVolume tracks volumes created during a clone so they can be rolled back
+type createdVolume struct {
+	pool string
+	name string
+}
+
+// lookupDiskVolume resolves the storage volume backing a disk from either its
+// file path or its pool/volume source attributes
+func (c *Client) lookupDiskVolume(file, poolName, volName string) (*libvirt.StorageVol, error) {
+	if poolName != "" && volName != "" {
+		pool, err := c.conn.LookupStoragePoolByName(poolName)
+		if err != nil {
+			return nil, fmt.Errorf("lookup pool: %w", err)
+		}
+		defer pool.Free()
+		return pool.LookupStorageVolByName(volName)
+	}
+	if file != "" {
+		return c.conn.LookupStorageVolByPath(file)
+	}
+	return nil, fmt.Errorf("disk has no file or volume source")
+}
+
+// CloneVM copies a shut-off VM into a new domain with a fresh name, UUID and MAC
+// addresses. Every disk is either fully copied or, when req.Linked is set, created
+// as a qcow2 overlay backed by the source disk. The source's cloud-init seed is
+// dropped and regenerated from req.CloudInit when provided. Cancelling ctx stops
+// the clone between disks and removes the copies made so far.
+func (c *Client) CloneVM(ctx context.Context, sourceUUID string, req core.CloneVMRequest, progress func(percent float64, message string)) (core.VM_Detailed, error) {
+	if req.Name == "" {
+		return core.VM_Detailed{}, fmt.Errorf("clone name is required")
+	}
+
+	dom, err := c.conn.LookupDomainByUUIDString(sourceUUID)
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("lookup domain: %w", err)
+	}
+	defer dom.Free()
+
+	sourceName, _ := dom.GetName()
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("get domain state: %w", err)
+	}
+	if state != libvirt.DOMAIN_SHUTOFF {
+		return core.VM_Detailed{}, fmt.Errorf("source VM '%s' must be shut off before cloning", sourceName)
+	}
+
+	if existing, err := c.conn.LookupDomainByName(req.Name); err == nil {
+		existing.Free()
+		return core.VM_Detailed{}, fmt.Errorf("a VM named '%s' already exists", req.Name)
+	}
+
+	xmlDesc, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE | libvirt.DOMAIN_XML_SECURE)
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("domain xml: %w", err)
+	}
+
+	// Copy the disks first, rewriting each <disk> element to point at its copy
+	var created []createdVolume
+	rollback := func() {
+		for _, v := range created {
+			_ = c.deleteVolume(v.pool, v.name) // Best-effort cleanup
+		}
+	}
+
+	total := 0
+	for _, block := range domainDiskPattern.FindAllString(xmlDesc, -1) {
+		var d cloneDisk
+		if xml.Unmarshal([]byte(block), &d) == nil && d.Device == "disk" {
+			total++
+		}
+	}
+
+	cloneXML, diskCount, err := rewriteCloneXML(xmlDesc, req, func(d cloneDisk, index int) (createdVolume, error) {
+		progress(float64(index)/float64(total)*90, fmt.Sprintf("Copying %s", d.Target.Dev))
+		vol, err := c.cloneDiskVolume(ctx, d, req, index)
+		if err == nil {
+			created = append(created, vol)
+		}
+		return vol, err
+	})
+	if err != nil {
+		rollback()
+		return core.VM_Detailed{}, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		rollback()
+		return core.VM_Detailed{}, err
+	}
+
+	progress(90, "Defining domain")
+	newDom, err := c.conn.DomainDefineXML(cloneXML)
+	if err != nil {
+		rollback()
+		return core.VM_Detailed{}, fmt.Errorf("failed to define cloned domain: %w", err)
+	}
+	defer newDom.Free()
+
+	if req.CloudInit != nil {
+		userData, err := generateUserDataYAML(req.CloudInit)
+		if err != nil {
+			fmt.Printf("Warning: Failed to generate cloud-init user data: %v\n", err)
+		} else if err := createAndAttachCloudInitISO(c.conn, newDom, userData, req.Name); err != nil {
+			fmt.Printf("Warning: Failed to create cloud-init ISO: %v\n", err)
+		}
+	}
+
+	mode := "full"
+	if req.Linked {
+		mode = "linked"
+	}
+	c.logger.Add("VM Cloned", req.Name, "Success", fmt.Sprintf("Cloned from %s (%s, %d disks)", sourceName, mode, diskCount))
+
+	if req.StartOnCreate {
+		if err := newDom.Create(); err != nil {
+			fmt.Printf("Warning: Failed to start cloned VM %s: %v\n", req.Name, err)
+		}
+	}
+
+	uuid, _ := newDom.GetUUIDString()
+	return c.GetVMDetails(uuid)
+}
+
+// rewriteCloneXML turns a source domain's XML into its clone's. copyDisk is
+// called for each <disk device='disk'>, in order, and the disk is pointed at the
+// volume it returns. The source's cloud-init seed is dropped, other CD-ROMs are
+// kept, and the name is replaced while the UUID, MAC addresses and NVRAM are
+// removed so libvirt generates new ones. It returns the XML and the number of
+// disks copied.
+func rewriteCloneXML(xmlDesc string, req core.CloneVMRequest, copyDisk func(d cloneDisk, index int) (createdVolume, error)) (string, int, error) {
+	diskIndex := 0
+	var cloneErr error
+	cloneXML := domainDiskPattern.ReplaceAllStringFunc(xmlDesc, func(block string) string {
+		if cloneErr != nil {
+			return block
+		}
+
+		var d cloneDisk
+		if err := xml.Unmarshal([]byte(block), &d); err != nil {
+			cloneErr = fmt.Errorf("parse disk xml: %w", err)
+			return block
+		}
+
+		if d.Device != "disk" {
+			// Drop the source VM's cloud-init seed; shared media such as install ISOs are kept
+			if strings.HasSuffix(d.Source.File, "-cloudinit.iso") {
+				return ""
+			}
+			return block
+		}
+
+		if !diskSourcePattern.MatchString(block) {
+			cloneErr = fmt.Errorf("clone disk %s: unsupported source element", d.Target.Dev)
+			return block
+		}
+		newVol, err := copyDisk(d, diskIndex)
+		if err != nil {
+			cloneErr = fmt.Errorf("clone disk %s: %w", d.Target.Dev, err)
+			return block
+		}
+		diskIndex++
+
+		newSource := fmt.Sprintf("<source pool='%s' volume='%s'/>", xmlEscape(newVol.pool), xmlEscape(newVol.name))
+		// The copy has its own backing chain, if any; only the disk's own source changes
+		block = diskBackingPattern.ReplaceAllString(block, "")
+		block = replaceFirst(diskSourcePattern, block, newSource)
+		block = diskTypePattern.ReplaceAllString(block, "${1}'volume'")
+		if req.Linked {
+			block = diskDriverPattern.ReplaceAllString(block, "${1}'qcow2'")
+		}
+		return block
+	})
+	if cloneErr != nil {
+		return "", 0, cloneErr
+	}
+
+	// Give the clone its own identity; libvirt generates a new UUID, MACs and NVRAM file
+	cloneXML = replaceFirst(domainNamePattern, cloneXML, "<name>"+xmlEscape(req.Name)+"</name>")
+	cloneXML = replaceFirst(domainUUIDPattern, cloneXML, "")
+	cloneXML = interfaceMACPattern.ReplaceAllString(cloneXML, "")
+	cloneXML = nvramPattern.ReplaceAllString(cloneXML, "")
+	return cloneXML, diskIndex, nil
+}
+
+// cloneDiskVolume creates the volume for one cloned disk and returns where it
+// lives. It does not start once ctx is cancelled.
+func (c *Client) cloneDiskVolume(ctx context.Context, d cloneDisk, req core.CloneVMRequest, index int) (createdVolume, error) {
+	if err := ctx.Err(); err != nil {
+		return createdVolume{}, err
+	}
+
+	srcVol, err := c.lookupDiskVolume(d.Source.File, d.Source.Pool, d.Source.Volume)
+	if err != nil {
+		return createdVolume{}, fmt.Errorf("disk is not in a known storage pool: %w", err)
+	}
+	defer srcVol.Free()
+
+	srcPath, err := srcVol.GetPath()
+	if err != nil {
+		return createdVolume{}, fmt.Errorf("get volume path: %w", err)
+	}
+	srcInfo, err := srcVol.GetInfo()
+	if err != nil {
+		return createdVolume{}, fmt.Errorf("get volume info: %w", err)
+	}
+
+	var pool *libvirt.StoragePool
+	if req.TargetPool != "" {
+		pool, err = c.conn.LookupStoragePoolByName(req.TargetPool)
+	} else {
+		pool, err = srcVol.LookupPoolByVolume()
+	}
+	if err != nil {
+		return createdVolume{}, fmt.Errorf("lookup target pool: %w", err)
+	}
+	defer pool.Free()
+
+	poolName, err := pool.GetName()
+	if err != nil {
+		return createdVolume{}, fmt.Errorf("get pool name: %w", err)
+	}
+
+	srcFormat := d.Driver.Type
+	if srcFormat == "" {
+		srcFormat = "raw"
+	}
+
+	volName := fmt.Sprintf("%s-disk-%d.qcow2", req.Name, index)
+	if srcFormat != "qcow2" && !req.Linked {
+		volName = fmt.Sprintf("%s-disk-%d.%s", req.Name, index, srcFormat)
+	}
+
+	if req.Linked {
+		volXML := fmt.Sprintf(`<volume>
+  <name>%s</name>
+  <capacity unit="bytes">%d</capacity>
+  <target>
+    <format type="qcow2"/>
+  </target>
+  <backingStore>
+    <path>%s</path>
+    <format type="%s"/>
+  </backingStore>
+</volume>`, xmlEscape(volName), srcInfo.Capacity, xmlEscape(srcPath), xmlEscape(srcFormat))
+
+		vol, err := pool.StorageVolCreateXML(volXML, 0)
+		if err != nil {
+			return createdVolume{}, fmt.Errorf("create linked volume: %w", err)
+		}
+		vol.Free()
+	} else {
+		volXML := fmt.Sprintf(`<volume>
+  <name>%s</name>
+  <capacity unit="bytes">%d</capacity>
+  <target>
+    <format type="%s"/>
+  </target>
+</volume>`, xmlEscape(volName), srcInfo.Capacity, xmlEscape(srcFormat))
+
+		vol, err := pool.StorageVolCreateXMLFrom(volXML, srcVol, 0)
+		if err != nil {
+			return createdVolume{}, fmt.Errorf("copy volume: %w", err)
+		}
+		vol.Free()
+	}
+
+	return createdVolume{pool: poolName, name: volName}, nil
+}
+
+// replaceFirst replaces only the first match of re, which for <name> and <uuid>
+// is the domain's own element and for <source> the disk's own
+func replaceFirst(re *regexp.Regexp, s, repl string) string {
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return s
+	}
+	return s[:loc[0]] + repl + s[loc[1]:]
+}
+
+// xmlEscape escapes a value for use in XML text or attributes
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
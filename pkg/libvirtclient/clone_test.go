@@ -0,0 +1,214 @@
+package libvirtclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+const cloneSourceXML = `<domain type='kvm'>
+  <name>web-01</name>
+  <uuid>0b6a7f9c-3c1e-4a53-9b1a-6f7e2d3c4b5a</uuid>
+  <memory unit='KiB'>2097152</memory>
+  <os>
+    <type arch='x86_64' machine='q35'>hvm</type>
+    <loader readonly='yes' type='pflash'>/usr/share/OVMF/OVMF_CODE.fd</loader>
+    <nvram template='/usr/share/OVMF/OVMF_VARS.fd'>/var/lib/libvirt/qemu/nvram/web-01_VARS.fd</nvram>
+  </os>
+  <devices>
+    <disk type='file' device='disk'>
+      <driver name='qemu' type='qcow2'/>
+      <source file='/var/lib/libvirt/images/web-01.qcow2'/>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+    <disk type='volume' device='disk'>
+      <driver name='qemu' type='raw'/>
+      <source pool='data' volume='web-01-data.img'/>
+      <target dev='vdb' bus='virtio'/>
+    </disk>
+    <disk type='file' device='cdrom'>
+      <driver name='qemu' type='raw'/>
+      <source file='/var/lib/libvirt/images/web-01-cloudinit.iso'/>
+      <target dev='sda' bus='sata'/>
+      <readonly/>
+    </disk>
+    <disk type='file' device='cdrom'>
+      <driver name='qemu' type='raw'/>
+      <source file='/var/lib/flint/images/ubuntu-24.04.iso'/>
+      <target dev='sdb' bus='sata'/>
+      <readonly/>
+    </disk>
+    <interface type='network'>
+      <mac address='52:54:00:12:34:56'/>
+      <source network='default'/>
+      <model type='virtio'/>
+    </interface>
+    <interface type='network'>
+      <mac address="52:54:00:ab:cd:ef" />
+      <source network='lab'/>
+    </interface>
+  </devices>
+</domain>`
+
+func TestRewriteCloneXML(t *testing.T) {
+	tests := []struct {
+		name      string
+		xml       string
+		req       core.CloneVMRequest
+		wantDisks []string // Target devices handed to copyDisk, in order
+		contains  []string
+		absent    []string
+	}{
+		{
+			name:      "full clone",
+			xml:       cloneSourceXML,
+			req:       core.CloneVMRequest{Name: "web-02"},
+			wantDisks: []string{"vda", "vdb"},
+			contains: []string{
+				"<name>web-02</name>",
+				"<disk type='volume' device='disk'>\n      <driver name='qemu' type='qcow2'/>\n      <source pool='images' volume='web-02-disk-0'/>\n      <target dev='vda'",
+				"<disk type='volume' device='disk'>\n      <driver name='qemu' type='raw'/>\n      <source pool='images' volume='web-02-disk-1'/>\n      <target dev='vdb'",
+				"<source file='/var/lib/flint/images/ubuntu-24.04.iso'/>",
+				"<loader readonly='yes' type='pflash'>/usr/share/OVMF/OVMF_CODE.fd</loader>",
+				"<source network='default'/>",
+			},
+			absent: []string{"web-01", "<uuid>", "<mac ", "<nvram", "cloudinit.iso", "target dev='sda'"},
+		},
+		{
+			name:      "linked clone uses qcow2 overlays",
+			xml:       cloneSourceXML,
+			req:       core.CloneVMRequest{Name: "web-02", Linked: true},
+			wantDisks: []string{"vda", "vdb"},
+			contains: []string{
+				"<driver name='qemu' type='qcow2'/>\n      <source pool='images' volume='web-02-disk-1'/>",
+				// The install ISO's driver is left alone
+				"<driver name='qemu' type='raw'/>\n      <source file='/var/lib/flint/images/ubuntu-24.04.iso'/>",
+			},
+			absent: []string{"type='raw'/>\n      <source pool="},
+		},
+		{
+			name: "self-closing nvram",
+			xml: `<domain><name>vm</name><uuid>abc</uuid><os>
+    <loader readonly='yes' type='pflash'>/usr/share/OVMF/OVMF_CODE.fd</loader>
+    <nvram/>
+  </os></domain>`,
+			req:      core.CloneVMRequest{Name: "vm-clone"},
+			contains: []string{"<name>vm-clone</name>", "<loader readonly='yes' type='pflash'>"},
+			absent:   []string{"<nvram", "<uuid>"},
+		},
+		{
+			name:     "name is escaped",
+			xml:      `<domain><name>vm</name></domain>`,
+			req:      core.CloneVMRequest{Name: "a&b<c"},
+			contains: []string{"<name>a&amp;b&lt;c</name>"},
+		},
+		{
+			name: "only the domain name and the disk's own source are replaced",
+			xml: `<domain><name>vm</name><devices><disk type='file' device='disk'>
+      <source file='/images/vm.qcow2'/>
+      <target dev='vda' bus='virtio'/>
+      <backingStore type='file'><format type='qcow2'/><source file='/images/base.qcow2'/></backingStore>
+    </disk></devices><seclabel><name>ignored</name></seclabel></domain>`,
+			req:       core.CloneVMRequest{Name: "vm2"},
+			wantDisks: []string{"vda"},
+			contains:  []string{"<name>vm2</name>", "<name>ignored</name>", "<source pool='images' volume='vm2-disk-0'/>\n      <target dev='vda'"},
+			absent:    []string{"backingStore", "base.qcow2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []cloneDisk
+			out, count, err := rewriteCloneXML(tt.xml, tt.req, func(d cloneDisk, index int) (createdVolume, error) {
+				got = append(got, d)
+				return createdVolume{pool: "images", name: fmt.Sprintf("%s-disk-%d", tt.req.Name, index)}, nil
+			})
+			if err != nil {
+				t.Fatalf("rewriteCloneXML: %v", err)
+			}
+
+			if count != len(tt.wantDisks) || len(got) != len(tt.wantDisks) {
+				t.Fatalf("copied %d disks (count %d), want %v", len(got), count, tt.wantDisks)
+			}
+			for i, dev := range tt.wantDisks {
+				if got[i].Target.Dev != dev {
+					t.Errorf("disk %d is %s, want %s", i, got[i].Target.Dev, dev)
+				}
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(out, want) {
+					t.Errorf("output is missing %q:\n%s", want, out)
+				}
+			}
+			for _, unwanted := range tt.absent {
+				if strings.Contains(out, unwanted) {
+					t.Errorf("output still contains %q:\n%s", unwanted, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteCloneXML_DiskSources(t *testing.T) {
+	var got []cloneDisk
+	_, _, err := rewriteCloneXML(cloneSourceXML, core.CloneVMRequest{Name: "web-02"}, func(d cloneDisk, index int) (createdVolume, error) {
+		got = append(got, d)
+		return createdVolume{pool: "images", name: "copy"}, nil
+	})
+	if err != nil {
+		t.Fatalf("rewriteCloneXML: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("copied %d disks, want 2", len(got))
+	}
+	if got[0].Source.File != "/var/lib/libvirt/images/web-01.qcow2" || got[0].Driver.Type != "qcow2" {
+		t.Errorf("file disk = %+v", got[0])
+	}
+	if got[1].Source.Pool != "data" || got[1].Source.Volume != "web-01-data.img" || got[1].Driver.Type != "raw" {
+		t.Errorf("volume disk = %+v", got[1])
+	}
+}
+
+func TestRewriteCloneXML_Errors(t *testing.T) {
+	networkDisk := `<domain><name>vm</name><devices>
+    <disk type='network' device='disk'>
+      <source protocol='rbd' name='pool/image'>
+        <host name='ceph-1' port='6789'/>
+      </source>
+      <target dev='vda' bus='virtio'/>
+    </disk>
+  </devices></domain>`
+
+	tests := []struct {
+		name      string
+		xml       string
+		copyErr   error
+		wantErr   string
+		wantCalls int
+	}{
+		{"unsupported source", networkDisk, nil, "clone disk vda: unsupported source element", 0},
+		{"copy fails", cloneSourceXML, errors.New("pool is full"), "clone disk vda: pool is full", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := 0
+			out, _, err := rewriteCloneXML(tt.xml, core.CloneVMRequest{Name: "vm2"}, func(d cloneDisk, index int) (createdVolume, error) {
+				called++
+				return createdVolume{}, tt.copyErr
+			})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if out != "" {
+				t.Errorf("got XML alongside an error: %s", out)
+			}
+			// Nothing is copied after the first failure
+			if called != tt.wantCalls {
+				t.Errorf("copyDisk called %d times, want %d", called, tt.wantCalls)
+			}
+		})
+	}
+}
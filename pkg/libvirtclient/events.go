@@ -0,0 +1,221 @@
+package libvirtclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+var eventLoopOnce sync.Once
+
+// startEventLoop registers libvirt's default event implementation and runs its
+// loop. It must happen before a connection is opened for callbacks on that
+// connection to fire, so NewClient calls it first.
+func startEventLoop() {
+	eventLoopOnce.Do(func() {
+		if err := libvirt.EventRegisterDefaultImpl(); err != nil {
+			logger.Warn("Failed to register libvirt event loop, live events disabled", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		go func() {
+			for {
+				if err := libvirt.EventRunDefaultImpl(); err != nil {
+					logger.Warn("libvirt event loop iteration failed", map[string]interface{}{
+						"error": err.Error(),
+					})
+					time.Sleep(time.Second)
+				}
+			}
+		}()
+	})
+}
+
+// SubscribeEvents registers callbacks for domain lifecycle, reboot, block job and
+// guest agent events plus network and storage pool lifecycle events. Every event
+// is passed to handler until the returned function is called.
+func (c *Client) SubscribeEvents(handler func(core.Event)) (func(), error) {
+	var domainIDs, networkIDs, poolIDs []int
+	unsubscribe := func() {
+		for _, id := range domainIDs {
+			_ = c.conn.DomainEventDeregister(id)
+		}
+		for _, id := range networkIDs {
+			_ = c.conn.NetworkEventDeregister(id)
+		}
+		for _, id := range poolIDs {
+			_ = c.conn.StoragePoolEventDeregister(id)
+		}
+	}
+
+	emitDomain := func(d *libvirt.Domain, kind, event, detail string) {
+		name, _ := d.GetName()
+		uuid, _ := d.GetUUIDString()
+		handler(core.Event{
+			Time:         time.Now(),
+			ResourceType: core.EventResourceDomain,
+			Kind:         kind,
+			Name:         name,
+			UUID:         uuid,
+			Event:        event,
+			Detail:       detail,
+		})
+	}
+
+	id, err := c.conn.DomainEventLifecycleRegister(nil, func(_ *libvirt.Connect, d *libvirt.Domain, e *libvirt.DomainEventLifecycle) {
+		emitDomain(d, "lifecycle", domainLifecycleName(e.Event), "")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("register domain lifecycle events: %w", err)
+	}
+	domainIDs = append(domainIDs, id)
+
+	id, err = c.conn.DomainEventRebootRegister(nil, func(_ *libvirt.Connect, d *libvirt.Domain) {
+		emitDomain(d, "reboot", "rebooted", "")
+	})
+	if err != nil {
+		unsubscribe()
+		return nil, fmt.Errorf("register domain reboot events: %w", err)
+	}
+	domainIDs = append(domainIDs, id)
+
+	id, err = c.conn.DomainEventBlockJobRegister(nil, func(_ *libvirt.Connect, d *libvirt.Domain, e *libvirt.DomainEventBlockJob) {
+		emitDomain(d, "block_job", blockJobStatusName(e.Status), e.Disk)
+	})
+	if err != nil {
+		unsubscribe()
+		return nil, fmt.Errorf("register block job events: %w", err)
+	}
+	domainIDs = append(domainIDs, id)
+
+	id, err = c.conn.DomainEventAgentLifecycleRegister(nil, func(_ *libvirt.Connect, d *libvirt.Domain, e *libvirt.DomainEventAgentLifecycle) {
+		state := "disconnected"
+		if e.State == libvirt.CONNECT_DOMAIN_EVENT_AGENT_LIFECYCLE_STATE_CONNECTED {
+			state = "connected"
+		}
+		emitDomain(d, "agent_lifecycle", state, "")
+	})
+	if err != nil {
+		unsubscribe()
+		return nil, fmt.Errorf("register guest agent events: %w", err)
+	}
+	domainIDs = append(domainIDs, id)
+
+	id, err = c.conn.NetworkEventLifecycleRegister(nil, func(_ *libvirt.Connect, n *libvirt.Network, e *libvirt.NetworkEventLifecycle) {
+		name, _ := n.GetName()
+		uuid, _ := n.GetUUIDString()
+		handler(core.Event{
+			Time:         time.Now(),
+			ResourceType: core.EventResourceNetwork,
+			Kind:         "lifecycle",
+			Name:         name,
+			UUID:         uuid,
+			Event:        networkLifecycleName(e.Event),
+		})
+	})
+	if err != nil {
+		unsubscribe()
+		return nil, fmt.Errorf("register network events: %w", err)
+	}
+	networkIDs = append(networkIDs, id)
+
+	id, err = c.conn.StoragePoolEventLifecycleRegister(nil, func(_ *libvirt.Connect, p *libvirt.StoragePool, e *libvirt.StoragePoolEventLifecycle) {
+		name, _ := p.GetName()
+		uuid, _ := p.GetUUIDString()
+		handler(core.Event{
+			Time:         time.Now(),
+			ResourceType: core.EventResourceStoragePool,
+			Kind:         "lifecycle",
+			Name:         name,
+			UUID:         uuid,
+			Event:        storagePoolLifecycleName(e.Event),
+		})
+	})
+	if err != nil {
+		unsubscribe()
+		return nil, fmt.Errorf("register storage pool events: %w", err)
+	}
+	poolIDs = append(poolIDs, id)
+
+	return unsubscribe, nil
+}
+
+func domainLifecycleName(t libvirt.DomainEventType) string {
+	switch t {
+	case libvirt.DOMAIN_EVENT_DEFINED:
+		return "defined"
+	case libvirt.DOMAIN_EVENT_UNDEFINED:
+		return "undefined"
+	case libvirt.DOMAIN_EVENT_STARTED:
+		return "started"
+	case libvirt.DOMAIN_EVENT_SUSPENDED:
+		return "suspended"
+	case libvirt.DOMAIN_EVENT_RESUMED:
+		return "resumed"
+	case libvirt.DOMAIN_EVENT_STOPPED:
+		return "stopped"
+	case libvirt.DOMAIN_EVENT_SHUTDOWN:
+		return "shutdown"
+	case libvirt.DOMAIN_EVENT_PMSUSPENDED:
+		return "pmsuspended"
+	case libvirt.DOMAIN_EVENT_CRASHED:
+		return "crashed"
+	default:
+		return fmt.Sprintf("unknown(%d)", t)
+	}
+}
+
+func blockJobStatusName(s libvirt.ConnectDomainEventBlockJobStatus) string {
+	switch s {
+	case libvirt.DOMAIN_BLOCK_JOB_COMPLETED:
+		return "completed"
+	case libvirt.DOMAIN_BLOCK_JOB_FAILED:
+		return "failed"
+	case libvirt.DOMAIN_BLOCK_JOB_CANCELED:
+		return "cancelled"
+	case libvirt.DOMAIN_BLOCK_JOB_READY:
+		return "ready"
+	default:
+		return fmt.Sprintf("unknown(%d)", s)
+	}
+}
+
+func networkLifecycleName(t libvirt.NetworkEventLifecycleType) string {
+	switch t {
+	case libvirt.NETWORK_EVENT_DEFINED:
+		return "defined"
+	case libvirt.NETWORK_EVENT_UNDEFINED:
+		return "undefined"
+	case libvirt.NETWORK_EVENT_STARTED:
+		return "started"
+	case libvirt.NETWORK_EVENT_STOPPED:
+		return "stopped"
+	default:
+		return fmt.Sprintf("unknown(%d)", t)
+	}
+}
+
+func storagePoolLifecycleName(t libvirt.StoragePoolEventLifecycleType) string {
+	switch t {
+	case libvirt.STORAGE_POOL_EVENT_DEFINED:
+		return "defined"
+	case libvirt.STORAGE_POOL_EVENT_UNDEFINED:
+		return "undefined"
+	case libvirt.STORAGE_POOL_EVENT_STARTED:
+		return "started"
+	case libvirt.STORAGE_POOL_EVENT_STOPPED:
+		return "stopped"
+	case libvirt.STORAGE_POOL_EVENT_CREATED:
+		return "created"
+	case libvirt.STORAGE_POOL_EVENT_DELETED:
+		return "deleted"
+	default:
+		return fmt.Sprintf("unknown(%d)", t)
+	}
+}
@@ -0,0 +1,514 @@
+package libvirtclient
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/backup"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/ovf"
+	"github.com/volantvm/flint/pkg/qemuimg"
+)
+
+// exportDomainXML is the part of a domain definition an OVF descriptor carries
+// beyond what libvirt reports directly
+type exportDomainXML struct {
+	Description string `xml:"description"`
+	OS          struct {
+		Firmware string `xml:"firmware,attr"`
+		Loader   *struct {
+			Type string `xml:"type,attr"`
+		} `xml:"loader"`
+	} `xml:"os"`
+	Interfaces []struct {
+		MAC struct {
+			Address string `xml:"address,attr"`
+		} `xml:"mac"`
+		Source struct {
+			Network string `xml:"network,attr"`
+			Bridge  string `xml:"bridge,attr"`
+		} `xml:"source"`
+		Model struct {
+			Type string `xml:"type,attr"`
+		} `xml:"model"`
+	} `xml:"devices>interface"`
+}
+
+// ExportVM writes a shut-off VM to an archive. OVA archives hold an OVF
+// descriptor and streamOptimized VMDK disks for VirtualBox and VMware; tar
+// archives also hold the libvirt domain XML and keep the disks as qcow2, so
+// Flint can import them without losing anything.
+func (c *Client) ExportVM(ctx context.Context, uuidStr string, req core.ExportVMRequest, progress func(percent float64, message string)) (core.VMExport, error) {
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+	if err := c.requireLocal("exports"); err != nil {
+		return core.VMExport{}, err
+	}
+
+	format := req.Format
+	if format == "" {
+		format = core.ExportFormatOVA
+	}
+	if format != core.ExportFormatOVA && format != core.ExportFormatTar {
+		return core.VMExport{}, fmt.Errorf("unsupported export format %q (use ova or tar)", format)
+	}
+	if req.Path == "" {
+		return core.VMExport{}, errors.New("export path is required")
+	}
+	if _, err := os.Stat(req.Path); err == nil {
+		return core.VMExport{}, fmt.Errorf("%s already exists", req.Path)
+	}
+
+	dom, err := c.conn.LookupDomainByUUIDString(uuidStr)
+	if err != nil {
+		return core.VMExport{}, fmt.Errorf("lookup domain: %w", err)
+	}
+	defer dom.Free()
+
+	name, _ := dom.GetName()
+	active, err := dom.IsActive()
+	if err != nil {
+		return core.VMExport{}, fmt.Errorf("get domain state: %w", err)
+	}
+	if active {
+		return core.VMExport{}, fmt.Errorf("VM '%s' must be shut off to export it", name)
+	}
+	info, err := dom.GetInfo()
+	if err != nil {
+		return core.VMExport{}, fmt.Errorf("get domain info: %w", err)
+	}
+	xmlDesc, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE)
+	if err != nil {
+		return core.VMExport{}, fmt.Errorf("domain xml: %w", err)
+	}
+	var dx exportDomainXML
+	if err := xml.Unmarshal([]byte(xmlDesc), &dx); err != nil {
+		return core.VMExport{}, fmt.Errorf("parse domain XML: %w", err)
+	}
+	disks, err := c.backupDisks(dom, xmlDesc)
+	if err != nil {
+		return core.VMExport{}, err
+	}
+
+	work, err := os.MkdirTemp(filepath.Dir(req.Path), ".flint-export-")
+	if err != nil {
+		return core.VMExport{}, fmt.Errorf("create work directory: %w", err)
+	}
+	defer os.RemoveAll(work)
+
+	started := time.Now()
+	base := snapshotFileName.ReplaceAllString(name, "-")
+	vm := ovf.VirtualMachine{
+		Name:        name,
+		Description: dx.Description,
+		VCPUs:       int(info.NrVirtCpu),
+		MemoryMB:    info.MaxMem / 1024,
+		Firmware:    "bios",
+	}
+	if dx.OS.Firmware == "efi" || (dx.OS.Loader != nil && dx.OS.Loader.Type == "pflash") {
+		vm.Firmware = "efi"
+	}
+
+	var diskFiles []string
+	for i, d := range disks {
+		progress(float64(i)/float64(len(disks))*80, fmt.Sprintf("Converting %s", d.target))
+
+		disk := ovf.Disk{Name: d.target, CapacityBytes: d.size, Controller: ovfController(d.bus)}
+		var args []string
+		if format == core.ExportFormatOVA {
+			disk.File = fmt.Sprintf("%s-disk%d.vmdk", base, i)
+			disk.Format = ovf.FormatVMDK
+			args = []string{"convert", "-f", d.format, "-O", "vmdk", "-o", "subformat=streamOptimized"}
+		} else {
+			disk.File = fmt.Sprintf("%s-disk%d.qcow2", base, i)
+			disk.Format = ovf.FormatQCOW2
+			args = []string{"convert", "-c", "-f", d.format, "-O", "qcow2"}
+		}
+		dst := filepath.Join(work, disk.File)
+		if err := runQemuImg(ctx, append(args, d.path, dst)...); err != nil {
+			return core.VMExport{}, fmt.Errorf("convert %s: %w", d.target, err)
+		}
+		st, err := os.Stat(dst)
+		if err != nil {
+			return core.VMExport{}, fmt.Errorf("stat %s: %w", disk.File, err)
+		}
+		disk.SizeBytes = st.Size()
+		vm.Disks = append(vm.Disks, disk)
+		diskFiles = append(diskFiles, disk.File)
+	}
+
+	for _, iface := range dx.Interfaces {
+		netName := iface.Source.Network
+		if netName == "" {
+			netName = iface.Source.Bridge
+		}
+		vm.NICs = append(vm.NICs, ovf.NIC{Network: netName, Model: ovfNICModel(iface.Model.Type), MAC: iface.MAC.Address})
+	}
+
+	descriptor, err := ovf.Marshal(vm)
+	if err != nil {
+		return core.VMExport{}, err
+	}
+	ovfFile, mfFile := base+".ovf", base+".mf"
+	if err := os.WriteFile(filepath.Join(work, ovfFile), descriptor, 0644); err != nil {
+		return core.VMExport{}, fmt.Errorf("write ovf descriptor: %w", err)
+	}
+	checked := []string{ovfFile}
+	if format == core.ExportFormatTar {
+		if err := os.WriteFile(filepath.Join(work, backup.DomainXMLFile), []byte(xmlDesc), 0644); err != nil {
+			return core.VMExport{}, fmt.Errorf("write domain xml: %w", err)
+		}
+		checked = append(checked, backup.DomainXMLFile)
+	}
+	checked = append(checked, diskFiles...)
+	if err := ovf.WriteManifest(filepath.Join(work, mfFile), work, checked); err != nil {
+		return core.VMExport{}, err
+	}
+
+	// The descriptor goes first and the manifest right after it, as OVA requires
+	progress(80, "Writing archive")
+	order := append([]string{ovfFile, mfFile}, checked[1:]...)
+	sum, size, err := ovf.Pack(ctx, req.Path, work, order)
+	if err != nil {
+		c.logger.Add("VM Export", name, "Error", fmt.Sprintf("Export failed: %v", err))
+		return core.VMExport{}, err
+	}
+
+	c.logger.Add("VM Export", name, "Success", fmt.Sprintf("Exported as %s to %s (%d MiB)", format, req.Path, size/(1024*1024)))
+	return core.VMExport{
+		VMName:     name,
+		VMUUID:     uuidStr,
+		Format:     format,
+		File:       filepath.Base(req.Path),
+		Path:       req.Path,
+		SizeBytes:  size,
+		SHA256:     sum,
+		Disks:      len(vm.Disks),
+		CreatedAt:  started,
+		DurationMs: time.Since(started).Milliseconds(),
+	}, nil
+}
+
+// ImportVM defines a new VM from an OVA, a tar archive written by ExportVM or
+// an OVF descriptor with its disks next to it. Disks are converted to qcow2
+// volumes in the flint-image-library pool. The VM always gets a new UUID and
+// new MAC addresses.
+func (c *Client) ImportVM(ctx context.Context, req core.ImportVMRequest, progress func(percent float64, message string)) (core.VM_Detailed, error) {
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+	if err := c.requireLocal("imports"); err != nil {
+		return core.VM_Detailed{}, err
+	}
+	if req.File == "" {
+		return core.VM_Detailed{}, errors.New("file to import is required")
+	}
+	if _, err := os.Stat(req.File); err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("open %s: %w", req.File, err)
+	}
+
+	dir, descriptor := filepath.Dir(req.File), filepath.Base(req.File)
+	archive := !strings.EqualFold(filepath.Ext(req.File), ".ovf")
+	if archive {
+		work, err := os.MkdirTemp(filepath.Dir(req.File), ".flint-import-")
+		if err != nil {
+			if work, err = os.MkdirTemp("", "flint-import-"); err != nil {
+				return core.VM_Detailed{}, fmt.Errorf("create work directory: %w", err)
+			}
+		}
+		defer os.RemoveAll(work)
+
+		progress(0, "Unpacking archive")
+		names, err := ovf.Unpack(ctx, req.File, work)
+		if err != nil {
+			return core.VM_Detailed{}, err
+		}
+		descriptor = ""
+		for _, n := range names {
+			if strings.EqualFold(filepath.Ext(n), ".ovf") {
+				if descriptor != "" {
+					return core.VM_Detailed{}, errors.New("archive holds more than one OVF descriptor")
+				}
+				descriptor = n
+			}
+		}
+		if descriptor == "" {
+			return core.VM_Detailed{}, errors.New("archive has no OVF descriptor")
+		}
+		dir = work
+	}
+
+	manifest := filepath.Join(dir, strings.TrimSuffix(descriptor, filepath.Ext(descriptor))+".mf")
+	if _, err := os.Stat(manifest); err == nil {
+		progress(25, "Verifying checksums")
+		if err := ovf.VerifyManifest(manifest); err != nil {
+			return core.VM_Detailed{}, err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, descriptor))
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("read ovf descriptor: %w", err)
+	}
+	vm, err := ovf.Parse(data)
+	if err != nil {
+		return core.VM_Detailed{}, err
+	}
+	if len(vm.Disks) == 0 {
+		return core.VM_Detailed{}, errors.New("package has no disks")
+	}
+
+	// Flint's own tar archives carry the original domain definition
+	var domainXML string
+	if archive {
+		if raw, err := os.ReadFile(filepath.Join(dir, backup.DomainXMLFile)); err == nil {
+			domainXML = string(raw)
+		}
+	}
+
+	name := req.Name
+	if name == "" {
+		name = vm.Name
+	}
+	if existing, err := c.conn.LookupDomainByName(name); err == nil {
+		existing.Free()
+		return core.VM_Detailed{}, fmt.Errorf("a VM named '%s' already exists", name)
+	}
+
+	pool, err := c.conn.LookupStoragePoolByName(flintImagePoolName)
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("lookup pool %s: %w", flintImagePoolName, err)
+	}
+	defer pool.Free()
+
+	var created []createdVolume
+	rollback := func() {
+		for _, v := range created {
+			_ = c.deleteVolume(v.pool, v.name) // Best-effort cleanup
+		}
+	}
+
+	var volNames []string
+	for i, d := range vm.Disks {
+		if err := ctx.Err(); err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("import cancelled: %w", err)
+		}
+		progress(30+float64(i)/float64(len(vm.Disks))*65, fmt.Sprintf("Importing %s", d.File))
+
+		src := filepath.Join(dir, d.File)
+		srcFormat := diskImageFormat(d.Format)
+		imgInfo, err := inspectImportDisk(ctx, src, srcFormat)
+		if err != nil {
+			rollback()
+			return core.VM_Detailed{}, err
+		}
+
+		volName := fmt.Sprintf("%s-disk-%d.qcow2", name, i)
+		volXML := fmt.Sprintf(`<volume>
+  <name>%s</name>
+  <capacity unit="bytes">%d</capacity>
+  <target>
+    <format type="qcow2"/>
+  </target>
+</volume>`, xmlEscape(volName), imgInfo.VirtualSize)
+		vol, err := pool.StorageVolCreateXML(volXML, 0)
+		if err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("create volume for %s: %w", d.File, err)
+		}
+		created = append(created, createdVolume{pool: flintImagePoolName, name: volName})
+		volPath, err := vol.GetPath()
+		vol.Free()
+		if err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("get volume path: %w", err)
+		}
+
+		if err := runQemuImg(ctx, "convert", "-n", "-f", imgInfo.Format, "-O", "qcow2", src, volPath); err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("import %s: %w", d.File, err)
+		}
+		volNames = append(volNames, volName)
+	}
+
+	if domainXML != "" {
+		volumes := make(map[string]string)
+		for i, d := range vm.Disks {
+			volumes[d.Name] = volNames[i]
+		}
+		domainXML, err = rewriteDiskSources(domainXML, volumes, flintImagePoolName)
+		if err != nil {
+			rollback()
+			return core.VM_Detailed{}, err
+		}
+		domainXML = renameDomainXML(domainXML, name, true)
+	} else {
+		network := req.Network
+		if network == "" {
+			network = "default"
+		}
+		domain, err := buildImportedDomain(vm, name, volNames, network)
+		if err != nil {
+			rollback()
+			return core.VM_Detailed{}, err
+		}
+		out, err := xml.MarshalIndent(domain, "", "  ")
+		if err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("failed to marshal domain xml: %w", err)
+		}
+		domainXML = string(out)
+	}
+
+	newDom, err := c.conn.DomainDefineXML(domainXML)
+	if err != nil {
+		rollback()
+		return core.VM_Detailed{}, fmt.Errorf("failed to define imported domain: %w", err)
+	}
+	defer newDom.Free()
+
+	c.logger.Add("VM Imported", name, "Success", fmt.Sprintf("Imported from %s (%d disks)", filepath.Base(req.File), len(volNames)))
+
+	if req.Start {
+		if err := newDom.Create(); err != nil {
+			fmt.Printf("Warning: Failed to start imported VM %s: %v\n", name, err)
+		}
+	}
+
+	uuid, _ := newDom.GetUUIDString()
+	return c.GetVMDetails(uuid)
+}
+
+// buildImportedDomain translates an OVF virtual system into a domain, starting
+// from the same defaults as CreateVM. Disks keep the controller type they had,
+// since the guest has drivers for it.
+func buildImportedDomain(vm ovf.VirtualMachine, name string, volumes []string, network string) (DomainXML, error) {
+	cfg := core.VMCreationConfig{Name: name, MemoryMB: vm.MemoryMB, VCPUs: vm.VCPUs, ImageType: "template"}
+	if cfg.VCPUs <= 0 {
+		cfg.VCPUs = 1
+	}
+	if cfg.MemoryMB == 0 {
+		cfg.MemoryMB = 1024
+	}
+	d := buildDomainXML(cfg, volumes[0], "")
+
+	template := d.Devices.Disks[0]
+	d.Devices.Disks = d.Devices.Disks[:0]
+	used := make(map[string]int) // device name prefix -> disks so far
+	for i, disk := range vm.Disks {
+		bus, prefix := libvirtDiskBus(disk.Controller)
+		n := used[prefix]
+		used[prefix]++
+		if n >= 26 {
+			return DomainXML{}, fmt.Errorf("too many %s disks", bus)
+		}
+
+		entry := template
+		entry.Source.Volume = volumes[i]
+		entry.Target.Dev = prefix + string(rune('a'+n))
+		entry.Target.Bus = bus
+		d.Devices.Disks = append(d.Devices.Disks, entry)
+	}
+
+	for _, nic := range vm.NICs {
+		iface := buildNetworkInterface(network)
+		iface.Model.Type = libvirtNICModel(nic.Model)
+		d.Devices.Interfaces = append(d.Devices.Interfaces, iface)
+	}
+
+	if vm.Firmware == "efi" {
+		d.OS.Firmware = "efi"
+	}
+	return d, nil
+}
+
+// inspectImportDisk reads an imported image's virtual size and format. Images
+// with backing files or extents outside themselves are refused: converting
+// them would read other files on the host.
+func inspectImportDisk(ctx context.Context, path, format string) (qemuimg.Info, error) {
+	info, err := qemuimg.Inspect(ctx, path, format)
+	if err != nil {
+		return qemuimg.Info{}, err
+	}
+	if err := info.Standalone(path); err != nil {
+		return qemuimg.Info{}, fmt.Errorf("%w; refusing to import it", err)
+	}
+	if info.VirtualSize == 0 {
+		return qemuimg.Info{}, fmt.Errorf("disk %s is empty", filepath.Base(path))
+	}
+	return info, nil
+}
+
+// diskImageFormat maps an OVF disk format URI to a qemu-img format, or "" to
+// let qemu-img detect it
+func diskImageFormat(uri string) string {
+	u := strings.ToLower(uri)
+	switch {
+	case strings.Contains(u, "vmdk"):
+		return "vmdk"
+	case strings.Contains(u, "qcow"):
+		return "qcow2"
+	}
+	return ""
+}
+
+// ovfController maps a libvirt disk bus to the OVF controller offered to the
+// target hypervisor. Virtio has no OVF equivalent; SATA works everywhere.
+func ovfController(bus string) string {
+	switch bus {
+	case "ide":
+		return ovf.ControllerIDE
+	case "scsi":
+		return ovf.ControllerSCSI
+	}
+	return ovf.ControllerSATA
+}
+
+// libvirtDiskBus maps an OVF controller to a libvirt disk bus and device prefix
+func libvirtDiskBus(controller string) (string, string) {
+	switch controller {
+	case ovf.ControllerIDE:
+		return "ide", "hd"
+	case ovf.ControllerSCSI:
+		return "scsi", "sd"
+	}
+	return "sata", "sd"
+}
+
+// ovfNICModel maps a libvirt NIC model to an OVF adapter type. Virtio becomes
+// E1000, which VirtualBox and VMware guests both have drivers for.
+func ovfNICModel(model string) string {
+	switch model {
+	case "e1000e":
+		return "E1000e"
+	case "vmxnet3":
+		return "VmxNet3"
+	case "pcnet":
+		return "PCNet32"
+	}
+	return "E1000"
+}
+
+// libvirtNICModel maps an OVF adapter type to a libvirt NIC model
+func libvirtNICModel(model string) string {
+	switch strings.ToLower(model) {
+	case "e1000e":
+		return "e1000e"
+	case "vmxnet3":
+		return "vmxnet3"
+	case "pcnet32", "pcnetfast":
+		return "pcnet"
+	case "virtio":
+		return "virtio"
+	}
+	return "e1000"
+}
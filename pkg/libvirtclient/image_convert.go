@@ -0,0 +1,285 @@
+package libvirtclient
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/qemuimg"
+)
+
+// ErrImageInUse is returned when deleting or replacing an image that volumes
+// or VMs still need
+var ErrImageInUse = errors.New("image is in use")
+
+// volumeBackingXML is the part of a storage volume definition naming its backing file
+type volumeBackingXML struct {
+	Name   string `xml:"name"`
+	Target struct {
+		Path string `xml:"path"`
+	} `xml:"target"`
+	BackingStore struct {
+		Path string `xml:"path"`
+	} `xml:"backingStore"`
+}
+
+// GetImageDetails returns an image with what qemu-img reports about it and
+// the volumes and VMs that need it. qemu-img only runs for local connections.
+func (c *Client) GetImageDetails(imageId string) (core.ImageDetails, error) {
+	image, err := c.lookupImage(imageId)
+	if err != nil {
+		return core.ImageDetails{}, err
+	}
+
+	users, err := c.imageUsers(image.Path)
+	if err != nil {
+		return core.ImageDetails{}, err
+	}
+	details := core.ImageDetails{Image: image, UsedBy: users}
+	if details.UsedBy == nil {
+		details.UsedBy = []core.ImageUser{}
+	}
+
+	if c.requireLocal("image inspection") != nil {
+		return details, nil
+	}
+	chain, err := qemuimg.BackingChain(context.Background(), image.Path)
+	if err != nil {
+		return core.ImageDetails{}, err
+	}
+	if len(chain) == 0 {
+		return details, nil
+	}
+
+	info := chain[0]
+	details.Info = &core.ImageInfo{
+		Format:       info.Format,
+		VirtualSizeB: info.VirtualSize,
+		ActualSizeB:  info.ActualSize,
+		ClusterSize:  info.ClusterSize,
+		Compat:       info.FormatSpecific.Data.Compat,
+		Compression:  info.FormatSpecific.Data.CompressionType,
+		Dirty:        info.DirtyFlag,
+		Corrupt:      info.FormatSpecific.Data.Corrupt,
+	}
+	for _, backing := range chain[1:] {
+		details.Info.BackingChain = append(details.Info.BackingChain, core.BackingFile{Path: backing.Filename, Format: backing.Format})
+	}
+	return details, nil
+}
+
+// ConvertImage converts, compresses or sparsifies an image in the library
+// with qemu-img. The result is written next to the image and renamed into
+// place once complete, so a failed conversion leaves the library unchanged.
+// Replacing an image in place is refused while volumes or VMs need it.
+func (c *Client) ConvertImage(ctx context.Context, imageId string, req core.ConvertImageRequest, progress func(percent float64, message string)) (core.Image, error) {
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+	if err := c.requireLocal("image conversions"); err != nil {
+		return core.Image{}, err
+	}
+
+	image, err := c.lookupImage(imageId)
+	if err != nil {
+		return core.Image{}, err
+	}
+	if image.Type == "iso" {
+		return core.Image{}, fmt.Errorf("ISO image %s cannot be converted", imageId)
+	}
+
+	progress(0, "Inspecting")
+	info, err := qemuimg.Inspect(ctx, image.Path, "")
+	if err != nil {
+		return core.Image{}, err
+	}
+	if err := info.Standalone(image.Path); err != nil {
+		return core.Image{}, fmt.Errorf("%w; only standalone images can be converted", err)
+	}
+	if !slices.Contains(qemuimg.ConvertibleFormats, info.Format) {
+		return core.Image{}, fmt.Errorf("image %s has unsupported format %s", imageId, info.Format)
+	}
+
+	opts := qemuimg.ConvertOptions{SourceFormat: info.Format, Format: "qcow2"}
+	switch req.Operation {
+	case core.ImageOpConvert:
+		if info.Format == "qcow2" {
+			return core.Image{}, fmt.Errorf("image %s is already qcow2", imageId)
+		}
+	case core.ImageOpCompress:
+		opts.Compress = true
+	case core.ImageOpSparsify:
+		if info.Format != "qcow2" && info.Format != "raw" {
+			return core.Image{}, fmt.Errorf("only qcow2 and raw images can be sparsified; convert %s first", imageId)
+		}
+		opts.Format = info.Format
+	default:
+		return core.Image{}, fmt.Errorf("unsupported image operation %q (use convert, compress or sparsify)", req.Operation)
+	}
+
+	target := req.Name
+	if target == "" {
+		target = imageId
+		if opts.Format != info.Format {
+			target = strings.TrimSuffix(imageId, filepath.Ext(imageId)) + ".qcow2"
+		}
+	}
+	if target != filepath.Base(target) || strings.HasPrefix(target, ".") || strings.HasSuffix(strings.ToLower(target), ".iso") {
+		return core.Image{}, fmt.Errorf("invalid image name: %s", target)
+	}
+
+	if target == imageId {
+		users, err := c.imageUsers(image.Path)
+		if err != nil {
+			return core.Image{}, err
+		}
+		if len(users) > 0 {
+			return core.Image{}, fmt.Errorf("%w by %s %s; convert it to a new name instead", ErrImageInUse, users[0].Type, users[0].Name)
+		}
+	} else if _, err := c.lookupImage(target); err == nil {
+		return core.Image{}, fmt.Errorf("image already exists: %s", target)
+	}
+
+	// Dot files are not picked up as volumes when the pool is refreshed meanwhile
+	dir := filepath.Dir(image.Path)
+	tmp := filepath.Join(dir, "."+target+".converting")
+	defer os.Remove(tmp)
+
+	progress(1, fmt.Sprintf("Converting %s to %s", info.Format, opts.Format))
+	err = qemuimg.Convert(ctx, image.Path, tmp, opts, func(percent float64) {
+		progress(1+percent*0.98, fmt.Sprintf("Converting %s to %s", info.Format, opts.Format))
+	})
+	if err != nil {
+		return core.Image{}, err
+	}
+	if st, err := os.Stat(image.Path); err == nil {
+		os.Chmod(tmp, st.Mode().Perm())
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, target)); err != nil {
+		return core.Image{}, fmt.Errorf("failed to move converted image into place: %w", err)
+	}
+
+	progress(99, "Refreshing image library")
+	pool, err := c.conn.LookupStoragePoolByName(flintImagePoolName)
+	if err != nil {
+		return core.Image{}, fmt.Errorf("managed image pool not found: %w", err)
+	}
+	defer pool.Free()
+	if err := pool.Refresh(0); err != nil {
+		return core.Image{}, fmt.Errorf("failed to refresh image library: %w", err)
+	}
+
+	result, err := c.lookupImage(target)
+	if err != nil {
+		return core.Image{}, err
+	}
+	c.logger.Add("Image "+strings.ToUpper(req.Operation[:1])+req.Operation[1:], target, "Success",
+		fmt.Sprintf("%s (%s) to %s", imageId, info.Format, opts.Format))
+	return result, nil
+}
+
+// lookupImage finds an image in the managed library by name
+func (c *Client) lookupImage(imageId string) (core.Image, error) {
+	images, err := c.GetImages()
+	if err != nil {
+		return core.Image{}, err
+	}
+	for _, image := range images {
+		if image.ID == imageId {
+			return image, nil
+		}
+	}
+	return core.Image{}, fmt.Errorf("image not found: %s", imageId)
+}
+
+// imageUsers finds the volumes in any active pool with the image as their
+// backing file, and the VMs with a disk on the image or on one of those volumes
+func (c *Client) imageUsers(path string) ([]core.ImageUser, error) {
+	path = filepath.Clean(path)
+	needed := map[string]bool{path: true}
+	var users []core.ImageUser
+
+	pools, err := c.conn.ListAllStoragePools(libvirt.CONNECT_LIST_STORAGE_POOLS_ACTIVE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage pools: %w", err)
+	}
+	for _, pool := range pools {
+		vols, err := pool.ListAllStorageVolumes(0)
+		pool.Free()
+		if err != nil {
+			continue
+		}
+		for _, vol := range vols {
+			desc, err := vol.GetXMLDesc(0)
+			vol.Free()
+			if err != nil {
+				continue
+			}
+			var vx volumeBackingXML
+			if xml.Unmarshal([]byte(desc), &vx) != nil || vx.BackingStore.Path == "" {
+				continue
+			}
+			backing := vx.BackingStore.Path
+			if !filepath.IsAbs(backing) {
+				backing = filepath.Join(filepath.Dir(vx.Target.Path), backing)
+			}
+			if filepath.Clean(backing) == path {
+				users = append(users, core.ImageUser{Type: "volume", Name: vx.Name, Path: vx.Target.Path})
+				needed[filepath.Clean(vx.Target.Path)] = true
+			}
+		}
+	}
+
+	domains, err := c.conn.ListAllDomains(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	for _, dom := range domains {
+		if user, ok := c.domainImageUser(&dom, needed); ok {
+			users = append(users, user)
+		}
+		dom.Free()
+	}
+	return users, nil
+}
+
+// domainImageUser reports whether a domain has a disk on one of the paths
+func (c *Client) domainImageUser(dom *libvirt.Domain, paths map[string]bool) (core.ImageUser, bool) {
+	desc, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE)
+	if err != nil {
+		return core.ImageUser{}, false
+	}
+	var dx struct {
+		Name    string `xml:"name"`
+		UUID    string `xml:"uuid"`
+		Devices struct {
+			Disks []cloneDisk `xml:"disk"`
+		} `xml:"devices"`
+	}
+	if xml.Unmarshal([]byte(desc), &dx) != nil {
+		return core.ImageUser{}, false
+	}
+
+	for _, d := range dx.Devices.Disks {
+		diskPath := d.Source.File
+		if diskPath == "" && d.Source.Pool != "" {
+			vol, err := c.lookupDiskVolume("", d.Source.Pool, d.Source.Volume)
+			if err != nil {
+				continue
+			}
+			diskPath, _ = vol.GetPath()
+			vol.Free()
+		}
+		if diskPath != "" && paths[filepath.Clean(diskPath)] {
+			return core.ImageUser{Type: "vm", Name: dx.Name, UUID: dx.UUID, Path: diskPath}, true
+		}
+	}
+	return core.ImageUser{}, false
+}
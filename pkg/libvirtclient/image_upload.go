@@ -0,0 +1,129 @@
+package libvirtclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// uploadChunkSize is how much of an upload is sent to libvirt at a time
+const uploadChunkSize = 1 << 20
+
+// CreateImageUploadVolume creates the volume an image is uploaded into. The
+// size is preallocated sparsely when known; with a size of 0 the volume grows
+// as data arrives.
+func (c *Client) CreateImageUploadVolume(name string, sizeBytes uint64) error {
+	pool, err := c.conn.LookupStoragePoolByName(flintImagePoolName)
+	if err != nil {
+		return fmt.Errorf("managed image pool not found: %w", err)
+	}
+	defer pool.Free()
+
+	if sizeBytes > 0 {
+		if info, err := pool.GetInfo(); err == nil && info.Available < sizeBytes {
+			return fmt.Errorf("not enough space in %s: %d bytes needed, %d available", flintImagePoolName, sizeBytes, info.Available)
+		}
+	}
+
+	volXML := fmt.Sprintf(`<volume>
+      <name>%s</name>
+      <capacity unit="bytes">%d</capacity>
+      <allocation unit="bytes">0</allocation>
+      <target>
+        <format type="raw"/>
+      </target>
+    </volume>`, xmlEscape(name), sizeBytes)
+
+	vol, err := pool.StorageVolCreateXML(volXML, 0)
+	if err != nil {
+		return fmt.Errorf("failed to create volume: %w", err)
+	}
+	vol.Free()
+	return nil
+}
+
+// UploadImage streams r into an image volume starting at offset and returns
+// the number of bytes written. The data travels over the libvirt connection,
+// so uploads work on remote hosts too.
+func (c *Client) UploadImage(ctx context.Context, name string, offset uint64, r io.Reader) (int64, error) {
+	pool, err := c.conn.LookupStoragePoolByName(flintImagePoolName)
+	if err != nil {
+		return 0, fmt.Errorf("managed image pool not found: %w", err)
+	}
+	defer pool.Free()
+
+	vol, err := pool.LookupStorageVolByName(name)
+	if err != nil {
+		return 0, fmt.Errorf("image not found: %w", err)
+	}
+	defer vol.Free()
+
+	stream, err := c.conn.NewStream(0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create stream: %w", err)
+	}
+	defer stream.Free()
+
+	if err := vol.Upload(stream, offset, 0, 0); err != nil {
+		return 0, fmt.Errorf("failed to start upload: %w", err)
+	}
+
+	var written int64
+	buf := make([]byte, uploadChunkSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			stream.Abort()
+			return written, err
+		}
+		n, rerr := r.Read(buf)
+		for sent := 0; sent < n; {
+			m, err := stream.Send(buf[sent:n])
+			if err != nil {
+				stream.Abort()
+				return written, fmt.Errorf("failed to send data: %w", err)
+			}
+			sent += m
+			written += int64(m)
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			stream.Abort()
+			return written, rerr
+		}
+	}
+
+	if err := stream.Finish(); err != nil {
+		return written, fmt.Errorf("failed to finish upload: %w", err)
+	}
+	return written, nil
+}
+
+// FinishImageUpload refreshes the image library after an upload so that the
+// format and size of the new image are detected, and returns the image
+func (c *Client) FinishImageUpload(name string) (core.Image, error) {
+	pool, err := c.conn.LookupStoragePoolByName(flintImagePoolName)
+	if err != nil {
+		return core.Image{}, fmt.Errorf("managed image pool not found: %w", err)
+	}
+	defer pool.Free()
+
+	if err := pool.Refresh(0); err != nil {
+		return core.Image{}, fmt.Errorf("failed to refresh image library: %w", err)
+	}
+
+	images, err := c.GetImages()
+	if err != nil {
+		return core.Image{}, err
+	}
+	for _, image := range images {
+		if image.Name == name {
+			c.logger.Add("Image Upload", name, "Success", fmt.Sprintf("Uploaded %d MiB", image.SizeB/(1024*1024)))
+			return image, nil
+		}
+	}
+	return core.Image{}, fmt.Errorf("image %s not found after upload", name)
+}
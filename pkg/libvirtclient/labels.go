@@ -0,0 +1,138 @@
+package libvirtclient
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// Flint keeps VM labels and descriptions in the domain's <metadata>, in an
+// element of its own namespace, so they live and migrate with the domain:
+//
+//	<metadata>
+//	  <flint:vm xmlns:flint="https://github.com/volantvm/flint/xmlns/vm/1">
+//	    <flint:description>Build server</flint:description>
+//	    <flint:labels>
+//	      <flint:label key="env">prod</flint:label>
+//	    </flint:labels>
+//	  </flint:vm>
+//	</metadata>
+const (
+	metadataNamespace = "https://github.com/volantvm/flint/xmlns/vm/1"
+	metadataPrefix    = "flint"
+)
+
+// vmMetadata is the content of Flint's metadata element
+type vmMetadata struct {
+	Description string          `xml:"description,omitempty"`
+	Labels      []metadataLabel `xml:"labels>label"`
+}
+
+type metadataLabel struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// parseVMMetadata returns the labels and description in a domain's XML. A
+// domain without Flint metadata has neither.
+func parseVMMetadata(xmlDesc string) (map[string]string, string) {
+	var domain struct {
+		Metadata struct {
+			VM *vmMetadata `xml:"https://github.com/volantvm/flint/xmlns/vm/1 vm"`
+		} `xml:"metadata"`
+	}
+	if err := xml.Unmarshal([]byte(xmlDesc), &domain); err != nil || domain.Metadata.VM == nil {
+		return nil, ""
+	}
+
+	var labels map[string]string
+	for _, label := range domain.Metadata.VM.Labels {
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[label.Key] = label.Value
+	}
+	return labels, domain.Metadata.VM.Description
+}
+
+// marshalVMMetadata renders the metadata element; empty metadata renders as ""
+// so that SetMetadata removes the element
+func marshalVMMetadata(labels map[string]string, description string) (string, error) {
+	if len(labels) == 0 && description == "" {
+		return "", nil
+	}
+
+	meta := vmMetadata{Description: description}
+	for key, value := range labels {
+		meta.Labels = append(meta.Labels, metadataLabel{Key: key, Value: value})
+	}
+	sort.Slice(meta.Labels, func(i, j int) bool { return meta.Labels[i].Key < meta.Labels[j].Key })
+
+	data, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"vm"`
+		vmMetadata
+	}{vmMetadata: meta})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// UpdateVMLabels changes a VM's labels and description. The change applies to
+// the persistent definition and, if the VM is running, to the live domain too.
+func (c *Client) UpdateVMLabels(uuidStr string, req core.UpdateVMLabelsRequest) (core.VM_Detailed, error) {
+	if err := req.Validate(); err != nil {
+		return core.VM_Detailed{}, err
+	}
+
+	dom, err := c.conn.LookupDomainByUUIDString(uuidStr)
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("lookup domain: %w", err)
+	}
+	defer dom.Free()
+
+	name, _ := dom.GetName()
+	persistent, err := dom.IsPersistent()
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("failed to check domain persistence: %w", err)
+	}
+	active, err := dom.IsActive()
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("failed to get domain state: %w", err)
+	}
+
+	var xmlFlags libvirt.DomainXMLFlags
+	var flags libvirt.DomainModificationImpact
+	if persistent {
+		xmlFlags = libvirt.DOMAIN_XML_INACTIVE
+		flags |= libvirt.DOMAIN_AFFECT_CONFIG
+	}
+	if active {
+		flags |= libvirt.DOMAIN_AFFECT_LIVE
+	}
+
+	xmlDesc, err := dom.GetXMLDesc(xmlFlags)
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("domain xml: %w", err)
+	}
+	labels, description := parseVMMetadata(xmlDesc)
+	labels = req.Apply(labels)
+	if req.Description != nil {
+		description = *req.Description
+	}
+
+	content, err := marshalVMMetadata(labels, description)
+	if err != nil {
+		return core.VM_Detailed{}, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if err := dom.SetMetadata(libvirt.DOMAIN_METADATA_ELEMENT, content, metadataPrefix, metadataNamespace, flags); err != nil {
+		c.logger.Add("VM Labels Updated", name, "Error", err.Error())
+		return core.VM_Detailed{}, fmt.Errorf("failed to set metadata: %w", err)
+	}
+
+	c.logger.Add("VM Labels Updated", name, "Success", fmt.Sprintf("Labels: %s", core.FormatLabels(labels)))
+	return c.GetVMDetails(uuidStr)
+}
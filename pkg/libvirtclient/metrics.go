@@ -0,0 +1,171 @@
+package libvirtclient
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// statsInterface is a network interface whose counters can be read
+type statsInterface struct {
+	Device string
+	MAC    string
+}
+
+// domainStatsDevices lists the disks and interfaces of a running domain from its
+// live XML, so counters cover every device rather than just vda and vnet0
+func domainStatsDevices(dom *libvirt.Domain) ([]string, []statsInterface, error) {
+	xmlDesc, err := dom.GetXMLDesc(0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("domain xml: %w", err)
+	}
+
+	var dx struct {
+		Devices struct {
+			Disks []struct {
+				Device string `xml:"device,attr"`
+				Target struct {
+					Dev string `xml:"dev,attr"`
+				} `xml:"target"`
+			} `xml:"disk"`
+			Ifaces []struct {
+				MAC struct {
+					Address string `xml:"address,attr"`
+				} `xml:"mac"`
+				Target struct {
+					Dev string `xml:"dev,attr"`
+				} `xml:"target"`
+			} `xml:"interface"`
+		} `xml:"devices"`
+	}
+	if err := xml.Unmarshal([]byte(xmlDesc), &dx); err != nil {
+		return nil, nil, fmt.Errorf("parse domain xml: %w", err)
+	}
+
+	var disks []string
+	for _, d := range dx.Devices.Disks {
+		// CD-ROMs and floppies carry no meaningful I/O
+		if d.Device == "disk" && d.Target.Dev != "" {
+			disks = append(disks, d.Target.Dev)
+		}
+	}
+
+	var ifaces []statsInterface
+	for _, i := range dx.Devices.Ifaces {
+		// Interfaces without a host-side device (e.g. user networking) have no counters
+		if i.Target.Dev != "" {
+			ifaces = append(ifaces, statsInterface{Device: i.Target.Dev, MAC: i.MAC.Address})
+		}
+	}
+
+	return disks, ifaces, nil
+}
+
+// domainCounters reads the cumulative CPU, memory, disk and network counters of a domain
+func domainCounters(dom *libvirt.Domain) (core.VMCounters, error) {
+	var counters core.VMCounters
+
+	info, err := dom.GetInfo()
+	if err != nil {
+		return counters, fmt.Errorf("get domain info: %w", err)
+	}
+	counters.Timestamp = time.Now().UnixNano()
+	counters.Name, _ = dom.GetName()
+	counters.UUID, _ = dom.GetUUIDString()
+	counters.State = libvirtStateToString(info.State)
+	counters.VCPUs = info.NrVirtCpu
+	counters.MemoryTotalKB = info.Memory
+
+	counters.Active, _ = dom.IsActive()
+	if !counters.Active {
+		return counters, nil
+	}
+	counters.CPUTimeNs = info.CpuTime
+	counters.MemoryUsedKB = domainMemoryUsedKB(dom)
+
+	disks, ifaces, err := domainStatsDevices(dom)
+	if err != nil {
+		return counters, err
+	}
+
+	for _, dev := range disks {
+		stats, err := dom.BlockStats(dev)
+		if err != nil {
+			continue
+		}
+		counters.Disks = append(counters.Disks, core.DiskCounters{
+			Device:     dev,
+			ReadBytes:  uint64(stats.RdBytes),
+			WriteBytes: uint64(stats.WrBytes),
+			ReadReqs:   uint64(stats.RdReq),
+			WriteReqs:  uint64(stats.WrReq),
+		})
+	}
+
+	for _, iface := range ifaces {
+		stats, err := dom.InterfaceStats(iface.Device)
+		if err != nil {
+			continue
+		}
+		counters.Interfaces = append(counters.Interfaces, core.InterfaceCounters{
+			Device:    iface.Device,
+			MAC:       iface.MAC,
+			RxBytes:   uint64(stats.RxBytes),
+			TxBytes:   uint64(stats.TxBytes),
+			RxPackets: uint64(stats.RxPackets),
+			TxPackets: uint64(stats.TxPackets),
+		})
+	}
+
+	return counters, nil
+}
+
+// domainMemoryUsedKB prefers the guest's own view of used memory (reported by the
+// balloon driver) and falls back to the QEMU process RSS
+func domainMemoryUsedKB(dom *libvirt.Domain) uint64 {
+	memStats, err := dom.MemoryStats(uint32(libvirt.DOMAIN_MEMORY_STAT_NR), 0)
+	if err != nil {
+		return 0
+	}
+
+	var available, unused, rss uint64
+	for _, stat := range memStats {
+		switch stat.Tag {
+		case int32(libvirt.DOMAIN_MEMORY_STAT_AVAILABLE):
+			available = stat.Val
+		case int32(libvirt.DOMAIN_MEMORY_STAT_UNUSED):
+			unused = stat.Val
+		case int32(libvirt.DOMAIN_MEMORY_STAT_RSS):
+			rss = stat.Val
+		}
+	}
+
+	if available > 0 && unused <= available {
+		return available - unused
+	}
+	return rss
+}
+
+// GetDomainCounters reads the counters of every domain. Domains that disappear
+// while being read are skipped.
+func (c *Client) GetDomainCounters() ([]core.VMCounters, error) {
+	domains, err := c.conn.ListAllDomains(0)
+	if err != nil {
+		return nil, fmt.Errorf("list domains: %w", err)
+	}
+
+	out := make([]core.VMCounters, 0, len(domains))
+	for i := range domains {
+		counters, err := domainCounters(&domains[i])
+		domains[i].Free()
+		if err != nil {
+			continue
+		}
+		out = append(out, counters)
+	}
+
+	return out, nil
+}
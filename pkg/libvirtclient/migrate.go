@@ -0,0 +1,223 @@
+package libvirtclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// migrationPollInterval is how often the domain job info is sampled for progress
+const migrationPollInterval = time.Second
+
+// MigrateVM moves a VM to the host behind dest. Running VMs are migrated live and
+// shut-off VMs (or req.Offline) only have their definition moved. The migration is
+// driven from this process over both connections, so the hosts do not need
+// credentials for each other. The destination keeps a persistent definition and
+// the source definition is removed once the migration succeeds.
+//
+// progress, if non-nil, is called with samples of the domain job info until the
+// migration finishes. Cancelling ctx aborts the migration.
+func (c *Client) MigrateVM(ctx context.Context, uuidStr string, dest ClientInterface, req core.MigrateVMRequest, progress func(core.MigrationProgress)) (core.MigrationResult, error) {
+	var result core.MigrationResult
+
+	destClient, ok := dest.(*Client)
+	if !ok || destClient == nil {
+		return result, fmt.Errorf("destination connection does not support migration")
+	}
+	if destClient == c {
+		return result, fmt.Errorf("source and destination are the same connection")
+	}
+
+	dom, err := c.conn.LookupDomainByUUIDString(uuidStr)
+	if err != nil {
+		return result, fmt.Errorf("lookup domain: %w", err)
+	}
+	defer dom.Free()
+
+	name, _ := dom.GetName()
+
+	state, _, err := dom.GetState()
+	if err != nil {
+		return result, fmt.Errorf("get domain state: %w", err)
+	}
+	live := state == libvirt.DOMAIN_RUNNING || state == libvirt.DOMAIN_PAUSED
+	if req.Offline && live {
+		return result, fmt.Errorf("VM '%s' must be shut off for an offline migration", name)
+	}
+	if !live && (req.CopyStorage != core.MigrateCopyStorageNone || req.PostCopy || req.AutoConverge) {
+		return result, fmt.Errorf("VM '%s' is not running; storage copy, auto-converge and post-copy require a live migration", name)
+	}
+
+	if existing, err := destClient.conn.LookupDomainByUUIDString(uuidStr); err == nil {
+		existing.Free()
+		return result, fmt.Errorf("a VM with UUID %s already exists on the destination", uuidStr)
+	}
+	if existing, err := destClient.conn.LookupDomainByName(name); err == nil {
+		existing.Free()
+		return result, fmt.Errorf("a VM named '%s' already exists on the destination", name)
+	}
+
+	flags := libvirt.MIGRATE_PERSIST_DEST | libvirt.MIGRATE_UNDEFINE_SOURCE
+	if live {
+		flags |= libvirt.MIGRATE_LIVE | libvirt.MIGRATE_ABORT_ON_ERROR
+	} else {
+		flags |= libvirt.MIGRATE_OFFLINE
+	}
+	switch req.CopyStorage {
+	case core.MigrateCopyStorageNone:
+	case core.MigrateCopyStorageAll:
+		flags |= libvirt.MIGRATE_NON_SHARED_DISK
+	case core.MigrateCopyStorageIncremental:
+		flags |= libvirt.MIGRATE_NON_SHARED_INC
+	default:
+		return result, fmt.Errorf("invalid copyStorage %q (use all or incremental)", req.CopyStorage)
+	}
+	if req.AutoConverge {
+		flags |= libvirt.MIGRATE_AUTO_CONVERGE
+	}
+	if req.PostCopy {
+		flags |= libvirt.MIGRATE_POSTCOPY
+	}
+
+	params := &libvirt.DomainMigrateParameters{}
+	if req.BandwidthMiB > 0 {
+		params.BandwidthSet = true
+		params.Bandwidth = req.BandwidthMiB
+	}
+	// Keep the persistent config (which may differ from the running one) on the destination
+	if live {
+		if persistXML, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE | libvirt.DOMAIN_XML_SECURE | libvirt.DOMAIN_XML_MIGRATABLE); err == nil {
+			params.PersistXMLSet = true
+			params.PersistXML = persistXML
+		}
+	}
+
+	destName, _ := destClient.conn.GetHostname()
+	if destName == "" {
+		destName, _ = destClient.conn.GetURI()
+	}
+
+	type migrateOutcome struct {
+		dom *libvirt.Domain
+		err error
+	}
+	done := make(chan migrateOutcome, 1)
+	started := time.Now()
+	go func() {
+		newDom, err := dom.Migrate3(destClient.conn, params, flags)
+		done <- migrateOutcome{dom: newDom, err: err}
+	}()
+
+	ticker := time.NewTicker(migrationPollInterval)
+	defer ticker.Stop()
+
+	var last core.MigrationProgress
+	postCopyStarted := false
+	aborted := false
+	var outcome migrateOutcome
+
+wait:
+	for {
+		select {
+		case outcome = <-done:
+			break wait
+		case <-ctx.Done():
+			if !aborted {
+				aborted = true
+				if err := dom.AbortJob(); err != nil {
+					fmt.Printf("Warning: Failed to abort migration of %s: %v\n", name, err)
+				}
+			}
+		case <-ticker.C:
+			if !live {
+				continue
+			}
+			info, err := dom.GetJobInfo()
+			if err != nil || info.Type != libvirt.DOMAIN_JOB_UNBOUNDED {
+				continue
+			}
+
+			// Pre-copy has made a full pass over memory without converging; let the
+			// destination run the guest and fetch the remaining pages on demand
+			if req.PostCopy && !postCopyStarted && info.MemIterationSet && info.MemIteration >= 2 {
+				if err := dom.MigrateStartPostCopy(0); err == nil {
+					postCopyStarted = true
+				}
+			}
+
+			last = migrationProgressFromJobInfo(info, postCopyStarted)
+			if progress != nil {
+				progress(last)
+			}
+		}
+	}
+
+	if outcome.err != nil {
+		if aborted {
+			c.logger.Add("VM Migration", name, "Error", fmt.Sprintf("Migration to %s cancelled", destName))
+			return result, fmt.Errorf("migration cancelled: %w", ctx.Err())
+		}
+		c.logger.Add("VM Migration", name, "Error", fmt.Sprintf("Migration to %s failed: %v", destName, outcome.err))
+		return result, fmt.Errorf("migrate domain: %w", outcome.err)
+	}
+	newUUID, _ := outcome.dom.GetUUIDString()
+	outcome.dom.Free()
+
+	mode := "offline"
+	if live {
+		mode = "live"
+	}
+	if postCopyStarted {
+		mode += ", post-copy"
+	}
+	if req.CopyStorage != core.MigrateCopyStorageNone {
+		mode += ", storage copy: " + req.CopyStorage
+	}
+	message := fmt.Sprintf("Migrated to %s (%s)", destName, mode)
+	c.logger.Add("VM Migrated", name, "Success", message)
+	destClient.logger.Add("VM Migrated", name, "Success", message)
+
+	result.Live = live
+	result.PostCopy = postCopyStarted
+	result.DurationMs = time.Since(started).Milliseconds()
+	result.DataTransferred = last.DataProcessed
+	result.VM, err = destClient.GetVMDetails(newUUID)
+	if err != nil {
+		return result, fmt.Errorf("VM migrated but reading it on the destination failed: %w", err)
+	}
+	return result, nil
+}
+
+// migrationProgressFromJobInfo converts libvirt's job info into a progress sample
+func migrationProgressFromJobInfo(info *libvirt.DomainJobInfo, postCopy bool) core.MigrationProgress {
+	p := core.MigrationProgress{
+		Phase:           "memory",
+		DataTotal:       info.DataTotal,
+		DataProcessed:   info.DataProcessed,
+		DataRemaining:   info.DataRemaining,
+		MemIteration:    info.MemIteration,
+		MemDirtyRate:    info.MemDirtyRate,
+		BytesPerSecond:  info.MemBps + info.DiskBps,
+		TimeElapsedMs:   info.TimeElapsed,
+		TimeRemainingMs: info.TimeRemaining,
+	}
+
+	switch {
+	case postCopy:
+		p.Phase = "postcopy"
+	case info.DiskRemainingSet && info.DiskRemaining > 0 && info.MemProcessed == 0:
+		p.Phase = "storage"
+	}
+
+	if info.DataTotal > 0 {
+		p.Percent = float64(info.DataProcessed) / float64(info.DataTotal) * 100
+		// Dirtied memory is re-sent, so the total grows; never report completion early
+		if p.Percent > 99 {
+			p.Percent = 99
+		}
+	}
+	return p
+}
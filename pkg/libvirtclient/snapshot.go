@@ -3,10 +3,58 @@ package libvirtclient
 import (
 	"encoding/xml"
 	"fmt"
-	"github.com/volantvm/flint/pkg/core"
+	"path/filepath"
+	"regexp"
+	"strings"
+
 	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/core"
 )
 
+// snapshotXML is a <domainsnapshot> document, both as libvirt reports it and as
+// sent to create external snapshots
+type snapshotXML struct {
+	XMLName      xml.Name           `xml:"domainsnapshot"`
+	Name         string             `xml:"name"`
+	Description  string             `xml:"description,omitempty"`
+	State        string             `xml:"state,omitempty"`
+	CreationTime int64              `xml:"creationTime,omitempty"`
+	Parent       *snapshotParentXML `xml:"parent,omitempty"`
+	Memory       *snapshotMemoryXML `xml:"memory,omitempty"`
+	Disks        *snapshotDisksXML  `xml:"disks,omitempty"`
+}
+
+type snapshotParentXML struct {
+	Name string `xml:"name"`
+}
+
+type snapshotMemoryXML struct {
+	Snapshot string `xml:"snapshot,attr"`
+	File     string `xml:"file,attr,omitempty"`
+}
+
+type snapshotDisksXML struct {
+	Disks []snapshotDiskXML `xml:"disk"`
+}
+
+type snapshotDiskXML struct {
+	Name     string             `xml:"name,attr"`
+	Snapshot string             `xml:"snapshot,attr,omitempty"`
+	Driver   *snapshotDriverXML `xml:"driver,omitempty"`
+	Source   *snapshotSourceXML `xml:"source,omitempty"`
+}
+
+type snapshotDriverXML struct {
+	Type string `xml:"type,attr"`
+}
+
+type snapshotSourceXML struct {
+	File string `xml:"file,attr"`
+}
+
+// snapshotFileName makes a snapshot name safe to use in overlay file names
+var snapshotFileName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
 // GetVMSnapshots lists all snapshots for a given VM.
 func (c *Client) GetVMSnapshots(uuidStr string) ([]core.Snapshot, error) {
 	dom, err := c.conn.LookupDomainByUUIDString(uuidStr)
@@ -15,61 +63,90 @@ func (c *Client) GetVMSnapshots(uuidStr string) ([]core.Snapshot, error) {
 	}
 	defer dom.Free()
 
-	// Get snapshot names first
-	snapNames, err := dom.SnapshotListNames(0)
+	snaps, err := dom.ListAllSnapshots(0)
 	if err != nil {
-		return nil, fmt.Errorf("list snapshot names: %w", err)
+		return nil, fmt.Errorf("list snapshots: %w", err)
 	}
 
-	out := make([]core.Snapshot, 0, len(snapNames))
-	for _, snapName := range snapNames {
-		snap, err := dom.SnapshotLookupByName(snapName, 0)
-		if err != nil {
-			continue // Skip snapshots we can't access
-		}
-
-		xmlDesc, err := snap.GetXMLDesc(0)
+	out := make([]core.Snapshot, 0, len(snaps))
+	for i := range snaps {
+		snapshot, err := c.getSnapshotDetails(&snaps[i])
+		snaps[i].Free()
 		if err != nil {
-			snap.Free()
 			continue // Skip snapshots we can't read
 		}
-
-		// Unmarshal the snapshot XML to get details
-		type snapshotXML struct {
-			Name         string `xml:"name"`
-			Description  string `xml:"description"`
-			State        string `xml:"state"`
-			CreationTime int64  `xml:"creationTime"`
-		}
-		var sx snapshotXML
-		if xml.Unmarshal([]byte(xmlDesc), &sx) == nil {
-			out = append(out, core.Snapshot{
-				Name:        sx.Name,
-				State:       sx.State,
-				CreationTS:  sx.CreationTime,
-				Description: sx.Description,
-			})
-		}
-		snap.Free()
+		out = append(out, snapshot)
 	}
 	return out, nil
 }
 
-// CreateVMSnapshot creates a new snapshot from a name and description.
+// CreateVMSnapshot creates a snapshot. By default it is internal; DiskOnly and
+// Memory create external qcow2 overlays next to each disk instead, Memory also
+// saving RAM to a file, and Quiesce freezes guest filesystems via the guest agent.
 func (c *Client) CreateVMSnapshot(uuidStr string, cfg core.CreateSnapshotRequest) (core.Snapshot, error) {
+	if err := cfg.Validate(); err != nil {
+		return core.Snapshot{}, err
+	}
+
 	dom, err := c.conn.LookupDomainByUUIDString(uuidStr)
 	if err != nil {
 		return core.Snapshot{}, fmt.Errorf("lookup domain: %w", err)
 	}
 	defer dom.Free()
 
-	// Generate snapshot XML
-	xmlDesc := fmt.Sprintf(`<domainsnapshot>
-  <name>%s</name>
-  <description>%s</description>
-</domainsnapshot>`, cfg.Name, cfg.Description)
+	spec := snapshotXML{Name: cfg.Name, Description: cfg.Description}
+	var flags libvirt.DomainSnapshotCreateFlags
+
+	if cfg.DiskOnly || cfg.Memory {
+		active, err := dom.IsActive()
+		if err != nil {
+			return core.Snapshot{}, fmt.Errorf("get domain state: %w", err)
+		}
+		if (cfg.Memory || cfg.Quiesce) && !active {
+			return core.Snapshot{}, fmt.Errorf("memory and quiesced snapshots require a running VM")
+		}
+
+		xmlDesc, err := dom.GetXMLDesc(0)
+		if err != nil {
+			return core.Snapshot{}, fmt.Errorf("get domain XML: %w", err)
+		}
+		vmName, err := dom.GetName()
+		if err != nil {
+			return core.Snapshot{}, fmt.Errorf("get domain name: %w", err)
+		}
+
+		disks, memoryDir, err := externalSnapshotDisks(xmlDesc, cfg.Name)
+		if err != nil {
+			return core.Snapshot{}, err
+		}
+		spec.Disks = &snapshotDisksXML{Disks: disks}
+
+		flags |= libvirt.DOMAIN_SNAPSHOT_CREATE_ATOMIC
+		if cfg.DiskOnly {
+			flags |= libvirt.DOMAIN_SNAPSHOT_CREATE_DISK_ONLY
+			if cfg.Quiesce {
+				flags |= libvirt.DOMAIN_SNAPSHOT_CREATE_QUIESCE
+			}
+		} else {
+			if memoryDir == "" {
+				return core.Snapshot{}, fmt.Errorf("memory snapshots need a file-backed disk to store the memory file next to")
+			}
+			spec.Memory = &snapshotMemoryXML{
+				Snapshot: "external",
+				File:     filepath.Join(memoryDir, fmt.Sprintf("%s.%s.mem", vmName, snapshotFileName.ReplaceAllString(cfg.Name, "-"))),
+			}
+			if cfg.Live {
+				flags |= libvirt.DOMAIN_SNAPSHOT_CREATE_LIVE
+			}
+		}
+	}
+
+	xmlDesc, err := xml.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return core.Snapshot{}, fmt.Errorf("build snapshot XML: %w", err)
+	}
 
-	snap, err := dom.CreateSnapshotXML(xmlDesc, 0)
+	snap, err := dom.CreateSnapshotXML(string(xmlDesc), flags)
 	if err != nil {
 		return core.Snapshot{}, fmt.Errorf("create snapshot: %w", err)
 	}
@@ -79,6 +156,94 @@ func (c *Client) CreateVMSnapshot(uuidStr string, cfg core.CreateSnapshotRequest
 	return c.getSnapshotDetails(snap)
 }
 
+// externalSnapshotDisks plans an external snapshot of a domain: each disk gets a
+// qcow2 overlay next to its current image, other devices (CD-ROMs) are skipped.
+// It also returns the directory of the first file-backed disk for the memory file.
+func externalSnapshotDisks(domainXML, snapshotName string) ([]snapshotDiskXML, string, error) {
+	var dx struct {
+		Devices struct {
+			Disks []cloneDisk `xml:"disk"`
+		} `xml:"devices"`
+	}
+	if err := xml.Unmarshal([]byte(domainXML), &dx); err != nil {
+		return nil, "", fmt.Errorf("parse domain XML: %w", err)
+	}
+
+	suffix := snapshotFileName.ReplaceAllString(snapshotName, "-")
+	var disks []snapshotDiskXML
+	var memoryDir string
+	for _, d := range dx.Devices.Disks {
+		if d.Target.Dev == "" {
+			continue
+		}
+		if d.Device != "disk" {
+			disks = append(disks, snapshotDiskXML{Name: d.Target.Dev, Snapshot: "no"})
+			continue
+		}
+
+		disk := snapshotDiskXML{
+			Name:     d.Target.Dev,
+			Snapshot: "external",
+			Driver:   &snapshotDriverXML{Type: "qcow2"},
+		}
+		// Disks referenced by pool/volume get an overlay name chosen by libvirt
+		if d.Source.File != "" {
+			dir := filepath.Dir(d.Source.File)
+			base := strings.TrimSuffix(filepath.Base(d.Source.File), filepath.Ext(d.Source.File))
+			disk.Source = &snapshotSourceXML{File: filepath.Join(dir, base+"."+suffix+".qcow2")}
+			if memoryDir == "" {
+				memoryDir = dir
+			}
+		}
+		disks = append(disks, disk)
+	}
+
+	if len(disks) == 0 {
+		return nil, "", fmt.Errorf("VM has no disks to snapshot")
+	}
+	return disks, memoryDir, nil
+}
+
+// snapshotFromXML converts a <domainsnapshot> description to a core.Snapshot
+func snapshotFromXML(xmlDesc string, current bool) (core.Snapshot, error) {
+	var sx snapshotXML
+	if err := xml.Unmarshal([]byte(xmlDesc), &sx); err != nil {
+		return core.Snapshot{}, fmt.Errorf("unmarshal snapshot XML: %w", err)
+	}
+
+	snapshot := core.Snapshot{
+		Name:        sx.Name,
+		State:       sx.State,
+		CreationTS:  sx.CreationTime,
+		Description: sx.Description,
+		Current:     current,
+		Kind:        core.SnapshotInternal,
+	}
+	if sx.Parent != nil {
+		snapshot.Parent = sx.Parent.Name
+	}
+	if sx.Memory != nil {
+		snapshot.Memory = sx.Memory.Snapshot != "" && sx.Memory.Snapshot != "no"
+		snapshot.MemoryFile = sx.Memory.File
+		if sx.Memory.Snapshot == core.SnapshotExternal {
+			snapshot.Kind = core.SnapshotExternal
+		}
+	}
+	if sx.Disks != nil {
+		for _, d := range sx.Disks.Disks {
+			disk := core.SnapshotDisk{Name: d.Name, Snapshot: d.Snapshot}
+			if d.Source != nil {
+				disk.File = d.Source.File
+			}
+			if d.Snapshot == core.SnapshotExternal {
+				snapshot.Kind = core.SnapshotExternal
+			}
+			snapshot.Disks = append(snapshot.Disks, disk)
+		}
+	}
+	return snapshot, nil
+}
+
 // DeleteVMSnapshot deletes a snapshot by its name.
 func (c *Client) DeleteVMSnapshot(uuidStr string, snapshotName string) error {
 	dom, err := c.conn.LookupDomainByUUIDString(uuidStr)
@@ -127,22 +292,6 @@ func (c *Client) getSnapshotDetails(snap *libvirt.DomainSnapshot) (core.Snapshot
 		return core.Snapshot{}, fmt.Errorf("get snapshot XML: %w", err)
 	}
 
-	// Unmarshal the snapshot XML to get details
-	type snapshotXML struct {
-		Name         string `xml:"name"`
-		Description  string `xml:"description"`
-		State        string `xml:"state"`
-		CreationTime int64  `xml:"creationTime"`
-	}
-	var sx snapshotXML
-	if err := xml.Unmarshal([]byte(xmlDesc), &sx); err != nil {
-		return core.Snapshot{}, fmt.Errorf("unmarshal snapshot XML: %w", err)
-	}
-
-	return core.Snapshot{
-		Name:        sx.Name,
-		State:       sx.State,
-		CreationTS:  sx.CreationTime,
-		Description: sx.Description,
-	}, nil
+	current, _ := snap.IsCurrent(0)
+	return snapshotFromXML(xmlDesc, current)
 }
@@ -64,6 +64,8 @@ func (c *Client) GetVMSummaries() ([]core.VM_Summary, error) {
 		info2       libvirt.DomainInfo
 		osInfo      string
 		ipAddresses []string
+		labels      map[string]string
+		description string
 		err         error
 	}
 
@@ -89,6 +91,8 @@ func (c *Client) GetVMSummaries() ([]core.VM_Summary, error) {
 
 			xmlDesc, err := s.dom.GetXMLDesc(0)
 			if err == nil {
+				s.labels, s.description = parseVMMetadata(xmlDesc)
+
 				// Try guest agent first, fallback to XML detection
 				guestInfo, guestAgentAvailable := c.getGuestAgentInfo(&s.dom)
 				if guestAgentAvailable && guestInfo.OSName != "" {
@@ -160,6 +164,8 @@ func (c *Client) GetVMSummaries() ([]core.VM_Summary, error) {
 			UptimeSec:   uint64(s.info2.CpuTime / 1e9),
 			OSInfo:      s.osInfo,
 			IPAddresses: s.ipAddresses,
+			Labels:      s.labels,
+			Description: s.description,
 		}
 
 		out = append(out, vm)
@@ -201,6 +207,7 @@ func (c *Client) GetVMDetails(uuidStr string) (core.VM_Detailed, error) {
 	out.MaxMemoryKB = uint64(info.MaxMem) // <-- ADD THIS LINE
 	out.MaxMemoryKB = uint64(info.MaxMem) // <-- ADD THIS LINE
 	out.XML = xmlDesc
+	out.Labels, out.Description = parseVMMetadata(xmlDesc)
 
 	// Parse XML for disks and nics (simple unmarshal using anonymous structs)
 	type target struct {
@@ -210,9 +217,14 @@ func (c *Client) GetVMDetails(uuidStr string) (core.VM_Detailed, error) {
 		File    string `xml:"file,attr"`
 		Dev     string `xml:"dev,attr"`
 		Network string `xml:"network,attr"`
+		Pool    string `xml:"pool,attr"`
+		Volume  string `xml:"volume,attr"`
 	}
 	type disk struct {
-		Device string     `xml:"device,attr"`
+		Device string `xml:"device,attr"`
+		Driver struct {
+			Type string `xml:"type,attr"`
+		} `xml:"driver"`
 		Source sourceFile `xml:"source"`
 		Target target     `xml:"target"`
 	}
@@ -245,11 +257,22 @@ func (c *Client) GetVMDetails(uuidStr string) (core.VM_Detailed, error) {
 	if err := xml.Unmarshal([]byte(xmlDesc), &dx); err == nil {
 		for _, d := range dx.Devices.Disks {
 			// only handle file-backed disks and volumes with target devs
-			out.Disks = append(out.Disks, core.Disk{
+			disk := core.Disk{
 				SourcePath: d.Source.File,
 				TargetDev:  d.Target.Dev,
 				Device:     d.Device,
-			})
+				Format:     d.Driver.Type,
+			}
+			if vol, err := c.lookupDiskVolume(d.Source.File, d.Source.Pool, d.Source.Volume); err == nil {
+				if path, err := vol.GetPath(); err == nil {
+					disk.SourcePath = path
+				}
+				if volInfo, err := vol.GetInfo(); err == nil {
+					disk.CapacityB = volInfo.Capacity
+				}
+				vol.Free()
+			}
+			out.Disks = append(out.Disks, disk)
 		}
 		for _, ifc := range dx.Devices.Ifaces {
 			src := ifc.Source.Network
@@ -302,6 +325,11 @@ func (c *Client) PerformVMAction(uuidStr string, action string) error {
 		if result == nil {
 			c.logger.Add("VM Force Stopped", name, "Success", "Virtual machine force stopped")
 		}
+	case "reset":
+		result = dom.Reset(0)
+		if result == nil {
+			c.logger.Add("VM Reset", name, "Success", "Virtual machine reset")
+		}
 	case "pause":
 		result = dom.Suspend()
 		if result == nil {
@@ -399,6 +427,7 @@ func (c *Client) DeleteVM(uuidStr string, deleteDisks bool) error {
 }
 
 // GetVMPerformance gets a single, real-time sample of performance counters for a VM.
+// Disk and network counters are summed over all of its devices.
 func (c *Client) GetVMPerformance(uuidStr string) (core.PerformanceSample, error) {
 	var sample core.PerformanceSample
 
@@ -427,18 +456,22 @@ func (c *Client) GetVMPerformance(uuidStr string) (core.PerformanceSample, error
 		}
 	}
 
-	// Get disk I/O stats (first disk device)
-	blockStats, err := dom.BlockStats("vda")
-	if err == nil {
-		sample.DiskReadBytes = uint64(blockStats.RdBytes)
-		sample.DiskWriteBytes = uint64(blockStats.WrBytes)
+	// Sum disk and network I/O across every device in the domain XML
+	disks, ifaces, err := domainStatsDevices(dom)
+	if err != nil {
+		return sample, nil
 	}
-
-	// Get network I/O stats (first interface)
-	ifaceStats, err := dom.InterfaceStats("vnet0")
-	if err == nil {
-		sample.NetRxBytes = uint64(ifaceStats.RxBytes)
-		sample.NetTxBytes = uint64(ifaceStats.TxBytes)
+	for _, dev := range disks {
+		if blockStats, err := dom.BlockStats(dev); err == nil {
+			sample.DiskReadBytes += uint64(blockStats.RdBytes)
+			sample.DiskWriteBytes += uint64(blockStats.WrBytes)
+		}
+	}
+	for _, iface := range ifaces {
+		if ifaceStats, err := dom.InterfaceStats(iface.Device); err == nil {
+			sample.NetRxBytes += uint64(ifaceStats.RxBytes)
+			sample.NetTxBytes += uint64(ifaceStats.TxBytes)
+		}
 	}
 
 	return sample, nil
@@ -586,8 +619,12 @@ func (c *Client) AttachDiskToVM(uuidStr string, volumePath string, targetDev str
       <target dev="%s" bus="virtio"/>
     </disk>`, volumePath, targetDev)
 
-	// Attach the disk
-	if err := dom.AttachDevice(attachXML); err != nil {
+	// Attach the disk to the definition, and hot-plug it if the VM is running
+	flags := libvirt.DOMAIN_DEVICE_MODIFY_CONFIG
+	if active, err := dom.IsActive(); err == nil && active {
+		flags |= libvirt.DOMAIN_DEVICE_MODIFY_LIVE
+	}
+	if err := dom.AttachDeviceFlags(attachXML, flags); err != nil {
 		return fmt.Errorf("failed to attach disk: %w", err)
 	}
 
@@ -606,22 +643,7 @@ func (c *Client) AttachNetworkInterfaceToVM(uuidStr string, networkName string,
 	name, _ := dom.GetName()
 
 	// Determine interface type based on the network name
-	interfaceType := "network" // default to virtual network
-	
-	// Check if it's a system interface (bridge or physical)
-	systemInterfaces, err := c.GetSystemInterfaces()
-	if err == nil {
-		for _, iface := range systemInterfaces {
-			if iface.Name == networkName {
-				if iface.Type == "bridge" {
-					interfaceType = "bridge"
-				} else if iface.Type == "physical" {
-					interfaceType = "direct"
-				}
-				break
-			}
-		}
-	}
+	interfaceType := c.networkInterfaceType(networkName)
 
 	// Create the appropriate attachment XML based on interface type
 	var attachXML string
@@ -679,6 +701,29 @@ func (c *Client) AttachNetworkInterfaceToVM(uuidStr string, networkName string,
 	return nil
 }
 
+// networkInterfaceType returns the <interface> type for a network name:
+// "bridge" for a host bridge, "direct" for a physical interface and "network"
+// for a libvirt virtual network
+func (c *Client) networkInterfaceType(networkName string) string {
+	systemInterfaces, err := c.GetSystemInterfaces()
+	if err != nil {
+		return "network"
+	}
+	for _, iface := range systemInterfaces {
+		if iface.Name != networkName {
+			continue
+		}
+		switch iface.Type {
+		case "bridge":
+			return "bridge"
+		case "physical":
+			return "direct"
+		}
+		return "network"
+	}
+	return "network"
+}
+
 // isFileInKnownStoragePool checks if a file path is within known storage pools
 func isFileInKnownStoragePool(filePath string) bool {
 	// Get the absolute path
@@ -27,58 +27,29 @@ type DomainXML struct {
 		Value     int    `xml:",chardata"`
 	} `xml:"vcpu"`
 	OS struct {
-		Type struct {
+		Firmware string `xml:"firmware,attr,omitempty"` // "efi" for UEFI guests
+		Type     struct {
 			Arch    string `xml:"arch,attr"`
 			Machine string `xml:"machine,attr"`
 			Value   string `xml:",chardata"`
 		} `xml:"type"`
-		Boot struct {
+		Loader *DomainLoader `xml:"loader"` // Secure boot
+		Boot   struct {
 			Dev string `xml:"dev,attr"`
 		} `xml:"boot"`
 		Kernel  string `xml:"kernel,omitempty"`  // For PXE: kernel URL
 		Initrd  string `xml:"initrd,omitempty"`  // For PXE: initrd URL
 		Cmdline string `xml:"cmdline,omitempty"` // For PXE: kernel arguments
 	} `xml:"os"`
-	Devices struct {
-		Emulator string `xml:"emulator"`
-		Disks    []struct {
-			Type   string `xml:"type,attr"`
-			Device string `xml:"device,attr"`
-			Driver struct {
-				Name string `xml:"name,attr"`
-				Type string `xml:"type,attr"`
-			} `xml:"driver"`
-			Source struct {
-				Pool   string `xml:"pool,attr,omitempty"`
-				Volume string `xml:"volume,attr,omitempty"`
-				File   string `xml:"file,attr,omitempty"`
-			} `xml:"source"`
-			Target struct {
-				Dev string `xml:"dev,attr"`
-				Bus string `xml:"bus,attr"`
-			} `xml:"target"`
-		} `xml:"disk"`
-		Interfaces []struct {
-			Type   string `xml:"type,attr"`
-			Source struct {
-				Network string `xml:"network,attr,omitempty"`
-				Bridge  string `xml:"bridge,attr,omitempty"`
-				Dev     string `xml:"dev,attr,omitempty"`
-				Mode    string `xml:"mode,attr,omitempty"`
-			} `xml:"source"`
-			Model struct {
-				Type string `xml:"type,attr"`
-			} `xml:"model"`
-		} `xml:"interface"`
-		Graphics struct {
-			Type     string `xml:"type,attr"`
-			Port     int    `xml:"port,attr"`
-			Autoport string `xml:"autoport,attr"`
-			Listen   struct {
-				Type string `xml:"type,attr"`
-			} `xml:"listen"`
-		} `xml:"graphics"`
-		Serial struct {
+	Features DomainFeatures `xml:"features"`
+	CPU      *DomainCPU     `xml:"cpu"`
+	Devices  struct {
+		Emulator    string             `xml:"emulator"`
+		Disks       []DomainDisk       `xml:"disk"`
+		Controllers []DomainController `xml:"controller"`
+		Interfaces  []DomainInterface  `xml:"interface"`
+		Graphics    *DomainGraphics    `xml:"graphics"` // Nil for headless VMs
+		Serial      struct {
 			Type   string `xml:"type,attr"`
 			Target struct {
 				Type  string `xml:"type,attr"`
@@ -95,9 +66,101 @@ type DomainXML struct {
 				Port int    `xml:"port,attr"`
 			} `xml:"target"`
 		} `xml:"console"`
+		TPM *DomainTPM `xml:"tpm"`
 	} `xml:"devices"`
 }
 
+// DomainDisk is a <disk> of DomainXML
+type DomainDisk struct {
+	Type   string `xml:"type,attr"`
+	Device string `xml:"device,attr"`
+	Driver struct {
+		Name  string `xml:"name,attr"`
+		Type  string `xml:"type,attr"`
+		Cache string `xml:"cache,attr,omitempty"`
+		IO    string `xml:"io,attr,omitempty"`
+	} `xml:"driver"`
+	Source struct {
+		Pool   string `xml:"pool,attr,omitempty"`
+		Volume string `xml:"volume,attr,omitempty"`
+		File   string `xml:"file,attr,omitempty"`
+	} `xml:"source"`
+	Target struct {
+		Dev string `xml:"dev,attr"`
+		Bus string `xml:"bus,attr"`
+	} `xml:"target"`
+}
+
+// DomainInterface is an <interface> of DomainXML
+type DomainInterface struct {
+	Type string `xml:"type,attr"`
+	MAC  *struct {
+		Address string `xml:"address,attr"`
+	} `xml:"mac"`
+	Source struct {
+		Network string `xml:"network,attr,omitempty"`
+		Bridge  string `xml:"bridge,attr,omitempty"`
+		Dev     string `xml:"dev,attr,omitempty"`
+		Mode    string `xml:"mode,attr,omitempty"`
+	} `xml:"source"`
+	Model struct {
+		Type string `xml:"type,attr"`
+	} `xml:"model"`
+	FilterRef *struct {
+		Filter string `xml:"filter,attr"`
+	} `xml:"filterref"`
+}
+
+// DomainController is a <controller>, added for SCSI disks
+type DomainController struct {
+	Type  string `xml:"type,attr"`
+	Index int    `xml:"index,attr"`
+	Model string `xml:"model,attr,omitempty"`
+}
+
+// DomainGraphics is the console a VM is reached on, VNC or SPICE
+type DomainGraphics struct {
+	Type     string `xml:"type,attr"`
+	Port     int    `xml:"port,attr"`
+	Autoport string `xml:"autoport,attr"`
+	Listen   struct {
+		Type string `xml:"type,attr"`
+	} `xml:"listen"`
+}
+
+// DomainFeatures are hypervisor features. SMM is required by secure boot.
+type DomainFeatures struct {
+	ACPI *struct{} `xml:"acpi"`
+	APIC *struct{} `xml:"apic"`
+	SMM  *struct {
+		State string `xml:"state,attr"`
+	} `xml:"smm"`
+}
+
+// DomainCPU is the CPU mode and topology
+type DomainCPU struct {
+	Mode     string `xml:"mode,attr,omitempty"`
+	Topology *struct {
+		Sockets int `xml:"sockets,attr"`
+		Cores   int `xml:"cores,attr"`
+		Threads int `xml:"threads,attr"`
+	} `xml:"topology"`
+}
+
+// DomainLoader has firmware autoselection pick an OVMF build with secure boot
+type DomainLoader struct {
+	Secure string `xml:"secure,attr"`
+}
+
+// DomainTPM is an emulated TPM (swtpm)
+type DomainTPM struct {
+	Model   string `xml:"model,attr"`
+	Backend struct {
+		Type    string `xml:"type,attr"`
+		Version string `xml:"version,attr"`
+	} `xml:"backend"`
+}
+
 // CreateVM orchestrates creating a new volume and defining the VM.
 func (c *Client) CreateVM(cfg core.VMCreationConfig) (core.VM_Detailed, error) {
 	// Step 1: Look up the source image from the managed library
@@ -120,10 +183,19 @@ func (c *Client) CreateVM(cfg core.VMCreationConfig) (core.VM_Detailed, error) {
 		}
 	}
 
+	// Volumes created so far, deleted again if the VM cannot be defined
+	var created []createdVolume
+	rollback := func() {
+		for _, v := range created {
+			_ = c.deleteVolume(v.pool, v.name) // Best-effort cleanup
+		}
+	}
+
 	// Step 2: Create the main disk volume for the VM
 	var diskName string
-	
-	if cfg.ImageType == "template" && sourcePath != "" {
+
+	if (cfg.ImageType == "template" || cfg.ImageType == "iso") && sourcePath != "" || cfg.ImageType == "pxe" {
+		// Templates are copied into it below; ISO and PXE installations start empty
 		diskName = fmt.Sprintf("%s-disk-0.qcow2", cfg.Name)
 		volCfg := core.VolumeConfig{
 			Name:   diskName,
@@ -132,99 +204,79 @@ func (c *Client) CreateVM(cfg core.VMCreationConfig) (core.VM_Detailed, error) {
 		if err := c.CreateVolume(flintImagePoolName, volCfg); err != nil {
 			return core.VM_Detailed{}, fmt.Errorf("could not create vm disk volume: %w", err)
 		}
-		
+		created = append(created, createdVolume{pool: flintImagePoolName, name: diskName})
+	}
+
+	if cfg.ImageType == "template" && diskName != "" {
 		// Copy the template image to the new volume
-		pool, err := c.conn.LookupStoragePoolByName(flintImagePoolName)
-		if err != nil {
-			return core.VM_Detailed{}, fmt.Errorf("lookup pool: %w", err)
-		}
-		defer pool.Free()
-		
-		vol, err := pool.LookupStorageVolByName(diskName)
+		volPath, err := c.volumePath(flintImagePoolName, diskName)
 		if err != nil {
-			return core.VM_Detailed{}, fmt.Errorf("lookup volume: %w", err)
+			rollback()
+			return core.VM_Detailed{}, err
 		}
-		defer vol.Free()
-		
-		volPath, err := vol.GetPath()
-		if err != nil {
-			return core.VM_Detailed{}, fmt.Errorf("get volume path: %w", err)
-		}
-		
+
 		// Use qemu-img to create a copy with the template as backing file
 		if err := exec.Command("qemu-img", "create", "-f", "qcow2", "-F", "qcow2", "-b", sourcePath, volPath).Run(); err != nil {
+			rollback()
 			return core.VM_Detailed{}, fmt.Errorf("failed to create disk from template: %w", err)
 		}
-		
+
 		// Resize the disk to the requested size
 		if err := exec.Command("qemu-img", "resize", volPath, fmt.Sprintf("%dG", cfg.DiskSizeGB)).Run(); err != nil {
 			fmt.Printf("Warning: Failed to resize disk: %v\n", err)
 		}
-	} else if cfg.ImageType == "iso" && sourcePath != "" {
-		// For ISO installations, create an empty disk for the OS installation
-		diskName = fmt.Sprintf("%s-disk-0.qcow2", cfg.Name)
-		volCfg := core.VolumeConfig{
-			Name:   diskName,
-			SizeGB: uint64(cfg.DiskSizeGB),
-		}
-		if err := c.CreateVolume(flintImagePoolName, volCfg); err != nil {
-			return core.VM_Detailed{}, fmt.Errorf("could not create vm disk volume: %w", err)
-		}
-	} else if cfg.ImageType == "pxe" {
-		// For PXE installations, create an empty disk for the OS installation
-		diskName = fmt.Sprintf("%s-disk-0.qcow2", cfg.Name)
-		volCfg := core.VolumeConfig{
-			Name:   diskName,
-			SizeGB: uint64(cfg.DiskSizeGB),
-		}
-		if err := c.CreateVolume(flintImagePoolName, volCfg); err != nil {
-			return core.VM_Detailed{}, fmt.Errorf("could not create vm disk volume: %w", err)
+	}
+
+	// Step 3: Create the data disks
+	for i, disk := range cfg.Disks {
+		pool, name := diskPoolName(disk.Pool), dataDiskName(cfg.Name, i)
+		if err := c.CreateVolume(pool, core.VolumeConfig{Name: name, SizeGB: disk.SizeGB}); err != nil {
+			rollback()
+			return core.VM_Detailed{}, fmt.Errorf("could not create data disk %s: %w", name, err)
 		}
+		created = append(created, createdVolume{pool: pool, name: name})
 	}
 
-	// Step 3: Generate cloud-init user data if configured
+	// Step 4: Generate cloud-init user data if configured
 	var userData string
 	if cfg.CloudInit != nil {
 		var err error
 		userData, err = generateUserDataYAML(cfg.CloudInit)
 		if err != nil {
+			rollback()
 			return core.VM_Detailed{}, fmt.Errorf("failed to generate cloud-init user data: %w", err)
 		}
 	}
 
-	// Step 4: Build the Domain XML structure from the config.
+	// Step 5: Build the Domain XML structure from the config.
 	domain := buildDomainXML(cfg, diskName, sourcePath)
 
-	// Step 5: Marshal the struct into an XML string.
+	// Step 6: Marshal the struct into an XML string.
 	xmlBytes, err := xml.MarshalIndent(domain, "", "  ")
 	if err != nil {
 		// This should not happen with a valid struct, but handle it.
-		// On failure, we should clean up the created disk.
-		if diskName != "" {
-			_ = c.deleteVolume(flintImagePoolName, diskName) // Best-effort cleanup
-		}
+		// On failure, we should clean up the created disks.
+		rollback()
 		return core.VM_Detailed{}, fmt.Errorf("failed to marshal domain xml: %w", err)
 	}
 	xmlString := string(xmlBytes)
 
-	// Step 6: Define the domain from the XML.
+	// Step 7: Define the domain from the XML.
 	dom, err := c.conn.DomainDefineXML(xmlString)
 	if err != nil {
-		if diskName != "" {
-			_ = c.deleteVolume(flintImagePoolName, diskName) // Best-effort cleanup
-		}
+		rollback()
 		return core.VM_Detailed{}, fmt.Errorf("failed to define domain from xml: %w", err)
 	}
 	defer dom.Free()
 
-	// Step 7: If cloud-init is configured, create and attach cloud-init ISO
+	// Step 8: If cloud-init is configured, create and attach cloud-init ISO
 	if userData != "" {
 		if err := createAndAttachCloudInitISO(c.conn, dom, userData, cfg.Name); err != nil {
 			fmt.Printf("Warning: Failed to create cloud-init ISO: %v\n", err)
 		}
 	}
 
-	// Step 8: Start the domain if requested.
+	// Step 9: Start the domain if requested.
 	if cfg.StartOnCreate {
 		if err := dom.Create(); err != nil {
 			// Failed to start, but it's defined. Return the details anyway.
@@ -232,12 +284,35 @@ func (c *Client) CreateVM(cfg core.VMCreationConfig) (core.VM_Detailed, error) {
 		}
 	}
 
-	// Step 9: Return the details of the newly created VM.
+	// Step 10: Return the details of the newly created VM.
 	uuid, _ := dom.GetUUIDString()
 	return c.GetVMDetails(uuid)
 }
 
+// volumePath returns the path of a volume in a pool
+func (c *Client) volumePath(poolName, volName string) (string, error) {
+	pool, err := c.conn.LookupStoragePoolByName(poolName)
+	if err != nil {
+		return "", fmt.Errorf("lookup pool: %w", err)
+	}
+	defer pool.Free()
+
+	vol, err := pool.LookupStorageVolByName(volName)
+	if err != nil {
+		return "", fmt.Errorf("lookup volume: %w", err)
+	}
+	defer vol.Free()
+
+	path, err := vol.GetPath()
+	if err != nil {
+		return "", fmt.Errorf("get volume path: %w", err)
+	}
+	return path, nil
+}
+
 // buildDomainXML is a helper to translate our config into the XML struct.
+// The config is expected to be validated; disks beyond the 26 device names
+// of a bus are left out.
 func buildDomainXML(cfg core.VMCreationConfig, diskVolumeName string, sourcePath string) DomainXML {
 	// A set of sensible defaults.
 	d := DomainXML{
@@ -251,130 +326,90 @@ func buildDomainXML(cfg core.VMCreationConfig, diskVolumeName string, sourcePath
 	d.VCPU.Placement = "static"
 	d.VCPU.Value = cfg.VCPUs
 
+	q35 := cfg.MachineType == "q35"
 	d.OS.Type.Arch = "x86_64"
-	d.OS.Type.Machine = "pc" // A modern machine type
+	d.OS.Type.Machine = "pc"
+	if q35 {
+		d.OS.Type.Machine = "q35"
+	}
 	d.OS.Type.Value = "hvm"
 
+	d.Features.ACPI = &struct{}{}
+	d.Features.APIC = &struct{}{}
+
+	// --- Firmware ---
+	// libvirt picks the OVMF build; secure boot needs one with SMM
+	if cfg.Firmware == "uefi" {
+		d.OS.Firmware = "efi"
+		if cfg.SecureBoot {
+			d.OS.Loader = &DomainLoader{Secure: "yes"}
+			d.Features.SMM = &struct {
+				State string `xml:"state,attr"`
+			}{State: "on"}
+		}
+	}
+
+	// --- CPU ---
+	if cfg.CPU != nil {
+		d.CPU = buildDomainCPU(*cfg.CPU)
+	}
+
 	d.Devices.Emulator = "/usr/bin/qemu-system-x86_64"
 
+	usedDevs := make(map[string]bool)
+
 	// --- Main OS Disk ---
-	if cfg.ImageType == "template" && diskVolumeName != "" {
-		// Use the created disk volume
-		osDisk := struct {
-			Type   string `xml:"type,attr"`
-			Device string `xml:"device,attr"`
-			Driver struct {
-				Name string `xml:"name,attr"`
-				Type string `xml:"type,attr"`
-			} `xml:"driver"`
-			Source struct {
-				Pool   string `xml:"pool,attr,omitempty"`
-				Volume string `xml:"volume,attr,omitempty"`
-				File   string `xml:"file,attr,omitempty"`
-			} `xml:"source"`
-			Target struct {
-				Dev string `xml:"dev,attr"`
-				Bus string `xml:"bus,attr"`
-			} `xml:"target"`
-		}{
-			Type:   "volume",
-			Device: "disk",
-			Driver: struct {
-				Name string `xml:"name,attr"`
-				Type string `xml:"type,attr"`
-			}{Name: "qemu", Type: "qcow2"},
-			Source: struct {
-				Pool   string `xml:"pool,attr,omitempty"`
-				Volume string `xml:"volume,attr,omitempty"`
-				File   string `xml:"file,attr,omitempty"`
-			}{Pool: flintImagePoolName, Volume: diskVolumeName},
-			Target: struct {
-				Dev string `xml:"dev,attr"`
-				Bus string `xml:"bus,attr"`
-			}{Dev: "vda", Bus: "virtio"},
-		}
+	// ISO VMs get an empty disk for the installation
+	if (cfg.ImageType == "template" || cfg.ImageType == "iso") && diskVolumeName != "" {
+		osDisk := volumeDisk(flintImagePoolName, diskVolumeName, "vda", "virtio")
+		usedDevs["vda"] = true
 		d.Devices.Disks = append(d.Devices.Disks, osDisk)
-		d.OS.Boot.Dev = "hd" // Boot from hard disk
-	}
-	
-	// Add main disk for ISO VMs (for OS installation)
-	if cfg.ImageType == "iso" && diskVolumeName != "" {
-		mainDisk := struct {
-			Type   string `xml:"type,attr"`
-			Device string `xml:"device,attr"`
-			Driver struct {
-				Name string `xml:"name,attr"`
-				Type string `xml:"type,attr"`
-			} `xml:"driver"`
-			Source struct {
-				Pool   string `xml:"pool,attr,omitempty"`
-				Volume string `xml:"volume,attr,omitempty"`
-				File   string `xml:"file,attr,omitempty"`
-			} `xml:"source"`
-			Target struct {
-				Dev string `xml:"dev,attr"`
-				Bus string `xml:"bus,attr"`
-			} `xml:"target"`
-		}{
-			Type:   "volume",
-			Device: "disk",
-			Driver: struct {
-				Name string `xml:"name,attr"`
-				Type string `xml:"type,attr"`
-			}{Name: "qemu", Type: "qcow2"},
-			Source: struct {
-				Pool   string `xml:"pool,attr,omitempty"`
-				Volume string `xml:"volume,attr,omitempty"`
-				File   string `xml:"file,attr,omitempty"`
-			}{Pool: flintImagePoolName, Volume: diskVolumeName},
-			Target: struct {
-				Dev string `xml:"dev,attr"`
-				Bus string `xml:"bus,attr"`
-			}{Dev: "vda", Bus: "virtio"},
+		if cfg.ImageType == "template" {
+			d.OS.Boot.Dev = "hd" // Boot from hard disk
 		}
-		d.Devices.Disks = append(d.Devices.Disks, mainDisk)
 	}
-	
+
 	// Add ISO as CDROM for ISO VMs
 	if cfg.ImageType == "iso" && sourcePath != "" {
-		// Use ISO as CDROM
-		cdrom := struct {
-			Type   string `xml:"type,attr"`
-			Device string `xml:"device,attr"`
-			Driver struct {
-				Name string `xml:"name,attr"`
-				Type string `xml:"type,attr"`
-			} `xml:"driver"`
-			Source struct {
-				Pool   string `xml:"pool,attr,omitempty"`
-				Volume string `xml:"volume,attr,omitempty"`
-				File   string `xml:"file,attr,omitempty"`
-			} `xml:"source"`
-			Target struct {
-				Dev string `xml:"dev,attr"`
-				Bus string `xml:"bus,attr"`
-			} `xml:"target"`
-		}{
-			Type:   "file",
-			Device: "cdrom",
-			Driver: struct {
-				Name string `xml:"name,attr"`
-				Type string `xml:"type,attr"`
-			}{Name: "qemu", Type: "raw"},
-			Source: struct {
-				Pool   string `xml:"pool,attr,omitempty"`
-				Volume string `xml:"volume,attr,omitempty"`
-				File   string `xml:"file,attr,omitempty"`
-			}{File: sourcePath},
-			Target: struct {
-				Dev string `xml:"dev,attr"`
-				Bus string `xml:"bus,attr"`
-			}{Dev: "sdb", Bus: "sata"},
-		}
+		var cdrom DomainDisk
+		cdrom.Type = "file"
+		cdrom.Device = "cdrom"
+		cdrom.Driver.Name = "qemu"
+		cdrom.Driver.Type = "raw"
+		cdrom.Source.File = sourcePath
+		cdrom.Target.Dev = "sdb"
+		cdrom.Target.Bus = "sata"
+		usedDevs["sdb"] = true
 		d.Devices.Disks = append(d.Devices.Disks, cdrom)
 		d.OS.Boot.Dev = "cdrom" // Set boot order to CDROM
 	}
 
+	// --- Data Disks ---
+	for i, disk := range cfg.Disks {
+		bus := disk.Bus
+		if bus == "" {
+			bus = "virtio"
+		}
+		prefix := "sd"
+		if bus == "virtio" {
+			prefix = "vd"
+		}
+		dev := nextDiskDev(prefix, usedDevs)
+		if dev == "" {
+			continue
+		}
+		usedDevs[dev] = true
+
+		entry := volumeDisk(diskPoolName(disk.Pool), dataDiskName(cfg.Name, i), dev, bus)
+		entry.Driver.Cache = disk.Cache
+		entry.Driver.IO = disk.IO
+		d.Devices.Disks = append(d.Devices.Disks, entry)
+
+		if bus == "scsi" && len(d.Devices.Controllers) == 0 {
+			d.Devices.Controllers = append(d.Devices.Controllers, DomainController{Type: "scsi", Index: 0, Model: "virtio-scsi"})
+		}
+	}
+
 	// --- PXE Boot Configuration ---
 	if cfg.PXEConfig != nil && cfg.PXEConfig.BootFromPXE {
 		// Set kernel, initrd, and cmdline for network boot
@@ -384,17 +419,25 @@ func buildDomainXML(cfg core.VMCreationConfig, diskVolumeName string, sourcePath
 		d.OS.Boot.Dev = "network" // Boot from network
 	}
 
-	// --- Network Interface ---
+	// --- Network Interfaces ---
 	if cfg.NetworkName != "" {
 		nic := buildNetworkInterface(cfg.NetworkName)
 		d.Devices.Interfaces = append(d.Devices.Interfaces, nic)
 	}
+	for _, nic := range cfg.NICs {
+		d.Devices.Interfaces = append(d.Devices.Interfaces, buildNIC(nic))
+	}
 
-	// --- Default Graphics for Console Access ---
-	d.Devices.Graphics.Type = "vnc"
-	d.Devices.Graphics.Port = -1
-	d.Devices.Graphics.Autoport = "yes"
-	d.Devices.Graphics.Listen.Type = "address"
+	// --- Graphics for Console Access ---
+	// VNC unless SPICE or a headless VM is asked for
+	if cfg.Graphics != "none" {
+		graphics := &DomainGraphics{Type: "vnc", Port: -1, Autoport: "yes"}
+		if cfg.Graphics == "spice" {
+			graphics.Type = "spice"
+		}
+		graphics.Listen.Type = "address"
+		d.Devices.Graphics = graphics
+	}
 
 	// --- Serial Console (PTY) ---
 	d.Devices.Serial.Type = "pty"
@@ -407,9 +450,72 @@ func buildDomainXML(cfg core.VMCreationConfig, diskVolumeName string, sourcePath
 	d.Devices.Console.Target.Type = "serial"
 	d.Devices.Console.Target.Port = 0
 
+	// --- TPM 2.0 ---
+	// CRB is the interface OVMF and Windows expect on q35
+	if cfg.TPM {
+		tpm := &DomainTPM{Model: "tpm-tis"}
+		if q35 {
+			tpm.Model = "tpm-crb"
+		}
+		tpm.Backend.Type = "emulator"
+		tpm.Backend.Version = "2.0"
+		d.Devices.TPM = tpm
+	}
+
 	return d
 }
 
+// volumeDisk is a qcow2 disk backed by a pool volume
+func volumeDisk(pool, volume, dev, bus string) DomainDisk {
+	var disk DomainDisk
+	disk.Type = "volume"
+	disk.Device = "disk"
+	disk.Driver.Name = "qemu"
+	disk.Driver.Type = "qcow2"
+	disk.Source.Pool = pool
+	disk.Source.Volume = volume
+	disk.Target.Dev = dev
+	disk.Target.Bus = bus
+	return disk
+}
+
+// buildDomainCPU translates a CPU config; missing topology counts are 1
+func buildDomainCPU(cfg core.VMCPUConfig) *DomainCPU {
+	cpu := &DomainCPU{Mode: cfg.Mode}
+	if cfg.Sockets > 0 || cfg.Cores > 0 || cfg.Threads > 0 {
+		cpu.Topology = &struct {
+			Sockets int `xml:"sockets,attr"`
+			Cores   int `xml:"cores,attr"`
+			Threads int `xml:"threads,attr"`
+		}{Sockets: max(cfg.Sockets, 1), Cores: max(cfg.Cores, 1), Threads: max(cfg.Threads, 1)}
+	}
+	return cpu
+}
+
+// nextDiskDev returns the first free device name with a prefix, such as vdb
+// after vda, or "" when all 26 are taken
+func nextDiskDev(prefix string, used map[string]bool) string {
+	for c := 'a'; c <= 'z'; c++ {
+		if dev := prefix + string(c); !used[dev] {
+			return dev
+		}
+	}
+	return ""
+}
+
+// diskPoolName defaults a pool to the image library
+func diskPoolName(pool string) string {
+	if pool == "" {
+		return flintImagePoolName
+	}
+	return pool
+}
+
+// dataDiskName continues the numbering of the OS disk, <vm>-disk-0.qcow2
+func dataDiskName(vmName string, i int) string {
+	return fmt.Sprintf("%s-disk-%d.qcow2", vmName, i+1)
+}
+
 // deleteVolume is a small helper for cleanup on failure.
 func (c *Client) deleteVolume(poolName, volName string) error {
 	pool, err := c.conn.LookupStoragePoolByName(poolName)
@@ -567,35 +673,9 @@ func addDiskToXML(existingXML, diskXML string) (string, error) {
 }
 
 // buildNetworkInterface creates the appropriate network interface configuration
-func buildNetworkInterface(networkName string) struct {
-	Type   string `xml:"type,attr"`
-	Source struct {
-		Network string `xml:"network,attr,omitempty"`
-		Bridge  string `xml:"bridge,attr,omitempty"`
-		Dev     string `xml:"dev,attr,omitempty"`
-		Mode    string `xml:"mode,attr,omitempty"`
-	} `xml:"source"`
-	Model struct {
-		Type string `xml:"type,attr"`
-	} `xml:"model"`
-} {
-	nic := struct {
-		Type   string `xml:"type,attr"`
-		Source struct {
-			Network string `xml:"network,attr,omitempty"`
-			Bridge  string `xml:"bridge,attr,omitempty"`
-			Dev     string `xml:"dev,attr,omitempty"`
-			Mode    string `xml:"mode,attr,omitempty"`
-		} `xml:"source"`
-		Model struct {
-			Type string `xml:"type,attr"`
-		} `xml:"model"`
-	}{
-		Type: "network", // default
-		Model: struct {
-			Type string `xml:"type,attr"`
-		}{Type: "virtio"},
-	}
+func buildNetworkInterface(networkName string) DomainInterface {
+	nic := DomainInterface{Type: "network"} // default
+	nic.Model.Type = "virtio"
 
 	// We need access to the client to check system interfaces
 	// For now, we'll use a simple heuristic: if it starts with "br" it's likely a bridge
@@ -611,6 +691,34 @@ func buildNetworkInterface(networkName string) struct {
 	return nic
 }
 
+// buildNIC creates an interface from a NIC config, which names its source
+// as a network or a bridge explicitly
+func buildNIC(cfg core.VMNICConfig) DomainInterface {
+	nic := DomainInterface{Type: "network"}
+	nic.Source.Network = cfg.Network
+	if cfg.Bridge != "" {
+		nic.Type = "bridge"
+		nic.Source.Network = ""
+		nic.Source.Bridge = cfg.Bridge
+	}
+
+	nic.Model.Type = cfg.Model
+	if nic.Model.Type == "" {
+		nic.Model.Type = "virtio"
+	}
+	if cfg.MAC != "" {
+		nic.MAC = &struct {
+			Address string `xml:"address,attr"`
+		}{Address: strings.ToLower(cfg.MAC)}
+	}
+	if cfg.NWFilter != "" {
+		nic.FilterRef = &struct {
+			Filter string `xml:"filter,attr"`
+		}{Filter: cfg.NWFilter}
+	}
+	return nic
+}
+
 // createLibvirtCloudInitDisk creates a cloud-init disk using libvirt's volume system
 func createLibvirtCloudInitDisk(conn *libvirt.Connect, dom *libvirt.Domain, userData, vmName string) error {
 	// Get the storage pool
@@ -884,6 +992,11 @@ func createAndAttachCloudInitISO(conn *libvirt.Connect, dom *libvirt.Domain, use
 	// Parse XML to find used devices
 	type DomainXML struct {
 		XMLName xml.Name `xml:"domain"`
+		OS      struct {
+			Type struct {
+				Machine string `xml:"machine,attr"`
+			} `xml:"type"`
+		} `xml:"os"`
 		Devices struct {
 			Disks []struct {
 				Target struct {
@@ -904,8 +1017,14 @@ func createAndAttachCloudInitISO(conn *libvirt.Connect, dom *libvirt.Domain, use
 		usedDevices[disk.Target.Dev] = true
 	}
 	
-	cloudInitDevice := "hdc" // ide2 - preferred for cloud-init
-	if usedDevices["hdc"] {
+	cloudInitDevice, cloudInitBus := "hdc", "ide" // ide2 - preferred for cloud-init
+	if strings.Contains(domain.OS.Type.Machine, "q35") {
+		// q35 has no IDE controller
+		cloudInitDevice, cloudInitBus = nextDiskDev("sd", usedDevices), "sata"
+		if cloudInitDevice == "" {
+			return fmt.Errorf("no available SATA devices for cloud-init")
+		}
+	} else if usedDevices["hdc"] {
 		cloudInitDevice = "hdd" // ide3 - fallback
 		if usedDevices["hdd"] {
 			return fmt.Errorf("no available IDE devices for cloud-init")
@@ -916,9 +1035,9 @@ func createAndAttachCloudInitISO(conn *libvirt.Connect, dom *libvirt.Domain, use
 	cloudInitDiskXML := fmt.Sprintf(`    <disk type="file" device="cdrom">
       <driver name="qemu" type="raw"/>
       <source file="%s"/>
-      <target dev="%s" bus="ide"/>
+      <target dev="%s" bus="%s"/>
       <readonly/>
-    </disk>`, isoPath, cloudInitDevice)
+    </disk>`, isoPath, cloudInitDevice, cloudInitBus)
 	
 	// Add to domain XML
 	updatedXML, err := addDiskToXML(xmlDesc, cloudInitDiskXML)
@@ -0,0 +1,704 @@
+package libvirtclient
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// UpdateVM reconfigures a VM in place. Every change is made to the running VM
+// when the hypervisor can do it live (vCPU hotplug, memory ballooning, block
+// resize, device detach, NIC network and CD-ROM media changes) and to the
+// persistent definition, so what cannot be applied live takes effect when the
+// VM is next started. Changes are made in order and stop at the first failure;
+// the result then holds the changes already made.
+func (c *Client) UpdateVM(uuidStr string, req core.UpdateVMRequest) (core.UpdateVMResult, error) {
+	if err := req.Validate(); err != nil {
+		return core.UpdateVMResult{}, err
+	}
+
+	dom, err := c.conn.LookupDomainByUUIDString(uuidStr)
+	if err != nil {
+		return core.UpdateVMResult{}, fmt.Errorf("lookup domain: %w", err)
+	}
+	defer dom.Free()
+
+	name, _ := dom.GetName()
+
+	u := &vmUpdate{c: c, dom: dom}
+	if u.active, err = dom.IsActive(); err != nil {
+		return core.UpdateVMResult{}, fmt.Errorf("failed to get domain state: %w", err)
+	}
+	if u.persistent, err = dom.IsPersistent(); err != nil {
+		return core.UpdateVMResult{}, fmt.Errorf("failed to get domain state: %w", err)
+	}
+
+	if err := u.apply(req); err != nil {
+		c.logger.Add("VM Reconfigured", name, "Error", err.Error())
+		if len(u.changes) > 0 {
+			err = fmt.Errorf("%w (already changed: %s)", err, u.summary())
+		}
+		return core.UpdateVMResult{Changes: u.changes}, err
+	}
+	c.logger.Add("VM Reconfigured", name, "Success", u.summary())
+
+	result := core.UpdateVMResult{Changes: u.changes}
+	for _, change := range u.changes {
+		result.RestartRequired = result.RestartRequired || change.RestartRequired
+	}
+	if result.VM, err = c.GetVMDetails(uuidStr); err != nil {
+		return result, fmt.Errorf("failed to get VM details: %w", err)
+	}
+	return result, nil
+}
+
+// vmUpdate applies the changes of one UpdateVM call to a domain
+type vmUpdate struct {
+	c          *Client
+	dom        *libvirt.Domain
+	active     bool
+	persistent bool
+	changes    []core.VMChange
+}
+
+// apply makes the requested changes, detaching devices first so a resize or
+// NIC change never touches a device that is about to go
+func (u *vmUpdate) apply(req core.UpdateVMRequest) error {
+	for _, target := range req.DetachDisks {
+		if err := u.detachDisk(target); err != nil {
+			return err
+		}
+	}
+	for _, mac := range req.DetachNICs {
+		if err := u.detachNIC(mac); err != nil {
+			return err
+		}
+	}
+	for _, resize := range req.ResizeDisks {
+		if err := u.resizeDisk(resize); err != nil {
+			return err
+		}
+	}
+	for _, nic := range req.UpdateNICs {
+		if err := u.updateNIC(nic); err != nil {
+			return err
+		}
+	}
+	if req.CDROM != nil {
+		if err := u.changeCDROM(*req.CDROM); err != nil {
+			return err
+		}
+	}
+	if req.VCPUs > 0 {
+		if err := u.setVCPUs(req.VCPUs); err != nil {
+			return err
+		}
+	}
+	if req.MemoryMB > 0 {
+		if err := u.setMemory(req.MemoryMB); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record adds a change to the result. A change that reached only the
+// definition of a running VM takes effect when the VM is restarted.
+func (u *vmUpdate) record(change core.VMChange) {
+	change.RestartRequired = u.active && change.Config && !change.Live
+	u.changes = append(u.changes, change)
+}
+
+// summary lists the changes made, for the activity log and errors
+func (u *vmUpdate) summary() string {
+	parts := make([]string, 0, len(u.changes))
+	for _, change := range u.changes {
+		parts = append(parts, fmt.Sprintf("%s %s", change.Field, change.To))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// views are where device changes are made: the running VM, the persistent
+// definition, or both
+func (u *vmUpdate) views() []libvirt.DomainDeviceModifyFlags {
+	var views []libvirt.DomainDeviceModifyFlags
+	if u.active {
+		views = append(views, libvirt.DOMAIN_DEVICE_MODIFY_LIVE)
+	}
+	if u.persistent {
+		views = append(views, libvirt.DOMAIN_DEVICE_MODIFY_CONFIG)
+	}
+	return views
+}
+
+// devices returns the devices of one view. It is read again for every change
+// since earlier changes alter the XML.
+func (u *vmUpdate) devices(view libvirt.DomainDeviceModifyFlags) ([]domainDevice, error) {
+	var flags libvirt.DomainXMLFlags
+	if view == libvirt.DOMAIN_DEVICE_MODIFY_CONFIG {
+		flags = libvirt.DOMAIN_XML_INACTIVE
+	}
+	xmlDesc, err := u.dom.GetXMLDesc(flags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain XML: %w", err)
+	}
+	return parseDomainDevices(xmlDesc)
+}
+
+// mark notes that a change reached a view
+func mark(change *core.VMChange, view libvirt.DomainDeviceModifyFlags) {
+	if view == libvirt.DOMAIN_DEVICE_MODIFY_LIVE {
+		change.Live = true
+	} else {
+		change.Config = true
+	}
+}
+
+func (u *vmUpdate) detachDisk(target string) error {
+	return u.detach("disk "+target, func(d domainDevice) (string, bool) {
+		var disk DomainDisk
+		if !d.decode("disk", &disk) || disk.Target.Dev != target {
+			return "", false
+		}
+		return diskSource(disk), true
+	})
+}
+
+func (u *vmUpdate) detachNIC(mac string) error {
+	return u.detach("nic "+strings.ToLower(mac), func(d domainDevice) (string, bool) {
+		nic, ok := d.nic(mac)
+		return interfaceNetwork(nic), ok
+	})
+}
+
+// detach removes the device match picks from every view it is in. match also
+// describes the device for the result.
+func (u *vmUpdate) detach(field string, match func(domainDevice) (string, bool)) error {
+	change := core.VMChange{Field: field, To: "detached"}
+	for _, view := range u.views() {
+		devices, err := u.devices(view)
+		if err != nil {
+			return err
+		}
+		for _, d := range devices {
+			from, ok := match(d)
+			if !ok {
+				continue
+			}
+			if change.From == "" {
+				change.From = from
+			}
+			if err := u.dom.DetachDeviceFlags(d.String(), view); err != nil {
+				return fmt.Errorf("failed to detach %s: %w", field, err)
+			}
+			mark(&change, view)
+			break
+		}
+	}
+	if !change.Live && !change.Config {
+		return fmt.Errorf("VM has no %s", field)
+	}
+	u.record(change)
+	return nil
+}
+
+// resizeDisk grows a disk: a running VM's with a block resize, which the guest
+// sees at once, and a shut-off VM's by resizing its storage volume
+func (u *vmUpdate) resizeDisk(r core.DiskResize) error {
+	view := libvirt.DOMAIN_DEVICE_MODIFY_CONFIG
+	if u.active {
+		view = libvirt.DOMAIN_DEVICE_MODIFY_LIVE
+	}
+	devices, err := u.devices(view)
+	if err != nil {
+		return err
+	}
+	var disk DomainDisk
+	found := false
+	for _, d := range devices {
+		var candidate DomainDisk
+		if d.decode("disk", &candidate) && candidate.Target.Dev == r.Target {
+			disk, found = candidate, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("VM has no disk %s", r.Target)
+	}
+	if disk.Device != "disk" {
+		return fmt.Errorf("%s is a %s, not a disk", r.Target, disk.Device)
+	}
+
+	size := r.SizeGB << 30
+	change := core.VMChange{Field: "disk " + r.Target, To: formatGB(size)}
+	if u.active {
+		info, err := u.dom.GetBlockInfo(r.Target, 0)
+		if err != nil {
+			return fmt.Errorf("failed to get size of disk %s: %w", r.Target, err)
+		}
+		change.From = formatGB(info.Capacity)
+		if size < info.Capacity {
+			return fmt.Errorf("disk %s is %s; disks cannot be shrunk", r.Target, change.From)
+		}
+		if size == info.Capacity {
+			change.Note = "unchanged"
+			u.record(change)
+			return nil
+		}
+		if err := u.dom.BlockResize(r.Target, size, libvirt.DOMAIN_BLOCK_RESIZE_BYTES); err != nil {
+			return fmt.Errorf("failed to resize disk %s: %w", r.Target, err)
+		}
+		// The image itself grew, so the definition needs no change
+		change.Live, change.Config = true, true
+		u.record(change)
+		return nil
+	}
+
+	vol, err := u.c.lookupDiskVolume(disk.Source.File, disk.Source.Pool, disk.Source.Volume)
+	if err != nil {
+		return fmt.Errorf("disk %s is not a storage volume, start the VM to resize it: %w", r.Target, err)
+	}
+	defer vol.Free()
+	info, err := vol.GetInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get size of disk %s: %w", r.Target, err)
+	}
+	change.From = formatGB(info.Capacity)
+	if size < info.Capacity {
+		return fmt.Errorf("disk %s is %s; disks cannot be shrunk", r.Target, change.From)
+	}
+	if size == info.Capacity {
+		change.Note = "unchanged"
+		u.record(change)
+		return nil
+	}
+	if err := vol.Resize(size, 0); err != nil {
+		return fmt.Errorf("failed to resize disk %s: %w", r.Target, err)
+	}
+	change.Config = true
+	u.record(change)
+	return nil
+}
+
+// updateNIC moves a NIC to another network, live where possible, and changes
+// its model, which QEMU cannot do to a running NIC
+func (u *vmUpdate) updateNIC(n core.NICUpdate) error {
+	field := "nic " + strings.ToLower(n.MAC)
+	var ifaceType string
+	if n.Network != "" {
+		ifaceType = u.c.networkInterfaceType(n.Network)
+	}
+	network := core.VMChange{Field: field + " network", To: n.Network}
+	model := core.VMChange{Field: field + " model", To: n.Model}
+	var found, networkDiffers, modelDiffers bool
+
+	for _, view := range u.views() {
+		devices, err := u.devices(view)
+		if err != nil {
+			return err
+		}
+		var dev domainDevice
+		var nic DomainInterface
+		ok := false
+		for _, d := range devices {
+			if nic, ok = d.nic(n.MAC); ok {
+				dev = d
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		found = true
+
+		typ, replace := "", make(map[string]string)
+		if n.Network != "" && interfaceNetwork(nic) != n.Network {
+			networkDiffers = true
+			if network.From == "" {
+				network.From = interfaceNetwork(nic)
+			}
+			typ, replace["source"] = ifaceType, interfaceSourceXML(ifaceType, n.Network)
+		}
+		if n.Model != "" && nic.Model.Type != n.Model {
+			modelDiffers = true
+			if model.From == "" {
+				model.From = nic.Model.Type
+			}
+			if view == libvirt.DOMAIN_DEVICE_MODIFY_LIVE {
+				if !u.persistent {
+					return fmt.Errorf("the model of %s cannot be changed while the VM runs", field)
+				}
+			} else {
+				replace["model"] = fmt.Sprintf(`<model type="%s"/>`, n.Model)
+			}
+		}
+		if len(replace) == 0 {
+			continue
+		}
+
+		deviceXML, err := dev.edit(typ, replace)
+		if err != nil {
+			return err
+		}
+		if err := u.dom.UpdateDeviceFlags(deviceXML, view); err != nil {
+			return fmt.Errorf("failed to update %s: %w", field, err)
+		}
+		if _, ok := replace["source"]; ok {
+			mark(&network, view)
+		}
+		if _, ok := replace["model"]; ok {
+			mark(&model, view)
+		}
+	}
+	if !found {
+		return fmt.Errorf("VM has no %s", field)
+	}
+
+	for _, change := range []struct {
+		core.VMChange
+		requested, differs bool
+	}{{network, n.Network != "", networkDiffers}, {model, n.Model != "", modelDiffers}} {
+		if !change.requested {
+			continue
+		}
+		if !change.differs {
+			change.From = change.To
+			change.Note = "unchanged"
+		}
+		u.record(change.VMChange)
+	}
+	return nil
+}
+
+// changeCDROM inserts an ISO into a CD-ROM drive or ejects it. Media changes
+// reach a running VM at once.
+func (u *vmUpdate) changeCDROM(cd core.CDROMChange) error {
+	var source string
+	if !cd.Eject {
+		var err error
+		if source, err = u.c.resolveISO(cd.ISO); err != nil {
+			return err
+		}
+	}
+
+	change := core.VMChange{Field: "cdrom", To: orEmpty(source)}
+	found := false
+	for _, view := range u.views() {
+		devices, err := u.devices(view)
+		if err != nil {
+			return err
+		}
+		for _, d := range devices {
+			var disk DomainDisk
+			if !d.decode("disk", &disk) || disk.Device != "cdrom" || cd.Target != "" && disk.Target.Dev != cd.Target {
+				continue
+			}
+			found = true
+			change.Field = "cdrom " + disk.Target.Dev
+			if change.From == "" {
+				change.From = orEmpty(disk.Source.File)
+			}
+			if disk.Source.File == source {
+				break
+			}
+
+			typ, replace := "", map[string]string{"source": ""}
+			if source != "" {
+				typ, replace["source"] = "file", fmt.Sprintf(`<source file="%s"/>`, xmlEscape(source))
+			}
+			deviceXML, err := d.edit(typ, replace)
+			if err != nil {
+				return err
+			}
+			if err := u.dom.UpdateDeviceFlags(deviceXML, view); err != nil {
+				return fmt.Errorf("failed to change media of %s: %w", change.Field, err)
+			}
+			mark(&change, view)
+			break
+		}
+	}
+	if !found {
+		if cd.Target != "" {
+			return fmt.Errorf("VM has no CD-ROM drive %s", cd.Target)
+		}
+		return fmt.Errorf("VM has no CD-ROM drive")
+	}
+	if !change.Live && !change.Config {
+		change.Note = "unchanged"
+	}
+	u.record(change)
+	return nil
+}
+
+// setVCPUs sets the vCPU count of the definition, raising its maximum if need
+// be, and hotplugs or unplugs vCPUs of a running VM up to its maximum
+func (u *vmUpdate) setVCPUs(vcpus int) error {
+	change := core.VMChange{Field: "vcpus", To: strconv.Itoa(vcpus)}
+	if current, err := u.dom.GetVcpusFlags(libvirt.DOMAIN_VCPU_CURRENT); err == nil {
+		change.From = strconv.Itoa(int(current))
+	}
+
+	if u.persistent {
+		maxVCPUs, err := u.dom.GetVcpusFlags(libvirt.DOMAIN_VCPU_CONFIG | libvirt.DOMAIN_VCPU_MAXIMUM)
+		if err != nil {
+			return fmt.Errorf("failed to get maximum vCPUs: %w", err)
+		}
+		if vcpus > int(maxVCPUs) {
+			if err := u.dom.SetVcpusFlags(uint(vcpus), libvirt.DOMAIN_VCPU_CONFIG|libvirt.DOMAIN_VCPU_MAXIMUM); err != nil {
+				return fmt.Errorf("failed to set maximum vCPUs: %w", err)
+			}
+		}
+		if err := u.dom.SetVcpusFlags(uint(vcpus), libvirt.DOMAIN_VCPU_CONFIG); err != nil {
+			return fmt.Errorf("failed to set vCPUs: %w", err)
+		}
+		change.Config = true
+	}
+
+	if u.active {
+		maxVCPUs, err := u.dom.GetVcpusFlags(libvirt.DOMAIN_VCPU_LIVE | libvirt.DOMAIN_VCPU_MAXIMUM)
+		if err != nil {
+			return fmt.Errorf("failed to get maximum vCPUs: %w", err)
+		}
+		if vcpus > int(maxVCPUs) {
+			change.Note = fmt.Sprintf("the running VM can hotplug up to %d vCPUs", maxVCPUs)
+		} else if err := u.dom.SetVcpusFlags(uint(vcpus), libvirt.DOMAIN_VCPU_LIVE); err != nil {
+			change.Note = fmt.Sprintf("hotplug failed: %v", err)
+		} else {
+			change.Live = true
+		}
+		if !change.Live && !u.persistent {
+			return fmt.Errorf("failed to set vCPUs: %s", change.Note)
+		}
+	}
+	u.record(change)
+	return nil
+}
+
+// setMemory sets the memory of the definition, raising its maximum if need be,
+// and balloons a running VM's memory up to its maximum. The guest needs a
+// balloon driver for the live change.
+func (u *vmUpdate) setMemory(memoryMB uint64) error {
+	memoryKB := memoryMB * 1024
+	change := core.VMChange{Field: "memory", To: fmt.Sprintf("%d MB", memoryMB)}
+	info, err := u.dom.GetInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get domain info: %w", err)
+	}
+	change.From = fmt.Sprintf("%d MB", info.Memory/1024)
+
+	if u.persistent {
+		maxKB, err := u.configMaxMemoryKB()
+		if err != nil {
+			return err
+		}
+		if memoryKB > maxKB {
+			if err := u.dom.SetMemoryFlags(memoryKB, libvirt.DOMAIN_MEM_CONFIG|libvirt.DOMAIN_MEM_MAXIMUM); err != nil {
+				return fmt.Errorf("failed to set maximum memory: %w", err)
+			}
+		}
+		if err := u.dom.SetMemoryFlags(memoryKB, libvirt.DOMAIN_MEM_CONFIG); err != nil {
+			return fmt.Errorf("failed to set memory: %w", err)
+		}
+		change.Config = true
+	}
+
+	if u.active {
+		if memoryKB > info.MaxMem {
+			change.Note = fmt.Sprintf("the running VM can balloon up to %d MB", info.MaxMem/1024)
+		} else if err := u.dom.SetMemoryFlags(memoryKB, libvirt.DOMAIN_MEM_LIVE); err != nil {
+			change.Note = fmt.Sprintf("ballooning failed: %v", err)
+		} else {
+			change.Live = true
+		}
+		if !change.Live && !u.persistent {
+			return fmt.Errorf("failed to set memory: %s", change.Note)
+		}
+	}
+	u.record(change)
+	return nil
+}
+
+// configMaxMemoryKB reads the maximum memory of the persistent definition,
+// which for a running VM may differ from the live one
+func (u *vmUpdate) configMaxMemoryKB() (uint64, error) {
+	xmlDesc, err := u.dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get domain XML: %w", err)
+	}
+	var domain struct {
+		Memory uint64 `xml:"memory"` // Always in KiB in libvirt's output
+	}
+	if err := xml.Unmarshal([]byte(xmlDesc), &domain); err != nil {
+		return 0, fmt.Errorf("failed to parse domain XML: %w", err)
+	}
+	return domain.Memory, nil
+}
+
+// resolveISO returns the path of an ISO given by its name in the image library
+// or ISO pool, or as an absolute path
+func (c *Client) resolveISO(iso string) (string, error) {
+	if filepath.IsAbs(iso) {
+		return iso, nil
+	}
+	images, err := c.GetImages()
+	if err != nil {
+		return "", fmt.Errorf("failed to get images: %w", err)
+	}
+	if isos, err := c.GetISOs(); err == nil {
+		images = append(images, isos...)
+	}
+	for _, img := range images {
+		if img.Name == iso {
+			return img.Path, nil
+		}
+	}
+	return "", fmt.Errorf("ISO '%s' not found in managed library", iso)
+}
+
+// domainDevice is one element of a domain's <devices>, kept as XML so it can be
+// handed back to libvirt to update or detach the device
+type domainDevice struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+func parseDomainDevices(xmlDesc string) ([]domainDevice, error) {
+	var domain struct {
+		Devices struct {
+			Items []domainDevice `xml:",any"`
+		} `xml:"devices"`
+	}
+	if err := xml.Unmarshal([]byte(xmlDesc), &domain); err != nil {
+		return nil, fmt.Errorf("failed to parse domain XML: %w", err)
+	}
+	return domain.Devices.Items, nil
+}
+
+func (d domainDevice) String() string {
+	data, _ := xml.Marshal(d)
+	return string(data)
+}
+
+// decode unmarshals the device into v if it is a <kind> element
+func (d domainDevice) decode(kind string, v any) bool {
+	return d.XMLName.Local == kind && xml.Unmarshal([]byte(d.String()), v) == nil
+}
+
+// nic returns the device as an interface if it has the given MAC address
+func (d domainDevice) nic(mac string) (DomainInterface, bool) {
+	var nic DomainInterface
+	if !d.decode("interface", &nic) || nic.MAC == nil || !strings.EqualFold(nic.MAC.Address, mac) {
+		return DomainInterface{}, false
+	}
+	return nic, true
+}
+
+// edit returns the device's XML with its type attribute set to typ, unless
+// empty, and its direct children named in replace swapped for the given XML.
+// An empty replacement drops the child; one for a child the device lacks is
+// added at the end.
+func (d domainDevice) edit(typ string, replace map[string]string) (string, error) {
+	var inner strings.Builder
+	dec := xml.NewDecoder(strings.NewReader(d.Inner))
+	seen := make(map[string]bool)
+	var last int64
+	for {
+		start := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s XML: %w", d.XMLName.Local, err)
+		}
+		elem, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		// Skip the whole child, so only direct children are ever seen here
+		if err := dec.Skip(); err != nil {
+			return "", fmt.Errorf("failed to parse %s XML: %w", d.XMLName.Local, err)
+		}
+		repl, ok := replace[elem.Name.Local]
+		if !ok {
+			continue
+		}
+		inner.WriteString(d.Inner[last:start])
+		inner.WriteString(repl)
+		last = dec.InputOffset()
+		seen[elem.Name.Local] = true
+	}
+	inner.WriteString(d.Inner[last:])
+
+	names := make([]string, 0, len(replace))
+	for name := range replace {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !seen[name] && replace[name] != "" {
+			inner.WriteString(replace[name])
+		}
+	}
+
+	edited := domainDevice{XMLName: d.XMLName, Inner: inner.String()}
+	for _, attr := range d.Attrs {
+		if attr.Name.Local == "type" && typ != "" {
+			attr.Value = typ
+		}
+		edited.Attrs = append(edited.Attrs, attr)
+	}
+	return edited.String(), nil
+}
+
+// interfaceNetwork is the network, bridge or host interface a NIC is on
+func interfaceNetwork(nic DomainInterface) string {
+	switch nic.Type {
+	case "bridge":
+		return nic.Source.Bridge
+	case "direct":
+		return nic.Source.Dev
+	}
+	return nic.Source.Network
+}
+
+// interfaceSourceXML is the <source> of an interface of the given type, as
+// AttachNetworkInterfaceToVM builds it
+func interfaceSourceXML(ifaceType, network string) string {
+	switch ifaceType {
+	case "bridge":
+		return fmt.Sprintf(`<source bridge="%s"/>`, xmlEscape(network))
+	case "direct":
+		return fmt.Sprintf(`<source dev="%s" mode="bridge"/>`, xmlEscape(network))
+	}
+	return fmt.Sprintf(`<source network="%s"/>`, xmlEscape(network))
+}
+
+func diskSource(disk DomainDisk) string {
+	if disk.Source.Pool != "" {
+		return disk.Source.Pool + "/" + disk.Source.Volume
+	}
+	return disk.Source.File
+}
+
+func formatGB(bytes uint64) string {
+	if bytes%(1<<30) == 0 {
+		return fmt.Sprintf("%d GB", bytes>>30)
+	}
+	return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+}
+
+func orEmpty(s string) string {
+	if s == "" {
+		return "(empty)"
+	}
+	return s
+}
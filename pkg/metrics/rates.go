@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// Rates converts two consecutive counter readings of the same VM into a point.
+// It returns false when no rate can be computed, e.g. for the first reading or
+// after the domain restarted and its counters went backwards.
+func Rates(prev, cur core.VMCounters) (core.VMMetricsPoint, bool) {
+	elapsed := float64(cur.Timestamp-prev.Timestamp) / float64(time.Second)
+	if elapsed <= 0 || cur.CPUTimeNs < prev.CPUTimeNs {
+		return core.VMMetricsPoint{}, false
+	}
+
+	point := core.VMMetricsPoint{
+		Timestamp:     time.Unix(0, cur.Timestamp).Unix(),
+		MemoryUsedKB:  cur.MemoryUsedKB,
+		MemoryTotalKB: cur.MemoryTotalKB,
+	}
+
+	if cur.VCPUs > 0 {
+		cpuSecs := float64(cur.CPUTimeNs-prev.CPUTimeNs) / float64(time.Second)
+		point.CPUPercent = cpuSecs / elapsed / float64(cur.VCPUs) * 100
+		if point.CPUPercent > 100 {
+			point.CPUPercent = 100
+		}
+	}
+
+	prevDisks := make(map[string]core.DiskCounters, len(prev.Disks))
+	for _, d := range prev.Disks {
+		prevDisks[d.Device] = d
+	}
+	for _, d := range cur.Disks {
+		p, ok := prevDisks[d.Device]
+		if !ok {
+			continue // Hot-plugged since the last reading
+		}
+		rates := core.DiskRates{
+			Device:    d.Device,
+			ReadBps:   delta(p.ReadBytes, d.ReadBytes, elapsed),
+			WriteBps:  delta(p.WriteBytes, d.WriteBytes, elapsed),
+			ReadIOPS:  delta(p.ReadReqs, d.ReadReqs, elapsed),
+			WriteIOPS: delta(p.WriteReqs, d.WriteReqs, elapsed),
+		}
+		point.DiskReadBps += rates.ReadBps
+		point.DiskWriteBps += rates.WriteBps
+		point.Disks = append(point.Disks, rates)
+	}
+
+	prevIfaces := make(map[string]core.InterfaceCounters, len(prev.Interfaces))
+	for _, i := range prev.Interfaces {
+		prevIfaces[i.Device] = i
+	}
+	for _, i := range cur.Interfaces {
+		p, ok := prevIfaces[i.Device]
+		if !ok || p.MAC != i.MAC {
+			continue // Device name reused by a different interface
+		}
+		rates := core.NetworkRates{
+			Device: i.Device,
+			MAC:    i.MAC,
+			RxBps:  delta(p.RxBytes, i.RxBytes, elapsed),
+			TxBps:  delta(p.TxBytes, i.TxBytes, elapsed),
+			RxPps:  delta(p.RxPackets, i.RxPackets, elapsed),
+			TxPps:  delta(p.TxPackets, i.TxPackets, elapsed),
+		}
+		point.NetRxBps += rates.RxBps
+		point.NetTxBps += rates.TxBps
+		point.Interfaces = append(point.Interfaces, rates)
+	}
+
+	return point, true
+}
+
+// delta returns the per-second rate between two counter values, treating a reset as zero
+func delta(prev, cur uint64, elapsed float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / elapsed
+}
+
+// Downsample averages points into step-sized buckets aligned to the Unix epoch.
+// Each bucket is stamped with its start time.
+func Downsample(points []core.VMMetricsPoint, step time.Duration) []core.VMMetricsPoint {
+	stepSecs := int64(step / time.Second)
+	if stepSecs <= 1 || len(points) == 0 {
+		return points
+	}
+
+	var out []core.VMMetricsPoint
+	var bucket []core.VMMetricsPoint
+	flush := func() {
+		if len(bucket) > 0 {
+			out = append(out, average(bucket, bucket[0].Timestamp-bucket[0].Timestamp%stepSecs))
+			bucket = bucket[:0]
+		}
+	}
+
+	for _, p := range points {
+		if len(bucket) > 0 && p.Timestamp/stepSecs != bucket[0].Timestamp/stepSecs {
+			flush()
+		}
+		bucket = append(bucket, p)
+	}
+	flush()
+
+	return out
+}
+
+// average combines points into one, averaging every rate per device
+func average(points []core.VMMetricsPoint, timestamp int64) core.VMMetricsPoint {
+	n := float64(len(points))
+	out := core.VMMetricsPoint{Timestamp: timestamp}
+
+	var memUsed, memTotal float64
+	disks := map[string]*core.DiskRates{}
+	var diskOrder []string
+	ifaces := map[string]*core.NetworkRates{}
+	var ifaceOrder []string
+
+	for _, p := range points {
+		out.CPUPercent += p.CPUPercent / n
+		out.DiskReadBps += p.DiskReadBps / n
+		out.DiskWriteBps += p.DiskWriteBps / n
+		out.NetRxBps += p.NetRxBps / n
+		out.NetTxBps += p.NetTxBps / n
+		memUsed += float64(p.MemoryUsedKB) / n
+		memTotal += float64(p.MemoryTotalKB) / n
+
+		// A device missing from some samples counts as idle for them
+		for _, d := range p.Disks {
+			acc, ok := disks[d.Device]
+			if !ok {
+				acc = &core.DiskRates{Device: d.Device}
+				disks[d.Device] = acc
+				diskOrder = append(diskOrder, d.Device)
+			}
+			acc.ReadBps += d.ReadBps / n
+			acc.WriteBps += d.WriteBps / n
+			acc.ReadIOPS += d.ReadIOPS / n
+			acc.WriteIOPS += d.WriteIOPS / n
+		}
+		for _, i := range p.Interfaces {
+			acc, ok := ifaces[i.Device]
+			if !ok {
+				acc = &core.NetworkRates{Device: i.Device, MAC: i.MAC}
+				ifaces[i.Device] = acc
+				ifaceOrder = append(ifaceOrder, i.Device)
+			}
+			acc.RxBps += i.RxBps / n
+			acc.TxBps += i.TxBps / n
+			acc.RxPps += i.RxPps / n
+			acc.TxPps += i.TxPps / n
+		}
+	}
+
+	out.MemoryUsedKB = uint64(memUsed)
+	out.MemoryTotalKB = uint64(memTotal)
+	for _, dev := range diskOrder {
+		out.Disks = append(out.Disks, *disks[dev])
+	}
+	for _, dev := range ifaceOrder {
+		out.Interfaces = append(out.Interfaces, *ifaces[dev])
+	}
+	return out
+}
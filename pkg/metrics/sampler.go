@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// DefaultInterval is how often domains are sampled unless configured otherwise
+const DefaultInterval = 30 * time.Second
+
+// Source is a connection whose running domains can be sampled (libvirtclient.ClientInterface)
+type Source interface {
+	GetDomainCounters() ([]core.VMCounters, error)
+}
+
+// SourceFunc returns the connections to sample, keyed by server ID ("" for the local connection)
+type SourceFunc func() map[string]Source
+
+// Sampler periodically reads the counters of every running domain on every
+// source and appends the resulting rates to a Store
+type Sampler struct {
+	store    *Store
+	interval time.Duration
+	sources  SourceFunc
+
+	prev     map[string]core.VMCounters // server ID + "/" + UUID -> last reading
+	failures map[string]string          // server ID -> last error, so failing hosts are logged once
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewSampler creates a sampler. Call Start to begin sampling.
+func NewSampler(store *Store, interval time.Duration, sources SourceFunc) *Sampler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Sampler{
+		store:    store,
+		interval: interval,
+		sources:  sources,
+		prev:     make(map[string]core.VMCounters),
+		failures: make(map[string]string),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start samples in the background until Stop is called
+func (s *Sampler) Start() {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.Sample()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.Sample()
+			}
+		}
+	}()
+}
+
+// Stop ends background sampling and waits for an in-progress sample to finish
+func (s *Sampler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+// Sample takes one reading from every source and records a point for each
+// domain that was also seen in the previous reading
+func (s *Sampler) Sample() {
+	sources := s.sources()
+
+	type reading struct {
+		serverID string
+		counters []core.VMCounters
+	}
+	readings := make([]reading, 0, len(sources))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	// Hosts are read concurrently so one slow server does not delay the others
+	for serverID, source := range sources {
+		wg.Add(1)
+		go func(serverID string, source Source) {
+			defer wg.Done()
+			counters, err := source.GetDomainCounters()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if s.failures[serverID] != err.Error() {
+					s.failures[serverID] = err.Error()
+					logger.Warn("Failed to sample domain metrics", map[string]interface{}{
+						"server_id": serverID,
+						"error":     err.Error(),
+					})
+				}
+				return
+			}
+			delete(s.failures, serverID)
+			readings = append(readings, reading{serverID: serverID, counters: counters})
+		}(serverID, source)
+	}
+	wg.Wait()
+
+	// The local connection and a registered server may be the same host; keep one
+	// series per VM by letting the first server (in ID order, local first) win
+	sort.Slice(readings, func(i, j int) bool {
+		return readings[i].serverID < readings[j].serverID
+	})
+
+	seen := make(map[string]bool)
+	current := make(map[string]core.VMCounters)
+	for _, r := range readings {
+		for _, c := range r.counters {
+			if !c.Active || seen[c.UUID] {
+				continue
+			}
+			seen[c.UUID] = true
+
+			key := r.serverID + "/" + c.UUID
+			current[key] = c
+
+			prev, ok := s.prev[key]
+			if !ok {
+				continue
+			}
+			point, ok := Rates(prev, c)
+			if !ok {
+				continue
+			}
+			if err := s.store.Append(c.UUID, point); err != nil {
+				logger.Warn("Failed to store domain metrics", map[string]interface{}{
+					"uuid":  c.UUID,
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+
+	// Domains that stopped are forgotten so a restart does not produce a bogus rate
+	s.prev = current
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+type fakeSource struct {
+	readings [][]core.VMCounters
+	calls    int
+}
+
+func (f *fakeSource) GetDomainCounters() ([]core.VMCounters, error) {
+	r := f.readings[f.calls]
+	f.calls++
+	return r, nil
+}
+
+func TestSampler_RecordsRates(t *testing.T) {
+	store, err := NewStore(t.TempDir(), Retention{})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	start := time.Now().Add(-time.Minute)
+	first := core.VMCounters{
+		UUID:       testUUID,
+		Active:     true,
+		Timestamp:  start.UnixNano(),
+		VCPUs:      2,
+		CPUTimeNs:  0,
+		Disks:      []core.DiskCounters{{Device: "vda", ReadBytes: 1000, WriteReqs: 10}},
+		Interfaces: []core.InterfaceCounters{{Device: "vnet0", MAC: "52:54:00:00:00:01", RxBytes: 0}},
+	}
+	second := core.VMCounters{
+		UUID:      testUUID,
+		Active:    true,
+		Timestamp: start.Add(10 * time.Second).UnixNano(),
+		VCPUs:     2,
+		CPUTimeNs: uint64(5 * time.Second),
+		Disks: []core.DiskCounters{
+			{Device: "vda", ReadBytes: 11000, WriteReqs: 60},
+			{Device: "vdb", ReadBytes: 500}, // Hot-plugged, no rate yet
+		},
+		Interfaces: []core.InterfaceCounters{{Device: "vnet0", MAC: "52:54:00:00:00:01", RxBytes: 2000}},
+	}
+
+	// The same VM reported by a registered server pointing at the local host is ignored
+	local := &fakeSource{readings: [][]core.VMCounters{{first}, {second}}}
+	duplicate := &fakeSource{readings: [][]core.VMCounters{{first}, {second}}}
+	sampler := NewSampler(store, time.Second, func() map[string]Source {
+		return map[string]Source{"": local, "srv-1": duplicate}
+	})
+
+	sampler.Sample()
+	sampler.Sample()
+
+	points := store.Query(testUUID, time.Time{}, time.Time{}, 0)
+	if len(points) != 1 {
+		t.Fatalf("got %d points, want 1", len(points))
+	}
+	p := points[0]
+	if p.CPUPercent != 25 {
+		t.Errorf("CPUPercent = %v, want 25", p.CPUPercent)
+	}
+	if p.DiskReadBps != 1000 || len(p.Disks) != 1 || p.Disks[0].WriteIOPS != 5 {
+		t.Errorf("disk rates = %+v (total read %v), want vda at 1000 B/s and 5 write IOPS", p.Disks, p.DiskReadBps)
+	}
+	if p.NetRxBps != 200 {
+		t.Errorf("NetRxBps = %v, want 200", p.NetRxBps)
+	}
+
+	// A restarted domain's counters go backwards; no rate is produced
+	if _, ok := Rates(second, first); ok {
+		t.Error("Rates() accepted counters that went backwards")
+	}
+}
@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// DefaultStoreDir is where per-VM metrics are kept unless configured otherwise
+const DefaultStoreDir = "/var/lib/flint/metrics"
+
+// pruneInterval is how often Append checks whether points have aged out
+const pruneInterval = time.Hour
+
+// seriesExt is the file extension of a VM's time series
+const seriesExt = ".jsonl"
+
+// Retention bounds how much history is kept per VM. Zero values disable a limit.
+type Retention struct {
+	MaxAge    time.Duration
+	MaxPoints int
+}
+
+// Store keeps a bounded time series per VM, one JSON-lines file per UUID, with
+// the points mirrored in memory for querying
+type Store struct {
+	mu        sync.RWMutex
+	dir       string
+	retention Retention
+	series    map[string][]core.VMMetricsPoint // UUID -> points, oldest first
+	lastPrune time.Time
+}
+
+// NewStore opens (or creates) the metrics directory, loads existing series and applies retention
+func NewStore(dir string, retention Retention) (*Store, error) {
+	if dir == "" {
+		dir = DefaultStoreDir
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+
+	s := &Store{
+		dir:       dir,
+		retention: retention,
+		series:    make(map[string][]core.VMMetricsPoint),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.pruneLocked(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Append adds a point to a VM's series
+func (s *Store) Append(uuid string, point core.VMMetricsPoint) error {
+	uuid = strings.ToLower(uuid)
+	data, err := json.Marshal(point)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics point: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.seriesPath(uuid), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open metrics series: %w", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write metrics point: %w", err)
+	}
+
+	points := append(s.series[uuid], point)
+	s.series[uuid] = points
+
+	max := s.retention.MaxPoints
+	if max > 0 && len(points) > max+max/10 {
+		s.series[uuid] = append([]core.VMMetricsPoint(nil), points[len(points)-max:]...)
+		if err := s.rewriteLocked(uuid); err != nil {
+			return err
+		}
+	}
+
+	if time.Since(s.lastPrune) > pruneInterval {
+		return s.pruneLocked()
+	}
+	return nil
+}
+
+// Query returns a VM's points between from and to (inclusive; zero times are
+// unbounded), averaged into step-sized buckets when step is positive
+func (s *Store) Query(uuid string, from, to time.Time, step time.Duration) []core.VMMetricsPoint {
+	s.mu.RLock()
+	points := s.series[strings.ToLower(uuid)]
+	var matched []core.VMMetricsPoint
+	for _, p := range points {
+		if !from.IsZero() && p.Timestamp < from.Unix() {
+			continue
+		}
+		if !to.IsZero() && p.Timestamp > to.Unix() {
+			break
+		}
+		matched = append(matched, p)
+	}
+	s.mu.RUnlock()
+
+	if step > 0 {
+		matched = Downsample(matched, step)
+	}
+	if matched == nil {
+		matched = []core.VMMetricsPoint{}
+	}
+	return matched
+}
+
+// seriesPath returns the file holding a VM's series
+func (s *Store) seriesPath(uuid string) string {
+	return filepath.Join(s.dir, uuid+seriesExt)
+}
+
+// load reads every series file, skipping lines that cannot be parsed (e.g. a
+// partial write from a crash)
+func (s *Store) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read metrics directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), seriesExt) {
+			continue
+		}
+		uuid := strings.TrimSuffix(entry.Name(), seriesExt)
+
+		file, err := os.Open(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to open metrics series: %w", err)
+		}
+
+		var points []core.VMMetricsPoint
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var p core.VMMetricsPoint
+			if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+				continue
+			}
+			points = append(points, p)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read metrics series %s: %w", uuid, err)
+		}
+
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].Timestamp < points[j].Timestamp
+		})
+		s.series[uuid] = points
+	}
+
+	return nil
+}
+
+// pruneLocked drops points outside the retention window, rewriting files that
+// changed and removing series with nothing left (e.g. deleted VMs). Callers
+// must hold s.mu or own the store.
+func (s *Store) pruneLocked() error {
+	s.lastPrune = time.Now()
+
+	var cutoff int64
+	if s.retention.MaxAge > 0 {
+		cutoff = time.Now().Add(-s.retention.MaxAge).Unix()
+	}
+
+	for uuid, points := range s.series {
+		keepFrom := 0
+		for keepFrom < len(points) && points[keepFrom].Timestamp < cutoff {
+			keepFrom++
+		}
+		if max := s.retention.MaxPoints; max > 0 && len(points)-keepFrom > max {
+			keepFrom = len(points) - max
+		}
+		if keepFrom == 0 {
+			continue
+		}
+
+		if keepFrom == len(points) {
+			delete(s.series, uuid)
+			if err := os.Remove(s.seriesPath(uuid)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove metrics series: %w", err)
+			}
+			continue
+		}
+
+		s.series[uuid] = append([]core.VMMetricsPoint(nil), points[keepFrom:]...)
+		if err := s.rewriteLocked(uuid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rewriteLocked atomically replaces a series file with the in-memory points
+func (s *Store) rewriteLocked(uuid string) error {
+	path := s.seriesPath(uuid)
+	tmpPath := path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to compact metrics series: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, p := range s.series[uuid] {
+		if err := encoder.Encode(p); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to compact metrics series: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact metrics series: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact metrics series: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to compact metrics series: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+const testUUID = "6f1f9a6e-7a43-4c39-9a7c-3c1c2b4b8f10"
+
+func TestStore_PersistsAndQueries(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, Retention{})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	base := time.Now().Add(-10 * time.Minute).Unix()
+	base -= base % 60
+	for i := int64(0); i < 4; i++ {
+		point := core.VMMetricsPoint{
+			Timestamp:  base + i*30,
+			CPUPercent: float64(i * 10),
+			Disks:      []core.DiskRates{{Device: "vda", ReadBps: float64(i * 100)}},
+		}
+		if err := store.Append(testUUID, point); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	reopened, err := NewStore(dir, Retention{})
+	if err != nil {
+		t.Fatalf("NewStore() reopen error = %v", err)
+	}
+
+	points := reopened.Query(testUUID, time.Time{}, time.Time{}, 0)
+	if len(points) != 4 {
+		t.Fatalf("Query() returned %d points after reopen, want 4", len(points))
+	}
+
+	points = reopened.Query(testUUID, time.Unix(base+30, 0), time.Unix(base+60, 0), 0)
+	if len(points) != 2 || points[0].CPUPercent != 10 {
+		t.Errorf("Query() with range = %+v, want points at +30s and +60s", points)
+	}
+
+	points = reopened.Query(testUUID, time.Time{}, time.Time{}, time.Minute)
+	if len(points) != 2 {
+		t.Fatalf("Query() with 1m step returned %d points, want 2", len(points))
+	}
+	if points[0].Timestamp != base || points[0].CPUPercent != 5 || points[0].Disks[0].ReadBps != 50 {
+		t.Errorf("first bucket = %+v, want averages of the first two points", points[0])
+	}
+
+	if got := reopened.Query("00000000-0000-0000-0000-000000000000", time.Time{}, time.Time{}, 0); len(got) != 0 {
+		t.Errorf("Query() for unknown VM returned %d points", len(got))
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, Retention{MaxAge: time.Hour, MaxPoints: 10})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	now := time.Now().Unix()
+	if err := store.Append("old-vm", core.VMMetricsPoint{Timestamp: now - 7200}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	for i := int64(0); i < 20; i++ {
+		if err := store.Append(testUUID, core.VMMetricsPoint{Timestamp: now - 20 + i}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	// Reopening prunes by age and count
+	reopened, err := NewStore(dir, Retention{MaxAge: time.Hour, MaxPoints: 10})
+	if err != nil {
+		t.Fatalf("NewStore() reopen error = %v", err)
+	}
+	if got := reopened.Query("old-vm", time.Time{}, time.Time{}, 0); len(got) != 0 {
+		t.Errorf("expired series still has %d points", len(got))
+	}
+	points := reopened.Query(testUUID, time.Time{}, time.Time{}, 0)
+	if len(points) != 10 || points[0].Timestamp != now-10 {
+		t.Errorf("retained %d points starting at %d, want 10 starting at %d", len(points), points[0].Timestamp, now-10)
+	}
+}
@@ -0,0 +1,249 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a parsed kubectl-style JSONPath template such as
+// '{range .items[*]}{.name}{"\t"}{.state}{"\n"}{end}'. It supports plain text,
+// field access (.a.b), indexes ([0], [-1]), wildcards ([*], .*), string
+// literals, and range/end blocks.
+type JSONPath struct {
+	nodes []jsonPathNode
+}
+
+// jsonPathNode is plain text or a string literal, a field expression, or a
+// range block with its body
+type jsonPathNode struct {
+	text    string
+	path    []pathStep
+	isPath  bool
+	rangeOf []pathStep
+	body    []jsonPathNode
+}
+
+type pathStep struct {
+	field    string
+	index    int
+	wildcard bool
+	isIndex  bool
+}
+
+// ParseJSONPath parses a JSONPath template
+func ParseJSONPath(tmpl string) (*JSONPath, error) {
+	p := &jsonPathParser{input: tmpl}
+	nodes, end, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		return nil, fmt.Errorf("invalid JSONPath %q: {end} without {range}", tmpl)
+	}
+	return &JSONPath{nodes: nodes}, nil
+}
+
+// Execute writes the template for data, which must be in its JSON form. An
+// expression with several results prints them separated by spaces.
+func (j *JSONPath) Execute(w io.Writer, data interface{}) error {
+	var b strings.Builder
+	executeNodes(&b, j.nodes, data)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func executeNodes(b *strings.Builder, nodes []jsonPathNode, data interface{}) {
+	for _, node := range nodes {
+		switch {
+		case node.rangeOf != nil:
+			items := evalPath(data, node.rangeOf)
+			if len(items) == 1 {
+				if list, ok := items[0].([]interface{}); ok {
+					items = list
+				}
+			}
+			for _, item := range items {
+				executeNodes(b, node.body, item)
+			}
+		case node.isPath:
+			for i, v := range evalPath(data, node.path) {
+				if i > 0 {
+					b.WriteByte(' ')
+				}
+				b.WriteString(scalarString(v))
+			}
+		default:
+			b.WriteString(node.text)
+		}
+	}
+}
+
+// evalPath returns every value the steps reach from data. Missing fields and
+// indexes reach nothing rather than failing, like in kubectl, so that one item
+// without a field does not spoil the output for the others.
+func evalPath(data interface{}, steps []pathStep) []interface{} {
+	current := []interface{}{data}
+	for _, step := range steps {
+		var next []interface{}
+		for _, v := range current {
+			switch {
+			case step.wildcard:
+				switch v := v.(type) {
+				case []interface{}:
+					next = append(next, v...)
+				case map[string]interface{}:
+					for _, item := range v {
+						next = append(next, item)
+					}
+				}
+			case step.isIndex:
+				list, _ := v.([]interface{})
+				i := step.index
+				if i < 0 {
+					i += len(list)
+				}
+				if i >= 0 && i < len(list) {
+					next = append(next, list[i])
+				}
+			default:
+				if value, ok := lookup(v, []string{step.field}); ok {
+					next = append(next, value)
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+type jsonPathParser struct {
+	input string
+	pos   int
+}
+
+// parse reads nodes up to the end of the input or an {end}, which it reports
+func (p *jsonPathParser) parse() ([]jsonPathNode, bool, error) {
+	var nodes []jsonPathNode
+	for p.pos < len(p.input) {
+		open := strings.IndexByte(p.input[p.pos:], '{')
+		if open < 0 {
+			nodes = append(nodes, jsonPathNode{text: p.input[p.pos:]})
+			p.pos = len(p.input)
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, jsonPathNode{text: p.input[p.pos : p.pos+open]})
+		}
+		p.pos += open + 1
+
+		expr, err := p.readExpression()
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case expr == "end":
+			return nodes, true, nil
+		case strings.HasPrefix(expr, "range "):
+			steps, err := parsePath(strings.TrimSpace(strings.TrimPrefix(expr, "range ")))
+			if err != nil {
+				return nil, false, err
+			}
+			body, end, err := p.parse()
+			if err != nil {
+				return nil, false, err
+			}
+			if !end {
+				return nil, false, fmt.Errorf("invalid JSONPath %q: {range} without {end}", p.input)
+			}
+			if steps == nil {
+				steps = []pathStep{}
+			}
+			nodes = append(nodes, jsonPathNode{rangeOf: steps, body: body})
+		case strings.HasPrefix(expr, `"`):
+			text, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid string %s in JSONPath: %w", expr, err)
+			}
+			nodes = append(nodes, jsonPathNode{text: text})
+		default:
+			steps, err := parsePath(expr)
+			if err != nil {
+				return nil, false, err
+			}
+			nodes = append(nodes, jsonPathNode{path: steps, isPath: true})
+		}
+	}
+	return nodes, false, nil
+}
+
+// readExpression reads up to the closing brace, skipping braces in strings
+func (p *jsonPathParser) readExpression() (string, error) {
+	start := p.pos
+	inString := false
+	for ; p.pos < len(p.input); p.pos++ {
+		switch c := p.input[p.pos]; {
+		case inString && c == '\\':
+			p.pos++
+		case c == '"':
+			inString = !inString
+		case c == '}' && !inString:
+			expr := strings.TrimSpace(p.input[start:p.pos])
+			p.pos++
+			return expr, nil
+		}
+	}
+	return "", fmt.Errorf("invalid JSONPath %q: unclosed {", p.input)
+}
+
+// parsePath parses expressions like .items[*].name, $.name or @
+func parsePath(expr string) ([]pathStep, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(expr, "$"), "@")
+	var steps []pathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			field := rest[:end]
+			rest = rest[end:]
+			switch field {
+			case "":
+				if rest != "" && rest[0] != '[' {
+					return nil, fmt.Errorf("invalid JSONPath expression %q: empty field", expr)
+				}
+			case "*":
+				steps = append(steps, pathStep{wildcard: true})
+			default:
+				steps = append(steps, pathStep{field: field})
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath expression %q: unclosed [", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if inner == "*" {
+				steps = append(steps, pathStep{wildcard: true})
+				continue
+			}
+			if quoted, err := strconv.Unquote(strings.ReplaceAll(inner, "'", `"`)); err == nil {
+				steps = append(steps, pathStep{field: quoted})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath expression %q: unsupported [%s]", expr, inner)
+			}
+			steps = append(steps, pathStep{index: index, isIndex: true})
+		default:
+			return nil, fmt.Errorf("invalid JSONPath expression %q: fields start with a dot", expr)
+		}
+	}
+	return steps, nil
+}
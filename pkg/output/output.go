@@ -0,0 +1,357 @@
+// Package output prints CLI results as tables or in machine-readable formats,
+// after filtering them with a selector and sorting them.
+//
+// Selectors, --sort-by, templates and JSONPath all work on an item's JSON form,
+// so they use the same field names as the REST API.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats are the values -o accepts besides the template forms
+var Formats = []string{"table", "wide", "json", "yaml", "name"}
+
+// Options are the output flags of a command
+type Options struct {
+	// Format is table, wide, json, yaml, name, go-template=TEMPLATE,
+	// go-template-file=FILE, jsonpath=EXPR or jsonpath-file=FILE
+	Format    string
+	Selector  string
+	SortBy    string
+	NoHeaders bool
+}
+
+// Column is a table column. Wide columns only appear with -o wide.
+type Column[T any] struct {
+	Header string
+	Wide   bool
+	Value  func(T) string
+}
+
+// Table describes how a list is shown with -o table, wide and name
+type Table[T any] struct {
+	Columns []Column[T]
+	// Name returns what -o name prints; items' "name" field by default
+	Name func(T) string
+}
+
+// IsTable reports whether the options ask for human-readable output
+func (o Options) IsTable() bool {
+	return o.Format == "" || o.Format == "table" || o.Format == "wide"
+}
+
+// Validate checks the format, selector and sort key without printing anything
+func (o Options) Validate() error {
+	if _, err := ParseSelector(o.Selector); err != nil {
+		return err
+	}
+	if _, err := o.renderer(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// renderer returns the template or JSONPath printer for the format, nil for the
+// fixed formats
+func (o Options) renderer() (func(io.Writer, interface{}) error, error) {
+	kind, arg, _ := strings.Cut(o.Format, "=")
+	switch kind {
+	case "", "table", "wide", "json", "yaml", "name":
+		if arg != "" {
+			return nil, fmt.Errorf("output format %s takes no argument", kind)
+		}
+		return nil, nil
+	case "go-template-file", "jsonpath-file":
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		arg = string(data)
+		kind = strings.TrimSuffix(kind, "-file")
+	case "go-template", "jsonpath":
+	default:
+		return nil, fmt.Errorf("unknown output format %q (use %s, go-template=..., or jsonpath=...)", o.Format, strings.Join(Formats, ", "))
+	}
+	if arg == "" {
+		return nil, fmt.Errorf("output format %s needs a template, e.g. %s='...'", kind, kind)
+	}
+
+	if kind == "jsonpath" {
+		path, err := ParseJSONPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		return path.Execute, nil
+	}
+	tmpl, err := template.New("output").Parse(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return func(w io.Writer, data interface{}) error {
+		return tmpl.Execute(w, data)
+	}, nil
+}
+
+// PrintList filters, sorts and prints items. Templates and JSONPath see the list
+// as {"items": [...]}, like kubectl.
+func PrintList[T any](w io.Writer, items []T, table Table[T], opts Options) error {
+	selector, err := ParseSelector(opts.Selector)
+	if err != nil {
+		return err
+	}
+	render, err := opts.renderer()
+	if err != nil {
+		return err
+	}
+
+	objects := make([]interface{}, len(items))
+	for i, item := range items {
+		if objects[i], err = toGeneric(item); err != nil {
+			return err
+		}
+	}
+
+	var keep []int
+	for i, obj := range objects {
+		if selector.Matches(obj) {
+			keep = append(keep, i)
+		}
+	}
+	if opts.SortBy != "" {
+		if err := sortIndices(keep, objects, opts.SortBy); err != nil {
+			return err
+		}
+	}
+
+	selected := make([]T, 0, len(keep))
+	generic := make([]interface{}, 0, len(keep))
+	for _, i := range keep {
+		selected = append(selected, items[i])
+		generic = append(generic, objects[i])
+	}
+
+	if render != nil {
+		return render(w, map[string]interface{}{"items": generic})
+	}
+	switch opts.Format {
+	case "json":
+		return writeJSON(w, selected)
+	case "yaml":
+		return writeYAML(w, generic)
+	case "name":
+		for i, item := range selected {
+			name := ""
+			if table.Name != nil {
+				name = table.Name(item)
+			} else {
+				name = fieldString(generic[i], "name")
+			}
+			if _, err := fmt.Fprintln(w, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return writeTable(w, selected, table.Columns, opts.Format == "wide", opts.NoHeaders)
+}
+
+// PrintObject prints a single object in a machine-readable format. It returns
+// false for table and wide, which the caller shows in its own layout.
+func PrintObject(w io.Writer, v interface{}, opts Options) (bool, error) {
+	if opts.IsTable() {
+		return false, nil
+	}
+	render, err := opts.renderer()
+	if err != nil {
+		return true, err
+	}
+
+	obj, err := toGeneric(v)
+	if err != nil {
+		return true, err
+	}
+	if render != nil {
+		return true, render(w, obj)
+	}
+	switch opts.Format {
+	case "json":
+		return true, writeJSON(w, v)
+	case "yaml":
+		return true, writeYAML(w, obj)
+	}
+	_, err = fmt.Fprintln(w, fieldString(obj, "name"))
+	return true, err
+}
+
+func writeTable[T any](w io.Writer, items []T, columns []Column[T], wide, noHeaders bool) error {
+	var shown []Column[T]
+	for _, col := range columns {
+		if wide || !col.Wide {
+			shown = append(shown, col)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if !noHeaders {
+		headers := make([]string, len(shown))
+		rules := make([]string, len(shown))
+		for i, col := range shown {
+			headers[i] = col.Header
+			rules[i] = strings.Repeat("-", len(col.Header))
+		}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		fmt.Fprintln(tw, strings.Join(rules, "\t"))
+	}
+	for _, item := range items {
+		cells := make([]string, len(shown))
+		for i, col := range shown {
+			cells[i] = col.Value(item)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func writeYAML(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlNumbers(v)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// yamlNumbers replaces json.Number, which YAML would quote, with int64 or float64
+func yamlNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = yamlNumbers(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = yamlNumbers(item)
+		}
+		return out
+	}
+	return v
+}
+
+// toGeneric converts v to the maps, slices and scalars encoding/json decodes
+// into, keeping numbers exact
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// sortIndices orders the indices by the field at key, keeping the original order
+// of equal items. Items without the field go last.
+func sortIndices(indices []int, objects []interface{}, key string) error {
+	path := splitKey(key)
+	values := make(map[int]interface{}, len(indices))
+	found := len(indices) == 0
+	for _, i := range indices {
+		if v, ok := lookup(objects[i], path); ok {
+			values[i] = v
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("cannot sort by %q: no item has that field", key)
+	}
+
+	sort.SliceStable(indices, func(a, b int) bool {
+		va, okA := values[indices[a]]
+		vb, okB := values[indices[b]]
+		if !okA || !okB {
+			return okA && !okB
+		}
+		return compare(va, vb) < 0
+	})
+	return nil
+}
+
+// compare orders numbers numerically and everything else by its text, ignoring case
+func compare(a, b interface{}) int {
+	na, okA := a.(json.Number)
+	nb, okB := b.(json.Number)
+	if okA && okB {
+		fa, errA := na.Float64()
+		fb, errB := nb.Float64()
+		if errA == nil && errB == nil {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(strings.ToLower(scalarString(a)), strings.ToLower(scalarString(b)))
+}
+
+// fieldString returns a top-level field as text, or "" if it is missing
+func fieldString(obj interface{}, key string) string {
+	v, ok := lookup(obj, []string{key})
+	if !ok {
+		return ""
+	}
+	return scalarString(v)
+}
+
+// scalarString formats a value the way templates and JSONPath print it: strings
+// as they are, everything else as JSON
+func scalarString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
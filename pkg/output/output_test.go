@@ -0,0 +1,123 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type testVM struct {
+	Name     string            `json:"name"`
+	State    string            `json:"state"`
+	MemoryKB uint64            `json:"memory_kb"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+var testVMs = []testVM{
+	{Name: "web-01", State: "Running", MemoryKB: 4194304, Tags: []string{"prod"}, Labels: map[string]string{"env": "prod"}},
+	{Name: "db-01", State: "Shutoff", MemoryKB: 8388608},
+	{Name: "web-02", State: "Shutoff", MemoryKB: 1048576, Labels: map[string]string{"env": "dev"}},
+}
+
+var testTable = Table[testVM]{
+	Columns: []Column[testVM]{
+		{Header: "NAME", Value: func(vm testVM) string { return vm.Name }},
+		{Header: "STATE", Value: func(vm testVM) string { return vm.State }},
+		{Header: "TAGS", Wide: true, Value: func(vm testVM) string { return strings.Join(vm.Tags, ",") }},
+	},
+}
+
+func printTestVMs(t *testing.T, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := PrintList(&buf, testVMs, testTable, opts); err != nil {
+		t.Fatalf("PrintList(%+v) failed: %v", opts, err)
+	}
+	return buf.String()
+}
+
+func TestPrintList_Formats(t *testing.T) {
+	table := printTestVMs(t, Options{})
+	if !strings.HasPrefix(table, "NAME    STATE\n----    -----\nweb-01  Running\n") || strings.Contains(table, "TAGS") {
+		t.Errorf("Unexpected table:\n%s", table)
+	}
+	if wide := printTestVMs(t, Options{Format: "wide", NoHeaders: true}); !strings.HasPrefix(wide, "web-01  Running  prod\n") {
+		t.Errorf("Unexpected wide table:\n%s", wide)
+	}
+	if names := printTestVMs(t, Options{Format: "name"}); names != "web-01\ndb-01\nweb-02\n" {
+		t.Errorf("Unexpected names %q", names)
+	}
+	if out := printTestVMs(t, Options{Format: "json"}); !strings.Contains(out, `"memory_kb": 4194304`) {
+		t.Errorf("Expected JSON field names and exact numbers, got\n%s", out)
+	}
+	if out := printTestVMs(t, Options{Format: "yaml"}); !strings.Contains(out, "- labels:\n    env: prod\n  memory_kb: 4194304\n") {
+		t.Errorf("Unexpected YAML\n%s", out)
+	}
+	if out := printTestVMs(t, Options{Format: `go-template={{range .items}}{{.name}}={{.memory_kb}} {{end}}`}); out != "web-01=4194304 db-01=8388608 web-02=1048576 " {
+		t.Errorf("Unexpected template output %q", out)
+	}
+	if out := printTestVMs(t, Options{Format: `jsonpath={range .items[*]}{.name}{"\t"}{.state}{"\n"}{end}`}); out != "web-01\tRunning\ndb-01\tShutoff\nweb-02\tShutoff\n" {
+		t.Errorf("Unexpected JSONPath output %q", out)
+	}
+	if out := printTestVMs(t, Options{Format: "jsonpath={.items[*].name}"}); out != "web-01 db-01 web-02" {
+		t.Errorf("Unexpected JSONPath output %q", out)
+	}
+
+	for _, format := range []string{"xml", "json=x", "go-template=", "go-template={{.name", "jsonpath={.items", "jsonpath={range .items}"} {
+		if err := (Options{Format: format}).Validate(); err == nil {
+			t.Errorf("Expected format %q to be rejected", format)
+		}
+	}
+}
+
+func TestPrintList_SelectorAndSort(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{Selector: "state=running"}, "web-01\n"},
+		{Options{Selector: "name=web-*"}, "web-01\nweb-02\n"},
+		{Options{Selector: "name=web-*,state!=Running"}, "web-02\n"},
+		{Options{Selector: "tag=prod"}, "web-01\n"},
+		{Options{Selector: "label.env=dev"}, "web-02\n"},
+		{Options{Selector: "labels.env!=prod"}, "db-01\nweb-02\n"},
+		{Options{Selector: "unknown=x"}, ""},
+		{Options{SortBy: ".memory_kb"}, "web-02\nweb-01\ndb-01\n"},
+		{Options{SortBy: "{.name}"}, "db-01\nweb-01\nweb-02\n"},
+		{Options{SortBy: "labels.env", Selector: "state=shutoff"}, "web-02\ndb-01\n"},
+	}
+	for _, tt := range tests {
+		tt.opts.Format = "name"
+		if got := printTestVMs(t, tt.opts); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+
+	if err := PrintList(&bytes.Buffer{}, testVMs, testTable, Options{SortBy: "size"}); err == nil {
+		t.Error("Expected an error sorting by a field no item has")
+	}
+	for _, selector := range []string{"state", "=running", "name=[web"} {
+		if _, err := ParseSelector(selector); err == nil {
+			t.Errorf("Expected selector %q to be rejected", selector)
+		}
+	}
+}
+
+func TestPrintObject(t *testing.T) {
+	var buf bytes.Buffer
+	if handled, err := PrintObject(&buf, testVMs[0], Options{Format: "wide"}); handled || err != nil || buf.Len() != 0 {
+		t.Errorf("Expected tables to be left to the caller, got %v, %v, %q", handled, err, buf.String())
+	}
+	if _, err := PrintObject(&buf, testVMs[0], Options{Format: "jsonpath={.labels.env}"}); err != nil || buf.String() != "prod" {
+		t.Errorf("Unexpected JSONPath output %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	if _, err := PrintObject(&buf, testVMs[0], Options{Format: "name"}); err != nil || buf.String() != "web-01\n" {
+		t.Errorf("Unexpected name output %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	if _, err := PrintObject(&buf, testVMs[1], Options{Format: "jsonpath={.name}:{.tags[0]}{.labels.env}"}); err != nil || buf.String() != "db-01:" {
+		t.Errorf("Expected missing fields to print nothing, got %q, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,120 @@
+package output
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Selector filters items by their fields. It is a comma-separated list of
+// requirements that must all hold:
+//
+//	state=running        field equals the value, ignoring case
+//	name=web-*           the value may be a glob
+//	state!=shutoff       field differs from the value or is missing
+//	tag=prod             list fields match if any element does
+//	labels.env=prod      dots reach into nested objects
+//
+// A key that is not found is retried in its plural form, so tag=prod matches
+// the tags field and label.env=prod the labels field.
+type Selector []Requirement
+
+// Requirement is one term of a Selector
+type Requirement struct {
+	Key    string
+	Value  string
+	Negate bool
+}
+
+// ParseSelector parses a selector. An empty string selects everything.
+func ParseSelector(s string) (Selector, error) {
+	var selector Selector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		var req Requirement
+		key, value, ok := strings.Cut(term, "!=")
+		if ok {
+			req.Negate = true
+		} else if key, value, ok = strings.Cut(term, "=="); !ok {
+			key, value, ok = strings.Cut(term, "=")
+		}
+		req.Key, req.Value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || req.Key == "" {
+			return nil, fmt.Errorf("invalid selector %q: use key=value or key!=value", term)
+		}
+		if _, err := path.Match(strings.ToLower(req.Value), ""); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", term, err)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// Matches reports whether an item, in its JSON form, meets every requirement
+func (s Selector) Matches(obj interface{}) bool {
+	for _, req := range s {
+		if req.matches(obj) == req.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Requirement) matches(obj interface{}) bool {
+	keys := splitKey(r.Key)
+	v, ok := lookup(obj, keys)
+	if !ok {
+		keys[0] += "s"
+		if v, ok = lookup(obj, keys); !ok {
+			return false
+		}
+	}
+
+	values := []interface{}{v}
+	if list, isList := v.([]interface{}); isList {
+		values = list
+	}
+	pattern := strings.ToLower(r.Value)
+	for _, value := range values {
+		if matched, _ := path.Match(pattern, strings.ToLower(scalarString(value))); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// splitKey turns "labels.env", ".labels.env" or "{.labels.env}" into its parts
+func splitKey(key string) []string {
+	key = strings.TrimSuffix(strings.TrimPrefix(key, "{"), "}")
+	key = strings.TrimPrefix(key, ".")
+	return strings.Split(key, ".")
+}
+
+// lookup follows keys through nested objects, matching keys case-insensitively
+// if there is no exact match
+func lookup(obj interface{}, keys []string) (interface{}, bool) {
+	for _, key := range keys {
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok := m[key]
+		if !ok {
+			for k, candidate := range m {
+				if strings.EqualFold(k, key) {
+					v, ok = candidate, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+		obj = v
+	}
+	return obj, true
+}
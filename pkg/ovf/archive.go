@@ -0,0 +1,236 @@
+package ovf
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// manifestLine matches "SHA256(file)= checksum"
+var manifestLine = regexp.MustCompile(`^(\w+)\((.+)\)\s*=\s*([0-9A-Fa-f]+)$`)
+
+// WriteManifest writes an OVF manifest with the SHA-256 of each file in dir
+func WriteManifest(path, dir string, files []string) error {
+	var b strings.Builder
+	for _, name := range files {
+		sum, err := hashFile(filepath.Join(dir, name), sha256.New())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "SHA256(%s)= %s\n", name, sum)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// VerifyManifest checks the files listed in a manifest, which are looked up
+// next to it. SHA1 (OVF 1.0), SHA256 and SHA512 are supported.
+func VerifyManifest(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open manifest: %w", err)
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		m := manifestLine.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("invalid manifest line %q", line)
+		}
+		var h hash.Hash
+		switch strings.ToUpper(m[1]) {
+		case "SHA1":
+			h = sha1.New()
+		case "SHA256":
+			h = sha256.New()
+		case "SHA512":
+			h = sha512.New()
+		default:
+			return fmt.Errorf("unsupported manifest algorithm %s", m[1])
+		}
+		name, err := memberName(m[2])
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(filepath.Join(dir, name), h)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, m[3]) {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+	}
+	return scanner.Err()
+}
+
+// Pack writes files from dir into a tar archive, in the given order. The OVF
+// descriptor must come first for the archive to be a valid OVA. It returns
+// the SHA-256 and size of the archive.
+func Pack(ctx context.Context, archive, dir string, files []string) (string, int64, error) {
+	part := archive + ".part"
+	out, err := os.OpenFile(part, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", 0, fmt.Errorf("create archive: %w", err)
+	}
+	fail := func(err error) (string, int64, error) {
+		out.Close()
+		os.Remove(part)
+		return "", 0, err
+	}
+
+	h := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(out, h)}
+	tw := tar.NewWriter(counter)
+	for _, name := range files {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		if err := addFile(tw, filepath.Join(dir, name), name); err != nil {
+			return fail(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fail(fmt.Errorf("write archive: %w", err))
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(part)
+		return "", 0, fmt.Errorf("write archive: %w", err)
+	}
+	if err := os.Rename(part, archive); err != nil {
+		os.Remove(part)
+		return "", 0, fmt.Errorf("rename archive: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), counter.n, nil
+}
+
+// Unpack extracts a tar archive such as an OVA into dir and returns the names
+// of its files. Only plain files at the top level are accepted.
+func Unpack(ctx context.Context, archive, dir string) ([]string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer f.Close()
+
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("archive member %s is not a regular file", hdr.Name)
+		}
+		name, err := memberName(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("extract %s: %w", name, err)
+		}
+		_, err = io.Copy(out, tr)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("extract %s: %w", name, err)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("archive is empty")
+	}
+	return names, nil
+}
+
+// memberName validates the name of a file in a package: it must not leave
+// the package directory
+func memberName(name string) (string, error) {
+	clean := strings.TrimPrefix(name, "./")
+	if clean == "" || clean == "." || clean == ".." || strings.ContainsAny(clean, `/\`) {
+		return "", fmt.Errorf("unsupported file name %q in package", name)
+	}
+	return clean, nil
+}
+
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", name, err)
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+func hashFile(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
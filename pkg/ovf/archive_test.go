@@ -0,0 +1,83 @@
+package ovf
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPackUnpackManifest(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"vm.ovf":        "<Envelope/>",
+		"vm-disk0.vmdk": "disk data",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteManifest(filepath.Join(src, "vm.mf"), src, []string{"vm.ovf", "vm-disk0.vmdk"}); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "vm.ova")
+	order := []string{"vm.ovf", "vm.mf", "vm-disk0.vmdk"}
+	sum, size, err := Pack(context.Background(), archive, src, order)
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+	if info, err := os.Stat(archive); err != nil || info.Size() != size || len(sum) != 64 {
+		t.Fatalf("Pack() = %s, %d; archive stat %v", sum, size, err)
+	}
+
+	dst := t.TempDir()
+	names, err := Unpack(context.Background(), archive, dst)
+	if err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	if !reflect.DeepEqual(names, order) {
+		t.Errorf("Unpack() = %v, want %v", names, order)
+	}
+	if err := VerifyManifest(filepath.Join(dst, "vm.mf")); err != nil {
+		t.Errorf("VerifyManifest() error = %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dst, "vm-disk0.vmdk"), []byte("tampered"), 0644)
+	if err := VerifyManifest(filepath.Join(dst, "vm.mf")); err == nil {
+		t.Error("VerifyManifest() of a modified disk expected an error")
+	}
+}
+
+func TestUnpack_RejectsUnsafeMembers(t *testing.T) {
+	for _, name := range []string{"../escape.vmdk", "/etc/passwd", "sub/dir.vmdk"} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "bad.ova")
+			f, err := os.Create(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tw := tar.NewWriter(f)
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1})
+			tw.Write([]byte("x"))
+			tw.Close()
+			f.Close()
+
+			if _, err := Unpack(context.Background(), archive, t.TempDir()); err == nil {
+				t.Errorf("Unpack() of %q expected an error", name)
+			}
+		})
+	}
+}
+
+func TestVerifyManifest_RejectsUnsafeNames(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vm.mf")
+	os.WriteFile(path, []byte("SHA256(../vm.ovf)= 00\n"), 0644)
+	if err := VerifyManifest(path); err == nil {
+		t.Error("VerifyManifest() with a path outside the package expected an error")
+	}
+}
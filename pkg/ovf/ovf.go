@@ -0,0 +1,597 @@
+// Package ovf reads and writes OVF 1.0 descriptors and OVA archives, the
+// format VirtualBox and VMware use to exchange virtual machines. It only deals
+// with the package format; mapping to libvirt domains is done by the caller.
+package ovf
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Controllers a disk can be attached to
+const (
+	ControllerIDE  = "ide"
+	ControllerSCSI = "scsi"
+	ControllerSATA = "sata"
+)
+
+// Disk image formats
+const (
+	FormatVMDK  = "http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"
+	FormatQCOW2 = "http://www.gnome.org/~markmc/qcow-image-format.html"
+)
+
+// CIM resource types used in virtual hardware sections
+const (
+	resourceCPU        = 3
+	resourceMemory     = 4
+	resourceIDE        = 5
+	resourceSCSI       = 6
+	resourceEthernet   = 10
+	resourceDisk       = 17
+	resourceSATA       = 20
+	resourceOtherStore = 31
+)
+
+// Namespaces of an OVF 1.0 envelope
+const (
+	namespaceOVF  = "http://schemas.dmtf.org/ovf/envelope/1"
+	namespaceRASD = "http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData"
+	namespaceVSSD = "http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData"
+	namespaceVMW  = "http://www.vmware.com/schema/ovf"
+)
+
+// VirtualMachine is the part of an OVF virtual system Flint understands
+type VirtualMachine struct {
+	Name        string
+	Description string
+	OSType      string // Guest OS description, e.g. "Ubuntu_64"
+	VCPUs       int
+	MemoryMB    uint64
+	Firmware    string // "bios" or "efi"
+	Disks       []Disk
+	NICs        []NIC
+}
+
+// Disk is a virtual disk and the file holding it in the package
+type Disk struct {
+	Name          string // Element name; Flint uses the libvirt target device
+	File          string // File name in the package
+	Format        string // Format URI, e.g. FormatVMDK
+	SizeBytes     int64  // Size of the file
+	CapacityBytes uint64 // Virtual size of the disk
+	Controller    string // ControllerIDE, ControllerSCSI or ControllerSATA
+}
+
+// NIC is a network adapter
+type NIC struct {
+	Network string // Logical network name
+	Model   string // Adapter type, e.g. E1000 or VmxNet3
+	MAC     string
+}
+
+// envelope is the OVF descriptor as written. encoding/xml writes prefixed
+// names verbatim, so the namespace declarations are plain attributes.
+type envelope struct {
+	XMLName        xml.Name        `xml:"Envelope"`
+	Version        string          `xml:"ovf:version,attr"`
+	Xmlns          string          `xml:"xmlns,attr"`
+	XmlnsOVF       string          `xml:"xmlns:ovf,attr"`
+	XmlnsRASD      string          `xml:"xmlns:rasd,attr"`
+	XmlnsVSSD      string          `xml:"xmlns:vssd,attr"`
+	XmlnsVMW       string          `xml:"xmlns:vmw,attr"`
+	Files          []fileRef       `xml:"References>File"`
+	DiskSection    diskSection     `xml:"DiskSection"`
+	NetworkSection *networkSection `xml:"NetworkSection,omitempty"`
+	VirtualSystem  virtualSystem   `xml:"VirtualSystem"`
+}
+
+type fileRef struct {
+	ID   string `xml:"ovf:id,attr"`
+	Href string `xml:"ovf:href,attr"`
+	Size int64  `xml:"ovf:size,attr"`
+}
+
+type diskSection struct {
+	Info  string    `xml:"Info"`
+	Disks []diskRef `xml:"Disk"`
+}
+
+type diskRef struct {
+	Capacity uint64 `xml:"ovf:capacity,attr"`
+	DiskID   string `xml:"ovf:diskId,attr"`
+	FileRef  string `xml:"ovf:fileRef,attr"`
+	Format   string `xml:"ovf:format,attr"`
+}
+
+type networkSection struct {
+	Info     string    `xml:"Info"`
+	Networks []network `xml:"Network"`
+}
+
+type network struct {
+	Name        string `xml:"ovf:name,attr"`
+	Description string `xml:"Description"`
+}
+
+type virtualSystem struct {
+	ID         string          `xml:"ovf:id,attr"`
+	Info       string          `xml:"Info"`
+	Name       string          `xml:"Name"`
+	Annotation *annotation     `xml:"AnnotationSection,omitempty"`
+	OS         operatingSystem `xml:"OperatingSystemSection"`
+	Hardware   virtualHardware `xml:"VirtualHardwareSection"`
+}
+
+type annotation struct {
+	Info       string `xml:"Info"`
+	Annotation string `xml:"Annotation"`
+}
+
+type operatingSystem struct {
+	ID          int    `xml:"ovf:id,attr"`
+	Info        string `xml:"Info"`
+	Description string `xml:"Description"`
+}
+
+type virtualHardware struct {
+	Info    string      `xml:"Info"`
+	System  systemInfo  `xml:"System"`
+	Items   []item      `xml:"Item"`
+	Configs []vmwConfig `xml:"vmw:Config"`
+}
+
+type systemInfo struct {
+	ElementName             string `xml:"vssd:ElementName"`
+	InstanceID              int    `xml:"vssd:InstanceID"`
+	VirtualSystemIdentifier string `xml:"vssd:VirtualSystemIdentifier"`
+	VirtualSystemType       string `xml:"vssd:VirtualSystemType"`
+}
+
+// item is a CIM resource allocation; the schema requires alphabetical order
+type item struct {
+	Address             string `xml:"rasd:Address,omitempty"`
+	AddressOnParent     string `xml:"rasd:AddressOnParent,omitempty"`
+	AllocationUnits     string `xml:"rasd:AllocationUnits,omitempty"`
+	AutomaticAllocation string `xml:"rasd:AutomaticAllocation,omitempty"`
+	Connection          string `xml:"rasd:Connection,omitempty"`
+	Description         string `xml:"rasd:Description,omitempty"`
+	ElementName         string `xml:"rasd:ElementName"`
+	HostResource        string `xml:"rasd:HostResource,omitempty"`
+	InstanceID          int    `xml:"rasd:InstanceID"`
+	Parent              string `xml:"rasd:Parent,omitempty"`
+	ResourceSubType     string `xml:"rasd:ResourceSubType,omitempty"`
+	ResourceType        int    `xml:"rasd:ResourceType"`
+	VirtualQuantity     string `xml:"rasd:VirtualQuantity,omitempty"`
+}
+
+type vmwConfig struct {
+	Required string `xml:"ovf:required,attr"`
+	Key      string `xml:"vmw:key,attr"`
+	Value    string `xml:"vmw:value,attr"`
+}
+
+// Marshal returns the OVF descriptor of a virtual machine. Disks are grouped
+// on one controller per type, in the order given.
+func Marshal(vm VirtualMachine) ([]byte, error) {
+	if vm.Name == "" {
+		return nil, errors.New("virtual machine name is required")
+	}
+	if vm.VCPUs <= 0 || vm.MemoryMB == 0 {
+		return nil, errors.New("virtual machine needs CPUs and memory")
+	}
+
+	env := envelope{
+		Version:     "1.0",
+		Xmlns:       namespaceOVF,
+		XmlnsOVF:    namespaceOVF,
+		XmlnsRASD:   namespaceRASD,
+		XmlnsVSSD:   namespaceVSSD,
+		XmlnsVMW:    namespaceVMW,
+		DiskSection: diskSection{Info: "Virtual disk information"},
+		VirtualSystem: virtualSystem{
+			ID:   vm.Name,
+			Info: "A virtual machine",
+			Name: vm.Name,
+			OS:   operatingSystem{ID: 1, Info: "The kind of installed guest operating system", Description: "Other"},
+			Hardware: virtualHardware{
+				Info: "Virtual hardware requirements",
+				System: systemInfo{
+					ElementName:             "Virtual Hardware Family",
+					VirtualSystemIdentifier: vm.Name,
+					VirtualSystemType:       "vmx-10",
+				},
+			},
+		},
+	}
+	if vm.OSType != "" {
+		env.VirtualSystem.OS.Description = vm.OSType
+	}
+	if vm.Description != "" {
+		env.VirtualSystem.Annotation = &annotation{Info: "A human-readable annotation", Annotation: vm.Description}
+	}
+
+	hw := &env.VirtualSystem.Hardware
+	nextID := 1
+	add := func(it item) int {
+		it.InstanceID = nextID
+		nextID++
+		hw.Items = append(hw.Items, it)
+		return it.InstanceID
+	}
+
+	add(item{
+		AllocationUnits: "hertz * 10^6",
+		Description:     "Number of Virtual CPUs",
+		ElementName:     fmt.Sprintf("%d virtual CPU(s)", vm.VCPUs),
+		ResourceType:    resourceCPU,
+		VirtualQuantity: strconv.Itoa(vm.VCPUs),
+	})
+	add(item{
+		AllocationUnits: "byte * 2^20",
+		Description:     "Memory Size",
+		ElementName:     fmt.Sprintf("%dMB of memory", vm.MemoryMB),
+		ResourceType:    resourceMemory,
+		VirtualQuantity: strconv.FormatUint(vm.MemoryMB, 10),
+	})
+
+	controllers := make(map[string]int) // controller key -> instance ID
+	attached := make(map[string]int)    // controller type -> disks so far
+	for i, d := range vm.Disks {
+		if d.File == "" {
+			return nil, fmt.Errorf("disk %d has no file", i)
+		}
+		fileID := fmt.Sprintf("file%d", i+1)
+		diskID := fmt.Sprintf("vmdisk%d", i+1)
+		format := d.Format
+		if format == "" {
+			format = FormatVMDK
+		}
+		env.Files = append(env.Files, fileRef{ID: fileID, Href: d.File, Size: d.SizeBytes})
+		env.DiskSection.Disks = append(env.DiskSection.Disks, diskRef{Capacity: d.CapacityBytes, DiskID: diskID, FileRef: fileID, Format: format})
+
+		controller := d.Controller
+		if controller != ControllerIDE && controller != ControllerSCSI {
+			controller = ControllerSATA
+		}
+		n := attached[controller]
+		attached[controller]++
+
+		// IDE controllers take two disks each; the others take plenty
+		index, address := 0, n
+		if controller == ControllerIDE {
+			index, address = n/2, n%2
+		}
+		key := fmt.Sprintf("%s%d", controller, index)
+		parent, ok := controllers[key]
+		if !ok {
+			parent = add(controllerItem(controller, index))
+			controllers[key] = parent
+		}
+
+		name := d.Name
+		if name == "" {
+			name = fmt.Sprintf("disk%d", i)
+		}
+		add(item{
+			AddressOnParent: strconv.Itoa(address),
+			ElementName:     name,
+			HostResource:    "ovf:/disk/" + diskID,
+			Parent:          strconv.Itoa(parent),
+			ResourceType:    resourceDisk,
+		})
+	}
+
+	seen := make(map[string]bool)
+	for i, nic := range vm.NICs {
+		netName := nic.Network
+		if netName == "" {
+			netName = "default"
+		}
+		if !seen[netName] {
+			seen[netName] = true
+			if env.NetworkSection == nil {
+				env.NetworkSection = &networkSection{Info: "The list of logical networks"}
+			}
+			env.NetworkSection.Networks = append(env.NetworkSection.Networks, network{Name: netName, Description: "The " + netName + " network"})
+		}
+		model := nic.Model
+		if model == "" {
+			model = "E1000"
+		}
+		add(item{
+			Address:             nic.MAC,
+			AddressOnParent:     strconv.Itoa(7 + i),
+			AutomaticAllocation: "true",
+			Connection:          netName,
+			ElementName:         fmt.Sprintf("ethernet%d", i),
+			ResourceSubType:     model,
+			ResourceType:        resourceEthernet,
+		})
+	}
+
+	if strings.EqualFold(vm.Firmware, "efi") {
+		hw.Configs = append(hw.Configs, vmwConfig{Required: "false", Key: "firmware", Value: "efi"})
+	}
+
+	data, err := xml.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal ovf descriptor: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// controllerItem describes a disk controller
+func controllerItem(controller string, index int) item {
+	it := item{Address: strconv.Itoa(index)}
+	switch controller {
+	case ControllerIDE:
+		it.Description = "IDE Controller"
+		it.ElementName = fmt.Sprintf("ideController%d", index)
+		it.ResourceType = resourceIDE
+	case ControllerSCSI:
+		it.Description = "SCSI Controller"
+		it.ElementName = fmt.Sprintf("scsiController%d", index)
+		it.ResourceSubType = "lsilogic"
+		it.ResourceType = resourceSCSI
+	default:
+		it.Description = "SATA Controller"
+		it.ElementName = fmt.Sprintf("sataController%d", index)
+		it.ResourceSubType = "AHCI"
+		it.ResourceType = resourceSATA
+	}
+	return it
+}
+
+// The parsing types use local names only, so they match whatever prefixes
+// and OVF version (1.0 or 2.x) the producer used.
+type parsedEnvelope struct {
+	Files []struct {
+		ID   string `xml:"id,attr"`
+		Href string `xml:"href,attr"`
+		Size string `xml:"size,attr"`
+	} `xml:"References>File"`
+	Disks []struct {
+		DiskID        string `xml:"diskId,attr"`
+		FileRef       string `xml:"fileRef,attr"`
+		Format        string `xml:"format,attr"`
+		Capacity      string `xml:"capacity,attr"`
+		CapacityUnits string `xml:"capacityAllocationUnits,attr"`
+	} `xml:"DiskSection>Disk"`
+	Systems     []parsedSystem `xml:"VirtualSystem"`
+	Collections []struct {
+		Systems []parsedSystem `xml:"VirtualSystem"`
+	} `xml:"VirtualSystemCollection"`
+}
+
+type parsedSystem struct {
+	ID         string `xml:"id,attr"`
+	Name       string `xml:"Name"`
+	Annotation string `xml:"AnnotationSection>Annotation"`
+	OS         struct {
+		Description string `xml:"Description"`
+		OSType      string `xml:"osType,attr"`
+	} `xml:"OperatingSystemSection"`
+	Hardware []struct {
+		Items         []parsedItem `xml:"Item"`
+		StorageItems  []parsedItem `xml:"StorageItem"`
+		EthernetItems []parsedItem `xml:"EthernetPortItem"`
+		Configs       []struct {
+			Key   string `xml:"key,attr"`
+			Value string `xml:"value,attr"`
+		} `xml:"Config"`
+	} `xml:"VirtualHardwareSection"`
+}
+
+type parsedItem struct {
+	Address         string `xml:"Address"`
+	AllocationUnits string `xml:"AllocationUnits"`
+	Caption         string `xml:"Caption"`
+	Connection      string `xml:"Connection"`
+	ElementName     string `xml:"ElementName"`
+	HostResource    string `xml:"HostResource"`
+	InstanceID      string `xml:"InstanceID"`
+	Parent          string `xml:"Parent"`
+	ResourceSubType string `xml:"ResourceSubType"`
+	ResourceType    string `xml:"ResourceType"`
+	VirtualQuantity string `xml:"VirtualQuantity"`
+}
+
+// Parse reads an OVF descriptor holding a single virtual system. CD-ROM drives
+// and devices Flint has no use for are left out.
+func Parse(data []byte) (VirtualMachine, error) {
+	var env parsedEnvelope
+	if err := xml.Unmarshal(data, &env); err != nil {
+		return VirtualMachine{}, fmt.Errorf("parse ovf descriptor: %w", err)
+	}
+
+	systems := env.Systems
+	for _, c := range env.Collections {
+		systems = append(systems, c.Systems...)
+	}
+	if len(systems) != 1 {
+		return VirtualMachine{}, fmt.Errorf("ovf descriptor has %d virtual systems; only single-VM packages are supported", len(systems))
+	}
+	sys := systems[0]
+
+	vm := VirtualMachine{
+		Name:        strings.TrimSpace(sys.Name),
+		Description: strings.TrimSpace(sys.Annotation),
+		OSType:      strings.TrimSpace(sys.OS.Description),
+		Firmware:    "bios",
+	}
+	if vm.Name == "" {
+		vm.Name = sys.ID
+	}
+	if sys.OS.OSType != "" {
+		vm.OSType = sys.OS.OSType
+	}
+	if len(sys.Hardware) == 0 {
+		return VirtualMachine{}, errors.New("ovf descriptor has no virtual hardware section")
+	}
+	hw := sys.Hardware[0]
+
+	files := make(map[string]Disk)
+	for _, f := range env.Files {
+		// Files must sit next to the descriptor; URLs and other paths are refused
+		name, err := memberName(f.Href)
+		if err != nil {
+			return VirtualMachine{}, err
+		}
+		size, _ := strconv.ParseInt(f.Size, 10, 64)
+		files[f.ID] = Disk{File: name, SizeBytes: size}
+	}
+	disks := make(map[string]Disk)
+	for _, d := range env.Disks {
+		disk, ok := files[d.FileRef]
+		if !ok {
+			// Disks without a file are created empty; Flint needs an image
+			return VirtualMachine{}, fmt.Errorf("disk %s has no file in the package", d.DiskID)
+		}
+		capacity, err := parseCapacity(d.Capacity, d.CapacityUnits)
+		if err != nil {
+			return VirtualMachine{}, fmt.Errorf("disk %s: %w", d.DiskID, err)
+		}
+		disk.Format = d.Format
+		disk.CapacityBytes = capacity
+		disks[d.DiskID] = disk
+	}
+
+	items := append(append(append([]parsedItem{}, hw.Items...), hw.StorageItems...), hw.EthernetItems...)
+	controllers := make(map[string]string) // instance ID -> controller type
+	for _, it := range items {
+		switch atoi(it.ResourceType) {
+		case resourceIDE:
+			controllers[it.InstanceID] = ControllerIDE
+		case resourceSCSI:
+			controllers[it.InstanceID] = ControllerSCSI
+		case resourceSATA:
+			controllers[it.InstanceID] = ControllerSATA
+		case resourceOtherStore:
+			if strings.EqualFold(it.ResourceSubType, "AHCI") {
+				controllers[it.InstanceID] = ControllerSATA
+			}
+		}
+	}
+
+	for _, it := range items {
+		switch atoi(it.ResourceType) {
+		case resourceCPU:
+			vm.VCPUs = atoi(it.VirtualQuantity)
+		case resourceMemory:
+			quantity, err := strconv.ParseUint(strings.TrimSpace(it.VirtualQuantity), 10, 64)
+			if err != nil {
+				return VirtualMachine{}, fmt.Errorf("invalid memory size %q", it.VirtualQuantity)
+			}
+			unit := uint64(1 << 20) // Producers that leave units out mean megabytes
+			if it.AllocationUnits != "" {
+				if unit, err = parseUnits(it.AllocationUnits); err != nil {
+					return VirtualMachine{}, fmt.Errorf("memory: %w", err)
+				}
+			}
+			vm.MemoryMB = quantity * unit / (1 << 20)
+		case resourceDisk:
+			disk, err := resolveDisk(it.HostResource, disks, files)
+			if err != nil {
+				return VirtualMachine{}, err
+			}
+			disk.Name = itemName(it)
+			disk.Controller = controllers[it.Parent]
+			vm.Disks = append(vm.Disks, disk)
+		case resourceEthernet:
+			vm.NICs = append(vm.NICs, NIC{Network: it.Connection, Model: it.ResourceSubType, MAC: it.Address})
+		}
+	}
+
+	for _, c := range hw.Configs {
+		if c.Key == "firmware" && c.Value != "" {
+			vm.Firmware = strings.ToLower(c.Value)
+		}
+	}
+	return vm, nil
+}
+
+// resolveDisk finds the disk an item's host resource points at, either
+// ovf:/disk/<id> or ovf:/file/<id>
+func resolveDisk(hostResource string, disks, files map[string]Disk) (Disk, error) {
+	ref := strings.TrimPrefix(strings.TrimSpace(hostResource), "ovf:")
+	switch {
+	case strings.HasPrefix(ref, "/disk/"):
+		if d, ok := disks[strings.TrimPrefix(ref, "/disk/")]; ok {
+			return d, nil
+		}
+	case strings.HasPrefix(ref, "/file/"):
+		if d, ok := files[strings.TrimPrefix(ref, "/file/")]; ok {
+			return d, nil
+		}
+	}
+	return Disk{}, fmt.Errorf("disk references unknown resource %q", hostResource)
+}
+
+// itemName returns an item's element name, or its caption for producers that
+// only set that
+func itemName(it parsedItem) string {
+	if it.ElementName != "" {
+		return it.ElementName
+	}
+	return it.Caption
+}
+
+// parseCapacity returns a disk capacity in bytes
+func parseCapacity(capacity, units string) (uint64, error) {
+	value, err := strconv.ParseUint(strings.TrimSpace(capacity), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported capacity %q", capacity)
+	}
+	if units == "" {
+		return value, nil
+	}
+	unit, err := parseUnits(units)
+	if err != nil {
+		return 0, err
+	}
+	return value * unit, nil
+}
+
+// parseUnits returns the size in bytes of programmatic units such as
+// "byte * 2^20", or of the names older producers use such as "MegaBytes"
+func parseUnits(units string) (uint64, error) {
+	u := strings.ToLower(strings.ReplaceAll(units, " ", ""))
+	switch u {
+	case "byte", "bytes":
+		return 1, nil
+	case "kilobytes", "kb":
+		return 1 << 10, nil
+	case "megabytes", "mb":
+		return 1 << 20, nil
+	case "gigabytes", "gb":
+		return 1 << 30, nil
+	case "terabytes", "tb":
+		return 1 << 40, nil
+	}
+
+	if base, exp, ok := strings.Cut(strings.TrimPrefix(u, "byte*"), "^"); ok && strings.HasPrefix(u, "byte*") {
+		n, err := strconv.Atoi(exp)
+		if err == nil && n >= 0 {
+			switch {
+			case base == "2" && n < 64:
+				return 1 << n, nil
+			case base == "10" && n <= 19:
+				unit := uint64(1)
+				for i := 0; i < n; i++ {
+					unit *= 10
+				}
+				return unit, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unsupported allocation units %q", units)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
@@ -0,0 +1,196 @@
+package ovf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalParseRoundTrip(t *testing.T) {
+	vm := VirtualMachine{
+		Name:        "web-01",
+		Description: "Front end",
+		OSType:      "Ubuntu_64",
+		VCPUs:       2,
+		MemoryMB:    2048,
+		Firmware:    "efi",
+		Disks: []Disk{
+			{Name: "vda", File: "web-01-disk0.vmdk", Format: FormatVMDK, SizeBytes: 1000, CapacityBytes: 10 << 30, Controller: ControllerSATA},
+			{Name: "hda", File: "web-01-disk1.vmdk", Format: FormatVMDK, SizeBytes: 2000, CapacityBytes: 1 << 30, Controller: ControllerIDE},
+			{Name: "hdb", File: "web-01-disk2.vmdk", Format: FormatVMDK, SizeBytes: 3000, CapacityBytes: 2 << 30, Controller: ControllerIDE},
+			{Name: "hdc", File: "web-01-disk3.vmdk", Format: FormatVMDK, SizeBytes: 4000, CapacityBytes: 3 << 30, Controller: ControllerIDE},
+		},
+		NICs: []NIC{
+			{Network: "default", Model: "E1000", MAC: "52:54:00:12:34:56"},
+			{Network: "lab", Model: "VmxNet3"},
+		},
+	}
+
+	data, err := Marshal(vm)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	for _, want := range []string{`ovf:version="1.0"`, `xmlns:rasd=`, `<rasd:ResourceSubType>AHCI</rasd:ResourceSubType>`, `vmw:key="firmware"`, `ideController1`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Marshal() output is missing %s", want)
+		}
+	}
+
+	got, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, vm) {
+		t.Errorf("Parse(Marshal()) =\n%+v\nwant\n%+v", got, vm)
+	}
+}
+
+func TestMarshal_RequiresHardware(t *testing.T) {
+	if _, err := Marshal(VirtualMachine{Name: "empty"}); err == nil {
+		t.Error("Marshal() without CPUs or memory expected an error")
+	}
+	if _, err := Marshal(VirtualMachine{VCPUs: 1, MemoryMB: 512}); err == nil {
+		t.Error("Marshal() without a name expected an error")
+	}
+}
+
+// A trimmed VirtualBox export: captions instead of element names, OVF 1.0
+// units and a CD-ROM drive
+const virtualBoxOVF = `<?xml version="1.0"?>
+<Envelope ovf:version="1.0" xml:lang="en-US" xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData" xmlns:vbox="http://www.virtualbox.org/ovf/machine">
+  <References>
+    <File ovf:id="file1" ovf:href="debian-disk001.vmdk"/>
+  </References>
+  <DiskSection>
+    <Info>List of the virtual disks used in the package</Info>
+    <Disk ovf:capacity="20" ovf:capacityAllocationUnits="byte * 2^30" ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:format="http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"/>
+  </DiskSection>
+  <VirtualSystem ovf:id="debian">
+    <Info>A virtual machine</Info>
+    <OperatingSystemSection ovf:id="96" vbox:OSType="Debian_64">
+      <Info>The kind of installed guest operating system</Info>
+      <Description>Debian_64</Description>
+    </OperatingSystemSection>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware requirements for a virtual machine</Info>
+      <Item>
+        <rasd:Caption>1 virtual CPU</rasd:Caption>
+        <rasd:InstanceID>1</rasd:InstanceID>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>1</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>MegaBytes</rasd:AllocationUnits>
+        <rasd:Caption>1024 MB of memory</rasd:Caption>
+        <rasd:InstanceID>2</rasd:InstanceID>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>1024</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:Address>0</rasd:Address>
+        <rasd:Caption>sataController0</rasd:Caption>
+        <rasd:InstanceID>3</rasd:InstanceID>
+        <rasd:ResourceSubType>AHCI</rasd:ResourceSubType>
+        <rasd:ResourceType>20</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>0</rasd:AddressOnParent>
+        <rasd:Caption>disk1</rasd:Caption>
+        <rasd:HostResource>/disk/vmdisk1</rasd:HostResource>
+        <rasd:InstanceID>4</rasd:InstanceID>
+        <rasd:Parent>3</rasd:Parent>
+        <rasd:ResourceType>17</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>1</rasd:AddressOnParent>
+        <rasd:Caption>cdrom1</rasd:Caption>
+        <rasd:InstanceID>5</rasd:InstanceID>
+        <rasd:Parent>3</rasd:Parent>
+        <rasd:ResourceType>15</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AutomaticAllocation>true</rasd:AutomaticAllocation>
+        <rasd:Caption>Ethernet adapter on 'NAT'</rasd:Caption>
+        <rasd:Connection>NAT</rasd:Connection>
+        <rasd:InstanceID>6</rasd:InstanceID>
+        <rasd:ResourceSubType>E1000</rasd:ResourceSubType>
+        <rasd:ResourceType>10</rasd:ResourceType>
+      </Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestParse_VirtualBox(t *testing.T) {
+	vm, err := Parse([]byte(virtualBoxOVF))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := VirtualMachine{
+		Name:     "debian",
+		OSType:   "Debian_64",
+		VCPUs:    1,
+		MemoryMB: 1024,
+		Firmware: "bios",
+		Disks: []Disk{{
+			Name: "disk1", File: "debian-disk001.vmdk", Format: FormatVMDK,
+			CapacityBytes: 20 << 30, Controller: ControllerSATA,
+		}},
+		NICs: []NIC{{Network: "NAT", Model: "E1000"}},
+	}
+	if !reflect.DeepEqual(vm, want) {
+		t.Errorf("Parse() =\n%+v\nwant\n%+v", vm, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]string{
+		"invalid xml": `<Envelope`,
+		"no system":   `<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1"></Envelope>`,
+		"two systems": `<Envelope><VirtualSystemCollection><VirtualSystem/><VirtualSystem/></VirtualSystemCollection></Envelope>`,
+		"unknown disk": `<Envelope><VirtualSystem><VirtualHardwareSection>
+			<Item><HostResource>ovf:/disk/missing</HostResource><ResourceType>17</ResourceType></Item>
+			</VirtualHardwareSection></VirtualSystem></Envelope>`,
+		"disk without file": `<Envelope><DiskSection><Disk diskId="d1" capacity="1"/></DiskSection>
+			<VirtualSystem><VirtualHardwareSection/></VirtualSystem></Envelope>`,
+		"file outside package": `<Envelope><References><File id="f1" href="../../etc/shadow"/></References>
+			<VirtualSystem><VirtualHardwareSection/></VirtualSystem></Envelope>`,
+		"file url": `<Envelope><References><File id="f1" href="http://example.com/disk.vmdk"/></References>
+			<VirtualSystem><VirtualHardwareSection/></VirtualSystem></Envelope>`,
+		"property capacity": `<Envelope><References><File id="f1" href="a.vmdk"/></References>
+			<DiskSection><Disk diskId="d1" fileRef="f1" capacity="${disk.size}"/></DiskSection>
+			<VirtualSystem><VirtualHardwareSection/></VirtualSystem></Envelope>`,
+	}
+	for name, descriptor := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(descriptor)); err == nil {
+				t.Error("Parse() expected an error")
+			}
+		})
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		units string
+		want  uint64
+	}{
+		{"byte", 1},
+		{"byte * 2^20", 1 << 20},
+		{"byte*2^30", 1 << 30},
+		{"byte * 10^6", 1000000},
+		{"MegaBytes", 1 << 20},
+		{"GigaBytes", 1 << 30},
+	}
+	for _, tt := range tests {
+		got, err := parseUnits(tt.units)
+		if err != nil || got != tt.want {
+			t.Errorf("parseUnits(%q) = %d, %v, want %d", tt.units, got, err, tt.want)
+		}
+	}
+	for _, units := range []string{"hertz * 10^6", "byte * 3^2", "furlongs"} {
+		if _, err := parseUnits(units); err == nil {
+			t.Errorf("parseUnits(%q) expected an error", units)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package qemuimg inspects and converts disk images with qemu-img, which must
+// be installed on the host running Flint.
+package qemuimg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// command is the qemu-img binary; tests replace it
+var command = "qemu-img"
+
+// ConvertibleFormats are the source formats Convert accepts
+var ConvertibleFormats = []string{"raw", "qcow2", "vmdk", "vdi", "vhdx", "vpc"}
+
+// Info is the part of `qemu-img info --output=json` Flint uses
+type Info struct {
+	Filename              string `json:"filename"`
+	Format                string `json:"format"`
+	VirtualSize           uint64 `json:"virtual-size"`
+	ActualSize            uint64 `json:"actual-size"`
+	ClusterSize           uint64 `json:"cluster-size"`
+	DirtyFlag             bool   `json:"dirty-flag"`
+	BackingFilename       string `json:"backing-filename"`
+	FullBackingFilename   string `json:"full-backing-filename"`
+	BackingFilenameFormat string `json:"backing-filename-format"`
+	FormatSpecific        struct {
+		Type string `json:"type"`
+		Data struct {
+			Compat          string `json:"compat"`
+			CompressionType string `json:"compression-type"`
+			Corrupt         bool   `json:"corrupt"`
+			Extents         []struct {
+				Filename string `json:"filename"`
+			} `json:"extents"`
+		} `json:"data"`
+	} `json:"format-specific"`
+}
+
+// Standalone fails for images that read other files: those with a backing
+// file, or VMDK descriptors with extents outside the image itself
+func (i Info) Standalone(path string) error {
+	if i.BackingFilename != "" {
+		return fmt.Errorf("disk %s refers to backing file %s", filepath.Base(path), i.BackingFilename)
+	}
+	for _, extent := range i.FormatSpecific.Data.Extents {
+		if filepath.Clean(extent.Filename) != filepath.Clean(path) {
+			return fmt.Errorf("disk %s refers to extent %s", filepath.Base(path), extent.Filename)
+		}
+	}
+	return nil
+}
+
+// Inspect reads an image's format, sizes and backing file. An empty format
+// lets qemu-img detect it. Images in use by running VMs can be inspected.
+func Inspect(ctx context.Context, path, format string) (Info, error) {
+	args := []string{"info", "--output=json", "-U"}
+	if format != "" {
+		args = append(args, "-f", format)
+	}
+	out, err := run(ctx, append(args, path)...)
+	if err != nil {
+		return Info{}, fmt.Errorf("inspect %s: %w", filepath.Base(path), err)
+	}
+
+	var info Info
+	if err := json.Unmarshal(out, &info); err != nil {
+		return Info{}, fmt.Errorf("parse qemu-img info: %w", err)
+	}
+	return info, nil
+}
+
+// BackingChain inspects an image and each of its backing files, the image first
+func BackingChain(ctx context.Context, path string) ([]Info, error) {
+	out, err := run(ctx, "info", "--output=json", "-U", "--backing-chain", path)
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", filepath.Base(path), err)
+	}
+
+	var chain []Info
+	if err := json.Unmarshal(out, &chain); err != nil {
+		return nil, fmt.Errorf("parse qemu-img info: %w", err)
+	}
+	return chain, nil
+}
+
+// ConvertOptions controls Convert
+type ConvertOptions struct {
+	SourceFormat string // Empty lets qemu-img detect it
+	Format       string // Output format, qcow2 or raw
+	Compress     bool   // Compress the data clusters of qcow2 output
+}
+
+// Convert writes src to dst in another format, reporting progress as a
+// percentage. Zeroed blocks are not written, so the output is sparse.
+func Convert(ctx context.Context, src, dst string, opts ConvertOptions, progress func(percent float64)) error {
+	if opts.Format != "qcow2" && opts.Format != "raw" {
+		return fmt.Errorf("unsupported output format %q", opts.Format)
+	}
+	if opts.Compress && opts.Format != "qcow2" {
+		return errors.New("only qcow2 images can be compressed")
+	}
+	if progress == nil {
+		progress = func(float64) {}
+	}
+
+	args := []string{"convert", "-p", "-O", opts.Format}
+	if opts.SourceFormat != "" {
+		args = append(args, "-f", opts.SourceFormat)
+	}
+	if opts.Compress {
+		args = append(args, "-c")
+	}
+	args = append(args, src, dst)
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("qemu-img convert: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		if percent, ok := parseProgress(scanner.Text()); ok {
+			progress(percent)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("qemu-img convert: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// run runs qemu-img and returns its output, including stderr in errors
+func run(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+var progressPattern = regexp.MustCompile(`\((\d+(?:\.\d+)?)/100%\)`)
+
+// parseProgress reads a progress line of qemu-img -p, such as "    (42.19/100%)"
+func parseProgress(line string) (float64, bool) {
+	m := progressPattern.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	percent, err := strconv.ParseFloat(m[1], 64)
+	return percent, err == nil
+}
+
+// scanProgressLines splits output on carriage returns as well as newlines,
+// since qemu-img redraws its progress line in place
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
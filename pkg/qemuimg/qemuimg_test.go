@@ -0,0 +1,82 @@
+package qemuimg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeQemuImg replaces qemu-img with a shell script for the test
+func fakeQemuImg(t *testing.T, script string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "qemu-img")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	previous := command
+	command = path
+	t.Cleanup(func() { command = previous })
+}
+
+func TestInspect(t *testing.T) {
+	fakeQemuImg(t, `cat <<'EOF'
+{
+    "virtual-size": 10737418240,
+    "filename": "/var/lib/flint/images/web-disk-0.qcow2",
+    "cluster-size": 65536,
+    "format": "qcow2",
+    "actual-size": 1441792,
+    "dirty-flag": false,
+    "backing-filename": "/var/lib/flint/images/noble.img",
+    "backing-filename-format": "qcow2",
+    "format-specific": {"type": "qcow2", "data": {"compat": "1.1", "compression-type": "zlib", "corrupt": false}}
+}
+EOF`)
+
+	info, err := Inspect(context.Background(), "/var/lib/flint/images/web-disk-0.qcow2", "")
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if info.Format != "qcow2" || info.VirtualSize != 10<<30 || info.ClusterSize != 65536 || info.FormatSpecific.Data.Compat != "1.1" {
+		t.Errorf("Inspect() = %+v", info)
+	}
+	if err := info.Standalone(info.Filename); err == nil || !strings.Contains(err.Error(), "noble.img") {
+		t.Errorf("Standalone() error = %v, want the backing file reported", err)
+	}
+
+	fakeQemuImg(t, `echo "qemu-img: Could not open 'x': No such file" >&2; exit 1`)
+	if _, err := Inspect(context.Background(), "x", "raw"); err == nil || !strings.Contains(err.Error(), "No such file") {
+		t.Errorf("Inspect() error = %v, want qemu-img's message", err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	fakeQemuImg(t, `echo "$@" > `+argsFile+`
+printf '    (0.00/100%%)\r    (33.33/100%%)\r    (100.00/100%%)\r\n'`)
+
+	var reported []float64
+	err := Convert(context.Background(), "in.vmdk", "out.qcow2", ConvertOptions{SourceFormat: "vmdk", Format: "qcow2", Compress: true}, func(p float64) {
+		reported = append(reported, p)
+	})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if len(reported) != 3 || reported[1] != 33.33 || reported[2] != 100 {
+		t.Errorf("progress = %v, want 0, 33.33 and 100", reported)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if got := strings.TrimSpace(string(args)); got != "convert -p -O qcow2 -f vmdk -c in.vmdk out.qcow2" {
+		t.Errorf("qemu-img called with %q", got)
+	}
+
+	if err := Convert(context.Background(), "in", "out", ConvertOptions{Format: "raw", Compress: true}, nil); err == nil {
+		t.Error("Convert() of compressed raw output expected an error")
+	}
+	if err := Convert(context.Background(), "in", "out", ConvertOptions{Format: "vmdk"}, nil); err == nil {
+		t.Error("Convert() to vmdk expected an error")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -221,6 +222,24 @@ func (r *Registry) GetServer(id string) (*core.ServerConfig, error) {
 	return server, nil
 }
 
+// FindServer retrieves a server by ID, falling back to a case-insensitive name match
+func (r *Registry) FindServer(idOrName string) (*core.ServerConfig, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if server, exists := r.servers[idOrName]; exists {
+		return server, nil
+	}
+
+	for _, server := range r.servers {
+		if strings.EqualFold(server.Name, idOrName) {
+			return server, nil
+		}
+	}
+
+	return nil, fmt.Errorf("server not found: %s", idOrName)
+}
+
 // GetDefaultServer returns the default server
 func (r *Registry) GetDefaultServer() (*core.ServerConfig, error) {
 	r.mu.RLock()
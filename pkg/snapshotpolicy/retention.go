@@ -0,0 +1,90 @@
+package snapshotpolicy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// nameTimeLayout is the timestamp suffix of generated snapshot names
+const nameTimeLayout = "20060102-150405"
+
+// SnapshotName returns the name of the snapshot a policy takes at t
+func SnapshotName(prefix string, t time.Time) string {
+	return prefix + "-" + t.Format(nameTimeLayout)
+}
+
+// Prune returns the names of the snapshots retention does not keep, oldest first. Only
+// snapshots named by the policy (<prefix>-<timestamp>) are considered, so
+// manual snapshots and other policies' snapshots are never pruned.
+func Prune(snapshots []core.Snapshot, prefix string, retention core.SnapshotRetention) []string {
+	if retention.IsEmpty() {
+		return nil
+	}
+
+	owned := make([]core.Snapshot, 0, len(snapshots))
+	for _, snap := range snapshots {
+		if isPolicySnapshot(snap.Name, prefix) {
+			owned = append(owned, snap)
+		}
+	}
+
+	// Newest first; the generated names sort chronologically when timestamps tie
+	sort.Slice(owned, func(i, j int) bool {
+		if owned[i].CreationTS != owned[j].CreationTS {
+			return owned[i].CreationTS > owned[j].CreationTS
+		}
+		return owned[i].Name > owned[j].Name
+	})
+
+	keep := make(map[string]bool)
+	for i := 0; i < retention.KeepLast && i < len(owned); i++ {
+		keep[owned[i].Name] = true
+	}
+	keepPerBucket(owned, retention.KeepDaily, keep, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPerBucket(owned, retention.KeepWeekly, keep, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	// Oldest first, so a failed deletion leaves the newest snapshots behind
+	var pruned []string
+	for i := len(owned) - 1; i >= 0; i-- {
+		if !keep[owned[i].Name] {
+			pruned = append(pruned, owned[i].Name)
+		}
+	}
+	return pruned
+}
+
+// keepPerBucket keeps the newest snapshot of each of the newest n buckets.
+// snapshots must be sorted newest first.
+func keepPerBucket(snapshots []core.Snapshot, n int, keep map[string]bool, bucket func(time.Time) string) {
+	seen := make(map[string]bool)
+	for _, snap := range snapshots {
+		if len(seen) >= n {
+			return
+		}
+		b := bucket(time.Unix(snap.CreationTS, 0))
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		keep[snap.Name] = true
+	}
+}
+
+// isPolicySnapshot reports whether name was generated by SnapshotName for prefix
+func isPolicySnapshot(name, prefix string) bool {
+	suffix, ok := strings.CutPrefix(name, prefix+"-")
+	if !ok {
+		return false
+	}
+	_, err := time.Parse(nameTimeLayout, suffix)
+	return err == nil
+}
@@ -0,0 +1,62 @@
+package snapshotpolicy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+func TestPrune(t *testing.T) {
+	base := time.Date(2025, 3, 20, 2, 0, 0, 0, time.Local) // A Thursday
+
+	var snapshots []core.Snapshot
+	for day := 0; day < 21; day++ {
+		at := base.AddDate(0, 0, -day)
+		snapshots = append(snapshots, core.Snapshot{Name: SnapshotName("auto-nightly", at), CreationTS: at.Unix()})
+	}
+	// Two runs on the newest day; only the later one counts as that day's daily
+	extra := base.Add(time.Hour)
+	snapshots = append(snapshots,
+		core.Snapshot{Name: SnapshotName("auto-nightly", extra), CreationTS: extra.Unix()},
+		core.Snapshot{Name: "before-upgrade", CreationTS: base.AddDate(0, 0, -30).Unix()},
+		core.Snapshot{Name: SnapshotName("auto-hourly", base), CreationTS: base.Unix()},
+	)
+
+	pruned := Prune(snapshots, "auto-nightly", core.SnapshotRetention{KeepLast: 1, KeepDaily: 3, KeepWeekly: 3})
+
+	kept := make(map[string]bool)
+	for _, snap := range snapshots {
+		kept[snap.Name] = true
+	}
+	for _, name := range pruned {
+		delete(kept, name)
+	}
+
+	want := map[string]bool{
+		SnapshotName("auto-nightly", extra):                   true, // Last, daily and weekly
+		SnapshotName("auto-nightly", base.AddDate(0, 0, -1)):  true, // Daily
+		SnapshotName("auto-nightly", base.AddDate(0, 0, -2)):  true, // Daily
+		SnapshotName("auto-nightly", base.AddDate(0, 0, -4)):  true, // Weekly (Sunday of the previous week)
+		SnapshotName("auto-nightly", base.AddDate(0, 0, -11)): true, // Weekly
+		"before-upgrade":                  true, // Not the policy's
+		SnapshotName("auto-hourly", base): true, // Another policy's
+	}
+	for name := range want {
+		if !kept[name] {
+			t.Errorf("Prune() removed %s", name)
+		}
+	}
+	for name := range kept {
+		if !want[name] {
+			t.Errorf("Prune() kept %s", name)
+		}
+	}
+
+	if first := pruned[0]; first != SnapshotName("auto-nightly", base.AddDate(0, 0, -20)) {
+		t.Errorf("Prune() first = %s, want the oldest snapshot", first)
+	}
+	if got := Prune(snapshots, "auto-nightly", core.SnapshotRetention{}); got != nil {
+		t.Errorf("Prune() with empty retention = %v, want nil", got)
+	}
+}
@@ -0,0 +1,346 @@
+package snapshotpolicy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/cron"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// DefaultInterval is how often the scheduler checks for due policies
+const DefaultInterval = 30 * time.Second
+
+// Client is a connection whose VMs can be snapshotted (libvirtclient.ClientInterface)
+type Client interface {
+	GetVMSummaries() ([]core.VM_Summary, error)
+	GetVMSnapshots(uuid string) ([]core.Snapshot, error)
+	CreateVMSnapshot(uuid string, cfg core.CreateSnapshotRequest) (core.Snapshot, error)
+	DeleteVMSnapshot(uuid string, snapshotName string) error
+}
+
+// Target is one server a policy applies to
+type Target struct {
+	ServerID string // "" for the local connection
+	Client   Client
+}
+
+// TargetFunc resolves the servers a policy applies to. Servers that cannot be
+// reached are returned as errors so the run reports them.
+type TargetFunc func(policy core.SnapshotPolicy) ([]Target, []error)
+
+// RecordFunc receives an activity event for every run
+type RecordFunc func(core.ActivityEvent)
+
+// Scheduler runs enabled policies when their cron schedule is due
+type Scheduler struct {
+	store    *Store
+	interval time.Duration
+	targets  TargetFunc
+	record   RecordFunc
+	now      func() time.Time
+
+	mu      sync.Mutex
+	next    map[string]time.Time // policy ID -> next run
+	running map[string]bool      // policy ID -> a run is in progress
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler. Call Start to begin running policies.
+func NewScheduler(store *Store, interval time.Duration, targets TargetFunc, record RecordFunc) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Scheduler{
+		store:    store,
+		interval: interval,
+		targets:  targets,
+		record:   record,
+		now:      time.Now,
+		next:     make(map[string]time.Time),
+		running:  make(map[string]bool),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start checks for due policies in the background until Stop is called.
+// Runs missed while the server was down are not caught up.
+func (s *Scheduler) Start() {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.Tick()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.Tick()
+			}
+		}
+	}()
+}
+
+// Stop ends scheduling and waits for in-progress runs to finish
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	s.wg.Wait()
+}
+
+// Tick starts every enabled policy whose next run is due. Each policy runs in
+// its own goroutine, and a policy is never run twice at once.
+func (s *Scheduler) Tick() {
+	now := s.now()
+	active := make(map[string]bool)
+
+	for _, policy := range s.store.List() {
+		active[policy.ID] = true
+		if !policy.Enabled {
+			s.mu.Lock()
+			delete(s.next, policy.ID)
+			s.mu.Unlock()
+			continue
+		}
+
+		due, err := s.due(policy, now)
+		if err != nil {
+			logger.Warn("Invalid snapshot policy schedule", map[string]interface{}{
+				"policy": policy.Name,
+				"error":  err.Error(),
+			})
+			continue
+		}
+		if !due || !s.begin(policy.ID) {
+			continue
+		}
+
+		s.wg.Add(1)
+		go func(policy core.SnapshotPolicy) {
+			defer s.wg.Done()
+			defer s.end(policy.ID)
+			s.run(policy)
+		}(policy)
+	}
+
+	// Forget deleted policies
+	s.mu.Lock()
+	for id := range s.next {
+		if !active[id] {
+			delete(s.next, id)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// due reports whether a policy should run now and schedules its following run
+func (s *Scheduler) due(policy core.SnapshotPolicy, now time.Time) (bool, error) {
+	schedule, err := cron.Parse(policy.Schedule)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, known := s.next[policy.ID]
+	if !known {
+		// First sight of the policy (or of an edited schedule): wait for the next slot
+		s.next[policy.ID] = schedule.Next(now)
+		return false, nil
+	}
+	if next.IsZero() || now.Before(next) {
+		return false, nil
+	}
+	s.next[policy.ID] = schedule.Next(now)
+	return true, nil
+}
+
+// NextRun returns when a policy will next run, if it is scheduled
+func (s *Scheduler) NextRun(policyID string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, ok := s.next[policyID]
+	return next, ok && !next.IsZero()
+}
+
+// Reschedule drops a policy's pending run so the next tick computes it from
+// the policy's current schedule
+func (s *Scheduler) Reschedule(policyID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.next, policyID)
+}
+
+// RunNow runs a policy immediately, whether or not it is enabled, and returns the outcome
+func (s *Scheduler) RunNow(idOrName string) (core.SnapshotPolicyRun, error) {
+	policy, err := s.store.Get(idOrName)
+	if err != nil {
+		return core.SnapshotPolicyRun{}, err
+	}
+	if !s.begin(policy.ID) {
+		return core.SnapshotPolicyRun{}, fmt.Errorf("snapshot policy %s is already running", policy.Name)
+	}
+	defer s.end(policy.ID)
+
+	return s.run(*policy), nil
+}
+
+func (s *Scheduler) begin(policyID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[policyID] {
+		return false
+	}
+	s.running[policyID] = true
+	return true
+}
+
+func (s *Scheduler) end(policyID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, policyID)
+}
+
+// run snapshots every VM the policy selects, prunes its old snapshots and
+// records the outcome on the policy and in the activity log
+func (s *Scheduler) run(policy core.SnapshotPolicy) core.SnapshotPolicyRun {
+	run := core.SnapshotPolicyRun{
+		PolicyID:  policy.ID,
+		StartedAt: s.now(),
+		Created:   []string{},
+		Pruned:    []string{},
+	}
+	snapshotName := SnapshotName(policy.NamePrefix, run.StartedAt)
+
+	targets, errs := s.targets(policy)
+	for _, err := range errs {
+		run.Errors = append(run.Errors, err.Error())
+	}
+
+	matched := make(map[string]bool)
+	for _, target := range targets {
+		vms, err := target.Client.GetVMSummaries()
+		if err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: failed to list VMs: %v", serverLabel(target.ServerID), err))
+			continue
+		}
+
+		for _, vm := range vms {
+			if !selectsVM(policy.VMs, vm, matched) {
+				continue
+			}
+			s.snapshotVM(policy, target.Client, vm, snapshotName, &run)
+		}
+	}
+
+	for _, want := range policy.VMs {
+		if !matched[want] {
+			run.Errors = append(run.Errors, fmt.Sprintf("VM not found: %s", want))
+		}
+	}
+
+	run.Status = "Success"
+	if len(run.Errors) > 0 {
+		run.Status = "Error"
+	}
+
+	message := fmt.Sprintf("Created %d snapshot(s), pruned %d", len(run.Created), len(run.Pruned))
+	if len(run.Errors) > 0 {
+		message += "; " + strings.Join(run.Errors, "; ")
+	}
+
+	if err := s.store.RecordRun(run, message); err != nil {
+		logger.Warn("Failed to record snapshot policy run", map[string]interface{}{
+			"policy": policy.Name,
+			"error":  err.Error(),
+		})
+	}
+
+	if s.record != nil {
+		s.record(core.ActivityEvent{
+			Action:  "Snapshot Policy Run",
+			Target:  policy.Name,
+			Status:  run.Status,
+			Message: message,
+			Actor:   "system",
+			Params: map[string]interface{}{
+				"policy_id": policy.ID,
+				"created":   run.Created,
+				"pruned":    run.Pruned,
+			},
+		})
+	}
+
+	return run
+}
+
+// snapshotVM takes one snapshot of a VM and prunes the policy's older ones
+func (s *Scheduler) snapshotVM(policy core.SnapshotPolicy, client Client, vm core.VM_Summary, snapshotName string, run *core.SnapshotPolicyRun) {
+	req := core.CreateSnapshotRequest{
+		Name:        snapshotName,
+		Description: fmt.Sprintf("Taken by snapshot policy %s", policy.Name),
+		DiskOnly:    policy.DiskOnly,
+		Quiesce:     policy.Quiesce,
+	}
+	if _, err := client.CreateVMSnapshot(vm.UUID, req); err != nil {
+		run.Errors = append(run.Errors, fmt.Sprintf("%s: failed to create snapshot: %v", vm.Name, err))
+		// Still prune: retention counts only snapshots that exist
+	} else {
+		run.Created = append(run.Created, vm.Name+"/"+snapshotName)
+	}
+
+	if policy.Retention.IsEmpty() {
+		return
+	}
+
+	snapshots, err := client.GetVMSnapshots(vm.UUID)
+	if err != nil {
+		run.Errors = append(run.Errors, fmt.Sprintf("%s: failed to list snapshots: %v", vm.Name, err))
+		return
+	}
+	for _, name := range Prune(snapshots, policy.NamePrefix, policy.Retention) {
+		if err := client.DeleteVMSnapshot(vm.UUID, name); err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: failed to delete snapshot %s: %v", vm.Name, name, err))
+			continue
+		}
+		run.Pruned = append(run.Pruned, vm.Name+"/"+name)
+	}
+}
+
+// selectsVM reports whether a policy's VM list includes vm (an empty list
+// selects all), marking the selectors that matched
+func selectsVM(selectors []string, vm core.VM_Summary, matched map[string]bool) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	selected := false
+	for _, sel := range selectors {
+		if sel == vm.Name || strings.EqualFold(sel, vm.UUID) {
+			matched[sel] = true
+			selected = true
+		}
+	}
+	return selected
+}
+
+func serverLabel(serverID string) string {
+	if serverID == "" {
+		return "local"
+	}
+	return serverID
+}
@@ -0,0 +1,165 @@
+package snapshotpolicy
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+const testUUID = "550e8400-e29b-41d4-a716-446655440000"
+
+type fakeClient struct {
+	mu        sync.Mutex
+	vms       []core.VM_Summary
+	snapshots map[string][]core.Snapshot
+	failOn    string // VM UUID whose snapshots fail
+}
+
+func (f *fakeClient) GetVMSummaries() ([]core.VM_Summary, error) {
+	return f.vms, nil
+}
+
+func (f *fakeClient) GetVMSnapshots(uuid string) ([]core.Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]core.Snapshot(nil), f.snapshots[uuid]...), nil
+}
+
+func (f *fakeClient) CreateVMSnapshot(uuid string, cfg core.CreateSnapshotRequest) (core.Snapshot, error) {
+	if uuid == f.failOn {
+		return core.Snapshot{}, errors.New("domain is locked")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	snap := core.Snapshot{Name: cfg.Name, CreationTS: time.Now().Unix()}
+	f.snapshots[uuid] = append(f.snapshots[uuid], snap)
+	return snap, nil
+}
+
+func (f *fakeClient) DeleteVMSnapshot(uuid string, snapshotName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	kept := f.snapshots[uuid][:0]
+	for _, snap := range f.snapshots[uuid] {
+		if snap.Name != snapshotName {
+			kept = append(kept, snap)
+		}
+	}
+	f.snapshots[uuid] = kept
+	return nil
+}
+
+func newTestScheduler(t *testing.T, client *fakeClient, events *[]core.ActivityEvent) (*Scheduler, *Store) {
+	t.Helper()
+	store, err := NewStore(filepath.Join(t.TempDir(), "snapshot-policies.json"))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	targets := func(policy core.SnapshotPolicy) ([]Target, []error) {
+		return []Target{{Client: client}}, nil
+	}
+	var mu sync.Mutex
+	record := func(event core.ActivityEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		*events = append(*events, event)
+	}
+	return NewScheduler(store, time.Second, targets, record), store
+}
+
+func TestScheduler_RunNowCreatesAndPrunes(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	client := &fakeClient{
+		vms: []core.VM_Summary{{Name: "web-01", UUID: testUUID}, {Name: "db-01", UUID: "other"}},
+		snapshots: map[string][]core.Snapshot{
+			testUUID: {
+				{Name: SnapshotName("auto-web", old), CreationTS: old.Unix()},
+				{Name: "manual", CreationTS: old.Unix()},
+			},
+		},
+	}
+	var events []core.ActivityEvent
+	scheduler, store := newTestScheduler(t, client, &events)
+
+	if _, err := store.Add(core.SnapshotPolicyRequest{
+		Name:      "web",
+		Schedule:  "@daily",
+		VMs:       []string{"web-01", "missing"},
+		Retention: core.SnapshotRetention{KeepLast: 1},
+	}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	run, err := scheduler.RunNow("web")
+	if err != nil {
+		t.Fatalf("RunNow() error = %v", err)
+	}
+
+	if len(run.Created) != 1 || len(run.Pruned) != 1 || run.Pruned[0] != "web-01/"+SnapshotName("auto-web", old) {
+		t.Errorf("RunNow() = %+v, want one created and the old snapshot pruned", run)
+	}
+	if run.Status != "Error" || len(run.Errors) != 1 {
+		t.Errorf("RunNow() status = %s errors = %v, want the missing VM reported", run.Status, run.Errors)
+	}
+	if snaps := client.snapshots[testUUID]; len(snaps) != 2 || snaps[0].Name != "manual" {
+		t.Errorf("snapshots after run = %+v, want manual plus the new one", snaps)
+	}
+	if _, ok := client.snapshots["other"]; ok {
+		t.Error("RunNow() snapshotted a VM outside the policy")
+	}
+
+	if len(events) != 1 || events[0].Action != "Snapshot Policy Run" || events[0].Target != "web" || events[0].Status != "Error" {
+		t.Errorf("activity = %+v", events)
+	}
+	policy, _ := store.Get("web")
+	if policy.LastRun == nil || policy.LastStatus != "Error" {
+		t.Errorf("policy after run = %+v", policy)
+	}
+}
+
+func TestScheduler_TickRunsDuePolicies(t *testing.T) {
+	client := &fakeClient{
+		vms:       []core.VM_Summary{{Name: "web-01", UUID: testUUID}},
+		snapshots: map[string][]core.Snapshot{},
+	}
+	var events []core.ActivityEvent
+	scheduler, store := newTestScheduler(t, client, &events)
+	if _, err := store.Add(core.SnapshotPolicyRequest{Name: "hourly", Schedule: "@hourly"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	now := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	scheduler.now = func() time.Time { return now }
+
+	// The first tick only schedules the policy
+	scheduler.Tick()
+	scheduler.wg.Wait()
+	if len(events) != 0 {
+		t.Fatalf("first Tick() ran the policy")
+	}
+	policy, _ := store.Get("hourly")
+	if next, ok := scheduler.NextRun(policy.ID); !ok || !next.Equal(time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("NextRun() = %v, %v", next, ok)
+	}
+
+	now = now.Add(29 * time.Minute)
+	scheduler.Tick()
+	scheduler.wg.Wait()
+	if len(events) != 0 {
+		t.Fatalf("Tick() before the slot ran the policy")
+	}
+
+	now = now.Add(time.Minute)
+	scheduler.Tick()
+	scheduler.wg.Wait()
+	if len(events) != 1 || events[0].Status != "Success" {
+		t.Fatalf("Tick() at the slot: activity = %+v", events)
+	}
+	if snaps := client.snapshots[testUUID]; len(snaps) != 1 || snaps[0].Name != "auto-hourly-20250115-110000" {
+		t.Errorf("snapshots = %+v", snaps)
+	}
+}
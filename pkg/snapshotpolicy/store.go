@@ -0,0 +1,296 @@
+// Package snapshotpolicy stores snapshot schedules and runs them, pruning the
+// snapshots each policy took earlier according to its retention rules.
+package snapshotpolicy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/cron"
+)
+
+// ErrPolicyNotFound is returned for unknown policy IDs or names
+var ErrPolicyNotFound = errors.New("snapshot policy not found")
+
+// Store persists snapshot policies
+type Store struct {
+	policies    map[string]*core.SnapshotPolicy
+	mu          sync.RWMutex
+	storagePath string
+}
+
+// NewStore creates a new snapshot policy store
+func NewStore(storagePath string) (*Store, error) {
+	if storagePath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		storagePath = filepath.Join(homeDir, ".flint", "snapshot-policies.json")
+	}
+
+	store := &Store{
+		policies:    make(map[string]*core.SnapshotPolicy),
+		storagePath: storagePath,
+	}
+
+	if err := store.load(); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load snapshot policy store: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// Add creates a policy from a request, assigning its ID and creation time
+func (s *Store) Add(req core.SnapshotPolicyRequest) (*core.SnapshotPolicy, error) {
+	policy, err := policyFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findByName(policy.Name) != nil {
+		return nil, fmt.Errorf("snapshot policy already exists: %s", policy.Name)
+	}
+
+	policy.ID = uuid.New().String()
+	policy.CreatedAt = time.Now()
+	s.policies[policy.ID] = policy
+
+	if err := s.save(); err != nil {
+		delete(s.policies, policy.ID)
+		return nil, fmt.Errorf("failed to save snapshot policy store: %w", err)
+	}
+
+	policyCopy := *policy
+	return &policyCopy, nil
+}
+
+// Update replaces a policy's settings, keeping its ID, creation time and run history
+func (s *Store) Update(idOrName string, req core.SnapshotPolicyRequest) (*core.SnapshotPolicy, error) {
+	updated, err := policyFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.find(idOrName)
+	if existing == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, idOrName)
+	}
+	if other := s.findByName(updated.Name); other != nil && other.ID != existing.ID {
+		return nil, fmt.Errorf("snapshot policy already exists: %s", updated.Name)
+	}
+
+	previous := *existing
+	updated.ID = existing.ID
+	updated.CreatedAt = existing.CreatedAt
+	updated.LastRun = existing.LastRun
+	updated.LastStatus = existing.LastStatus
+	updated.LastMessage = existing.LastMessage
+	s.policies[existing.ID] = updated
+
+	if err := s.save(); err != nil {
+		s.policies[existing.ID] = &previous
+		return nil, fmt.Errorf("failed to save snapshot policy store: %w", err)
+	}
+
+	policyCopy := *updated
+	return &policyCopy, nil
+}
+
+// Get retrieves a policy by ID or name
+func (s *Store) Get(idOrName string) (*core.SnapshotPolicy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	policy := s.find(idOrName)
+	if policy == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, idOrName)
+	}
+
+	policyCopy := *policy
+	return &policyCopy, nil
+}
+
+// List returns all policies sorted by name
+func (s *Store) List() []core.SnapshotPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	policies := make([]core.SnapshotPolicy, 0, len(s.policies))
+	for _, policy := range s.policies {
+		policies = append(policies, *policy)
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+
+	return policies
+}
+
+// Delete removes a policy. Snapshots it already took are left untouched.
+func (s *Store) Delete(idOrName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy := s.find(idOrName)
+	if policy == nil {
+		return fmt.Errorf("%w: %s", ErrPolicyNotFound, idOrName)
+	}
+
+	delete(s.policies, policy.ID)
+
+	if err := s.save(); err != nil {
+		return fmt.Errorf("failed to save snapshot policy store: %w", err)
+	}
+
+	return nil
+}
+
+// RecordRun stores the outcome of a run on its policy
+func (s *Store) RecordRun(run core.SnapshotPolicyRun, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy, exists := s.policies[run.PolicyID]
+	if !exists {
+		// Deleted while it ran
+		return nil
+	}
+
+	startedAt := run.StartedAt
+	policy.LastRun = &startedAt
+	policy.LastStatus = run.Status
+	policy.LastMessage = message
+
+	if err := s.save(); err != nil {
+		return fmt.Errorf("failed to save snapshot policy store: %w", err)
+	}
+
+	return nil
+}
+
+// find looks a policy up by ID, then by name. Callers hold the lock.
+func (s *Store) find(idOrName string) *core.SnapshotPolicy {
+	if policy, exists := s.policies[idOrName]; exists {
+		return policy
+	}
+	return s.findByName(idOrName)
+}
+
+func (s *Store) findByName(name string) *core.SnapshotPolicy {
+	for _, policy := range s.policies {
+		if policy.Name == name {
+			return policy
+		}
+	}
+	return nil
+}
+
+// policyFromRequest validates a request and fills in defaults
+func policyFromRequest(req core.SnapshotPolicyRequest) (*core.SnapshotPolicy, error) {
+	name := strings.TrimSpace(req.Name)
+	switch {
+	case name == "":
+		return nil, errors.New("policy name is required")
+	case req.ServerID != "" && len(req.Tags) > 0:
+		return nil, errors.New("server_id and tags are mutually exclusive")
+	case req.Quiesce && !req.DiskOnly:
+		return nil, errors.New("quiesce requires disk_only")
+	case req.Retention.KeepLast < 0 || req.Retention.KeepDaily < 0 || req.Retention.KeepWeekly < 0:
+		return nil, errors.New("retention counts must not be negative")
+	}
+	if _, err := cron.Parse(req.Schedule); err != nil {
+		return nil, err
+	}
+
+	prefix := strings.TrimSpace(req.NamePrefix)
+	if prefix == "" {
+		prefix = "auto-" + name
+	}
+	if strings.ContainsAny(prefix, "/\x00 ") {
+		return nil, errors.New("name_prefix must not contain '/' or spaces")
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	return &core.SnapshotPolicy{
+		Name:       name,
+		Enabled:    enabled,
+		Schedule:   strings.TrimSpace(req.Schedule),
+		ServerID:   req.ServerID,
+		Tags:       req.Tags,
+		VMs:        req.VMs,
+		DiskOnly:   req.DiskOnly,
+		Quiesce:    req.Quiesce,
+		NamePrefix: prefix,
+		Retention:  req.Retention,
+	}, nil
+}
+
+// load reads policies from storage
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.storagePath)
+	if err != nil {
+		return err
+	}
+
+	var stored struct {
+		Policies map[string]*core.SnapshotPolicy `json:"policies"`
+	}
+
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to unmarshal snapshot policy store: %w", err)
+	}
+
+	if stored.Policies != nil {
+		s.policies = stored.Policies
+	}
+
+	return nil
+}
+
+// save writes policies to storage
+func (s *Store) save() error {
+	dir := filepath.Dir(s.storagePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	stored := struct {
+		Policies map[string]*core.SnapshotPolicy `json:"policies"`
+	}{
+		Policies: s.policies,
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot policy store: %w", err)
+	}
+
+	if err := os.WriteFile(s.storagePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot policy store: %w", err)
+	}
+
+	return nil
+}
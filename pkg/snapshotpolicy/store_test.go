@@ -0,0 +1,82 @@
+package snapshotpolicy
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+func TestStore_AddUpdatePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot-policies.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	policy, err := store.Add(core.SnapshotPolicyRequest{Name: "nightly", Schedule: "@daily", Retention: core.SnapshotRetention{KeepDaily: 7}})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if !policy.Enabled || policy.NamePrefix != "auto-nightly" {
+		t.Errorf("Add() = %+v, want enabled with prefix auto-nightly", policy)
+	}
+	if _, err := store.Add(core.SnapshotPolicyRequest{Name: "nightly", Schedule: "@daily"}); err == nil {
+		t.Error("Add() with a duplicate name expected an error")
+	}
+
+	disabled := false
+	updated, err := store.Update("nightly", core.SnapshotPolicyRequest{Name: "nightly", Schedule: "0 3 * * *", Enabled: &disabled})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.ID != policy.ID || updated.Enabled || updated.Schedule != "0 3 * * *" {
+		t.Errorf("Update() = %+v", updated)
+	}
+
+	if err := store.RecordRun(core.SnapshotPolicyRun{PolicyID: policy.ID, StartedAt: time.Now(), Status: "Success"}, "Created 1 snapshot(s), pruned 0"); err != nil {
+		t.Fatalf("RecordRun() error = %v", err)
+	}
+
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() reopen error = %v", err)
+	}
+	got, err := reopened.Get(policy.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.LastRun == nil || got.LastStatus != "Success" || got.Schedule != "0 3 * * *" {
+		t.Errorf("reopened policy = %+v", got)
+	}
+
+	if err := reopened.Delete("nightly"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := reopened.Get("nightly"); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrPolicyNotFound", err)
+	}
+}
+
+func TestStore_AddValidates(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "snapshot-policies.json"))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	invalid := []core.SnapshotPolicyRequest{
+		{Schedule: "@daily"},
+		{Name: "bad-cron", Schedule: "every day"},
+		{Name: "both", Schedule: "@daily", ServerID: "hv-01", Tags: []string{"prod"}},
+		{Name: "quiesce", Schedule: "@daily", Quiesce: true},
+		{Name: "negative", Schedule: "@daily", Retention: core.SnapshotRetention{KeepLast: -1}},
+		{Name: "prefix", Schedule: "@daily", NamePrefix: "a/b"},
+	}
+	for _, req := range invalid {
+		if _, err := store.Add(req); err == nil {
+			t.Errorf("Add(%+v) expected an error", req)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// Store persists VM template metadata
+type Store struct {
+	templates   map[string]*core.VMTemplate
+	mu          sync.RWMutex
+	storagePath string
+}
+
+// NewStore creates a new template store
+func NewStore(storagePath string) (*Store, error) {
+	if storagePath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		storagePath = filepath.Join(homeDir, ".flint", "templates.json")
+	}
+
+	store := &Store{
+		templates:   make(map[string]*core.VMTemplate),
+		storagePath: storagePath,
+	}
+
+	if err := store.load(); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load template store: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// Add registers a new template, assigning its ID and creation time
+func (s *Store) Add(tmpl core.VMTemplate) (*core.VMTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.templates {
+		if existing.Name == tmpl.Name && existing.ServerID == tmpl.ServerID {
+			return nil, fmt.Errorf("template already exists: %s", tmpl.Name)
+		}
+	}
+
+	tmpl.ID = uuid.New().String()
+	tmpl.CreatedAt = time.Now()
+	s.templates[tmpl.ID] = &tmpl
+
+	if err := s.save(); err != nil {
+		delete(s.templates, tmpl.ID)
+		return nil, fmt.Errorf("failed to save template store: %w", err)
+	}
+
+	return &tmpl, nil
+}
+
+// Get retrieves a template by ID
+func (s *Store) Get(id string) (*core.VMTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tmpl, exists := s.templates[id]
+	if !exists {
+		return nil, fmt.Errorf("template not found: %s", id)
+	}
+
+	tmplCopy := *tmpl
+	return &tmplCopy, nil
+}
+
+// List returns the templates registered for any of the given servers ("" for
+// the local host), newest first
+func (s *Store) List(serverIDs ...string) []core.VMTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	templates := make([]core.VMTemplate, 0, len(s.templates))
+	for _, tmpl := range s.templates {
+		if slices.Contains(serverIDs, tmpl.ServerID) {
+			templates = append(templates, *tmpl)
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].CreatedAt.After(templates[j].CreatedAt)
+	})
+
+	return templates
+}
+
+// Delete removes a template. The source VM is left untouched.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.templates[id]; !exists {
+		return fmt.Errorf("template not found: %s", id)
+	}
+
+	delete(s.templates, id)
+
+	if err := s.save(); err != nil {
+		return fmt.Errorf("failed to save template store: %w", err)
+	}
+
+	return nil
+}
+
+// MarkUsed records that a template was just used to create a VM
+func (s *Store) MarkUsed(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpl, exists := s.templates[id]
+	if !exists {
+		return fmt.Errorf("template not found: %s", id)
+	}
+
+	now := time.Now()
+	tmpl.LastUsed = &now
+
+	if err := s.save(); err != nil {
+		return fmt.Errorf("failed to save template store: %w", err)
+	}
+
+	return nil
+}
+
+// load reads templates from storage
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.storagePath)
+	if err != nil {
+		return err
+	}
+
+	var stored struct {
+		Templates map[string]*core.VMTemplate `json:"templates"`
+	}
+
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to unmarshal template store: %w", err)
+	}
+
+	if stored.Templates != nil {
+		s.templates = stored.Templates
+	}
+
+	return nil
+}
+
+// save writes templates to storage
+func (s *Store) save() error {
+	dir := filepath.Dir(s.storagePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	stored := struct {
+		Templates map[string]*core.VMTemplate `json:"templates"`
+	}{
+		Templates: s.templates,
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal template store: %w", err)
+	}
+
+	if err := os.WriteFile(s.storagePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write template store: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package templates
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/core"
+)
+
+func TestStore_AddListPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	base, err := store.Add(core.VMTemplate{Name: "base", SourceUUID: "vm-1", SourceVM: "web-01", VCPUs: 2, MemoryMB: 2048})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if base.ID == "" || base.CreatedAt.IsZero() {
+		t.Errorf("Add() = %+v, want an ID and creation time", base)
+	}
+	if _, err := store.Add(core.VMTemplate{Name: "base", SourceUUID: "vm-2"}); err == nil {
+		t.Error("Add() with a duplicate name on the same server expected an error")
+	}
+	remote, err := store.Add(core.VMTemplate{Name: "base", ServerID: "hv-01", SourceUUID: "vm-3"})
+	if err != nil {
+		t.Fatalf("Add() of the same name on another server error = %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	newer, err := store.Add(core.VMTemplate{Name: "db", SourceUUID: "vm-4"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if err := store.MarkUsed(base.ID); err != nil {
+		t.Fatalf("MarkUsed() error = %v", err)
+	}
+
+	reopened, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() reopen error = %v", err)
+	}
+	got, err := reopened.Get(base.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.SourceVM != "web-01" || got.MemoryMB != 2048 || got.LastUsed == nil {
+		t.Errorf("reopened template = %+v", got)
+	}
+
+	local := reopened.List("")
+	if len(local) != 2 || local[0].ID != newer.ID || local[1].ID != base.ID {
+		t.Errorf("List(\"\") = %+v, want db then base", local)
+	}
+	if list := reopened.List("hv-01"); len(list) != 1 || list[0].ID != remote.ID {
+		t.Errorf("List(hv-01) = %+v", list)
+	}
+	if list := reopened.List("", "hv-01"); len(list) != 3 {
+		t.Errorf("List(\"\", hv-01) returned %d templates, want 3", len(list))
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "templates.json"))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	tmpl, err := store.Add(core.VMTemplate{Name: "base", SourceUUID: "vm-1"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if err := store.Delete(tmpl.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get(tmpl.ID); err == nil {
+		t.Error("Get() after Delete() expected an error")
+	}
+	if err := store.Delete(tmpl.ID); err == nil {
+		t.Error("Delete() of a missing template expected an error")
+	}
+	if err := store.MarkUsed(tmpl.ID); err == nil {
+		t.Error("MarkUsed() of a missing template expected an error")
+	}
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/activity"
+	"github.com/volantvm/flint/pkg/config"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// Actors recorded in the audit log
+const (
+	actorAPIKey  = "api-key"
+	actorCLI     = "cli"
+	actorSession = "session"
+)
+
+// cliUserAgent is sent by the flint CLI so its requests are attributed to "cli"
+const cliUserAgent = "flint-cli"
+
+// maxAuditBodyBytes caps how much of a JSON request body is captured as parameters
+const maxAuditBodyBytes = 64 * 1024
+
+// auditTargetParams are the URL parameters that name the target of a request, in priority order
+var auditTargetParams = []string{"snapshotName", "backupId", "file", "uploadId", "volumeName", "poolName", "networkName", "name", "templateId", "imageId", "jobId", "policyId", "tokenId", "userName", "serverID"}
+
+// initAuditStore opens the persistent audit log configured in config.Config and
+// routes activity from every libvirt connection into it
+func (s *Server) initAuditStore(cfg *config.Config) {
+	store, err := activity.NewStore(cfg.Audit.Path, activity.Retention{
+		MaxAge:    time.Duration(cfg.Audit.RetentionDays) * 24 * time.Hour,
+		MaxEvents: cfg.Audit.MaxEvents,
+	})
+	if err != nil {
+		logger.Error("Failed to open audit log, activity will not be persisted", map[string]interface{}{
+			"path":  cfg.Audit.Path,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	s.auditStore = store
+	activity.SetStore(store)
+}
+
+// actorFor returns who made an authenticated request
+func actorFor(r *http.Request) string {
+	if actor, ok := r.Context().Value(actorKey).(string); ok {
+		return actor
+	}
+	return ""
+}
+
+// withActor records who authenticated the request
+func withActor(r *http.Request, actor string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), actorKey, actor))
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Flush passes through so streaming handlers (SSE) keep working behind the recorder
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through so websocket upgrades keep working behind the recorder
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// auditMiddleware records every state-changing API request in the audit log:
+// who made it, the route, its target, its parameters (secrets redacted) and the result
+func (s *Server) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auditStore == nil || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body := captureJSONBody(r)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// Route parameters are only known once chi has finished routing
+		params := map[string]interface{}{}
+		event := core.ActivityEvent{
+			Action:     r.Method + " " + routePattern(r),
+			Actor:      actorFor(r),
+			RemoteAddr: s.getClientIP(r),
+			ServerID:   s.auditServerID(r),
+			Status:     "Success",
+			Message:    fmt.Sprintf("HTTP %d", rec.status),
+		}
+		if rec.status >= http.StatusBadRequest {
+			event.Status = "Error"
+		}
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			for i, key := range rctx.URLParams.Keys {
+				if key == "*" || i >= len(rctx.URLParams.Values) {
+					continue
+				}
+				params[key] = rctx.URLParams.Values[i]
+			}
+		}
+		for key, values := range r.URL.Query() {
+			params[key] = strings.Join(values, ",")
+		}
+		if body != nil {
+			params["body"] = redactSecrets(body)
+		}
+		if len(params) > 0 {
+			event.Params = params
+		}
+
+		event.TargetUUID = chi.URLParam(r, "uuid")
+		for _, key := range auditTargetParams {
+			if v := chi.URLParam(r, key); v != "" {
+				event.Target = v
+				break
+			}
+		}
+		if bodyMap, ok := body.(map[string]interface{}); ok && event.Target == "" {
+			if name, ok := bodyMap["name"].(string); ok {
+				event.Target = name
+			}
+		}
+		if event.Target == "" {
+			event.Target = event.TargetUUID
+		}
+
+		if err := s.auditStore.Record(event); err != nil {
+			logger.Warn("Failed to record audit event", map[string]interface{}{
+				"action": event.Action,
+				"error":  err.Error(),
+			})
+		}
+	})
+}
+
+// routePattern returns the matched chi route (e.g. /api/vms/{uuid}/action), or the raw path
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return strings.Replace(pattern, "/*", "", -1)
+		}
+	}
+	return r.URL.Path
+}
+
+// auditServerID resolves the registered server a request targeted, if any
+func (s *Server) auditServerID(r *http.Request) string {
+	selector := chi.URLParam(r, "serverID")
+	if selector == "" {
+		selector = r.Header.Get(ServerHeader)
+	}
+	if selector == "" || s.serverRegistry == nil {
+		return selector
+	}
+	if server, err := s.serverRegistry.FindServer(selector); err == nil {
+		return server.ID
+	}
+	return selector
+}
+
+// captureJSONBody decodes a small JSON request body for the audit log and puts
+// the bytes back so the handler can still read them
+func captureJSONBody(r *http.Request) interface{} {
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxAuditBodyBytes+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil || len(buf) == 0 || len(buf) > maxAuditBodyBytes {
+		return nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(buf, &body); err != nil {
+		return nil
+	}
+	return body
+}
+
+// redactSecrets replaces values whose keys look like credentials
+func redactSecrets(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, inner := range value {
+			if isSecretKey(k) {
+				out[k] = "[REDACTED]"
+			} else {
+				out[k] = redactSecrets(inner)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, inner := range value {
+			out[i] = redactSecrets(inner)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// isSecretKey reports whether a parameter name likely holds a credential
+func isSecretKey(key string) bool {
+	k := strings.ToLower(key)
+	for _, marker := range []string{"password", "passphrase", "secret", "token", "key", "userdata", "user_data"} {
+		if strings.Contains(k, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Activity pagination bounds
+const (
+	defaultActivityLimit = 50
+	maxActivityLimit     = 1000
+)
+
+// parseActivityQuery builds an audit query from /api/activity query parameters
+func parseActivityQuery(r *http.Request) (activity.Query, error) {
+	q := r.URL.Query()
+	query := activity.Query{
+		Target:   q.Get("target"),
+		Action:   q.Get("action"),
+		Status:   q.Get("status"),
+		Actor:    q.Get("actor"),
+		ServerID: q.Get("server"),
+		Limit:    defaultActivityLimit,
+	}
+
+	var err error
+	if query.Since, err = parseActivityTime(q.Get("since")); err != nil {
+		return query, fmt.Errorf("invalid since: %w", err)
+	}
+	if query.Until, err = parseActivityTime(q.Get("until")); err != nil {
+		return query, fmt.Errorf("invalid until: %w", err)
+	}
+
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return query, fmt.Errorf("limit must be a positive integer")
+		}
+		if limit > maxActivityLimit {
+			limit = maxActivityLimit
+		}
+		query.Limit = limit
+	}
+	if raw := q.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return query, fmt.Errorf("offset must be a non-negative integer")
+		}
+		query.Offset = offset
+	}
+
+	return query, nil
+}
+
+// parseActivityTime accepts RFC 3339, Unix seconds, or a duration meaning "that long ago" (e.g. 24h)
+func parseActivityTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("expected RFC 3339 time, Unix seconds or a duration such as 24h")
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/activity"
+	"github.com/volantvm/flint/pkg/auth"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/serverregistry"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	store, err := activity.NewStore(filepath.Join(t.TempDir(), "activity.jsonl"), activity.Retention{})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	defer store.Close()
+
+	s := &Server{auditStore: store}
+	router := chi.NewRouter()
+	router.Use(s.auditMiddleware)
+
+	var handlerBody map[string]interface{}
+	router.Post("/api/vms/{uuid}/action", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&handlerBody)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	router.Get("/api/vms", func(w http.ResponseWriter, r *http.Request) {})
+
+	uuid := "550e8400-e29b-41d4-a716-446655440000"
+	req := httptest.NewRequest(http.MethodPost, "/api/vms/"+uuid+"/action?force=true",
+		strings.NewReader(`{"action": "stop", "cloudInit": {"password": "hunter2"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), withActor(req, actorCLI))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/vms", nil))
+
+	if handlerBody["action"] != "stop" {
+		t.Errorf("Handler should still see the request body, got %v", handlerBody)
+	}
+
+	events, total := store.Query(activity.Query{})
+	if total != 1 {
+		t.Fatalf("Expected only the POST to be audited, got %d events", total)
+	}
+
+	event := events[0]
+	if event.Action != "POST /api/vms/{uuid}/action" {
+		t.Errorf("Unexpected action %q", event.Action)
+	}
+	if event.Actor != actorCLI || event.TargetUUID != uuid || event.Status != "Success" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if event.Params["force"] != "true" {
+		t.Errorf("Expected query parameters to be recorded, got %v", event.Params)
+	}
+
+	data, _ := json.Marshal(event.Params)
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("Password leaked into the audit log: %s", data)
+	}
+}
+
+func TestParseActivityQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"defaults", "", false},
+		{"filters", "target=web-01&action=started&status=Success&limit=10&offset=20", false},
+		{"since RFC 3339", "since=2025-01-02T15:04:05Z", false},
+		{"since duration", "since=24h", false},
+		{"since unix", "since=1735830245", false},
+		{"bad since", "since=yesterday", true},
+		{"bad limit", "limit=0", true},
+		{"bad offset", "offset=-1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/activity?"+tt.query, nil)
+			_, err := parseActivityQuery(req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseActivityQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleGetActivity_Scoping(t *testing.T) {
+	store, err := activity.NewStore(filepath.Join(t.TempDir(), "activity.jsonl"), activity.Retention{})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	defer store.Close()
+	registry, err := serverregistry.NewRegistry(filepath.Join(t.TempDir(), "servers.json"))
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	hostA, err := registry.AddServer(core.CreateServerRequest{Name: "host-a", URI: "qemu+ssh://root@host-a/system"})
+	if err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+	hostB, err := registry.AddServer(core.CreateServerRequest{Name: "host-b", URI: "qemu+ssh://root@host-b/system"})
+	if err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+
+	store.Record(core.ActivityEvent{Action: "VM Started", Target: "local-vm"})
+	store.Record(core.ActivityEvent{Action: "VM Started", Target: "a-vm", ServerID: hostA.ID})
+	store.Record(core.ActivityEvent{Action: "VM Started", Target: "b-vm", ServerID: hostB.ID})
+	s := &Server{auditStore: store, serverRegistry: registry}
+
+	scopedToA := auth.Identity{User: "sam", Role: core.RoleViewer, Scope: core.AccessScope{Servers: []string{"host-a"}}}
+	tests := []struct {
+		name       string
+		identity   auth.Identity
+		serverID   string // Host-routed request
+		query      string
+		wantStatus int
+		wantTotal  string
+	}{
+		{"unscoped sees everything", adminIdentity("admin"), "", "", http.StatusOK, "3"},
+		{"unscoped filters by server", adminIdentity("admin"), "", "server=" + hostB.ID, http.StatusOK, "1"},
+		{"host route ignores server filter", adminIdentity("admin"), hostA.ID, "server=" + hostB.ID, http.StatusOK, "1"},
+		{"scoped token sees its servers", scopedToA, "", "", http.StatusOK, "1"},
+		{"scoped token cannot filter outside its scope", scopedToA, "", "server=" + hostB.ID, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/activity?"+tt.query, nil)
+			req = withIdentity(req, tt.identity)
+			if tt.serverID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), serverIDKey, tt.serverID))
+			}
+			rec := httptest.NewRecorder()
+			s.handleGetActivity().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := rec.Header().Get("X-Total-Count"); got != tt.wantTotal {
+				t.Errorf("X-Total-Count = %q, want %q", got, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -2,12 +2,17 @@ package server
 
 import (
 	"bufio"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/download"
 	"github.com/volantvm/flint/pkg/imagerepository"
+	"github.com/volantvm/flint/pkg/jobs"
+	"github.com/volantvm/flint/pkg/libvirtclient"
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 	"io"
@@ -17,6 +22,7 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -27,11 +33,35 @@ import (
 
 func (s *Server) handleGetVMs() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vms, err := s.client.GetVMSummaries()
+		// ?label=env=prod&label=tier selects VMs with all the given labels
+		selector, err := core.ParseLabelSelector(strings.Join(r.URL.Query()["label"], ","))
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Tokens scoped to VM labels only see the VMs they match
+		scope, err := identityFor(r).Scope.VMSelector()
+		if err != nil {
+			sendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		selector = append(selector, scope...)
+
+		vms, err := s.clientFor(r).GetVMSummaries()
 		if err != nil {
 			sendInternalError(w, err)
 			return
 		}
+		if len(selector) > 0 {
+			matching := make([]core.VM_Summary, 0, len(vms))
+			for _, vm := range vms {
+				if selector.Matches(vm.Labels) {
+					matching = append(matching, vm)
+				}
+			}
+			vms = matching
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(vms)
 	}
@@ -45,7 +75,7 @@ func (s *Server) handleGetVMDetails() http.HandlerFunc {
 			return
 		}
 
-		vm, err := s.client.GetVMDetails(uuid)
+		vm, err := s.clientFor(r).GetVMDetails(uuid)
 		if err != nil {
 			// Check if it's a "domain not found" error
 			if strings.Contains(err.Error(), "lookup domain") {
@@ -62,7 +92,7 @@ func (s *Server) handleGetVMDetails() http.HandlerFunc {
 func (s *Server) handleGetVMSnapshots() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uuid := chi.URLParam(r, "uuid")
-		snapshots, err := s.client.GetVMSnapshots(uuid)
+		snapshots, err := s.clientFor(r).GetVMSnapshots(uuid)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -71,6 +101,22 @@ func (s *Server) handleGetVMSnapshots() http.HandlerFunc {
 	}
 }
 
+// handleGetVMSnapshotTree returns a VM's snapshots arranged by parent, with the
+// current snapshot and the overlay files of external snapshots
+func (s *Server) handleGetVMSnapshotTree() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		snapshots, err := s.clientFor(r).GetVMSnapshots(uuid)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(core.BuildSnapshotTree(snapshots))
+	}
+}
+
 func (s *Server) handleCreateVMSnapshot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uuid := chi.URLParam(r, "uuid")
@@ -79,15 +125,35 @@ func (s *Server) handleCreateVMSnapshot() http.HandlerFunc {
 			http.Error(w, `{"error": "Invalid JSON in request body"}`, http.StatusBadRequest)
 			return
 		}
+		if err := req.Validate(); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		snapshot, err := s.client.CreateVMSnapshot(uuid, req)
-		if err != nil {
-			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusInternalServerError)
+		client := s.clientFor(r)
+		job, ok := s.runJob(w, r, "vm.snapshot", uuid, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			// A job cancelled while queued never reaches libvirt
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			p.Update(0, "Creating snapshot")
+			snapshot, err := client.CreateVMSnapshot(uuid, req)
+			if err != nil {
+				return nil, err
+			}
+			p.Update(100, "Snapshot created")
+			return snapshot, nil
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			http.Error(w, `{"error": "`+job.Error+`"}`, http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(snapshot)
+		json.NewEncoder(w).Encode(job.Result)
 	}
 }
 
@@ -96,7 +162,7 @@ func (s *Server) handleDeleteVMSnapshot() http.HandlerFunc {
 		uuid := chi.URLParam(r, "uuid")
 		snapshotName := chi.URLParam(r, "snapshotName")
 
-		err := s.client.DeleteVMSnapshot(uuid, snapshotName)
+		err := s.clientFor(r).DeleteVMSnapshot(uuid, snapshotName)
 		if err != nil {
 			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusInternalServerError)
 			return
@@ -106,10 +172,14 @@ func (s *Server) handleDeleteVMSnapshot() http.HandlerFunc {
 	}
 }
 
-// handleGetRepositoryImages returns all available cloud images from the repository
+// handleGetRepositoryImages returns all available cloud images from the
+// repository and its catalogs, optionally only those for ?arch=
 func (s *Server) handleGetRepositoryImages() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		images := s.imageRepo.GetImages()
+		if arch := r.URL.Query().Get("arch"); arch != "" {
+			images = s.imageRepo.GetImagesByArchitecture(arch)
+		}
 		
 		// Add download status to each image
 		type ImageWithStatus struct {
@@ -117,7 +187,7 @@ func (s *Server) handleGetRepositoryImages() http.HandlerFunc {
 			Downloaded bool `json:"downloaded"`
 		}
 		
-		var imagesWithStatus []ImageWithStatus
+		imagesWithStatus := []ImageWithStatus{}
 		for _, img := range images {
 			imagesWithStatus = append(imagesWithStatus, ImageWithStatus{
 				CloudImage: img,
@@ -139,48 +209,60 @@ func (s *Server) handleDownloadRepositoryImage() http.HandlerFunc {
 			return
 		}
 
-		// Allow re-download always (user can decide if they want to re-download)
+		// Allow re-download always (user can decide if they want to re-download),
+		// but never run two downloads of the same image at once
+		if job, active := s.activeImageDownload(imageID); active {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{
+				"status":  "in_progress",
+				"message": "Download already in progress",
+				"imageId": imageID,
+				"jobId":   job.ID,
+			})
+			return
+		}
 
-		// Start download in background
-		go func() {
-			var lastProgress float64 = -1
-			err := s.imageRepo.DownloadImage(imageID, func(downloaded, total int64) {
-				// Only log progress every 10% to reduce spam
-				progress := float64(downloaded) / float64(total) * 100
-				if progress-lastProgress >= 10 || progress == 100 {
-					fmt.Printf("Download progress for %s: %.1f%%\n", imageID, progress)
-					lastProgress = progress
+		client, serverID := s.clientFor(r), serverIDFor(r)
+		job := s.jobManager.SubmitTo(jobs.Long, "image.download", imageID, serverID, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			p.Update(0, "Queued")
+			err := s.imageRepo.DownloadImageContext(ctx, imageID, func(downloaded, total int64) {
+				if total > 0 {
+					p.Update(float64(downloaded)/float64(total)*100, "Downloading")
 				}
 			})
-			
 			if err != nil {
-				fmt.Printf("Download failed for %s: %v\n", imageID, err)
-			} else {
-				fmt.Printf("Download completed for %s\n", imageID)
-				
-				// Import the downloaded image into the main image library
-				downloadedPath := s.imageRepo.GetDownloadedImagePath(imageID)
-				if downloadedPath != "" {
-					fmt.Printf("Importing downloaded image %s from %s\n", imageID, downloadedPath)
-					_, importErr := s.client.ImportImageFromPath(downloadedPath)
-					if importErr != nil {
-						fmt.Printf("Failed to import downloaded image %s: %v\n", imageID, importErr)
-					} else {
-						fmt.Printf("Successfully imported downloaded image %s\n", imageID)
-					}
-				}
+				return nil, err
 			}
-		}()
+
+			// Import the downloaded image into the main image library
+			p.Update(100, "Importing")
+			image, err := s.importDownloadedImage(ctx, serverID, client, s.imageRepo.GetDownloadedImagePath(imageID))
+			if err != nil {
+				return nil, fmt.Errorf("failed to import downloaded image: %w", err)
+			}
+			return image, nil
+		})
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
 			"status":  "started",
 			"message": "Download started in background",
 			"imageId": imageID,
+			"jobId":   job.ID,
 		})
 	}
 }
 
+// activeImageDownload returns the queued or running download job for an image, if any
+func (s *Server) activeImageDownload(imageID string) (core.Job, bool) {
+	for _, job := range s.jobManager.List(jobs.Filter{Type: "image.download", Target: imageID}) {
+		if !job.State.IsFinal() {
+			return job, true
+		}
+	}
+	return core.Job{}, false
+}
+
 // handleGetDownloadStatus returns the download status of an image
 func (s *Server) handleGetDownloadStatus() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -190,14 +272,30 @@ func (s *Server) handleGetDownloadStatus() http.HandlerFunc {
 			return
 		}
 
-		downloaded := s.imageRepo.IsImageDownloaded(imageID)
-		
+		status := map[string]interface{}{
+			"imageId":     imageID,
+			"downloaded":  s.imageRepo.IsImageDownloaded(imageID),
+			"downloading": false,
+			"path":        s.imageRepo.GetDownloadedImagePath(imageID),
+		}
+
+		// Report the most recent download job; progress is a 0-1 fraction here
+		if recent := s.jobManager.List(jobs.Filter{Type: "image.download", Target: imageID}); len(recent) > 0 {
+			job := recent[0]
+			status["jobId"] = job.ID
+			status["state"] = job.State
+			status["progress"] = job.Progress / 100
+			if !job.State.IsFinal() {
+				status["downloaded"] = false
+				status["downloading"] = true
+			}
+			if job.Error != "" {
+				status["error"] = job.Error
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"imageId":    imageID,
-			"downloaded": downloaded,
-			"path":       s.imageRepo.GetDownloadedImagePath(imageID),
-		})
+		json.NewEncoder(w).Encode(status)
 	}
 }
 
@@ -206,7 +304,7 @@ func (s *Server) handleRevertToVMSnapshot() http.HandlerFunc {
 		uuid := chi.URLParam(r, "uuid")
 		snapshotName := chi.URLParam(r, "snapshotName")
 
-		err := s.client.RevertToVMSnapshot(uuid, snapshotName)
+		err := s.clientFor(r).RevertToVMSnapshot(uuid, snapshotName)
 		if err != nil {
 			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusInternalServerError)
 			return
@@ -227,7 +325,7 @@ func (s *Server) handleVMAction() http.HandlerFunc {
 			http.Error(w, `{"error": "Invalid JSON in request body"}`, http.StatusBadRequest)
 			return
 		}
-		err := s.client.PerformVMAction(uuid, req.Action)
+		err := s.clientFor(r).PerformVMAction(uuid, req.Action)
 		if err != nil {
 			if strings.Contains(err.Error(), "lookup domain") {
 				http.Error(w, `{"error": "VM not found"}`, http.StatusNotFound)
@@ -251,7 +349,7 @@ func (s *Server) handleDeleteVM() http.HandlerFunc {
 			deleteDisks = true
 		}
 
-		err := s.client.DeleteVM(uuid, deleteDisks)
+		err := s.clientFor(r).DeleteVM(uuid, deleteDisks)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -262,7 +360,7 @@ func (s *Server) handleDeleteVM() http.HandlerFunc {
 
 func (s *Server) handleGetHostStatus() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		status, err := s.client.GetHostStatus()
+		status, err := s.clientFor(r).GetHostStatus()
 		if err != nil {
 			sendInternalError(w, err)
 			return
@@ -274,7 +372,7 @@ func (s *Server) handleGetHostStatus() http.HandlerFunc {
 
 func (s *Server) handleGetHostResources() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		resources, err := s.client.GetHostResources()
+		resources, err := s.clientFor(r).GetHostResources()
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
 			return
@@ -286,7 +384,7 @@ func (s *Server) handleGetHostResources() http.HandlerFunc {
 
 func (s *Server) handleGetStoragePools() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		pools, err := s.client.GetStoragePools()
+		pools, err := s.clientFor(r).GetStoragePools()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -313,7 +411,7 @@ func (s *Server) handleCreateStoragePool() http.HandlerFunc {
 			return
 		}
 
-		err := s.client.CreateStoragePool(cfg)
+		err := s.clientFor(r).CreateStoragePool(cfg)
 		if err != nil {
 			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusInternalServerError)
 			return
@@ -325,7 +423,7 @@ func (s *Server) handleCreateStoragePool() http.HandlerFunc {
 
 func (s *Server) handleGetNetworks() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		networks, err := s.client.GetNetworks()
+		networks, err := s.clientFor(r).GetNetworks()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -337,7 +435,7 @@ func (s *Server) handleGetNetworks() http.HandlerFunc {
 
 func (s *Server) handleGetSystemInterfaces() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		interfaces, err := s.client.GetSystemInterfaces()
+		interfaces, err := s.clientFor(r).GetSystemInterfaces()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -348,10 +446,41 @@ func (s *Server) handleGetSystemInterfaces() http.HandlerFunc {
 	}
 }
 
+// handleGetActivity returns audit events, newest first. It supports ?since=,
+// ?until=, ?target=, ?action=, ?status=, ?actor= and ?server= filters plus
+// ?limit= / ?offset= pagination; X-Total-Count carries the unpaginated count.
 func (s *Server) handleGetActivity() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		activity := s.client.GetActivity()
-		json.NewEncoder(w).Encode(activity)
+		if s.auditStore == nil {
+			// No persistent log; fall back to the connection's in-memory buffer
+			activity := s.clientFor(r).GetActivity()
+			json.NewEncoder(w).Encode(activity)
+			return
+		}
+
+		query, err := parseActivityQuery(r)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Host-routed calls only see their own server's events; scoped tokens
+		// only see the servers in their scope
+		if serverID := serverIDFor(r); serverID != "" {
+			query.ServerID = serverID
+		} else if identity := identityFor(r); !identity.Scope.IsEmpty() {
+			if query.ServerID == "" {
+				query.ServerIDs = s.serverIDsInScope(identity)
+			} else if !s.selectorInScope(identity, query.ServerID) {
+				sendError(w, "server is outside the token's scope", http.StatusForbidden)
+				return
+			}
+		}
+
+		events, total := s.auditStore.Query(query)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		json.NewEncoder(w).Encode(events)
 	}
 }
 
@@ -447,7 +576,7 @@ func (s *Server) handleGetAPIKey() http.HandlerFunc {
 
 func (s *Server) handleGetImages() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		images, err := s.client.GetImages()
+		images, err := s.clientFor(r).GetImages()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -472,7 +601,7 @@ func (s *Server) handleImportImageFromPath() http.HandlerFunc {
 			return
 		}
 
-		image, err := s.client.ImportImageFromPath(req.Path)
+		image, err := s.clientFor(r).ImportImageFromPath(req.Path)
 		if err != nil {
 			http.Error(w, `{"error": "Failed to import image"}`, http.StatusInternalServerError)
 			return
@@ -483,11 +612,15 @@ func (s *Server) handleImportImageFromPath() http.HandlerFunc {
 	}
 }
 
+// handleDownloadImage downloads an image from a URL and imports it into the
+// managed library. Interrupted downloads of the same URL and name resume.
 func (s *Server) handleDownloadImage() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
-			URL  string `json:"url"`
-			Name string `json:"name,omitempty"`
+			URL    string `json:"url"`
+			Name   string `json:"name,omitempty"`
+			SHA256 string `json:"sha256,omitempty"`
+			SHA512 string `json:"sha512,omitempty"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, `{"error": "Invalid JSON in request body"}`, http.StatusBadRequest)
@@ -500,78 +633,96 @@ func (s *Server) handleDownloadImage() http.HandlerFunc {
 			return
 		}
 
-		// Parse URL to validate it
-		parsedURL, err := url.Parse(req.URL)
-		if err != nil {
-			http.Error(w, `{"error": "Invalid URL format"}`, http.StatusBadRequest)
-			return
-		}
-
-		// Only allow HTTP and HTTPS URLs
-		if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-			http.Error(w, `{"error": "Only HTTP and HTTPS URLs are allowed"}`, http.StatusBadRequest)
+		// Only allow absolute HTTP and HTTPS URLs
+		if err := download.ValidateURL(req.URL); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		parsedURL, _ := url.Parse(req.URL)
 
 		// Generate filename if not provided
 		filename := req.Name
 		if filename == "" {
 			// Extract filename from URL
-			urlPath := parsedURL.Path
-			parts := strings.Split(urlPath, "/")
-			if len(parts) > 0 {
-				filename = parts[len(parts)-1]
-			}
+			filename = path.Base(parsedURL.Path)
 			// If still empty, generate a default name
-			if filename == "" {
+			if filename == "" || filename == "." || filename == "/" {
 				filename = "downloaded-image-" + time.Now().Format("20060102-150405")
 			}
 		}
-
-		// Create temporary file
-		tempFile, err := os.CreateTemp("", "flint-download-*-"+filename)
-		if err != nil {
-			http.Error(w, `{"error": "Failed to create temporary file: `+err.Error()+`"}`, http.StatusInternalServerError)
+		if filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") || strings.HasSuffix(filename, ".part") {
+			sendError(w, fmt.Sprintf("invalid image name %q", filename), http.StatusBadRequest)
 			return
 		}
-		defer os.Remove(tempFile.Name()) // Clean up temp file
-		defer tempFile.Close()
 
-		// Download the file
-		resp, err := http.Get(req.URL)
-		if err != nil {
-			http.Error(w, `{"error": "Failed to download file: `+err.Error()+`"}`, http.StatusInternalServerError)
+		client, serverID := s.clientFor(r), serverIDFor(r)
+		dest := filepath.Join(s.downloadPath, filename)
+		job, ok := s.runLongJob(w, r, "image.download-url", req.URL, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return s.downloadAndImportImage(ctx, serverID, client, download.Request{URL: req.URL, Path: dest, SHA256: req.SHA256, SHA512: req.SHA512}, p)
+		})
+		if !ok {
 			return
 		}
-		defer resp.Body.Close()
-
-		// Check if the download was successful
-		if resp.StatusCode != http.StatusOK {
-			http.Error(w, `{"error": "Failed to download file: HTTP `+strconv.Itoa(resp.StatusCode)+`"}`, http.StatusInternalServerError)
+		if job.State != core.JobSucceeded {
+			sendError(w, job.Error, http.StatusInternalServerError)
 			return
 		}
 
-		// Copy the downloaded content to the temporary file
-		_, err = io.Copy(tempFile, resp.Body)
-		if err != nil {
-			http.Error(w, `{"error": "Failed to save downloaded file: `+err.Error()+`"}`, http.StatusInternalServerError)
-			return
-		}
+		// Return the imported image info
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
 
-		// Close the temp file so we can import it
-		tempFile.Close()
+// downloadAndImportImage downloads an image into the download directory,
+// reporting progress, and imports it into the managed image library. The
+// partial file is kept if the download fails so that a retry resumes it.
+func (s *Server) downloadAndImportImage(ctx context.Context, serverID string, client libvirtclient.ClientInterface, req download.Request, p *jobs.Progress) (core.Image, error) {
+	if err := os.MkdirAll(s.downloadPath, 0750); err != nil {
+		return core.Image{}, fmt.Errorf("failed to create download directory: %w", err)
+	}
 
-		// Import the downloaded file into the managed image library
-		image, err := s.client.ImportImageFromPath(tempFile.Name())
-		if err != nil {
-			http.Error(w, `{"error": "Failed to import downloaded image: `+err.Error()+`"}`, http.StatusInternalServerError)
-			return
+	p.Update(0, "Queued")
+	req.Progress = func(downloaded, total int64) {
+		if total > 0 {
+			p.Update(float64(downloaded)/float64(total)*100, "Downloading")
 		}
+	}
+	if _, err := s.downloader.Download(ctx, req); err != nil {
+		return core.Image{}, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer os.Remove(req.Path)
 
-		// Return the imported image info
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(image)
+	// Import the downloaded file into the managed image library
+	p.Update(100, "Importing")
+	image, err := s.importDownloadedImage(ctx, serverID, client, req.Path)
+	if err != nil {
+		return core.Image{}, fmt.Errorf("failed to import downloaded image: %w", err)
+	}
+	return image, nil
+}
+
+// importDownloadedImage imports a file downloaded on this host into the image
+// library of a server. The local host copies it in place; other servers have
+// it streamed to them through libvirt, like an image upload.
+func (s *Server) importDownloadedImage(ctx context.Context, serverID string, client libvirtclient.ClientInterface, path string) (core.Image, error) {
+	if s.isLocalServer(serverID) {
+		return client.ImportImageFromPath(path)
+	}
+	if s.imageUploads == nil {
+		return core.Image{}, fmt.Errorf("image uploads are not available to copy the image to server %s", serverID)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return core.Image{}, err
 	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return core.Image{}, err
+	}
+	return s.imageUploads.Upload(ctx, client, core.CreateImageUploadRequest{Name: filepath.Base(path), SizeBytes: info.Size()}, f)
 }
 
 // generateSecureToken generates a secure random token for WebSocket authentication
@@ -583,7 +734,7 @@ func generateSecureToken() string {
 
 func (s *Server) handleGetISOs() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		isos, err := s.client.GetISOs()
+		isos, err := s.clientFor(r).GetISOs()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -594,7 +745,7 @@ func (s *Server) handleGetISOs() http.HandlerFunc {
 
 func (s *Server) handleGetTemplates() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		templates, err := s.client.GetTemplates()
+		templates, err := s.clientFor(r).GetTemplates()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -606,7 +757,7 @@ func (s *Server) handleGetTemplates() http.HandlerFunc {
 func (s *Server) handleGetVMPerformance() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uuid := chi.URLParam(r, "uuid")
-		perf, err := s.client.GetVMPerformance(uuid)
+		perf, err := s.clientFor(r).GetVMPerformance(uuid)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -619,21 +770,16 @@ func (s *Server) handleGetVMSerialConsole() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uuid := chi.URLParam(r, "uuid")
 
-		// Debug: Log API key length
-		fmt.Printf("DEBUG: API key length: %d\n", len(s.apiKey))
-		if s.apiKey == "" {
-			fmt.Printf("DEBUG: API key is empty! Generating new one...\n")
-			// Generate API key directly here since method might not be accessible
-			bytes := make([]byte, 32)
-			rand.Read(bytes)
-			s.apiKey = hex.EncodeToString(bytes)
-			fmt.Printf("DEBUG: Generated API key length: %d\n", len(s.apiKey))
+		// Hand back the caller's own token for WebSocket authentication; web UI
+		// sessions use the server's API key
+		token, ok := r.Context().Value("api_key").(string)
+		if !ok {
+			token = s.apiKey
 		}
 
-		// Use the server's API key for WebSocket authentication
 		response := map[string]string{
-			"websocket_path": fmt.Sprintf("/api/vms/%s/serial-console/ws", uuid),
-			"token":          s.apiKey,
+			"websocket_path": vmPathFor(r, uuid, "serial-console/ws"),
+			"token":          token,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -641,6 +787,22 @@ func (s *Server) handleGetVMSerialConsole() http.HandlerFunc {
 	}
 }
 
+// authorizeConsoleToken checks the ?token= of a console WebSocket. Consoles give
+// control of the guest, so tokens need the operator role and the server and VM in scope.
+func (s *Server) authorizeConsoleToken(w http.ResponseWriter, r *http.Request, token string) bool {
+	identity, err := s.authenticateToken(token)
+	if err != nil {
+		http.Error(w, "Invalid authentication token", http.StatusUnauthorized)
+		return false
+	}
+	if !identity.Role.Allows(core.RoleOperator) || !s.selectorInScope(identity, serverIDFor(r)) ||
+		!vmInScope(identity, s.clientFor(r), chi.URLParam(r, "uuid")) {
+		http.Error(w, "Forbidden: operator access required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 func (s *Server) handleGetGuestAgentStatus() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vmUUID := chi.URLParam(r, "uuid")
@@ -649,7 +811,7 @@ func (s *Server) handleGetGuestAgentStatus() http.HandlerFunc {
 			return
 		}
 
-		available, err := s.client.CheckGuestAgentStatus(vmUUID)
+		available, err := s.clientFor(r).CheckGuestAgentStatus(vmUUID)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error": "Failed to check guest agent status: %s"}`, err.Error()), http.StatusInternalServerError)
 			return
@@ -671,7 +833,7 @@ func (s *Server) handleInstallGuestAgent() http.HandlerFunc {
 			return
 		}
 
-		err := s.client.InstallGuestAgent(vmUUID)
+		err := s.clientFor(r).InstallGuestAgent(vmUUID)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error": "Failed to install guest agent: %s"}`, err.Error()), http.StatusInternalServerError)
 			return
@@ -696,9 +858,11 @@ func (s *Server) handleVMSerialConsoleWS() http.HandlerFunc {
 			return
 		}
 
-		// Validate the token against the server's API key
-		if token != s.apiKey {
-			http.Error(w, "Invalid authentication token", http.StatusUnauthorized)
+		// Validate the API key or an operator's API token
+		if !s.authorizeConsoleToken(w, r, token) {
+			return
+		}
+		if !s.requireLocalServer(w, r, "serial consoles") {
 			return
 		}
 
@@ -722,7 +886,7 @@ func (s *Server) handleVMSerialConsoleWS() http.HandlerFunc {
 		defer conn.Close()
 
 		// Get the PTY path for the VM
-		ptyPath, err := s.client.GetVMSerialConsolePath(uuid)
+		ptyPath, err := s.clientFor(r).GetVMSerialConsolePath(uuid)
 		if err != nil {
 			conn.WriteMessage(websocket.TextMessage, []byte("Error: "+err.Error()))
 			return
@@ -813,16 +977,8 @@ func (s *Server) handleVMSerialConsoleWS() http.HandlerFunc {
 // validateVMCreationConfig validates VM creation configuration
 func validateVMCreationConfig(cfg *core.VMCreationConfig) error {
 	// Validate VM name
-	if cfg.Name == "" {
-		return fmt.Errorf("VM name is required")
-	}
-	if len(cfg.Name) > 64 {
-		return fmt.Errorf("VM name must be 64 characters or less")
-	}
-	// Allow alphanumeric, hyphens, and underscores
-	nameRegex := regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
-	if !nameRegex.MatchString(cfg.Name) {
-		return fmt.Errorf("VM name can only contain letters, numbers, hyphens, and underscores")
+	if err := validateVMName(cfg.Name); err != nil {
+		return err
 	}
 
 	// Validate memory
@@ -869,6 +1025,17 @@ func validateVMCreationConfig(cfg *core.VMCreationConfig) error {
 		return fmt.Errorf("disk size cannot exceed 10 TB")
 	}
 
+	// Validate data disks, NICs and hardware options
+	if err := validateVMDisks(cfg.Disks); err != nil {
+		return err
+	}
+	if err := validateVMNICs(cfg.NICs); err != nil {
+		return err
+	}
+	if err := validateVMHardware(cfg); err != nil {
+		return err
+	}
+
 	// Validate PXE config if provided
 	if cfg.ImageType == "pxe" {
 		if cfg.PXEConfig == nil {
@@ -899,6 +1066,127 @@ func validateVMCreationConfig(cfg *core.VMCreationConfig) error {
 	return nil
 }
 
+// Limits on the devices of a new VM
+const (
+	maxVMDataDisks = 16
+	maxVMNICs      = 16
+)
+
+// validateVMDisks validates the data disks of a new VM
+func validateVMDisks(disks []core.VMDiskConfig) error {
+	if len(disks) > maxVMDataDisks {
+		return fmt.Errorf("at most %d data disks are supported", maxVMDataDisks)
+	}
+	for i, disk := range disks {
+		n := i + 1
+		if disk.SizeGB == 0 {
+			return fmt.Errorf("disk %d: size must be greater than 0 GB", n)
+		}
+		if disk.SizeGB > 10000 {
+			return fmt.Errorf("disk %d: size cannot exceed 10 TB", n)
+		}
+		if len(disk.Pool) > 50 {
+			return fmt.Errorf("disk %d: pool name must be 50 characters or less", n)
+		}
+		switch disk.Bus {
+		case "", "virtio", "scsi", "sata":
+		default:
+			return fmt.Errorf("disk %d: bus must be 'virtio', 'scsi', or 'sata'", n)
+		}
+		switch disk.Cache {
+		case "", "none", "writeback", "writethrough", "directsync", "unsafe":
+		default:
+			return fmt.Errorf("disk %d: cache must be 'none', 'writeback', 'writethrough', 'directsync', or 'unsafe'", n)
+		}
+		switch disk.IO {
+		case "", "threads", "io_uring":
+		case "native":
+			// Native AIO needs O_DIRECT, which only these cache modes use
+			if disk.Cache != "none" && disk.Cache != "directsync" {
+				return fmt.Errorf("disk %d: io 'native' requires cache 'none' or 'directsync'", n)
+			}
+		default:
+			return fmt.Errorf("disk %d: io must be 'native', 'threads', or 'io_uring'", n)
+		}
+	}
+	return nil
+}
+
+// validateVMNICs validates the NICs of a new VM
+func validateVMNICs(nics []core.VMNICConfig) error {
+	if len(nics) > maxVMNICs {
+		return fmt.Errorf("at most %d NICs are supported", maxVMNICs)
+	}
+	for i, nic := range nics {
+		n := i + 1
+		if (nic.Network == "") == (nic.Bridge == "") {
+			return fmt.Errorf("NIC %d: exactly one of network or bridge is required", n)
+		}
+		if len(nic.Network) > 50 {
+			return fmt.Errorf("NIC %d: network name must be 50 characters or less", n)
+		}
+		if len(nic.Bridge) > 15 {
+			return fmt.Errorf("NIC %d: bridge name must be 15 characters or less", n)
+		}
+		switch nic.Model {
+		case "", "virtio", "e1000", "e1000e", "rtl8139":
+		default:
+			return fmt.Errorf("NIC %d: model must be 'virtio', 'e1000', 'e1000e', or 'rtl8139'", n)
+		}
+		if nic.MAC != "" {
+			mac, err := net.ParseMAC(nic.MAC)
+			if err != nil || len(mac) != 6 {
+				return fmt.Errorf("NIC %d: invalid MAC address %q", n, nic.MAC)
+			}
+			if mac[0]&1 != 0 {
+				return fmt.Errorf("NIC %d: MAC address must be unicast", n)
+			}
+		}
+		if len(nic.NWFilter) > 50 {
+			return fmt.Errorf("NIC %d: network filter name must be 50 characters or less", n)
+		}
+	}
+	return nil
+}
+
+// validateVMHardware validates the CPU, machine type, firmware and graphics of a new VM
+func validateVMHardware(cfg *core.VMCreationConfig) error {
+	if cpu := cfg.CPU; cpu != nil {
+		switch cpu.Mode {
+		case "", "host-passthrough", "host-model":
+		default:
+			return fmt.Errorf("CPU mode must be 'host-passthrough' or 'host-model'")
+		}
+		if cpu.Sockets < 0 || cpu.Cores < 0 || cpu.Threads < 0 {
+			return fmt.Errorf("CPU sockets, cores and threads cannot be negative")
+		}
+		if cpu.Sockets > 0 || cpu.Cores > 0 || cpu.Threads > 0 {
+			if total := max(cpu.Sockets, 1) * max(cpu.Cores, 1) * max(cpu.Threads, 1); total != cfg.VCPUs {
+				return fmt.Errorf("CPU topology has %d vCPUs but %d were requested", total, cfg.VCPUs)
+			}
+		}
+	}
+
+	if cfg.MachineType != "" && cfg.MachineType != "pc" && cfg.MachineType != "q35" {
+		return fmt.Errorf("machine type must be 'pc' or 'q35'")
+	}
+	if cfg.Firmware != "" && cfg.Firmware != "bios" && cfg.Firmware != "uefi" {
+		return fmt.Errorf("firmware must be 'bios' or 'uefi'")
+	}
+	if cfg.SecureBoot {
+		if cfg.Firmware != "uefi" {
+			return fmt.Errorf("secure boot requires 'uefi' firmware")
+		}
+		if cfg.MachineType != "q35" {
+			return fmt.Errorf("secure boot requires the 'q35' machine type")
+		}
+	}
+	if cfg.Graphics != "" && cfg.Graphics != "vnc" && cfg.Graphics != "spice" && cfg.Graphics != "none" {
+		return fmt.Errorf("graphics must be 'vnc', 'spice', or 'none'")
+	}
+	return nil
+}
+
 // validateCloudInitConfig validates cloud-init configuration
 func validateCloudInitConfig(cfg *core.CloudInitConfig) error {
 	if cfg.CommonFields.Hostname != "" {
@@ -938,6 +1226,51 @@ func validateCloudInitConfig(cfg *core.CloudInitConfig) error {
 	return nil
 }
 
+// validateVMName validates a VM name
+func validateVMName(name string) error {
+	if name == "" {
+		return fmt.Errorf("VM name is required")
+	}
+	if len(name) > 64 {
+		return fmt.Errorf("VM name must be 64 characters or less")
+	}
+	// Allow alphanumeric, hyphens, and underscores
+	nameRegex := regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("VM name can only contain letters, numbers, hyphens, and underscores")
+	}
+	return nil
+}
+
+// validateCloneVMRequest validates a clone request
+func validateCloneVMRequest(req *core.CloneVMRequest) error {
+	if err := validateVMName(req.Name); err != nil {
+		return err
+	}
+	if req.CloudInit != nil {
+		if err := validateCloudInitConfig(req.CloudInit); err != nil {
+			return fmt.Errorf("cloud-init config: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateMigrateVMRequest validates a migration request
+func validateMigrateVMRequest(req *core.MigrateVMRequest) error {
+	if strings.TrimSpace(req.TargetServer) == "" {
+		return fmt.Errorf("targetServer is required")
+	}
+	switch req.CopyStorage {
+	case core.MigrateCopyStorageNone, core.MigrateCopyStorageAll, core.MigrateCopyStorageIncremental:
+	default:
+		return fmt.Errorf("copyStorage must be \"all\" or \"incremental\"")
+	}
+	if req.Offline && (req.CopyStorage != core.MigrateCopyStorageNone || req.AutoConverge || req.PostCopy) {
+		return fmt.Errorf("copyStorage, autoConverge and postCopy only apply to live migrations")
+	}
+	return nil
+}
+
 // validateUUID validates UUID format
 func validateUUID(uuid string) error {
 	if uuid == "" {
@@ -1007,22 +1340,37 @@ func (s *Server) handleCreateVM() http.HandlerFunc {
 			return
 		}
 
-		vm, err := s.client.CreateVM(cfg)
-		if err != nil {
+		client := s.clientFor(r)
+		job, ok := s.runJob(w, r, "vm.create", cfg.Name, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			p.Update(0, "Creating VM")
+			vm, err := client.CreateVM(cfg)
+			if err != nil {
+				return nil, err
+			}
+			p.Update(100, "VM created")
+			return vm, nil
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
 			// Don't expose internal error details that could be sensitive
 			http.Error(w, `{"error": "Failed to create VM"}`, http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(vm)
+		json.NewEncoder(w).Encode(job.Result)
 	}
 }
 
 func (s *Server) handleGetVolumes() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		poolName := chi.URLParam(r, "poolName")
-		volumes, err := s.client.GetVolumes(poolName)
+		volumes, err := s.clientFor(r).GetVolumes(poolName)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -1041,7 +1389,7 @@ func (s *Server) handleCreateVolume() http.HandlerFunc {
 			return
 		}
 
-		err := s.client.CreateVolume(poolName, req)
+		err := s.clientFor(r).CreateVolume(poolName, req)
 		if err != nil {
 			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusInternalServerError)
 			return
@@ -1074,9 +1422,23 @@ func (s *Server) handleUpdateVolume() http.HandlerFunc {
 			Name:   volumeName,
 			SizeGB: uint64(req.SizeGB),
 		}
-		err := s.client.UpdateVolume(poolName, volumeName, config)
-		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "Failed to update volume: %s"}`, err.Error()), http.StatusInternalServerError)
+		client := s.clientFor(r)
+		job, ok := s.runJob(w, r, "volume.resize", poolName+"/"+volumeName, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			p.Update(0, "Resizing volume")
+			if err := client.UpdateVolume(poolName, volumeName, config); err != nil {
+				return nil, err
+			}
+			p.Update(100, "Volume resized")
+			return nil, nil
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to update volume: %s"}`, job.Error), http.StatusInternalServerError)
 			return
 		}
 
@@ -1096,7 +1458,7 @@ func (s *Server) handleDeleteVolume() http.HandlerFunc {
 			return
 		}
 
-		err := s.client.DeleteVolume(poolName, volumeName)
+		err := s.clientFor(r).DeleteVolume(poolName, volumeName)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error": "Failed to delete volume: %s"}`, err.Error()), http.StatusInternalServerError)
 			return
@@ -1119,7 +1481,7 @@ func (s *Server) handleCreateNetwork() http.HandlerFunc {
 			return
 		}
 
-		err := s.client.CreateNetwork(req.Name, req.BridgeName)
+		err := s.clientFor(r).CreateNetwork(req.Name, req.BridgeName)
 		if err != nil {
 			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusInternalServerError)
 			return
@@ -1189,7 +1551,7 @@ func (s *Server) handleAttachDiskToVM() http.HandlerFunc {
 			return
 		}
 
-		err := s.client.AttachDiskToVM(uuid, req.VolumePath, req.TargetDev)
+		err := s.clientFor(r).AttachDiskToVM(uuid, req.VolumePath, req.TargetDev)
 		if err != nil {
 			http.Error(w, `{"error": "`+err.Error()+`"}`, http.StatusInternalServerError)
 			return
@@ -1238,7 +1600,7 @@ func (s *Server) handleAttachNetworkInterfaceToVM() http.HandlerFunc {
 
 		// For now, just try to attach the interface directly
 		// TODO: Add proper VM state management when hot-plug is needed
-		err := s.client.AttachNetworkInterfaceToVM(uuid, networkName, model)
+		err := s.clientFor(r).AttachNetworkInterfaceToVM(uuid, networkName, model)
 		if err != nil {
 			http.Error(w, `{"error": "Failed to attach network interface: `+err.Error()+`"}`, http.StatusInternalServerError)
 			return
@@ -1289,128 +1651,7 @@ func (s *Server) handleGetVMConsoleStream() http.HandlerFunc {
 		// Return WebSocket path for frontend to connect to
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{
-			"websocket_path": fmt.Sprintf("/api/vms/%s/serial-console/ws", uuid),
-		})
-	})
-}
-
-// handleCreateVMFromTemplate creates a VM from a template
-func (s *Server) handleCreateVMFromTemplate() http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var req struct {
-			TemplateID string `json:"templateId"`
-			Name       string `json:"name"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
-
-		// Get snapshots for the template VM
-		snapshots, err := s.client.GetVMSnapshots(req.TemplateID)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get template snapshots: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		if len(snapshots) == 0 {
-			http.Error(w, "No snapshots found for template", http.StatusBadRequest)
-			return
-		}
-
-		// Use the latest snapshot
-		latestSnapshot := snapshots[0]
-
-		// Create VM from snapshot (simplified - in practice you'd clone the VM)
-		err = s.client.RevertToVMSnapshot(req.TemplateID, latestSnapshot.Name)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create VM from template: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"uuid":    req.TemplateID, // In practice, this would be a new UUID
-			"name":    req.Name,
-			"message": "VM created from template successfully",
-		})
-	})
-}
-
-// handleGetVMTemplates returns available VM templates
-func (s *Server) handleGetVMTemplates() http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get all VMs and check which ones have snapshots (templates)
-		vms, err := s.client.GetVMSummaries()
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get VMs: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		var templates []map[string]interface{}
-
-		for _, vm := range vms {
-			snapshots, err := s.client.GetVMSnapshots(vm.UUID)
-			if err != nil {
-				continue // Skip VMs we can't get snapshots for
-			}
-
-			if len(snapshots) > 0 {
-				// This VM has snapshots, so it can be used as a template
-				template := map[string]interface{}{
-					"id":          vm.UUID,
-					"name":        vm.Name + "-template",
-					"description": fmt.Sprintf("Template based on %s with %d snapshots", vm.Name, len(snapshots)),
-					"sourceVM":    vm.Name,
-					"vcpus":       vm.VCPUs,
-					"memory":      vm.MemoryKB / 1024, // Convert to MB
-					"diskSize":    20,                 // Default disk size
-					"createdAt":   time.Now().Format(time.RFC3339),
-				}
-				templates = append(templates, template)
-			}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(templates)
-	})
-}
-
-// handleCreateVMTemplate creates a new template from a VM
-func (s *Server) handleCreateVMTemplate() http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var req struct {
-			VMID        string `json:"vmId"`
-			Name        string `json:"name"`
-			Description string `json:"description"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
-			return
-		}
-
-		// Create a snapshot of the VM to use as template
-		snapshotReq := core.CreateSnapshotRequest{
-			Name:        req.Name,
-			Description: req.Description,
-		}
-
-		snapshot, err := s.client.CreateVMSnapshot(req.VMID, snapshotReq)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create template snapshot: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"id":          req.VMID,
-			"name":        req.Name,
-			"description": req.Description,
-			"snapshot":    snapshot.Name,
-			"createdAt":   time.Now().Format(time.RFC3339),
-			"message":     "Template created successfully",
+			"websocket_path": vmPathFor(r, uuid, "serial-console/ws"),
 		})
 	})
 }
@@ -1433,7 +1674,7 @@ func (s *Server) handleUpdateNetwork() http.HandlerFunc {
 			return
 		}
 
-		err := s.client.UpdateNetwork(networkName, req.Action)
+		err := s.clientFor(r).UpdateNetwork(networkName, req.Action)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error": "Failed to update network: %s"}`, err.Error()), http.StatusInternalServerError)
 			return
@@ -1461,7 +1702,7 @@ func (s *Server) handleDeleteNetwork() http.HandlerFunc {
 		}
 
 		// Call the actual delete function
-		err := s.client.DeleteNetwork(networkName)
+		err := s.clientFor(r).DeleteNetwork(networkName)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error": "Failed to delete network: %s"}`, err.Error()), http.StatusInternalServerError)
 			return
@@ -1484,9 +1725,13 @@ func (s *Server) handleDeleteImage() http.HandlerFunc {
 		}
 
 		// Call the actual delete function
-		err := s.client.DeleteImage(imageId)
+		err := s.clientFor(r).DeleteImage(imageId)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "Failed to delete image: %s"}`, err.Error()), http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			if errors.Is(err, libvirtclient.ErrImageInUse) {
+				status = http.StatusConflict
+			}
+			sendError(w, fmt.Sprintf("Failed to delete image: %v", err), status)
 			return
 		}
 
@@ -1503,7 +1748,7 @@ func (s *Server) handleGetVMVNCInfo() http.HandlerFunc {
 			return
 		}
 
-		vncInfo, err := s.client.GetVMVNCInfo(uuid)
+		vncInfo, err := s.clientFor(r).GetVMVNCInfo(uuid)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error": "Failed to get VNC info: %s"}`, err.Error()), http.StatusInternalServerError)
 			return
@@ -1526,14 +1771,16 @@ func (s *Server) handleVMVNCWebSocket() http.HandlerFunc {
 			return
 		}
 
-		// Validate the token against the server's API key
-		if token != s.apiKey {
-			http.Error(w, "Invalid authentication token", http.StatusUnauthorized)
+		// Validate the API key or an operator's API token
+		if !s.authorizeConsoleToken(w, r, token) {
+			return
+		}
+		if !s.requireLocalServer(w, r, "VNC consoles") {
 			return
 		}
 
 		// Get VNC connection details
-		vncInfo, err := s.client.GetVMVNCInfo(uuid)
+		vncInfo, err := s.clientFor(r).GetVMVNCInfo(uuid)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Failed to get VNC info: %s", err.Error()), http.StatusInternalServerError)
 			return
@@ -1554,7 +1801,7 @@ func (s *Server) handleVMVNCWebSocket() http.HandlerFunc {
 		defer wsConn.Close()
 
 		// Connect to VNC server
-		vncAddr := fmt.Sprintf("%s:%s", vncInfo.Host, vncInfo.Port)
+		vncAddr := net.JoinHostPort(vncInfo.Host, vncInfo.Port)
 		vncConn, err := net.Dial("tcp", vncAddr)
 		if err != nil {
 			log.Printf("Failed to connect to VNC server at %s: %v", vncAddr, err)
@@ -1624,7 +1871,7 @@ func (s *Server) handleVMVNCWebSocket() http.HandlerFunc {
 // handleListNWFilters lists all network filters
 func (s *Server) handleListNWFilters() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		filters, err := s.client.ListNWFilters()
+		filters, err := s.clientFor(r).ListNWFilters()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -1643,7 +1890,7 @@ func (s *Server) handleGetNWFilter() http.HandlerFunc {
 			return
 		}
 
-		filter, err := s.client.GetNWFilter(name)
+		filter, err := s.clientFor(r).GetNWFilter(name)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
@@ -1667,7 +1914,7 @@ func (s *Server) handleCreateNWFilter() http.HandlerFunc {
 			return
 		}
 
-		if err := s.client.CreateNWFilter(req); err != nil {
+		if err := s.clientFor(r).CreateNWFilter(req); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -1693,7 +1940,7 @@ func (s *Server) handleUpdateNWFilter() http.HandlerFunc {
 			return
 		}
 
-		if err := s.client.UpdateNWFilter(name, req); err != nil {
+		if err := s.clientFor(r).UpdateNWFilter(name, req); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -1712,7 +1959,7 @@ func (s *Server) handleDeleteNWFilter() http.HandlerFunc {
 			return
 		}
 
-		if err := s.client.DeleteNWFilter(name); err != nil {
+		if err := s.clientFor(r).DeleteNWFilter(name); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
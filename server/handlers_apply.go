@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/volantvm/flint/pkg/apply"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+)
+
+// maxSpecSize caps the body of /api/plan and /api/apply
+const maxSpecSize = 4 << 20
+
+// readSpec parses the YAML or JSON spec in a request body
+func readSpec(r *http.Request) (core.Spec, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxSpecSize+1))
+	if err != nil {
+		return core.Spec{}, fmt.Errorf("failed to read spec: %w", err)
+	}
+	if len(data) > maxSpecSize {
+		return core.Spec{}, fmt.Errorf("spec is larger than %d MiB", maxSpecSize>>20)
+	}
+	spec, err := apply.Parse(data)
+	if err != nil {
+		return core.Spec{}, err
+	}
+
+	// Disks, NICs and hardware options are held to the same rules as POST /api/vms
+	for _, vm := range spec.VMs {
+		if vm.Ensure == core.EnsureAbsent {
+			continue
+		}
+		err := validateVMDisks(vm.Disks)
+		if err == nil {
+			err = validateVMNICs(vm.NICs)
+		}
+		if err == nil {
+			err = validateVMHardware(&vm.VMCreationConfig)
+		}
+		if err != nil {
+			return core.Spec{}, fmt.Errorf("invalid spec: vm %s: %w", vm.Name, err)
+		}
+	}
+	return spec, nil
+}
+
+// handlePlan returns what applying a spec would change
+func (s *Server) handlePlan() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		spec, err := readSpec(r)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		plan, err := apply.Plan(s.clientFor(r), spec)
+		if err != nil {
+			sendError(w, fmt.Sprintf("Failed to plan: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plan)
+	}
+}
+
+// handleApply brings the host to a spec as a job
+func (s *Server) handleApply() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		spec, err := readSpec(r)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		client := s.clientFor(r)
+		plan, err := apply.Plan(client, spec)
+		if err != nil {
+			sendError(w, fmt.Sprintf("Failed to plan: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+
+		// Pools, networks and filters are admin routes when changed directly
+		if identity := identityFor(r); identity.User != "" && !identity.Role.Allows(core.RoleAdmin) {
+			for _, action := range plan.Actions {
+				if action.Kind != "vm" {
+					sendError(w, fmt.Sprintf("Forbidden: %s access required to %s %s %s", core.RoleAdmin, action.Op, action.Kind, action.Name), http.StatusForbidden)
+					return
+				}
+			}
+		}
+
+		job, ok := s.runLongJob(w, r, "apply", "", func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return apply.Apply(ctx, client, spec, p.Update)
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, fmt.Sprintf("Failed to apply spec: %s", job.Error), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
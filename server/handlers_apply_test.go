@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/volantvm/flint/pkg/auth"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+// applyTestClient is an empty host; other methods are not implemented
+type applyTestClient struct {
+	libvirtclient.ClientInterface
+}
+
+func (applyTestClient) GetStoragePools() ([]core.StoragePool, error) { return nil, nil }
+func (applyTestClient) GetNetworks() ([]core.Network, error)         { return nil, nil }
+func (applyTestClient) ListNWFilters() ([]core.NWFilter, error)      { return nil, nil }
+func (applyTestClient) GetVMSummaries() ([]core.VM_Summary, error)   { return nil, nil }
+
+func TestHandleApply(t *testing.T) {
+	s := &Server{client: applyTestClient{}, jobManager: jobs.NewManager(0, 0, 0)}
+	operator := auth.Identity{User: "ci", Role: core.RoleOperator}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		want    int
+	}{
+		{"plan", s.handlePlan(), "networks:\n  - name: lab\n    bridge: virbr-lab\n", http.StatusOK},
+		{"plan with unknown field", s.handlePlan(), "vms:\n  - name: a\n    ram: 1024\n", http.StatusBadRequest},
+		{"plan with secure boot on pc", s.handlePlan(), "vms:\n  - name: a\n    memoryMB: 512\n    vcpus: 1\n    firmware: uefi\n    secureBoot: true\n", http.StatusBadRequest},
+		{"plan of missing network without bridge", s.handlePlan(), "networks:\n  - name: lab\n", http.StatusUnprocessableEntity},
+		{"operator creating a network", s.handleApply(), "networks:\n  - name: lab\n    bridge: virbr-lab\n", http.StatusForbidden},
+		{"operator deleting a missing network", s.handleApply(), "networks:\n  - name: lab\n    ensure: absent\n", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := withIdentity(httptest.NewRequest(http.MethodPost, "/api/apply", strings.NewReader(tt.body)), operator)
+		tt.handler(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/auth"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// handleListUsers returns all API users
+func (s *Server) handleListUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.authStore.ListUsers())
+	}
+}
+
+// handleCreateUser adds a named user with a role and optional scope
+func (s *Server) handleCreateUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req core.CreateUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		user, err := s.authStore.AddUser(req)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+// handleDeleteUser removes a user and revokes all of its tokens
+func (s *Server) handleDeleteUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.authStore.DeleteUser(chi.URLParam(r, "userName")); err != nil {
+			sendAuthStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleListTokens returns token metadata, optionally for one ?user=
+func (s *Server) handleListTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.authStore.ListTokens(r.URL.Query().Get("user")))
+	}
+}
+
+// handleCreateToken issues a token for a user. The secret is only returned here.
+func (s *Server) handleCreateToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req core.CreateTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		token, secret, err := s.authStore.CreateToken(req)
+		if err != nil {
+			sendAuthStoreError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(core.CreateTokenResponse{Token: token, Secret: secret})
+	}
+}
+
+// handleRevokeToken deletes a token by ID or prefix
+func (s *Server) handleRevokeToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := s.authStore.RevokeToken(chi.URLParam(r, "tokenId"))
+		if err != nil {
+			sendAuthStoreError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(token)
+	}
+}
+
+// sendAuthStoreError maps user and token store errors to HTTP statuses
+func sendAuthStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrUserNotFound) || errors.Is(err, auth.ErrTokenNotFound) {
+		sendError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendError(w, err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/backup"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+)
+
+// handleListBackups returns every backup in the repository, oldest first
+func (s *Server) handleListBackups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.sendBackups(w, "")
+	}
+}
+
+// handleListVMBackups returns a VM's backups, oldest first. The VM may have been deleted since.
+func (s *Server) handleListVMBackups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		if err := validateUUID(uuid); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.sendBackups(w, uuid)
+	}
+}
+
+func (s *Server) sendBackups(w http.ResponseWriter, vm string) {
+	repo, err := backup.NewRepository(s.backupPath)
+	if err != nil {
+		sendError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	backups, err := repo.List(vm)
+	if err != nil {
+		sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backups)
+}
+
+// handleCreateVMBackup backs a VM up into the configured repository
+func (s *Server) handleCreateVMBackup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		if err := validateUUID(uuid); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req core.CreateBackupRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				sendError(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		// Clients never choose where the server writes
+		req.Repository = s.backupPath
+
+		client := s.clientFor(r)
+		job, ok := s.runLongJob(w, r, "vm.backup", uuid, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return client.BackupVM(ctx, uuid, req, p.Update)
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, fmt.Sprintf("Failed to back up VM: %s", job.Error), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
+
+// handleRestoreVMBackup verifies a backup and defines a VM from it. Use
+// "latest" as the backup ID for the VM's newest backup.
+func (s *Server) handleRestoreVMBackup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		if err := validateUUID(uuid); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req core.RestoreBackupRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				sendError(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		req.Repository = s.backupPath
+		req.VM = uuid
+		req.BackupID = chi.URLParam(r, "backupId")
+		if req.BackupID == "latest" {
+			req.BackupID = ""
+		}
+
+		repo, err := backup.NewRepository(s.backupPath)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if _, err := repo.Get(uuid, req.BackupID); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, backup.ErrBackupNotFound) {
+				status = http.StatusNotFound
+			}
+			sendError(w, err.Error(), status)
+			return
+		}
+
+		client := s.clientFor(r)
+		job, ok := s.runLongJob(w, r, "vm.restore", uuid, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return client.RestoreBackup(ctx, req, p.Update)
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, fmt.Sprintf("Failed to restore VM: %s", job.Error), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
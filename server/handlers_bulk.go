@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/volantvm/flint/pkg/bulk"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+)
+
+// handleBulkVMAction runs an action on the VMs given by UUID or selected by
+// labels and name, as a vm.bulk-action job. Per-VM failures are reported in the
+// result rather than failing the request.
+func (s *Server) handleBulkVMAction() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req core.BulkVMActionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid JSON in request body", http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, uuid := range req.UUIDs {
+			if err := validateUUID(uuid); err != nil {
+				sendError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		client := s.clientFor(r)
+		// Tokens scoped to VM labels only act on the VMs they match
+		if identity := identityFor(r); identity.Scope.VMLabels != "" {
+			for _, uuid := range req.UUIDs {
+				if !vmInScope(identity, client, uuid) {
+					sendError(w, fmt.Sprintf("VM %s is outside the token's scope", uuid), http.StatusForbidden)
+					return
+				}
+			}
+			if len(req.UUIDs) == 0 {
+				req.Selector = strings.Trim(req.Selector+","+identity.Scope.VMLabels, ",")
+			}
+		}
+
+		job, ok := s.runLongJob(w, r, "vm.bulk-action", req.Action, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return bulk.Run(ctx, client, req, p.Update)
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, fmt.Sprintf("Failed to run %s: %s", req.Action, job.Error), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
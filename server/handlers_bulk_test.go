@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+// bulkTestClient records stopped VMs; other methods are not implemented
+type bulkTestClient struct {
+	libvirtclient.ClientInterface
+	stopped chan string
+}
+
+func (c *bulkTestClient) GetVMSummaries() ([]core.VM_Summary, error) {
+	return []core.VM_Summary{
+		{Name: "lab-web", UUID: labelTestUUID, State: "Running", Labels: map[string]string{"env": "lab"}},
+		{Name: "lab-db", UUID: "1b6f3c2e-5d1a-4c3b-9e2f-1a2b3c4d5e6f", State: "Shutoff", Labels: map[string]string{"env": "lab"}},
+		{Name: "prod-web", UUID: "2b6f3c2e-5d1a-4c3b-9e2f-1a2b3c4d5e6f", State: "Running", Labels: map[string]string{"env": "prod"}},
+	}, nil
+}
+
+func (c *bulkTestClient) PerformVMAction(uuidStr string, action string) error {
+	c.stopped <- uuidStr
+	return nil
+}
+
+func TestHandleBulkVMAction(t *testing.T) {
+	client := &bulkTestClient{stopped: make(chan string, 3)}
+	s := &Server{client: client, jobManager: jobs.NewManager(0, 0, 0)}
+
+	w := httptest.NewRecorder()
+	s.handleBulkVMAction()(w, httptest.NewRequest(http.MethodPost, "/api/vms/actions", strings.NewReader(`{"action": "stop", "selector": "env=lab", "parallelism": 2}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var result core.BulkVMActionResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if result.Succeeded != 1 || result.Unchanged != 1 || len(result.Results) != 2 || result.Results[1].Name != "lab-web" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if uuid := <-client.stopped; uuid != labelTestUUID {
+		t.Errorf("stopped %s, want %s", uuid, labelTestUUID)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no selection", `{"action": "stop"}`},
+		{"unknown action", `{"action": "migrate", "name": "*"}`},
+		{"invalid UUID", `{"action": "stop", "uuids": ["lab-web"]}`},
+		{"invalid JSON", `{"action": `},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.handleBulkVMAction()(w, httptest.NewRequest(http.MethodPost, "/api/vms/actions", strings.NewReader(tt.body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/events"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// eventHeartbeatInterval keeps idle event streams from being closed by proxies
+const eventHeartbeatInterval = 30 * time.Second
+
+// eventResourceLabels name resource types in activity log actions
+var eventResourceLabels = map[string]string{
+	core.EventResourceDomain:      "VM",
+	core.EventResourceNetwork:     "Network",
+	core.EventResourceStoragePool: "Storage Pool",
+}
+
+// watchEvents starts forwarding a connection's libvirt events to the event hub
+func (s *Server) watchEvents(serverID string, client events.Source) {
+	if s.eventHub == nil || client == nil {
+		return
+	}
+	if err := s.eventHub.Watch(serverID, client); err != nil {
+		logger.Warn("Failed to subscribe to libvirt events", map[string]interface{}{
+			"server_id": serverID,
+			"error":     err.Error(),
+		})
+	}
+}
+
+// recordEvents copies every libvirt event into the audit log so out-of-band
+// changes (virsh, crashes, guest shutdowns) show up in /api/activity
+func (s *Server) recordEvents() {
+	ch, _ := s.eventHub.Subscribe(events.Filter{})
+	for e := range ch {
+		if s.auditStore == nil {
+			continue
+		}
+		if err := s.auditStore.Record(activityFromEvent(e)); err != nil {
+			logger.Warn("Failed to record libvirt event", map[string]interface{}{
+				"name":  e.Name,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// activityFromEvent turns a libvirt event into an audit entry such as "VM Crashed"
+func activityFromEvent(e core.Event) core.ActivityEvent {
+	label := eventResourceLabels[e.ResourceType]
+	if label == "" {
+		label = e.ResourceType
+	}
+
+	var action string
+	switch e.Kind {
+	case "block_job":
+		action = label + " Block Job " + titleWord(e.Event)
+	case "agent_lifecycle":
+		action = label + " Guest Agent " + titleWord(e.Event)
+	default:
+		action = label + " " + titleWord(e.Event)
+	}
+
+	status := "Success"
+	if e.Event == "crashed" || e.Event == "failed" {
+		status = "Error"
+	}
+
+	message := fmt.Sprintf("libvirt %s event", strings.Replace(e.Kind, "_", " ", -1))
+	if e.Detail != "" {
+		message += ": " + e.Detail
+	}
+
+	return core.ActivityEvent{
+		Timestamp:  e.Time.Unix(),
+		Action:     action,
+		Target:     e.Name,
+		TargetUUID: e.UUID,
+		ServerID:   e.ServerID,
+		Status:     status,
+		Message:    message,
+		Actor:      "libvirt",
+	}
+}
+
+// titleWord upper-cases the first letter of an event name ("started" -> "Started")
+func titleWord(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// eventFilter builds a subscription filter from ?type=, ?uuid=, ?name= and ?server=
+func (s *Server) eventFilter(r *http.Request) events.Filter {
+	q := r.URL.Query()
+	filter := events.Filter{
+		UUID: q.Get("uuid"),
+		Name: q.Get("name"),
+	}
+
+	for _, t := range strings.Split(q.Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.ResourceTypes = append(filter.ResourceTypes, t)
+		}
+	}
+
+	if server := q.Get("server"); server != "" {
+		filter.ServerID = server
+		if s.serverRegistry != nil {
+			if cfg, err := s.serverRegistry.FindServer(server); err == nil {
+				filter.ServerID = cfg.ID
+			}
+		}
+	}
+
+	return filter
+}
+
+// handleEvents streams libvirt events as server-sent events, or over a WebSocket
+// when the client requests an upgrade
+func (s *Server) handleEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.eventHub == nil {
+			sendError(w, "Event stream is not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		filter := s.eventFilter(r)
+		if identity := identityFor(r); !identity.Scope.IsEmpty() {
+			server := r.URL.Query().Get("server")
+			if server == "" || !s.selectorInScope(identity, server) {
+				sendError(w, "Scoped tokens must stream events for a server in scope with ?server=", http.StatusForbidden)
+				return
+			}
+		}
+
+		if websocket.IsWebSocketUpgrade(r) {
+			s.streamEventsWebSocket(w, r, filter)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			sendError(w, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		ch, unsubscribe := s.eventHub.Subscribe(filter)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(eventHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				flusher.Flush()
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.ResourceType, data)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// streamEventsWebSocket sends each event as a JSON text message
+func (s *Server) streamEventsWebSocket(w http.ResponseWriter, r *http.Request, filter events.Filter) {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ch, unsubscribe := s.eventHub.Subscribe(filter)
+	defer unsubscribe()
+
+	// Drain client messages so close frames are noticed
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// localEventsID is the server ID the local connection's events carry: that of
+// the registered server with a local URI, if any, so ?server= finds them
+func (s *Server) localEventsID() string {
+	if local := s.localServer(); local != nil {
+		return local.ID
+	}
+	return ""
+}
+
+// watchRegisteredServers connects to every registered server in the background
+// so their events are captured without waiting for a request to target them
+func (s *Server) watchRegisteredServers() {
+	if s.serverRegistry == nil || s.connectionPool == nil {
+		return
+	}
+
+	for _, server := range s.serverRegistry.ListServers() {
+		// The local connection is already watched; a second subscription would
+		// deliver every local event twice
+		if isLocalURI(server.URI) {
+			continue
+		}
+		client, err := s.connectionPool.GetConnection(server)
+		if err != nil {
+			logger.Warn("Skipping event subscription for unreachable server", map[string]interface{}{
+				"server_id": server.ID,
+				"error":     err.Error(),
+			})
+			continue
+		}
+		s.watchEvents(server.ID, client)
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/connectionpool"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/events"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/serverregistry"
+)
+
+// eventsTestClient counts the event subscriptions made on one host
+type eventsTestClient struct {
+	libvirtclient.ClientInterface
+	mu            sync.Mutex
+	subscriptions int
+}
+
+func (c *eventsTestClient) SubscribeEvents(handler func(core.Event)) (func(), error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscriptions++
+	return func() {}, nil
+}
+
+func (c *eventsTestClient) Subscriptions() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subscriptions
+}
+
+func (c *eventsTestClient) Close() error { return nil }
+
+func TestWatchRegisteredServers_SkipsLocalHost(t *testing.T) {
+	registry, err := serverregistry.NewRegistry(filepath.Join(t.TempDir(), "servers.json"))
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	local, err := registry.AddServer(core.CreateServerRequest{Name: "local", URI: "qemu:///system", IsDefault: true})
+	if err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+	remote, err := registry.AddServer(core.CreateServerRequest{Name: "host-b", URI: "qemu+ssh://root@host-b/system"})
+	if err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+
+	pooled := map[string]*eventsTestClient{
+		local.ID:  {},
+		remote.ID: {},
+	}
+	pool := connectionpool.NewPoolWithDialer(time.Hour, time.Hour, func(config *core.ServerConfig) (libvirtclient.ClientInterface, error) {
+		return pooled[config.ID], nil
+	})
+	defer pool.CloseAll()
+
+	localClient := &eventsTestClient{}
+	s := &Server{client: localClient, eventHub: events.NewHub(), serverRegistry: registry, connectionPool: pool}
+
+	if got := s.localEventsID(); got != local.ID {
+		t.Errorf("localEventsID() = %q, want %q", got, local.ID)
+	}
+
+	s.watchEvents(s.localEventsID(), localClient)
+	s.watchRegisteredServers()
+	for _, selector := range []string{local.ID, remote.ID} {
+		if _, _, status, err := s.resolveServerClient(selector); err != nil {
+			t.Fatalf("resolveServerClient(%s) = %d, %v", selector, status, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		client *eventsTestClient
+		want   int
+	}{
+		{"local connection", localClient, 1},
+		{"pooled local server", pooled[local.ID], 0},
+		{"remote server", pooled[remote.ID], 1},
+	}
+	for _, tt := range tests {
+		if got := tt.client.Subscriptions(); got != tt.want {
+			t.Errorf("%s: %d event subscriptions, want %d", tt.name, got, tt.want)
+		}
+	}
+}
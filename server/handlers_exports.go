@@ -0,0 +1,255 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+)
+
+// exportNameChars are the characters kept from a VM name in archive names
+var exportNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportFilePath returns the path of a file in the export directory. Names
+// must not leave the directory or clash with in-progress work files.
+func (s *Server) exportFilePath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".part") {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return filepath.Join(s.exportPath, name), nil
+}
+
+// handleExportVM writes a shut-off VM to an OVA or tar archive in the export directory
+func (s *Server) handleExportVM() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		if err := validateUUID(uuid); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req core.ExportVMRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				sendError(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Format == "" {
+			req.Format = core.ExportFormatOVA
+		}
+		if req.Format != core.ExportFormatOVA && req.Format != core.ExportFormatTar {
+			sendError(w, "format must be ova or tar", http.StatusBadRequest)
+			return
+		}
+
+		client := s.clientFor(r)
+		vm, err := client.GetVMDetails(uuid)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err := os.MkdirAll(s.exportPath, 0750); err != nil {
+			sendError(w, fmt.Sprintf("Failed to create export directory: %v", err), http.StatusInternalServerError)
+			return
+		}
+		// Clients never choose where the server writes
+		req.Path = filepath.Join(s.exportPath, fmt.Sprintf("%s-%s.%s",
+			exportNameChars.ReplaceAllString(vm.Name, "-"), time.Now().UTC().Format("20060102-150405"), req.Format))
+
+		job, ok := s.runLongJob(w, r, "vm.export", uuid, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return client.ExportVM(ctx, uuid, req, p.Update)
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, fmt.Sprintf("Failed to export VM: %s", job.Error), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
+
+// handleImportVM defines a VM from an archive or OVF descriptor in the export directory
+func (s *Server) handleImportVM() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req core.ImportVMRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		path, err := s.exportFilePath(req.File)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			sendError(w, fmt.Sprintf("File %s not found in the export directory", req.File), http.StatusNotFound)
+			return
+		}
+		req.File = path
+
+		client := s.clientFor(r)
+		job, ok := s.runLongJob(w, r, "vm.import", filepath.Base(path), func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return client.ImportVM(ctx, req, p.Update)
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, fmt.Sprintf("Failed to import VM: %s", job.Error), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
+
+// handleListExports lists the files in the export directory
+func (s *Server) handleListExports() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		files := []core.ExportFile{}
+		entries, err := os.ReadDir(s.exportPath)
+		if err != nil && !os.IsNotExist(err) {
+			sendError(w, fmt.Sprintf("Failed to read export directory: %v", err), http.StatusInternalServerError)
+			return
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			if _, err := s.exportFilePath(entry.Name()); err != nil {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, core.ExportFile{Name: entry.Name(), SizeBytes: info.Size(), ModifiedAt: info.ModTime()})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(files)
+	}
+}
+
+// handleDownloadExport sends a file from the export directory, with range support
+func (s *Server) handleDownloadExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path, err := s.exportFilePath(chi.URLParam(r, "file"))
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			sendError(w, "File not found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			sendError(w, "File not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	}
+}
+
+// handleUploadExport stores the request body as a file in the export
+// directory, for importing afterwards. Existing files are not replaced, and
+// bodies over the configured size limit are refused.
+func (s *Server) handleUploadExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "file")
+		path, err := s.exportFilePath(name)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if s.exportMaxSize > 0 {
+			if r.ContentLength > s.exportMaxSize {
+				sendError(w, fmt.Sprintf("File is larger than the %d byte limit", s.exportMaxSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, s.exportMaxSize)
+		}
+		if _, err := os.Stat(path); err == nil {
+			sendError(w, fmt.Sprintf("File %s already exists", name), http.StatusConflict)
+			return
+		}
+		if err := os.MkdirAll(s.exportPath, 0750); err != nil {
+			sendError(w, fmt.Sprintf("Failed to create export directory: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		part := path + ".part"
+		out, err := os.OpenFile(part, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+		if err != nil {
+			sendError(w, fmt.Sprintf("Failed to create file: %v", err), http.StatusConflict)
+			return
+		}
+		size, err := io.Copy(out, r.Body)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(part, path)
+		}
+		if err != nil {
+			os.Remove(part)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				sendError(w, fmt.Sprintf("File is larger than the %d byte limit", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			sendError(w, fmt.Sprintf("Failed to store upload: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(core.ExportFile{Name: name, SizeBytes: size, ModifiedAt: time.Now()})
+	}
+}
+
+// handleDeleteExport removes a file from the export directory
+func (s *Server) handleDeleteExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path, err := s.exportFilePath(chi.URLParam(r, "file"))
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := os.Remove(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				sendError(w, "File not found", http.StatusNotFound)
+				return
+			}
+			sendError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+func exportRequest(method, file, body string) *http.Request {
+	r := httptest.NewRequest(method, "/api/exports/"+file, strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("file", file)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestExportDirectoryHandlers(t *testing.T) {
+	s := &Server{exportPath: t.TempDir()}
+
+	w := httptest.NewRecorder()
+	s.handleUploadExport()(w, exportRequest(http.MethodPut, "web-01.ova", "archive"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.handleUploadExport()(w, exportRequest(http.MethodPut, "web-01.ova", "again"))
+	if w.Code != http.StatusConflict {
+		t.Errorf("second upload status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleListExports()(w, httptest.NewRequest(http.MethodGet, "/api/exports", nil))
+	var files []core.ExportFile
+	if err := json.NewDecoder(w.Body).Decode(&files); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "web-01.ova" || files[0].SizeBytes != int64(len("archive")) {
+		t.Errorf("list = %+v, want web-01.ova", files)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleDownloadExport()(w, exportRequest(http.MethodGet, "web-01.ova", ""))
+	if w.Code != http.StatusOK || w.Body.String() != "archive" {
+		t.Errorf("download = %d %q, want the uploaded archive", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.handleDeleteExport()(w, exportRequest(http.MethodDelete, "web-01.ova", ""))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	w = httptest.NewRecorder()
+	s.handleDeleteExport()(w, exportRequest(http.MethodDelete, "web-01.ova", ""))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second delete status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestExportFilePath_RejectsUnsafeNames(t *testing.T) {
+	s := &Server{exportPath: "/var/lib/flint/exports"}
+	for _, name := range []string{"", "..", "../etc/passwd", "sub/web.ova", ".flint-export-1", "web.ova.part"} {
+		if _, err := s.exportFilePath(name); err == nil {
+			t.Errorf("exportFilePath(%q) expected an error", name)
+		}
+	}
+	if path, err := s.exportFilePath("web-01.ova"); err != nil || path != "/var/lib/flint/exports/web-01.ova" {
+		t.Errorf("exportFilePath(web-01.ova) = %q, %v", path, err)
+	}
+}
+
+func TestHandleUploadExport_SizeLimit(t *testing.T) {
+	s := &Server{exportPath: t.TempDir(), exportMaxSize: 4}
+
+	tests := []struct {
+		name          string
+		file          string
+		body          string
+		contentLength int64
+		want          int
+	}{
+		{"within the limit", "small.ova", "abcd", 4, http.StatusCreated},
+		{"declared size over the limit", "big.ova", "abcde", 5, http.StatusRequestEntityTooLarge},
+		{"unknown size over the limit", "chunked.ova", "abcde", -1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := exportRequest(http.MethodPut, tt.file, tt.body)
+			r.ContentLength = tt.contentLength
+			w := httptest.NewRecorder()
+			s.handleUploadExport()(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want != http.StatusCreated {
+				if _, err := os.Stat(filepath.Join(s.exportPath, tt.file)); !os.IsNotExist(err) {
+					t.Errorf("%s was stored, want it refused", tt.file)
+				}
+				if _, err := os.Stat(filepath.Join(s.exportPath, tt.file+".part")); !os.IsNotExist(err) {
+					t.Errorf("%s.part was left behind", tt.file)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/volantvm/flint/pkg/fleet"
+)
+
+// fleetAggregator builds an aggregator honouring the optional ?timeout= query
+// parameter, which is capped at fleet.MaxTimeout. It writes the error response
+// and returns false if the aggregator cannot be built.
+func (s *Server) fleetAggregator(w http.ResponseWriter, r *http.Request) (*fleet.Aggregator, bool) {
+	if s.serverRegistry == nil || s.connectionPool == nil {
+		http.Error(w, `{"error": "multi-server mode is not available"}`, http.StatusServiceUnavailable)
+		return nil, false
+	}
+
+	timeout := fleet.DefaultTimeout
+	if raw := r.URL.Query().Get("timeout"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			http.Error(w, `{"error": "timeout must be a positive duration, e.g. 30s"}`, http.StatusBadRequest)
+			return nil, false
+		}
+		timeout = d // NewAggregator caps it
+	}
+
+	return fleet.NewAggregator(s.serverRegistry, s.connectionPool, timeout), true
+}
+
+// handleGetFleetVMs returns the VMs of every registered server
+func (s *Server) handleGetFleetVMs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aggregator, ok := s.fleetAggregator(w, r)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(aggregator.VMs(r.Context()))
+	}
+}
+
+// handleGetFleetStoragePools returns the storage pools of every registered server
+func (s *Server) handleGetFleetStoragePools() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aggregator, ok := s.fleetAggregator(w, r)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(aggregator.StoragePools(r.Context()))
+	}
+}
+
+// handleGetFleetNetworks returns the virtual networks of every registered server
+func (s *Server) handleGetFleetNetworks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aggregator, ok := s.fleetAggregator(w, r)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(aggregator.Networks(r.Context()))
+	}
+}
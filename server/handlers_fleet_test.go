@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/volantvm/flint/pkg/connectionpool"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/serverregistry"
+)
+
+// fleetTestClient is a pooled client with one VM
+type fleetTestClient struct {
+	libvirtclient.ClientInterface
+}
+
+func (c *fleetTestClient) GetVMSummaries() ([]core.VM_Summary, error) {
+	return []core.VM_Summary{{Name: "web-01"}}, nil
+}
+
+func (c *fleetTestClient) Close() error { return nil }
+
+func TestHandleGetFleetVMs_Timeout(t *testing.T) {
+	registry, err := serverregistry.NewRegistry(filepath.Join(t.TempDir(), "servers.json"))
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	if _, err := registry.AddServer(core.CreateServerRequest{Name: "host-a", URI: "qemu+ssh://root@host-a/system"}); err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+	pool := connectionpool.NewPoolWithDialer(time.Hour, time.Hour, func(config *core.ServerConfig) (libvirtclient.ClientInterface, error) {
+		return &fleetTestClient{}, nil
+	})
+	defer pool.CloseAll()
+	s := &Server{serverRegistry: registry, connectionPool: pool}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"?timeout=30s", http.StatusOK},
+		{"?timeout=24h", http.StatusOK}, // Capped at fleet.MaxTimeout
+		{"?timeout=0s", http.StatusBadRequest},
+		{"?timeout=-5s", http.StatusBadRequest},
+		{"?timeout=soon", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.handleGetFleetVMs()(w, httptest.NewRequest(http.MethodGet, "/api/fleet/vms"+tt.query, nil))
+		if w.Code != tt.want {
+			t.Errorf("%q: status = %d, want %d: %s", tt.query, w.Code, tt.want, w.Body.String())
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		var list core.FleetVMList
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("%q: failed to decode response: %v", tt.query, err)
+		}
+		if len(list.Items) != 1 || list.Items[0].ServerName != "host-a" {
+			t.Errorf("%q: unexpected VMs %+v", tt.query, list.Items)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	(&Server{}).handleGetFleetVMs()(w, httptest.NewRequest(http.MethodGet, "/api/fleet/vms", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a registry: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
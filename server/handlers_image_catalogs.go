@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/imagerepository"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// initImageCatalogs opens the image catalog store and refreshes its catalogs
+// in the background, serving the images cached from the last refresh meanwhile
+func (s *Server) initImageCatalogs() {
+	store, err := imagerepository.NewCatalogStore("")
+	if err != nil {
+		logger.Error("Failed to initialize image catalog store, image catalogs disabled", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	store.Downloader = s.downloader
+	s.imageRepo.Catalogs = store
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		for _, catalog := range store.RefreshAll(ctx) {
+			if catalog.LastError != "" {
+				logger.Warn("Failed to refresh image catalog", map[string]interface{}{
+					"catalog": catalog.Name,
+					"error":   catalog.LastError,
+				})
+			}
+		}
+	}()
+}
+
+// handleListImageCatalogs returns the image catalogs, without their images
+func (s *Server) handleListImageCatalogs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalogs := s.imageRepo.Catalogs.List()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(catalogs)
+	}
+}
+
+// handleGetImageCatalog returns one catalog with its images
+func (s *Server) handleGetImageCatalog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalog, err := s.imageRepo.Catalogs.Get(chi.URLParam(r, "name"))
+		if err != nil {
+			sendImageCatalogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(catalog)
+	}
+}
+
+// handleAddImageCatalog adds a catalog, reading it right away so that bad
+// locations are reported to the caller
+func (s *Server) handleAddImageCatalog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req imagerepository.CatalogRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		catalog, err := s.imageRepo.Catalogs.Add(r.Context(), req)
+		if err != nil {
+			sendImageCatalogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(catalog)
+	}
+}
+
+// handleRefreshImageCatalogs refreshes every catalog. Catalogs that failed
+// to refresh have LastError set and keep their previous images.
+func (s *Server) handleRefreshImageCatalogs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalogs := s.imageRepo.Catalogs.RefreshAll(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(catalogs)
+	}
+}
+
+// handleRefreshImageCatalog refreshes one catalog
+func (s *Server) handleRefreshImageCatalog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		catalog, err := s.imageRepo.Catalogs.Refresh(r.Context(), chi.URLParam(r, "name"))
+		if err != nil {
+			sendImageCatalogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(catalog)
+	}
+}
+
+// handleDeleteImageCatalog removes a catalog, keeping images downloaded from it
+func (s *Server) handleDeleteImageCatalog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.imageRepo.Catalogs.Remove(chi.URLParam(r, "name")); err != nil {
+			sendImageCatalogError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// sendImageCatalogError reports unknown catalogs as 404, duplicates as 409
+// and catalogs that could not be read as 422
+func sendImageCatalogError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, imagerepository.ErrCatalogNotFound):
+		sendError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, imagerepository.ErrCatalogExists):
+		sendError(w, err.Error(), http.StatusConflict)
+	default:
+		sendError(w, err.Error(), http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/config"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/imageupload"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// UploadOffsetHeader carries the offset of a chunk in PATCH requests, and
+// the bytes received so far in responses
+const UploadOffsetHeader = "Upload-Offset"
+
+// initImageUploads sets up resumable image uploads with the limits in config.Config
+func (s *Server) initImageUploads(cfg *config.Config) {
+	maxSize := int64(cfg.Upload.MaxSizeGB) << 30
+	ttl := time.Duration(cfg.Upload.SessionTTLHours) * time.Hour
+	manager, err := imageupload.NewManager("", maxSize, ttl, s.imageUploaderFor)
+	if err != nil {
+		logger.Error("Failed to initialize image upload store, image uploads disabled", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	s.imageUploads = manager
+}
+
+// imageUploaderFor returns the connection an upload writes to
+func (s *Server) imageUploaderFor(serverID string) (imageupload.Uploader, error) {
+	if serverID == "" {
+		return s.client, nil
+	}
+	_, client, _, err := s.resolveServerClient(serverID)
+	return client, err
+}
+
+// imageUploadStatus maps upload errors to HTTP status codes
+func imageUploadStatus(err error) int {
+	switch {
+	case errors.Is(err, imageupload.ErrUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, imageupload.ErrOffsetMismatch), errors.Is(err, imageupload.ErrUploadBusy), errors.Is(err, imageupload.ErrImageExists):
+		return http.StatusConflict
+	case errors.Is(err, imageupload.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, imageupload.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, imageupload.ErrChecksumMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, imageupload.ErrInvalidUpload):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// handleUploadImage stores an image sent in a single request, either as the
+// raw body (?name=&sha256=&format=) or as a multipart form with the same
+// fields followed by a "file" part
+func (s *Server) handleUploadImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.imageUploads == nil {
+			sendError(w, "Image uploads are not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		q := r.URL.Query()
+		req := core.CreateImageUploadRequest{Name: q.Get("name"), SHA256: q.Get("sha256"), Format: q.Get("format")}
+		var body io.Reader = r.Body
+
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+			mr, err := r.MultipartReader()
+			if err != nil {
+				sendError(w, "Invalid multipart body", http.StatusBadRequest)
+				return
+			}
+			body = nil
+			for body == nil {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					sendError(w, "Multipart body has no file part", http.StatusBadRequest)
+					return
+				}
+				if err != nil {
+					sendError(w, "Invalid multipart body", http.StatusBadRequest)
+					return
+				}
+				if part.FormName() == "file" {
+					if req.Name == "" {
+						req.Name = filepath.Base(part.FileName())
+					}
+					body = part
+					break
+				}
+				value, err := io.ReadAll(io.LimitReader(part, 1024))
+				if err != nil {
+					sendError(w, "Invalid multipart body", http.StatusBadRequest)
+					return
+				}
+				switch part.FormName() {
+				case "name":
+					req.Name = strings.TrimSpace(string(value))
+				case "sha256":
+					req.SHA256 = strings.TrimSpace(string(value))
+				case "format":
+					req.Format = strings.TrimSpace(string(value))
+				}
+			}
+		} else if r.ContentLength > 0 {
+			req.SizeBytes = r.ContentLength
+		}
+
+		image, err := s.imageUploads.Upload(r.Context(), s.clientFor(r), req, body)
+		if err != nil {
+			sendError(w, fmt.Sprintf("Failed to upload image: %v", err), imageUploadStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(image)
+	}
+}
+
+// handleCreateImageUpload starts a resumable upload
+func (s *Server) handleCreateImageUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.imageUploads == nil {
+			sendError(w, "Image uploads are not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req core.CreateImageUploadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		upload, err := s.imageUploads.Begin(s.clientFor(r), serverIDFor(r), req)
+		if err != nil {
+			sendError(w, err.Error(), imageUploadStatus(err))
+			return
+		}
+
+		w.Header().Set("Location", "/api/images/uploads/"+upload.ID)
+		w.Header().Set(UploadOffsetHeader, "0")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(upload)
+	}
+}
+
+// handleListImageUploads lists the resumable uploads of the selected server
+func (s *Server) handleListImageUploads() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uploads := []core.ImageUpload{}
+		if s.imageUploads != nil {
+			uploads = s.imageUploads.List(serverIDFor(r))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(uploads)
+	}
+}
+
+// handleGetImageUpload returns a resumable upload; HEAD requests only get
+// the Upload-Offset header, for clients working out where to resume
+func (s *Server) handleGetImageUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.imageUploads == nil {
+			sendError(w, "Image uploads are not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		upload, err := s.imageUploads.Get(serverIDFor(r), chi.URLParam(r, "uploadId"))
+		if err != nil {
+			sendError(w, err.Error(), imageUploadStatus(err))
+			return
+		}
+
+		w.Header().Set(UploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(upload)
+	}
+}
+
+// handlePatchImageUpload writes the request body to a resumable upload at
+// the offset in the Upload-Offset header
+func (s *Server) handlePatchImageUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.imageUploads == nil {
+			sendError(w, "Image uploads are not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		offset, err := strconv.ParseInt(r.Header.Get(UploadOffsetHeader), 10, 64)
+		if err != nil || offset < 0 {
+			sendError(w, "Upload-Offset header is required", http.StatusBadRequest)
+			return
+		}
+
+		upload, err := s.imageUploads.Write(r.Context(), s.clientFor(r), serverIDFor(r), chi.URLParam(r, "uploadId"), offset, r.Body)
+		if upload.ID != "" {
+			w.Header().Set(UploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+		}
+		if err != nil {
+			sendError(w, err.Error(), imageUploadStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(upload)
+	}
+}
+
+// handleDeleteImageUpload cancels a resumable upload and deletes its partial image
+func (s *Server) handleDeleteImageUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.imageUploads == nil {
+			sendError(w, "Image uploads are not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		if err := s.imageUploads.Abort(s.clientFor(r), serverIDFor(r), chi.URLParam(r, "uploadId")); err != nil {
+			sendError(w, err.Error(), imageUploadStatus(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
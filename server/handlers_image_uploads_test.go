@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/imageupload"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/serverregistry"
+)
+
+// uploadTestClient keeps the image library in memory; other methods are not implemented
+type uploadTestClient struct {
+	libvirtclient.ClientInterface
+	volumes map[string][]byte
+}
+
+func (c *uploadTestClient) GetImages() ([]core.Image, error) {
+	var images []core.Image
+	for name, data := range c.volumes {
+		images = append(images, core.Image{ID: name, Name: name, SizeB: uint64(len(data))})
+	}
+	return images, nil
+}
+
+func (c *uploadTestClient) CreateImageUploadVolume(name string, sizeBytes uint64) error {
+	c.volumes[name] = nil
+	return nil
+}
+
+func (c *uploadTestClient) UploadImage(ctx context.Context, name string, offset uint64, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	c.volumes[name] = append(c.volumes[name][:offset], data...)
+	return int64(len(data)), nil
+}
+
+func (c *uploadTestClient) FinishImageUpload(name string) (core.Image, error) {
+	return core.Image{ID: name, Name: name, SizeB: uint64(len(c.volumes[name]))}, nil
+}
+
+func (c *uploadTestClient) DeleteImage(imageId string) error {
+	delete(c.volumes, imageId)
+	return nil
+}
+
+func newUploadTestServer(t *testing.T) (*Server, *uploadTestClient) {
+	t.Helper()
+	client := &uploadTestClient{volumes: make(map[string][]byte)}
+	s := &Server{client: client}
+	manager, err := imageupload.NewManager(filepath.Join(t.TempDir(), "image-uploads.json"), 1<<20, time.Hour, s.imageUploaderFor)
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	s.imageUploads = manager
+	return s, client
+}
+
+func uploadRequest(method, id string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, "/api/images/uploads/"+id, body)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uploadId", id)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+// qcow2Image returns a small image with a qcow2 v3 header
+func qcow2Image(size int) []byte {
+	data := make([]byte, size)
+	copy(data, "QFI\xfb")
+	binary.BigEndian.PutUint32(data[4:8], 3)
+	for i := 128; i < size; i++ {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func TestHandleUploadImage(t *testing.T) {
+	s, client := newUploadTestServer(t)
+	data := qcow2Image(4096)
+	sum := sha256.Sum256(data)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/api/images/upload?name=raw-body.qcow2&sha256="+hex.EncodeToString(sum[:]), bytes.NewReader(data))
+	s.handleUploadImage()(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("raw upload status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+	if !bytes.Equal(client.volumes["raw-body.qcow2"], data) {
+		t.Error("raw upload stored different data")
+	}
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	mw.WriteField("sha256", hex.EncodeToString(sum[:]))
+	part, _ := mw.CreateFormFile("file", "/home/me/form.qcow2")
+	part.Write(data)
+	mw.Close()
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPut, "/api/images/upload", &form)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	s.handleUploadImage()(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("multipart upload status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+	if _, ok := client.volumes["form.qcow2"]; !ok {
+		t.Error("multipart upload did not use the file name of the part")
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		body  []byte
+		want  int
+	}{
+		{"existing image", "?name=raw-body.qcow2", data, http.StatusConflict},
+		{"bad checksum", "?name=bad.qcow2&sha256=" + hex.EncodeToString(make([]byte, 32)), data, http.StatusUnprocessableEntity},
+		{"iso extension", "?name=disk.iso", data, http.StatusUnsupportedMediaType},
+		{"over the limit", "?name=big.img", make([]byte, 2<<20), http.StatusRequestEntityTooLarge},
+		{"no name", "", data, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.handleUploadImage()(w, httptest.NewRequest(http.MethodPut, "/api/images/upload"+tt.query, bytes.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
+
+func TestImageUploadSessionHandlers(t *testing.T) {
+	s, client := newUploadTestServer(t)
+	data := qcow2Image(imageupload.SniffSize + 1000)
+
+	body, _ := json.Marshal(core.CreateImageUploadRequest{Name: "chunked.qcow2", SizeBytes: int64(len(data))})
+	w := httptest.NewRecorder()
+	s.handleCreateImageUpload()(w, httptest.NewRequest(http.MethodPost, "/api/images/uploads", bytes.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+	var upload core.ImageUpload
+	if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+		t.Fatalf("decode upload: %v", err)
+	}
+	if w.Header().Get("Location") != "/api/images/uploads/"+upload.ID {
+		t.Errorf("Location = %q", w.Header().Get("Location"))
+	}
+
+	patch := func(offset int, chunk []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := uploadRequest(http.MethodPatch, upload.ID, bytes.NewReader(chunk))
+		r.Header.Set(UploadOffsetHeader, strconv.Itoa(offset))
+		s.handlePatchImageUpload()(w, r)
+		return w
+	}
+
+	split := imageupload.SniffSize
+	if w := patch(0, data[:split]); w.Code != http.StatusOK || w.Header().Get(UploadOffsetHeader) != strconv.Itoa(split) {
+		t.Fatalf("first chunk = %d, offset %q: %s", w.Code, w.Header().Get(UploadOffsetHeader), w.Body.String())
+	}
+	if w := patch(0, data[:split]); w.Code != http.StatusConflict || w.Header().Get(UploadOffsetHeader) != strconv.Itoa(split) {
+		t.Errorf("stale chunk = %d, offset %q, want 409 at %d", w.Code, w.Header().Get(UploadOffsetHeader), split)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleGetImageUpload()(w, uploadRequest(http.MethodHead, upload.ID, nil))
+	if w.Header().Get(UploadOffsetHeader) != strconv.Itoa(split) {
+		t.Errorf("HEAD offset = %q, want %d", w.Header().Get(UploadOffsetHeader), split)
+	}
+
+	w = patch(split, data[split:])
+	if w.Code != http.StatusOK {
+		t.Fatalf("last chunk status = %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.NewDecoder(w.Body).Decode(&upload); err != nil {
+		t.Fatalf("decode upload: %v", err)
+	}
+	if !upload.Completed || upload.Image == nil || upload.Format != core.ImageFormatQCOW2 {
+		t.Errorf("last chunk = %+v, want a completed qcow2 upload", upload)
+	}
+	if !bytes.Equal(client.volumes["chunked.qcow2"], data) {
+		t.Error("chunked upload stored different data")
+	}
+
+	w = httptest.NewRecorder()
+	s.handleDeleteImageUpload()(w, uploadRequest(http.MethodDelete, upload.ID, nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	w = httptest.NewRecorder()
+	s.handleGetImageUpload()(w, uploadRequest(http.MethodGet, upload.ID, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestImportDownloadedImage_RemoteServer(t *testing.T) {
+	s, client := newUploadTestServer(t)
+	registry, err := serverregistry.NewRegistry(filepath.Join(t.TempDir(), "servers.json"))
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	remote, err := registry.AddServer(core.CreateServerRequest{Name: "host-b", URI: "qemu+ssh://root@host-b/system"})
+	if err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+	s.serverRegistry = registry
+
+	data := qcow2Image(4096)
+	path := filepath.Join(t.TempDir(), "debian-12.qcow2")
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	image, err := s.importDownloadedImage(context.Background(), remote.ID, client, path)
+	if err != nil {
+		t.Fatalf("importDownloadedImage() error = %v", err)
+	}
+	if image.Name != "debian-12.qcow2" {
+		t.Errorf("image name = %q, want debian-12.qcow2", image.Name)
+	}
+	if !bytes.Equal(client.volumes["debian-12.qcow2"], data) {
+		t.Error("the remote server received different data")
+	}
+}
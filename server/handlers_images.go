@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+// handleGetImage returns an image with its qemu-img info and the volumes and VMs that need it
+func (s *Server) handleGetImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		imageId := chi.URLParam(r, "imageId")
+		details, err := s.clientFor(r).GetImageDetails(imageId)
+		if err != nil {
+			if strings.Contains(err.Error(), "image not found") {
+				sendError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			sendError(w, fmt.Sprintf("Failed to inspect image: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(details)
+	}
+}
+
+// handleConvertImage converts, compresses or sparsifies an image as a job
+func (s *Server) handleConvertImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		imageId := chi.URLParam(r, "imageId")
+
+		var req core.ConvertImageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		switch req.Operation {
+		case core.ImageOpConvert, core.ImageOpCompress, core.ImageOpSparsify:
+		default:
+			sendError(w, "operation must be convert, compress or sparsify", http.StatusBadRequest)
+			return
+		}
+
+		client := s.clientFor(r)
+		job, ok := s.runLongJob(w, r, "image."+req.Operation, imageId, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return client.ConvertImage(ctx, imageId, req, p.Update)
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			status := http.StatusInternalServerError
+			if strings.Contains(job.Error, libvirtclient.ErrImageInUse.Error()) {
+				status = http.StatusConflict
+			}
+			sendError(w, fmt.Sprintf("Failed to %s image: %s", req.Operation, job.Error), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+// imageTestClient converts images in memory; other methods are not implemented
+type imageTestClient struct {
+	libvirtclient.ClientInterface
+	inUse map[string]bool
+}
+
+func (c *imageTestClient) ConvertImage(ctx context.Context, imageId string, req core.ConvertImageRequest, progress func(float64, string)) (core.Image, error) {
+	if c.inUse[imageId] && req.Name == "" {
+		return core.Image{}, fmt.Errorf("%w by vm web-01", libvirtclient.ErrImageInUse)
+	}
+	progress(50, "Converting")
+	name := req.Name
+	if name == "" {
+		name = strings.TrimSuffix(imageId, ".vmdk") + ".qcow2"
+	}
+	return core.Image{ID: name, Name: name, Type: "template"}, nil
+}
+
+func (c *imageTestClient) DeleteImage(imageId string) error {
+	if c.inUse[imageId] {
+		return fmt.Errorf("%w by volume web-01-disk-0.qcow2", libvirtclient.ErrImageInUse)
+	}
+	return nil
+}
+
+func imageRequest(method, imageId, body string) *http.Request {
+	r := httptest.NewRequest(method, "/api/images/"+imageId+"/convert", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("imageId", imageId)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestHandleConvertImage(t *testing.T) {
+	s := &Server{
+		client:     &imageTestClient{inUse: map[string]bool{"noble.img": true}},
+		jobManager: jobs.NewManager(0, 0, 0),
+	}
+
+	w := httptest.NewRecorder()
+	s.handleConvertImage()(w, imageRequest(http.MethodPost, "appliance.vmdk", `{"operation": "convert"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("convert status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+	var image core.Image
+	if err := json.NewDecoder(w.Body).Decode(&image); err != nil || image.Name != "appliance.qcow2" {
+		t.Errorf("convert returned %+v, %v", image, err)
+	}
+
+	tests := []struct {
+		name    string
+		imageId string
+		body    string
+		want    int
+	}{
+		{"unknown operation", "appliance.vmdk", `{"operation": "shrink"}`, http.StatusBadRequest},
+		{"in place while in use", "noble.img", `{"operation": "compress"}`, http.StatusConflict},
+		{"new name while in use", "noble.img", `{"operation": "compress", "name": "noble-small.img"}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.handleConvertImage()(w, imageRequest(http.MethodPost, tt.imageId, tt.body))
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.handleDeleteImage()(w, imageRequest(http.MethodDelete, "noble.img", ""))
+	if w.Code != http.StatusConflict {
+		t.Errorf("delete of an image in use status = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+)
+
+// wantsAsync reports whether the client asked for a job handle instead of waiting
+func wantsAsync(r *http.Request) bool {
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	return async
+}
+
+// runJob submits fn to the job manager as a short job so every slow operation is
+// tracked in /api/jobs. With ?async=true the client gets 202 Accepted and the
+// queued job, and runJob returns false. Otherwise it waits and returns the
+// finished job for the handler to render as the endpoint always has.
+func (s *Server) runJob(w http.ResponseWriter, r *http.Request, jobType, target string, fn jobs.Func) (core.Job, bool) {
+	return s.awaitJob(w, r, s.jobManager.Submit(jobType, target, serverIDFor(r), fn))
+}
+
+// runLongJob is runJob for operations that move bulk data or fan out over many
+// VMs. They run on the long workers so they never hold up short jobs.
+func (s *Server) runLongJob(w http.ResponseWriter, r *http.Request, jobType, target string, fn jobs.Func) (core.Job, bool) {
+	return s.awaitJob(w, r, s.jobManager.SubmitTo(jobs.Long, jobType, target, serverIDFor(r), fn))
+}
+
+// awaitJob answers 202 for ?async=true or waits for the job to finish
+func (s *Server) awaitJob(w http.ResponseWriter, r *http.Request, job core.Job) (core.Job, bool) {
+	if wantsAsync(r) {
+		sendJobAccepted(w, job)
+		return job, false
+	}
+
+	job, err := s.jobManager.Wait(r.Context(), job.ID)
+	if err != nil {
+		// The client went away; the job keeps running and stays visible in /api/jobs
+		return job, false
+	}
+	return job, true
+}
+
+// sendJobAccepted responds 202 with the job and where to poll it
+func sendJobAccepted(w http.ResponseWriter, job core.Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleListJobs returns tracked jobs, newest first, filtered by ?type=, ?state=, ?target= and ?server=
+func (s *Server) handleListJobs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		filter := jobs.Filter{
+			Type:     q.Get("type"),
+			Target:   q.Get("target"),
+			ServerID: q.Get("server"),
+			State:    core.JobState(q.Get("state")),
+		}
+
+		identity := identityFor(r)
+		list := s.jobManager.List(filter)
+		visible := make([]core.Job, 0, len(list))
+		for _, job := range list {
+			if s.jobInScope(identity, job) {
+				visible = append(visible, job)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(visible)
+	}
+}
+
+// handleGetJob returns a single job
+func (s *Server) handleGetJob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := s.jobManager.Get(chi.URLParam(r, "jobId"))
+		if err != nil || !s.jobInScope(identityFor(r), job) {
+			sendError(w, jobs.ErrNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// handleCancelJob requests cancellation of a queued or running job
+func (s *Server) handleCancelJob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobID := chi.URLParam(r, "jobId")
+		if job, err := s.jobManager.Get(jobID); err == nil && !s.jobInScope(identityFor(r), job) {
+			sendError(w, jobs.ErrNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		job, err := s.jobManager.Cancel(jobID)
+		if errors.Is(err, jobs.ErrNotFound) {
+			sendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, jobs.ErrFinished) {
+			sendError(w, "Job already "+string(job.State), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	}
+}
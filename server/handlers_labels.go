@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// handleUpdateVMLabels changes a VM's labels and description and returns the
+// updated VM
+func (s *Server) handleUpdateVMLabels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		if err := validateUUID(uuid); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req core.UpdateVMLabelsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid JSON in request body", http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		vm, err := s.clientFor(r).UpdateVMLabels(uuid, req)
+		if err != nil {
+			if strings.Contains(err.Error(), "lookup domain") {
+				sendError(w, "VM not found", http.StatusNotFound)
+				return
+			}
+			sendError(w, fmt.Sprintf("Failed to update labels: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(vm)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+const labelTestUUID = "0b6f3c2e-5d1a-4c3b-9e2f-1a2b3c4d5e6f"
+
+// labelTestClient keeps VM labels in memory; other methods are not implemented
+type labelTestClient struct {
+	libvirtclient.ClientInterface
+	vms []core.VM_Summary
+}
+
+func (c *labelTestClient) GetVMSummaries() ([]core.VM_Summary, error) { return c.vms, nil }
+
+func (c *labelTestClient) UpdateVMLabels(uuidStr string, req core.UpdateVMLabelsRequest) (core.VM_Detailed, error) {
+	for i := range c.vms {
+		if c.vms[i].UUID == uuidStr {
+			c.vms[i].Labels = req.Apply(c.vms[i].Labels)
+			if req.Description != nil {
+				c.vms[i].Description = *req.Description
+			}
+			return core.VM_Detailed{VM_Summary: c.vms[i]}, nil
+		}
+	}
+	return core.VM_Detailed{}, fmt.Errorf("lookup domain: not found")
+}
+
+func TestHandleGetVMs_LabelSelector(t *testing.T) {
+	s := &Server{client: &labelTestClient{vms: []core.VM_Summary{
+		{Name: "web-01", Labels: map[string]string{"env": "prod", "tier": "web"}},
+		{Name: "db-01", Labels: map[string]string{"env": "prod"}},
+		{Name: "web-02", Labels: map[string]string{"env": "dev", "tier": "web"}},
+		{Name: "scratch"},
+	}}}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "web-01,db-01,web-02,scratch"},
+		{"?label=env=prod", "web-01,db-01"},
+		{"?label=env=prod&label=tier=web", "web-01"},
+		{"?label=env=prod,tier", "web-01"},
+		{"?label=env!=prod", "web-02,scratch"},
+		{"?label=!env", "scratch"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.handleGetVMs()(w, httptest.NewRequest(http.MethodGet, "/api/vms"+tt.query, nil))
+		var vms []core.VM_Summary
+		if err := json.NewDecoder(w.Body).Decode(&vms); err != nil {
+			t.Fatalf("%q: failed to decode response: %v", tt.query, err)
+		}
+		names := make([]string, len(vms))
+		for i, vm := range vms {
+			names[i] = vm.Name
+		}
+		if got := strings.Join(names, ","); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.query, got, tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.handleGetVMs()(w, httptest.NewRequest(http.MethodGet, "/api/vms?label=-bad=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid selector status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleUpdateVMLabels(t *testing.T) {
+	client := &labelTestClient{vms: []core.VM_Summary{
+		{Name: "web-01", UUID: labelTestUUID, Labels: map[string]string{"env": "dev", "owner": "ops"}},
+	}}
+	s := &Server{client: client}
+
+	patch := func(uuid, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPatch, "/api/vms/"+uuid+"/labels", strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("uuid", uuid)
+		w := httptest.NewRecorder()
+		s.handleUpdateVMLabels()(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+		return w
+	}
+
+	w := patch(labelTestUUID, `{"labels": {"env": "prod", "owner": null}, "description": "Front end"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var vm core.VM_Detailed
+	if err := json.NewDecoder(w.Body).Decode(&vm); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if core.FormatLabels(vm.Labels) != "env=prod" || vm.Description != "Front end" {
+		t.Errorf("unexpected labels %v and description %q", vm.Labels, vm.Description)
+	}
+
+	tests := []struct {
+		name string
+		uuid string
+		body string
+		want int
+	}{
+		{"invalid key", labelTestUUID, `{"labels": {"bad key": "x"}}`, http.StatusBadRequest},
+		{"invalid JSON", labelTestUUID, `{"labels": `, http.StatusBadRequest},
+		{"invalid UUID", "web-01", `{}`, http.StatusBadRequest},
+		{"unknown VM", "1b6f3c2e-5d1a-4c3b-9e2f-1a2b3c4d5e6f", `{"labels": {"env": "prod"}}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := patch(tt.uuid, tt.body); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/config"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/logger"
+	"github.com/volantvm/flint/pkg/metrics"
+)
+
+// defaultMetricsRange is how far back /metrics looks when ?from= is omitted
+const defaultMetricsRange = time.Hour
+
+// initMetrics opens the per-VM metrics store and starts sampling every running
+// VM on the local connection and all connected registered servers
+func (s *Server) initMetrics(cfg *config.Config) {
+	if !cfg.Metrics.Enabled {
+		return
+	}
+
+	interval := time.Duration(cfg.Metrics.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = metrics.DefaultInterval
+	}
+	retention := time.Duration(cfg.Metrics.RetentionHours) * time.Hour
+
+	retentionPolicy := metrics.Retention{MaxAge: retention}
+	if retention > 0 {
+		retentionPolicy.MaxPoints = int(retention / interval)
+	}
+
+	store, err := metrics.NewStore(cfg.Metrics.Path, retentionPolicy)
+	if err != nil {
+		logger.Error("Failed to open metrics store, VM metrics history disabled", map[string]interface{}{
+			"path":  cfg.Metrics.Path,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	s.metricsStore = store
+	s.metricsSampler = metrics.NewSampler(store, interval, s.metricsSources)
+	s.metricsSampler.Start()
+}
+
+// metricsSources returns the connections to sample. Registered servers are only
+// sampled while the pool holds a live connection to them, so an unreachable host
+// never stalls sampling.
+func (s *Server) metricsSources() map[string]metrics.Source {
+	sources := make(map[string]metrics.Source)
+	if s.client != nil {
+		sources[""] = s.client
+	}
+
+	if s.serverRegistry == nil || s.connectionPool == nil {
+		return sources
+	}
+	for _, server := range s.serverRegistry.ListServers() {
+		info, ok := s.connectionPool.GetConnectionInfo(server.ID)
+		if !ok || !info.IsConnected {
+			continue
+		}
+		if client, err := s.connectionPool.GetConnection(server); err == nil {
+			sources[server.ID] = client
+		}
+	}
+	return sources
+}
+
+// handleGetVMMetrics returns a VM's sampled history. ?from= and ?to= accept RFC
+// 3339, Unix seconds or a duration ago (default: the last hour); ?step= averages
+// samples into buckets (a duration such as 5m, or seconds).
+func (s *Server) handleGetVMMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		if err := validateUUID(uuid); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if s.metricsStore == nil {
+			sendError(w, "Metrics collection is disabled", http.StatusServiceUnavailable)
+			return
+		}
+
+		q := r.URL.Query()
+		from, err := parseActivityTime(q.Get("from"))
+		if err != nil {
+			sendError(w, fmt.Sprintf("invalid from: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		to, err := parseActivityTime(q.Get("to"))
+		if err != nil {
+			sendError(w, fmt.Sprintf("invalid to: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		if to.IsZero() {
+			to = time.Now()
+		}
+		if from.IsZero() {
+			from = to.Add(-defaultMetricsRange)
+		}
+		if !from.Before(to) {
+			sendError(w, "from must be before to", http.StatusBadRequest)
+			return
+		}
+
+		step, err := parseMetricsStep(q.Get("step"))
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		series := core.VMMetricsSeries{
+			UUID:   uuid,
+			From:   from.Unix(),
+			To:     to.Unix(),
+			Step:   int64(step / time.Second),
+			Points: s.metricsStore.Query(uuid, from, to, step),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(series)
+	}
+}
+
+// parseMetricsStep accepts a duration (5m) or a number of seconds (300)
+func parseMetricsStep(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, nil
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid step: expected a duration such as 5m or a number of seconds")
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+)
+
+// handleMigrateVM moves a VM from the selected server to another registered server
+func (s *Server) handleMigrateVM() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		if err := validateUUID(uuid); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req core.MigrateVMRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid JSON in request body", http.StatusBadRequest)
+			return
+		}
+		if err := validateMigrateVMRequest(&req); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		targetID, dest, status, err := s.resolveServerClient(req.TargetServer)
+		if err != nil {
+			sendError(w, err.Error(), status)
+			return
+		}
+		sourceID := serverIDFor(r)
+		if targetID == sourceID {
+			sendError(w, "VM is already on the target server", http.StatusBadRequest)
+			return
+		}
+
+		client := s.clientFor(r)
+		job, ok := s.runLongJob(w, r, "vm.migrate", uuid, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			p.Update(0, "Starting migration")
+			result, err := client.MigrateVM(ctx, uuid, dest, req, func(mp core.MigrationProgress) {
+				p.Update(mp.Percent, migrationProgressMessage(mp))
+			})
+			if err != nil {
+				return nil, err
+			}
+			result.SourceServer = sourceID
+			result.TargetServer = targetID
+			return result, nil
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, fmt.Sprintf("Failed to migrate VM: %s", job.Error), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
+
+// migrationProgressMessage summarises a progress sample for the job message
+func migrationProgressMessage(mp core.MigrationProgress) string {
+	const mib = 1024 * 1024
+	message := fmt.Sprintf("%s: %d/%d MiB transferred", mp.Phase, mp.DataProcessed/mib, mp.DataTotal/mib)
+	if mp.MemIteration > 0 {
+		message += fmt.Sprintf(", pass %d", mp.MemIteration)
+	}
+	if mp.BytesPerSecond > 0 {
+		message += fmt.Sprintf(", %d MiB/s", mp.BytesPerSecond/mib)
+	}
+	return message
+}
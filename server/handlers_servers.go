@@ -18,7 +18,11 @@ func (s *Server) handleListServers() http.HandlerFunc {
 		// Build response with status information
 		serversWithStatus := make([]core.ServerWithStatus, 0, len(servers))
 
+		identity := identityFor(r)
 		for _, server := range servers {
+			if !s.selectorInScope(identity, server.ID) {
+				continue
+			}
 			status := s.getServerStatus(server.ID)
 			serversWithStatus = append(serversWithStatus, core.ServerWithStatus{
 				ServerConfig: *server,
@@ -172,7 +176,10 @@ func (s *Server) handleDeleteServer() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		serverID := chi.URLParam(r, "serverID")
 
-		// Close connection if exists
+		// Stop watching its events and close the connection if it exists
+		if s.eventHub != nil {
+			s.eventHub.Unwatch(serverID)
+		}
 		s.connectionPool.CloseConnection(serverID)
 
 		// Delete from registry
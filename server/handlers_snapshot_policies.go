@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+	"github.com/volantvm/flint/pkg/logger"
+	"github.com/volantvm/flint/pkg/snapshotpolicy"
+)
+
+// initSnapshotPolicies opens the snapshot policy store and starts the scheduler
+func (s *Server) initSnapshotPolicies() {
+	store, err := snapshotpolicy.NewStore("")
+	if err != nil {
+		logger.Error("Failed to initialize snapshot policy store, scheduled snapshots disabled", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	s.snapshotPolicies = store
+	s.snapshotScheduler = snapshotpolicy.NewScheduler(store, 0, s.snapshotPolicyTargets, s.recordSnapshotPolicyRun)
+	s.snapshotScheduler.Start()
+}
+
+// snapshotPolicyTargets resolves the servers a policy applies to: every
+// registered server with one of its tags, its registered server, or the local
+// connection
+func (s *Server) snapshotPolicyTargets(policy core.SnapshotPolicy) ([]snapshotpolicy.Target, []error) {
+	var selectors []string
+	switch {
+	case len(policy.Tags) > 0:
+		if s.serverRegistry == nil {
+			return nil, []error{errors.New("multi-server mode is not available")}
+		}
+		for _, server := range s.serverRegistry.ListServers() {
+			if hasAnyTag(server.Tags, policy.Tags) {
+				selectors = append(selectors, server.ID)
+			}
+		}
+		if len(selectors) == 0 {
+			return nil, []error{fmt.Errorf("no registered server has tag %v", policy.Tags)}
+		}
+	case policy.ServerID != "":
+		selectors = []string{policy.ServerID}
+	default:
+		return []snapshotpolicy.Target{{Client: s.client}}, nil
+	}
+
+	var targets []snapshotpolicy.Target
+	var errs []error
+	for _, selector := range selectors {
+		serverID, client, _, err := s.resolveServerClient(selector)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		targets = append(targets, snapshotpolicy.Target{ServerID: serverID, Client: client})
+	}
+	return targets, errs
+}
+
+// recordSnapshotPolicyRun writes a policy run to the audit log
+func (s *Server) recordSnapshotPolicyRun(event core.ActivityEvent) {
+	logger.Info("Snapshot policy run", map[string]interface{}{
+		"policy":  event.Target,
+		"status":  event.Status,
+		"message": event.Message,
+	})
+	if s.auditStore == nil {
+		return
+	}
+	if err := s.auditStore.Record(event); err != nil {
+		logger.Warn("Failed to record snapshot policy run", map[string]interface{}{
+			"policy": event.Target,
+			"error":  err.Error(),
+		})
+	}
+}
+
+func hasAnyTag(tags, want []string) bool {
+	for _, tag := range tags {
+		for _, w := range want {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withNextRun fills in when the scheduler will next run a policy
+func (s *Server) withNextRun(policy core.SnapshotPolicy) core.SnapshotPolicy {
+	if next, ok := s.snapshotScheduler.NextRun(policy.ID); ok && policy.Enabled {
+		policy.NextRun = &next
+	}
+	return policy
+}
+
+// handleListSnapshotPolicies returns all snapshot policies
+func (s *Server) handleListSnapshotPolicies() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		policies := s.snapshotPolicies.List()
+		for i := range policies {
+			policies[i] = s.withNextRun(policies[i])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(policies)
+	}
+}
+
+// handleGetSnapshotPolicy returns one policy by ID or name
+func (s *Server) handleGetSnapshotPolicy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		policy, err := s.snapshotPolicies.Get(chi.URLParam(r, "policyId"))
+		if err != nil {
+			sendSnapshotPolicyError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.withNextRun(*policy))
+	}
+}
+
+// handleCreateSnapshotPolicy adds a policy. It first runs at the next slot of its schedule.
+func (s *Server) handleCreateSnapshotPolicy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req core.SnapshotPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		policy, err := s.snapshotPolicies.Add(req)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.snapshotScheduler.Tick()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(s.withNextRun(*policy))
+	}
+}
+
+// handleUpdateSnapshotPolicy replaces a policy's settings
+func (s *Server) handleUpdateSnapshotPolicy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req core.SnapshotPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		policy, err := s.snapshotPolicies.Update(chi.URLParam(r, "policyId"), req)
+		if err != nil {
+			sendSnapshotPolicyError(w, err)
+			return
+		}
+		s.snapshotScheduler.Reschedule(policy.ID)
+		s.snapshotScheduler.Tick()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.withNextRun(*policy))
+	}
+}
+
+// handleDeleteSnapshotPolicy removes a policy, keeping the snapshots it took
+func (s *Server) handleDeleteSnapshotPolicy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.snapshotPolicies.Delete(chi.URLParam(r, "policyId")); err != nil {
+			sendSnapshotPolicyError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleRunSnapshotPolicy runs a policy now, whether or not it is enabled
+func (s *Server) handleRunSnapshotPolicy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		policy, err := s.snapshotPolicies.Get(chi.URLParam(r, "policyId"))
+		if err != nil {
+			sendSnapshotPolicyError(w, err)
+			return
+		}
+
+		job, ok := s.runLongJob(w, r, "snapshot-policy.run", policy.Name, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return s.snapshotScheduler.RunNow(policy.ID)
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, job.Error, http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
+
+// sendSnapshotPolicyError maps snapshot policy store errors to HTTP statuses
+func sendSnapshotPolicyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, snapshotpolicy.ErrPolicyNotFound) {
+		sendError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendError(w, err.Error(), http.StatusBadRequest)
+}
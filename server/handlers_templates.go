@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/jobs"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// handleGetVMTemplates returns the templates registered for the selected server
+func (s *Server) handleGetVMTemplates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.templateStore == nil {
+			sendError(w, "Template store is not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		serverIDs := []string{s.templateServerID(serverIDFor(r))}
+		if serverIDs[0] != "" && serverIDs[0] == s.templateServerID("") {
+			// Templates saved for the local connection before it was registered
+			serverIDs = append(serverIDs, "")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.templateStore.List(serverIDs...))
+	}
+}
+
+// templateServerID returns the ID templates are stored under for a server from
+// serverIDFor. The local connection maps to its registered server, if any, so
+// both ways of addressing the local host see the same templates.
+func (s *Server) templateServerID(serverID string) string {
+	if serverID == "" {
+		if local := s.localServer(); local != nil {
+			return local.ID
+		}
+	}
+	return serverID
+}
+
+// handleCreateVMTemplate registers an existing VM as a template, capturing its
+// CPU, memory and disk layout
+func (s *Server) handleCreateVMTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.templateStore == nil {
+			sendError(w, "Template store is not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req core.CreateVMTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid JSON in request body", http.StatusBadRequest)
+			return
+		}
+		if err := validateUUID(req.VMID); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Name == "" {
+			sendError(w, "Template name is required", http.StatusBadRequest)
+			return
+		}
+
+		vm, err := s.clientFor(r).GetVMDetails(req.VMID)
+		if err != nil {
+			sendError(w, fmt.Sprintf("Failed to get source VM: %v", err), http.StatusNotFound)
+			return
+		}
+
+		tmpl := core.VMTemplate{
+			Name:        req.Name,
+			Description: req.Description,
+			ServerID:    s.templateServerID(serverIDFor(r)),
+			SourceUUID:  vm.UUID,
+			SourceVM:    vm.Name,
+			VCPUs:       vm.VCPUs,
+			MemoryMB:    vm.MaxMemoryKB / 1024,
+		}
+
+		var totalBytes uint64
+		for _, disk := range vm.Disks {
+			if disk.Device != "disk" {
+				continue
+			}
+			tmpl.Disks = append(tmpl.Disks, disk)
+			totalBytes += disk.CapacityB
+		}
+		tmpl.DiskSizeGB = (totalBytes + (1 << 30) - 1) >> 30
+
+		created, err := s.templateStore.Add(tmpl)
+		if err != nil {
+			sendError(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		logger.Info("VM template created", map[string]interface{}{
+			"template_id": created.ID,
+			"source_vm":   created.SourceVM,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// handleDeleteVMTemplate removes a template; the source VM is left untouched
+func (s *Server) handleDeleteVMTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.templateStore == nil {
+			sendError(w, "Template store is not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		templateID := chi.URLParam(r, "templateId")
+		if err := s.templateStore.Delete(templateID); err != nil {
+			sendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleCreateVMFromTemplate clones a template's source VM into a new VM
+func (s *Server) handleCreateVMFromTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.templateStore == nil {
+			sendError(w, "Template store is not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		var req core.CreateVMFromTemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid JSON in request body", http.StatusBadRequest)
+			return
+		}
+		if err := validateCloneVMRequest(&req.CloneVMRequest); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tmpl, err := s.templateStore.Get(req.TemplateID)
+		if err != nil {
+			sendError(w, "Template not found", http.StatusNotFound)
+			return
+		}
+		if s.templateServerID(tmpl.ServerID) != s.templateServerID(serverIDFor(r)) {
+			sendError(w, "Template belongs to a different server", http.StatusBadRequest)
+			return
+		}
+
+		client := s.clientFor(r)
+		job, ok := s.runJob(w, r, "vm.clone", req.Name, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			vm, err := client.CloneVM(ctx, tmpl.SourceUUID, req.CloneVMRequest, p.Update)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := s.templateStore.MarkUsed(tmpl.ID); err != nil {
+				logger.Warn("Failed to update template usage", map[string]interface{}{
+					"template_id": tmpl.ID,
+					"error":       err.Error(),
+				})
+			}
+			return vm, nil
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, fmt.Sprintf("Failed to create VM from template: %s", job.Error), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
+
+// handleCloneVM clones a VM directly, without going through a template
+func (s *Server) handleCloneVM() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		if err := validateUUID(uuid); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req core.CloneVMRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid JSON in request body", http.StatusBadRequest)
+			return
+		}
+		if err := validateCloneVMRequest(&req); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		client := s.clientFor(r)
+		job, ok := s.runJob(w, r, "vm.clone", req.Name, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return client.CloneVM(ctx, uuid, req, p.Update)
+		})
+		if !ok {
+			return
+		}
+		if job.State != core.JobSucceeded {
+			sendError(w, fmt.Sprintf("Failed to clone VM: %s", job.Error), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(job.Result)
+	}
+}
@@ -112,6 +112,92 @@ func TestValidateVMCreationConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "disks, NICs and hardware options",
+			config: core.VMCreationConfig{
+				Name:        "test-vm",
+				MemoryMB:    4096,
+				VCPUs:       4,
+				ImageName:   "ubuntu-24.04",
+				DiskSizeGB:  20,
+				Disks:       []core.VMDiskConfig{{SizeGB: 50}, {Pool: "data", SizeGB: 100, Bus: "scsi", Cache: "none", IO: "native"}},
+				NICs:        []core.VMNICConfig{{Bridge: "br0", Model: "e1000e", MAC: "52:54:00:12:34:56", NWFilter: "clean-traffic"}},
+				CPU:         &core.VMCPUConfig{Mode: "host-passthrough", Sockets: 1, Cores: 2, Threads: 2},
+				MachineType: "q35",
+				Firmware:    "uefi",
+				SecureBoot:  true,
+				TPM:         true,
+				Graphics:    "spice",
+			},
+			wantErr: false,
+		},
+		{
+			name: "CPU topology not matching vcpus",
+			config: core.VMCreationConfig{
+				Name:      "test-vm",
+				MemoryMB:  2048,
+				VCPUs:     2,
+				ImageName: "ubuntu-24.04",
+				CPU:       &core.VMCPUConfig{Cores: 4},
+			},
+			wantErr: true,
+		},
+		{
+			name: "secure boot without q35",
+			config: core.VMCreationConfig{
+				Name:       "test-vm",
+				MemoryMB:   2048,
+				VCPUs:      2,
+				ImageName:  "ubuntu-24.04",
+				Firmware:   "uefi",
+				SecureBoot: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "NIC with both network and bridge",
+			config: core.VMCreationConfig{
+				Name:      "test-vm",
+				MemoryMB:  2048,
+				VCPUs:     2,
+				ImageName: "ubuntu-24.04",
+				NICs:      []core.VMNICConfig{{Network: "default", Bridge: "br0"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "multicast MAC",
+			config: core.VMCreationConfig{
+				Name:      "test-vm",
+				MemoryMB:  2048,
+				VCPUs:     2,
+				ImageName: "ubuntu-24.04",
+				NICs:      []core.VMNICConfig{{Network: "default", MAC: "01:00:5e:00:00:01"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "native io with write-back cache",
+			config: core.VMCreationConfig{
+				Name:      "test-vm",
+				MemoryMB:  2048,
+				VCPUs:     2,
+				ImageName: "ubuntu-24.04",
+				Disks:     []core.VMDiskConfig{{SizeGB: 10, Cache: "writeback", IO: "native"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown graphics",
+			config: core.VMCreationConfig{
+				Name:      "test-vm",
+				MemoryMB:  2048,
+				VCPUs:     2,
+				ImageName: "ubuntu-24.04",
+				Graphics:  "rdp",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -204,3 +290,89 @@ func TestValidateFilePath(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCloneVMRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     core.CloneVMRequest
+		wantErr bool
+	}{
+		{
+			name:    "valid linked clone",
+			req:     core.CloneVMRequest{Name: "web-02", Linked: true},
+			wantErr: false,
+		},
+		{
+			name:    "empty name",
+			req:     core.CloneVMRequest{Name: ""},
+			wantErr: true,
+		},
+		{
+			name:    "invalid name characters",
+			req:     core.CloneVMRequest{Name: "web 02"},
+			wantErr: true,
+		},
+		{
+			name: "invalid cloud-init hostname",
+			req: core.CloneVMRequest{
+				Name: "web-02",
+				CloudInit: &core.CloudInitConfig{
+					CommonFields: core.CloudInitCommonFields{Hostname: "bad_host!"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCloneVMRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateCloneVMRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMigrateVMRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     core.MigrateVMRequest
+		wantErr bool
+	}{
+		{
+			name:    "live with storage copy",
+			req:     core.MigrateVMRequest{TargetServer: "host-b", CopyStorage: core.MigrateCopyStorageAll, BandwidthMiB: 100},
+			wantErr: false,
+		},
+		{
+			name:    "offline",
+			req:     core.MigrateVMRequest{TargetServer: "host-b", Offline: true},
+			wantErr: false,
+		},
+		{
+			name:    "missing target",
+			req:     core.MigrateVMRequest{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown storage mode",
+			req:     core.MigrateVMRequest{TargetServer: "host-b", CopyStorage: "some"},
+			wantErr: true,
+		},
+		{
+			name:    "offline with post-copy",
+			req:     core.MigrateVMRequest{TargetServer: "host-b", Offline: true, PostCopy: true},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMigrateVMRequest(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateMigrateVMRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+)
+
+// handleUpdateVM reconfigures a VM in place and returns the VM with the changes
+// made, each saying whether it reached the running VM, the definition or both
+func (s *Server) handleUpdateVM() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid := chi.URLParam(r, "uuid")
+		if err := validateUUID(uuid); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req core.UpdateVMRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid JSON in request body", http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := s.clientFor(r).UpdateVM(uuid, req)
+		if err != nil {
+			msg := err.Error()
+			switch {
+			case strings.Contains(msg, "lookup domain"):
+				sendError(w, "VM not found", http.StatusNotFound)
+			case strings.Contains(msg, "VM has no"), strings.Contains(msg, "cannot be"), strings.Contains(msg, "not found in managed library"):
+				sendError(w, msg, http.StatusBadRequest)
+			default:
+				sendError(w, fmt.Sprintf("Failed to update VM: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
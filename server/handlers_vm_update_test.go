@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+// updateTestClient reconfigures one running VM that has disks vda and vdb;
+// other methods are not implemented
+type updateTestClient struct {
+	libvirtclient.ClientInterface
+	req *core.UpdateVMRequest
+}
+
+func (c *updateTestClient) UpdateVM(uuidStr string, req core.UpdateVMRequest) (core.UpdateVMResult, error) {
+	if uuidStr != labelTestUUID {
+		return core.UpdateVMResult{}, fmt.Errorf("lookup domain: not found")
+	}
+	c.req = &req
+	var result core.UpdateVMResult
+	for _, target := range req.DetachDisks {
+		if target != "vda" && target != "vdb" {
+			return core.UpdateVMResult{}, fmt.Errorf("VM has no disk %s", target)
+		}
+		result.Changes = append(result.Changes, core.VMChange{Field: "disk " + target, To: "detached", Live: true, Config: true})
+	}
+	if req.VCPUs > 0 {
+		result.Changes = append(result.Changes, core.VMChange{Field: "vcpus", From: "2", To: fmt.Sprint(req.VCPUs), Config: true, RestartRequired: true})
+		result.RestartRequired = true
+	}
+	return result, nil
+}
+
+func TestHandleUpdateVM(t *testing.T) {
+	client := &updateTestClient{}
+	s := &Server{client: client}
+
+	patch := func(uuid, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPatch, "/api/vms/"+uuid, strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("uuid", uuid)
+		w := httptest.NewRecorder()
+		s.handleUpdateVM()(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+		return w
+	}
+
+	w := patch(labelTestUUID, `{"vcpus": 8, "detachDisks": ["vdb"], "cdrom": {"eject": true}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var result core.UpdateVMResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(result.Changes) != 2 || !result.Changes[0].Live || !result.RestartRequired {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if client.req.CDROM == nil || !client.req.CDROM.Eject {
+		t.Errorf("cdrom change not passed on: %+v", client.req)
+	}
+
+	tests := []struct {
+		name string
+		uuid string
+		body string
+		want int
+	}{
+		{"nothing to change", labelTestUUID, `{}`, http.StatusBadRequest},
+		{"too many vCPUs", labelTestUUID, `{"vcpus": 1000}`, http.StatusBadRequest},
+		{"invalid JSON", labelTestUUID, `{"vcpus": `, http.StatusBadRequest},
+		{"invalid UUID", "web-01", `{"vcpus": 2}`, http.StatusBadRequest},
+		{"unknown device", labelTestUUID, `{"detachDisks": ["vdz"]}`, http.StatusBadRequest},
+		{"unknown VM", "1b6f3c2e-5d1a-4c3b-9e2f-1a2b3c4d5e6f", `{"vcpus": 2}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := patch(tt.uuid, tt.body); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/exporter"
+	"github.com/volantvm/flint/pkg/jobs"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// prometheusScrapeTimeout bounds how long one host may take to answer a scrape
+const prometheusScrapeTimeout = 10 * time.Second
+
+// localServerLabel identifies the local connection when it is not itself a registered server
+const localServerLabel = "local"
+
+// errScrapeTimeout marks a host that did not answer within prometheusScrapeTimeout
+var errScrapeTimeout = errors.New("scrape timed out")
+
+// imageDownloadJobTypes are the job types reported as image download progress
+var imageDownloadJobTypes = []string{"image.download", "image.download-url"}
+
+// httpMetricsMiddleware records the latency of every request by method, route
+// pattern and status code. Unmatched paths share one label to bound cardinality.
+func (s *Server) httpMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+		s.requestDurations.Observe(r.Method, route, strconv.Itoa(rec.status), time.Since(start))
+	})
+}
+
+// prometheusAuthMiddleware accepts the scrape token from the prometheus config,
+// the API key, an unscoped API token, or anything at all when
+// allow_unauthenticated is set
+func (s *Server) prometheusAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.prometheusConfig.AllowUnauthenticated {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			token := []byte(parts[1])
+			if s.prometheusConfig.Token != "" && subtle.ConstantTimeCompare(token, []byte(s.prometheusConfig.Token)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if subtle.ConstantTimeCompare(token, []byte(s.apiKey)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Unscoped API tokens may scrape; scoped ones would see other servers
+			if s.authStore != nil {
+				if identity, err := s.authStore.Authenticate(parts[1]); err == nil && identity.Scope.IsEmpty() {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="flint-metrics"`)
+		http.Error(w, `{"error": "Authentication required. Use the Prometheus token or API key"}`, http.StatusUnauthorized)
+	})
+}
+
+// scrapeTarget is one libvirt connection reported by /metrics
+type scrapeTarget struct {
+	serverID string
+	client   libvirtclient.ClientInterface
+}
+
+// scrapeTargets returns the local connection plus every connected registered
+// server on another host. A registered server with a local URI is the local
+// connection and lends it its ID.
+func (s *Server) scrapeTargets() []scrapeTarget {
+	local := scrapeTarget{serverID: localServerLabel, client: s.client}
+	var targets []scrapeTarget
+
+	if s.serverRegistry != nil && s.connectionPool != nil {
+		for _, server := range s.serverRegistry.ListServers() {
+			if isLocalURI(server.URI) {
+				if local.serverID == localServerLabel || server.IsDefault {
+					local.serverID = server.ID
+				}
+				continue
+			}
+			info, ok := s.connectionPool.GetConnectionInfo(server.ID)
+			if !ok || !info.IsConnected {
+				continue
+			}
+			if client, err := s.connectionPool.GetConnection(server); err == nil {
+				targets = append(targets, scrapeTarget{serverID: server.ID, client: client})
+			}
+		}
+	}
+
+	if s.client != nil {
+		targets = append([]scrapeTarget{local}, targets...)
+	}
+	return targets
+}
+
+// hostScrape is everything read from one host for a scrape
+type hostScrape struct {
+	target    scrapeTarget
+	err       error
+	resources core.HostResources
+	domains   []core.VMCounters
+	pools     []core.StoragePool
+	networks  []core.Network
+}
+
+// scrapeHost reads one host, giving up after prometheusScrapeTimeout
+func scrapeHost(target scrapeTarget) hostScrape {
+	done := make(chan hostScrape, 1)
+	go func() {
+		result := hostScrape{target: target}
+		if result.resources, result.err = target.client.GetHostResources(); result.err != nil {
+			done <- result
+			return
+		}
+		result.domains, _ = target.client.GetDomainCounters()
+		result.pools, _ = target.client.GetStoragePools()
+		result.networks, _ = target.client.GetNetworks()
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-time.After(prometheusScrapeTimeout):
+		return hostScrape{target: target, err: errScrapeTimeout}
+	}
+}
+
+// handlePrometheusMetrics serves host, VM, storage, network, download and HTTP
+// metrics in the Prometheus text format. Series carry a server_id label once
+// more than one server is registered.
+func (s *Server) handlePrometheusMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		targets := s.scrapeTargets()
+		labelServers := s.serverRegistry != nil && s.serverRegistry.GetServerCount() > 1
+
+		scrapes := make([]hostScrape, len(targets))
+		var wg sync.WaitGroup
+		for i, target := range targets {
+			wg.Add(1)
+			go func(i int, target scrapeTarget) {
+				defer wg.Done()
+				scrapes[i] = scrapeHost(target)
+			}(i, target)
+		}
+		wg.Wait()
+
+		out := exporter.NewWriter()
+		for _, scrape := range scrapes {
+			base := exporter.Labels{}
+			if labelServers {
+				base["server_id"] = scrape.target.serverID
+			}
+			writeHostMetrics(out, base, scrape)
+		}
+		s.writeJobMetrics(out)
+		s.requestDurations.Collect(out, "flint_http_request_duration_seconds", "Latency of Flint HTTP requests by method, route and status code.")
+
+		w.Header().Set("Content-Type", exporter.ContentType)
+		if _, err := out.WriteTo(w); err != nil {
+			logger.Warn("Failed to write Prometheus metrics", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// writeHostMetrics adds the metrics of one host scrape
+func writeHostMetrics(out *exporter.Writer, base exporter.Labels, scrape hostScrape) {
+	up := 1.0
+	if scrape.err != nil {
+		up = 0
+	}
+	out.Family("flint_libvirt_up", "Whether the libvirt connection answered the scrape.", exporter.Gauge).Add(base, up)
+	if scrape.err != nil {
+		return
+	}
+
+	res := scrape.resources
+	out.Family("flint_host_memory_total_bytes", "Total host memory.", exporter.Gauge).Add(base, float64(res.TotalMemoryKB)*1024)
+	out.Family("flint_host_memory_free_bytes", "Free host memory.", exporter.Gauge).Add(base, float64(res.FreeMemoryKB)*1024)
+	out.Family("flint_host_cpu_cores", "Host CPU cores.", exporter.Gauge).Add(base, float64(res.CPUCores))
+	out.Family("flint_host_storage_total_bytes", "Capacity of all storage pools on the host.", exporter.Gauge).Add(base, float64(res.StorageTotalB))
+	out.Family("flint_host_storage_used_bytes", "Allocation of all storage pools on the host.", exporter.Gauge).Add(base, float64(res.StorageUsedB))
+	out.Family("flint_host_active_interfaces", "Active host network interfaces.", exporter.Gauge).Add(base, float64(res.ActiveInterfaces))
+
+	for _, vm := range scrape.domains {
+		vmLabels := base.With("uuid", vm.UUID, "name", vm.Name)
+		out.Family("flint_vm_state", "VM state; the series with the current state label is 1.", exporter.Gauge).Add(vmLabels.With("state", vm.State), 1)
+		out.Family("flint_vm_vcpus", "vCPUs assigned to the VM.", exporter.Gauge).Add(vmLabels, float64(vm.VCPUs))
+		out.Family("flint_vm_memory_bytes", "Memory assigned to the VM.", exporter.Gauge).Add(vmLabels, float64(vm.MemoryTotalKB)*1024)
+		if !vm.Active {
+			continue
+		}
+		out.Family("flint_vm_memory_used_bytes", "Memory in use by the VM, as reported by the guest balloon driver or QEMU RSS.", exporter.Gauge).Add(vmLabels, float64(vm.MemoryUsedKB)*1024)
+		out.Family("flint_vm_cpu_seconds_total", "CPU time consumed by the VM.", exporter.Counter).Add(vmLabels, float64(vm.CPUTimeNs)/1e9)
+
+		for _, d := range vm.Disks {
+			l := vmLabels.With("device", d.Device)
+			out.Family("flint_vm_disk_read_bytes_total", "Bytes read from a VM disk.", exporter.Counter).Add(l, float64(d.ReadBytes))
+			out.Family("flint_vm_disk_written_bytes_total", "Bytes written to a VM disk.", exporter.Counter).Add(l, float64(d.WriteBytes))
+			out.Family("flint_vm_disk_read_requests_total", "Read requests issued to a VM disk.", exporter.Counter).Add(l, float64(d.ReadReqs))
+			out.Family("flint_vm_disk_write_requests_total", "Write requests issued to a VM disk.", exporter.Counter).Add(l, float64(d.WriteReqs))
+		}
+		for _, i := range vm.Interfaces {
+			l := vmLabels.With("device", i.Device, "mac", i.MAC)
+			out.Family("flint_vm_network_receive_bytes_total", "Bytes received by a VM interface.", exporter.Counter).Add(l, float64(i.RxBytes))
+			out.Family("flint_vm_network_transmit_bytes_total", "Bytes transmitted by a VM interface.", exporter.Counter).Add(l, float64(i.TxBytes))
+			out.Family("flint_vm_network_receive_packets_total", "Packets received by a VM interface.", exporter.Counter).Add(l, float64(i.RxPackets))
+			out.Family("flint_vm_network_transmit_packets_total", "Packets transmitted by a VM interface.", exporter.Counter).Add(l, float64(i.TxPackets))
+		}
+	}
+
+	for _, pool := range scrape.pools {
+		l := base.With("pool", pool.Name)
+		active := 0.0
+		if pool.State == "Active" {
+			active = 1
+		}
+		out.Family("flint_storage_pool_active", "Whether the storage pool is active.", exporter.Gauge).Add(l, active)
+		out.Family("flint_storage_pool_capacity_bytes", "Storage pool capacity.", exporter.Gauge).Add(l, float64(pool.CapacityB))
+		out.Family("flint_storage_pool_allocation_bytes", "Storage pool allocation.", exporter.Gauge).Add(l, float64(pool.AllocationB))
+	}
+
+	for _, network := range scrape.networks {
+		active := 0.0
+		if network.IsActive {
+			active = 1
+		}
+		out.Family("flint_network_active", "Whether the virtual network is active.", exporter.Gauge).Add(base.With("network", network.Name, "bridge", network.Bridge), active)
+	}
+}
+
+// writeJobMetrics reports the progress of running image downloads
+func (s *Server) writeJobMetrics(out *exporter.Writer) {
+	if s.jobManager == nil {
+		return
+	}
+
+	family := out.Family("flint_image_download_progress_ratio", "Progress of running image downloads, 0 to 1.", exporter.Gauge)
+	for _, jobType := range imageDownloadJobTypes {
+		for _, job := range s.jobManager.List(jobs.Filter{Type: jobType, State: core.JobRunning}) {
+			family.Add(exporter.Labels{"job_id": job.ID, "image": job.Target, "type": job.Type}, job.Progress/100)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/config"
+	"github.com/volantvm/flint/pkg/exporter"
+)
+
+func TestPrometheusAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.PrometheusConfig
+		auth   string
+		want   int
+	}{
+		{"no credentials", config.PrometheusConfig{Token: "scrape"}, "", http.StatusUnauthorized},
+		{"scrape token", config.PrometheusConfig{Token: "scrape"}, "Bearer scrape", http.StatusOK},
+		{"api key", config.PrometheusConfig{Token: "scrape"}, "Bearer api-key", http.StatusOK},
+		{"wrong token", config.PrometheusConfig{Token: "scrape"}, "Bearer nope", http.StatusUnauthorized},
+		{"empty token never matches", config.PrometheusConfig{}, "Bearer ", http.StatusUnauthorized},
+		{"unauthenticated allowed", config.PrometheusConfig{AllowUnauthenticated: true}, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{apiKey: "api-key", prometheusConfig: tt.config}
+			handler := s.prometheusAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPMetricsMiddleware(t *testing.T) {
+	s := &Server{requestDurations: exporter.NewRequestDurations(nil)}
+	router := chi.NewRouter()
+	router.Use(s.httpMetricsMiddleware)
+	router.Get("/api/vms/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/vms/550e8400-e29b-41d4-a716-446655440000", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	out := exporter.NewWriter()
+	s.requestDurations.Collect(out, "flint_http_request_duration_seconds", "latency")
+	var buf strings.Builder
+	out.WriteTo(&buf)
+
+	for _, want := range []string{
+		`flint_http_request_duration_seconds_count{code="404",method="GET",route="/api/vms/{uuid}"} 1`,
+		`flint_http_request_duration_seconds_count{code="404",method="GET",route="unmatched"} 1`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestIsLocalURI(t *testing.T) {
+	tests := map[string]bool{
+		"qemu:///system":                   true,
+		"qemu:///session":                  true,
+		"qemu+ssh://root@hv-02/system":     false,
+		"qemu+tcp://10.0.0.5:16509/system": false,
+	}
+	for uri, want := range tests {
+		if got := isLocalURI(uri); got != want {
+			t.Errorf("isLocalURI(%q) = %v, want %v", uri, got, want)
+		}
+	}
+}
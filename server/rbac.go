@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/volantvm/flint/pkg/auth"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+const identityKey contextKey = "identity"
+
+// routeRule raises the role needed for matching requests above the default:
+// viewer for reads, operator for changes
+type routeRule struct {
+	writesOnly bool   // Only applies to non-GET requests
+	pattern    string // path.Match pattern relative to /api, after any /servers/{serverID} prefix
+	role       core.Role
+}
+
+var adminRoutes = []routeRule{
+	{false, "/api-key", core.RoleAdmin},
+	{false, "/users", core.RoleAdmin},
+	{false, "/users/*", core.RoleAdmin},
+	{false, "/tokens", core.RoleAdmin},
+	{false, "/tokens/*", core.RoleAdmin},
+	{true, "/connection/*", core.RoleAdmin},
+	{true, "/servers", core.RoleAdmin},
+	{true, "/servers/*", core.RoleAdmin},
+	{true, "/servers/*/default", core.RoleAdmin},
+	{true, "/servers/*/refresh", core.RoleAdmin},
+	{true, "/storage-pools", core.RoleAdmin},
+	{true, "/networks", core.RoleAdmin},
+	{true, "/networks/*", core.RoleAdmin},
+	{true, "/bridges", core.RoleAdmin},
+	{true, "/nwfilters", core.RoleAdmin},
+	{true, "/nwfilters/*", core.RoleAdmin},
+	{true, "/image-repository/catalogs", core.RoleAdmin},
+	{true, "/image-repository/catalogs/*", core.RoleAdmin},
+	{true, "/image-repository/catalogs/*/refresh", core.RoleAdmin},
+}
+
+// scopedGlobalRoutes are the routes outside a single host that scoped identities
+// may use. Their handlers only show resources in scope.
+var scopedGlobalRoutes = []string{"/jobs", "/jobs/*", "/jobs/*/cancel", "/events", "/ssh-key/detect", "/servers"}
+
+// serverManagementPaths are the /servers/{serverID}/... paths that manage the
+// registration itself rather than operate on the host
+var serverManagementPaths = map[string]bool{"default": true, "refresh": true}
+
+// adminIdentity is how the API key and web UI sessions are authorised
+func adminIdentity(actor string) auth.Identity {
+	return auth.Identity{User: actor, Role: core.RoleAdmin}
+}
+
+// identityFor returns who an authenticated request acts as
+func identityFor(r *http.Request) auth.Identity {
+	if identity, ok := r.Context().Value(identityKey).(auth.Identity); ok {
+		return identity
+	}
+	return auth.Identity{}
+}
+
+// withIdentity records the identity and its audit actor on the request
+func withIdentity(r *http.Request, identity auth.Identity) *http.Request {
+	return withActor(r.WithContext(context.WithValue(r.Context(), identityKey, identity)), identity.User)
+}
+
+// authenticateToken resolves a bearer token: the API key, or a user's API token
+func (s *Server) authenticateToken(token string) (auth.Identity, error) {
+	if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.apiKey)) == 1 {
+		return adminIdentity(actorAPIKey), nil
+	}
+	if s.authStore == nil {
+		return auth.Identity{}, auth.ErrInvalidToken
+	}
+	return s.authStore.Authenticate(token)
+}
+
+// splitHostRoute separates /servers/{serverID}/rest into the server selector and
+// the host route /rest. Other paths are returned unchanged with no selector.
+func splitHostRoute(apiPath string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(apiPath, "/servers/"), "/", 2)
+	if !strings.HasPrefix(apiPath, "/servers/") || len(parts) < 2 || parts[1] == "" || serverManagementPaths[parts[1]] {
+		return "", apiPath
+	}
+	return parts[0], "/" + parts[1]
+}
+
+// requiredRole returns the role a request to an /api path needs
+func requiredRole(method, apiPath string) core.Role {
+	_, route := splitHostRoute(apiPath)
+	route = strings.TrimSuffix(route, "/")
+	if route == "" {
+		route = "/"
+	}
+	write := method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+
+	for _, rule := range adminRoutes {
+		if rule.writesOnly && !write {
+			continue
+		}
+		if ok, _ := path.Match(rule.pattern, route); ok {
+			return rule.role
+		}
+	}
+	if write {
+		return core.RoleOperator
+	}
+	return core.RoleViewer
+}
+
+// authorize checks an identity's role and scope against a request. It returns
+// the HTTP status to use when access is denied.
+func (s *Server) authorize(r *http.Request, identity auth.Identity) (int, error) {
+	apiPath := strings.TrimPrefix(r.URL.Path, "/api")
+
+	if required := requiredRole(r.Method, apiPath); !identity.Role.Allows(required) {
+		return http.StatusForbidden, fmt.Errorf("%s access required", required)
+	}
+	if identity.Scope.IsEmpty() {
+		return http.StatusOK, nil
+	}
+
+	selector, route := splitHostRoute(apiPath)
+	hostRoute := true
+	if selector == "" {
+		route = strings.TrimSuffix(route, "/")
+		for _, pattern := range scopedGlobalRoutes {
+			if ok, _ := path.Match(pattern, route); ok {
+				// The event stream is per server and would show VMs out of scope
+				if route == "/events" && identity.Scope.VMLabels != "" {
+					return http.StatusForbidden, errors.New("not available to tokens scoped to VM labels")
+				}
+				return http.StatusOK, nil
+			}
+		}
+		switch ok, _ := path.Match("/servers/*", route); {
+		case ok:
+			// A registered server's own details
+			selector, hostRoute = strings.TrimPrefix(route, "/servers/"), false
+		case isGlobalRoute(route):
+			return http.StatusForbidden, errors.New("not available to scoped tokens")
+		default:
+			selector = strings.TrimSpace(r.Header.Get(ServerHeader))
+		}
+	}
+
+	if !s.selectorInScope(identity, selector) {
+		return http.StatusForbidden, errors.New("server is outside the token's scope")
+	}
+	if hostRoute && identity.Scope.VMLabels != "" {
+		return s.authorizeVMRoute(identity, selector, route)
+	}
+	return http.StatusOK, nil
+}
+
+// authorizeVMRoute limits identities scoped to VM labels to the VM list, bulk
+// actions and /vms/{uuid}/... routes of VMs whose labels match. The VM list and
+// bulk action handlers only act on VMs in scope.
+func (s *Server) authorizeVMRoute(identity auth.Identity, selector, route string) (int, error) {
+	route = strings.TrimSuffix(route, "/")
+	if route == "/vms" || route == "/vms/actions" {
+		return http.StatusOK, nil
+	}
+	uuid, ok := strings.CutPrefix(route, "/vms/")
+	uuid, _, _ = strings.Cut(uuid, "/")
+	if !ok || validateUUID(uuid) != nil {
+		return http.StatusForbidden, errors.New("not available to tokens scoped to VM labels")
+	}
+
+	client := s.client
+	if selector != "" {
+		_, selected, status, err := s.resolveServerClient(selector)
+		if err != nil {
+			return status, err
+		}
+		client = selected
+	}
+	if !vmInScope(identity, client, uuid) {
+		return http.StatusForbidden, errors.New("VM is outside the token's scope")
+	}
+	return http.StatusOK, nil
+}
+
+// vmInScope reports whether the VM with this UUID or name matches the identity's
+// VM label scope
+func vmInScope(identity auth.Identity, client libvirtclient.ClientInterface, vm string) bool {
+	if identity.Scope.VMLabels == "" {
+		return true
+	}
+	selector, err := identity.Scope.VMSelector()
+	if err != nil || client == nil {
+		return false
+	}
+	vms, err := client.GetVMSummaries()
+	if err != nil {
+		return false
+	}
+	for _, summary := range vms {
+		if summary.UUID == vm || summary.Name == vm {
+			return selector.Matches(summary.Labels)
+		}
+	}
+	return false
+}
+
+// jobInScope reports whether an identity may see a job: its server must be in
+// scope and, for tokens scoped to VM labels, its target a VM in scope
+func (s *Server) jobInScope(identity auth.Identity, job core.Job) bool {
+	if !s.selectorInScope(identity, job.ServerID) {
+		return false
+	}
+	if identity.Scope.VMLabels == "" {
+		return true
+	}
+	client := s.client
+	if job.ServerID != "" {
+		_, selected, _, err := s.resolveServerClient(job.ServerID)
+		if err != nil {
+			return false
+		}
+		client = selected
+	}
+	return vmInScope(identity, client, job.Target)
+}
+
+// isGlobalRoute reports whether an /api route is not scoped to a single host
+func isGlobalRoute(route string) bool {
+	for _, prefix := range []string{"/api-key", "/ssh-key", "/events", "/connection", "/servers", "/fleet", "/jobs", "/image-repository", "/users", "/tokens", "/snapshot-policies"} {
+		if route == prefix || strings.HasPrefix(route, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorInScope reports whether a server selector (ID or name, "" for the
+// local connection) is in the identity's scope
+func (s *Server) selectorInScope(identity auth.Identity, selector string) bool {
+	if identity.Scope.IsEmpty() {
+		return true
+	}
+	if selector == "" {
+		return auth.InScope(identity.Scope, s.localServer())
+	}
+	if s.serverRegistry == nil {
+		return false
+	}
+	server, err := s.serverRegistry.FindServer(selector)
+	if err != nil {
+		return false
+	}
+	return auth.InScope(identity.Scope, server)
+}
+
+// serverIDsInScope lists the IDs of the registered servers in a scoped identity's
+// scope, plus "" when the local connection is in scope
+func (s *Server) serverIDsInScope(identity auth.Identity) []string {
+	ids := []string{}
+	if s.selectorInScope(identity, "") {
+		ids = append(ids, "")
+	}
+	if s.serverRegistry == nil {
+		return ids
+	}
+	for _, server := range s.serverRegistry.ListServers() {
+		if auth.InScope(identity.Scope, server) {
+			ids = append(ids, server.ID)
+		}
+	}
+	return ids
+}
+
+// initAuthStore opens the user and API token store
+func (s *Server) initAuthStore() {
+	store, err := auth.NewStore("")
+	if err != nil {
+		logger.Error("Failed to initialize user and token store, only the API key will be accepted", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	s.authStore = store
+}
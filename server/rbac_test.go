@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/auth"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+)
+
+func TestRequiredRole(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   core.Role
+	}{
+		{"GET", "/vms", core.RoleViewer},
+		{"POST", "/vms/550e8400-e29b-41d4-a716-446655440000/action", core.RoleOperator},
+		{"DELETE", "/vms/550e8400-e29b-41d4-a716-446655440000", core.RoleOperator},
+		{"GET", "/api-key", core.RoleAdmin},
+		{"GET", "/tokens", core.RoleAdmin},
+		{"POST", "/servers", core.RoleAdmin},
+		{"GET", "/servers/hv-01", core.RoleViewer},
+		{"POST", "/servers/hv-01/refresh", core.RoleAdmin},
+		{"POST", "/servers/hv-01/vms", core.RoleOperator},
+		{"DELETE", "/servers/hv-01/networks/default", core.RoleAdmin},
+		{"POST", "/storage-pools", core.RoleAdmin},
+		{"POST", "/storage-pools/default/volumes", core.RoleOperator},
+		{"POST", "/image-repository/catalogs", core.RoleAdmin},
+		{"POST", "/image-repository/catalogs/golden/refresh", core.RoleAdmin},
+		{"POST", "/image-repository/ubuntu-24.04-lts/download", core.RoleOperator},
+		{"GET", "/image-repository/catalogs", core.RoleViewer},
+	}
+	for _, tt := range tests {
+		if got := requiredRole(tt.method, tt.path); got != tt.want {
+			t.Errorf("requiredRole(%s %s) = %s, want %s", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAuthMiddleware_EnforcesRoles(t *testing.T) {
+	store, err := auth.NewStore(filepath.Join(t.TempDir(), "auth.json"))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	s := &Server{apiKey: "api-key", authStore: store}
+
+	newToken := func(user string, role core.Role, scope core.AccessScope) string {
+		if _, err := store.AddUser(core.CreateUserRequest{Name: user, Role: role, Scope: scope}); err != nil {
+			t.Fatalf("AddUser() error = %v", err)
+		}
+		_, secret, err := store.CreateToken(core.CreateTokenRequest{User: user})
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+		return secret
+	}
+	viewer := newToken("vera", core.RoleViewer, core.AccessScope{})
+	operator := newToken("otto", core.RoleOperator, core.AccessScope{})
+	scoped := newToken("sam", core.RoleOperator, core.AccessScope{Servers: []string{"hv-01"}})
+
+	var actor string
+	router := chi.NewRouter()
+	router.Route("/api", func(r chi.Router) {
+		r.Use(s.authMiddleware)
+		handler := func(w http.ResponseWriter, r *http.Request) { actor = actorFor(r) }
+		r.Get("/vms", handler)
+		r.Post("/vms/{uuid}/action", handler)
+		r.Get("/tokens", handler)
+	})
+
+	uuid := "550e8400-e29b-41d4-a716-446655440000"
+	tests := []struct {
+		name      string
+		token     string
+		method    string
+		path      string
+		want      int
+		wantActor string
+	}{
+		{"no token", "", "GET", "/api/vms", http.StatusUnauthorized, ""},
+		{"viewer reads", viewer, "GET", "/api/vms", http.StatusOK, "vera"},
+		{"viewer cannot act", viewer, "POST", "/api/vms/" + uuid + "/action", http.StatusForbidden, ""},
+		{"operator acts", operator, "POST", "/api/vms/" + uuid + "/action", http.StatusOK, "otto"},
+		{"operator cannot list tokens", operator, "GET", "/api/tokens", http.StatusForbidden, ""},
+		{"api key is admin", "api-key", "GET", "/api/tokens", http.StatusOK, actorAPIKey},
+		{"scoped token outside scope", scoped, "GET", "/api/vms", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor = ""
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+			if actor != tt.wantActor {
+				t.Errorf("actor = %q, want %q", actor, tt.wantActor)
+			}
+		})
+	}
+}
+
+// labelsTestClient serves a fixed VM list for label scope checks
+type labelsTestClient struct {
+	libvirtclient.ClientInterface
+	vms []core.VM_Summary
+}
+
+func (c *labelsTestClient) GetVMSummaries() ([]core.VM_Summary, error) { return c.vms, nil }
+
+func (c *labelsTestClient) Close() error { return nil }
+
+func TestAuthMiddleware_VMLabelScope(t *testing.T) {
+	store, err := auth.NewStore(filepath.Join(t.TempDir(), "auth.json"))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	if _, err := store.AddUser(core.CreateUserRequest{Name: "web", Role: core.RoleOperator, Scope: core.AccessScope{VMLabels: "team=web"}}); err != nil {
+		t.Fatalf("AddUser() error = %v", err)
+	}
+	_, secret, err := store.CreateToken(core.CreateTokenRequest{User: "web"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	webVM := "550e8400-e29b-41d4-a716-446655440000"
+	dbVM := "650e8400-e29b-41d4-a716-446655440000"
+	client := &labelsTestClient{vms: []core.VM_Summary{
+		{Name: "web-01", UUID: webVM, Labels: map[string]string{"team": "web"}},
+		{Name: "db-01", UUID: dbVM, Labels: map[string]string{"team": "db"}},
+	}}
+	s := &Server{apiKey: "api-key", authStore: store, client: client}
+
+	router := chi.NewRouter()
+	router.Route("/api", func(r chi.Router) {
+		r.Use(s.authMiddleware)
+		handler := func(w http.ResponseWriter, r *http.Request) {}
+		r.Get("/vms", s.handleGetVMs())
+		r.Post("/vms/{uuid}/action", handler)
+		r.Post("/vms/import", handler)
+		r.Get("/storage-pools", handler)
+		r.Get("/events", handler)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"lists VMs", "GET", "/api/vms", http.StatusOK},
+		{"acts on a VM in scope", "POST", "/api/vms/" + webVM + "/action", http.StatusOK},
+		{"VM outside scope", "POST", "/api/vms/" + dbVM + "/action", http.StatusForbidden},
+		{"imports", "POST", "/api/vms/import", http.StatusForbidden},
+		{"host resources", "GET", "/api/storage-pools", http.StatusForbidden},
+		{"event stream", "GET", "/api/events", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+secret)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.path == "/api/vms" {
+				var vms []core.VM_Summary
+				json.NewDecoder(rec.Body).Decode(&vms)
+				if len(vms) != 1 || vms[0].UUID != webVM {
+					t.Errorf("Expected only web-01 to be listed, got %+v", vms)
+				}
+			}
+		})
+	}
+}
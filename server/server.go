@@ -4,14 +4,27 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
-	"crypto/subtle"
 	"embed"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/volantvm/flint/pkg/activity"
+	"github.com/volantvm/flint/pkg/auth"
+	"github.com/volantvm/flint/pkg/config"
+	"github.com/volantvm/flint/pkg/connectionpool"
+	"github.com/volantvm/flint/pkg/download"
+	"github.com/volantvm/flint/pkg/events"
+	"github.com/volantvm/flint/pkg/exporter"
 	"github.com/volantvm/flint/pkg/imagerepository"
+	"github.com/volantvm/flint/pkg/imageupload"
+	"github.com/volantvm/flint/pkg/jobs"
 	"github.com/volantvm/flint/pkg/libvirtclient"
 	"github.com/volantvm/flint/pkg/logger"
+	"github.com/volantvm/flint/pkg/metrics"
+	"github.com/volantvm/flint/pkg/serverregistry"
+	"github.com/volantvm/flint/pkg/snapshotpolicy"
+	"github.com/volantvm/flint/pkg/templates"
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/crypto/bcrypt"
 	"io"
@@ -36,6 +49,26 @@ type Server struct {
 	imageRepo        *imagerepository.ImageRepository
 	sessions         map[string]time.Time // sessionID -> expiry time
 	sessionsMu       sync.RWMutex
+	serverRegistry   *serverregistry.Registry
+	connectionPool   *connectionpool.Pool
+	templateStore    *templates.Store
+	jobManager       *jobs.Manager
+	auditStore       *activity.Store
+	eventHub         *events.Hub
+	metricsStore     *metrics.Store
+	metricsSampler   *metrics.Sampler
+	requestDurations *exporter.RequestDurations
+	prometheusConfig config.PrometheusConfig
+	authStore        *auth.Store
+
+	backupPath        string
+	exportPath        string
+	exportMaxSize     int64 // Bytes, 0 means no limit
+	snapshotPolicies  *snapshotpolicy.Store
+	snapshotScheduler *snapshotpolicy.Scheduler
+	imageUploads      *imageupload.Manager
+	downloader        *download.Downloader
+	downloadPath      string
 }
 
 type rateLimiter struct {
@@ -55,10 +88,71 @@ func NewServer(client libvirtclient.ClientInterface, assets embed.FS) *Server {
 		rateLimiters: make(map[string]*rateLimiter),
 		imageRepo:    imageRepo,
 		sessions:     make(map[string]time.Time),
+		eventHub:     events.NewHub(),
+
+		requestDurations: exporter.NewRequestDurations(nil),
 	}
 
 	// Load or generate config
 	s.loadOrGenerateConfig()
+	appConfig := loadAppConfig()
+	s.prometheusConfig = appConfig.Prometheus
+	s.backupPath = appConfig.Backup.Path
+	s.exportPath = appConfig.Export.Path
+	s.exportMaxSize = int64(appConfig.Export.MaxSizeGB) << 30
+	s.downloadPath = appConfig.Download.Path
+	s.jobManager = jobs.NewManager(appConfig.Jobs.Workers, appConfig.Jobs.LongWorkers, 0)
+
+	// One downloader enforces the concurrency and bandwidth limits for URL and repository downloads
+	s.downloader = download.New(download.Config{
+		MaxConcurrent:  appConfig.Download.MaxConcurrent,
+		BytesPerSecond: int64(appConfig.Download.BandwidthLimitKBps) << 10,
+	})
+	s.imageRepo.Downloader = s.downloader
+
+	// Initialize multi-server registry and connection pool
+	registry, err := serverregistry.NewRegistry("")
+	if err != nil {
+		logger.Error("Failed to initialize server registry, multi-server mode disabled", map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else {
+		s.serverRegistry = registry
+		s.connectionPool = connectionpool.NewPool(0, 0)
+		s.migrateToMultiServer()
+	}
+
+	// Load persisted VM templates
+	if store, err := templates.NewStore(""); err != nil {
+		logger.Error("Failed to initialize template store", map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else {
+		s.templateStore = store
+	}
+
+	// Named users and scoped API tokens
+	s.initAuthStore()
+
+	// Persist activity from every connection to the audit log
+	s.initAuditStore(appConfig)
+
+	// Fan out libvirt events to /api/events and the audit log
+	go s.recordEvents()
+	s.watchEvents(s.localEventsID(), client)
+	go s.watchRegisteredServers()
+
+	// Record per-VM performance history for /api/vms/{uuid}/metrics
+	s.initMetrics(appConfig)
+
+	// Take and prune snapshots on the schedules in ~/.flint/snapshot-policies.json
+	s.initSnapshotPolicies()
+
+	// Resumable image uploads, tracked in ~/.flint/image-uploads.json
+	s.initImageUploads(appConfig)
+
+	// User-defined image catalogs from ~/.flint/image-catalogs.json
+	s.initImageCatalogs()
 
 	logger.Info("Initializing Flint server", map[string]interface{}{
 		"api_key_length": len(s.apiKey),
@@ -72,6 +166,19 @@ func NewServer(client libvirtclient.ClientInterface, assets embed.FS) *Server {
 	return s
 }
 
+// loadAppConfig reads the settings of optional subsystems (background jobs, audit
+// log, metrics, Prometheus exporter) from config.Config, falling back to defaults
+func loadAppConfig() *config.Config {
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		logger.Warn("Failed to load config, using defaults", map[string]interface{}{
+			"error": err.Error(),
+		})
+		cfg = config.DefaultConfig()
+	}
+	return cfg
+}
+
 // loadOrGenerateConfig loads config from file or creates a new one with defaults
 func (s *Server) loadOrGenerateConfig() {
 	configDir := filepath.Join(os.Getenv("HOME"), ".flint")
@@ -328,32 +435,61 @@ func (s *Server) getStoredPassphraseHash() string {
 	return s.passphraseHash
 }
 
-// authMiddleware validates API key from Authorization header OR session cookie
+// authMiddleware validates the API key or a user's API token from the
+// Authorization header, OR a session cookie, then enforces role and scope
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// First try API key authentication (for CLI/API usage)
+		var identity auth.Identity
+		authenticated := false
+
+		// First try the API key or a user's API token (for CLI/API usage)
 		authHeader := r.Header.Get("Authorization")
 		if authHeader != "" {
-			// Expected format: "Bearer <api-key>"
+			// Expected format: "Bearer <api-key or token>"
 			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) == 2 && parts[0] == "Bearer" && subtle.ConstantTimeCompare([]byte(parts[1]), []byte(s.apiKey)) == 1 {
-				// Valid API key, continue
-				ctx := context.WithValue(r.Context(), "api_key", parts[1])
-				next.ServeHTTP(w, r.WithContext(ctx))
-				return
+			if len(parts) == 2 && parts[0] == "Bearer" {
+				var err error
+				identity, err = s.authenticateToken(parts[1])
+				if errors.Is(err, auth.ErrTokenExpired) {
+					http.Error(w, `{"error": "API token expired"}`, http.StatusUnauthorized)
+					return
+				}
+				if errors.Is(err, auth.ErrTokenOutOfScope) {
+					http.Error(w, `{"error": "API token scope is no longer granted to its user"}`, http.StatusUnauthorized)
+					return
+				}
+				if err == nil {
+					authenticated = true
+					if identity.User == actorAPIKey && strings.HasPrefix(r.UserAgent(), cliUserAgent) {
+						identity.User = actorCLI
+					}
+					r = r.WithContext(context.WithValue(r.Context(), "api_key", parts[1]))
+				}
 			}
 		}
 
 		// Fallback to session cookie authentication (for web app)
-		cookie, err := r.Cookie("flint_session")
-		if err == nil && s.isValidSession(cookie.Value) {
-			// Valid session from passphrase authentication
-			next.ServeHTTP(w, r)
+		if !authenticated {
+			cookie, err := r.Cookie("flint_session")
+			if err == nil && s.isValidSession(cookie.Value) {
+				// Valid session from passphrase authentication
+				identity, authenticated = adminIdentity(actorSession), true
+			}
+		}
+
+		if !authenticated {
+			// No valid authentication found
+			http.Error(w, `{"error": "Authentication required. Use API key or login via web UI"}`, http.StatusUnauthorized)
+			return
+		}
+
+		// Enforce the identity's role and scope for this route
+		if status, err := s.authorize(r, identity); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Forbidden: %s"}`, err.Error()), status)
 			return
 		}
 
-		// No valid authentication found
-		http.Error(w, `{"error": "Authentication required. Use API key or login via web UI"}`, http.StatusUnauthorized)
+		next.ServeHTTP(w, withIdentity(r, identity))
 	})
 }
 
@@ -452,77 +588,109 @@ func min(a, b int) int {
 
 // Serve embedded static files via chi, without overriding API routes
 func (s *Server) setupRoutes() {
+	// Request latencies for the Prometheus exporter
+	s.router.Use(s.httpMetricsMiddleware)
+
 	// Public API endpoints (no authentication required)
 	s.router.Get("/api/health", s.handleHealthCheck())
 
+	// Prometheus exporter (scrape token or API key, unless configured as public)
+	if s.prometheusConfig.Enabled {
+		s.router.With(s.prometheusAuthMiddleware).Get("/metrics", s.handlePrometheusMetrics())
+	}
+
 	// Serial console endpoints (token-based auth, not middleware auth)
-	s.router.Get("/api/vms/{uuid}/serial-console", s.handleGetVMSerialConsole())
-	s.router.Get("/api/vms/{uuid}/serial-console/ws", s.handleVMSerialConsoleWS())
+	s.router.With(s.serverSelectorMiddleware).Get("/api/vms/{uuid}/serial-console/ws", s.handleVMSerialConsoleWS())
+	s.router.With(s.serverSelectorMiddleware).Get("/api/servers/{serverID}/vms/{uuid}/serial-console/ws", s.handleVMSerialConsoleWS())
 
 	// VNC console WebSocket endpoint (token-based auth, not middleware auth)
-	s.router.Get("/api/vms/{uuid}/vnc/ws", s.handleVMVNCWebSocket())
+	s.router.With(s.serverSelectorMiddleware).Get("/api/vms/{uuid}/vnc/ws", s.handleVMVNCWebSocket())
+	s.router.With(s.serverSelectorMiddleware).Get("/api/servers/{serverID}/vms/{uuid}/vnc/ws", s.handleVMVNCWebSocket())
 
 	// Protected API routes with authentication
 	s.router.Route("/api", func(r chi.Router) {
 		r.Use(s.authMiddleware)
+		r.Use(s.auditMiddleware)
 		r.Get("/api-key", s.handleGetAPIKey()) // Now requires authentication!
 		r.Get("/ssh-key/detect", s.handleDetectSSHKey())
+		r.Get("/events", s.handleEvents())
 
 		// Connection management endpoints
 		r.Get("/connection/status", s.handleGetConnectionStatus())
 		r.Post("/connection/test", s.handleTestConnection())
 		r.Put("/connection/config", s.handleUpdateConnectionConfig())
 
-		r.Get("/vms", s.handleGetVMs())
-		r.Post("/vms", s.handleCreateVM())
-		r.Post("/vms/from-template", s.handleCreateVMFromTemplate())
-		r.Get("/vms/{uuid}", s.handleGetVMDetails())
-		r.Delete("/vms/{uuid}", s.handleDeleteVM())
-		r.Post("/vms/{uuid}/action", s.handleVMAction())
-		r.Get("/vms/{uuid}/guest-agent/status", s.handleGetGuestAgentStatus())
-		r.Post("/vms/{uuid}/guest-agent/install", s.handleInstallGuestAgent())
-		r.Get("/vms/{uuid}/vnc", s.handleGetVMVNCInfo())
-		r.Get("/vms/{uuid}/console-stream", s.handleGetVMConsoleStream())
-		r.Get("/vms/{uuid}/snapshots", s.handleGetVMSnapshots())
-		r.Post("/vms/{uuid}/snapshots", s.handleCreateVMSnapshot())
-		r.Delete("/vms/{uuid}/snapshots/{snapshotName}", s.handleDeleteVMSnapshot())
-		r.Post("/vms/{uuid}/snapshots/{snapshotName}/revert", s.handleRevertToVMSnapshot())
-		r.Get("/vm-templates", s.handleGetVMTemplates())
-		r.Post("/vm-templates", s.handleCreateVMTemplate())
-		r.Get("/vms/{uuid}/performance", s.handleGetVMPerformance())
-		r.Post("/vms/{uuid}/attach-disk", s.handleAttachDiskToVM())
-		r.Post("/vms/{uuid}/attach-network", s.handleAttachNetworkInterfaceToVM())
-		r.Get("/host/status", s.handleGetHostStatus())
-		r.Get("/host/resources", s.handleGetHostResources())
-		r.Get("/storage-pools", s.handleGetStoragePools())
-		r.Post("/storage-pools", s.handleCreateStoragePool())
-		r.Get("/storage-pools/{poolName}/volumes", s.handleGetVolumes())
-		r.Post("/storage-pools/{poolName}/volumes", s.handleCreateVolume())
-		r.Put("/storage-pools/{poolName}/volumes/{volumeName}", s.handleUpdateVolume())
-		r.Get("/networks", s.handleGetNetworks())
-		r.Get("/system-interfaces", s.handleGetSystemInterfaces())
-		r.Post("/networks", s.handleCreateNetwork())
-		r.Post("/bridges", s.handleCreateBridge())
-		r.Put("/networks/{networkName}", s.handleUpdateNetwork())
-		r.Delete("/networks/{networkName}", s.handleDeleteNetwork())
-		r.Delete("/storage-pools/{poolName}/volumes/{volumeName}", s.handleDeleteVolume())
-		r.Get("/images", s.handleGetImages())
-		r.Post("/images/import-from-path", s.handleImportImageFromPath())
-		r.Post("/images/download", s.handleDownloadImage())
-		r.Delete("/images/{imageId}", s.handleDeleteImage())
+		// Host-scoped endpoints, optionally targeting a server via the X-Flint-Server header
+		r.Group(func(r chi.Router) {
+			r.Use(s.serverSelectorMiddleware)
+			s.setupHostRoutes(r)
+		})
+
+		// Multi-server management endpoints
+		if s.serverRegistry != nil {
+			r.Route("/servers", func(r chi.Router) {
+				r.Get("/", s.handleListServers())
+				r.Post("/", s.handleCreateServer())
+				r.Post("/validate", s.handleValidateServer())
+
+				r.Route("/{serverID}", func(r chi.Router) {
+					r.Get("/", s.handleGetServer())
+					r.Put("/", s.handleUpdateServer())
+					r.Delete("/", s.handleDeleteServer())
+					r.Post("/default", s.handleSetDefaultServer())
+					r.Post("/refresh", s.handleRefreshServerConnection())
+
+					// Host-scoped endpoints for this server: /api/servers/{serverID}/vms, ...
+					r.Group(func(r chi.Router) {
+						r.Use(s.serverSelectorMiddleware)
+						s.setupHostRoutes(r)
+					})
+				})
+			})
+		}
+
+		// Fleet-wide views aggregated across all registered servers
+		r.Get("/fleet/vms", s.handleGetFleetVMs())
+		r.Get("/fleet/storage-pools", s.handleGetFleetStoragePools())
+		r.Get("/fleet/networks", s.handleGetFleetNetworks())
+
+		// Background jobs (VM creation, image downloads, snapshots, volume resizes)
+		r.Get("/jobs", s.handleListJobs())
+		r.Get("/jobs/{jobId}", s.handleGetJob())
+		r.Post("/jobs/{jobId}/cancel", s.handleCancelJob())
+
+		// Users and API tokens (role-based access control)
+		if s.authStore != nil {
+			r.Get("/users", s.handleListUsers())
+			r.Post("/users", s.handleCreateUser())
+			r.Delete("/users/{userName}", s.handleDeleteUser())
+			r.Get("/tokens", s.handleListTokens())
+			r.Post("/tokens", s.handleCreateToken())
+			r.Delete("/tokens/{tokenId}", s.handleRevokeToken())
+		}
+
+		// Scheduled snapshots with retention
+		if s.snapshotPolicies != nil {
+			r.Get("/snapshot-policies", s.handleListSnapshotPolicies())
+			r.Post("/snapshot-policies", s.handleCreateSnapshotPolicy())
+			r.Get("/snapshot-policies/{policyId}", s.handleGetSnapshotPolicy())
+			r.Put("/snapshot-policies/{policyId}", s.handleUpdateSnapshotPolicy())
+			r.Delete("/snapshot-policies/{policyId}", s.handleDeleteSnapshotPolicy())
+			r.Post("/snapshot-policies/{policyId}/run", s.handleRunSnapshotPolicy())
+		}
 
 		// Image repository endpoints
 		r.Get("/image-repository", s.handleGetRepositoryImages())
 		r.Post("/image-repository/{imageId}/download", s.handleDownloadRepositoryImage())
 		r.Get("/image-repository/{imageId}/status", s.handleGetDownloadStatus())
-		r.Get("/activity", s.handleGetActivity())
-
-		// Network filter / Firewall endpoints
-		r.Get("/nwfilters", s.handleListNWFilters())
-		r.Get("/nwfilters/{name}", s.handleGetNWFilter())
-		r.Post("/nwfilters", s.handleCreateNWFilter())
-		r.Put("/nwfilters/{name}", s.handleUpdateNWFilter())
-		r.Delete("/nwfilters/{name}", s.handleDeleteNWFilter())
+		if s.imageRepo.Catalogs != nil {
+			r.Get("/image-repository/catalogs", s.handleListImageCatalogs())
+			r.Post("/image-repository/catalogs", s.handleAddImageCatalog())
+			r.Post("/image-repository/catalogs/refresh", s.handleRefreshImageCatalogs())
+			r.Get("/image-repository/catalogs/{name}", s.handleGetImageCatalog())
+			r.Delete("/image-repository/catalogs/{name}", s.handleDeleteImageCatalog())
+			r.Post("/image-repository/catalogs/{name}/refresh", s.handleRefreshImageCatalog())
+		}
 	})
 
 	// Web UI routes with passphrase authentication
@@ -582,6 +750,86 @@ func (s *Server) setupRoutes() {
 	s.router.Mount("/", webRouter)
 }
 
+// setupHostRoutes registers the endpoints that operate on a single libvirt host.
+// They are mounted both at /api and under /api/servers/{serverID}.
+func (s *Server) setupHostRoutes(r chi.Router) {
+	r.Get("/vms", s.handleGetVMs())
+	r.Post("/vms", s.handleCreateVM())
+	r.Post("/vms/from-template", s.handleCreateVMFromTemplate())
+	r.Post("/vms/actions", s.handleBulkVMAction())
+	r.Get("/vms/{uuid}", s.handleGetVMDetails())
+	r.Patch("/vms/{uuid}", s.handleUpdateVM())
+	r.Delete("/vms/{uuid}", s.handleDeleteVM())
+	r.Post("/vms/{uuid}/action", s.handleVMAction())
+	r.Patch("/vms/{uuid}/labels", s.handleUpdateVMLabels())
+	r.Get("/vms/{uuid}/guest-agent/status", s.handleGetGuestAgentStatus())
+	r.Post("/vms/{uuid}/guest-agent/install", s.handleInstallGuestAgent())
+	r.Get("/vms/{uuid}/vnc", s.handleGetVMVNCInfo())
+	r.Get("/vms/{uuid}/console-stream", s.handleGetVMConsoleStream())
+	r.Get("/vms/{uuid}/serial-console", s.handleGetVMSerialConsole())
+	r.Get("/vms/{uuid}/snapshots", s.handleGetVMSnapshots())
+	r.Get("/vms/{uuid}/snapshots/tree", s.handleGetVMSnapshotTree())
+	r.Post("/vms/{uuid}/snapshots", s.handleCreateVMSnapshot())
+	r.Delete("/vms/{uuid}/snapshots/{snapshotName}", s.handleDeleteVMSnapshot())
+	r.Post("/vms/{uuid}/snapshots/{snapshotName}/revert", s.handleRevertToVMSnapshot())
+	r.Post("/vms/{uuid}/clone", s.handleCloneVM())
+	r.Post("/vms/{uuid}/migrate", s.handleMigrateVM())
+	r.Get("/vms/{uuid}/backups", s.handleListVMBackups())
+	r.Post("/vms/{uuid}/backups", s.handleCreateVMBackup())
+	r.Post("/vms/{uuid}/backups/{backupId}/restore", s.handleRestoreVMBackup())
+	r.Get("/backups", s.handleListBackups())
+	r.Post("/vms/{uuid}/export", s.handleExportVM())
+	r.Post("/vms/import", s.handleImportVM())
+	r.Get("/exports", s.handleListExports())
+	r.Get("/exports/{file}", s.handleDownloadExport())
+	r.Put("/exports/{file}", s.handleUploadExport())
+	r.Delete("/exports/{file}", s.handleDeleteExport())
+	r.Get("/vm-templates", s.handleGetVMTemplates())
+	r.Post("/vm-templates", s.handleCreateVMTemplate())
+	r.Delete("/vm-templates/{templateId}", s.handleDeleteVMTemplate())
+	r.Get("/vms/{uuid}/performance", s.handleGetVMPerformance())
+	r.Get("/vms/{uuid}/metrics", s.handleGetVMMetrics())
+	r.Post("/vms/{uuid}/attach-disk", s.handleAttachDiskToVM())
+	r.Post("/vms/{uuid}/attach-network", s.handleAttachNetworkInterfaceToVM())
+	r.Get("/host/status", s.handleGetHostStatus())
+	r.Get("/host/resources", s.handleGetHostResources())
+	r.Get("/storage-pools", s.handleGetStoragePools())
+	r.Post("/storage-pools", s.handleCreateStoragePool())
+	r.Get("/storage-pools/{poolName}/volumes", s.handleGetVolumes())
+	r.Post("/storage-pools/{poolName}/volumes", s.handleCreateVolume())
+	r.Put("/storage-pools/{poolName}/volumes/{volumeName}", s.handleUpdateVolume())
+	r.Delete("/storage-pools/{poolName}/volumes/{volumeName}", s.handleDeleteVolume())
+	r.Get("/networks", s.handleGetNetworks())
+	r.Get("/system-interfaces", s.handleGetSystemInterfaces())
+	r.Post("/networks", s.handleCreateNetwork())
+	r.Post("/bridges", s.handleCreateBridge())
+	r.Put("/networks/{networkName}", s.handleUpdateNetwork())
+	r.Delete("/networks/{networkName}", s.handleDeleteNetwork())
+	r.Get("/images", s.handleGetImages())
+	r.Post("/images/import-from-path", s.handleImportImageFromPath())
+	r.Post("/images/download", s.handleDownloadImage())
+	r.Put("/images/upload", s.handleUploadImage())
+	r.Get("/images/uploads", s.handleListImageUploads())
+	r.Post("/images/uploads", s.handleCreateImageUpload())
+	r.Get("/images/uploads/{uploadId}", s.handleGetImageUpload())
+	r.Head("/images/uploads/{uploadId}", s.handleGetImageUpload())
+	r.Patch("/images/uploads/{uploadId}", s.handlePatchImageUpload())
+	r.Delete("/images/uploads/{uploadId}", s.handleDeleteImageUpload())
+	r.Get("/images/{imageId}", s.handleGetImage())
+	r.Post("/images/{imageId}/convert", s.handleConvertImage())
+	r.Delete("/images/{imageId}", s.handleDeleteImage())
+	r.Get("/activity", s.handleGetActivity())
+	r.Post("/plan", s.handlePlan())
+	r.Post("/apply", s.handleApply())
+
+	// Network filter / Firewall endpoints
+	r.Get("/nwfilters", s.handleListNWFilters())
+	r.Get("/nwfilters/{name}", s.handleGetNWFilter())
+	r.Post("/nwfilters", s.handleCreateNWFilter())
+	r.Put("/nwfilters/{name}", s.handleUpdateNWFilter())
+	r.Delete("/nwfilters/{name}", s.handleDeleteNWFilter())
+}
+
 // Start starts the HTTP server with graceful shutdown
 func (s *Server) Start(addr string) error {
 	if addr == "" {
@@ -631,6 +879,23 @@ func (s *Server) Start(addr string) error {
 		return err
 	}
 
+	if s.metricsSampler != nil {
+		s.metricsSampler.Stop()
+	}
+
+	if s.snapshotScheduler != nil {
+		s.snapshotScheduler.Stop()
+	}
+
+	if s.connectionPool != nil {
+		s.connectionPool.CloseAll()
+	}
+
+	if s.auditStore != nil {
+		activity.SetStore(nil)
+		s.auditStore.Close()
+	}
+
 	logger.Info("Server shutdown complete")
 	return nil
 }
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/logger"
+)
+
+// ServerHeader lets API clients target a registered server without using the
+// /api/servers/{serverID}/... path prefix
+const ServerHeader = "X-Flint-Server"
+
+type contextKey string
+
+const (
+	serverClientKey contextKey = "server_client"
+	serverIDKey     contextKey = "server_id"
+	actorKey        contextKey = "actor"
+)
+
+// serverSelectorMiddleware resolves the target server from the {serverID} path
+// parameter or the X-Flint-Server header and stores its client in the request
+// context. Requests without a selector keep using the server's local client.
+func (s *Server) serverSelectorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		selector := chi.URLParam(r, "serverID")
+		if selector == "" {
+			selector = strings.TrimSpace(r.Header.Get(ServerHeader))
+		}
+		if selector == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		serverID, client, status, err := s.resolveServerClient(selector)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%s"}`, err.Error()), status)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), serverClientKey, client)
+		ctx = context.WithValue(ctx, serverIDKey, serverID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// resolveServerClient looks up a registered server by ID or name and returns its
+// ID and a pooled client for it, along with the HTTP status to use on failure
+func (s *Server) resolveServerClient(selector string) (string, libvirtclient.ClientInterface, int, error) {
+	if s.serverRegistry == nil {
+		return "", nil, http.StatusServiceUnavailable, fmt.Errorf("multi-server mode is not available")
+	}
+
+	server, err := s.serverRegistry.FindServer(selector)
+	if err != nil {
+		return "", nil, http.StatusNotFound, fmt.Errorf("server not found: %s", selector)
+	}
+
+	if s.connectionPool == nil {
+		return "", nil, http.StatusServiceUnavailable, fmt.Errorf("multi-server mode is not available")
+	}
+
+	client, err := s.connectionPool.GetConnection(server)
+	if err != nil {
+		logger.Warn("Failed to resolve server connection", map[string]interface{}{
+			"server_id": server.ID,
+			"error":     err.Error(),
+		})
+		s.serverRegistry.UpdateServerStatus(server.ID, false, err.Error())
+		return "", nil, http.StatusServiceUnavailable, fmt.Errorf("server %s is unavailable", server.Name)
+	}
+
+	// Pooled clients are replaced on reconnect; make sure the current one feeds
+	// /api/events. The local host's events already come from the local connection.
+	if !isLocalURI(server.URI) {
+		s.watchEvents(server.ID, client)
+	}
+
+	return server.ID, client, http.StatusOK, nil
+}
+
+// clientFor returns the libvirt client selected for this request, falling back
+// to the local client when no server selector was given
+func (s *Server) clientFor(r *http.Request) libvirtclient.ClientInterface {
+	if client, ok := r.Context().Value(serverClientKey).(libvirtclient.ClientInterface); ok && client != nil {
+		return client
+	}
+	return s.client
+}
+
+// serverIDFor returns the ID of the registered server selected for this request,
+// or "" when the request targets the local client
+func serverIDFor(r *http.Request) string {
+	if serverID, ok := r.Context().Value(serverIDKey).(string); ok {
+		return serverID
+	}
+	return ""
+}
+
+// isLocalURI reports whether a libvirt URI points at this host (no hostname, e.g. qemu:///system)
+func isLocalURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.Host == ""
+}
+
+// localServer returns the registered server for the local connection, if any
+func (s *Server) localServer() *core.ServerConfig {
+	if s.serverRegistry == nil {
+		return nil
+	}
+	var local *core.ServerConfig
+	for _, server := range s.serverRegistry.ListServers() {
+		if isLocalURI(server.URI) && (local == nil || server.IsDefault) {
+			local = server
+		}
+	}
+	return local
+}
+
+// isLocalServer reports whether a server ID selects the local host: no
+// server, or a registered server with a local URI
+func (s *Server) isLocalServer(serverID string) bool {
+	if serverID == "" || s.serverRegistry == nil {
+		return true
+	}
+	server, err := s.serverRegistry.GetServer(serverID)
+	return err == nil && isLocalURI(server.URI)
+}
+
+// requireLocalServer fails the request with 400 when it targets another host.
+// Consoles read the VM's PTY and VNC socket directly, so they only work for
+// VMs on this machine.
+func (s *Server) requireLocalServer(w http.ResponseWriter, r *http.Request, operation string) bool {
+	if s.isLocalServer(serverIDFor(r)) {
+		return true
+	}
+	http.Error(w, fmt.Sprintf(`{"error": "%s are only supported on the local host"}`, operation), http.StatusBadRequest)
+	return false
+}
+
+// vmPathFor returns the API path of a VM sub-resource, keeping the request's
+// /api/servers/{serverID} prefix so follow-up calls reach the same server
+func vmPathFor(r *http.Request, uuid, suffix string) string {
+	if serverID := serverIDFor(r); serverID != "" {
+		return fmt.Sprintf("/api/servers/%s/vms/%s/%s", serverID, uuid, suffix)
+	}
+	return fmt.Sprintf("/api/vms/%s/%s", uuid, suffix)
+}
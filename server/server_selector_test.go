@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/volantvm/flint/pkg/connectionpool"
+	"github.com/volantvm/flint/pkg/core"
+	"github.com/volantvm/flint/pkg/libvirtclient"
+	"github.com/volantvm/flint/pkg/serverregistry"
+)
+
+func TestServerSelectorMiddleware(t *testing.T) {
+	registry, err := serverregistry.NewRegistry(filepath.Join(t.TempDir(), "servers.json"))
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		server     *Server
+		header     string
+		wantStatus int
+	}{
+		{
+			name:       "no selector uses local client",
+			server:     &Server{serverRegistry: registry},
+			header:     "",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unknown server",
+			server:     &Server{serverRegistry: registry},
+			header:     "does-not-exist",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "multi-server mode disabled",
+			server:     &Server{},
+			header:     "anything",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.server.serverSelectorMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.server.clientFor(r) != tt.server.client {
+					t.Error("clientFor() did not fall back to the local client")
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/vms", nil)
+			if tt.header != "" {
+				req.Header.Set(ServerHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// selectorTestClient is a pooled client standing in for one registered server
+type selectorTestClient struct {
+	libvirtclient.ClientInterface
+	name string
+}
+
+func (c *selectorTestClient) Close() error { return nil }
+
+func TestServerSelectorMiddleware_ResolvesRegisteredServer(t *testing.T) {
+	registry, err := serverregistry.NewRegistry(filepath.Join(t.TempDir(), "servers.json"))
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	hostA, err := registry.AddServer(core.CreateServerRequest{Name: "host-a", URI: "qemu+ssh://root@host-a/system"})
+	if err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+	hostB, err := registry.AddServer(core.CreateServerRequest{Name: "host-b", URI: "qemu+ssh://root@host-b/system"})
+	if err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+
+	pool := connectionpool.NewPoolWithDialer(time.Hour, time.Hour, func(config *core.ServerConfig) (libvirtclient.ClientInterface, error) {
+		return &selectorTestClient{name: config.Name}, nil
+	})
+	defer pool.CloseAll()
+	s := &Server{client: &selectorTestClient{name: "local"}, serverRegistry: registry, connectionPool: pool}
+
+	tests := []struct {
+		name     string
+		serverID string // {serverID} path parameter
+		header   string // X-Flint-Server
+		wantName string
+		wantID   string
+	}{
+		{"path parameter by ID", hostA.ID, "", "host-a", hostA.ID},
+		{"path parameter by name", "host-b", "", "host-b", hostB.ID},
+		{"header by name", "", "host-a", "host-a", hostA.ID},
+		{"header by ID", "", hostB.ID, "host-b", hostB.ID},
+		{"path parameter wins over header", "host-b", "host-a", "host-b", hostB.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotName, gotID string
+			handler := s.serverSelectorMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if client, ok := s.clientFor(r).(*selectorTestClient); ok {
+					gotName = client.name
+				}
+				gotID = serverIDFor(r)
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/vms", nil)
+			if tt.header != "" {
+				req.Header.Set(ServerHeader, tt.header)
+			}
+			if tt.serverID != "" {
+				rctx := chi.NewRouteContext()
+				rctx.URLParams.Add("serverID", tt.serverID)
+				req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			if gotName != tt.wantName || gotID != tt.wantID {
+				t.Errorf("clientFor() = %s, serverIDFor() = %s; want %s, %s", gotName, gotID, tt.wantName, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestRequireLocalServer(t *testing.T) {
+	registry, err := serverregistry.NewRegistry(filepath.Join(t.TempDir(), "servers.json"))
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	local, err := registry.AddServer(core.CreateServerRequest{Name: "local", URI: "qemu:///system", IsDefault: true})
+	if err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+	remote, err := registry.AddServer(core.CreateServerRequest{Name: "host-b", URI: "qemu+ssh://root@host-b/system"})
+	if err != nil {
+		t.Fatalf("AddServer() error = %v", err)
+	}
+	s := &Server{serverRegistry: registry}
+
+	tests := []struct {
+		name     string
+		serverID string
+		want     bool
+		wantPath string
+	}{
+		{"local connection", "", true, "/api/vms/vm-1/serial-console/ws"},
+		{"registered local server", local.ID, true, "/api/servers/" + local.ID + "/vms/vm-1/serial-console/ws"},
+		{"remote server", remote.ID, false, "/api/servers/" + remote.ID + "/vms/vm-1/serial-console/ws"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/vms/vm-1/serial-console/ws", nil)
+			if tt.serverID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), serverIDKey, tt.serverID))
+			}
+			rec := httptest.NewRecorder()
+
+			if got := s.requireLocalServer(rec, req, "serial consoles"); got != tt.want {
+				t.Errorf("requireLocalServer() = %v, want %v", got, tt.want)
+			}
+			if !tt.want && rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if got := vmPathFor(req, "vm-1", "serial-console/ws"); got != tt.wantPath {
+				t.Errorf("vmPathFor() = %s, want %s", got, tt.wantPath)
+			}
+		})
+	}
+}
@@ -62,7 +62,7 @@ export function DashboardView() {
               action: translateActivityAction(event.action),
               target: event.target,
               time: formatTimestamp(event.timestamp),
-              user: "system" // Backend doesn't provide user yet
+              user: event.actor || "system"
             }))
             setRecentActivity(transformedActivity)
           }
@@ -31,12 +31,12 @@ interface VMTemplate {
   id: string
   name: string
   description: string
-  sourceVM: string
+  source_vm: string
   vcpus: number
-  memory: number
-  diskSize: number
-  createdAt: string
-  lastUsed?: string
+  memory_mb: number
+  disk_size_gb: number
+  created_at: string
+  last_used?: string
 }
 
 interface VMTemplatesProps {
@@ -80,7 +80,7 @@ export function VMTemplates({ onLaunchFromTemplate }: VMTemplatesProps) {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({
-          templateId: selectedTemplate.id,
+          template_id: selectedTemplate.id,
           name: newVMName,
         }),
       })
@@ -180,15 +180,15 @@ export function VMTemplates({ onLaunchFromTemplate }: VMTemplatesProps) {
                   </div>
                   <div className="flex items-center gap-1">
                     <MemoryStick className="h-3 w-3" />
-                    {formatMemory(template.memory)}
+                    {formatMemory(template.memory_mb)}
                   </div>
                   <div className="flex items-center gap-1">
                     <HardDrive className="h-3 w-3" />
-                    {template.diskSize}GB
+                    {template.disk_size_gb}GB
                   </div>
                   <div className="flex items-center gap-1">
                     <Clock className="h-3 w-3" />
-                    {new Date(template.createdAt).toLocaleDateString()}
+                    {new Date(template.created_at).toLocaleDateString()}
                   </div>
                 </div>
               </div>
@@ -226,9 +226,9 @@ export function VMTemplates({ onLaunchFromTemplate }: VMTemplatesProps) {
                       <p className="text-sm font-medium mb-2">{t('vm.templateConfiguration')}:</p>
                       <div className="grid grid-cols-2 gap-2 text-sm text-muted-foreground">
                         <span>vCPUs: {template.vcpus}</span>
-                        <span>{t('vm.memory')}: {formatMemory(template.memory)}</span>
-                        <span>{t('vm.disk')}: {template.diskSize}GB</span>
-                        <span>{t('vm.source')}: {template.sourceVM}</span>
+                        <span>{t('vm.memory')}: {formatMemory(template.memory_mb)}</span>
+                        <span>{t('vm.disk')}: {template.disk_size_gb}GB</span>
+                        <span>{t('vm.source')}: {template.source_vm}</span>
                       </div>
                     </div>
                   </div>